@@ -0,0 +1,225 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// CORRUPTKEEP is how long an index set aside as index.corrupt-TIME is kept
+// before maintenance removes it
+const CORRUPTKEEP = 30 * 24 * time.Hour
+
+// INTENTLOGMAX is how big an intent log gets before the replay every run
+// starts with also rewrites it with only what is pending
+const INTENTLOGMAX = 64 << 10
+
+// rotateJournal moves the journal to journal.1, journal.1 to journal.2 and
+// so on once it has reached JournalMaxSize, and drops the generations past
+// JournalGenerations and, with a JournalMaxAge, those last written before
+// it. The stats file takes in what a generation has before it goes, so srm
+// stats still has its days. Two srms rotating at once can shift the
+// generations twice over, which loses nothing but a generation's place.
+func rotateJournal(limits remove.AuxLimits) (string, error) {
+	path, err := journalPath()
+	if err != nil {
+		return "", err
+	}
+	files, err := journalFiles()
+	if err != nil {
+		return "", err
+	}
+	// oldest first, the journal itself last
+	rotated := files[:len(files)-1]
+
+	dropping := []string{}
+	if limits.JournalMaxAge > 0 {
+		for _, name := range rotated {
+			if fi, err := os.Stat(name); err == nil && time.Since(fi.ModTime()) > limits.JournalMaxAge {
+				dropping = append(dropping, name)
+			}
+		}
+	}
+	full := false
+	if fi, err := os.Stat(path); err == nil && limits.JournalMaxSize > 0 && fi.Size() >= limits.JournalMaxSize {
+		full = true
+		// journal.N becomes journal.N+1, and those past the last go
+		for i, name := range rotated {
+			if len(rotated)-i >= limits.JournalGenerations && !remove.In(name, dropping) {
+				dropping = append(dropping, name)
+			}
+		}
+	}
+	if len(dropping) == 0 && !full {
+		return "nothing to rotate", nil
+	}
+
+	if len(dropping) > 0 {
+		if err := foldRotated(dropping); err != nil {
+			return "", fmt.Errorf("keeping the stats of the generations rotated away: %w", err)
+		}
+		for _, name := range dropping {
+			if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return "", err
+			}
+		}
+	}
+	if !full {
+		return fmt.Sprintf("dropped %d old generations", len(dropping)), nil
+	}
+
+	// oldest first, so each moves out of the way of the one after it
+	for _, name := range rotated {
+		if remove.In(name, dropping) {
+			continue
+		}
+		n, _ := strconv.Atoi(strings.TrimPrefix(name, path+"."))
+		if err := os.Rename(name, fmt.Sprintf("%s.%d", path, n+1)); err != nil {
+			return "", err
+		}
+	}
+	if limits.JournalGenerations == 0 {
+		if err := foldRotated([]string{path}); err != nil {
+			return "", fmt.Errorf("keeping the stats of the journal: %w", err)
+		}
+		return "emptied the journal", os.Truncate(path, 0)
+	}
+	if err := os.Rename(path, path+".1"); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("rotated the journal, dropped %d generations", len(dropping)), nil
+}
+
+// rotateAux is the maintenance task keeping srm's own files under their
+// limits: the journal rotated, the stats file cut to StatsMaxAge, the size
+// cache to SizeCacheMax, and indexes set aside as corrupt removed once
+// CORRUPTKEEP old
+func rotateAux(index *remove.Index, journal *Journal) (string, error) {
+	limits := remove.CurrentAuxLimits()
+	done := []string{}
+	summary, err := rotateJournal(limits)
+	if err != nil {
+		return "", fmt.Errorf("journal: %w", err)
+	}
+	if summary != "nothing to rotate" {
+		done = append(done, summary)
+	}
+
+	if dropped, err := trimStats(limits.StatsMaxAge); err != nil {
+		return "", fmt.Errorf("stats: %w", err)
+	} else if dropped > 0 {
+		done = append(done, fmt.Sprintf("dropped %d days from the stats file", dropped))
+	}
+
+	if cache, err := remove.OpenSizeCache(); err == nil {
+		if dropped := cache.Compact(); dropped > 0 {
+			done = append(done, fmt.Sprintf("dropped %d size cache rows", dropped))
+		}
+	}
+
+	dir, err := remove.DataDir()
+	if err != nil {
+		return "", err
+	}
+	corrupt, _ := filepath.Glob(filepath.Join(dir, "index.corrupt-*"))
+	removed := 0
+	for _, name := range corrupt {
+		if fi, err := os.Stat(name); err == nil && time.Since(fi.ModTime()) > CORRUPTKEEP {
+			if err := os.Remove(name); err != nil {
+				return "", err
+			}
+			removed++
+		}
+	}
+	if removed > 0 {
+		done = append(done, fmt.Sprintf("removed %d corrupt indexes set aside", removed))
+	}
+
+	if len(done) == 0 {
+		return "everything under its limit", nil
+	}
+	return strings.Join(done, "; "), nil
+}
+
+// trimStats drops the days of the stats file older than maxAge, returning
+// how many went
+func trimStats(maxAge time.Duration) (int, error) {
+	path, err := statsPath()
+	if err != nil || maxAge == 0 {
+		return 0, err
+	}
+	days, err := loadStats(path)
+	if err != nil {
+		return 0, err
+	}
+	oldest := time.Now().Add(-maxAge).Format(STATSDAY)
+	dropped := 0
+	for day := range days {
+		if day < oldest {
+			delete(days, day)
+			dropped++
+		}
+	}
+	if dropped == 0 {
+		return 0, nil
+	}
+	return dropped, writeStats(path, days)
+}
+
+// AuxUsage is how much one kind of srm's own files takes up
+type AuxUsage struct {
+	Name  string
+	Files int
+	Bytes int64
+}
+
+// auxUsage sums up what is in srm's data dir by kind: the index with its
+// segments and the copies set aside as corrupt, the journal with its generations, the intent
+// logs, and everything else by its name
+func auxUsage() (dir string, usage []AuxUsage, err error) {
+	dir, err = remove.DataDir()
+	if err != nil {
+		return "", nil, err
+	}
+	byName := map[string]*AuxUsage{}
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && path == dir {
+			return filepath.SkipDir
+		}
+		if err != nil || d.IsDir() {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		name, _, _ := strings.Cut(filepath.ToSlash(rel), "/")
+		if strings.HasPrefix(name, "index.corrupt-") || name == "index.d" || name == "index.fold" {
+			name = "index"
+		} else if base, gen, ok := strings.Cut(name, "."); ok && base == "journal" {
+			if _, err := strconv.Atoi(gen); err == nil {
+				name = "journal"
+			}
+		}
+		if byName[name] == nil {
+			byName[name] = &AuxUsage{Name: name}
+		}
+		byName[name].Files++
+		byName[name].Bytes += fi.Size()
+		return nil
+	})
+	for _, u := range byName {
+		usage = append(usage, *u)
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Name < usage[j].Name })
+	return dir, usage, err
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// dataEnv points srm's data dir and config at a scratch directory, and
+// returns the data dir
+func dataEnv(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	t.Setenv("HOME", root)
+	t.Setenv("XDG_DATA_HOME", filepath.Join(root, "data"))
+	t.Setenv(remove.CONFIGENV, filepath.Join(root, "config"))
+	oldSystem := remove.SYSTEMCONFIG
+	remove.SYSTEMCONFIG = filepath.Join(root, "system.config")
+	t.Cleanup(func() { remove.SYSTEMCONFIG = oldSystem })
+	return filepath.Join(root, "data", "srm")
+}
+
+// journalRun journals one run trashing a 10 byte file, as op
+func journalRun(op string) {
+	j := openJournal(op, nil)
+	j.Record(remove.Result{Action: "trashed", Source: "f", Bytes: 10})
+	j.Close()
+}
+
+// journalOps lists the operations in the journal's generations, oldest
+// first
+func journalOps(t *testing.T) []string {
+	t.Helper()
+	ops := []string{}
+	err := readJournal(func(record JournalRecord) {
+		if record.Kind == "start" {
+			ops = append(ops, record.Op)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ops
+}
+
+// The journal rotates once it reaches JournalMaxSize and not before,
+// keeps JournalGenerations generations, and reads back oldest first across
+// all of them, with the stats of dropped generations kept
+func TestRotateJournal(t *testing.T) {
+	dir := dataEnv(t)
+	journal := filepath.Join(dir, "journal")
+
+	journalRun("op0")
+	fi, err := os.Stat(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// between what one run and two runs write, so every other run rotates
+	limits := remove.AuxLimits{JournalMaxSize: fi.Size() * 3 / 2, JournalGenerations: 2}
+	if did, err := rotateJournal(limits); err != nil || did != "nothing to rotate" {
+		t.Errorf("under the size: %q, %v", did, err)
+	}
+	journalRun("op1")
+	if did, err := rotateJournal(limits); err != nil || !strings.HasPrefix(did, "rotated") {
+		t.Errorf("at the size: %q, %v", did, err)
+	}
+	if _, err := os.Stat(journal + ".1"); err != nil {
+		t.Errorf("no journal.1 after rotating: %v", err)
+	}
+	if ops := journalOps(t); strings.Join(ops, " ") != "op0 op1" {
+		t.Errorf("read back %v after one rotation", ops)
+	}
+
+	for i := 2; i < 20; i++ {
+		if _, err := rotateJournal(limits); err != nil {
+			t.Fatal(err)
+		}
+		journalRun(fmt.Sprintf("op%d", i))
+	}
+	files, err := journalFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{journal + ".2", journal + ".1", journal}; strings.Join(files, " ") != strings.Join(want, " ") {
+		t.Errorf("generations %q, want %q", files, want)
+	}
+	// two runs a generation: the last two generations and the journal
+	if ops := journalOps(t); strings.Join(ops, " ") != "op14 op15 op16 op17 op18 op19" {
+		t.Errorf("read back %v", ops)
+	}
+	if ops := []string{}; readJournalFiles(files[:1], func(record JournalRecord) {
+		if record.Kind == "start" {
+			ops = append(ops, record.Op)
+		}
+	}) != nil || strings.Join(ops, " ") != "op14 op15" {
+		t.Errorf("journal.2 alone has %v", ops)
+	}
+
+	days, err := currentStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if today := days[time.Now().Format(STATSDAY)]; today.Operations != 20 || today.Trashed != 200 {
+		t.Errorf("stats for today are %+v, want 20 operations trashing 200 B", today)
+	}
+}
+
+// Generations last written before JournalMaxAge go whatever the size, and
+// with no generations kept the journal is emptied in place
+func TestRotateJournalAgeAndNone(t *testing.T) {
+	dir := dataEnv(t)
+	journal := filepath.Join(dir, "journal")
+	for i := 0; i < 3; i++ {
+		journalRun(fmt.Sprintf("op%d", i))
+		if _, err := rotateJournal(remove.AuxLimits{JournalMaxSize: 1, JournalGenerations: 4}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(journal+".3", old, old); err != nil {
+		t.Fatal(err)
+	}
+	if did, err := rotateJournal(remove.AuxLimits{JournalMaxSize: 1 << 20, JournalGenerations: 4, JournalMaxAge: 24 * time.Hour}); err != nil || did != "dropped 1 old generations" {
+		t.Errorf("past the age: %q, %v", did, err)
+	}
+	if ops := journalOps(t); strings.Join(ops, " ") != "op1 op2" {
+		t.Errorf("read back %v after dropping the oldest", ops)
+	}
+
+	journalRun("op3")
+	if did, err := rotateJournal(remove.AuxLimits{JournalMaxSize: 1}); err != nil || did != "emptied the journal" {
+		t.Errorf("with no generations: %q, %v", did, err)
+	}
+	if fi, err := os.Stat(journal); err != nil || fi.Size() != 0 {
+		t.Errorf("journal not emptied: %v", err)
+	}
+	days, err := currentStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if today := days[time.Now().Format(STATSDAY)]; today.Operations != 4 {
+		t.Errorf("stats for today are %+v, want 4 operations", today)
+	}
+}
+
+// The stats file drops days past stats_max_age
+func TestTrimStats(t *testing.T) {
+	dataEnv(t)
+	journalRun("op")
+	if _, err := rotateJournal(remove.AuxLimits{JournalMaxSize: 1, JournalGenerations: 0}); err != nil {
+		t.Fatal(err)
+	}
+	days, err := currentStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := statsPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	days["2001-01-01"] = DayStats{Day: "2001-01-01", Operations: 1}
+	if err := writeStats(path, days); err != nil {
+		t.Fatal(err)
+	}
+	if dropped, err := trimStats(remove.DEFAULTAUXLIMITS.StatsMaxAge); dropped != 1 || err != nil {
+		t.Errorf("dropped %d days, %v, want 2001-01-01 dropped", dropped, err)
+	}
+	days, err = currentStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := days["2001-01-01"]; ok || days[time.Now().Format(STATSDAY)].Operations != 1 {
+		t.Errorf("stats after trimming are %+v", days)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// backendName is --backend, or backend from the config when it isn't
+// given, PLATFORMBACKEND when neither is. It isn't opened until operands
+// are stored in it, see OpenBackend.
+func backendName(flags []string, config remove.Config) (string, error) {
+	name, ok := remove.FlagValue("--backend", flags)
+	if !ok {
+		name = config["backend"]
+	}
+	if name == "" {
+		name = remove.PLATFORMBACKEND
+	}
+	if name == remove.DEFAULTBACKEND {
+		return remove.DEFAULTBACKEND, nil
+	}
+	specs, err := remove.ConfiguredBackends(config)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := specs[name]; !ok {
+		return "", fmt.Errorf("no backend %q, add backend[%s] = KIND:ARG to the config", name, name)
+	}
+	return name, nil
+}
+
+// backendCommand
+// srm backend [NAME [list | stats | restore ID [DEST] | purge ID]]
+// without a NAME, shows each configured backend with its stats. With one,
+// lists its entries (the default), sums it up, puts an entry back at DEST
+// (its origin when not given) or drops it for good.
+func backendCommand(args []string) {
+	_, rest := parseArgs(args)
+	settings, err := remove.LoadSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm backend: %s\n", err)
+		os.Exit(1)
+	}
+	specs, err := remove.ConfiguredBackends(settings.Config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm backend: %s\n", err)
+		os.Exit(1)
+	}
+
+	if len(rest) == 0 {
+		names := []string{}
+		for name := range specs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Printf("%-12s  %-30s  %7s  %10s  %10s\n", "name", "spec", "entries", "bytes", "stored")
+		fmt.Printf("%-12s  %-30s  %7s  %10s  %10s\n", remove.DEFAULTBACKEND, "(srm list)", "-", "-", "-")
+		for _, name := range names {
+			backend, err := remove.OpenBackend(name, settings.Config)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "srm backend: %s\n", err)
+				continue
+			}
+			stats, err := backend.Stats()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "srm backend: %s: %s\n", name, err)
+				continue
+			}
+			fmt.Printf("%-12s  %-30s  %7d  %10s  %10s\n", name, specs[name], stats.Entries, remove.FormatSize(stats.Bytes), remove.FormatSize(stats.Stored))
+		}
+		return
+	}
+
+	name, action := rest[0], "list"
+	if len(rest) > 1 {
+		action = rest[1]
+	}
+	if name == remove.DEFAULTBACKEND {
+		fmt.Fprintln(os.Stderr, "srm backend: the trash is srm list, -W and srm empty's")
+		os.Exit(1)
+	}
+	backend, err := remove.OpenBackend(name, settings.Config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm backend: %s\n", err)
+		os.Exit(1)
+	}
+	want := map[string][2]int{"list": {2, 2}, "stats": {2, 2}, "restore": {3, 4}, "purge": {3, 3}}
+	bounds, ok := want[action]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "srm backend: unknown action %q, expected list, stats, restore or purge\n", action)
+		os.Exit(1)
+	}
+	if len(rest) < bounds[0] && action != "list" || len(rest) > bounds[1] {
+		fmt.Fprintf(os.Stderr, "srm backend: usage: srm backend %s %s\n", name, map[string]string{"list": "list", "stats": "stats", "restore": "restore ID [DEST]", "purge": "purge ID"}[action])
+		os.Exit(1)
+	}
+
+	switch action {
+	case "list":
+		entries, err := backend.List()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "srm backend: %s\n", err)
+			os.Exit(1)
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Deleted.Before(entries[j].Deleted) })
+		for _, entry := range entries {
+			origin := entry.Origin
+			if entry.IsDir {
+				origin += "/"
+			}
+			fmt.Printf("%s  %s  %8s  %s\n", entry.ID, entry.Deleted.Format("2006-01-02 15:04:05"), remove.FormatSize(entry.Size), origin)
+		}
+	case "stats":
+		stats, err := backend.Stats()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "srm backend: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("entries: %d\nbytes:   %s\nstored:  %s\n", stats.Entries, remove.FormatSize(stats.Bytes), remove.FormatSize(stats.Stored))
+	case "restore":
+		id := remove.EntryID(rest[2])
+		dst := ""
+		if len(rest) > 3 {
+			if dst, err = filepath.Abs(rest[3]); err != nil {
+				fmt.Fprintf(os.Stderr, "srm backend: %s\n", err)
+				os.Exit(1)
+			}
+		} else {
+			entries, err := backend.List()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "srm backend: %s\n", err)
+				os.Exit(1)
+			}
+			for _, entry := range entries {
+				if entry.ID == id {
+					dst = entry.Origin
+				}
+			}
+			if dst == "" {
+				fmt.Fprintf(os.Stderr, "srm backend: %s: %s\n", id, remove.ErrNoEntry)
+				os.Exit(1)
+			}
+		}
+		if err := backend.Restore(id, dst); err != nil {
+			fmt.Fprintf(os.Stderr, "srm backend: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("restored %s to %s\n", id, remove.DisplayPath(dst))
+	case "purge":
+		if err := backend.Purge(remove.EntryID(rest[2])); err != nil {
+			fmt.Fprintf(os.Stderr, "srm backend: %s\n", err)
+			os.Exit(1)
+		}
+	}
+}
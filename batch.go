@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// readNULBatches calls fn with the NUL-terminated paths read from r as they
+// arrive, for --batch-stdin: each batch is one path and those that came
+// right behind it, up to BATCHSIZE, so a fast producer gets RemoveEach's
+// shared writes and a slow one has each path removed without waiting for
+// the next. A last path without its NUL counts too, as with xargs -0.
+func readNULBatches(r io.Reader, fn func(paths []string)) error {
+	br := bufio.NewReaderSize(r, 64*1024)
+	for {
+		path, err := br.ReadString(0)
+		if err == io.EOF {
+			if path != "" {
+				fn([]string{path})
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		batch := []string{strings.TrimSuffix(path, "\x00")}
+		for len(batch) < remove.BATCHSIZE {
+			// only what is already read, never waiting for more
+			buffered, _ := br.Peek(br.Buffered())
+			if bytes.IndexByte(buffered, 0) < 0 {
+				break
+			}
+			path, _ := br.ReadString(0)
+			batch = append(batch, strings.TrimSuffix(path, "\x00"))
+		}
+		fn(batch)
+	}
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// bump when the bundle layout changes; import refuses anything newer
+const BUNDLEVERSION = 1
+
+// BundleManifest is the first member of every bundle
+type BundleManifest struct {
+	Version int           `json:"version"`
+	Entries []BundleEntry `json:"entries"`
+}
+
+// BundleEntry is an index row plus the checksum of its payload, stored in the
+// bundle under payload/<ID>/<Name>
+type BundleEntry struct {
+	remove.IndexEntry
+	Checksum string `json:"checksum"`
+}
+
+// exportCommand
+// srm export <entry ...> -o bundle.tar
+func exportCommand(args []string) {
+	flags, queries := parseArgs(args)
+	output, ok := remove.FlagValue("--output", flags)
+	if !ok || len(queries) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: srm export <entry ...> -o bundle.tar")
+		os.Exit(1)
+	}
+
+	index, err := remove.OpenIndex()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm export: %s\n", err)
+		os.Exit(1)
+	}
+	entries, err := index.Entries()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm export: %s\n", err)
+		os.Exit(1)
+	}
+
+	selected := []remove.IndexEntry{}
+	for _, query := range queries {
+		matches := resolveEntries(entries, query)
+		if len(matches) == 0 {
+			fmt.Fprintf(os.Stderr, "srm export: %s: not in the trash\n", query)
+			os.Exit(1)
+		}
+		if len(matches) > 1 {
+			fmt.Fprintf(os.Stderr, "srm export: %s is ambiguous:\n", query)
+			for _, m := range matches {
+				fmt.Fprintf(os.Stderr, "    %s  %s\n", m.ID, m.Origin)
+			}
+			os.Exit(1)
+		}
+		selected = append(selected, matches[0])
+	}
+
+	if err := writeBundle(remove.OSFS{}, output, selected); err != nil {
+		os.Remove(output)
+		fmt.Fprintf(os.Stderr, "srm export: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("exported %d entries to %s\n", len(selected), output)
+}
+
+// importCommand
+// srm import bundle.tar
+func importCommand(args []string) {
+	_, bundles := parseArgs(args)
+	if len(bundles) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: srm import bundle.tar")
+		os.Exit(1)
+	}
+
+	trashDir, err := remove.FindTrashDir()
+	if err == nil {
+		// the index can't vouch for payloads others could swap
+		if err = remove.CheckPrivateDir(trashDir); !errors.Is(err, remove.ErrNotPrivate) {
+			err = nil
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm import: %s\n", err)
+		os.Exit(1)
+	}
+	index, err := remove.OpenIndex()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm import: %s\n", err)
+		os.Exit(1)
+	}
+
+	imported, err := readBundle(remove.OSFS{}, bundles[0], trashDir, index)
+	for _, entry := range imported {
+		fmt.Printf("imported %s as %s\n", remove.DisplayName(entry.Origin), remove.DisplayName(entry.Payload()))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm import: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// resolveEntries finds the entries a user means by query: an exact entry ID,
+// a name in the trash, or an original path (absolute or relative to here)
+func resolveEntries(entries []remove.IndexEntry, query string) []remove.IndexEntry {
+	for _, entry := range entries {
+		if entry.ID == query {
+			return []remove.IndexEntry{entry}
+		}
+	}
+
+	abs, _ := filepath.Abs(query)
+	matches := []remove.IndexEntry{}
+	for _, entry := range entries {
+		if entry.Name == query || entry.Origin == abs {
+			matches = append(matches, entry)
+		}
+	}
+	return matches
+}
+
+// payloadChecksum hashes a payload. Directories hash every member's relative
+// path, type and contents in name order, so two trees with the same contents
+// hash the same wherever they live.
+func payloadChecksum(fsys remove.FS, root string) (string, error) {
+	h := sha256.New()
+
+	var walk func(p string, rel string) error
+	walk = func(p string, rel string) error {
+		fi, err := fsys.Lstat(p)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%s\x00", rel, typeChar(fi.Mode()))
+
+		switch {
+		case fi.Mode().IsRegular():
+			f, err := fsys.Open(p)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(h, f)
+			f.Close()
+			return err
+		case fi.Mode()&fs.ModeSymlink != 0:
+			target, err := fsys.Readlink(p)
+			h.Write([]byte(target))
+			return err
+		case fi.IsDir():
+			children, err := fsys.ReadDir(p)
+			if err != nil {
+				return err
+			}
+			for _, child := range children {
+				if err := walk(filepath.Join(p, child.Name()), path.Join(rel, child.Name())); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := walk(root, "."); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// typeChar
+// regular file --> "f", directory --> "d", symlink --> "l", anything else --> "o"
+func typeChar(mode fs.FileMode) string {
+	switch {
+	case mode.IsRegular():
+		return "f"
+	case mode.IsDir():
+		return "d"
+	case mode&fs.ModeSymlink != 0:
+		return "l"
+	}
+	return "o"
+}
+
+// writeBundle writes the manifest and then every payload to a tarball at out
+func writeBundle(fsys remove.FS, out string, entries []remove.IndexEntry) error {
+	manifest := BundleManifest{Version: BUNDLEVERSION}
+	for _, entry := range entries {
+		sum, err := payloadChecksum(fsys, entry.Payload())
+		if err != nil {
+			return err
+		}
+		manifest.Entries = append(manifest.Entries, BundleEntry{IndexEntry: entry.EncodeRaw(), Checksum: sum})
+	}
+
+	f, err := fsys.Create(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	tw := tar.NewWriter(f)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{Name: "manifest.json", Mode: 0600, Size: int64(len(data)), Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		prefix := path.Join("payload", entry.ID)
+		err := filepath.WalkDir(entry.Payload(), func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			fi, err := fsys.Lstat(p)
+			if err != nil {
+				return err
+			}
+			link := ""
+			if fi.Mode()&fs.ModeSymlink != 0 {
+				if link, err = fsys.Readlink(p); err != nil {
+					return err
+				}
+			}
+			hdr, err := tar.FileInfoHeader(fi, link)
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(entry.Trash, p)
+			hdr.Name = path.Join(prefix, filepath.ToSlash(rel))
+			if fi.IsDir() {
+				hdr.Name += "/"
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if !fi.Mode().IsRegular() {
+				return nil
+			}
+			src, err := fsys.Open(p)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(tw, src)
+			src.Close()
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// readBundle unpacks a bundle into trashDir, giving each payload a free name,
+// checking it against the manifest checksum and adding it to the index.
+// Entries unpacked before an error are returned along with it.
+func readBundle(fsys remove.FS, bundle string, trashDir string, index *remove.Index) ([]remove.IndexEntry, error) {
+	f, err := fsys.Open(bundle)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	tr := tar.NewReader(f)
+
+	hdr, err := tr.Next()
+	if err != nil || hdr.Name != "manifest.json" {
+		return nil, fmt.Errorf("%s: not an srm bundle", bundle)
+	}
+	var manifest BundleManifest
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("%s: bad manifest: %w", bundle, err)
+	}
+	for i := range manifest.Entries {
+		manifest.Entries[i].DecodeRaw()
+	}
+	if manifest.Version > BUNDLEVERSION {
+		return nil, fmt.Errorf("%s: bundle version %d is newer than this srm understands (%d)", bundle, manifest.Version, BUNDLEVERSION)
+	}
+	// a name is joined to the trash, so anything but one plain component
+	// could put the payload somewhere else
+	for _, entry := range manifest.Entries {
+		if entry.Name == "." || entry.Name != filepath.Base(entry.Name) || !filepath.IsLocal(entry.Name) {
+			return nil, fmt.Errorf("%s: bad name %q in manifest", bundle, entry.Name)
+		}
+	}
+
+	existing, err := index.Entries()
+	if err != nil {
+		return nil, err
+	}
+	taken := map[string]bool{}
+	for _, entry := range existing {
+		taken[entry.ID] = true
+	}
+
+	// payloads are unpacked into a staging dir per entry and renamed into
+	// place once their checksum matches
+	staging := map[string]string{}
+	byID := map[string]BundleEntry{}
+	for _, entry := range manifest.Entries {
+		byID[entry.ID] = entry
+		dir, err := os.MkdirTemp(trashDir, ".srm-import-")
+		if err != nil {
+			return nil, err
+		}
+		defer fsys.RemoveAll(dir)
+		staging[entry.ID] = dir
+	}
+
+	// directories get their real modes last so read-only ones could still be
+	// filled
+	dirModes := map[string]fs.FileMode{}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := path.Clean(hdr.Name)
+		parts := strings.SplitN(name, "/", 3)
+		if len(parts) < 3 || parts[0] != "payload" {
+			return nil, fmt.Errorf("%s: unexpected member %s", bundle, hdr.Name)
+		}
+		dir, ok := staging[parts[1]]
+		if !ok {
+			return nil, fmt.Errorf("%s: member %s has no manifest entry", bundle, hdr.Name)
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			dirModes[filepath.Join(dir, filepath.FromSlash(parts[2]))] = remove.TarMode(hdr)
+		}
+		if err := remove.ExtractMember(fsys, tr, hdr, dir, parts[2]); err != nil {
+			return nil, fmt.Errorf("%s: %w", bundle, err)
+		}
+	}
+
+	for _, dir := range staging {
+		if err := remove.RestoreDirModes(fsys, dir, dirModes); err != nil {
+			return nil, err
+		}
+	}
+
+	imported := []remove.IndexEntry{}
+	for _, bundled := range manifest.Entries {
+		dir := staging[bundled.ID]
+		payload := filepath.Join(dir, bundled.Name)
+
+		sum, err := payloadChecksum(fsys, payload)
+		if err != nil {
+			return imported, err
+		}
+		if sum != bundled.Checksum {
+			return imported, fmt.Errorf("%s: checksum mismatch, bundle is corrupt", bundled.Origin)
+		}
+
+		entry := bundled.IndexEntry
+		entry.Trash = trashDir
+		entry.Name, err = remove.MoveToFreeName(fsys, trashDir, bundled.Name, entry.Origin, entry.Deleted, func(dest string) error {
+			return fsys.RenameNoReplace(payload, dest)
+		})
+		if err != nil {
+			return imported, err
+		}
+		if taken[entry.ID] {
+			entry.ID = remove.NewEntryID()
+		}
+		if err := index.Append(entry); err != nil {
+			return imported, err
+		}
+		taken[entry.ID] = true
+		imported = append(imported, entry)
+	}
+
+	return imported, nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// bundleMember is one member of a bundle a test writes after its manifest
+type bundleMember struct {
+	name string
+	typ  byte
+	link string
+	body string
+}
+
+// writeTestBundle writes a bundle of entries and members to path, the way
+// a hostile one would be: whatever the manifest says, unchecked
+func writeTestBundle(t *testing.T, path string, entries []BundleEntry, members []bundleMember) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	data, err := json.Marshal(BundleManifest{Version: BUNDLEVERSION, Entries: entries})
+	if err != nil {
+		t.Fatal(err)
+	}
+	members = append([]bundleMember{{name: "manifest.json", typ: tar.TypeReg, body: string(data)}}, members...)
+	for _, m := range members {
+		hdr := &tar.Header{Name: m.name, Typeflag: m.typ, Linkname: m.link, Mode: 0644, Size: int64(len(m.body))}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(m.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// A bundle can't put anything outside the trash it is imported into, by a
+// symlink among its members or by the names in its manifest
+func TestReadBundleUnsafe(t *testing.T) {
+	entry := func(name string) []BundleEntry {
+		return []BundleEntry{{IndexEntry: remove.IndexEntry{ID: "abc", Name: name, Origin: "/tmp/x"}, Checksum: "0"}}
+	}
+	tests := []struct {
+		name    string
+		entries []BundleEntry
+		members []bundleMember
+	}{
+		{"a file through a symlinked payload", entry("x"), []bundleMember{
+			{name: "payload/abc/x", typ: tar.TypeSymlink, link: "VICTIM"},
+			{name: "payload/abc/x/owned", typ: tar.TypeReg, body: "owned"}}},
+		{"a member above its staging directory", entry("x"), []bundleMember{
+			{name: "payload/abc/../../owned", typ: tar.TypeReg, body: "owned"}}},
+		{"a name leading out of the trash", entry("../owned"), []bundleMember{
+			{name: "payload/abc/x", typ: tar.TypeReg, body: "owned"}}},
+		{"a name of several components", entry("sub/owned"), nil},
+		{"a name of ..", entry(".."), nil},
+		{"a name of .", entry("."), nil},
+		{"an absolute name", entry("/owned"), nil},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		trash, victim := filepath.Join(dir, "trash"), filepath.Join(dir, "victim")
+		for _, d := range []string{trash, victim} {
+			if err := os.Mkdir(d, 0700); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i := range tt.members {
+			tt.members[i].link = strings.Replace(tt.members[i].link, "VICTIM", victim, 1)
+		}
+		bundle := filepath.Join(dir, "bundle.tar")
+		writeTestBundle(t, bundle, tt.entries, tt.members)
+
+		index := remove.NewIndex(filepath.Join(dir, "index"))
+		imported, err := readBundle(remove.OSFS{}, bundle, trash, index)
+		if err == nil || len(imported) != 0 {
+			t.Errorf("%s: imported %v, %v", tt.name, imported, err)
+		}
+		for _, owned := range []string{filepath.Join(victim, "owned"), filepath.Join(dir, "owned")} {
+			if _, err := os.Lstat(owned); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("%s: wrote %s", tt.name, owned)
+			}
+		}
+	}
+}
+
+// A bundle written by writeBundle imports, under the name it had
+func TestReadBundle(t *testing.T) {
+	dir := t.TempDir()
+	from, to := filepath.Join(dir, "from"), filepath.Join(dir, "to")
+	for _, d := range []string{filepath.Join(from, "notes", "sub"), to} {
+		if err := os.MkdirAll(d, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(from, "notes", "sub", "a"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	entry := remove.IndexEntry{ID: "abc", Trash: from, Name: "notes", Origin: "/tmp/notes"}
+	bundle := filepath.Join(dir, "bundle.tar")
+	if err := writeBundle(remove.OSFS{}, bundle, []remove.IndexEntry{entry}); err != nil {
+		t.Fatal(err)
+	}
+
+	imported, err := readBundle(remove.OSFS{}, bundle, to, remove.NewIndex(filepath.Join(dir, "index")))
+	if err != nil || len(imported) != 1 {
+		t.Fatalf("imported %v, %v", imported, err)
+	}
+	if got, err := os.ReadFile(filepath.Join(imported[0].Payload(), "sub", "a")); err != nil || string(got) != "a" {
+		t.Errorf("imported notes/sub/a holds %q, %v", got, err)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// configCommand
+// srm config
+// prints the effective settings and which file each one came from
+func configCommand(args []string) {
+	_, rest := parseArgs(args)
+	if len(rest) > 0 {
+		fmt.Fprintf(os.Stderr, "srm config: unexpected argument %s\n", rest[0])
+		os.Exit(1)
+	}
+
+	settings, err := remove.LoadSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm config: %s\n", err)
+		os.Exit(1)
+	}
+
+	for _, key := range settings.Keys() {
+		fmt.Printf("%s = %s  # %s\n", key, settings.Config[key], settings.Describe(key))
+	}
+	for key, value := range settings.Overridden {
+		if _, ok := settings.Config[key]; !ok {
+			fmt.Printf("# %s = %s ignored, locked by %s\n", key, value, remove.SYSTEMCONFIG)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+//go:build conformance
+
+package main
+
+// The conformance test runs a matrix of fixtures and flag sets through rm
+// and srm, in fresh copies of the same tree, and compares exit codes,
+// diagnostics (with the "rm: "/"srm: " prefix stripped) and what is left
+// of the tree. Where rm's files are gone, srm's must be in its trash: that
+// is the one difference expected. tests/rm-parity.sh has the hand-picked
+// cases; this is the cross product, to catch what nobody thought to pick.
+//
+//	go test -tags=conformance -run Conformance
+//
+// SRM names the srm to test, or one is built; RM names the rm, the one on
+// PATH by default. Every combination runs twice, in srm's own mode and
+// under SRM_POSIX=1, which also compares prompts and stdout. Answers are
+// piped to both, srm's own mode taking them from stdin by
+// SRM_ANSWERS=/dev/stdin rather than from the terminal. The combinations
+// that differ on purpose in srm's own mode are skipped by
+// conformanceDivergent. Write-protected fixtures are skipped as root, who
+// is never asked about them.
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// conformanceFixture is a tree built in the current directory and the
+// operands run against it
+type conformanceFixture struct {
+	name     string
+	setup    func() error
+	operands []string
+}
+
+// touch creates each of names empty
+func touch(names ...string) error {
+	for _, name := range names {
+		if err := os.WriteFile(name, nil, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CONFORMANCEFIXTURES include rm -- --, where only the first -- ends the
+// options
+var CONFORMANCEFIXTURES = []conformanceFixture{
+	{"file", func() error { return os.WriteFile("file", []byte("data\n"), 0644) },
+		[]string{"file"}},
+	{"write-protected file", func() error { return os.WriteFile("file", []byte("data\n"), 0444) },
+		[]string{"file"}},
+	{"empty directory", func() error { return os.Mkdir("dir", 0755) },
+		[]string{"dir"}},
+	{"full directory", func() error {
+		if err := os.MkdirAll("dir/sub", 0755); err != nil {
+			return err
+		}
+		return touch("dir/sub/file")
+	},
+		[]string{"dir"}},
+	{"missing", func() error { return touch("file") },
+		[]string{"missing"}},
+	{"missing and present", func() error { return touch("file") },
+		[]string{"missing", "file"}},
+	{"directory and file", func() error {
+		if err := os.Mkdir("dir", 0755); err != nil {
+			return err
+		}
+		return touch("file")
+	},
+		[]string{"dir", "file"}},
+	{"symlink to a directory", func() error {
+		if err := os.Mkdir("dir", 0755); err != nil {
+			return err
+		}
+		if err := touch("dir/file"); err != nil {
+			return err
+		}
+		return os.Symlink("dir", "link")
+	},
+		[]string{"link"}},
+	{"dangling symlink", func() error { return os.Symlink("missing", "link") },
+		[]string{"link"}},
+	{"path through a file", func() error { return touch("file") },
+		[]string{"file/x"}},
+	{"file named like a flag", func() error { return touch("-x", "file") },
+		[]string{"--", "-x", "file"}},
+	{"file named --", func() error { return touch("--", "file") },
+		[]string{"--", "--"}},
+	{"file named -- among others", func() error { return touch("--", "file") },
+		[]string{"--", "file", "--"}},
+}
+
+// CONFORMANCEFLAGS are run with each fixture; CONFORMANCEINPUT answers any
+// prompt
+var CONFORMANCEFLAGS = [][]string{
+	{},
+	{"-f"},
+	{"-r"},
+	{"-d"},
+	{"-rf"},
+	{"-v"},
+	{"-i"},
+	{"-f", "-i"},
+	{"-i", "-f"},
+	{"-r", "-i"},
+}
+
+const CONFORMANCEINPUT = "y\ny\ny\ny\n"
+
+// conformanceDivergent says whether srm differs from rm for mode, fixture
+// and flags on purpose. In its own mode it asks its own questions in its
+// own words, and refuses a read-only file without -f instead of asking only
+// on a terminal.
+func conformanceDivergent(mode string, fixture string, flags []string) bool {
+	if mode != "srm" {
+		return false
+	}
+	switch strings.Join(flags, " ") {
+	case "-i", "-f -i", "-r -i":
+		return true
+	case "", "-r", "-d", "-v":
+		return fixture == "write-protected file"
+	}
+	return false
+}
+
+// conformanceSRM is the srm to test: $SRM, or one built from this package
+func conformanceSRM(t *testing.T) string {
+	if path := os.Getenv("SRM"); path != "" {
+		path, err := filepath.Abs(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	path := filepath.Join(t.TempDir(), "srm")
+	if out, err := exec.Command("go", "build", "-o", path, ".").CombinedOutput(); err != nil {
+		t.Fatalf("building srm: %v\n%s", err, out)
+	}
+	return path
+}
+
+// conformanceRun is what one run of a tool left: its exit status, its
+// diagnostics without the prefix, its stdout, and the tree
+type conformanceRun struct {
+	status int
+	msg    string
+	out    string
+	tree   []string
+}
+
+var conformancePrefix = regexp.MustCompile(`(?m)^s?rm: `)
+
+// prompts run together on one line, each with the prefix
+var conformancePromptPrefix = regexp.MustCompile(`\? s?rm: `)
+
+// listTree is every path under dir, relative and sorted, as find . | sort
+// has it
+func listTree(dir string) ([]string, error) {
+	tree := []string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel != "." {
+			rel = "./" + filepath.ToSlash(rel)
+		}
+		tree = append(tree, rel)
+		return nil
+	})
+	slices.Sort(tree)
+	return tree, err
+}
+
+// inDir runs fn in dir; the caller can't run in parallel with anything
+// else that depends on the working directory
+func inDir(dir string, fn func() error) error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if err := os.Chdir(dir); err != nil {
+		return err
+	}
+	defer os.Chdir(wd)
+	return fn()
+}
+
+// runConformance runs the command in a fresh copy of fixture under dir
+func runConformance(dir string, fixture conformanceFixture, command []string, env []string) (conformanceRun, error) {
+	cwd := filepath.Join(dir, "cwd")
+	for _, d := range []string{filepath.Join(dir, "home", ".Trash"), cwd} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return conformanceRun{}, err
+		}
+	}
+	if err := inDir(cwd, fixture.setup); err != nil {
+		return conformanceRun{}, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Dir, cmd.Env = cwd, append(os.Environ(), env...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = strings.NewReader(CONFORMANCEINPUT), &stdout, &stderr
+	run := conformanceRun{}
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return run, err
+		}
+		run.status = exitErr.ExitCode()
+	}
+	run.out = stdout.String()
+	run.msg = conformancePromptPrefix.ReplaceAllString(conformancePrefix.ReplaceAllString(stderr.String(), ""), "? ")
+	tree, err := listTree(cwd)
+	run.tree = tree
+	return run, err
+}
+
+func TestConformance(t *testing.T) {
+	srm := conformanceSRM(t)
+	rm := os.Getenv("RM")
+	if rm == "" {
+		rm = "rm"
+	}
+	if _, err := exec.LookPath(rm); err != nil {
+		t.Skipf("no rm to compare with: %v", err)
+	}
+
+	for _, mode := range []string{"srm", "posix"} {
+		for _, fixture := range CONFORMANCEFIXTURES {
+			for _, flags := range CONFORMANCEFLAGS {
+				label := mode + "/" + fixture.name
+				if len(flags) > 0 {
+					label += " with " + strings.Join(flags, " ")
+				}
+				t.Run(label, func(t *testing.T) {
+					if strings.HasPrefix(fixture.name, "write-protected") && os.Geteuid() == 0 {
+						t.Skip("root is never asked about a write-protected file")
+					}
+					if conformanceDivergent(mode, fixture.name, flags) {
+						t.Skip("divergent on purpose")
+					}
+					testConformance(t, srm, rm, mode, fixture, flags)
+				})
+			}
+		}
+	}
+}
+
+// testConformance runs one combination through both and compares them
+func testConformance(t *testing.T, srm string, rm string, mode string, fixture conformanceFixture, flags []string) {
+	work := t.TempDir()
+	t.Cleanup(func() {
+		// a write-protected fixture left behind by either
+		filepath.WalkDir(work, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && d.Type()&fs.ModeSymlink == 0 {
+				os.Chmod(path, 0755)
+			}
+			return nil
+		})
+	})
+	args := append(slices.Clone(flags), fixture.operands...)
+
+	setup := filepath.Join(work, "setup")
+	if err := os.MkdirAll(setup, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := inDir(setup, fixture.setup); err != nil {
+		t.Fatal(err)
+	}
+	before, err := listTree(setup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rmRun, err := runConformance(filepath.Join(work, "rm"), fixture, append([]string{rm}, args...), nil)
+	if err != nil {
+		t.Fatalf("rm: %v", err)
+	}
+	srmHome := filepath.Join(work, "srm", "home")
+	command, env := []string{srm, "--quiet"}, []string{"HOME=" + srmHome, "SRM_ANSWERS=/dev/stdin"}
+	if mode == "posix" {
+		command, env = []string{srm}, []string{"HOME=" + srmHome, "SRM_POSIX=1"}
+	}
+	srmRun, err := runConformance(filepath.Join(work, "srm"), fixture, append(command, args...), env)
+	if err != nil {
+		t.Fatalf("srm: %v", err)
+	}
+
+	if rmRun.status != srmRun.status {
+		t.Errorf("rm exited %d, srm %d", rmRun.status, srmRun.status)
+	}
+	if rmRun.msg != srmRun.msg {
+		t.Errorf("the diagnostics differ:\nrm:\n%s\nsrm:\n%s", rmRun.msg, srmRun.msg)
+	}
+	if !slices.Equal(rmRun.tree, srmRun.tree) {
+		t.Errorf("the trees differ:\nrm:  %q\nsrm: %q", rmRun.tree, srmRun.tree)
+	}
+	if mode == "posix" && rmRun.out != srmRun.out {
+		t.Errorf("the output differs:\nrm:\n%s\nsrm:\n%s", rmRun.out, srmRun.out)
+	}
+
+	// every operand srm removed is in its trash under its own name, the
+	// trash starting out empty
+	operands := fixture.operands
+	if operands[0] == "--" {
+		operands = operands[1:]
+	}
+	for _, operand := range operands {
+		if !slices.Contains(before, "./"+operand) || slices.Contains(srmRun.tree, "./"+operand) {
+			continue
+		}
+		if _, err := os.Lstat(filepath.Join(srmHome, ".Trash", filepath.Base(operand))); err != nil {
+			t.Errorf("%s is gone but not in the trash: %v", operand, err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// doctorCommand
+// srm doctor
+// reports where srm puts things and whether they are usable
+func doctorCommand(args []string) {
+	check := func(name, status string) {
+		fmt.Printf("%-12s %s\n", name+":", status)
+	}
+
+	if dir, err := remove.FindTrashDir(); err != nil {
+		check("trash", err.Error())
+	} else if err := remove.CheckPrivateDir(dir); errors.Is(err, remove.ErrNotPrivate) {
+		check("trash", dir+" (group or world writable, its entries are not indexed)")
+	} else {
+		check("trash", dir)
+	}
+
+	// the journal, index and intent logs live here and must be ours alone
+	if dir, err := remove.DataDir(); err != nil {
+		check("data", err.Error())
+	} else if err := remove.CheckPrivateDir(dir); errors.Is(err, fs.ErrNotExist) {
+		check("data", dir+" (not created yet)")
+	} else if err != nil {
+		check("data", err.Error())
+	} else {
+		check("data", dir)
+	}
+
+	if path, err := journalPath(); err != nil {
+		check("journal", err.Error())
+	} else if _, err := os.Stat(path); err != nil {
+		check("journal", path+" (not written yet)")
+	} else {
+		check("journal", path)
+	}
+
+	if index, err := remove.OpenIndex(); err != nil {
+		check("index", err.Error())
+	} else {
+		count := 0
+		if err := index.Scan(func(remove.IndexEntry, int64) bool { count++; return true }); err != nil {
+			check("index", err.Error())
+		} else {
+			check("index", fmt.Sprintf("%s (%d entries)", index.Path(), count))
+		}
+	}
+
+	if safe, err := safeModeEnabled(); err != nil {
+		check("safe mode", err.Error())
+	} else if safe {
+		check("safe mode", "on")
+	} else {
+		check("safe mode", "off")
+	}
+
+	if sandbox, err := sandboxEnabled(); err != nil {
+		check("sandbox", err.Error())
+	} else if sandbox {
+		check("sandbox", "on, "+sandboxStatus())
+	} else {
+		check("sandbox", "off, "+sandboxStatus())
+	}
+
+	check("maintenance", timerStatus())
+
+	// where the settings that change behaviour come from
+	if settings, err := remove.LoadSettings(); err != nil {
+		check("config", err.Error())
+	} else {
+		for _, key := range []string{"safe_mode", "max_entries"} {
+			value, ok := settings.Config[key]
+			if !ok {
+				value = "unset"
+			}
+			check(key, value+" ("+settings.Describe(key)+")")
+		}
+	}
+}
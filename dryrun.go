@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// DRYRUNVERBS are how --dry-run words each Result.Action
+var DRYRUNVERBS = map[string]string{
+	"trashed": "would trash",
+	"deleted": "would delete",
+	"skipped": "would skip",
+	"kept":    "would keep",
+	"failed":  "would fail",
+}
+
+// dryRunLines are the lines --dry-run prints for result: what would happen
+// to it, then one indented line per question, warning or likely problem
+func dryRunLines(result remove.Result) []string {
+	path := remove.DisplayPath(result.Source)
+	verb, ok := DRYRUNVERBS[result.Action]
+	if !ok {
+		verb = "would " + result.Action
+	}
+
+	lines := []string{}
+	switch status := result.Status(); {
+	case status == remove.StatusFailed || status == remove.StatusSkippedProtected:
+		for _, msg := range failureMessages(result.Source, result.Err) {
+			lines = append(lines, verb+": "+msg)
+		}
+		return lines
+	case errors.Is(result.Err, remove.ErrSkipped):
+		lines = append(lines, verb+" "+path+" ("+result.FSType+": "+result.Policy+")")
+	case result.Dest != "":
+		lines = append(lines, verb+" "+path+" -> "+result.Dest)
+	case result.Strategy != "":
+		lines = append(lines, verb+" "+path+" by "+result.Strategy)
+	default:
+		lines = append(lines, verb+" "+path)
+	}
+
+	for _, prompt := range result.Prompts {
+		lines = append(lines, "  asks: "+prompt)
+	}
+	for _, warning := range result.Warnings {
+		lines = append(lines, "  warns: "+warning)
+	}
+	for _, problem := range result.Problems {
+		lines = append(lines, "  likely fails: "+problem)
+	}
+	return lines
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/shanahanjrs/srm/remove"
+	"github.com/shanahanjrs/srm/trashquery"
+)
+
+// duCommand
+// srm du [--internal]
+// prints how much is in the trash and how many entries it holds against its
+// cap, or with --internal how much srm's own bookkeeping takes up
+func duCommand(args []string) {
+	flags, rest := parseArgs(args)
+	if len(rest) > 0 {
+		fmt.Fprintf(os.Stderr, "srm du: unexpected argument %s\n", rest[0])
+		os.Exit(1)
+	}
+	if remove.In("--internal", flags) {
+		duInternal()
+		return
+	}
+
+	targetDir, err := remove.FindTrashDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm du: %s\n", err)
+		os.Exit(1)
+	}
+	settings, err := remove.LoadSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm du: %s\n", err)
+		os.Exit(1)
+	}
+
+	// the sizes srm recorded as it trashed, by a hash of the name, so only
+	// what it has no size for is walked; a huge trash costs 16 bytes an entry
+	recorded := map[uint64]int64{}
+	if index, err := trashquery.OpenDefault(); err == nil {
+		for entry, err := range index.ListEntries(trashquery.Filter{Location: targetDir}) {
+			if err != nil {
+				break
+			}
+			// an archive's recorded size is what went into it
+			if entry.Size > 0 && entry.Archive == "" {
+				recorded[remove.HashString(entry.Name)] = entry.Size
+			}
+		}
+	}
+
+	var size int64
+	count := 0
+	err = remove.ForEachDirEntry(targetDir, func(de os.DirEntry) bool {
+		count++
+		if entrySize, ok := recorded[remove.HashString(de.Name())]; ok {
+			size += entrySize
+			return true
+		}
+		entrySize, err := remove.DiskUsage(remove.OSFS{}, filepath.Join(targetDir, de.Name()))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "srm du: %s\n", err)
+		}
+		size += entrySize
+		return true
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm du: %s\n", err)
+		os.Exit(1)
+	}
+	limit, err := maxEntries(settings.Config, targetDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm du: %s\n", err)
+		os.Exit(1)
+	}
+
+	entries := fmt.Sprintf("%d entries", count)
+	if limit > 0 {
+		entries = fmt.Sprintf("%d/%d entries", count, limit)
+	}
+	fmt.Printf("%s\t%s\t%s\n", remove.FormatSize(size), entries, targetDir)
+}
+
+// duInternal prints a line per kind of file srm keeps in its data dir, with
+// the journal's limit beside it, and their total
+func duInternal() {
+	dir, usage, err := auxUsage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm du: %s\n", err)
+		os.Exit(1)
+	}
+	limits := remove.CurrentAuxLimits()
+	var total int64
+	for _, u := range usage {
+		note := ""
+		switch u.Name {
+		case "journal":
+			note = fmt.Sprintf(" (rotated at %s, keeping %d)", remove.FormatSize(limits.JournalMaxSize), limits.JournalGenerations)
+		case "sizecache":
+			note = fmt.Sprintf(" (up to %d directories)", limits.SizeCacheMax)
+		}
+		fmt.Printf("%s\t%d files\t%s%s\n", remove.FormatSize(u.Bytes), u.Files, u.Name, note)
+		total += u.Bytes
+	}
+	fmt.Printf("%s\ttotal\t%s\n", remove.FormatSize(total), dir)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// emptyCommand
+// srm empty [-f] [-v] [--older-than AGE] [--keep-last N] [--pattern GLOB] [--dry-run]
+// srm --empty ...
+// permanently deletes what is in the trash. --older-than spares entries
+// deleted more recently than AGE ago, and those with no deletion time unless
+// -f. --keep-last then spares the N most recently deleted entries of those
+// matching --pattern.
+func emptyCommand(args []string) {
+	flags, rest := parseArgs(args)
+	if len(rest) > 0 {
+		fmt.Fprintf(os.Stderr, "srm empty: unexpected argument %s\n", rest[0])
+		os.Exit(1)
+	}
+	dryRun := remove.In("--dry-run", flags)
+
+	keepLast := 0
+	if value, ok := remove.FlagValue("--keep-last", flags); ok {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			fmt.Fprintf(os.Stderr, "srm empty: invalid --keep-last %q, expected a count\n", value)
+			os.Exit(1)
+		}
+		keepLast = n
+	}
+	var olderThan time.Duration
+	value, hasOlderThan := remove.FlagValue("--older-than", flags)
+	if hasOlderThan {
+		d, err := remove.ParseAge(value)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "srm empty: invalid --older-than %q, expected an age like 30d, 2w or 12h\n", value)
+			os.Exit(1)
+		}
+		olderThan = d
+	}
+	verbose := remove.In("-v", flags) || remove.In("-vv", flags)
+	pattern, hasPattern := remove.FlagValue("--pattern", flags)
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		fmt.Fprintf(os.Stderr, "srm empty: invalid --pattern: %s\n", err)
+		os.Exit(1)
+	}
+
+	opts, err := resolveOptions(flags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm empty: %s\n", err)
+		os.Exit(1)
+	}
+	if opts.SafeMode && !dryRun {
+		fmt.Fprintln(os.Stderr, "srm empty: disabled by safe mode")
+		os.Exit(1)
+	}
+
+	targetDir, err := remove.FindTrashDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm empty: %s\n", err)
+		os.Exit(1)
+	}
+	index, err := remove.OpenIndex()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm empty: %s\n", err)
+		os.Exit(1)
+	}
+
+	candidates, err := emptyCandidates(index, targetDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm empty: %s\n", err)
+		os.Exit(1)
+	}
+	if hasPattern {
+		matching := []remove.EmptyCandidate{}
+		for _, c := range candidates {
+			if matchAny(c.Entry.Name, []string{pattern}) {
+				matching = append(matching, c)
+			}
+		}
+		candidates = matching
+	}
+	undated := 0
+	if hasOlderThan {
+		cutoff := time.Now().Add(-olderThan)
+		old := []remove.EmptyCandidate{}
+		for _, c := range candidates {
+			switch {
+			case !c.Dated && !opts.Force:
+				undated++
+			case !c.Dated || c.Entry.Deleted.Before(cutoff):
+				old = append(old, c)
+			}
+		}
+		candidates = old
+	}
+	if undated > 0 {
+		fmt.Fprintf(os.Stderr, "srm empty: keeping %d entries with no deletion time (-f removes them too)\n", undated)
+	}
+
+	// newest first, ties broken by entry ID then name so the cut is stable
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i].Entry, candidates[j].Entry
+		if !a.Deleted.Equal(b.Deleted) {
+			return a.Deleted.After(b.Deleted)
+		}
+		if a.ID != b.ID {
+			return a.ID < b.ID
+		}
+		return a.Name < b.Name
+	})
+	keep, purge := candidates, []remove.EmptyCandidate{}
+	if keepLast < len(candidates) {
+		keep, purge = candidates[:keepLast], candidates[keepLast:]
+	}
+
+	if len(purge) == 0 {
+		fmt.Println("srm empty: nothing to purge")
+		return
+	}
+
+	var keepBytes, purgeBytes int64
+	for _, c := range keep {
+		keepBytes += c.Size
+	}
+	for _, c := range purge {
+		purgeBytes += c.Size
+	}
+
+	if dryRun {
+		for _, c := range purge {
+			fmt.Printf("would purge %s (%s)\n", remove.DisplayName(c.Entry.Payload()), remove.FormatSize(c.Size))
+		}
+		fmt.Printf("would purge %d entries (%s), keeping %d (%s)\n", len(purge), remove.FormatSize(purgeBytes), len(keep), remove.FormatSize(keepBytes))
+		return
+	}
+
+	if !opts.SkipsPrompt("empty") {
+		msg := fmt.Sprintf("permanently delete %d entries (%s), keeping %d (%s)? ", len(purge), remove.FormatSize(purgeBytes), len(keep), remove.FormatSize(keepBytes))
+		if !remove.GetUserConfirmation(msg) {
+			os.Exit(0)
+		}
+	}
+
+	journal := openJournal(newOpID(), os.Args)
+	defer journal.Close()
+
+	failed := false
+	purged, reclaimed := 0, int64(0)
+	for _, c := range purge {
+		result := remove.PurgeCandidate(remove.OSFS{}, index, c, false)
+		if result.Err != nil {
+			fmt.Fprintf(os.Stderr, "srm empty: %s\n", result.Err)
+			failed = true
+		} else {
+			purged++
+			reclaimed += c.Size
+			if verbose {
+				fmt.Printf("purged %s (%s)\n", remove.DisplayName(c.Entry.Payload()), remove.FormatSize(c.Size))
+			}
+		}
+		journal.Record(result)
+	}
+	fmt.Printf("srm empty: purged %d entries, reclaiming %s\n", purged, remove.FormatSize(reclaimed))
+	if failed || journal.Refused() {
+		journal.Close()
+		os.Exit(1)
+	}
+}
+
+// emptyCandidates lists everything in trashDir. Payloads srm didn't index
+// count as deleted when their .trashinfo says, or failing that when they
+// were last modified, which leaves them undated.
+func emptyCandidates(index *remove.Index, trashDir string) ([]remove.EmptyCandidate, error) {
+	dirEntries, err := os.ReadDir(trashDir)
+	if err != nil {
+		return nil, err
+	}
+
+	known := map[string]remove.IndexEntry{}
+	entries, err := index.Entries()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.Trash == trashDir {
+			known[entry.Name] = entry
+		}
+	}
+
+	candidates := []remove.EmptyCandidate{}
+	for _, de := range dirEntries {
+		entry, isKnown := known[de.Name()]
+		dated := isKnown && !entry.Deleted.IsZero()
+		if !isKnown {
+			entry = remove.IndexEntry{Trash: trashDir, Name: de.Name(), IsDir: de.IsDir()}
+			entry.Deleted, dated = remove.TrashInfoDate(entry.Payload())
+			if fi, err := de.Info(); err == nil && !dated {
+				entry.Deleted = fi.ModTime()
+			}
+		}
+		size, _ := remove.DiskUsage(remove.OSFS{}, entry.Payload())
+		candidates = append(candidates, remove.EmptyCandidate{Entry: entry, Known: isKnown, Dated: dated, Size: size})
+	}
+	return candidates, nil
+}
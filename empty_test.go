@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// Everything in the trash is a candidate, dated from the index or else
+// its .trashinfo, and purging them all leaves the trash and index empty
+func TestEmptyCandidates(t *testing.T) {
+	work, trash, index, r := scratchTrash(t)
+	writeFiles(t, work, map[string]string{"a": "a", "d/b": "b"})
+	for _, name := range []string{"a", "d"} {
+		if result := r.Remove(filepath.Join(work, name)); result.Err != nil {
+			t.Fatal(result.Err)
+		}
+	}
+	// put there by something else
+	stray := filepath.Join(trash, "stray")
+	if err := os.WriteFile(stray, []byte("stray"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	if err := os.Chtimes(stray, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	candidates, err := emptyCandidates(index, trash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 3 {
+		t.Fatalf("%d candidates, want 3: %+v", len(candidates), candidates)
+	}
+	for _, c := range candidates {
+		known := c.Entry.Name != "stray"
+		if c.Known != known || c.Dated != known {
+			t.Errorf("%s: known %v, dated %v", c.Entry.Name, c.Known, c.Dated)
+		}
+		if !known && !c.Entry.Deleted.Equal(old) {
+			t.Errorf("stray dated %v, want its mtime", c.Entry.Deleted)
+		}
+		if result := remove.PurgeCandidate(remove.OSFS{}, index, c, false); result.Err != nil {
+			t.Fatal(result.Err)
+		}
+	}
+	if left, err := os.ReadDir(trash); err != nil || len(left) > 0 {
+		t.Errorf("left in the trash: %v, %v", left, err)
+	}
+	if entries, err := index.Entries(); err != nil || len(entries) > 0 {
+		t.Errorf("left in the index: %v, %v", entries, err)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// rmDiagnostic words a failure to remove path the way rm does, as in
+// "cannot remove 'x': Is a directory", for the failures rm has too. srm's
+// own refusals, like protected paths, have no rm wording and return false.
+func rmDiagnostic(path string, err error) (string, bool) {
+	var errno syscall.Errno
+	reason := ""
+	switch {
+	case errors.Is(err, remove.ErrDotOperand):
+		return fmt.Sprintf("refusing to remove '.' or '..' directory: skipping '%s'", remove.DisplayPath(path)), true
+	case errors.Is(err, remove.ErrPreserveRoot):
+		// the second line is failureMessages'
+		return fmt.Sprintf("it is dangerous to operate recursively on '%s'", remove.DisplayPath(path)), true
+	case errors.Is(err, remove.ErrIsDirectory):
+		reason = "Is a directory"
+	case errors.Is(err, remove.ErrDirNotEmpty):
+		reason = "Directory not empty"
+	case errors.Is(err, remove.ErrNotFound):
+		reason = "No such file or directory"
+	case errors.As(err, &errno):
+		// strerror's wording, which Go keeps apart from the capital
+		reason = strings.ToUpper(errno.Error()[:1]) + errno.Error()[1:]
+	default:
+		return "", false
+	}
+	return fmt.Sprintf("cannot remove '%s': %s", remove.DisplayPath(path), reason), true
+}
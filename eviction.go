@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// maxEntries is the entry cap for trashDir: max_entries[<trashDir>] if set,
+// else max_entries, else 0 for no cap
+func maxEntries(config remove.Config, trashDir string) (int, error) {
+	for _, key := range []string{"max_entries[" + trashDir + "]", "max_entries"} {
+		value, ok := config[key]
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%s: expected a count, got %q", key, value)
+		}
+		return n, nil
+	}
+	return 0, nil
+}
+
+// trashEntryCount is how many names sit directly in trashDir, whether or not
+// srm put them there. It counts a batch at a time rather than reading the
+// whole directory.
+func trashEntryCount(trashDir string) (int, error) {
+	count := 0
+	err := remove.ForEachDirEntry(trashDir, func(os.DirEntry) bool {
+		count++
+		return true
+	})
+	return count, err
+}
+
+// evictOldest permanently removes candidates, oldest first, for as long as
+// over reports the trash is still over its limit, calling evicted after each
+// successful removal so over can see the trash shrink. Entry caps and byte
+// quotas differ only in what over and evicted count.
+func evictOldest(fsys remove.FS, index *remove.Index, candidates []remove.IndexEntry, over func() bool, evicted func(remove.IndexEntry)) []remove.Result {
+	sorted := append([]remove.IndexEntry{}, candidates...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Deleted.Before(sorted[j].Deleted)
+	})
+
+	results := []remove.Result{}
+	for _, entry := range sorted {
+		if !over() {
+			break
+		}
+
+		start := time.Now()
+		result := remove.Result{
+			Action:   "evicted",
+			Source:   entry.Origin,
+			Dest:     entry.Payload(),
+			Bytes:    entry.Size,
+			Strategy: "remove-all",
+			IsDir:    entry.IsDir,
+		}
+		if err := fsys.RemoveAll(entry.Payload()); err != nil {
+			result.Action, result.Err = "failed", err
+			results = append(results, result)
+			continue
+		}
+		if err := remove.RemoveTrashInfo(entry.Payload()); err != nil {
+			result.Note = "trash info: " + err.Error()
+		}
+		if err := index.Forget(entry); err != nil {
+			result.Note = "index: " + err.Error()
+		}
+		result.Duration = time.Since(start)
+		results = append(results, result)
+		evicted(entry)
+	}
+	return results
+}
+
+// entriesOverCap is how many entries trashDir holds and its max_entries
+// when it holds more than that, zeros when it doesn't
+func entriesOverCap(config remove.Config, trashDir string) (count int, limit int, err error) {
+	limit, err = maxEntries(config, trashDir)
+	if err != nil || limit == 0 {
+		return 0, 0, err
+	}
+	count, err = trashEntryCount(trashDir)
+	if err != nil || count <= limit {
+		return 0, 0, err
+	}
+	return count, limit, nil
+}
+
+// enforceEntryCap evicts srm's oldest entries from trashDir until it holds no
+// more than its configured max_entries. Eviction is permanent, so callers
+// leave it out in safe mode.
+func enforceEntryCap(fsys remove.FS, index *remove.Index, config remove.Config, trashDir string) ([]remove.Result, error) {
+	count, limit, err := entriesOverCap(config, trashDir)
+	if err != nil || limit == 0 {
+		return nil, err
+	}
+
+	entries, err := index.Entries()
+	if err != nil {
+		return nil, err
+	}
+	candidates := []remove.IndexEntry{}
+	for _, entry := range entries {
+		if entry.Trash == trashDir {
+			candidates = append(candidates, entry)
+		}
+	}
+
+	over := func() bool { return count > limit }
+	evicted := func(remove.IndexEntry) { count-- }
+	return evictOldest(fsys, index, candidates, over, evicted), nil
+}
+
+// evictCaps is the maintenance task applying entry caps to the trash
+func evictCaps(index *remove.Index, journal *Journal) (string, error) {
+	trashDir, err := remove.FindTrashDir()
+	if err != nil {
+		return "", err
+	}
+	settings, err := remove.LoadSettings()
+	if err != nil {
+		return "", err
+	}
+	safe, err := safeModeEnabled()
+	if err != nil {
+		return "", err
+	}
+	if safe {
+		count, limit, err := entriesOverCap(settings.Config, trashDir)
+		if err != nil || limit == 0 {
+			return "within limits", err
+		}
+		return fmt.Sprintf("%d entries, over max_entries of %d, but safe mode evicts none", count, limit), nil
+	}
+
+	results, err := enforceEntryCap(remove.OSFS{}, index, settings.Config, trashDir)
+	if err != nil {
+		return "", err
+	}
+	evicted := 0
+	for _, result := range results {
+		journal.Record(result)
+		if result.Action == "evicted" {
+			evicted++
+		} else {
+			fmt.Fprintf(os.Stderr, "srm maintain: evict %s: %s\n", result.Dest, result.Err)
+		}
+	}
+	if evicted == 0 {
+		return "within limits", nil
+	}
+	return fmt.Sprintf("evicted %d entries over max_entries", evicted), nil
+}
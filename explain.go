@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// explainCommand
+// srm explain [removal options] <path ...>
+// runs the same checks a removal would, without prompting or removing, and
+// prints what srm would do with each path and why. Exits 1 when any of them
+// would fail.
+func explainCommand(args []string) {
+	flags, files := parseArgs(args)
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "srm explain: no paths given")
+		os.Exit(1)
+	}
+
+	opts, err := resolveOptions(flags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm explain: %s\n", err)
+		os.Exit(1)
+	}
+	sortBy, _ := remove.FlagValue("--sort-operands", flags)
+	if files, err = sortOperands(files, sortBy); err != nil {
+		fmt.Fprintf(os.Stderr, "srm explain: %s\n", err)
+		os.Exit(1)
+	}
+	onNoTrash, err := resolveOnNoTrash(flags, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm explain: %s\n", err)
+		os.Exit(1)
+	}
+
+	// as a run would pick it, the XDG trash it would create included
+	targetDir, note, created, trashErr := selectTarget(onNoTrash, opts.PreferTrash, true)
+	if created != "" {
+		note = "not there yet, a run creates it"
+	}
+	opts.TrashDir = targetDir
+	opts.ResolveTrash = true
+	opts.Permanent = targetDir == "" && trashErr == nil
+	opts.TrashNote = note
+	opts.Archive = remove.In("--archive", flags)
+	remover := remove.NewRemover(opts)
+
+	wouldFail := false
+	for i, path := range files {
+		if i > 0 {
+			fmt.Println()
+		}
+		if !explainPath(remover, opts, path, trashErr) {
+			wouldFail = true
+		}
+	}
+	if wouldFail {
+		os.Exit(1)
+	}
+}
+
+// explainPath prints the decision trace for one operand and reports whether
+// removing it would succeed
+func explainPath(remover *remove.Remover, opts remove.Options, path string, trashErr error) bool {
+	line := func(name, value string) {
+		fmt.Printf("%-9s %s\n", name, remove.DisplayName(value))
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	line("path", abs)
+
+	fi, statErr := os.Lstat(path)
+	if statErr == nil {
+		line("type", fileKind(path, fi))
+		if size, err := remove.DiskUsage(remove.OSFS{}, path); err == nil {
+			line("size", remove.FormatSize(size))
+		}
+	}
+
+	plan, planErr := remover.Plan(path)
+	switch {
+	case trashErr != nil:
+		line("trash", "none: "+trashErr.Error())
+	case opts.Permanent:
+		line("trash", "none: "+opts.TrashNote)
+	case opts.TrashNote != "":
+		line("trash", opts.TrashDir+" ("+opts.TrashNote+")")
+	case plan.Trash != "":
+		line("trash", plan.Trash+" ("+plan.TrashWhy+")")
+	default:
+		line("trash", opts.TrashDir+" (first usable trash)")
+	}
+	if opts.SafeMode {
+		line("safe mode", "on")
+	}
+
+	if len(plan.TrashCandidates) > 0 {
+		fmt.Println("trash candidates:")
+		chosen := false
+		for i, candidate := range plan.TrashCandidates {
+			status := "usable"
+			switch {
+			case candidate.Err != nil:
+				status = "unusable: " + candidate.Err.Error()
+			case !chosen:
+				status, chosen = "chosen", true
+			}
+			fmt.Printf("  %d. %s\n", i+1, remove.DisplayName(fmt.Sprintf("%s [%s] %s; %s", candidate.Dir, candidate.Kind, candidate.Why, status)))
+		}
+	}
+
+	fmt.Println("decision:")
+	for _, step := range plan.Trace {
+		fmt.Println("  - " + remove.DisplayName(step))
+	}
+	for _, prompt := range plan.Prompts {
+		fmt.Println("  - asks: " + remove.DisplayName(prompt))
+	}
+	for _, warning := range plan.Warnings {
+		fmt.Println("  - warns: " + remove.DisplayName(warning))
+	}
+
+	problems := []string{}
+	if statErr == nil && planErr == nil && plan.Action != "skipped" {
+		problems = remove.ExplainProblems(abs, fi, plan)
+	}
+	if len(problems) > 0 {
+		fmt.Println("problems:")
+		for _, problem := range problems {
+			fmt.Println("  - " + remove.DisplayName(problem))
+		}
+	}
+
+	// a missing operand or a directory without -r fails before the trash
+	// is ever looked at
+	switch {
+	case planErr != nil && remove.IsProtection(planErr):
+		line("result", "skipped: "+planErr.Error())
+		return true
+	case planErr != nil && !errors.Is(planErr, remove.ErrTrashUnavailable):
+		line("result", "fails: "+planErr.Error())
+		return false
+	case trashErr != nil:
+		line("result", "refused: "+trashErr.Error())
+		return false
+	case planErr != nil:
+		line("result", "fails: "+planErr.Error())
+		return false
+	case plan.Action == "skipped":
+		line("result", "skipped: "+remove.ErrSkipped.Error())
+		return true
+	case len(problems) > 0:
+		line("result", "likely fails when "+plan.Strategy+" is attempted")
+		return false
+	}
+	if plan.Dest != "" {
+		line("result", fmt.Sprintf("%s by %s to %s", plan.Action, plan.Strategy, plan.Dest))
+	} else {
+		line("result", fmt.Sprintf("%s by %s", plan.Action, plan.Strategy))
+	}
+	return true
+}
+
+// fileKind describes fi for humans
+func fileKind(path string, fi fs.FileInfo) string {
+	switch mode := fi.Mode(); {
+	case mode&fs.ModeSymlink != 0:
+		target, _ := os.Readlink(path)
+		return "symlink to " + target + " (the link is removed, not its target)"
+	case mode.IsDir() && len(remove.OverlayXattrs(path)) > 0:
+		return "opaque overlayfs directory"
+	case mode.IsDir():
+		return "directory"
+	case mode.IsRegular():
+		return "file"
+	case mode&fs.ModeNamedPipe != 0:
+		return "named pipe"
+	case mode&fs.ModeSocket != 0:
+		return "socket"
+	case remove.IsWhiteout(fi):
+		return "overlayfs whiteout (character device 0/0)"
+	case mode&fs.ModeDevice != 0:
+		return "device"
+	}
+	return "other"
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// FILEURIPREFIX starts the operands desktops, browsers and clipboard
+// managers hand over instead of a path, as in file:///home/me/My%20File.txt
+const FILEURIPREFIX = "file://"
+
+// isFileURI reports whether operand is a file:// URI to decode. Only
+// operands before -- are, so -- file://x still means the path file:/x.
+func isFileURI(operand string) bool {
+	return len(operand) >= len(FILEURIPREFIX) && strings.EqualFold(operand[:len(FILEURIPREFIX)], FILEURIPREFIX)
+}
+
+// decodeFileURI is the local path a file:// URI names, per RFC 8089: an
+// empty or localhost authority, then an absolute path whose percent-escapes
+// are decoded. A + is a plus sign, not a space; that is only so in query
+// strings. Any other authority is refused, as is a port, a query or a
+// fragment, which a path can't have unescaped.
+func decodeFileURI(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return "", fmt.Errorf("%s: not a file URI: %w", uri, err)
+	}
+	if host := u.Hostname(); host != "" && !strings.EqualFold(host, "localhost") {
+		return "", fmt.Errorf("%s: %w (%s); only file:///path and file://localhost/path are local", uri, remove.ErrRemoteURI, host)
+	}
+	if u.Port() != "" {
+		return "", fmt.Errorf("%s: a file URI has no port", uri)
+	}
+	if u.RawQuery != "" || u.ForceQuery || u.Fragment != "" {
+		return "", fmt.Errorf("%s: a file URI has no ? or #; a name with one is written %%3F or %%23", uri)
+	}
+	path := u.Path
+	if strings.ContainsRune(path, 0) {
+		return "", fmt.Errorf("%s: decodes to a path with a NUL byte in it", uri)
+	}
+	// file:///C:/Users/me is C:\Users\me
+	if runtime.GOOS == "windows" && len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	if path == "" || !filepath.IsAbs(filepath.FromSlash(path)) {
+		return "", fmt.Errorf("%s: names no absolute path", uri)
+	}
+	return filepath.FromSlash(path), nil
+}
+
+// fileURINote is what -v adds after a path that was given as a file:// URI,
+// so the line shows both: "removed /home/me/My File.txt (file:///home/me/My%20File.txt)"
+func fileURINote(uris map[string]string, path string) string {
+	uri, ok := uris[path]
+	if !ok {
+		return ""
+	}
+	return " (" + remove.DisplayName(uri) + ")"
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+func TestIsFileURI(t *testing.T) {
+	tests := []struct {
+		operand string
+		want    bool
+	}{
+		{"file:///tmp/x", true},
+		{"FILE:///tmp/x", true},
+		{"File://localhost/tmp/x", true},
+		{"file://", true},
+		{"file:/tmp/x", false},
+		{"file:", false},
+		{"./file:///tmp/x", false},
+		{"/tmp/file:///x", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isFileURI(tt.operand); got != tt.want {
+			t.Errorf("isFileURI(%q) = %v, want %v", tt.operand, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeFileURI(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the paths are POSIX ones")
+	}
+	tests := []struct {
+		name string
+		uri  string
+		want string
+	}{
+		{"an escaped space", "file:///home/me/My%20File.txt", "/home/me/My File.txt"},
+		{"escaped spaces throughout", "file:///a%20b/c%20%20d/%20", "/a b/c  d/ "},
+		{"escaped UTF-8", "file:///tmp/caf%C3%A9/na%C3%AFve", "/tmp/café/naïve"},
+		{"unescaped UTF-8", "file:///tmp/café", "/tmp/café"},
+		{"escaped four-byte UTF-8", "file:///tmp/%F0%9F%97%91", "/tmp/🗑"},
+		{"lowercase escapes", "file:///tmp/caf%c3%a9", "/tmp/café"},
+		{"plus signs stay plus signs", "file:///tmp/c++/a+b", "/tmp/c++/a+b"},
+		{"an escaped plus sign", "file:///tmp/a%2Bb", "/tmp/a+b"},
+		{"an escaped percent sign", "file:///tmp/100%25", "/tmp/100%"},
+		{"escaped ? and #", "file:///tmp/what%3F%23", "/tmp/what?#"},
+		{"localhost", "file://localhost/tmp/a%20b", "/tmp/a b"},
+		{"localhost in capitals", "file://LOCALHOST/tmp/x", "/tmp/x"},
+		{"a scheme in capitals", "FILE:///tmp/upper", "/tmp/upper"},
+		// rm treats dir/ apart from dir, so the slash stays
+		{"a trailing slash", "file:///tmp/dir/", "/tmp/dir/"},
+		{"the root", "file:///", "/"},
+	}
+	for _, tt := range tests {
+		got, err := decodeFileURI(tt.uri)
+		if err != nil {
+			t.Errorf("%s: %s: %v", tt.name, tt.uri, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: %s decoded to %q, want %q", tt.name, tt.uri, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeFileURIInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		uri    string
+		remote bool
+	}{
+		{"a server", "file://server/share/x", true},
+		{"a server with a port", "file://server:22/x", true},
+		{"an address", "file://192.168.1.2/x", true},
+		{"localhost with a port", "file://localhost:8080/x", false},
+		{"a bad escape", "file:///tmp/%zz", false},
+		{"a cut-off escape", "file:///tmp/a%2", false},
+		{"a query", "file:///tmp/a?b", false},
+		{"an empty query", "file:///tmp/a?", false},
+		{"a fragment", "file:///tmp/a#b", false},
+		{"an escaped NUL", "file:///tmp/a%00b", false},
+		{"no path", "file://", false},
+		{"only localhost", "file://localhost", false},
+	}
+	for _, tt := range tests {
+		path, err := decodeFileURI(tt.uri)
+		if err == nil {
+			t.Errorf("%s: %s decoded to %q, want an error", tt.name, tt.uri, path)
+			continue
+		}
+		if errors.Is(err, remove.ErrRemoteURI) != tt.remote {
+			t.Errorf("%s: %s: %v, remote %v", tt.name, tt.uri, err, tt.remote)
+		}
+	}
+}
+
+func TestFileURINote(t *testing.T) {
+	uris := map[string]string{"/tmp/a b": "file:///tmp/a%20b"}
+	if got, want := fileURINote(uris, "/tmp/a b"), " (file:///tmp/a%20b)"; got != want {
+		t.Errorf("fileURINote = %q, want %q", got, want)
+	}
+	if got := fileURINote(uris, "/tmp/other"); got != "" {
+		t.Errorf("fileURINote for a path given as one = %q", got)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// OptionValue says whether an option takes a value and how it may be given
+type OptionValue int
+
+const (
+	NoValue OptionValue = iota
+	// RequiredValue options are given as --name value or --name=value
+	RequiredValue
+	// OptionalValue options are given bare, or as --name=value only, so a
+	// bare one never swallows the operand after it
+	OptionalValue
+)
+
+// Option is one entry of the OPTIONS table
+type Option struct {
+	Name    string
+	Aliases []string
+	Value   OptionValue
+	// Arg names the value in usage, like DIR or N
+	Arg string
+	// Repeatable options collect every value given, see FlagValues. Any
+	// other option may be repeated with the same value only.
+	Repeatable bool
+	// Sensitive options have their value left out of the journal when
+	// redact_argv = true, see recordedArgv
+	Sensitive bool
+	// Command is the subcommand the option belongs to, "" for removal.
+	// Also lists the other commands that take it, "" again being removal,
+	// and Global options are taken by every command.
+	Command string
+	Also    []string
+	Global  bool
+	Help    string
+}
+
+// Synopsis is one usage line of a command, "" for removal
+type Synopsis struct {
+	Command string
+	Line    string
+}
+
+// HelpTopic is a section of help beyond a command's options. Commands are
+// the subcommands whose --help shows it.
+type HelpTopic struct {
+	Title    string
+	Commands []string
+	Lines    []string
+}
+
+// Name is what srm --help TOPIC takes: the title in lower case, with - for
+// spaces
+func (t HelpTopic) Name() string {
+	return strings.ReplaceAll(strings.ToLower(t.Title), " ", "-")
+}
+
+// Print writes the topic as a section of usage
+func (t HelpTopic) Print() {
+	fmt.Println(t.Title + ":")
+	for _, line := range t.Lines {
+		fmt.Println("    " + line)
+	}
+}
+
+// optionIndex maps every option name and alias to its OPTIONS entry
+var optionIndex map[string]*Option
+
+// subcommandNames is SUBCOMMANDS' keys sorted, for completion, which is
+// itself in SUBCOMMANDS and so can't read the map while it is initialised
+var subcommandNames []string
+
+func init() {
+	for name := range SUBCOMMANDS {
+		subcommandNames = append(subcommandNames, name)
+	}
+	sort.Strings(subcommandNames)
+
+	optionIndex = map[string]*Option{}
+	for i := range OPTIONS {
+		opt := &OPTIONS[i]
+		for _, name := range append([]string{opt.Name}, opt.Aliases...) {
+			if _, dup := optionIndex[name]; dup {
+				panic("srm: option " + name + " is in OPTIONS twice")
+			}
+			optionIndex[name] = opt
+		}
+	}
+}
+
+// lookupOption returns the option called name, by name or alias, or nil
+func lookupOption(name string) *Option {
+	return optionIndex[name]
+}
+
+// takes reports whether command, "" for removal, takes opt. srm explain
+// takes every removal option, as it explains a removal.
+func (opt *Option) takes(command string) bool {
+	switch {
+	case opt.Global, opt.Command == command, remove.In(command, opt.Also):
+		return true
+	case command == "explain":
+		return opt.Command == ""
+	}
+	return false
+}
+
+// validateFlags
+// checks parsed flags against the table: each is one command, "" for
+// removal, takes, required values are there, and options that aren't
+// repeatable weren't given conflicting values
+func validateFlags(command string, flags []string) error {
+	seen := map[string]string{}
+	for _, flag := range flags {
+		name, value, hasValue := strings.Cut(flag, "=")
+		opt := lookupOption(name)
+		if opt == nil {
+			continue
+		}
+		if !opt.takes(command) {
+			if command == "" {
+				return fmt.Errorf("option %s is for srm %s, not removing files", name, opt.Command)
+			}
+			return fmt.Errorf("srm %s doesn't take option %s", command, name)
+		}
+		if opt.Value == RequiredValue && !hasValue {
+			return fmt.Errorf("option %s requires a value", name)
+		}
+		if previous, ok := seen[name]; ok && !opt.Repeatable && previous != value {
+			return fmt.Errorf("option %s given more than once with different values", name)
+		}
+		seen[name] = value
+	}
+	return nil
+}
+
+// optionUsage is opt's line of an Options section
+func optionUsage(opt Option) string {
+	names := append([]string{opt.Name}, opt.Aliases...)
+	spec := strings.Join(names, ", ")
+	switch opt.Value {
+	case RequiredValue:
+		spec += " " + opt.Arg
+	case OptionalValue:
+		spec += "[=" + opt.Arg + "]"
+	}
+	return fmt.Sprintf("    %-26s %s", spec, opt.Help)
+}
+
+// commandUsage
+// is srm COMMAND --help: the command's usage lines and the options it takes,
+// those every command takes last
+func commandUsage(command string) {
+	fmt.Println("Usage:")
+	for _, synopsis := range SYNOPSES {
+		if synopsis.Command == command {
+			fmt.Println("    " + synopsis.Line)
+		}
+	}
+	own, global := []string{}, []string{}
+	for _, opt := range OPTIONS {
+		switch {
+		case opt.Global:
+			global = append(global, optionUsage(opt))
+		case opt.takes(command):
+			own = append(own, optionUsage(opt))
+		}
+	}
+	if len(own) > 0 {
+		fmt.Println("Options:")
+		for _, line := range own {
+			fmt.Println(line)
+		}
+	}
+	fmt.Println("Options of every command:")
+	for _, line := range global {
+		fmt.Println(line)
+	}
+	for _, topic := range HELPTOPICS {
+		if remove.In(command, topic.Commands) {
+			topic.Print()
+		}
+	}
+}
+
+// helpTopics
+// is srm --help TOPIC ...: the sections named, or an error naming the one
+// there is none of
+func helpTopics(names []string) error {
+	topics := []HelpTopic{}
+	for _, name := range names {
+		i := slices.IndexFunc(HELPTOPICS, func(t HelpTopic) bool { return t.Name() == strings.ToLower(name) })
+		if i < 0 {
+			return fmt.Errorf("no help topic %q; srm --help lists them", name)
+		}
+		topics = append(topics, HELPTOPICS[i])
+	}
+	for _, topic := range topics {
+		topic.Print()
+	}
+	return nil
+}
+
+// completionCommand
+// srm completion bash|zsh
+// prints a shell completion script generated from OPTIONS and SUBCOMMANDS
+func completionCommand(args []string) {
+	_, rest := parseArgs(args)
+	if len(rest) != 1 || (rest[0] != "bash" && rest[0] != "zsh") {
+		fmt.Fprintln(os.Stderr, "usage: srm completion bash|zsh")
+		os.Exit(1)
+	}
+
+	commands := subcommandNames
+	byCommand := map[string][]string{}
+	for _, opt := range OPTIONS {
+		for _, name := range append([]string{opt.Name}, opt.Aliases...) {
+			if opt.Value == OptionalValue {
+				name += "="
+			}
+			for _, command := range append([]string{""}, commands...) {
+				if opt.takes(command) {
+					byCommand[command] = append(byCommand[command], name)
+				}
+			}
+		}
+	}
+
+	if rest[0] == "zsh" {
+		fmt.Println("autoload -U +X bashcompinit && bashcompinit")
+	}
+	fmt.Println("_srm() {")
+	fmt.Println("\tlocal cur=${COMP_WORDS[COMP_CWORD]} opts")
+	fmt.Println("\tcase ${COMP_WORDS[1]} in")
+	for _, command := range commands {
+		if opts, ok := byCommand[command]; ok {
+			fmt.Printf("\t%s) opts=%q ;;\n", command, strings.Join(opts, " "))
+		}
+	}
+	fmt.Printf("\t*) opts=%q ;;\n", strings.Join(byCommand[""], " "))
+	fmt.Println("\tesac")
+	fmt.Println("\tif [[ $cur == -* ]]; then")
+	fmt.Println("\t\tCOMPREPLY=($(compgen -W \"$opts\" -- \"$cur\"))")
+	fmt.Println("\t\treturn")
+	fmt.Println("\tfi")
+	fmt.Println("\tCOMPREPLY=()")
+	fmt.Println("\tif [ \"$COMP_CWORD\" -eq 1 ]; then")
+	fmt.Printf("\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(commands, " "))
+	fmt.Println("\tfi")
+	fmt.Println("\tCOMPREPLY+=($(compgen -f -- \"$cur\"))")
+	fmt.Println("}")
+	fmt.Println("complete -o filenames -F _srm srm")
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		args  []string
+		flags []string
+		files []string
+	}{
+		{[]string{"a"}, []string{}, []string{"a"}},
+		{[]string{"-r", "a"}, []string{"-r"}, []string{"a"}},
+		{[]string{"-rf", "a"}, []string{"-r", "-f"}, []string{"a"}},
+		{[]string{"-ff", "a"}, []string{"-f", "-f"}, []string{"a"}},
+		{[]string{"a", "-r", "b"}, []string{"-r"}, []string{"a", "b"}},
+		// aliases are recorded by the option's name
+		{[]string{"-R", "--recursive", "--directory", "-D", "--verbose", "-h"}, []string{"-r", "-r", "-d", "--permanent", "-v", "--help"}, []string{}},
+		{[]string{"-vv", "a"}, []string{"-vv"}, []string{"a"}},
+		// --opt value and --opt=value
+		{[]string{"--trash-dir", "/t", "a"}, []string{"--trash-dir=/t"}, []string{"a"}},
+		{[]string{"--trash-dir=/t", "a"}, []string{"--trash-dir=/t"}, []string{"a"}},
+		{[]string{"--trash-dir=", "a"}, []string{"--trash-dir="}, []string{"a"}},
+		{[]string{"--trash-dir=a=b"}, []string{"--trash-dir=a=b"}, []string{}},
+		{[]string{"--reason", "two words", "a"}, []string{"--reason=two words"}, []string{"a"}},
+		{[]string{"--reason", "-r", "a"}, []string{"--reason=-r"}, []string{"a"}},
+		{[]string{"--reason", "--", "a"}, []string{"--reason=--"}, []string{"a"}},
+		// a value that is missing is left for validateFlags
+		{[]string{"a", "--trash-dir"}, []string{"--trash-dir"}, []string{"a"}},
+		// repeated options, repeatable or not, are all kept in order
+		{[]string{"--prefer-trash", "home", "--prefer-trash=volume", "a"}, []string{"--prefer-trash=home", "--prefer-trash=volume"}, []string{"a"}},
+		{[]string{"--format=a", "--format", "b"}, []string{"--format=a", "--format=b"}, []string{}},
+		// optional values only ever come with =
+		{[]string{"--keep-hidden", "2"}, []string{"--keep-hidden"}, []string{"2"}},
+		{[]string{"--keep-hidden=2", "a"}, []string{"--keep-hidden=2"}, []string{"a"}},
+		{[]string{"--hidden-only=", "a"}, []string{"--hidden-only="}, []string{"a"}},
+		// --force and --interactive are spelled as -f, -i and -I
+		{[]string{"--force", "a"}, []string{"-f"}, []string{"a"}},
+		{[]string{"--force=1"}, []string{"-f"}, []string{}},
+		{[]string{"--force=2"}, []string{"-f", "-f"}, []string{}},
+		{[]string{"--interactive"}, []string{"-i"}, []string{}},
+		{[]string{"--interactive=always"}, []string{"-i"}, []string{}},
+		{[]string{"--interactive=once"}, []string{"-I"}, []string{}},
+		{[]string{"-i", "-I", "-v", "--interactive=never", "a"}, []string{"-v"}, []string{"a"}},
+		{[]string{"--interactive=never", "-i"}, []string{"-i"}, []string{}},
+		// a short option taking a value may come last in a run
+		{[]string{"-o", "out.tar", "x"}, []string{"--output=out.tar"}, []string{"x"}},
+		{[]string{"-vo", "out.tar"}, []string{"-v", "--output=out.tar"}, []string{}},
+		{[]string{"--output=out.tar"}, []string{"--output=out.tar"}, []string{}},
+		// after -- everything is a file
+		{[]string{"-r", "--", "-f", "--trash-dir", "--force=9", "--"}, []string{"-r"}, []string{"-f", "--trash-dir", "--force=9", "--"}},
+		{[]string{"--", "--interactive=never"}, []string{}, []string{"--interactive=never"}},
+		// - alone is a file, as for rm
+		{[]string{"-", "-f"}, []string{"-f"}, []string{"-"}},
+		{[]string{""}, []string{}, []string{""}},
+	}
+	for _, tt := range tests {
+		flags, files := parseArgs(tt.args)
+		if !slices.Equal(flags, tt.flags) || !slices.Equal(files, tt.files) {
+			t.Errorf("parseArgs(%q) = %q, %q, want %q, %q", tt.args, flags, files, tt.flags, tt.files)
+		}
+	}
+}
+
+func TestParseArgPositions(t *testing.T) {
+	args := []string{"file:///tmp/a", "-r", "plain", "--trash-dir", "/t", "file://localhost/b", "--", "file:///tmp/c", "-f"}
+	_, files, positions, uris := parseArgPositions(args)
+	if want := []string{"file:///tmp/a", "plain", "file://localhost/b", "file:///tmp/c", "-f"}; !slices.Equal(files, want) {
+		t.Errorf("files %q, want %q", files, want)
+	}
+	if want := []int{0, 2, 5, 7, 8}; !slices.Equal(positions, want) {
+		t.Errorf("positions %v, want %v", positions, want)
+	}
+	// only before -- are file:// operands URIs
+	if want := map[int]bool{0: true, 2: true}; !maps.Equal(uris, want) {
+		t.Errorf("URIs %v, want %v", uris, want)
+	}
+}
+
+// parseArgs exits on options it doesn't know, so those cases run in a
+// child test process
+func TestParseArgsIllegal(t *testing.T) {
+	if encoded, ok := os.LookupEnv("SRM_TEST_PARSEARGS"); ok {
+		var args []string
+		json.Unmarshal([]byte(encoded), &args)
+		parseArgs(args)
+		os.Exit(0)
+	}
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-z"}, "srm: illegal option -- z\nusage: srm"},
+		{[]string{"-rz", "a"}, "srm: illegal option -- z\n"},
+		{[]string{"-z", "--", "a"}, "srm: illegal option -- z\n"},
+		{[]string{"--nope"}, "srm: illegal option --nope\n"},
+		{[]string{"--nope=1"}, "srm: illegal option --nope=1\n"},
+		{[]string{"--quiet=yes"}, "srm: option --quiet doesn't take a value\n"},
+		{[]string{"-or", "x"}, "srm: illegal option -- o\n"},
+		{[]string{"-r=1"}, "srm: illegal option -- =\n"},
+		{[]string{"--force=3"}, "srm: invalid --force level \"3\": expected 1 or 2\n"},
+		{[]string{"--interactive=sometimes"}, "srm: invalid --interactive value \"sometimes\": expected never, once or always\n"},
+	}
+	for _, tt := range tests {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestParseArgsIllegal$")
+		encoded, _ := json.Marshal(tt.args)
+		cmd.Env = append(os.Environ(), "SRM_TEST_PARSEARGS="+string(encoded))
+		out, err := cmd.CombinedOutput()
+		if exit, ok := err.(*exec.ExitError); !ok || exit.ExitCode() != 1 {
+			t.Errorf("parseArgs(%q) exited with %v, want status 1", tt.args, err)
+		}
+		if !strings.HasPrefix(string(out), tt.want) {
+			t.Errorf("parseArgs(%q) printed %q, want %q", tt.args, out, tt.want)
+		}
+	}
+}
+
+func TestValidateFlags(t *testing.T) {
+	tests := []struct {
+		command string
+		args    []string
+		err     string
+	}{
+		{"", []string{"-rf", "--trash-dir=/t", "--bytes"}, ""},
+		{"", []string{"--trash-dir"}, "option --trash-dir requires a value"},
+		{"", []string{"--reason="}, ""},
+		{"", []string{"--format=a", "--format=a"}, ""},
+		{"", []string{"--format=a", "--format=b"}, "option --format given more than once with different values"},
+		{"", []string{"--prefer-trash=home", "--prefer-trash=volume"}, ""},
+		{"", []string{"-r", "-r", "-f", "-f"}, ""},
+		{"", []string{"--keep-hidden", "--keep-hidden=2"}, "option --keep-hidden given more than once with different values"},
+		// subcommand options belong to their commands
+		{"", []string{"--sort=size"}, "option --sort is for srm list, not removing files"},
+		{"", []string{"--older-than=3d"}, "option --older-than is for srm empty, not removing files"},
+		{"list", []string{"--sort=size", "--limit=3", "--format=full", "--when=today"}, ""},
+		{"list", []string{"-r"}, "srm list doesn't take option -r"},
+		{"search", []string{"--when=today", "--reason=x"}, ""},
+		{"search", []string{"--sort=name"}, "srm search doesn't take option --sort"},
+		{"empty", []string{"-f", "-v", "--dry-run", "--older-than=3d"}, ""},
+		{"empty", []string{"--limit=1"}, "srm empty doesn't take option --limit"},
+		{"purge", []string{"--force", "--yes"}, ""},
+		{"export", []string{"-o", "x"}, ""},
+		// global options go with every command
+		{"list", []string{"--bytes", "--trash-dir=/t", "--abs", "--help"}, ""},
+		{"doctor", []string{"--relative-to=/", "--no-size-cache"}, ""},
+		{"init", []string{"--trash=home", "--alias"}, ""},
+		// --json is stats', and removal's too
+		{"stats", []string{"--json", "--days=3"}, ""},
+		{"", []string{"--json"}, ""},
+		{"history", []string{"--json"}, "srm history doesn't take option --json"},
+		// srm explain explains a removal, so takes its options
+		{"explain", []string{"-rf", "--prefer-trash=home", "--on-no-trash=tmp"}, ""},
+		{"explain", []string{"--sort=size"}, "srm explain doesn't take option --sort"},
+	}
+	for _, tt := range tests {
+		flags, _ := parseArgs(tt.args)
+		err := validateFlags(tt.command, flags)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("srm %s %q: %v", tt.command, tt.args, err)
+		case tt.err != "" && (err == nil || err.Error() != tt.err):
+			t.Errorf("srm %s %q: error %v, want %q", tt.command, tt.args, err, tt.err)
+		}
+	}
+}
+
+// The table is the one source of parsing, validation, usage and
+// completion, so what they rely on is checked here
+func TestOptionsTable(t *testing.T) {
+	for _, opt := range OPTIONS {
+		for _, name := range append([]string{opt.Name}, opt.Aliases...) {
+			if !strings.HasPrefix(name, "-") || strings.ContainsAny(name, "= ") {
+				t.Errorf("%s: %q isn't an option name", opt.Name, name)
+			}
+			if lookupOption(name) != &OPTIONS[slices.IndexFunc(OPTIONS, func(o Option) bool { return o.Name == opt.Name })] {
+				t.Errorf("%s: lookupOption(%s) finds another option", opt.Name, name)
+			}
+			if len(name) == 2 && opt.Value == OptionalValue {
+				t.Errorf("%s: a short option can't take an optional value", name)
+			}
+		}
+		if (opt.Value == NoValue) != (opt.Arg == "") {
+			t.Errorf("%s: Arg %q doesn't go with its Value", opt.Name, opt.Arg)
+		}
+		if opt.Repeatable && opt.Value == NoValue {
+			t.Errorf("%s: repeatable without a value", opt.Name)
+		}
+		if opt.Help == "" {
+			t.Errorf("%s: no help", opt.Name)
+		}
+		for _, command := range append([]string{opt.Command}, opt.Also...) {
+			if _, ok := SUBCOMMANDS[command]; command != "" && !ok {
+				t.Errorf("%s: no srm %s", opt.Name, command)
+			}
+		}
+		if opt.Global && (opt.Command != "" || len(opt.Also) > 0) {
+			t.Errorf("%s: global and also for particular commands", opt.Name)
+		}
+		if !opt.takes(opt.Command) || !opt.takes("explain") && opt.Command == "" {
+			t.Errorf("%s: not taken by its own command", opt.Name)
+		}
+	}
+	for _, synopsis := range SYNOPSES {
+		if _, ok := SUBCOMMANDS[synopsis.Command]; synopsis.Command != "" && !ok {
+			t.Errorf("usage line for srm %s, which doesn't exist", synopsis.Command)
+		}
+	}
+	names := map[string]bool{}
+	for _, topic := range HELPTOPICS {
+		if names[topic.Name()] {
+			t.Errorf("help topic %s twice", topic.Name())
+		}
+		names[topic.Name()] = true
+		for _, command := range topic.Commands {
+			if _, ok := SUBCOMMANDS[command]; !ok {
+				t.Errorf("help topic %s for srm %s, which doesn't exist", topic.Name(), command)
+			}
+		}
+	}
+}
+
+func TestFlagValues(t *testing.T) {
+	flags := []string{"-v", "--format=csv", "--prefer-trash=home", "--format=", "--prefer-trash=/t=1", "--formats=x"}
+	tests := []struct {
+		name   string
+		values []string
+		last   string
+		ok     bool
+	}{
+		{"--format", []string{"csv", ""}, "", true},
+		{"--prefer-trash", []string{"home", "/t=1"}, "/t=1", true},
+		{"--trash-dir", []string{}, "", false},
+		{"-v", []string{}, "", false},
+	}
+	for _, tt := range tests {
+		if got := remove.FlagValues(tt.name, flags); !slices.Equal(got, tt.values) {
+			t.Errorf("FlagValues(%s) = %q, want %q", tt.name, got, tt.values)
+		}
+		if last, ok := remove.FlagValue(tt.name, flags); last != tt.last || ok != tt.ok {
+			t.Errorf("FlagValue(%s) = %q, %v, want %q, %v", tt.name, last, ok, tt.last, tt.ok)
+		}
+	}
+}
+
+func TestCommandOf(t *testing.T) {
+	tests := []struct {
+		args    []string
+		command string
+		rest    []string
+	}{
+		{[]string{"a", "b"}, "", []string{"a", "b"}},
+		{[]string{"list", "-v"}, "list", []string{"-v"}},
+		{[]string{"--bytes", "du"}, "du", []string{}},
+		{[]string{"--bytes", "--no-size-cache", "list", "x"}, "list", []string{"x"}},
+		// a file called list is removed with ./list or after --
+		{[]string{"./list"}, "", []string{"./list"}},
+		{[]string{"--", "list"}, "", []string{"--", "list"}},
+		{[]string{"-r", "list"}, "", []string{"-r", "list"}},
+		{[]string{"--empty", "-f"}, "empty", []string{"-f"}},
+		{[]string{"-v", "--empty"}, "empty", []string{"-v"}},
+		{[]string{"--list"}, "list", []string{"--sort=deleted", "--format=full"}},
+		{[]string{"--list", "--sort=size", "--format=csv"}, "list", []string{"--sort=size", "--format=csv"}},
+		{[]string{"--list", "--columns=name"}, "list", []string{"--sort=deleted", "--columns=name"}},
+	}
+	for _, tt := range tests {
+		flags, _ := parseArgs(tt.args)
+		command, rest := commandOf(tt.args, flags)
+		if command != tt.command || !slices.Equal(rest, tt.rest) {
+			t.Errorf("commandOf(%q) = %q, %q, want %q, %q", tt.args, command, rest, tt.command, tt.rest)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// Entry is what a --format template sees for each processed entry, both when
+// removing files and when listing the trash
+type Entry struct {
+	// Schema is SCHEMAVERSION, for tools reading --format=json
+	Schema int
+	Name   string
+	Path   string
+	Dest   string
+	Size   int64
+	IsDir  bool
+	Action string
+	// Status is what became of the entry, see STATUSES
+	Status   remove.Status
+	Duration time.Duration
+	// Note explains any fallback taken, e.g. when there was no usable trash
+	Note string
+	// Reason is the --reason the entry was trashed with
+	Reason string
+	// Deleted is when srm list's entry was trashed, zero when nothing
+	// recorded it
+	Deleted time.Time
+	// FSType and Policy say which filesystem type policy applied, if any
+	FSType string
+	Policy string
+	// Trash is the trash directory Dest is in and TrashWhy why it was picked
+	Trash    string
+	TrashWhy string
+	// Volume and TrashVolume are the mount points the entry came from and
+	// went to; trashing only frees space on Volume when they differ
+	Volume      string
+	TrashVolume string
+	// Label is the removable drive srm list's entry is on, by the name it
+	// is mounted under, and Offline says that drive isn't mounted now
+	Label   string
+	Offline bool
+	// DryRun marks entries from --dry-run, which say what would happen;
+	// Prompts, Warnings and Problems are then the questions it would ask,
+	// the warnings it would print and what is likely to fail
+	DryRun   bool
+	Prompts  []string `json:",omitempty"`
+	Warnings []string `json:",omitempty"`
+	Problems []string `json:",omitempty"`
+	// Error is why the entry failed or was skipped
+	Error string `json:",omitempty"`
+	// Op is the operation ID, for srm history show
+	Op string
+	// NameBase64, PathBase64 and DestBase64 carry the exact bytes of names
+	// that aren't valid UTF-8, which JSON strings can't
+	NameBase64 string `json:",omitempty"`
+	PathBase64 string `json:",omitempty"`
+	DestBase64 string `json:",omitempty"`
+}
+
+// formatPresets
+// named templates usable as --format=NAME, keyed by the command they belong to
+var formatPresets = map[string]map[string]string{
+	"remove": {
+		"long": "{{.Action}} {{display .Path}}{{with .Dest}} -> {{display .}}{{end}} ({{size .Size}} in {{.Duration}}){{with .Note}} [{{.}}]{{end}}",
+		"csv":  "{{csv .Action}},{{csv .Path}},{{csv .Dest}},{{.Size}},{{.Duration.Microseconds}},{{csv .Note}}",
+		"json": "{{json .}}",
+	},
+	"list": {
+		"long": "{{if .IsDir}}d{{else}}-{{end}} {{printf \"%12s\" (size .Size)}} {{display .Name}}{{with .Path}}  (from {{display .}}){{end}}{{with .Label}}  [on {{.}}{{if $.Offline}}, offline{{end}}]{{end}}",
+		"full": "{{if .IsDir}}d{{else}}-{{end}} {{printf \"%12s\" (size .Size)}}  {{printf \"%-16s\" (when .Deleted)}}  {{display .Name}}{{with .Path}}  (from {{display .}}){{end}}{{with .Label}}  [on {{.}}{{if $.Offline}}, offline{{end}}]{{end}}",
+		"csv":  "{{csv .Name}},{{csv .Dest}},{{.Size}},{{.IsDir}}",
+		"json": "{{json .}}",
+	},
+}
+
+// listColumns are the names srm list --columns accepts
+var listColumns = map[string]string{
+	"name":    "{{display .Name}}",
+	"path":    "{{display .Path}}",
+	"dest":    "{{display .Dest}}",
+	"size":    "{{size .Size}}",
+	"dir":     "{{.IsDir}}",
+	"reason":  "{{.Reason}}",
+	"deleted": "{{when .Deleted}}",
+	"volume":  "{{with .Label}}{{.}}{{if $.Offline}} (offline){{end}}{{end}}",
+}
+
+// columnsTemplate
+// builds a tab separated template from a comma separated column list.
+// A leading + adds the columns to the default name column instead.
+func columnsTemplate(spec string) (string, error) {
+	names := []string{}
+	if rest, ok := strings.CutPrefix(spec, "+"); ok {
+		names = append(names, "name")
+		spec = rest
+	}
+	names = append(names, strings.Split(spec, ",")...)
+
+	fields := []string{}
+	for _, name := range names {
+		field, ok := listColumns[strings.TrimPrefix(strings.TrimSpace(name), "+")]
+		if !ok {
+			return "", fmt.Errorf("unknown column %q", name)
+		}
+		fields = append(fields, field)
+	}
+	return strings.Join(fields, "\t"), nil
+}
+
+// formatWhen is how list shows a deletion time, "?" when there is none
+func formatWhen(t time.Time) string {
+	if t.IsZero() {
+		return "?"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+var formatFuncs = template.FuncMap{
+	"csv":     csvField,
+	"size":    remove.FormatSize,
+	"display": remove.DisplayName,
+	"when":    formatWhen,
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// Formatter renders one line per Entry
+type Formatter struct {
+	tmpl *template.Template
+}
+
+// NewFormatter
+// resolves spec against the presets for command (or treats it as a raw template)
+// and dry-runs it so bad field names are caught before anything is touched
+func NewFormatter(command string, spec string) (*Formatter, error) {
+	if preset, ok := formatPresets[command][spec]; ok {
+		spec = preset
+	}
+
+	tmpl, err := template.New("format").Funcs(formatFuncs).Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tmpl.Execute(io.Discard, Entry{}); err != nil {
+		return nil, err
+	}
+
+	return &Formatter{tmpl: tmpl}, nil
+}
+
+// Write renders e to w, terminating the line if the template didn't
+func (f *Formatter) Write(w io.Writer, e Entry) error {
+	e.Schema = remove.SCHEMAVERSION
+	var sb strings.Builder
+	if err := f.tmpl.Execute(&sb, e); err != nil {
+		return err
+	}
+
+	line := sb.String()
+	if !strings.HasSuffix(line, "\n") {
+		line += "\n"
+	}
+
+	_, err := fmt.Fprint(w, line)
+	return err
+}
+
+// resultEntry turns a Remover Result into what --format templates see
+func resultEntry(r remove.Result) Entry {
+	path := r.Source
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	entry := Entry{
+		Name:        filepath.Base(r.Source),
+		Path:        path,
+		Dest:        r.Dest,
+		Size:        r.Bytes,
+		IsDir:       r.IsDir,
+		Action:      r.Action,
+		Status:      r.Status(),
+		Duration:    r.Duration,
+		Note:        r.Note,
+		FSType:      r.FSType,
+		Policy:      r.Policy,
+		Trash:       r.Trash,
+		TrashWhy:    r.TrashWhy,
+		Volume:      r.Volume,
+		TrashVolume: r.TrashVolume,
+		Prompts:     r.Prompts,
+		Warnings:    r.Warnings,
+		Problems:    r.Problems,
+		Op:          r.Op,
+	}
+	if r.Err != nil {
+		entry.Error = r.Err.Error()
+	}
+	return entry.withBase64()
+}
+
+// withBase64 fills in the Base64 fields for names JSON would mangle
+func (e Entry) withBase64() Entry {
+	e.NameBase64, e.PathBase64, e.DestBase64 = remove.Base64Name(e.Name), remove.Base64Name(e.Path), remove.Base64Name(e.Dest)
+	return e
+}
+
+// csvField quotes s if it contains anything csv would choke on
+func csvField(s string) string {
+	if strings.ContainsAny(s, ",\"\r\n") {
+		return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+	}
+	return s
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// --format=json carries every path exactly, in PathBase64 when JSON can't
+func TestFormatJSONNames(t *testing.T) {
+	formatter, err := NewFormatter("remove", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for _, name := range []string{
+		"plain.txt",
+		"\xff\xfe invalid",
+		"half\xe2\x82 rune",
+		"new\nline",
+		"esc\x1b[31mred\x1b[0m",
+		"del\x7f",
+		"back\\slash",
+		`"quoted"`,
+		"‮rtl override",
+		"c1\u0085next line",
+	} {
+		path := filepath.Join(dir, name)
+		result := remove.Result{Source: path, Dest: filepath.Join(dir, "trash", name), Action: "trashed"}
+		var out bytes.Buffer
+		if err := formatter.Write(&out, resultEntry(result)); err != nil {
+			t.Errorf("%q: %v", name, err)
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+			t.Errorf("%q: wrote %q: %v", name, out.Bytes(), err)
+			continue
+		}
+		if got := entryPath(entry); got != path {
+			t.Errorf("%q: has the path as %q", name, got)
+		}
+	}
+}
+
+// entryPath is the exact path of a --format=json entry, from PathBase64
+// when JSON couldn't carry it
+func entryPath(e Entry) string {
+	if e.PathBase64 == "" {
+		return e.Path
+	}
+	raw, err := base64.StdEncoding.DecodeString(e.PathBase64)
+	if err != nil {
+		return ""
+	}
+	return string(raw)
+}
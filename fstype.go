@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// what a filesystem type policy can ask for
+var FSPOLICIES = []string{"trash", "permanent", "ask", "skip"}
+
+// fsPolicies reads the fstype[PATTERN] = POLICY keys from config, most
+// specific first: exact types before globs, longer globs before shorter
+func fsPolicies(config remove.Config) ([]remove.FSPolicy, error) {
+	policies := []remove.FSPolicy{}
+	for key, value := range config {
+		pattern, ok := strings.CutPrefix(key, "fstype[")
+		if !ok || !strings.HasSuffix(pattern, "]") {
+			continue
+		}
+		pattern = strings.TrimSuffix(pattern, "]")
+		if _, err := filepath.Match(pattern, ""); err != nil || pattern == "" {
+			return nil, fmt.Errorf("%s: bad filesystem type pattern", key)
+		}
+		if !remove.In(value, FSPOLICIES) {
+			return nil, fmt.Errorf("%s: expected trash, permanent, ask or skip, got %q", key, value)
+		}
+		policies = append(policies, remove.FSPolicy{Pattern: pattern, Policy: value})
+	}
+
+	isGlob := func(p string) bool { return strings.ContainsAny(p, "*?[") }
+	sort.Slice(policies, func(i, j int) bool {
+		a, b := policies[i].Pattern, policies[j].Pattern
+		if isGlob(a) != isGlob(b) {
+			return !isGlob(a)
+		}
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+		return a < b
+	})
+	return policies, nil
+}
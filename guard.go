@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// WILDCARDGUARD is the default wildcard_guard: operands that are this
+// percentage or more of their directory's entries look like `srm -rf * .*`
+// run in the wrong place
+const WILDCARDGUARD = 80
+
+// WILDCARDMINOPERANDS is how many operands from one directory it takes
+// before the guard looks at what share of it they are, so that removing
+// two of a directory's three files doesn't ask
+const WILDCARDMINOPERANDS = 4
+
+// wildcardGuardPercent reads wildcard_guard from the config: a percentage,
+// 0 turning the guard off
+func wildcardGuardPercent(config remove.Config) (int, error) {
+	value, ok := config["wildcard_guard"]
+	if !ok {
+		return WILDCARDGUARD, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 || n > 100 {
+		return 0, fmt.Errorf("wildcard_guard: expected a percentage from 0 (off) to 100, got %q", value)
+	}
+	return n, nil
+}
+
+// wildcardSweep looks for the signature of a wildcard that took in far
+// more than was meant: an operand that is the current directory's parent
+// or above it, like the .. that .* expands to, or operands that are percent
+// or more of the entries of the directory most of them are in. It reads
+// that one directory and nothing else. The question names the directory
+// and the share of it about to go; ok is false when nothing looks wrong.
+func wildcardSweep(paths []string, percent int) (question string, ok bool) {
+	if percent == 0 {
+		return "", false
+	}
+
+	cwd, err := os.Getwd()
+	if err == nil {
+		parent := filepath.Dir(cwd)
+		for _, path := range paths {
+			// .. is refused outright, without asking
+			if remove.CheckPreserved(remove.OSFS{}, path, true) != nil {
+				continue
+			}
+			operand := remove.CanonicalOperand(path)
+			if rel, err := filepath.Rel(operand, parent); err == nil && (rel == "." || !remove.IsParentRel(rel)) {
+				return fmt.Sprintf("%s is %s, which holds the current directory; remove 100%% of it?", remove.DisplayPath(path), remove.DisplayPath(operand)), true
+			}
+		}
+	}
+
+	// the directory most operands are in, and which of its entries they are
+	byDir := map[string]map[string]bool{}
+	common := ""
+	for _, path := range paths {
+		operand := remove.CanonicalOperand(path)
+		dir := filepath.Dir(operand)
+		if byDir[dir] == nil {
+			byDir[dir] = map[string]bool{}
+		}
+		byDir[dir][filepath.Base(operand)] = true
+		if len(byDir[dir]) > len(byDir[common]) {
+			common = dir
+		}
+	}
+	if len(byDir[common]) < WILDCARDMINOPERANDS {
+		return "", false
+	}
+
+	f, err := os.Open(common)
+	if err != nil {
+		return "", false
+	}
+	names, err := f.Readdirnames(-1)
+	f.Close()
+	if err != nil || len(names) == 0 {
+		return "", false
+	}
+	covered := 0
+	for _, name := range names {
+		if byDir[common][name] {
+			covered++
+		}
+	}
+	share := covered * 100 / len(names)
+	if share < percent {
+		return "", false
+	}
+	return fmt.Sprintf("the operands are %d%% of %s (%d of its %d entries); remove them?", share, remove.DisplayPath(common), covered, len(names)), true
+}
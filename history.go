@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// Operation is every journal record sharing one operation ID
+type Operation struct {
+	ID    string
+	Start time.Time
+	Argv  []string
+	// Argc and ArgvHash are set when Argv was cut short, see recordedArgv
+	Argc     int
+	ArgvHash string
+	User     string
+	SudoUser string
+	Cwd      string
+	TTY      string
+	Duration time.Duration
+	Files    []JournalRecord
+}
+
+// Counts tallies the operation's files by action
+func (op *Operation) Counts() map[string]int {
+	counts := map[string]int{}
+	for _, f := range op.Files {
+		counts[f.Action]++
+	}
+	return counts
+}
+
+// Failed reports whether any file in the operation failed
+func (op *Operation) Failed() bool {
+	return op.Counts()["failed"] > 0
+}
+
+// loadOperations groups the journal into operations in the order they started
+func loadOperations() ([]*Operation, error) {
+	ops := []*Operation{}
+	byID := map[string]*Operation{}
+
+	err := readJournal(func(record JournalRecord) {
+		op, ok := byID[record.Op]
+		if !ok {
+			op = &Operation{ID: record.Op, Start: record.Time}
+			byID[record.Op] = op
+			ops = append(ops, op)
+		}
+
+		switch record.Kind {
+		case "start":
+			op.Start, op.Argv, op.User = record.Time, record.Argv, record.User
+			op.Argc, op.ArgvHash = record.Argc, record.ArgvHash
+			op.SudoUser, op.Cwd, op.TTY = record.SudoUser, record.Cwd, record.TTY
+		case "file":
+			op.Files = append(op.Files, record)
+		case "end":
+			op.Duration = record.Duration
+		}
+	})
+
+	return ops, err
+}
+
+// historyCommand
+// srm history [--path SUBSTR] [--since WHEN] [--failed-only]
+// srm history show <op-id>
+func historyCommand(args []string) {
+	if len(args) > 0 && args[0] == "show" {
+		historyShow(args[1:])
+		return
+	}
+
+	flags, rest := parseArgs(args)
+	if len(rest) > 0 {
+		fmt.Fprintf(os.Stderr, "srm history: unexpected argument %s\n", rest[0])
+		os.Exit(1)
+	}
+
+	pathFilter, _ := remove.FlagValue("--path", flags)
+	failedOnly := remove.In("--failed-only", flags)
+
+	var since time.Time
+	if value, ok := remove.FlagValue("--since", flags); ok {
+		var err error
+		since, err = parseSince(value, time.Now())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "srm history: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	ops, err := loadOperations()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm history: %s\n", err)
+		os.Exit(1)
+	}
+
+	stopPager := startPager()
+	defer stopPager()
+	for _, op := range ops {
+		if op.Start.Before(since) {
+			continue
+		}
+		if failedOnly && !op.Failed() {
+			continue
+		}
+		if pathFilter != "" && !op.touches(pathFilter) {
+			continue
+		}
+
+		fmt.Printf("%s  %s  %-8s %8s  %-24s %s\n",
+			op.ID,
+			op.Start.Local().Format("2006-01-02 15:04:05"),
+			op.User,
+			op.Duration.Round(time.Millisecond),
+			summarizeCounts(op.Counts()),
+			remove.DisplayName(shellJoin(op.Argv)),
+		)
+	}
+}
+
+// historyShow prints every per-file record of a single operation
+func historyShow(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: srm history show <op-id>")
+		os.Exit(1)
+	}
+
+	ops, err := loadOperations()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm history: %s\n", err)
+		os.Exit(1)
+	}
+
+	for _, op := range ops {
+		if op.ID != args[0] {
+			continue
+		}
+		stopPager := startPager()
+		defer stopPager()
+
+		fmt.Printf("operation %s\n", op.ID)
+		fmt.Printf("  started   %s\n", op.Start.Local().Format(time.RFC3339))
+		if op.SudoUser != "" {
+			fmt.Printf("  user      %s (sudo from %s)\n", op.User, op.SudoUser)
+		} else {
+			fmt.Printf("  user      %s\n", op.User)
+		}
+		if op.TTY != "" {
+			fmt.Printf("  tty       %s\n", op.TTY)
+		}
+		if op.Cwd != "" {
+			fmt.Printf("  cwd       %s\n", remove.DisplayName(op.Cwd))
+		}
+		switch {
+		case op.Argv == nil:
+			fmt.Printf("  command   not recorded\n")
+		case op.Argc > len(op.Argv):
+			fmt.Printf("  command   %s ... (%d arguments in all, sha256 %s)\n", remove.DisplayName(shellJoin(op.Argv)), op.Argc, op.ArgvHash)
+		default:
+			fmt.Printf("  command   %s\n", remove.DisplayName(shellJoin(op.Argv)))
+		}
+		fmt.Printf("  duration  %s\n", op.Duration.Round(time.Millisecond))
+		fmt.Printf("  files     %s\n", summarizeCounts(op.Counts()))
+		for _, f := range op.Files {
+			f.Source, f.Dest = remove.DisplayName(f.Source), remove.DisplayName(f.Dest)
+			// records from before statuses only have the action
+			if f.Status != "" {
+				f.Action = string(f.Status)
+			}
+			switch {
+			case f.Error != "":
+				fmt.Printf("  %-8s %s: %s\n", f.Action, f.Source, errorDetail(f.Source, f.Error))
+			case f.Dest != "" && f.Bytes > 0:
+				fmt.Printf("  %-8s %s -> %s (%s)\n", f.Action, f.Source, f.Dest, remove.FormatSize(f.Bytes))
+			case f.Dest != "":
+				fmt.Printf("  %-8s %s -> %s\n", f.Action, f.Source, f.Dest)
+			default:
+				fmt.Printf("  %-8s %s\n", f.Action, f.Source)
+			}
+		}
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "srm history: no operation %s\n", args[0])
+	os.Exit(1)
+}
+
+// errorDetail is msg without the path it leads with when that is source,
+// which history shows already. The error names it as it was typed, so a
+// relative path source ends with counts too.
+func errorDetail(source string, msg string) string {
+	path, detail, ok := strings.Cut(msg, ": ")
+	if ok && (path == source || strings.HasSuffix(source, "/"+strings.TrimPrefix(path, "./"))) {
+		return detail
+	}
+	return msg
+}
+
+// touches reports whether any file in the operation has substr in its path
+func (op *Operation) touches(substr string) bool {
+	for _, f := range op.Files {
+		if strings.Contains(f.Source, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// summarizeCounts
+// {"trashed": 2, "failed": 1} --> "1 failed, 2 trashed"
+func summarizeCounts(counts map[string]int) string {
+	actions := []string{}
+	for action := range counts {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+
+	parts := []string{}
+	for _, action := range actions {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[action], action))
+	}
+	if len(parts) == 0 {
+		return "nothing"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// parseSince
+// accepts a duration back from now ("7d", "12h", "30m") or a date/time
+// ("2024-06-01", "2024-06-01 14:00", RFC 3339)
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+
+	for _, layout := range []string{"2006-01-02", "2006-01-02 15:04", "2006-01-02 15:04:05"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid --since %q (try 7d, 12h, 2024-06-01 or 2024-06-01 14:00)", value)
+}
+
+// shellJoin quotes argv back into something that could be pasted into a shell,
+// naming the program by its base name
+func shellJoin(argv []string) string {
+	quoted := []string{}
+	for i, arg := range argv {
+		if i == 0 {
+			arg = filepath.Base(arg)
+		}
+		if arg == "" || strings.ContainsAny(arg, " \t\n'\"\\$`*?[]{}()<>|&;#~!") {
+			arg = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+		quoted = append(quoted, arg)
+	}
+	return strings.Join(quoted, " ")
+}
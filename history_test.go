@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestErrorDetail(t *testing.T) {
+	tests := []struct {
+		source string
+		msg    string
+		want   string
+	}{
+		{"/tmp/w/c", "c: not asked, the session was aborted", "not asked, the session was aborted"},
+		{"/tmp/w/c", "./c: declined", "declined"},
+		{"/tmp/w/sub/c", "sub/c: declined", "declined"},
+		{"/tmp/w/c", "/tmp/w/c: permission denied", "permission denied"},
+		{"/tmp/w/abc", "c: declined", "c: declined"},
+		{"/tmp/w/c", "rename /tmp/w/c /t/c: invalid cross-device link", "rename /tmp/w/c /t/c: invalid cross-device link"},
+	}
+	for _, tt := range tests {
+		if got := errorDetail(tt.source, tt.msg); got != tt.want {
+			t.Errorf("errorDetail(%q, %q) = %q, want %q", tt.source, tt.msg, got, tt.want)
+		}
+	}
+}
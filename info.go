@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// infoCommand
+// srm info <entry ...>
+// prints everything srm recorded about trash entries
+func infoCommand(args []string) {
+	_, queries := parseArgs(args)
+	if len(queries) == 0 {
+		fmt.Fprintln(os.Stderr, "srm info: no entries given")
+		os.Exit(1)
+	}
+
+	index, err := remove.OpenIndex()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm info: %s\n", err)
+		os.Exit(1)
+	}
+	entries, err := index.Entries()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm info: %s\n", err)
+		os.Exit(1)
+	}
+
+	failed := false
+	for i, query := range queries {
+		matches := resolveEntries(entries, query)
+		if len(matches) == 0 {
+			fmt.Fprintf(os.Stderr, "srm info: %s: not in the trash\n", remove.DisplayName(query))
+			failed = true
+			continue
+		}
+
+		for j, entry := range matches {
+			if i > 0 || j > 0 {
+				fmt.Println()
+			}
+			printEntryInfo(entry)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func printEntryInfo(entry remove.IndexEntry) {
+	field := func(name, value string) {
+		if value != "" {
+			fmt.Printf("%-9s %s\n", name, remove.DisplayName(value))
+		}
+	}
+
+	kind := "file"
+	if entry.IsDir {
+		kind = "directory"
+	}
+
+	field("id", entry.ID)
+	field("origin", entry.Origin)
+	field("payload", entry.Payload())
+	field("deleted", entry.Deleted.Local().Format(time.RFC3339))
+	field("type", kind)
+	field("size", remove.FormatSize(entry.Size))
+	field("archive", entry.Archive)
+	field("op", entry.Op)
+	field("reason", entry.ReasonText())
+
+	if t := entry.Times; t != nil {
+		stamp := func(name string, v time.Time) {
+			if !v.IsZero() {
+				field(name, v.Local().Format(time.RFC3339Nano))
+			}
+		}
+		stamp("modified", t.Modified)
+		stamp("accessed", t.Accessed)
+		stamp("changed", t.Changed)
+		stamp("born", t.Born)
+	}
+}
+
+// searchCommand
+// srm search [--reason TEXT] [--when WHEN]
+// lists trash entries whose --reason contains TEXT, ignoring case, and that
+// were deleted within WHEN
+func searchCommand(args []string) {
+	flags, rest := parseArgs(args)
+	if len(rest) > 0 {
+		fmt.Fprintf(os.Stderr, "srm search: unexpected argument %s\n", rest[0])
+		os.Exit(1)
+	}
+	text, hasReason := remove.FlagValue("--reason", flags)
+	expr, hasWhen := remove.FlagValue("--when", flags)
+	if !hasReason && !hasWhen {
+		fmt.Fprintln(os.Stderr, "srm search: nothing to search for, try --reason TEXT or --when WHEN")
+		os.Exit(1)
+	}
+
+	when := WhenRange{}
+	if hasWhen {
+		var err error
+		if when, err = parseWhen(expr, time.Now()); err != nil {
+			fmt.Fprintf(os.Stderr, "srm search: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	index, err := remove.OpenIndex()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm search: %s\n", err)
+		os.Exit(1)
+	}
+
+	// matches print as they are found, nothing is held on to
+	text = strings.ToLower(text)
+	err = index.Scan(func(entry remove.IndexEntry, offset int64) bool {
+		reason := entry.ReasonText()
+		if hasReason && (reason == "" || !strings.Contains(strings.ToLower(reason), text)) {
+			return true
+		}
+		if when.Contains(entry.Deleted) {
+			fmt.Printf("%s  %s  %s\n", entry.ID, remove.DisplayName(entry.Origin), remove.DisplayName(reason))
+		}
+		return true
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm search: %s\n", err)
+		os.Exit(1)
+	}
+}
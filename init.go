@@ -0,0 +1,344 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// INITSTAMP is the file in srm's data dir that says the first run notice
+// has been shown, or that srm init made it unnecessary
+const INITSTAMP = "initialized"
+
+// ALIASLINE is what srm init adds to the shell's startup file
+const ALIASLINE = "alias rm='srm'  # added by srm init"
+
+// initCommand
+// srm init [--trash home|volume|DIR] [--alias] [--timer]
+// chooses the trash and creates it, writes prefer_trash to the user config,
+// and with --alias and --timer adds alias rm=srm to the shell's startup file
+// and installs the maintenance timer. On a terminal it asks about whatever
+// no flag settled. Running it again with the same choices changes nothing.
+func initCommand(args []string) {
+	flags, rest := parseArgs(args)
+	if len(rest) > 0 {
+		fmt.Fprintf(os.Stderr, "srm init: unexpected argument %s\n", rest[0])
+		os.Exit(1)
+	}
+	fail := func(err error) {
+		fmt.Fprintf(os.Stderr, "srm init: %s\n", err)
+		os.Exit(1)
+	}
+	interactive := remove.IsTTY(os.Stdin) && remove.CanAsk()
+
+	where, ok := remove.FlagValue("--trash", flags)
+	if !ok && interactive {
+		where = askLine("where should trashed files go: home (~/.Trash), volume (a .Trash-UID on each filesystem, home otherwise) or a directory? [home] ")
+	}
+	prefer, err := initPrefer(where)
+	if err != nil {
+		fail(err)
+	}
+	rc, rcErr := shellStartupFile()
+	alias := remove.In("--alias", flags)
+	if !alias && interactive && rcErr == nil {
+		alias = remove.In(remove.GetUserAnswer(fmt.Sprintf("add %s to %s? [y/N] ", ALIASLINE, rc)), remove.YESANSWERS)
+	}
+	timer := remove.In("--timer", flags)
+	if !timer && interactive {
+		timer = remove.In(remove.GetUserAnswer("run srm maintain daily? [y/N] "), remove.YESANSWERS)
+	}
+
+	summary := [][2]string{}
+	step := func(name, what string) {
+		summary = append(summary, [2]string{name, what})
+	}
+
+	trash, created, err := createTrash(prefer)
+	if err != nil {
+		fail(err)
+	}
+	if created {
+		step("trash", trash.Dir+" (created; "+trash.Why+")")
+	} else {
+		step("trash", trash.Dir+" ("+trash.Why+")")
+	}
+
+	value := configList(prefer)
+	if settings, err := remove.LoadSettings(); err != nil {
+		fail(err)
+	} else if settings.Locked["prefer_trash"] {
+		step("config", "prefer_trash is locked by "+remove.SYSTEMCONFIG+", left alone")
+	} else if path, err := remove.UserConfigPath(); err != nil {
+		fail(err)
+	} else if changed, err := setConfigValue(path, "prefer_trash", value); err != nil {
+		fail(err)
+	} else if changed {
+		step("config", fmt.Sprintf("prefer_trash = %s written to %s", value, path))
+	} else {
+		step("config", fmt.Sprintf("prefer_trash = %s already in %s", value, path))
+	}
+
+	if alias {
+		if rcErr != nil {
+			fail(rcErr)
+		}
+		existing, err := addAlias(rc)
+		switch {
+		case err != nil:
+			fail(err)
+		case existing == "":
+			step("alias", "added to "+rc+", open a new shell to use it")
+		case existing == ALIASLINE:
+			step("alias", "already in "+rc)
+		default:
+			step("alias", fmt.Sprintf("%s already aliases rm (%s), left as it is", rc, existing))
+		}
+	}
+
+	if timer {
+		if status := timerStatus(); strings.HasPrefix(status, "daily") {
+			step("maintenance", status+", already")
+		} else if err := installTimer(); err != nil {
+			fail(err)
+		} else {
+			step("maintenance", timerStatus())
+		}
+	}
+
+	// srm init says everything the first run notice would
+	if err := writeInitStamp(); err != nil {
+		fail(err)
+	}
+
+	for _, line := range summary {
+		fmt.Printf("%-12s %s\n", line[0]+":", line[1])
+	}
+}
+
+// askLine prints msg and returns the line answered, trimmed but otherwise
+// as typed, unlike GetUserAnswer; "" at end of input
+func askLine(msg string) string {
+	fmt.Print(msg)
+	line, _ := remove.AnswerInput().ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+// initPrefer turns srm init's answer into prefer_trash: home, volume
+// falling back to home, or an absolute directory
+func initPrefer(where string) ([]string, error) {
+	switch where {
+	case "", "home":
+		return []string{"home"}, nil
+	case "volume":
+		return []string{"volume", "home"}, nil
+	}
+	if strings.HasPrefix(where, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		where = filepath.Join(home, where[2:])
+	}
+	abs, err := filepath.Abs(where)
+	if err != nil {
+		return nil, err
+	}
+	return []string{abs}, nil
+}
+
+// createTrash resolves the trash a run with prefer would use, the same way
+// a removal does, and creates it owner-only if it isn't there yet. A volume
+// trash only makes sense per operand, so for the run as a whole that is the
+// home trash.
+func createTrash(prefer []string) (remove.TrashCandidate, bool, error) {
+	candidates := remove.ResolveTrash(remove.CurrentTrashContext("", prefer))
+	if len(candidates) == 0 {
+		return remove.TrashCandidate{}, false, fmt.Errorf("%w (HOME is not set, so there is no ~/.Trash; set HOME, or set %s to a trash directory)", remove.ErrTrashUnavailable, remove.TRASHDIRENV)
+	}
+	trash := candidates[0]
+
+	_, err := os.Stat(trash.Dir)
+	created := errors.Is(err, fs.ErrNotExist)
+	if created {
+		if err := remove.MkdirPrivate(trash.Dir); err != nil {
+			return trash, false, err
+		}
+	}
+	if trash.Kind == "xdg" {
+		if err := remove.MkdirPrivate(filepath.Join(filepath.Dir(trash.Dir), "info")); err != nil {
+			return trash, created, err
+		}
+	}
+	return trash, created, remove.CheckTrashCandidate(trash)
+}
+
+// configList formats items the way Config.List reads them back
+func configList(items []string) string {
+	quoted := []string{}
+	for _, item := range items {
+		quoted = append(quoted, strconv.Quote(item))
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// setConfigValue makes key = value the setting in the config file at path:
+// the line already setting key is replaced in place, or one is added at the
+// end, and everything else, comments included, is kept. changed is false
+// when key already had that value, leaving the file untouched.
+func setConfigValue(path string, key string, value string) (changed bool, err error) {
+	config, err := remove.ReadConfig(path)
+	if err != nil {
+		return false, err
+	}
+	if current, ok := config[key]; ok && current == value {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+	lines := []string{}
+	if len(data) > 0 {
+		lines = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	}
+	line := key + " = " + value
+	replaced := false
+	for i, existing := range lines {
+		name, _, ok := strings.Cut(existing, "=")
+		if ok && strings.TrimSpace(name) == key && !strings.HasPrefix(strings.TrimSpace(existing), "#") {
+			lines[i], replaced = line, true
+		}
+	}
+	if !replaced {
+		lines = append(lines, line)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".config-")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		tmp.Close()
+		return false, err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return false, err
+	}
+	if err := tmp.Close(); err != nil {
+		return false, err
+	}
+	return true, os.Rename(tmp.Name(), path)
+}
+
+// shellStartupFile is where $SHELL reads aliases from
+func shellStartupFile() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	switch shell := filepath.Base(os.Getenv("SHELL")); shell {
+	case "bash":
+		return filepath.Join(home, ".bashrc"), nil
+	case "zsh":
+		if dir := os.Getenv("ZDOTDIR"); dir != "" {
+			return filepath.Join(dir, ".zshrc"), nil
+		}
+		return filepath.Join(home, ".zshrc"), nil
+	case "fish":
+		return filepath.Join(home, ".config", "fish", "config.fish"), nil
+	case ".", "":
+		return "", errors.New("SHELL isn't set, so there is no telling where to put the alias")
+	default:
+		return "", fmt.Errorf("srm doesn't know where %s keeps aliases; add alias rm='srm' yourself", shell)
+	}
+}
+
+// addAlias appends ALIASLINE to the startup file rc unless rc aliases rm
+// already, in which case that line is returned and rc left alone
+func addAlias(rc string) (existing string, err error) {
+	data, err := os.ReadFile(rc)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "alias rm=") || strings.HasPrefix(line, "alias rm ") {
+			return line, nil
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(rc), 0755); err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(rc, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return "", err
+	}
+	text := ALIASLINE + "\n"
+	if len(data) > 0 && !strings.HasSuffix(string(data), "\n") {
+		text = "\n" + text
+	}
+	if _, err := f.WriteString(text); err != nil {
+		f.Close()
+		return "", err
+	}
+	return "", f.Close()
+}
+
+// initStampPath is INITSTAMP in srm's data dir
+func initStampPath() (string, error) {
+	dir, err := remove.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, INITSTAMP), nil
+}
+
+// writeInitStamp records that the first run notice needn't be shown
+func writeInitStamp() error {
+	path, err := initStampPath()
+	if err != nil {
+		return err
+	}
+	f, err := remove.OpenPrivate(path, os.O_WRONLY|os.O_CREATE)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// firstRunNotice is said once, after the first removal that trashed
+// something with no user config: where trashed files go and how to choose
+// another place. The stamp is written first, so a notice that can't be
+// recorded as shown isn't shown at all rather than on every run.
+func firstRunNotice(trashed remove.Result) string {
+	if path, err := remove.UserConfigPath(); err != nil {
+		return ""
+	} else if _, err := os.Stat(path); err == nil {
+		return ""
+	}
+	path, err := initStampPath()
+	if err != nil {
+		return ""
+	}
+	if _, err := os.Stat(path); err == nil {
+		return ""
+	}
+	if writeInitStamp() != nil {
+		return ""
+	}
+	return fmt.Sprintf("trashed files go to %s (%s); srm init chooses another place (said only this once)", trashed.Trash, trashed.TrashWhy)
+}
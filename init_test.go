@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// Running srm init a second time with the same choices changes nothing,
+// and says so
+func TestInitTwice(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_DATA_HOME", filepath.Join(home, ".local", "share"))
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("SHELL", "/bin/bash")
+	t.Setenv(remove.CONFIGENV, "")
+	t.Setenv(remove.TRASHDIRENV, "")
+	// not a terminal, so nothing is asked
+	devnull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldStdin := os.Stdin
+	os.Stdin = devnull
+	t.Cleanup(func() { os.Stdin = oldStdin; devnull.Close() })
+	oldSystem := remove.SYSTEMCONFIG
+	remove.SYSTEMCONFIG = filepath.Join(home, "system.config")
+	t.Cleanup(func() { remove.SYSTEMCONFIG = oldSystem })
+
+	config := filepath.Join(home, ".config", "srm", "config")
+	rc := filepath.Join(home, ".bashrc")
+	if err := os.MkdirAll(filepath.Dir(config), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(config, []byte("# mine\nmax_entries = 10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(rc, []byte("export EDITOR=vi"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// snapshot is everything init can touch
+	snapshot := func() map[string]string {
+		files := map[string]string{}
+		filepath.Walk(home, func(path string, fi os.FileInfo, err error) error {
+			if err == nil {
+				data, _ := os.ReadFile(path)
+				files[path] = fi.Mode().String() + " " + string(data)
+			}
+			return nil
+		})
+		return files
+	}
+
+	args := []string{"--trash", "volume", "--alias"}
+	first := stdout(t, func() { initCommand(args) })
+	after := snapshot()
+	second := stdout(t, func() { initCommand(args) })
+
+	if again := snapshot(); len(again) != len(after) {
+		t.Errorf("the second run left %d files, the first %d", len(again), len(after))
+	} else {
+		for path, was := range after {
+			if again[path] != was {
+				t.Errorf("the second run changed %s from %q to %q", path, was, again[path])
+			}
+		}
+	}
+	for _, want := range []string{"created", "written to", "added to"} {
+		if !strings.Contains(first, want) {
+			t.Errorf("the first run didn't say %q:\n%s", want, first)
+		}
+		if strings.Contains(second, want) {
+			t.Errorf("the second run said %q:\n%s", want, second)
+		}
+	}
+	for _, want := range []string{"already in " + config, "already in " + rc} {
+		if !strings.Contains(second, want) {
+			t.Errorf("the second run didn't say %q:\n%s", want, second)
+		}
+	}
+
+	if data, _ := os.ReadFile(config); string(data) != "# mine\nmax_entries = 10\nprefer_trash = [\"volume\", \"home\"]\n" {
+		t.Errorf("config is %q", data)
+	}
+	if data, _ := os.ReadFile(rc); string(data) != "export EDITOR=vi\n"+ALIASLINE+"\n" {
+		t.Errorf(".bashrc is %q", data)
+	}
+}
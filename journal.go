@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// JournalRecord is one line of the journal. Every invocation that removes
+// anything writes a start record, one file record per operand and an end
+// record, all sharing the same operation ID.
+type JournalRecord struct {
+	// Schema is the SCHEMAVERSION the record was written with
+	Schema int       `json:"v,omitempty"`
+	Op     string    `json:"op"`
+	Kind   string    `json:"kind"` // start, file or end
+	Time   time.Time `json:"time"`
+
+	// start. Argv is cut to MAXRECORDEDARGV, Argc and ArgvHash then being
+	// the count and SHA-256 of all of it.
+	Argv     []string `json:"argv,omitempty"`
+	Argc     int      `json:"argc,omitempty"`
+	ArgvHash string   `json:"argv_sha256,omitempty"`
+	User     string   `json:"user,omitempty"`
+	SudoUser string   `json:"sudo_user,omitempty"`
+	Cwd      string   `json:"cwd,omitempty"`
+	TTY      string   `json:"tty,omitempty"`
+
+	// file
+	Action string        `json:"action,omitempty"`
+	Status remove.Status `json:"status,omitempty"`
+	Source string        `json:"source,omitempty"`
+	Dest   string        `json:"dest,omitempty"`
+	Bytes  int64         `json:"bytes,omitempty"`
+	Error  string        `json:"error,omitempty"`
+
+	// end
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+func journalPath() (string, error) {
+	dir, err := remove.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "journal"), nil
+}
+
+// newOpID returns a random identifier for one invocation. 64 bits make
+// a collision with an earlier operation unlikely enough not to look for one
+func newOpID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Journal appends the records of a single invocation
+type Journal struct {
+	f     *os.File
+	op    string
+	start time.Time
+	// refused is set when the journal was a symlink, and not written
+	refused bool
+}
+
+// openJournal starts the journal entry for operation op. Failing to journal
+// never stops a removal, so errors are only reported; a journal refused for
+// being a symlink also makes the run exit 1, see Refused.
+func openJournal(op string, argv []string) *Journal {
+	j := &Journal{op: op, start: time.Now()}
+
+	// rotated first, so a run's records all land in one generation
+	if _, err := rotateJournal(remove.CurrentAuxLimits()); err != nil {
+		fmt.Fprintf(os.Stderr, "srm: journal: rotating: %s\n", err)
+	}
+	path, err := journalPath()
+	if err == nil {
+		j.f, err = remove.OpenPrivate(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm: journal: %s\n", err)
+		j.refused = errors.Is(err, remove.ErrSymlinkRefused)
+		return j
+	}
+
+	username := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		username = u.Username
+	}
+	record := JournalRecord{Kind: "start", User: username, SudoUser: os.Getenv("SUDO_USER"), TTY: remove.TTYName()}
+	record.Cwd, _ = os.Getwd()
+
+	// the command line may say more than a privacy-minded config wants kept
+	keep, redact := true, false
+	if settings, err := remove.LoadSettings(); err == nil {
+		if _, set := settings.Config["record_argv"]; set {
+			keep, err = settings.Config.Bool("record_argv")
+		}
+		if err == nil {
+			redact, err = settings.Config.Bool("redact_argv")
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "srm: journal: %s, not recording the command line\n", err)
+			keep = false
+		}
+	}
+	if keep {
+		record.Argv, record.Argc, record.ArgvHash = recordedArgv(argv, redact)
+	}
+
+	j.write(record)
+	return j
+}
+
+// MAXRECORDEDARGV is how many arguments a start record keeps. A shell glob
+// can hand srm 100k operands, which would make every start record of the
+// journal as big as the command line.
+const MAXRECORDEDARGV = 64
+
+// REDACTED stands in for the value of a Sensitive option
+const REDACTED = "<redacted>"
+
+// recordedArgv is argv as a start record keeps it: with redact, the values
+// of Sensitive options given before -- replaced with REDACTED, and past
+// MAXRECORDEDARGV cut short, argc and hash then being the count and
+// SHA-256 of every argument as recorded, NUL separated
+func recordedArgv(argv []string, redact bool) (kept []string, argc int, hash string) {
+	kept = make([]string, 0, min(len(argv), MAXRECORDEDARGV))
+	sum := sha256.New()
+	for i := 0; i < len(argv); i++ {
+		arg := argv[i]
+		if redact && arg == "--" {
+			redact = false
+		} else if redact {
+			if opt := lookupOption(arg); opt != nil && opt.Sensitive && opt.Value == RequiredValue && i+1 < len(argv) {
+				kept = keepArg(kept, sum, arg)
+				arg = REDACTED
+				i++
+			} else if name, _, ok := strings.Cut(arg, "="); ok {
+				if opt := lookupOption(name); opt != nil && opt.Sensitive {
+					arg = name + "=" + REDACTED
+				}
+			}
+		}
+		kept = keepArg(kept, sum, arg)
+	}
+	if len(argv) <= MAXRECORDEDARGV {
+		return kept, 0, ""
+	}
+	return kept, len(argv), hex.EncodeToString(sum.Sum(nil))
+}
+
+// keepArg adds arg to the hash, and to kept while it is short enough
+func keepArg(kept []string, sum io.Writer, arg string) []string {
+	sum.Write([]byte(arg))
+	sum.Write([]byte{0})
+	if len(kept) < MAXRECORDEDARGV {
+		kept = append(kept, arg)
+	}
+	return kept
+}
+
+// Refused reports whether the journal was a symlink srm wouldn't write
+// through. Someone may have planted it to hide what the run did, so the
+// run says so with its exit status.
+func (j *Journal) Refused() bool {
+	return j.refused
+}
+
+// Op is the operation ID shared by every record of this invocation
+func (j *Journal) Op() string {
+	return j.op
+}
+
+// Record journals the outcome of one operand
+func (j *Journal) Record(result remove.Result) {
+	source := result.Source
+	if abs, err := filepath.Abs(source); err == nil {
+		source = abs
+	}
+
+	record := JournalRecord{
+		Kind:   "file",
+		Action: result.Action,
+		Status: result.Status(),
+		Source: source,
+		Dest:   result.Dest,
+		Bytes:  result.Bytes,
+	}
+	if result.Err != nil {
+		record.Error = result.Err.Error()
+	}
+	j.write(record)
+}
+
+// Close writes the end record
+func (j *Journal) Close() {
+	if j.f == nil {
+		return
+	}
+	j.write(JournalRecord{Kind: "end", Duration: time.Since(j.start)})
+	j.f.Close()
+	j.f = nil
+}
+
+func (j *Journal) write(record JournalRecord) {
+	if j.f == nil {
+		return
+	}
+	record.Op = j.op
+	record.Schema = remove.SCHEMAVERSION
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	if _, err := j.f.Write(append(line, '\n')); err != nil {
+		fmt.Fprintf(os.Stderr, "srm: journal: %s\n", err)
+	}
+}
+
+// journalFiles lists the journal and its rotated generations, oldest first
+func journalFiles() ([]string, error) {
+	path, err := journalPath()
+	if err != nil {
+		return nil, err
+	}
+
+	rotated, _ := filepath.Glob(path + ".*")
+	generation := func(name string) int {
+		n, err := strconv.Atoi(strings.TrimPrefix(name, path+"."))
+		if err != nil {
+			return -1
+		}
+		return n
+	}
+	files := []string{}
+	for _, name := range rotated {
+		if generation(name) > 0 {
+			files = append(files, name)
+		}
+	}
+
+	// journal.3 is older than journal.2 which is older than journal
+	sort.Slice(files, func(i, j int) bool {
+		return generation(files[i]) > generation(files[j])
+	})
+	return append(files, path), nil
+}
+
+// readJournal calls fn with every record in every journal generation, oldest
+// first. Lines that don't parse are skipped.
+func readJournal(fn func(JournalRecord)) error {
+	files, err := journalFiles()
+	if err != nil {
+		return err
+	}
+	return readJournalFiles(files, fn)
+}
+
+// readJournalFiles is readJournal for just the generations in files
+func readJournalFiles(files []string, fn func(JournalRecord)) error {
+	for _, name := range files {
+		f, err := os.Open(name)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			var record JournalRecord
+			if json.Unmarshal(scanner.Bytes(), &record) == nil && record.Op != "" {
+				fn(record)
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
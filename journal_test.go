@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+func TestRecordedArgv(t *testing.T) {
+	tests := []struct {
+		name   string
+		argv   []string
+		redact bool
+		want   []string
+	}{
+		{"--reason and its value",
+			[]string{"srm", "--reason", "secret", "x"}, true,
+			[]string{"srm", "--reason", REDACTED, "x"}},
+		{"--reason=value",
+			[]string{"srm", "--reason=secret", "x"}, true,
+			[]string{"srm", "--reason=" + REDACTED, "x"}},
+		{"an empty --reason=",
+			[]string{"srm", "--reason=", "x"}, true,
+			[]string{"srm", "--reason=" + REDACTED, "x"}},
+		{"after --",
+			[]string{"srm", "--reason", "secret", "--", "--reason", "kept", "--reason=kept"}, true,
+			[]string{"srm", "--reason", REDACTED, "--", "--reason", "kept", "--reason=kept"}},
+		{"--reason with nothing after it",
+			[]string{"srm", "x", "--reason"}, true,
+			[]string{"srm", "x", "--reason"}},
+		{"options that aren't sensitive",
+			[]string{"srm", "-rf", "--trash-dir", "/t", "--format=json"}, true,
+			[]string{"srm", "-rf", "--trash-dir", "/t", "--format=json"}},
+		{"without redact_argv",
+			[]string{"srm", "--reason", "secret", "--reason=secret"}, false,
+			[]string{"srm", "--reason", "secret", "--reason=secret"}},
+	}
+	for _, tt := range tests {
+		kept, argc, hash := recordedArgv(tt.argv, tt.redact)
+		if !slices.Equal(kept, tt.want) || argc != 0 || hash != "" {
+			t.Errorf("%s: recorded %q, %d, %q, want %q whole", tt.name, kept, argc, hash, tt.want)
+		}
+	}
+}
+
+// A command line past MAXRECORDEDARGV is cut short, with a count and a hash
+// of all of it
+func TestRecordedArgvCut(t *testing.T) {
+	argv := func(n int) []string {
+		args := []string{"srm", "-rf"}
+		for i := 0; len(args) < n; i++ {
+			args = append(args, fmt.Sprintf("file%d", i))
+		}
+		return args
+	}
+	if kept, argc, _ := recordedArgv(argv(MAXRECORDEDARGV), false); len(kept) != MAXRECORDEDARGV || argc != 0 {
+		t.Errorf("%d arguments: kept %d, argc %d", MAXRECORDEDARGV, len(kept), argc)
+	}
+
+	long := argv(100000)
+	kept, argc, hash := recordedArgv(long, false)
+	if !slices.Equal(kept, long[:MAXRECORDEDARGV]) || argc != len(long) || len(hash) != 64 {
+		t.Errorf("kept %d of %d arguments and hash %q", len(kept), argc, hash)
+	}
+	if _, _, again := recordedArgv(long, false); again != hash {
+		t.Errorf("hashed %q, then %q", hash, again)
+	}
+	long[len(long)-1] = "changed"
+	if _, _, other := recordedArgv(long, false); other == hash {
+		t.Errorf("the hash doesn't cover the arguments left out")
+	}
+
+	// redacting changes the hash, so a secret past the cut isn't in it
+	secret := append(argv(MAXRECORDEDARGV+1), "--reason", "secret")
+	_, _, redacted := recordedArgv(secret, true)
+	secret[len(secret)-1] = "other"
+	if _, _, other := recordedArgv(secret, true); other != redacted {
+		t.Errorf("the hash of a redacted command line covers the value it left out")
+	}
+}
+
+// A journal that is a symlink isn't written through, and a removal that
+// couldn't journal for that reason still goes ahead but exits 1
+func TestJournalSymlink(t *testing.T) {
+	if operand, ok := os.LookupEnv("SRM_TEST_JOURNALSYMLINK"); ok {
+		os.Args = []string{"srm", operand}
+		main()
+		os.Exit(0)
+	}
+
+	dir := dataEnv(t)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(t.TempDir(), "target")
+	if err := os.WriteFile(target, []byte("not srm's\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, filepath.Join(dir, "journal")); err != nil {
+		t.Skip("no symlinks here:", err)
+	}
+
+	j := openJournal("op", nil)
+	j.Record(remove.Result{Action: "trashed", Source: "f"})
+	j.Close()
+	if !j.Refused() {
+		t.Error("openJournal didn't refuse the symlink")
+	}
+
+	operand := filepath.Join(t.TempDir(), "f")
+	if err := os.WriteFile(operand, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestJournalSymlink$")
+	cmd.Env = append(os.Environ(), "SRM_TEST_JOURNALSYMLINK="+operand, remove.TRASHDIRENV+"="+t.TempDir())
+	out, err := cmd.CombinedOutput()
+	if exit, ok := err.(*exec.ExitError); !ok || exit.ExitCode() != 1 {
+		t.Errorf("srm with a symlinked journal exited with %v, want status 1", err)
+	}
+	if !strings.Contains(string(out), "refusing to write through it") {
+		t.Errorf("srm with a symlinked journal said %q", out)
+	}
+	if _, err := os.Lstat(operand); err == nil {
+		t.Error("the operand wasn't removed")
+	}
+	if data, _ := os.ReadFile(target); string(data) != "not srm's\n" {
+		t.Errorf("the symlink's target was written: %q", data)
+	}
+}
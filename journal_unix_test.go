@@ -0,0 +1,30 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// The data dir and the journal are owner-only whatever the umask
+func TestJournalModes(t *testing.T) {
+	for _, umask := range []int{0, 022, 0377} {
+		dir := dataEnv(t)
+		old := syscall.Umask(umask)
+		journalRun("op")
+		syscall.Umask(old)
+
+		for path, want := range map[string]os.FileMode{dir: 0700, filepath.Join(dir, "journal"): 0600} {
+			fi, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fi.Mode().Perm(); got != want {
+				t.Errorf("under umask %03o %s is %v, want %v", umask, filepath.Base(path), got, want)
+			}
+		}
+	}
+}
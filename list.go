@@ -0,0 +1,347 @@
+package main
+
+import (
+	"archive/tar"
+	"container/heap"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shanahanjrs/srm/remove"
+	"github.com/shanahanjrs/srm/trashquery"
+)
+
+// listCommand
+// srm list [--format=TEMPLATE | --columns=COLS] [--tree] [--when WHEN] [--sort KEY] [--limit N] [pattern ...]
+// prints one line per entry in the trash, optionally filtered by glob patterns
+// and, for entries srm knows the deletion time of, --when.
+// --tree also prints what is inside directories and archives.
+// --sort orders by name (the default), deleted (newest first) or size
+// (largest first) and --limit stops after N entries. Memory stays a few
+// dozen bytes per entry, and with --limit only N entries are held at all.
+func listCommand(args []string) {
+	flags, patterns := parseArgs(args)
+	tree := remove.In("--tree", flags)
+
+	spec, ok := remove.FlagValue("--format", flags)
+	if columns, hasColumns := remove.FlagValue("--columns", flags); hasColumns {
+		if ok {
+			fmt.Fprintln(os.Stderr, "srm: --format and --columns can't be combined")
+			os.Exit(1)
+		}
+		var err error
+		if spec, err = columnsTemplate(columns); err != nil {
+			fmt.Fprintf(os.Stderr, "srm: invalid --columns: %s\n", err)
+			os.Exit(1)
+		}
+	} else if !ok {
+		spec = "{{display .Name}}{{with .Label}}  [on {{.}}{{if $.Offline}}, offline{{end}}]{{end}}"
+	}
+	formatter, err := NewFormatter("list", spec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm: invalid --format: %s\n", err)
+		os.Exit(1)
+	}
+
+	var when *WhenRange
+	if expr, ok := remove.FlagValue("--when", flags); ok {
+		r, err := parseWhen(expr, time.Now())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "srm: %s\n", err)
+			os.Exit(1)
+		}
+		when = &r
+	}
+
+	sortBy, _ := remove.FlagValue("--sort", flags)
+	if sortBy == "" {
+		sortBy = "name"
+	}
+	before, ok := listSorts[sortBy]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "srm: invalid --sort %q, expected name, deleted or size\n", sortBy)
+		os.Exit(1)
+	}
+	limit := 0
+	if value, ok := remove.FlagValue("--limit", flags); ok {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			fmt.Fprintf(os.Stderr, "srm: invalid --limit %q, expected a positive count\n", value)
+			os.Exit(1)
+		}
+	}
+
+	targetDir, err := remove.FindTrashDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm: %s\n", err)
+		os.Exit(1)
+	}
+
+	// the trash, then those on removable drives, which desktops keep their
+	// own trashes on; the home trash has no Label
+	index, indexErr := trashquery.OpenDefault()
+	trashes := []RemovableTrash{{Dir: targetDir}}
+	if mounts, err := remove.LoadMounts(); err == nil && os.Getuid() >= 0 {
+		locations := []string{}
+		if indexErr == nil {
+			locations, _ = index.Locations()
+		}
+		for _, trash := range removableTrashes(mounts, locations, os.Getuid(), currentUsername()) {
+			if trash.Dir != targetDir {
+				trashes = append(trashes, trash)
+			}
+		}
+	}
+	trashOf := map[string]int{}
+	for t, trash := range trashes {
+		trashOf[trash.Dir] = t
+	}
+
+	// what srm itself knows about the payloads, as the offset of each name's
+	// index row, so a huge index costs a few bytes per entry rather than
+	// every entry in full
+	type nameRef struct {
+		hash   uint64
+		offset int64
+	}
+	known := []nameRef{}
+	if indexErr == nil {
+		for entry, err := range index.ListEntries(trashquery.Filter{}) {
+			if err != nil {
+				break
+			}
+			if _, ok := trashOf[entry.Location]; ok {
+				known = append(known, nameRef{remove.HashString(entry.Payload()), entry.Offset})
+			}
+		}
+	}
+	// later rows for the same name win, as they would in a map
+	sort.SliceStable(known, func(i, j int) bool { return known[i].hash < known[j].hash })
+	lookup := func(t int, name string) (trashquery.Entry, bool) {
+		payload := filepath.Join(trashes[t].Dir, name)
+		hash := remove.HashString(payload)
+		i := sort.Search(len(known), func(i int) bool { return known[i].hash > hash }) - 1
+		if i < 0 || known[i].hash != hash {
+			return trashquery.Entry{}, false
+		}
+		entry, err := index.At(known[i].offset)
+		return entry, err == nil && entry.Payload() == payload
+	}
+
+	// first pass: just the names to print and what they sort by, keeping
+	// only the best --limit of them when there is a limit
+	top := &listTop{before: before, limit: limit}
+	offer := func(t int, name string, info func() (os.FileInfo, error)) {
+		if len(patterns) > 0 && !matchAny(name, patterns) {
+			return
+		}
+
+		key := listKey{trash: t, name: name}
+		if when != nil || sortBy == "deleted" {
+			indexed, isKnown := lookup(t, name)
+			if when != nil && (!isKnown || !when.Contains(indexed.Deleted)) {
+				return
+			}
+			if isKnown {
+				key.value = indexed.Deleted.UnixNano()
+			} else if deleted, ok := remove.TrashInfoDate(filepath.Join(trashes[t].Dir, name)); ok {
+				key.value = deleted.UnixNano()
+			} else if fi, err := info(); err == nil {
+				key.value = fi.ModTime().UnixNano()
+			}
+		}
+		if sortBy == "size" {
+			if trashes[t].Offline {
+				indexed, _ := lookup(t, name)
+				key.value = indexed.Size
+			} else {
+				key.value, _ = remove.DiskUsage(remove.OSFS{}, filepath.Join(trashes[t].Dir, name))
+			}
+		}
+		top.Offer(key)
+	}
+	for t, trash := range trashes {
+		if trash.Offline {
+			// the index is all there is of a drive that isn't mounted
+			seen := map[string]bool{}
+			for entry, err := range index.ListEntries(trashquery.Filter{Location: trash.Dir}) {
+				if err != nil {
+					break
+				}
+				if !seen[entry.Name] {
+					seen[entry.Name] = true
+					offer(t, entry.Name, func() (os.FileInfo, error) { return nil, os.ErrNotExist })
+				}
+			}
+			continue
+		}
+		err = remove.ForEachDirEntry(trash.Dir, func(de os.DirEntry) bool {
+			offer(t, de.Name(), de.Info)
+			return true
+		})
+		if err != nil && t == 0 {
+			fmt.Fprintf(os.Stderr, "srm: %s\n", err)
+			os.Exit(1)
+		}
+		if err != nil {
+			// a drive pulled out mid-listing has nothing more to show
+			fmt.Fprintf(os.Stderr, "srm: warning: %s: %s\n", trash.Label, err)
+		}
+	}
+
+	// second pass: one full row at a time
+	keys := top.Sorted()
+	if len(keys) == 0 {
+		if len(patterns) > 0 {
+			fmt.Fprintf(os.Stderr, "srm list: nothing in %s matches\n", remove.DisplayPath(targetDir))
+		} else {
+			fmt.Fprintf(os.Stderr, "srm list: %s is empty\n", remove.DisplayPath(targetDir))
+		}
+		return
+	}
+	stopPager := startPager()
+	defer stopPager()
+	for _, key := range keys {
+		trash := trashes[key.trash]
+		dest := filepath.Join(trash.Dir, key.name)
+		entry := Entry{
+			Name:    key.name,
+			Dest:    dest,
+			Action:  "trashed",
+			Label:   trash.Label,
+			Offline: trash.Offline,
+		}
+		indexed, isKnown := lookup(key.trash, key.name)
+		if trash.Offline {
+			entry.Path, entry.Size, entry.IsDir = indexed.Origin, indexed.Size, indexed.IsDir
+			entry.Reason, entry.Deleted = indexed.Reason, indexed.Deleted
+			formatter.Write(os.Stdout, entry.withBase64())
+			continue
+		}
+
+		fi, err := os.Lstat(dest)
+		if err != nil {
+			// gone since the first pass
+			continue
+		}
+		entry.Size, entry.IsDir = key.value, fi.IsDir()
+		if sortBy != "size" {
+			if entry.Size, err = remove.DiskUsage(remove.OSFS{}, dest); err != nil {
+				fmt.Fprintf(os.Stderr, "srm: %s\n", err)
+			}
+		}
+		if isKnown {
+			entry.Path = indexed.Origin
+			entry.IsDir = indexed.IsDir
+			entry.Reason = indexed.Reason
+			entry.Deleted = indexed.Deleted
+		} else {
+			entry.Deleted, _ = remove.TrashInfoDate(dest)
+		}
+		formatter.Write(os.Stdout, entry.withBase64())
+
+		if !tree {
+			continue
+		}
+		if isKnown && indexed.Archive != "" {
+			err = remove.ArchiveMembers(remove.OSFS{}, dest, func(hdr *tar.Header) {
+				fmt.Println("    " + remove.DisplayName(strings.TrimSuffix(hdr.Name, "/")))
+			})
+		} else if fi.IsDir() {
+			err = filepath.WalkDir(dest, func(path string, d os.DirEntry, err error) error {
+				if err == nil && path != dest {
+					rel, _ := filepath.Rel(trash.Dir, path)
+					fmt.Println("    " + remove.DisplayName(rel))
+				}
+				return err
+			})
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "srm: %s\n", err)
+		}
+	}
+}
+
+// listKey is all srm list holds per entry until it prints: which of its
+// trashes the entry is in, the name and the deletion time or size it sorts by
+type listKey struct {
+	trash int
+	name  string
+	value int64
+}
+
+// listSorts are the --sort orders, each saying whether a comes before b.
+// Ties go by name, then trash, so output is stable.
+var listSorts = map[string]func(a, b listKey) bool{
+	"name": byListName,
+	"deleted": func(a, b listKey) bool {
+		if a.value != b.value {
+			return a.value > b.value
+		}
+		return byListName(a, b)
+	},
+	"size": func(a, b listKey) bool {
+		if a.value != b.value {
+			return a.value > b.value
+		}
+		return byListName(a, b)
+	},
+}
+
+func byListName(a, b listKey) bool {
+	if a.name != b.name {
+		return a.name < b.name
+	}
+	return a.trash < b.trash
+}
+
+// listTop collects keys, keeping only the first limit of them in before
+// order when limit is set. It is a heap with the last kept key on top, so
+// each key costs O(log limit) and memory never exceeds limit keys.
+type listTop struct {
+	keys   []listKey
+	before func(a, b listKey) bool
+	limit  int
+}
+
+func (t *listTop) Len() int           { return len(t.keys) }
+func (t *listTop) Less(i, j int) bool { return t.before(t.keys[j], t.keys[i]) }
+func (t *listTop) Swap(i, j int)      { t.keys[i], t.keys[j] = t.keys[j], t.keys[i] }
+func (t *listTop) Push(x interface{}) { t.keys = append(t.keys, x.(listKey)) }
+func (t *listTop) Pop() interface{} {
+	last := t.keys[len(t.keys)-1]
+	t.keys = t.keys[:len(t.keys)-1]
+	return last
+}
+
+// Offer adds key if it is among the first limit seen so far
+func (t *listTop) Offer(key listKey) {
+	switch {
+	case t.limit <= 0:
+		t.keys = append(t.keys, key)
+	case len(t.keys) < t.limit:
+		heap.Push(t, key)
+	case t.before(key, t.keys[0]):
+		t.keys[0] = key
+		heap.Fix(t, 0)
+	}
+}
+
+// Sorted returns the kept keys in before order
+func (t *listTop) Sorted() []listKey {
+	sort.Slice(t.keys, func(i, j int) bool { return t.before(t.keys[i], t.keys[j]) })
+	return t.keys
+}
+
+// matchAny reports whether name matches at least one of the glob patterns
+func matchAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// srm list --limit keeps no more keys than the limit whatever it is offered
+func TestListTopBounded(t *testing.T) {
+	for _, limit := range []int{1, 20, 1000} {
+		top := &listTop{before: listSorts["size"], limit: limit}
+		for i := 0; i < 100000; i++ {
+			top.Offer(listKey{name: fmt.Sprint(i), value: int64(i * 7919 % 100003)})
+			if len(top.keys) > limit {
+				t.Fatalf("limit %d: holding %d keys", limit, len(top.keys))
+			}
+		}
+		keys := top.Sorted()
+		if len(keys) != limit {
+			t.Fatalf("limit %d: kept %d keys", limit, len(keys))
+		}
+		for i := 1; i < len(keys); i++ {
+			if keys[i].value > keys[i-1].value {
+				t.Fatalf("limit %d: %v comes before %v", limit, keys[i-1], keys[i])
+			}
+		}
+		// the biggest value any i < 100000 gets is the top one
+		if keys[0].value != 100002 {
+			t.Errorf("limit %d: the biggest kept is %d", limit, keys[0].value)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"text/template"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// maintenanceTask is one step of srm maintain. run reports what it did in a
+// few words.
+type maintenanceTask struct {
+	name string
+	run  func(index *remove.Index, journal *Journal) (string, error)
+}
+
+// maintenanceTasks run in this order
+var maintenanceTasks = []maintenanceTask{
+	{"evict", evictCaps},
+	{"intents", replayIntents},
+	{"gc", gcIndex},
+	{"compact", compactIndex},
+	{"stats", updateStats},
+	{"rotate", rotateAux},
+}
+
+// replayIntents settles moves into the trash that an interrupted srm logged
+// but never indexed, then empties the intent log
+func replayIntents(index *remove.Index, journal *Journal) (string, error) {
+	trashDir, err := remove.FindTrashDir()
+	if err != nil {
+		return "", err
+	}
+	intents, err := remove.OpenIntentLog(trashDir)
+	if err != nil {
+		return "", err
+	}
+	finished, dropped, err := intents.Replay(remove.OSFS{}, index)
+	if err != nil {
+		return "", err
+	}
+	if err := intents.Compact(); err != nil {
+		return "", err
+	}
+	if len(finished) == 0 && len(dropped) == 0 {
+		return "nothing interrupted", nil
+	}
+	return fmt.Sprintf("indexed %d interrupted moves, dropped %d that never happened", len(finished), len(dropped)), nil
+}
+
+// gcCommand
+// srm gc
+// replays the intent log and forgets index entries whose payload is gone
+func gcCommand(args []string) {
+	_, rest := parseArgs(args)
+	if len(rest) > 0 {
+		fmt.Fprintf(os.Stderr, "srm gc: unexpected argument %s\n", rest[0])
+		os.Exit(1)
+	}
+
+	index, err := remove.OpenIndex()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm gc: %s\n", err)
+		os.Exit(1)
+	}
+	for _, task := range []maintenanceTask{{"intents", replayIntents}, {"gc", gcIndex}} {
+		summary, err := task.run(index, nil)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "srm gc: %s: %s\n", task.name, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%-8s %s\n", task.name, summary)
+	}
+}
+
+// gcIndex forgets index rows whose payload has disappeared from the trash
+func gcIndex(index *remove.Index, journal *Journal) (string, error) {
+	entries, err := index.Entries()
+	if err != nil {
+		return "", err
+	}
+
+	missing := []remove.IndexEntry{}
+	for _, entry := range entries {
+		if _, err := os.Lstat(entry.Payload()); errors.Is(err, fs.ErrNotExist) {
+			missing = append(missing, entry)
+		}
+	}
+	if len(missing) == 0 {
+		return "nothing to collect", nil
+	}
+	if err := index.Forget(missing...); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("forgot %d entries with missing payloads", len(missing)), nil
+}
+
+// compactIndex folds the index's segments into it and rewrites it without
+// Gone rows
+func compactIndex(index *remove.Index, journal *Journal) (string, error) {
+	// reading it first sets aside an index that doesn't parse
+	if _, err := index.Entries(); err != nil {
+		return "", err
+	}
+	before := index.Size()
+	live, err := index.Compact()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d live entries, %s down to %s", live, remove.FormatSize(before), remove.FormatSize(index.Size())), nil
+}
+
+// maintainCommand
+// srm maintain [--install-timer | --uninstall]
+func maintainCommand(args []string) {
+	flags, rest := parseArgs(args)
+	if len(rest) > 0 {
+		fmt.Fprintf(os.Stderr, "srm maintain: unexpected argument %s\n", rest[0])
+		os.Exit(1)
+	}
+
+	switch {
+	case remove.In("--install-timer", flags):
+		if err := installTimer(); err != nil {
+			fmt.Fprintf(os.Stderr, "srm maintain: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	case remove.In("--uninstall", flags):
+		if err := uninstallTimer(); err != nil {
+			fmt.Fprintf(os.Stderr, "srm maintain: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	index, err := remove.OpenIndex()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm maintain: %s\n", err)
+		os.Exit(1)
+	}
+
+	journal := openJournal(newOpID(), os.Args)
+	defer journal.Close()
+
+	failed := false
+	for _, task := range maintenanceTasks {
+		summary, err := task.run(index, journal)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "srm maintain: %s: %s\n", task.name, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("%-8s %s\n", task.name, summary)
+	}
+	if failed || journal.Refused() {
+		journal.Close()
+		os.Exit(1)
+	}
+}
+
+// unit files for running srm maintain once a day
+var (
+	systemdService = template.Must(template.New("service").Parse(`[Unit]
+Description=srm trash maintenance
+
+[Service]
+Type=oneshot
+ExecStart={{.Exe}} maintain
+`))
+
+	systemdTimer = template.Must(template.New("timer").Parse(`[Unit]
+Description=Daily srm trash maintenance
+
+[Timer]
+OnCalendar=daily
+Persistent=true
+RandomizedDelaySec=1h
+
+[Install]
+WantedBy=timers.target
+`))
+
+	launchdPlist = template.Must(template.New("plist").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>{{.Label}}</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>{{.Exe}}</string>
+		<string>maintain</string>
+	</array>
+	<key>StartCalendarInterval</key>
+	<dict>
+		<key>Hour</key>
+		<integer>3</integer>
+		<key>Minute</key>
+		<integer>0</integer>
+	</dict>
+</dict>
+</plist>
+`))
+)
+
+const LAUNCHDLABEL = "com.github.shanahanjrs.srm.maintain"
+
+// timerFiles returns the unit files for this platform, keyed by the path they
+// are installed at
+func timerFiles() (map[string]*template.Template, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		return map[string]*template.Template{
+			filepath.Join(homeDir, "Library", "LaunchAgents", LAUNCHDLABEL+".plist"): launchdPlist,
+		}, nil
+	case "linux":
+		configDir := filepath.Join(homeDir, ".config")
+		if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+			configDir = xdg
+		}
+		unitDir := filepath.Join(configDir, "systemd", "user")
+		return map[string]*template.Template{
+			filepath.Join(unitDir, "srm-maintain.service"): systemdService,
+			filepath.Join(unitDir, "srm-maintain.timer"):   systemdTimer,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("scheduled maintenance is not supported on %s, run srm maintain from cron instead", runtime.GOOS)
+}
+
+func installTimer() error {
+	files, err := timerFiles()
+	if err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	for path, tmpl := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+		err = tmpl.Execute(f, map[string]string{"Exe": exe, "Label": LAUNCHDLABEL})
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("wrote %s\n", path)
+	}
+
+	if runtime.GOOS == "darwin" {
+		for path := range files {
+			return run("launchctl", "load", "-w", path)
+		}
+	}
+	if err := run("systemctl", "--user", "daemon-reload"); err != nil {
+		return err
+	}
+	return run("systemctl", "--user", "enable", "--now", "srm-maintain.timer")
+}
+
+func uninstallTimer() error {
+	files, err := timerFiles()
+	if err != nil {
+		return err
+	}
+
+	if runtime.GOOS == "darwin" {
+		for path := range files {
+			run("launchctl", "unload", "-w", path)
+		}
+	} else {
+		run("systemctl", "--user", "disable", "--now", "srm-maintain.timer")
+	}
+
+	for path := range files {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		fmt.Printf("removed %s\n", path)
+	}
+
+	if runtime.GOOS == "linux" {
+		run("systemctl", "--user", "daemon-reload")
+	}
+	return nil
+}
+
+// timerStatus describes whether scheduled maintenance is set up, for doctor
+func timerStatus() string {
+	files, err := timerFiles()
+	if err != nil {
+		return err.Error()
+	}
+	for path := range files {
+		if _, err := os.Stat(path); err != nil {
+			return "not configured (srm maintain --install-timer)"
+		}
+	}
+
+	if runtime.GOOS == "linux" {
+		if exec.Command("systemctl", "--user", "is-enabled", "--quiet", "srm-maintain.timer").Run() != nil {
+			return "unit files installed but the timer is not enabled"
+		}
+		return "daily (systemd user timer)"
+	}
+	return "daily (launchd agent)"
+}
+
+// run runs a command, passing its output through
+func run(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
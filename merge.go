@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// MERGEPOLICIES are what --merge takes: restore what is only in the trash
+// and leave the rest in it (missing), or also settle what conflicts in
+// favour of the trash's (trash) or what is on disk (disk), or ask about each
+// conflict (review)
+var MERGEPOLICIES = []string{"missing", "trash", "disk", "review"}
+
+// MergeItem is one path of an entry being restored over what is at its
+// origin again, relative to both. Directories on both sides are walked
+// rather than listed; one on a side only is a single item.
+type MergeItem struct {
+	Path string `json:"path"`
+	// Status is only-in-trash, only-on-disk, identical or conflicting
+	Status string `json:"status"`
+	IsDir  bool   `json:"dir,omitempty"`
+	Size   int64  `json:"size"`
+}
+
+// MergeAnalysis is what restoring an entry over its origin would find
+type MergeAnalysis struct {
+	Entry   string         `json:"entry,omitempty"`
+	Payload string         `json:"payload"`
+	Dest    string         `json:"dest"`
+	Counts  map[string]int `json:"counts"`
+	Items   []MergeItem    `json:"items"`
+}
+
+// analyzeMerge walks payload and dest side by side. Identical means the
+// same type, size and, for files of the same size, payloadChecksum, the
+// hash srm export and srm which compare payloads by, so files of different
+// sizes are never read. An archive entry is one item, its tarball not being
+// a tree to compare.
+func analyzeMerge(fsys remove.FS, target remove.RestoreTarget, dest string) (MergeAnalysis, error) {
+	a := MergeAnalysis{Entry: target.Entry.ID, Payload: target.Entry.Payload(), Dest: dest, Counts: map[string]int{}}
+	add := func(rel string, status string, fi fs.FileInfo, root string) {
+		size, _ := remove.DiskUsage(fsys, filepath.Join(root, filepath.FromSlash(rel)))
+		a.Items = append(a.Items, MergeItem{Path: rel, Status: status, IsDir: fi.IsDir(), Size: size})
+		a.Counts[status]++
+	}
+
+	var walk func(rel string) error
+	walk = func(rel string) error {
+		trashed := filepath.Join(a.Payload, filepath.FromSlash(rel))
+		onDisk := filepath.Join(dest, filepath.FromSlash(rel))
+		tfi, err := fsys.Lstat(trashed)
+		if err != nil {
+			if rel == "." {
+				return err
+			}
+			dfi, err := fsys.Lstat(onDisk)
+			if err != nil {
+				return err
+			}
+			add(rel, "only-on-disk", dfi, dest)
+			return nil
+		}
+		dfi, err := fsys.Lstat(onDisk)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			add(rel, "only-in-trash", tfi, a.Payload)
+			return nil
+		case err != nil:
+			return err
+		case target.Entry.Archive != "":
+			add(rel, "conflicting", tfi, a.Payload)
+			return nil
+		case !tfi.IsDir() || !dfi.IsDir():
+			same, err := sameContents(fsys, trashed, tfi, onDisk, dfi)
+			if err != nil {
+				return err
+			}
+			if same {
+				add(rel, "identical", tfi, a.Payload)
+			} else {
+				add(rel, "conflicting", tfi, a.Payload)
+			}
+			return nil
+		}
+
+		names := map[string]bool{}
+		for _, dir := range []string{trashed, onDisk} {
+			children, err := fsys.ReadDir(dir)
+			if err != nil {
+				return err
+			}
+			for _, child := range children {
+				names[child.Name()] = true
+			}
+		}
+		sorted := []string{}
+		for name := range names {
+			sorted = append(sorted, name)
+		}
+		sort.Strings(sorted)
+		for _, name := range sorted {
+			if err := walk(path.Join(rel, name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return a, walk(".")
+}
+
+// sameContents is whether a and b, neither both directories, hold the same
+func sameContents(fsys remove.FS, a string, afi fs.FileInfo, b string, bfi fs.FileInfo) (bool, error) {
+	if typeChar(afi.Mode()) != typeChar(bfi.Mode()) {
+		return false, nil
+	}
+	switch {
+	case afi.Mode().IsRegular():
+		if afi.Size() != bfi.Size() {
+			return false, nil
+		}
+	case afi.Mode()&fs.ModeSymlink != 0:
+	default:
+		// fifos and the like have no contents to differ in
+		return true, nil
+	}
+	aSum, err := payloadChecksum(fsys, a)
+	if err != nil {
+		return false, err
+	}
+	bSum, err := payloadChecksum(fsys, b)
+	return aSum == bSum, err
+}
+
+// Summary is the line shown before asking how to merge
+func (a MergeAnalysis) Summary() string {
+	return fmt.Sprintf("%s is there again: %d only in the trash, %d only on disk, %d identical, %d conflicting",
+		remove.DisplayPath(a.Dest), a.Counts["only-in-trash"], a.Counts["only-on-disk"], a.Counts["identical"], a.Counts["conflicting"])
+}
+
+// askMergePolicy asks which of MERGEPOLICIES to merge by, "" for none
+func askMergePolicy(a MergeAnalysis) string {
+	fmt.Println(a.Summary())
+	msg := "restore what is missing only (m), preferring the trash's (t) or what is on disk (d) where they conflict, review each conflict (r), or leave it (n)? [m/t/d/r/N] "
+	if a.Counts["conflicting"] == 0 {
+		msg = "restore what is missing (m), or leave it (n)? [m/N] "
+	}
+	switch remove.GetUserAnswer(msg) {
+	case "m", "missing":
+		return "missing"
+	case "t", "trash":
+		return "trash"
+	case "d", "disk":
+		return "disk"
+	case "r", "review":
+		return "review"
+	}
+	return ""
+}
+
+// mergeEntry restores the entry of a over what is at its origin by policy.
+// What is only in the trash goes back; identical copies are dropped from
+// it; a conflict taken from the trash has what is on disk trashed with r
+// first, and one settled for the disk has the trash's copy dropped. The
+// entry is gone from the trash once it holds nothing but directories, and
+// stays, holding just them, while conflicts are left. Under dryRun it only
+// says what it would do.
+func mergeEntry(r *remove.Remover, fsys remove.FS, journal *Journal, a MergeAnalysis, policy string, dryRun bool) (restored int64, left int, err error) {
+	for _, item := range a.Items {
+		trashed := filepath.Join(a.Payload, filepath.FromSlash(item.Path))
+		onDisk := filepath.Join(a.Dest, filepath.FromSlash(item.Path))
+		take, drop := false, false
+		switch item.Status {
+		case "only-on-disk":
+			continue
+		case "only-in-trash":
+			take = true
+		case "identical":
+			drop = true
+		case "conflicting":
+			switch policy {
+			case "trash":
+				take = true
+			case "disk":
+				drop = true
+			case "review":
+				if dryRun {
+					fmt.Printf("would ask about %s\n", remove.DisplayName(remove.DisplayPath(onDisk)))
+					continue
+				}
+				answer := remove.GetUserAnswer(fmt.Sprintf("%s differs from the trash's: take the trash's (t), keep what is on disk (d), or leave both (n)? [t/d/N] ", remove.DisplayName(remove.DisplayPath(onDisk))))
+				take, drop = remove.In(answer, []string{"t", "trash"}), remove.In(answer, []string{"d", "disk"})
+			}
+		}
+
+		switch {
+		case dryRun && take && item.Status == "conflicting":
+			fmt.Printf("would trash %s and restore the trash's\n", remove.DisplayName(remove.DisplayPath(onDisk)))
+		case dryRun && take:
+			fmt.Printf("would restore %s\n", remove.DisplayName(remove.DisplayPath(onDisk)))
+		case dryRun && drop:
+			fmt.Printf("would drop the trash's copy of %s\n", remove.DisplayName(item.Path))
+		case dryRun:
+			fmt.Printf("would leave %s in the trash\n", remove.DisplayName(item.Path))
+		case take:
+			if item.Status == "conflicting" {
+				if r == nil {
+					return restored, left, fmt.Errorf("%s: %w to move it to", remove.DisplayPath(onDisk), remove.ErrTrashUnavailable)
+				}
+				result := r.Remove(onDisk)
+				journal.Record(result)
+				if result.Err != nil {
+					return restored, left, result.Err
+				}
+			}
+			if err := os.MkdirAll(filepath.Dir(onDisk), 0755); err != nil {
+				return restored, left, err
+			}
+			if err := remove.MoveBack(fsys, trashed, onDisk); err != nil {
+				return restored, left, err
+			}
+			restored += item.Size
+		case drop:
+			if err := fsys.RemoveAll(trashed); err != nil {
+				return restored, left, err
+			}
+		default:
+			left++
+		}
+	}
+	if dryRun || left > 0 {
+		return restored, left, nil
+	}
+
+	// what is left is the directories the disk has too
+	err = filepath.WalkDir(a.Payload, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			err = fmt.Errorf("%s: %w", remove.DisplayPath(path), remove.ErrDestExists)
+		}
+		return err
+	})
+	if err != nil {
+		return restored, left, err
+	}
+	return restored, 0, fsys.RemoveAll(a.Payload)
+}
+
+// printMergeAnalysis prints a as one JSON line, for --json
+func printMergeAnalysis(a MergeAnalysis) error {
+	line, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(line))
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// A directory trashed and made again at its origin is compared item by
+// item, and merging with the trash's copy winning trashes what it replaces
+func TestMergeEntry(t *testing.T) {
+	work, trash, index, r := scratchTrash(t)
+	tree := filepath.Join(work, "tree")
+	writeFiles(t, tree, map[string]string{"same": "same", "differs": "old", "gone": "gone", "sub/deep": "deep"})
+	if result := r.Remove(tree); result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	writeFiles(t, tree, map[string]string{"same": "same", "differs": "newer", "new": "new"})
+
+	entries, err := index.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, err := findRestoreTarget(entries, trash, tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	analysis, err := analyzeMerge(remove.OSFS{}, target, tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"differs": "conflicting", "gone": "only-in-trash", "new": "only-on-disk", "same": "identical", "sub": "only-in-trash"}
+	if len(analysis.Items) != len(want) {
+		t.Errorf("%d items, want %d: %+v", len(analysis.Items), len(want), analysis.Items)
+	}
+	for _, item := range analysis.Items {
+		if want[item.Path] != item.Status {
+			t.Errorf("%s: %s, want %s", item.Path, item.Status, want[item.Path])
+		}
+	}
+
+	if _, left, err := mergeEntry(r, remove.OSFS{}, &Journal{}, analysis, "trash", false); err != nil || left != 0 {
+		t.Fatalf("%d left in the trash, %v", left, err)
+	}
+	for name, content := range map[string]string{"same": "same", "differs": "old", "gone": "gone", "new": "new", "sub/deep": "deep"} {
+		if got, err := os.ReadFile(filepath.Join(tree, name)); err != nil || string(got) != content {
+			t.Errorf("%s: %q, %v, want %q", name, got, err, content)
+		}
+	}
+	if _, err := os.Lstat(target.Entry.Payload()); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("the merged entry is still in the trash")
+	}
+	// what it replaced is in the trash
+	if got, err := os.ReadFile(filepath.Join(trash, "differs")); err != nil || string(got) != "newer" {
+		t.Errorf("the replaced differs is %q in the trash, %v", got, err)
+	}
+}
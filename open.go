@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// openCommand
+// srm open [entry]
+// shows the trash, or the directory holding entry, in the file manager.
+// Exits 1 after printing the path when no file manager could be launched.
+func openCommand(args []string) {
+	_, queries := parseArgs(args)
+	if len(queries) > 1 {
+		fmt.Fprintln(os.Stderr, "srm open: takes at most one entry")
+		os.Exit(1)
+	}
+
+	targetDir, err := remove.FindTrashDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm open: %s\n", err)
+		os.Exit(1)
+	}
+
+	target, reveal := targetDir, false
+	if len(queries) == 1 {
+		target, err = entryPayload(targetDir, queries[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "srm open: %s\n", err)
+			os.Exit(1)
+		}
+		reveal = true
+	}
+
+	if err := launchFileManager(target, reveal); err != nil {
+		fmt.Println(target)
+		fmt.Fprintf(os.Stderr, "srm open: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// entryPayload finds the payload a query names, either through the index or
+// as a plain name in the trash
+func entryPayload(targetDir, query string) (string, error) {
+	if index, err := remove.OpenIndex(); err == nil {
+		entries, _ := index.Entries()
+		matches := resolveEntries(entries, query)
+		if len(matches) == 1 {
+			return matches[0].Payload(), nil
+		}
+		if len(matches) > 1 {
+			return "", fmt.Errorf("%s is ambiguous, use an entry ID", query)
+		}
+	}
+
+	path := filepath.Join(targetDir, filepath.Base(query))
+	if _, err := os.Lstat(path); err != nil {
+		return "", fmt.Errorf("%s: %w", query, remove.ErrNotFound)
+	}
+	return path, nil
+}
+
+// launchFileManager starts the platform's opener on path without waiting for
+// it. With reveal, path is selected inside its directory where the opener can
+// do that, otherwise its directory is opened.
+func launchFileManager(path string, reveal bool) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		if reveal {
+			cmd = exec.Command("open", "-R", path)
+		} else {
+			cmd = exec.Command("open", path)
+		}
+	case "windows":
+		if reveal {
+			cmd = exec.Command("explorer", "/select,"+path)
+		} else {
+			cmd = exec.Command("explorer", path)
+		}
+	default:
+		if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+			return errors.New("no display to open a file manager on")
+		}
+		if reveal {
+			path = filepath.Dir(path)
+		}
+		cmd = exec.Command("xdg-open", path)
+	}
+
+	if _, err := exec.LookPath(cmd.Args[0]); err != nil {
+		return fmt.Errorf("no file manager opener: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// safeModeEnabled reports whether srm is locked down, by SRM_SAFE=1 or by
+// safe_mode = true in the system or user config. None of them can be used to
+// turn safe mode off once another has turned it on.
+func safeModeEnabled() (bool, error) {
+	if env := os.Getenv("SRM_SAFE"); env != "" {
+		on, err := strconv.ParseBool(env)
+		if err != nil {
+			return false, fmt.Errorf("SRM_SAFE: expected true or false, got %q", env)
+		}
+		if on {
+			return true, nil
+		}
+	}
+
+	system, err := remove.ReadConfig(remove.SYSTEMCONFIG)
+	if err != nil {
+		return false, err
+	}
+	if on, err := system.Bool("safe_mode"); on || err != nil {
+		return on, err
+	}
+
+	settings, err := remove.LoadSettings()
+	if err != nil {
+		return false, err
+	}
+	return settings.Config.Bool("safe_mode")
+}
+
+// forceLevel counts -f: once is rm's -f, twice (-ff) is FORCEBYPASS, and
+// more is no more than that
+func forceLevel(flags []string) int {
+	level := 0
+	for _, flag := range flags {
+		if flag == "-f" {
+			level++
+		}
+	}
+	return min(level, remove.FORCEBYPASS)
+}
+
+// overwritePasses are the passes -P writes over a file: zeros once, and
+// random data before them for -PP. Without -P there are none.
+func overwritePasses(flags []string) []string {
+	passes := 0
+	for _, flag := range flags {
+		if flag == "-P" {
+			passes++
+		}
+	}
+	switch passes {
+	case 0:
+		return nil
+	case 1:
+		return []string{remove.SCRUBZEROS}
+	}
+	return []string{remove.SCRUBRANDOM, remove.SCRUBZEROS}
+}
+
+// resolveOptions
+// turns parsed flags plus the environment and config into Remover Options.
+// Every command goes through here so restrictions like safe mode apply
+// everywhere the same way.
+func resolveOptions(flags []string) (remove.Options, error) {
+	opts := remove.Options{
+		Force:           remove.In("-f", flags),
+		ForceLevel:      forceLevel(flags),
+		Interactive:     remove.In("-i", flags),
+		OnceInteractive: remove.In("-I", flags),
+		Recursive:       remove.In("-r", flags),
+		Dir:             remove.In("-d", flags),
+		OneFileSystem:   remove.In("-x", flags),
+		Verify:          remove.In("--verify", flags),
+		Delete:          remove.In("--permanent", flags) || remove.In("-P", flags),
+		Overwrite:       overwritePasses(flags),
+		NoPreserveRoot:  lastOf(flags, "--preserve-root", "--no-preserve-root") == "--no-preserve-root",
+	}
+
+	settings, err := remove.LoadSettings()
+	if err != nil {
+		return opts, err
+	}
+	opts.FSPolicies, err = fsPolicies(settings.Config)
+	if err != nil {
+		return opts, err
+	}
+	checkExec, err := settings.Config.Bool("check_exec")
+	if err != nil {
+		return opts, err
+	}
+	opts.CheckExec = checkExec || remove.In("--check-exec", flags)
+	opts.Fast = remove.In("--fast", flags)
+	if opts.CheckBudgets, err = remove.CheckBudgets(settings.Config); err != nil {
+		return opts, err
+	}
+
+	if opts.KeepHidden, err = remove.HiddenDepth("--keep-hidden", flags); err != nil {
+		return opts, err
+	}
+	if opts.HiddenOnly, err = remove.HiddenDepth("--hidden-only", flags); err != nil {
+		return opts, err
+	}
+	switch {
+	case opts.KeepHidden > 0 && opts.HiddenOnly > 0:
+		return opts, fmt.Errorf("--keep-hidden and --hidden-only can't be combined")
+	case (opts.KeepHidden > 0 || opts.HiddenOnly > 0) && !opts.Recursive:
+		return opts, fmt.Errorf("--keep-hidden and --hidden-only only apply with -r")
+	}
+
+	if opts.PreferTrash, err = preferTrash(flags, settings.Config); err != nil {
+		return opts, err
+	}
+	if opts.BackendName, err = backendName(flags, settings.Config); err != nil {
+		return opts, err
+	}
+	if _, err := settings.Config.Path("trash_dir"); err != nil {
+		return opts, err
+	}
+	if opts.Protected, err = protectedPaths(settings.Config); err != nil {
+		return opts, err
+	}
+	if opts.ConfirmOverSize, err = settings.Config.Size("confirm_over_size"); err != nil {
+		return opts, err
+	}
+	if opts.ConfirmSize, err = confirmSize(flags, settings.Config); err != nil {
+		return opts, err
+	}
+
+	if remove.POSIX {
+		// the last of -f and -i wins
+		opts.POSIX = true
+		switch lastOf(flags, "-f", "-i") {
+		case "-f":
+			opts.Interactive = false
+		case "-i":
+			opts.Force = false
+			opts.ForceLevel = 0
+		}
+		opts.PromptWriteProtected = !opts.Force && remove.IsTTY(os.Stdin)
+	}
+
+	safe, err := safeModeEnabled()
+	if err != nil {
+		return opts, err
+	}
+	if safe {
+		// always prompt, -f only keeps its meaning for files that aren't there
+		opts.SafeMode = true
+		opts.Interactive = true
+		if opts.NoPreserveRoot {
+			return opts, fmt.Errorf("--no-preserve-root: disabled by safe mode")
+		}
+		if len(opts.Overwrite) > 0 {
+			return opts, fmt.Errorf("-P: disabled by safe mode")
+		}
+		if opts.Delete {
+			return opts, fmt.Errorf("--permanent: disabled by safe mode")
+		}
+	}
+
+	return opts, nil
+}
+
+// protectedPaths reads protected = [...] from the config, the paths srm
+// refuses to remove, as CanonicalOperand has them
+func protectedPaths(config remove.Config) ([]string, error) {
+	paths, err := config.List("protected")
+	if err != nil {
+		return nil, err
+	}
+	protected := []string{}
+	for _, path := range paths {
+		abs, err := remove.Config{"protected": path}.Path("protected")
+		if err != nil {
+			return nil, err
+		}
+		protected = append(protected, remove.CanonicalOperand(abs))
+	}
+	return protected, nil
+}
+
+// preferTrash returns --prefer-trash, or prefer_trash from the config when
+// it isn't given, with directories made absolute
+func preferTrash(flags []string, config remove.Config) ([]string, error) {
+	prefer := remove.FlagValues("--prefer-trash", flags)
+	if len(prefer) == 0 {
+		var err error
+		if prefer, err = config.List("prefer_trash"); err != nil {
+			return nil, err
+		}
+	}
+	for i, value := range prefer {
+		if value == "home" || value == "volume" {
+			continue
+		}
+		if value == "" {
+			return nil, fmt.Errorf("invalid --prefer-trash: expected home, volume or a directory")
+		}
+		abs, err := filepath.Abs(value)
+		if err != nil {
+			return nil, err
+		}
+		prefer[i] = abs
+	}
+	return prefer, nil
+}
+
+// confirmSize returns --confirm-size, or confirm_size from the config when
+// it isn't given; 0 is off
+func confirmSize(flags []string, config remove.Config) (int64, error) {
+	value, ok := remove.FlagValue("--confirm-size", flags)
+	if !ok {
+		return config.Size("confirm_size")
+	}
+	size, err := remove.ParseSize(value)
+	if err != nil {
+		return 0, fmt.Errorf("--confirm-size: %w", err)
+	}
+	return size, nil
+}
+
+// resolveOnNoTrash returns the --on-no-trash policy, or on_no_trash from
+// the config when it isn't given, fail when neither is. Safe mode refuses
+// --on-no-trash=permanent, and takes the config's permanent as fail.
+func resolveOnNoTrash(flags []string, opts remove.Options) (string, error) {
+	onNoTrash, ok := remove.FlagValue("--on-no-trash", flags)
+	if !ok {
+		settings, err := remove.LoadSettings()
+		if err != nil {
+			return "", err
+		}
+		onNoTrash, ok = settings.Config["on_no_trash"]
+		if !ok {
+			return "fail", nil
+		}
+		if !remove.In(onNoTrash, remove.ONNOTRASH) {
+			return "", fmt.Errorf("on_no_trash: expected fail, permanent or tmp, got %q", onNoTrash)
+		}
+		if onNoTrash == "permanent" && opts.SafeMode {
+			return "fail", nil
+		}
+		return onNoTrash, nil
+	}
+	if !remove.In(onNoTrash, remove.ONNOTRASH) {
+		return "", fmt.Errorf("invalid --on-no-trash: %s (expected fail, permanent or tmp)", onNoTrash)
+	}
+	if onNoTrash == "permanent" && opts.SafeMode {
+		return "", fmt.Errorf("--on-no-trash=permanent: disabled by safe mode")
+	}
+	return onNoTrash, nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+func TestForceLevel(t *testing.T) {
+	tests := []struct {
+		flags []string
+		want  int
+	}{
+		{nil, 0},
+		{[]string{"-r"}, 0},
+		{[]string{"-f"}, 1},
+		{[]string{"-f", "-r", "-f"}, remove.FORCEBYPASS},
+		{[]string{"-f", "-f", "-f", "-f"}, remove.FORCEBYPASS},
+	}
+	for _, tt := range tests {
+		if got := forceLevel(tt.flags); got != tt.want {
+			t.Errorf("forceLevel(%q) = %d, want %d", tt.flags, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// Order contract: operands are processed, prompted for and reported in the
+// order they were given, or the --sort-operands order, one at a time. An
+// operand repeated or inside another is reported in its own place, as
+// covered by the first one. srm list sorts by name unless --sort says
+// otherwise, ties always going by name.
+
+// OPERANDSORTS are the orders --sort-operands accepts
+var OPERANDSORTS = []string{"none", "path", "size"}
+
+// sortOperands reorders paths for --sort-operands: none keeps them as
+// given, path sorts them bytewise and size puts the biggest first, going by
+// what they take on disk. Sorts are stable, so ties keep the order given,
+// and an operand that can't be measured counts as empty.
+func sortOperands(paths []string, by string) ([]string, error) {
+	sorted := append([]string{}, paths...)
+	switch by {
+	case "", "none":
+	case "path":
+		sort.SliceStable(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	case "size":
+		sizes := map[string]int64{}
+		for _, path := range sorted {
+			sizes[path], _ = remove.DiskUsage(remove.OSFS{}, path)
+		}
+		sort.SliceStable(sorted, func(i, j int) bool { return sizes[sorted[i]] > sizes[sorted[j]] })
+	default:
+		return nil, fmt.Errorf("invalid --sort-operands: %s (expected none, path or size)", by)
+	}
+	return sorted, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSortOperands(t *testing.T) {
+	dir := t.TempDir()
+	sizes := map[string]int{"small": 4096, "big": 1 << 20, "empty1": 0, "empty2": 0, "medium": 64 << 10}
+	for name, size := range sizes {
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		operands []string
+		by       string
+		want     []string
+	}{
+		{[]string{"b", "a", "c"}, "", []string{"b", "a", "c"}},
+		{[]string{"b", "a", "c"}, "none", []string{"b", "a", "c"}},
+		{[]string{"b", "a", "c"}, "path", []string{"a", "b", "c"}},
+		// bytewise: upper case before lower, and a directory before its
+		// contents
+		{[]string{"b", "B", "a/x", "a", "a-"}, "path", []string{"B", "a", "a-", "a/x", "b"}},
+		// a repeat keeps its place among its ties
+		{[]string{"b", "a", "b"}, "path", []string{"a", "b", "b"}},
+		{[]string{"small", "empty1", "big", "medium"}, "size", []string{"big", "medium", "small", "empty1"}},
+		// ties keep the order given, and what can't be measured counts as
+		// empty
+		{[]string{"empty2", "missing", "small", "empty1"}, "size", []string{"small", "empty2", "missing", "empty1"}},
+	}
+	for _, tt := range tests {
+		operands := []string{}
+		for _, operand := range tt.operands {
+			operands = append(operands, filepath.Join(dir, operand))
+		}
+		got, err := sortOperands(operands, tt.by)
+		if err != nil {
+			t.Errorf("sortOperands(%v, %q): %v", tt.operands, tt.by, err)
+			continue
+		}
+		names := []string{}
+		for _, path := range got {
+			names = append(names, strings.TrimPrefix(path, dir+string(filepath.Separator)))
+		}
+		if !slices.Equal(names, tt.want) {
+			t.Errorf("sortOperands(%v, %q) = %v, want %v", tt.operands, tt.by, names, tt.want)
+		}
+	}
+
+	operands := []string{"b", "a"}
+	if _, err := sortOperands(operands, "mtime"); err == nil || !strings.Contains(err.Error(), "invalid --sort-operands") {
+		t.Errorf("sortOperands with mtime: %v", err)
+	}
+	if _, err := sortOperands(operands, "path"); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(operands, []string{"b", "a"}) {
+		t.Errorf("sortOperands changed its argument to %v", operands)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// MOREPROMPT is what the built-in pager asks after each screenful
+const MOREPROMPT = "-- more (y/n) -- "
+
+// dumbTerminal reports whether TERM says the terminal can't move the
+// cursor, as over a serial console or an editor's shell buffer
+func dumbTerminal() bool {
+	return os.Getenv("TERM") == "dumb"
+}
+
+// redrawable reports whether f is a terminal that can take a progress bar
+// redrawn in place: a real tty, not a dumb one
+func redrawable(f *os.File) bool {
+	return remove.IsTTY(f) && !dumbTerminal()
+}
+
+// pagerTerminal is how startPager tells a terminal, which a test swaps
+// for a fake one
+var pagerTerminal = remove.IsTTY
+
+// morePager pages what is written to it onto a terminal height lines tall,
+// asking MOREPROMPT on in after every screenful but the last. Once the
+// answer is anything but yes the rest is dropped.
+type morePager struct {
+	out    io.Writer
+	in     *bufio.Reader
+	height int
+	lines  int
+	quit   bool
+}
+
+func newMorePager(out io.Writer, in io.Reader, height int) *morePager {
+	if height < 2 {
+		height = 2
+	}
+	return &morePager{out: out, in: bufio.NewReader(in), height: height}
+}
+
+func (p *morePager) Write(b []byte) (int, error) {
+	n := len(b)
+	for len(b) > 0 && !p.quit {
+		if p.lines == p.height-1 {
+			io.WriteString(p.out, MOREPROMPT)
+			answer, err := p.in.ReadString('\n')
+			if (err != nil && answer == "") || !remove.In(strings.TrimSpace(answer), append([]string{""}, remove.YESANSWERS...)) {
+				p.quit = true
+				break
+			}
+			p.lines = 0
+		}
+		line := b
+		if i := bytes.IndexByte(b, '\n'); i >= 0 {
+			line = b[:i+1]
+			p.lines++
+		}
+		if _, err := p.out.Write(line); err != nil {
+			return n, err
+		}
+		b = b[len(line):]
+	}
+	return n, nil
+}
+
+// startPager sends what the command prints to stdout from here on through
+// a pager, for list, history and anything else that can print more than a
+// screenful: $PAGER when it is set and the terminal isn't dumb, otherwise
+// morePager. Output that isn't to a terminal someone is reading, or with
+// no one at stdin to answer, stays plain. stop restores stdout and waits
+// for the pager to finish; it is safe to call more than once.
+func startPager() (stop func()) {
+	if !pagerTerminal(os.Stdout) || !pagerTerminal(os.Stdin) {
+		return func() {}
+	}
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	var cmd *exec.Cmd
+	if pager := os.Getenv("PAGER"); pager != "" && !dumbTerminal() {
+		cmd = exec.Command("sh", "-c", pager)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = r, stdout, os.Stderr
+		if err := cmd.Start(); err != nil {
+			cmd = nil
+		}
+	}
+	if cmd != nil {
+		// the pager has its own copy of the read end
+		r.Close()
+		go func() {
+			cmd.Wait()
+			close(done)
+		}()
+	} else {
+		_, height := remove.TerminalSize()
+		go func() {
+			io.Copy(newMorePager(stdout, os.Stdin, height), r)
+			r.Close()
+			close(done)
+		}()
+	}
+	os.Stdout = w
+
+	stopped := false
+	return func() {
+		if stopped {
+			return
+		}
+		stopped = true
+		os.Stdout = stdout
+		w.Close()
+		<-done
+	}
+}
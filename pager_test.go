@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMorePager(t *testing.T) {
+	text := "1\n2\n3\n4\n5\n6\n"
+	tests := []struct {
+		height  int
+		answers string
+		want    string
+	}{
+		{10, "", text},
+		{3, "y\ny\n", "1\n2\n" + MOREPROMPT + "3\n4\n" + MOREPROMPT + "5\n6\n"},
+		// enter goes on too
+		{3, "\n\n", "1\n2\n" + MOREPROMPT + "3\n4\n" + MOREPROMPT + "5\n6\n"},
+		{3, "y\nn\n", "1\n2\n" + MOREPROMPT + "3\n4\n" + MOREPROMPT},
+		{3, "q\n", "1\n2\n" + MOREPROMPT},
+		// no one left to answer
+		{3, "", "1\n2\n" + MOREPROMPT},
+		// too short to page is taken as 2 lines
+		{0, "y\ny\ny\ny\ny\n", "1\n" + MOREPROMPT + "2\n" + MOREPROMPT + "3\n" + MOREPROMPT + "4\n" + MOREPROMPT + "5\n" + MOREPROMPT + "6\n"},
+	}
+	for _, tt := range tests {
+		out := &strings.Builder{}
+		p := newMorePager(out, strings.NewReader(tt.answers), tt.height)
+		// written in pieces that split lines
+		for _, piece := range []string{text[:3], text[3:8], text[8:]} {
+			if n, err := io.WriteString(p, piece); n != len(piece) || err != nil {
+				t.Errorf("height %d: wrote %d of %d, %v", tt.height, n, len(piece), err)
+			}
+		}
+		if out.String() != tt.want {
+			t.Errorf("height %d answering %q: %q, want %q", tt.height, tt.answers, out.String(), tt.want)
+		}
+	}
+}
+
+// startPager pages only onto a terminal with someone at it, through $PAGER
+// when set and morePager otherwise
+func TestStartPager(t *testing.T) {
+	tests := []struct {
+		name     string
+		terminal bool
+		pager    string
+		term     string
+		want     string
+	}{
+		{"not a terminal", false, "", "xterm", "1\n2\n3\n4\n"},
+		{"the built-in pager", true, "", "xterm", "1\n2\n" + MOREPROMPT + "3\n4\n"},
+		{"$PAGER", true, "sed s/^/paged:/", "xterm", "paged:1\npaged:2\npaged:3\npaged:4\n"},
+		{"$PAGER on a dumb terminal", true, "sed s/^/paged:/", "dumb", "1\n2\n" + MOREPROMPT + "3\n4\n"},
+	}
+	for _, tt := range tests {
+		t.Setenv("PAGER", tt.pager)
+		t.Setenv("TERM", tt.term)
+		t.Setenv("LINES", "3")
+		old := pagerTerminal
+		pagerTerminal = func(*os.File) bool { return tt.terminal }
+
+		// stdin answers the one question the built-in pager asks
+		in, answers, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		answers.WriteString("y\n")
+		answers.Close()
+		oldStdin := os.Stdin
+		os.Stdin = in
+
+		out := stdout(t, func() {
+			stop := startPager()
+			for i := 1; i <= 4; i++ {
+				fmt.Println(i)
+			}
+			stop()
+			stop()
+		})
+		os.Stdin = oldStdin
+		in.Close()
+		pagerTerminal = old
+
+		if out != tt.want {
+			t.Errorf("%s: %q, want %q", tt.name, out, tt.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// posixEnabled reports whether --posix was given or SRM_POSIX is set
+func posixEnabled(flags []string) (bool, error) {
+	if remove.In("--posix", flags) {
+		return true, nil
+	}
+	env := os.Getenv("SRM_POSIX")
+	if env == "" {
+		return false, nil
+	}
+	on, err := strconv.ParseBool(env)
+	if err != nil {
+		return false, fmt.Errorf("SRM_POSIX: expected true or false, got %q", env)
+	}
+	return on, nil
+}
+
+// lastOf returns whichever of names comes last in flags, "" for none
+func lastOf(flags []string, names ...string) string {
+	last := ""
+	for _, flag := range flags {
+		if remove.In(flag, names) {
+			last = flag
+		}
+	}
+	return last
+}
+
+// posixMessage words any failure to remove path as rm would, "cannot
+// remove 'x': " and the innermost error, capitalised like strerror
+func posixMessage(path string, err error) string {
+	if msg, ok := rmDiagnostic(path, err); ok {
+		return msg
+	}
+	for inner := errors.Unwrap(err); inner != nil; inner = errors.Unwrap(err) {
+		err = inner
+	}
+	reason := err.Error()
+	if reason == "" {
+		return fmt.Sprintf("cannot remove '%s'", remove.DisplayPath(path))
+	}
+	return fmt.Sprintf("cannot remove '%s': %s", remove.DisplayPath(path), strings.ToUpper(reason[:1])+reason[1:])
+}
+
+// posixVerbose is rm's -v line for result
+func posixVerbose(result remove.Result) string {
+	if result.IsDir {
+		return fmt.Sprintf("removed directory '%s'", remove.DisplayPath(result.Source))
+	}
+	return fmt.Sprintf("removed '%s'", remove.DisplayPath(result.Source))
+}
+
+// missingOperand is rm's complaint about being given nothing to remove
+func missingOperand() {
+	fmt.Fprintln(os.Stderr, "srm: missing operand")
+	fmt.Fprintln(os.Stderr, "Try 'srm --help' for more information.")
+	os.Exit(1)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// PROGRESSMIN is the smallest operand worth drawing a progress bar for
+var PROGRESSMIN int64 = 64 << 20
+
+// progressBar returns an OnProgress callback that draws a one line bar on w,
+// redrawing at most every 100ms and clearing the line once done reaches total
+func progressBar(w io.Writer, width int) func(done, total int64) {
+	var last time.Time
+	drawn := false
+
+	return func(done, total int64) {
+		if total < PROGRESSMIN {
+			return
+		}
+		if done >= total {
+			if drawn {
+				fmt.Fprint(w, "\r\033[K")
+				drawn = false
+			}
+			return
+		}
+		if time.Since(last) < 100*time.Millisecond {
+			return
+		}
+		last = time.Now()
+
+		label := fmt.Sprintf(" %3d%% %s / %s", done*100/total, remove.FormatSize(done), remove.FormatSize(total))
+		barWidth := width - len(label) - 3
+		if barWidth < 10 {
+			fmt.Fprint(w, "\r"+label)
+		} else {
+			filled := int(int64(barWidth) * done / total)
+			fmt.Fprintf(w, "\r[%s%s]%s", strings.Repeat("#", filled), strings.Repeat(" ", barWidth-filled), label)
+		}
+		drawn = true
+	}
+}
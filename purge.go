@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// purgeCommand
+// srm purge [--yes] [--secure] <entry ...>
+// permanently deletes exactly the named trash entries, each given by ID,
+// trash name or original path. Every entry is asked about unless --yes (or
+// -f); a name matching several entries lists them and purges none. A failed
+// entry doesn't stop the rest, but makes srm purge exit 1.
+func purgeCommand(args []string) {
+	flags, queries := parseArgs(args)
+	if len(queries) == 0 {
+		fmt.Fprintln(os.Stderr, "srm purge: no entries given")
+		os.Exit(1)
+	}
+	yes := remove.In("--yes", flags) || remove.In("-f", flags)
+	secure := remove.In("--secure", flags)
+
+	opts, err := resolveOptions(flags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm purge: %s\n", err)
+		os.Exit(1)
+	}
+	if opts.SafeMode {
+		fmt.Fprintln(os.Stderr, "srm purge: disabled by safe mode")
+		os.Exit(1)
+	}
+
+	targetDir, err := remove.FindTrashDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm purge: %s\n", err)
+		os.Exit(1)
+	}
+	index, err := remove.OpenIndex()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm purge: %s\n", err)
+		os.Exit(1)
+	}
+	candidates, err := emptyCandidates(index, targetDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm purge: %s\n", err)
+		os.Exit(1)
+	}
+
+	journal := openJournal(newOpID(), os.Args)
+	defer journal.Close()
+
+	failed := false
+	for _, query := range queries {
+		matches := purgeMatches(candidates, query)
+		switch {
+		case len(matches) == 0:
+			fmt.Fprintf(os.Stderr, "srm purge: %s: not in the trash\n", remove.DisplayName(query))
+			failed = true
+			continue
+		case len(matches) > 1:
+			fmt.Fprintf(os.Stderr, "srm purge: %s is ambiguous, give one of these IDs:\n", remove.DisplayName(query))
+			for _, c := range matches {
+				fmt.Fprintf(os.Stderr, "    %s  %s  %s  %s\n", c.Entry.ID, c.Entry.Deleted.Local().Format("2006-01-02 15:04"), remove.FormatSize(c.Size), remove.DisplayName(c.Entry.Origin))
+			}
+			failed = true
+			continue
+		}
+
+		c := matches[0]
+		if !yes {
+			what := c.Entry.Payload()
+			if c.Entry.Origin != "" {
+				what = remove.DisplayPath(c.Entry.Origin)
+			}
+			msg := fmt.Sprintf("permanently delete %s (%s)? ", remove.DisplayName(what), remove.FormatSize(c.Size))
+			if secure {
+				msg = fmt.Sprintf("overwrite and permanently delete %s (%s)? ", remove.DisplayName(what), remove.FormatSize(c.Size))
+			}
+			if !remove.GetUserConfirmation(msg) {
+				continue
+			}
+		}
+
+		result := remove.PurgeCandidate(remove.OSFS{}, index, c, secure)
+		journal.Record(result)
+		if result.Err != nil {
+			fmt.Fprintf(os.Stderr, "srm purge: %s\n", remove.DisplayName(result.Err.Error()))
+			failed = true
+		}
+	}
+	if failed || journal.Refused() {
+		journal.Close()
+		os.Exit(1)
+	}
+}
+
+// purgeMatches finds the trash entries query names. An ID picks exactly its
+// entry; a trash name or original path may match several generations.
+func purgeMatches(candidates []remove.EmptyCandidate, query string) []remove.EmptyCandidate {
+	for _, c := range candidates {
+		if c.Known && c.Entry.ID == query {
+			return []remove.EmptyCandidate{c}
+		}
+	}
+
+	abs, _ := filepath.Abs(query)
+	matches := []remove.EmptyCandidate{}
+	for _, c := range candidates {
+		if c.Entry.Name == query || (c.Known && c.Entry.Origin == abs) {
+			matches = append(matches, c)
+		}
+	}
+	return matches
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// REMOVABLEROOTS are where udisks mounts removable drives for a desktop
+// session, at ROOT/USER/LABEL: /run/media on most distributions, /media on
+// Debian and Ubuntu
+var REMOVABLEROOTS = []string{"/run/media", "/media"}
+
+// RemovableTrash is the trash on a removable drive, which srm list shows
+// beside the home trash
+type RemovableTrash struct {
+	Dir   string
+	Label string
+	// Offline is set when the drive isn't mounted; its entries are then
+	// only known from the index
+	Offline bool
+}
+
+// removableLabel is the label of the removable drive mounted at point, the
+// name udisks mounted it under, and false when point isn't where udisks
+// mounts drives for username
+func removableLabel(point string, username string) (string, bool) {
+	if username == "" {
+		return "", false
+	}
+	for _, root := range REMOVABLEROOTS {
+		if filepath.Dir(point) == filepath.Join(root, username) {
+			return filepath.Base(point), true
+		}
+	}
+	return "", false
+}
+
+// currentUsername is the name of the user srm runs as, USER when the
+// account can't be looked up
+func currentUsername() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// removableTrashes are the trashes on removable drives: those laid out as a
+// freedesktop.org trash on the drives mounted now, which GIO makes, and
+// those the index has entries in, offline when their drive isn't mounted.
+// locations are the index's trash directories.
+func removableTrashes(mounts []remove.Mount, locations []string, uid int, username string) []RemovableTrash {
+	found := map[string]RemovableTrash{}
+	mounted := map[string]bool{}
+	for _, m := range mounts {
+		label, ok := removableLabel(m.Point, username)
+		if !ok {
+			continue
+		}
+		mounted[m.Point] = true
+		if top := remove.VolumeTrashDir(m.Point, uid); remove.IsSpecTrash(top) {
+			dir := filepath.Join(top, "files")
+			found[dir] = RemovableTrash{Dir: dir, Label: label}
+		}
+	}
+
+	for _, location := range locations {
+		// .Trash-UID, or its files directory
+		top := location
+		if filepath.Base(top) == "files" {
+			top = filepath.Dir(top)
+		}
+		point := filepath.Dir(top)
+		label, ok := removableLabel(point, username)
+		if !ok || top != remove.VolumeTrashDir(point, uid) {
+			continue
+		}
+		if _, ok := found[location]; ok {
+			continue
+		}
+		if !mounted[point] {
+			found[location] = RemovableTrash{Dir: location, Label: label, Offline: true}
+		} else if fi, err := os.Stat(location); err == nil && fi.IsDir() {
+			found[location] = RemovableTrash{Dir: location, Label: label}
+		}
+	}
+
+	trashes := []RemovableTrash{}
+	for _, trash := range found {
+		trashes = append(trashes, trash)
+	}
+	sort.Slice(trashes, func(i, j int) bool {
+		if trashes[i].Label != trashes[j].Label {
+			return trashes[i].Label < trashes[j].Label
+		}
+		return trashes[i].Dir < trashes[j].Dir
+	})
+	return trashes
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// A drive udisks mounted for the user is listed when the index knows its
+// trash, offline when it isn't mounted now; a drive mounted without a
+// trash and a trash outside udisks' mount points aren't
+func TestRemovableTrashes(t *testing.T) {
+	mounts := []remove.Mount{{Point: "/media/alice/Stick", FSType: "vfat"}}
+	locations := []string{"/run/media/alice/USB DISK/.Trash-1000/files", "/media/alice/Stick/.Trash-1000", "/mnt/other/.Trash-1000"}
+	trashes := removableTrashes(mounts, locations, 1000, "alice")
+	want := []RemovableTrash{{Dir: locations[0], Label: "USB DISK", Offline: true}}
+	if !slices.Equal(trashes, want) {
+		t.Errorf("removable trashes %v, want %v", trashes, want)
+	}
+	if trashes := removableTrashes(mounts, locations, 1000, ""); len(trashes) > 0 {
+		t.Errorf("with no user name: %v", trashes)
+	}
+}
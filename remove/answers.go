@@ -0,0 +1,118 @@
+package remove
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// answers that count as a yes
+var YESANSWERS = []string{"y", "yes", "yea", "yeah", "da", "si", "letsgo"}
+
+// answers are read a whole line at a time from ANSWERS, so nothing typed
+// after one answer is left over to answer the next question. It is nil
+// until the first question, when AnswerInput picks where they come from;
+// setting it before then answers them from anywhere else.
+var ANSWERS *bufio.Reader
+
+// answersGone is set once there is nothing to read answers from, no
+// terminal or input that ended (Ctrl-D): every question after that is taken
+// as no without being asked, rather than asked again and again of no one
+var (
+	answersGone   bool
+	answersGoneAt sync.Once
+)
+
+// AnswerInput
+// picks where answers come from the first time one is needed: the file
+// SRM_ANSWERS names, for scripts and tests; stdin under --posix, as for rm;
+// otherwise the terminal, which is stdin when it is one and /dev/tty when
+// stdin was redirected, so `xargs srm -i` doesn't answer with the file list
+// piped to it. nil once answersGone.
+func AnswerInput() *bufio.Reader {
+	if ANSWERS != nil || answersGone {
+		return ANSWERS
+	}
+	switch name := os.Getenv("SRM_ANSWERS"); {
+	case name != "":
+		if f, err := os.Open(name); err != nil {
+			fmt.Fprintf(os.Stderr, "srm: SRM_ANSWERS: %s\n", err)
+		} else {
+			ANSWERS = bufio.NewReader(f)
+		}
+	case POSIX || IsTTY(os.Stdin):
+		ANSWERS = bufio.NewReader(os.Stdin)
+	default:
+		if tty, err := openTerminal(); err == nil {
+			ANSWERS = bufio.NewReader(tty)
+		}
+	}
+	answersGone = ANSWERS == nil
+	return ANSWERS
+}
+
+// CanAsk reports whether a question can be answered at all
+func CanAsk() bool {
+	return AnswerInput() != nil
+}
+
+// stopAsking takes every question from now on as no, saying why once
+func stopAsking(why string) {
+	ANSWERS, answersGone = nil, true
+	answersGoneAt.Do(func() {
+		fmt.Fprintf(os.Stderr, "srm: %s, taking every question as no\n", why)
+	})
+}
+
+// GetUserAnswer
+// will print your msg (string) and then return what the user typed,
+// lowercased; "" without asking when there is no one to ask, see AnswerInput
+func GetUserAnswer(msg string) string {
+	in := AnswerInput()
+	if in == nil {
+		stopAsking("no terminal to ask on")
+		return ""
+	}
+	fmt.Print(msg)
+	answer, err := readAnswer(in, os.Stderr)
+	if err != nil {
+		// end the prompt's line, as typing an answer would have
+		fmt.Println()
+		stopAsking("end of input")
+	}
+	return answer
+}
+
+// readAnswer
+// reads one line from in and returns its first word, lowercased, or io.EOF
+// once the input has ended. The rest of the line is dropped, and a note on
+// notes says so, or says when the word looks like a pasted path rather
+// than an answer
+func readAnswer(in *bufio.Reader, notes io.Writer) (string, error) {
+	line, err := in.ReadString('\n')
+	if line == "" && err != nil {
+		return "", err
+	}
+	words := strings.Fields(line)
+	if len(words) == 0 {
+		return "", nil
+	}
+
+	answer := strings.ToLower(words[0])
+	switch {
+	case len(words) > 1:
+		fmt.Fprintf(notes, "srm: only the first word of an answer counts, ignoring %q\n", DisplayName(strings.Join(words[1:], " ")))
+	case strings.Contains(answer, "/"):
+		fmt.Fprintf(notes, "srm: %q looks pasted rather than typed, taking it as no\n", DisplayName(words[0]))
+	}
+	return answer, nil
+}
+
+// GetUserConfirmation
+// will print your msg (string) and then return true or false depending on users response
+func GetUserConfirmation(msg string) bool {
+	return In(GetUserAnswer(msg), YESANSWERS)
+}
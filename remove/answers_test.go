@@ -0,0 +1,125 @@
+package remove
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Each question takes one line of a scripted session, and nothing typed
+// after the first word of one is left to answer the next
+func TestReadAnswer(t *testing.T) {
+	type turn struct {
+		answer string
+		note   string
+	}
+	tests := []struct {
+		name   string
+		script string
+		turns  []turn
+	}{
+		{"one word a line", "y\nn\nyes\n", []turn{{"y", ""}, {"n", ""}, {"yes", ""}}},
+		{"case and spaces", "  YES \n\tNo\t\n", []turn{{"yes", ""}, {"no", ""}}},
+		{"more than one word", "yes please\nn\n", []turn{{"yes", `ignoring "please"`}, {"n", ""}}},
+		{"a no with a yes after it", "no yes y\ny\n", []turn{{"no", `ignoring "yes y"`}, {"y", ""}}},
+		{"an empty line", "\n   \ny\n", []turn{{"", ""}, {"", ""}, {"y", ""}}},
+		{"a pasted path", "/home/u/report.txt\ny\n", []turn{{"/home/u/report.txt", "looks pasted"}, {"y", ""}}},
+		{"a pasted command", "srm -rf /tmp/x\ny\n", []turn{{"srm", `ignoring "-rf /tmp/x"`}, {"y", ""}}},
+		{"a last line without its newline", "n\ny", []turn{{"n", ""}, {"y", ""}}},
+		{"crlf", "y\r\nn\r\n", []turn{{"y", ""}, {"n", ""}}},
+		{"escapes in what is ignored", "y \x1b[2J\n", []turn{{"y", `ignoring "\\e[2J"`}}},
+	}
+	for _, tt := range tests {
+		in := bufio.NewReader(strings.NewReader(tt.script))
+		for i, want := range tt.turns {
+			var notes strings.Builder
+			answer, err := readAnswer(in, &notes)
+			if err != nil {
+				t.Errorf("%s: question %d: %v", tt.name, i+1, err)
+				break
+			}
+			if answer != want.answer {
+				t.Errorf("%s: question %d answered %q, want %q", tt.name, i+1, answer, want.answer)
+			}
+			if (want.note == "") != (notes.Len() == 0) || !strings.Contains(notes.String(), want.note) {
+				t.Errorf("%s: question %d noted %q, want %q", tt.name, i+1, notes.String(), want.note)
+			}
+		}
+		if answer, err := readAnswer(in, io.Discard); !errors.Is(err, io.EOF) {
+			t.Errorf("%s: a question after the script got %q, %v", tt.name, answer, err)
+		}
+	}
+}
+
+// withAnswers answers srm's questions from script for the rest of the test
+func withAnswers(t *testing.T, script string) {
+	t.Helper()
+	saved, savedGone := ANSWERS, answersGone
+	t.Cleanup(func() { ANSWERS, answersGone = saved, savedGone })
+	ANSWERS, answersGone = bufio.NewReader(strings.NewReader(script)), false
+}
+
+func TestGetUserConfirmation(t *testing.T) {
+	withAnswers(t, "yes please\nno\nda\nsure\n/tmp/y\ny")
+	for i, want := range []bool{true, false, true, false, false, true} {
+		if got := GetUserConfirmation("remove it? "); got != want {
+			t.Errorf("question %d: %v, want %v", i+1, got, want)
+		}
+	}
+	// once the input ends, every question is no without asking
+	for i := 0; i < 2; i++ {
+		if GetUserConfirmation("remove it? ") {
+			t.Errorf("a question after the end of input was answered yes")
+		}
+	}
+	if !answersGone || CanAsk() {
+		t.Errorf("still asking after the end of input")
+	}
+}
+
+func TestAnswersFromEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "answers")
+	if err := os.WriteFile(path, []byte("Yes please\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	saved, savedGone := ANSWERS, answersGone
+	defer func() { ANSWERS, answersGone = saved, savedGone }()
+	ANSWERS, answersGone = nil, false
+	t.Setenv("SRM_ANSWERS", path)
+
+	in := AnswerInput()
+	if in == nil {
+		t.Fatal("no answers read from SRM_ANSWERS")
+	}
+	if answer, err := readAnswer(in, io.Discard); answer != "yes" || err != nil {
+		t.Errorf("answered %q, %v, want yes", answer, err)
+	}
+	if answer, err := readAnswer(in, io.Discard); !errors.Is(err, io.EOF) {
+		t.Errorf("answered %q, %v after the end of the file", answer, err)
+	}
+}
+
+func TestTerminalPrompt(t *testing.T) {
+	saved := POSIX
+	t.Cleanup(func() { POSIX = saved })
+	req := PromptRequest{Kind: "confirm", Path: "a", Message: "remove a?"}
+
+	// the Remover's option decides, whatever the variable says: rm's
+	// prompt takes the end of input as no and keeps asking, srm's stops
+	for _, posix := range []bool{true, false} {
+		POSIX = !posix
+		withAnswers(t, "y\n")
+		prompt := NewRemover(Options{POSIX: posix}).opts.Callbacks.OnPrompt
+		if answer, err := prompt(req); answer != "y" || err != nil {
+			t.Errorf("posix %v: answered %q, %v, want y", posix, answer, err)
+		}
+		prompt(req)
+		if answersGone == posix {
+			t.Errorf("posix %v: still asking after the end of input is %v", posix, !answersGone)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package remove
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+)
+
+// the only archive format --archive writes
+const ARCHIVEFORMAT = "tar.gz"
+
+var errInterrupted = errors.New("interrupted")
+
+// archiveTree streams the tree rooted at dir into a gzipped tarball at dest,
+// with member names relative to dir's parent. The tarball is written under a
+// temporary name, synced, and only then renamed into place, so dest either
+// holds a complete archive or doesn't exist. An interrupt while writing
+// removes the partial file and returns errInterrupted. progress, when not
+// nil, is called with the bytes of file contents archived so far.
+func archiveTree(fsys FS, dir string, dest string, progress func(int64)) (size int64, err error) {
+	partial := dest + ".partial"
+	out, err := fsys.Create(partial)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			out.Close()
+			fsys.Remove(partial)
+		}
+	}()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	base := filepath.Dir(dir)
+	contents := &progressWriter{w: tw, fn: progress}
+
+	var walk func(path string) error
+	walk = func(path string) error {
+		select {
+		case <-sigs:
+			return errInterrupted
+		default:
+		}
+
+		fi, err := fsys.Lstat(path)
+		if err != nil {
+			return err
+		}
+
+		link := ""
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if link, err = fsys.Readlink(path); err != nil {
+				return err
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if fi.IsDir() {
+			hdr.Name += "/"
+			// an opaque directory hides the lower layers only while it has these
+			for name, value := range OverlayXattrs(path) {
+				if hdr.PAXRecords == nil {
+					hdr.PAXRecords = map[string]string{}
+				}
+				hdr.PAXRecords["SCHILY.xattr."+name] = value
+			}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		switch {
+		case fi.Mode().IsRegular():
+			f, err := fsys.Open(path)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(contents, f)
+			f.Close()
+			size = contents.done
+			return err
+		case fi.IsDir():
+			children, err := fsys.ReadDir(path)
+			if err != nil {
+				return err
+			}
+			for _, child := range children {
+				if err := walk(filepath.Join(path, child.Name())); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if err = walk(dir); err != nil {
+		return size, err
+	}
+	if err = tw.Close(); err != nil {
+		return size, err
+	}
+	if err = gz.Close(); err != nil {
+		return size, err
+	}
+	if err = out.Sync(); err != nil {
+		return size, err
+	}
+	if err = out.Close(); err != nil {
+		return size, err
+	}
+
+	return size, fsys.RenameNoReplace(partial, dest)
+}
+
+// progressWriter counts what passes through it, reporting the running total
+// to fn when there is one
+type progressWriter struct {
+	w    io.Writer
+	done int64
+	fn   func(int64)
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.done += int64(n)
+	if p.fn != nil {
+		p.fn(p.done)
+	}
+	return n, err
+}
+
+// ArchiveMembers calls fn with the header of every member of a tarball
+// written by archiveTree
+func ArchiveMembers(fsys FS, path string, fn func(*tar.Header)) error {
+	f, err := fsys.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fn(hdr)
+	}
+}
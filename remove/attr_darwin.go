@@ -0,0 +1,25 @@
+package remove
+
+import "syscall"
+
+const (
+	UF_IMMUTABLE = 0x00000002
+	UF_APPEND    = 0x00000004
+	SF_IMMUTABLE = 0x00020000
+	SF_APPEND    = 0x00040000
+)
+
+// immutable reports whether path has the user or system immutable or
+// append-only flag (chflags uchg, schg, uappnd, sappnd)
+func immutable(path string) (bool, error) {
+	var st syscall.Stat_t
+	if err := syscall.Lstat(path, &st); err != nil {
+		return false, err
+	}
+	return st.Flags&(UF_IMMUTABLE|UF_APPEND|SF_IMMUTABLE|SF_APPEND) != 0, nil
+}
+
+// overlayfs is Linux only
+func OverlayXattrs(path string) map[string]string {
+	return nil
+}
@@ -0,0 +1,50 @@
+package remove
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// _IOR('f', 1, long), so it depends on the size of a long
+	FS_IOC_GETFLAGS = 0x80006601 | unsafe.Sizeof(uintptr(0))<<16
+	FS_IMMUTABLE_FL = 0x00000010
+	FS_APPEND_FL    = 0x00000020
+)
+
+// immutable reports whether path has the immutable or append-only attribute
+// (chattr +i / +a), which stops even root from renaming it
+func immutable(path string) (bool, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	var flags int32
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), FS_IOC_GETFLAGS, uintptr(unsafe.Pointer(&flags)))
+	if errno != 0 {
+		// not every filesystem keeps these attributes
+		return false, nil
+	}
+	return flags&(FS_IMMUTABLE_FL|FS_APPEND_FL) != 0, nil
+}
+
+// OVERLAYXATTRS are the xattrs overlayfs marks an opaque directory with,
+// trusted.* for a privileged mount and user.* for a userxattr one
+var OVERLAYXATTRS = []string{"trusted.overlay.opaque", "user.overlay.opaque"}
+
+// OverlayXattrs returns the overlayfs xattrs set on path. trusted.* can only
+// be read with CAP_SYS_ADMIN, so without it only user.* ever shows up.
+func OverlayXattrs(path string) map[string]string {
+	xattrs := map[string]string{}
+	buf := make([]byte, 16)
+	for _, name := range OVERLAYXATTRS {
+		n, err := syscall.Getxattr(path, name, buf)
+		if err == nil {
+			xattrs[name] = string(buf[:n])
+		}
+	}
+	return xattrs
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+package remove
+
+func immutable(path string) (bool, error) {
+	return false, nil
+}
+
+func OverlayXattrs(path string) map[string]string {
+	return nil
+}
@@ -0,0 +1,77 @@
+package remove
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// AuxLimits cap how big srm's own files in its data dir get, the journal,
+// the size cache and the stats file; the index is compacted and the intent
+// logs emptied of settled moves whatever their size
+type AuxLimits struct {
+	// the journal is rotated to journal.1 once it reaches JournalMaxSize,
+	// keeping JournalGenerations rotated generations, and those last written
+	// to longer than JournalMaxAge ago are dropped (0 keeps them)
+	JournalMaxSize     int64
+	JournalGenerations int
+	JournalMaxAge      time.Duration
+	// SizeCacheMax is how many directories the size cache remembers
+	SizeCacheMax int
+	// StatsMaxAge is how far back the stats file keeps days
+	StatsMaxAge time.Duration
+}
+
+// DEFAULTAUXLIMITS are the limits without journal_max_size,
+// journal_generations, journal_max_age, size_cache_max and stats_max_age
+// in the config
+var DEFAULTAUXLIMITS = AuxLimits{
+	JournalMaxSize:     8 << 20,
+	JournalGenerations: 4,
+	SizeCacheMax:       SIZECACHEMAX,
+	StatsMaxAge:        2 * 365 * 24 * time.Hour,
+}
+
+// auxLimits reads the limits the config sets, DEFAULTAUXLIMITS' otherwise
+func auxLimits(config Config) (AuxLimits, error) {
+	limits := DEFAULTAUXLIMITS
+	var err error
+	if _, ok := config["journal_max_size"]; ok {
+		if limits.JournalMaxSize, err = config.Size("journal_max_size"); err != nil {
+			return limits, err
+		}
+	}
+	for key, count := range map[string]*int{"journal_generations": &limits.JournalGenerations, "size_cache_max": &limits.SizeCacheMax} {
+		value, ok := config[key]
+		if !ok {
+			continue
+		}
+		if *count, err = strconv.Atoi(value); err != nil || *count < 0 {
+			return limits, fmt.Errorf("%s: expected a count, got %q", key, value)
+		}
+	}
+	for key, age := range map[string]*time.Duration{"journal_max_age": &limits.JournalMaxAge, "stats_max_age": &limits.StatsMaxAge} {
+		value, ok := config[key]
+		if !ok {
+			continue
+		}
+		if *age, err = ParseAge(value); err != nil {
+			return limits, fmt.Errorf("%s: %w, expected an age like 90d or 52w", key, err)
+		}
+	}
+	return limits, nil
+}
+
+// CurrentAuxLimits are the limits of the user's config, the defaults when
+// it can't be read
+func CurrentAuxLimits() AuxLimits {
+	settings, err := LoadSettings()
+	if err != nil {
+		return DEFAULTAUXLIMITS
+	}
+	limits, err := auxLimits(settings.Config)
+	if err != nil {
+		return DEFAULTAUXLIMITS
+	}
+	return limits
+}
@@ -0,0 +1,314 @@
+package remove
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// EntryID names one entry inside a backend, unique within it
+type EntryID string
+
+// EntryMeta is what a backend is told about what it stores
+type EntryMeta struct {
+	// Origin is the absolute path it was removed from
+	Origin  string
+	Deleted time.Time
+	Reason  string
+	Op      string
+}
+
+// BackendEntry is one entry a backend holds
+type BackendEntry struct {
+	ID      EntryID
+	Origin  string
+	Deleted time.Time
+	// Size is the apparent size of what was stored
+	Size   int64
+	IsDir  bool
+	Reason string
+}
+
+// BackendStats sums up a backend
+type BackendStats struct {
+	Entries int
+	// Bytes is the apparent size of every entry, Stored what they take up
+	// in the backend, which can be less when it shares equal contents
+	Bytes  int64
+	Stored int64
+}
+
+// TrashBackend is somewhere removed files can go other than a trash
+// directory, like a snapshotting dataset or an archive. Store takes src
+// away, a file or a whole tree, and Restore puts an entry back at dst,
+// which mustn't exist, after which the backend no longer holds it.
+type TrashBackend interface {
+	Store(src string, meta EntryMeta) (EntryID, error)
+	Restore(id EntryID, dst string) error
+	List() ([]BackendEntry, error)
+	Purge(id EntryID) error
+	Stats() (BackendStats, error)
+}
+
+// ErrNoEntry is an EntryID a backend doesn't hold
+var ErrNoEntry = errors.New("no such entry")
+
+// DEFAULTBACKEND is the trash as srm always had it: each operand's trash
+// directory picked by ResolveTrash, the run's when none of its own is
+// usable. Removing into it doesn't go through a TrashBackend at all.
+const DEFAULTBACKEND = "trash"
+
+// BACKENDKINDS make a backend of each kind from what follows the colon in
+// backend[NAME] = KIND:ARG. A kind kept outside the tree is added from an
+// init function of the program importing this package; one that only it
+// uses can go straight into Options.Backend instead.
+var BACKENDKINDS = map[string]func(arg string) (TrashBackend, error){
+	"directory":  newDirectoryBackend,
+	"cas":        newCASBackend,
+	"recyclebin": newRecycleBinBackend,
+}
+
+// PLATFORMBACKENDS are backends there without a backend[NAME] key, as
+// KIND:ARG by name, like the Recycle Bin on Windows; a key of the same name
+// replaces one
+var PLATFORMBACKENDS = map[string]string{}
+
+// PLATFORMBACKEND is what removals go to without --backend or backend in
+// the config: DEFAULTBACKEND, unless the platform's own trash isn't a
+// directory, as Windows' Recycle Bin isn't
+var PLATFORMBACKEND = DEFAULTBACKEND
+
+// ConfiguredBackends reads the backend[NAME] = KIND:ARG keys of config
+func ConfiguredBackends(config Config) (map[string]string, error) {
+	specs := map[string]string{}
+	for name, spec := range PLATFORMBACKENDS {
+		specs[name] = spec
+	}
+	for key, value := range config {
+		name, ok := strings.CutPrefix(key, "backend[")
+		if !ok || !strings.HasSuffix(name, "]") {
+			continue
+		}
+		name = strings.TrimSuffix(name, "]")
+		kind, _, _ := strings.Cut(value, ":")
+		switch {
+		case name == "" || name == DEFAULTBACKEND:
+			return nil, fmt.Errorf("%s: the name %q is taken", key, name)
+		case BACKENDKINDS[kind] == nil:
+			return nil, fmt.Errorf("%s: no backend kind %q, expected one of %s", key, kind, strings.Join(backendKinds(), ", "))
+		}
+		specs[name] = value
+	}
+	return specs, nil
+}
+
+func backendKinds() []string {
+	kinds := []string{}
+	for kind := range BACKENDKINDS {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// OpenBackend makes the backend configured as name. The default trash has
+// no TrashBackend and gives nil.
+func OpenBackend(name string, config Config) (TrashBackend, error) {
+	if name == DEFAULTBACKEND {
+		return nil, nil
+	}
+	specs, err := ConfiguredBackends(config)
+	if err != nil {
+		return nil, err
+	}
+	spec, ok := specs[name]
+	if !ok {
+		return nil, fmt.Errorf("no backend %q, add backend[%s] = KIND:ARG to the config", name, name)
+	}
+	kind, arg, _ := strings.Cut(spec, ":")
+	backend, err := BACKENDKINDS[kind](arg)
+	if err != nil {
+		return nil, fmt.Errorf("backend %s: %w", name, err)
+	}
+	return backend, nil
+}
+
+// BackendRoot is the directory a backend keeps everything in, for the
+// sandbox, or "" when it has none
+func BackendRoot(backend TrashBackend) string {
+	if rooted, ok := backend.(interface{ Root() string }); ok {
+		return rooted.Root()
+	}
+	return ""
+}
+
+// directoryBackend is a trash directory as a TrashBackend, its entries
+// recorded in the index like any trash's, so srm list, -W and srm empty
+// see them too. Its EntryIDs are the index's.
+type directoryBackend struct {
+	dir   string
+	fs    FS
+	index *Index
+}
+
+// newDirectoryBackend opens the trash directory dir, which must exist
+func newDirectoryBackend(dir string) (TrashBackend, error) {
+	if !filepath.IsAbs(dir) {
+		return nil, fmt.Errorf("%q: expected an absolute trash directory", dir)
+	}
+	if err := CheckTrashDir(dir); err != nil {
+		return nil, err
+	}
+	index, err := OpenIndex()
+	if err != nil {
+		return nil, err
+	}
+	return &directoryBackend{dir: filepath.Clean(dir), fs: OSFS{}, index: index}, nil
+}
+
+func (b *directoryBackend) Root() string {
+	return b.dir
+}
+
+// Store moves src into the trash under a free name, copying it when it is
+// on another filesystem
+func (b *directoryBackend) Store(src string, meta EntryMeta) (EntryID, error) {
+	fi, err := b.fs.Lstat(src)
+	if err != nil {
+		return "", err
+	}
+	size, _ := DiskUsage(b.fs, src)
+	name, err := MoveToFreeName(b.fs, b.dir, filepath.Base(src), meta.Origin, meta.Deleted, func(dest string) error {
+		err := b.fs.RenameNoReplace(src, dest)
+		if errors.Is(err, syscall.EXDEV) {
+			if _, err = copyTree(b.fs, src, dest, nil, false); err == nil {
+				err = b.fs.RemoveAll(src)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	dest := filepath.Join(b.dir, name)
+	recordDirectorySize(b.fs, dest)
+
+	entry := IndexEntry{
+		ID:      NewEntryID(),
+		Trash:   b.dir,
+		Name:    name,
+		Origin:  meta.Origin,
+		Deleted: meta.Deleted,
+		Size:    size,
+		IsDir:   fi.IsDir(),
+		Op:      meta.Op,
+		Reason:  url.PathEscape(meta.Reason),
+	}
+	return EntryID(entry.ID), b.index.Append(entry)
+}
+
+// entry is the index row of id in this trash
+func (b *directoryBackend) entry(id EntryID) (IndexEntry, error) {
+	entries, err := b.index.Entries()
+	if err != nil {
+		return IndexEntry{}, err
+	}
+	for _, entry := range entries {
+		if entry.ID == string(id) && entry.Trash == b.dir {
+			return entry, nil
+		}
+	}
+	return IndexEntry{}, fmt.Errorf("%s: %w", id, ErrNoEntry)
+}
+
+func (b *directoryBackend) Restore(id EntryID, dst string) error {
+	entry, err := b.entry(id)
+	if err != nil {
+		return err
+	}
+	if _, err := b.fs.Lstat(dst); err == nil {
+		return fmt.Errorf("%s: %w", DisplayPath(dst), os.ErrExist)
+	}
+	if err := RestoreEntry(b.fs, RestoreTarget{Entry: entry, Known: true, Generations: 1}, dst); err != nil {
+		return err
+	}
+	RemoveTrashInfo(entry.Payload())
+	return b.index.Forget(entry)
+}
+
+func (b *directoryBackend) List() ([]BackendEntry, error) {
+	entries, err := b.index.Entries()
+	if err != nil {
+		return nil, err
+	}
+	listed := []BackendEntry{}
+	for _, entry := range entries {
+		if entry.Trash != b.dir {
+			continue
+		}
+		listed = append(listed, BackendEntry{
+			ID:      EntryID(entry.ID),
+			Origin:  entry.Origin,
+			Deleted: entry.Deleted,
+			Size:    entry.Size,
+			IsDir:   entry.IsDir,
+			Reason:  entry.ReasonText(),
+		})
+	}
+	return listed, nil
+}
+
+func (b *directoryBackend) Purge(id EntryID) error {
+	entry, err := b.entry(id)
+	if err != nil {
+		return err
+	}
+	return PurgeCandidate(b.fs, b.index, EmptyCandidate{Entry: entry, Known: true, Dated: true, Size: entry.Size}, false).Err
+}
+
+func (b *directoryBackend) Stats() (BackendStats, error) {
+	entries, err := b.List()
+	if err != nil {
+		return BackendStats{}, err
+	}
+	stats := BackendStats{Entries: len(entries)}
+	for _, entry := range entries {
+		stats.Bytes += entry.Size
+	}
+	stats.Stored, err = DiskUsage(b.fs, b.dir)
+	return stats, err
+}
+
+// storeInBackend carries out a plan to put the operand into the Backend.
+// Dest is then NAME:ID, which srm backend NAME restore ID takes.
+func (r *Remover) storeInBackend(result Result, plan Plan) Result {
+	origin, err := filepath.Abs(plan.Path)
+	if err != nil {
+		origin = plan.Path
+	}
+	size, _ := DiskUsage(r.fs, plan.Path)
+	start := time.Now()
+	id, err := r.opts.Backend.Store(plan.Path, EntryMeta{Origin: origin, Deleted: start, Reason: r.opts.Reason, Op: r.opts.Op})
+	result.Duration = time.Since(start)
+	if id != "" {
+		result.Dest = r.opts.BackendName + ":" + string(id)
+		result.Trash, result.TrashWhy = plan.Trash, plan.TrashWhy
+	}
+	if err != nil {
+		result.Action = "failed"
+		if id != "" {
+			// stored, but the operand is still here
+			result.Note = strings.TrimPrefix(result.Note+"; stored as "+result.Dest, "; ")
+		}
+		result.Err = displayErr(err, plan.Path)
+		return result
+	}
+	result.Action, result.Strategy, result.Bytes = "trashed", "backend", size
+	return result
+}
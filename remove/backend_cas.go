@@ -0,0 +1,387 @@
+package remove
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CASGRACE is how old an object with nothing referring to it must be
+// before it is collected, since a Store running alongside writes its
+// objects before the manifest that refers to them. Purging an entry frees
+// its space once its contents are that old.
+const CASGRACE = time.Hour
+
+// casBackend is an archive directory with content-addressed payloads, the
+// cas backend kind: every file's contents are kept once, under their
+// SHA-256, in objects/, however many entries hold them, and each entry is
+// a manifest in entries/ of the tree it was. Restore rebuilds the tree.
+type casBackend struct {
+	root string
+}
+
+// casManifest is an entry of a casBackend, entries/ID.json
+type casManifest struct {
+	ID      EntryID     `json:"id"`
+	Origin  string      `json:"origin"`
+	Deleted time.Time   `json:"deleted"`
+	Reason  string      `json:"reason,omitempty"`
+	Op      string      `json:"op,omitempty"`
+	Size    int64       `json:"size"`
+	Files   []casMember `json:"files"`
+}
+
+// casMember is one thing in an entry's tree, the entry itself at "."
+// first and every directory ahead of what is in it
+type casMember struct {
+	Path     string      `json:"path"`
+	Mode     fs.FileMode `json:"mode"`
+	Modified time.Time   `json:"mtime"`
+	// Object is the SHA-256 of a regular file's contents, Link a
+	// symlink's target
+	Object string `json:"object,omitempty"`
+	Link   string `json:"link,omitempty"`
+}
+
+// newCASBackend opens the archive at root, an absolute directory created
+// owner-only when it isn't there
+func newCASBackend(root string) (TrashBackend, error) {
+	if !filepath.IsAbs(root) {
+		return nil, fmt.Errorf("%q: expected an absolute archive directory", root)
+	}
+	b := &casBackend{root: filepath.Clean(root)}
+	for _, dir := range []string{b.root, b.dir("objects"), b.dir("entries")} {
+		if err := MkdirPrivate(dir); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func (b *casBackend) Root() string {
+	return b.root
+}
+
+func (b *casBackend) dir(name string) string {
+	return filepath.Join(b.root, name)
+}
+
+func (b *casBackend) objectPath(sum string) string {
+	return filepath.Join(b.root, "objects", sum[:2], sum)
+}
+
+func (b *casBackend) manifestPath(id EntryID) string {
+	return filepath.Join(b.root, "entries", string(id)+".json")
+}
+
+// Store puts every file of src into objects/, writes the manifest and only
+// then removes src, so an entry is never without its contents. Fifos,
+// sockets and devices have no contents to keep and fail it.
+func (b *casBackend) Store(src string, meta EntryMeta) (EntryID, error) {
+	manifest := casManifest{ID: EntryID(NewEntryID()), Origin: meta.Origin, Deleted: meta.Deleted, Reason: meta.Reason, Op: meta.Op}
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		member := casMember{Path: filepath.ToSlash(rel), Mode: fi.Mode(), Modified: fi.ModTime()}
+		switch mode := fi.Mode(); {
+		case mode.IsRegular():
+			if member.Object, err = b.putObject(path); err != nil {
+				return err
+			}
+			manifest.Size += fi.Size()
+		case mode&fs.ModeSymlink != 0:
+			if member.Link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		case !mode.IsDir():
+			return fmt.Errorf("%s: a %s has no contents to archive", DisplayPath(path), fileTypePhrase(fi))
+		}
+		manifest.Files = append(manifest.Files, member)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := b.writeManifest(manifest); err != nil {
+		return "", err
+	}
+	if err := os.RemoveAll(src); err != nil {
+		return manifest.ID, err
+	}
+	return manifest.ID, nil
+}
+
+// putObject copies the file at path into objects/ under the SHA-256 of its
+// contents, unless an object by that sum is already there
+func (b *casBackend) putObject(path string) (string, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	tmp, err := os.CreateTemp(b.dir("objects"), ".object-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), in); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	dest := b.objectPath(sum)
+	if _, err := os.Stat(dest); err == nil {
+		// touched, so collecting doesn't take it before the manifest is in
+		now := time.Now()
+		return sum, os.Chtimes(dest, now, now)
+	}
+	if err := MkdirPrivate(filepath.Dir(dest)); err != nil {
+		return "", err
+	}
+	return sum, os.Rename(tmp.Name(), dest)
+}
+
+// writeManifest writes manifest by a rename, so it is whole or absent
+func (b *casBackend) writeManifest(manifest casManifest) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(b.dir("entries"), ".entry-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), b.manifestPath(manifest.ID))
+}
+
+func (b *casBackend) readManifest(id EntryID) (casManifest, error) {
+	var manifest casManifest
+	if id == "" || strings.ContainsAny(string(id), `/\`) || strings.HasPrefix(string(id), ".") {
+		return manifest, fmt.Errorf("%s: %w", id, ErrNoEntry)
+	}
+	data, err := os.ReadFile(b.manifestPath(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return manifest, fmt.Errorf("%s: %w", id, ErrNoEntry)
+	}
+	if err != nil {
+		return manifest, err
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, fmt.Errorf("%s: %w", b.manifestPath(id), err)
+	}
+	return manifest, nil
+}
+
+// Restore rebuilds the entry at a staging path beside dst, renamed into
+// place once complete, then drops the entry. Directories get their modes
+// and times once everything is in them.
+func (b *casBackend) Restore(id EntryID, dst string) error {
+	manifest, err := b.readManifest(id)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("%s: %w", DisplayPath(dst), os.ErrExist)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	staging := dst + ".partial"
+	if err := b.rebuild(manifest, staging); err != nil {
+		os.RemoveAll(staging)
+		return err
+	}
+	if err := os.Rename(staging, dst); err != nil {
+		os.RemoveAll(staging)
+		return err
+	}
+	return b.drop(id)
+}
+
+func (b *casBackend) rebuild(manifest casManifest, staging string) error {
+	dirs := []casMember{}
+	for _, member := range manifest.Files {
+		path := filepath.Join(staging, filepath.FromSlash(member.Path))
+		switch {
+		case member.Mode.IsDir():
+			if err := os.Mkdir(path, 0700); err != nil {
+				return err
+			}
+			dirs = append(dirs, member)
+			continue
+		case member.Mode&fs.ModeSymlink != 0:
+			if err := os.Symlink(member.Link, path); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := b.getObject(member.Object, path); err != nil {
+			return err
+		}
+		if err := os.Chmod(path, chmodBits(member.Mode)); err != nil {
+			return err
+		}
+		if err := os.Chtimes(path, member.Modified, member.Modified); err != nil {
+			return err
+		}
+	}
+	// deepest first, so setting one's times isn't undone inside it
+	for i := len(dirs) - 1; i >= 0; i-- {
+		path := filepath.Join(staging, filepath.FromSlash(dirs[i].Path))
+		if err := os.Chmod(path, chmodBits(dirs[i].Mode)); err != nil {
+			return err
+		}
+		if err := os.Chtimes(path, dirs[i].Modified, dirs[i].Modified); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getObject writes the contents stored as sum to the new file at path,
+// checking they still have that sum
+func (b *casBackend) getObject(sum string, path string) error {
+	in, err := os.Open(b.objectPath(sum))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, h), in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != sum {
+		return fmt.Errorf("%s: %w (object %s reads back as %s)", DisplayPath(path), ErrVerifyFailed, sum, got)
+	}
+	return nil
+}
+
+func (b *casBackend) List() ([]BackendEntry, error) {
+	manifests, err := b.manifests()
+	if err != nil {
+		return nil, err
+	}
+	listed := []BackendEntry{}
+	for _, manifest := range manifests {
+		entry := BackendEntry{ID: manifest.ID, Origin: manifest.Origin, Deleted: manifest.Deleted, Size: manifest.Size, Reason: manifest.Reason}
+		entry.IsDir = len(manifest.Files) > 0 && manifest.Files[0].Mode.IsDir()
+		listed = append(listed, entry)
+	}
+	return listed, nil
+}
+
+func (b *casBackend) manifests() ([]casManifest, error) {
+	des, err := os.ReadDir(b.dir("entries"))
+	if err != nil {
+		return nil, err
+	}
+	manifests := []casManifest{}
+	for _, de := range des {
+		id, ok := strings.CutSuffix(de.Name(), ".json")
+		if !ok || strings.HasPrefix(id, ".") {
+			continue
+		}
+		manifest, err := b.readManifest(EntryID(id))
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, manifest)
+	}
+	return manifests, nil
+}
+
+// Purge drops the entry, and with it whatever contents only it had
+func (b *casBackend) Purge(id EntryID) error {
+	if _, err := b.readManifest(id); err != nil {
+		return err
+	}
+	return b.drop(id)
+}
+
+// drop removes the entry's manifest and collects the objects nothing
+// refers to any more
+func (b *casBackend) drop(id EntryID) error {
+	if err := os.Remove(b.manifestPath(id)); err != nil {
+		return err
+	}
+	manifests, err := b.manifests()
+	if err != nil {
+		return err
+	}
+	referenced := map[string]bool{}
+	for _, manifest := range manifests {
+		for _, member := range manifest.Files {
+			referenced[member.Object] = true
+		}
+	}
+	return filepath.WalkDir(b.dir("objects"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || referenced[d.Name()] {
+			return err
+		}
+		// young ones may be a Store's whose manifest isn't in yet, and
+		// leftovers of one cut short are collected the same way
+		if fi, err := d.Info(); err != nil || time.Since(fi.ModTime()) < CASGRACE {
+			return err
+		}
+		return os.Remove(path)
+	})
+}
+
+func (b *casBackend) Stats() (BackendStats, error) {
+	entries, err := b.List()
+	if err != nil {
+		return BackendStats{}, err
+	}
+	stats := BackendStats{Entries: len(entries)}
+	for _, entry := range entries {
+		stats.Bytes += entry.Size
+	}
+	stats.Stored, err = DiskUsage(OSFS{}, b.dir("objects"))
+	return stats, err
+}
@@ -0,0 +1,90 @@
+package remove_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// boxBackend keeps each entry renamed into a numbered name under dir, as a
+// backend written outside srm's tree would
+type boxBackend struct {
+	dir     string
+	entries []remove.BackendEntry
+}
+
+func (b *boxBackend) Store(src string, meta remove.EntryMeta) (remove.EntryID, error) {
+	id := remove.EntryID(fmt.Sprint(len(b.entries) + 1))
+	if err := os.Rename(src, filepath.Join(b.dir, string(id))); err != nil {
+		return "", err
+	}
+	b.entries = append(b.entries, remove.BackendEntry{ID: id, Origin: meta.Origin, Deleted: meta.Deleted})
+	return id, nil
+}
+
+func (b *boxBackend) Restore(id remove.EntryID, dst string) error {
+	for i, entry := range b.entries {
+		if entry.ID == id {
+			b.entries = append(b.entries[:i], b.entries[i+1:]...)
+			return os.Rename(filepath.Join(b.dir, string(id)), dst)
+		}
+	}
+	return remove.ErrNoEntry
+}
+
+func (b *boxBackend) List() ([]remove.BackendEntry, error) {
+	return b.entries, nil
+}
+
+func (b *boxBackend) Purge(id remove.EntryID) error {
+	return errors.ErrUnsupported
+}
+
+func (b *boxBackend) Stats() (remove.BackendStats, error) {
+	return remove.BackendStats{Entries: len(b.entries)}, nil
+}
+
+// A program importing remove can remove into a backend of its own, and
+// register it as a kind for backend[NAME] = KIND:ARG
+func TestExternalBackend(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("HOME", filepath.Join(root, "home"))
+	t.Setenv("XDG_DATA_HOME", filepath.Join(root, "home", ".local", "share"))
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(root, "home", ".config"))
+	dir, path := filepath.Join(root, "box"), filepath.Join(root, "report.txt")
+	for _, err := range []error{os.Mkdir(dir, 0700), os.WriteFile(path, []byte("report"), 0644)} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	remove.BACKENDKINDS["box"] = func(arg string) (remove.TrashBackend, error) {
+		return &boxBackend{dir: arg}, nil
+	}
+	defer delete(remove.BACKENDKINDS, "box")
+	backend, err := remove.OpenBackend("box", remove.Config{"backend[box]": "box:" + dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := remove.NewRemover(remove.Options{Backend: backend, BackendName: "box"})
+	defer r.Close()
+	result := r.Remove(path)
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	id, ok := strings.CutPrefix(result.Dest, "box:")
+	if !ok || result.Status() != remove.StatusTrashed {
+		t.Fatalf("%s went to %q as %s", path, result.Dest, result.Status())
+	}
+	if err := backend.Restore(remove.EntryID(id), path); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(path); err != nil || string(got) != "report" {
+		t.Fatalf("restored %q, %v", got, err)
+	}
+}
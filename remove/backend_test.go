@@ -0,0 +1,109 @@
+package remove
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Both built-in backends store, list, restore and purge a directory, the
+// cas one sharing equal contents and dropping objects nothing refers to
+func TestDirectoryAndCASBackends(t *testing.T) {
+	env := testEnv(t)
+	vault, err := newCASBackend(filepath.Join(env.root, "vault"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shelf := filepath.Join(env.root, "shelf")
+	if err := MkdirPrivate(shelf); err != nil {
+		t.Fatal(err)
+	}
+	backends := map[string]TrashBackend{
+		"shelf": &directoryBackend{dir: shelf, fs: OSFS{}, index: env.index},
+		"vault": vault,
+	}
+	for _, name := range []string{"shelf", "vault"} {
+		backend := backends[name]
+		// the same contents twice, which the cas backend keeps once
+		for _, file := range []string{"a.txt", "sub/b.txt"} {
+			if _, err := env.file(filepath.Join(name, file), "same contents"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		dir := filepath.Join(env.work, name)
+		r := env.remover(true)
+		r.opts.Backend, r.opts.BackendName = backend, name
+		result := r.Remove(dir)
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+		id, ok := strings.CutPrefix(result.Dest, name+":")
+		if !ok || result.Status() != StatusTrashed {
+			t.Fatalf("%s: %s went to %q as %s", name, dir, result.Dest, result.Status())
+		}
+		if _, err := os.Lstat(dir); !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("%s: %s is still there", name, dir)
+		}
+		entries, err := backend.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 || entries[0].ID != EntryID(id) || entries[0].Origin != dir || !entries[0].IsDir {
+			t.Fatalf("%s: listed %+v, want %s from %s", name, entries, id, dir)
+		}
+		stats, err := backend.Stats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name == "vault" && stats.Stored >= stats.Bytes {
+			t.Fatalf("vault: stores %d bytes of %d, equal contents weren't shared", stats.Stored, stats.Bytes)
+		}
+
+		restored := dir + ".restored"
+		if err := backend.Restore(EntryID(id), restored); err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(filepath.Join(restored, "sub", "b.txt"))
+		if err != nil || string(got) != "same contents" {
+			t.Fatalf("%s: restored %q (%v)", name, got, err)
+		}
+		if err := backend.Restore(EntryID(id), dir); !errors.Is(err, ErrNoEntry) {
+			t.Fatalf("%s: restoring twice gave %v, want %v", name, err, ErrNoEntry)
+		}
+
+		// stored again and purged, taking its contents with it
+		id2, err := backend.Store(restored, EntryMeta{Origin: restored, Deleted: time.Now()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name == "vault" {
+			old := time.Now().Add(-2 * CASGRACE)
+			filepath.WalkDir(vault.(*casBackend).dir("objects"), func(path string, d fs.DirEntry, err error) error {
+				if err == nil && !d.IsDir() {
+					os.Chtimes(path, old, old)
+				}
+				return nil
+			})
+		}
+		if err := backend.Purge(id2); err != nil {
+			t.Fatal(err)
+		}
+		if stats, err := backend.Stats(); err != nil || stats.Entries != 0 {
+			t.Fatalf("%s: %d entries after purging (%v)", name, stats.Entries, err)
+		}
+	}
+	kept := 0
+	err = filepath.WalkDir(vault.(*casBackend).dir("objects"), func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			kept++
+		}
+		return err
+	})
+	if err != nil || kept > 0 {
+		t.Errorf("vault: kept %d objects nothing refers to (%v)", kept, err)
+	}
+}
@@ -0,0 +1,271 @@
+package remove
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// BATCHSIZE caps how many moves share one synced intent write and one index
+// write, which is also how many intents a crash can leave for replay
+var BATCHSIZE = 256
+
+// RemoveEach removes paths in order, calling done with each one's position
+// and Result as it finishes; covers is CoveringOperands' answer for paths,
+// and records what each removal took with it. An operand is reported
+// covered only once what holds it is gone: one inside a later operand waits
+// for it, and is attempted after all if that operand stays. Runs of
+// operands in the same directory, like a log directory being cleared out,
+// go through removeRun.
+func (r *Remover) RemoveEach(paths []string, covers *Coverage, done func(i int, result Result)) {
+	// an operand put back by u and asked about again is reported to done a
+	// second time, see undoLast
+	removeOperand := func(i int) Result {
+		r.mu.Lock()
+		r.operand.path, r.operand.report = paths[i], func(result Result) { done(i, result) }
+		r.mu.Unlock()
+		return r.Remove(paths[i])
+	}
+	defer func() {
+		r.mu.Lock()
+		r.operand.path, r.operand.report = "", nil
+		r.mu.Unlock()
+	}()
+
+	waiting := map[int][]int{}
+	var finish func(i int, result Result)
+	finish = func(i int, result Result) {
+		covers.Record(i, result)
+		done(i, result)
+		for _, k := range waiting[i] {
+			if by, ok := covers.CoveredBy(k); ok {
+				done(k, r.Covered(paths[k], by))
+			} else {
+				finish(k, removeOperand(k))
+			}
+		}
+	}
+
+	for i := 0; i < len(paths); {
+		if by, ok := covers.CoveredBy(i); ok {
+			done(i, r.Covered(paths[i], by))
+			i++
+			continue
+		}
+		if j, ok := covers.Waits(i); ok {
+			waiting[j] = append(waiting[j], i)
+			i++
+			continue
+		}
+
+		parent := OperandParent(paths[i])
+		j := i + 1
+		for j < len(paths) && !covers.Enclosed(j) && OperandParent(paths[j]) == parent {
+			j++
+		}
+		if j-i < 2 || covers.Enclosed(i) || r.opts.Permanent || r.opts.Interactive || r.opts.DryRun {
+			finish(i, removeOperand(i))
+			i++
+			continue
+		}
+		r.removeRun(parent, i, paths[i:j], finish)
+		i = j
+	}
+}
+
+// OperandParent is the directory holding path's last element
+func OperandParent(path string) string {
+	return filepath.Dir(trimSeparators(path))
+}
+
+// batchMove is an operand planned for the fast path, waiting on its batch
+type batchMove struct {
+	i    int
+	path string
+	plan Plan
+}
+
+// removeRun removes paths, which all share the directory parent, with that
+// directory held open: each move is a single renameat between it and the
+// trash, and moves are batched so a batch shares one synced intent write,
+// one index write and one done write. Operands that need anything more than
+// a plain rename, like a prompt, a warning or a read-only attribute cleared,
+// go through removePlanned in their turn.
+func (r *Remover) removeRun(parent string, first int, paths []string, done func(i int, result Result)) {
+	dir, err := r.fs.OpenDir(parent)
+	if err != nil {
+		// a parent reached through a symlink can't be held open without
+		// following it, and the slow path reports any real problem
+		for k, path := range paths {
+			done(first+k, r.Remove(path))
+		}
+		return
+	}
+	defer dir.Close()
+
+	batch := []batchMove{}
+	flush := func() {
+		r.moveBatch(dir, batch, done)
+		batch = batch[:0]
+	}
+	for k, path := range paths {
+		if r.opts.Callbacks.OnEntryStart != nil {
+			r.opts.Callbacks.OnEntryStart(path)
+		}
+		plan, err := r.Plan(path)
+		if err != nil || !r.plainRename(plan) {
+			flush()
+			result := r.removePlanned(path, plan, err, true)
+			if r.opts.Callbacks.OnEntryDone != nil {
+				r.opts.Callbacks.OnEntryDone(result)
+			}
+			done(first+k, result)
+			continue
+		}
+		batch = append(batch, batchMove{i: first + k, path: path, plan: plan})
+		if len(batch) == BATCHSIZE {
+			flush()
+		}
+	}
+	flush()
+}
+
+// plainRename reports whether plan is nothing more than a rename into the
+// trash, which is all moveBatch knows how to do
+func (r *Remover) plainRename(plan Plan) bool {
+	filtered := plan.IsDir && (r.opts.KeepHidden > 0 || r.opts.HiddenOnly > 0)
+	return plan.Strategy == "rename" && len(plan.Prompts) == 0 && len(plan.Warnings) == 0 && plan.InTrash == nil && !plan.ClearReadOnly && !filtered
+}
+
+// moveBatch moves every operand in batch into the trash relative to dir,
+// the same as removePlanned would one at a time
+func (r *Remover) moveBatch(dir Dir, batch []batchMove, done func(i int, result Result)) {
+	if len(batch) == 0 {
+		return
+	}
+	tracked := r.opts.Index != nil
+
+	results := make([]Result, len(batch))
+	entries := make([]IndexEntry, len(batch))
+	for k, move := range batch {
+		plan := move.plan
+		results[k] = Result{
+			Action: plan.Action, Source: move.path, Strategy: plan.Strategy, Dest: plan.Dest,
+			IsDir: plan.IsDir, Note: r.opts.TrashNote, Op: r.opts.Op,
+			FSType: plan.FSType, Policy: plan.Policy, Overlay: plan.Overlay,
+			Trash: plan.Trash, TrashWhy: plan.TrashWhy, Volume: plan.Volume, TrashVolume: plan.TrashVolume,
+		}
+		if r.opts.MeasureSize || (tracked && !plan.IsDir) {
+			r.runCheck("size", plan.Path, func() { results[k].Bytes, _ = DiskUsage(r.fs, plan.Path) })
+		}
+		if tracked {
+			entries[k] = r.indexEntry(plan.Path, plan)
+		}
+	}
+
+	note := func(k int, what string, err error) {
+		results[k].Note = strings.TrimPrefix(results[k].Note+"; "+what+": "+err.Error(), "; ")
+	}
+	if tracked && r.opts.Intents != nil {
+		if err := r.opts.Intents.Begin(entries...); err != nil {
+			for k := range batch {
+				note(k, "intent log", err)
+			}
+		}
+	}
+
+	settled := []string{}
+	moved := []IndexEntry{}
+	movedAt := []int{}
+	for k, move := range batch {
+		// planned before its trash turned read-only
+		if r.trashIsLost(move.plan.Trash) {
+			replan, planErr := r.Plan(move.path)
+			results[k] = r.removePlanned(move.path, replan, planErr, true)
+			if tracked {
+				settled = append(settled, entries[k].ID)
+			}
+			continue
+		}
+
+		// another srm can take the planned name first, as the srms xargs -P
+		// runs with the same names do, and the intent follows it elsewhere
+		plan := &batch[k].plan
+		retarget := func() {
+			results[k].Dest = plan.Dest
+			if tracked {
+				if err := r.retarget(&entries[k], *plan); err != nil {
+					note(k, "intent log", err)
+				}
+			}
+		}
+		start := time.Now()
+		planned := plan.Dest
+		release, err := r.claimDest(plan)
+		copied := false
+		if err == nil {
+			if plan.Dest != planned {
+				retarget()
+			}
+			err = r.moveToDest(plan, dir, &release, retarget)
+		}
+		move = batch[k]
+		if errors.Is(err, syscall.EXDEV) {
+			results[k].Strategy = "copy"
+			results[k].Bytes, copied, err = r.copyIntoTrash(move.plan, r.copyProgress(move.path))
+		}
+		results[k].Duration = time.Since(start)
+		if err != nil {
+			results[k].Action = "failed"
+			results[k].Trash, results[k].TrashWhy, results[k].Volume, results[k].TrashVolume = "", "", "", ""
+			results[k].Err = displayErr(err, move.path)
+			if !copied {
+				if release != nil {
+					release()
+				}
+				results[k].Dest = ""
+				if tracked {
+					settled = append(settled, entries[k].ID)
+				}
+				if result, ok := r.replanLost(move.path, move.plan, err, true); ok {
+					results[k] = result
+				}
+				continue
+			}
+		} else {
+			results[k].Verify = r.verifyNote(results[k].Strategy)
+			if err := recordDirectorySize(r.fs, move.plan.Dest); err != nil {
+				note(k, "directorysizes", err)
+			}
+		}
+		if tracked {
+			entries[k].Size = results[k].Bytes
+			moved = append(moved, entries[k])
+			movedAt = append(movedAt, k)
+		}
+	}
+
+	if len(moved) > 0 {
+		if err := r.opts.Index.Append(moved...); err != nil {
+			for _, k := range movedAt {
+				note(k, "index", err)
+			}
+		} else {
+			for _, entry := range moved {
+				settled = append(settled, entry.ID)
+			}
+		}
+	}
+	if r.opts.Intents != nil && len(settled) > 0 {
+		r.opts.Intents.Done(settled...)
+	}
+
+	for k, move := range batch {
+		if r.opts.Callbacks.OnEntryDone != nil {
+			r.opts.Callbacks.OnEntryDone(results[k])
+		}
+		done(move.i, results[k])
+	}
+}
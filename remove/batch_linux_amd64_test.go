@@ -0,0 +1,164 @@
+package remove
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+// BATCHFILES is how many files of one directory the syscall counts remove
+const BATCHFILES = 2000
+
+// countSyscalls runs TestBatchSyscallsChild in a child process under
+// ptrace and counts the system calls all its threads make between its two
+// getppid calls, which bracket the removal
+func countSyscalls(mode string, files int) (int, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestBatchSyscallsChild$")
+	cmd.Env = append(os.Environ(), "SRM_TEST_BATCH="+mode, "SRM_TEST_BATCHFILES="+strconv.Itoa(files))
+	cmd.SysProcAttr = &syscall.SysProcAttr{Ptrace: true}
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	pid := cmd.Process.Pid
+	var ws syscall.WaitStatus
+	if _, err := syscall.Wait4(pid, &ws, 0, nil); err != nil {
+		return 0, err
+	}
+	options := syscall.PTRACE_O_TRACECLONE | syscall.PTRACE_O_TRACESYSGOOD | 0x100000 // PTRACE_O_EXITKILL
+	if err := syscall.PtraceSetOptions(pid, options); err != nil {
+		return 0, err
+	}
+	if err := syscall.PtraceSyscall(pid, 0); err != nil {
+		return 0, err
+	}
+
+	count, markers := 0, 0
+	inSyscall := map[int]bool{}
+	for {
+		tid, err := syscall.Wait4(-1, &ws, syscall.WALL, nil)
+		if err != nil {
+			return 0, err
+		}
+		if ws.Exited() || ws.Signaled() {
+			if tid != pid {
+				continue
+			}
+			if ws.ExitStatus() != 0 {
+				return 0, fmt.Errorf("the child failed with status %d", ws.ExitStatus())
+			}
+			break
+		}
+		if !ws.Stopped() {
+			continue
+		}
+		signal := 0
+		switch sig := ws.StopSignal(); {
+		case sig == syscall.SIGTRAP|0x80:
+			inSyscall[tid] = !inSyscall[tid]
+			if inSyscall[tid] {
+				var regs syscall.PtraceRegs
+				if err := syscall.PtraceGetRegs(tid, &regs); err == nil {
+					if regs.Orig_rax == syscall.SYS_GETPPID {
+						markers++
+					} else if markers == 1 {
+						count++
+					}
+				}
+			}
+		case sig == syscall.SIGTRAP, sig == syscall.SIGSTOP:
+			// a new thread starting, or a ptrace event
+		default:
+			signal = int(sig)
+		}
+		syscall.PtraceSyscall(tid, signal)
+	}
+	if markers != 2 {
+		return 0, fmt.Errorf("saw %d of the child's 2 markers", markers)
+	}
+	return count, nil
+}
+
+// TestBatchSyscallsChild is the child countSyscalls traces, and does
+// nothing in a plain go test run
+func TestBatchSyscallsChild(t *testing.T) {
+	mode := os.Getenv("SRM_TEST_BATCH")
+	if mode == "" {
+		t.Skip("run by countSyscalls")
+	}
+	files, _ := strconv.Atoi(os.Getenv("SRM_TEST_BATCHFILES"))
+	env := testEnv(t)
+	paths := []string{}
+	for i := 0; i < files; i++ {
+		path, err := env.file(filepath.Join("logs", fmt.Sprintf("app.log.%d", i)), "x")
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	r := env.remover(false)
+
+	syscall.Getppid()
+	switch mode {
+	case "batched":
+		if _, err := r.RemoveAll(paths); err != nil {
+			t.Fatal(err)
+		}
+	case "one-by-one":
+		for _, path := range paths {
+			if result := r.Remove(path); result.Err != nil {
+				t.Fatal(result.Err)
+			}
+		}
+	}
+	syscall.Getppid()
+}
+
+// Removing a directory's worth of files as one batch takes well under half
+// the system calls of removing them one at a time
+func TestBatchSyscalls(t *testing.T) {
+	if testing.Short() {
+		t.Skip("spawns traced processes")
+	}
+	counts := map[string]int{}
+	for _, mode := range []string{"batched", "one-by-one"} {
+		count, err := countSyscalls(mode, BATCHFILES)
+		if errors.Is(err, syscall.EPERM) {
+			t.Skipf("can't trace a child here: %v", err)
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		counts[mode] = count
+	}
+	t.Logf("%d files: %d syscalls batched, %d one by one", BATCHFILES, counts["batched"], counts["one-by-one"])
+	if counts["batched"]*2 > counts["one-by-one"] {
+		t.Errorf("batched removal made %d syscalls, not under half of one by one's %d", counts["batched"], counts["one-by-one"])
+	}
+}
+
+// BenchmarkBatchSyscalls reports syscalls/file for either way of removing
+// the files of one directory
+func BenchmarkBatchSyscalls(b *testing.B) {
+	for _, mode := range []string{"batched", "one-by-one"} {
+		b.Run(mode, func(b *testing.B) {
+			total := 0
+			for i := 0; i < b.N; i++ {
+				count, err := countSyscalls(mode, BATCHFILES)
+				if err != nil {
+					b.Skip(err)
+				}
+				total += count
+			}
+			b.ReportMetric(float64(total)/float64(b.N*BATCHFILES), "syscalls/file")
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package remove
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"syscall"
+)
+
+// removalPiece is one removal of --biggest-first: a whole operand, or one
+// entry of a directory operand
+type removalPiece struct {
+	path    string
+	size    int64
+	operand int
+}
+
+// biggestFirstPieces splits the recursive directory operands among paths
+// into their entries and sizes everything once, biggest first. split lists
+// the directory operands that were split, to be removed once empty.
+func biggestFirstPieces(fsys FS, paths []string, covers *Coverage, recursive bool) (pieces []removalPiece, split []int) {
+	for i, path := range paths {
+		if covers.Enclosed(i) {
+			continue
+		}
+		fi, err := fsys.Lstat(path)
+		if err != nil || !fi.IsDir() || !recursive {
+			// the removal itself reports whatever is wrong with it
+			size, _ := DiskUsage(fsys, path)
+			pieces = append(pieces, removalPiece{path: path, size: size, operand: i})
+			continue
+		}
+
+		entries, err := fsys.ReadDir(path)
+		if err != nil {
+			size, _ := DiskUsage(fsys, path)
+			pieces = append(pieces, removalPiece{path: path, size: size, operand: i})
+			continue
+		}
+		for _, de := range entries {
+			child := filepath.Join(path, de.Name())
+			size, _ := DiskUsage(fsys, child)
+			pieces = append(pieces, removalPiece{path: child, size: size, operand: i})
+		}
+		split = append(split, i)
+	}
+
+	sort.SliceStable(pieces, func(i, j int) bool { return pieces[i].size > pieces[j].size })
+	return pieces, split
+}
+
+// RemoveBiggestFirst is RemoveEach for --biggest-first: every piece from
+// biggestFirstPieces is removed on its own, biggest first across operands,
+// then the directories they came out of. done gets the operand each
+// Result belongs to, and progress the bytes handled so far out of the total.
+// Operands inside another operand come last, covered if it went and removed
+// on their own if it stayed. An interrupt stops it between pieces; what was
+// never attempted is returned, in the order it would have gone.
+func (r *Remover) RemoveBiggestFirst(paths []string, covers *Coverage, done func(i int, result Result), progress func(handled, total int64)) (left []string) {
+	pieces, split := biggestFirstPieces(r.fs, paths, covers, r.opts.Recursive)
+	// a piece's canonical path is taken while the symlinks above it are
+	// still there to resolve
+	canonical := make([]string, len(pieces))
+	for k, piece := range pieces {
+		canonical[k] = CanonicalOperand(piece.path)
+	}
+
+	var total, handled int64
+	for _, piece := range pieces {
+		total += piece.size
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	emptied := map[int]bool{}
+	for _, i := range split {
+		emptied[i] = true
+	}
+	for k, piece := range pieces {
+		select {
+		case <-sigs:
+			for _, rest := range pieces[k:] {
+				left = append(left, rest.path)
+			}
+			for _, i := range split {
+				left = append(left, paths[i])
+			}
+			for i, path := range paths {
+				if covers.Enclosed(i) {
+					left = append(left, path)
+				}
+			}
+			return left
+		default:
+		}
+
+		result := r.Remove(piece.path)
+		if result.Err != nil {
+			// the directory can't be emptied, so it stays
+			emptied[piece.operand] = false
+		}
+		covers.record(canonical[k], piece.operand, result)
+		done(piece.operand, result)
+		handled += piece.size
+		if progress != nil {
+			progress(handled, total)
+		}
+	}
+
+	for _, i := range split {
+		if emptied[i] {
+			result := r.Remove(paths[i])
+			covers.Record(i, result)
+			done(i, result)
+		}
+	}
+	for i, path := range paths {
+		if !covers.Enclosed(i) {
+			continue
+		}
+		if by, ok := covers.CoveredBy(i); ok {
+			done(i, r.Covered(path, by))
+			continue
+		}
+		result := r.Remove(path)
+		covers.Record(i, result)
+		done(i, result)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package remove
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// ExtractMember writes one tar member to rel below root, creating parents
+// as needed. rel is the member's clean slash-separated path inside root. A
+// member outside root, or reached through a symlink an earlier member
+// planted, is refused with ErrUnsafeMember, as is one landing on something
+// already there other than a directory for a directory. Directories are
+// left writable by us, callers apply their modes afterwards.
+func ExtractMember(fsys FS, r io.Reader, hdr *tar.Header, root string, rel string) error {
+	if !filepath.IsLocal(filepath.FromSlash(rel)) {
+		return fmt.Errorf("%s: %w", hdr.Name, ErrUnsafeMember)
+	}
+
+	// one component at a time, so no symlink is ever followed; root is
+	// private to us, so nothing can be swapped in behind the check
+	parts := strings.Split(rel, "/")
+	dir := root
+	for _, part := range parts[:len(parts)-1] {
+		dir = filepath.Join(dir, part)
+		fi, err := fsys.Lstat(dir)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			err = fsys.Mkdir(dir, 0700)
+		case err == nil && !fi.IsDir():
+			err = fmt.Errorf("%s: %w", hdr.Name, ErrUnsafeMember)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	dest := filepath.Join(dir, parts[len(parts)-1])
+	fi, err := fsys.Lstat(dest)
+	if err == nil && (hdr.Typeflag != tar.TypeDir || !fi.IsDir()) {
+		return fmt.Errorf("%s: %w", hdr.Name, ErrUnsafeMember)
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		if err == nil {
+			return nil
+		}
+		return fsys.Mkdir(dest, 0700)
+	case tar.TypeSymlink:
+		return fsys.Symlink(hdr.Linkname, dest)
+	case tar.TypeReg:
+		f, err := fsys.Create(dest)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, r)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		if err := fsys.Chmod(dest, TarMode(hdr)); err != nil {
+			return err
+		}
+		return fsys.Chtimes(dest, hdr.ModTime, hdr.ModTime)
+	}
+
+	return errors.New(hdr.Name + ": unsupported member type")
+}
+
+// TarMode is hdr's mode as an fs.FileMode, keeping the setuid, setgid and
+// sticky bits that Perm drops
+func TarMode(hdr *tar.Header) fs.FileMode {
+	return chmodBits(hdr.FileInfo().Mode())
+}
+
+// RestoreDirModes gives every directory below root the mode modes recorded
+// for it, or 0755 if it was only created as a parent of something, innermost
+// first. Modes are set with chmod after the tree is filled, so neither the
+// umask nor a read-only directory gets in the way.
+func RestoreDirModes(fsys FS, root string, modes map[string]fs.FileMode) error {
+	dirs := []string{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path != root {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		mode, ok := modes[dirs[i]]
+		if !ok {
+			mode = 0755
+		}
+		if err := fsys.Chmod(dirs[i], mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
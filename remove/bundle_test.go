@@ -0,0 +1,146 @@
+package remove
+
+import (
+	"archive/tar"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// member is one tar member a test writes: a directory, a symlink to link,
+// or a file holding body
+type member struct {
+	name string
+	typ  byte
+	link string
+	body string
+}
+
+// writeMembers writes members to tw, in order
+func writeMembers(t *testing.T, tw *tar.Writer, members []member) {
+	t.Helper()
+	for _, m := range members {
+		hdr := &tar.Header{Name: m.name, Typeflag: m.typ, Linkname: m.link, Mode: 0644, Size: int64(len(m.body))}
+		if m.typ == tar.TypeDir {
+			hdr.Mode = 0755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(m.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// A member can't be written outside the directory it is unpacked into,
+// whether by its name or through a symlink an earlier member left
+func TestExtractMemberUnsafe(t *testing.T) {
+	tests := []struct {
+		name    string
+		members []member
+	}{
+		{"a member above root", []member{
+			{name: "../owned", typ: tar.TypeReg, body: "x"}}},
+		{"an absolute member", []member{
+			{name: "/owned", typ: tar.TypeReg, body: "x"}}},
+		{"a file through a symlinked directory", []member{
+			{name: "x", typ: tar.TypeSymlink, link: "VICTIM"},
+			{name: "x/owned", typ: tar.TypeReg, body: "x"}}},
+		{"a directory through a symlinked directory", []member{
+			{name: "x", typ: tar.TypeSymlink, link: "VICTIM"},
+			{name: "x/owned/", typ: tar.TypeDir}}},
+		{"a file deeper through a symlinked directory", []member{
+			{name: "d/", typ: tar.TypeDir},
+			{name: "d/x", typ: tar.TypeSymlink, link: "VICTIM"},
+			{name: "d/x/sub/owned", typ: tar.TypeReg, body: "x"}}},
+		{"a file over a symlink to a file", []member{
+			{name: "x", typ: tar.TypeSymlink, link: "VICTIM/owned"},
+			{name: "x", typ: tar.TypeReg, body: "x"}}},
+		{"a directory over a symlink", []member{
+			{name: "x", typ: tar.TypeSymlink, link: "VICTIM"},
+			{name: "x/", typ: tar.TypeDir}}},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		root, victim := filepath.Join(dir, "root"), filepath.Join(dir, "victim")
+		for _, d := range []string{root, victim} {
+			if err := os.Mkdir(d, 0700); err != nil {
+				t.Fatal(err)
+			}
+		}
+		tarball := filepath.Join(dir, "t.tar")
+		f, err := os.Create(tarball)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range tt.members {
+			tt.members[i].link = strings.Replace(tt.members[i].link, "VICTIM", victim, 1)
+		}
+		writeMembers(t, tar.NewWriter(f), tt.members)
+		f.Close()
+
+		f, _ = os.Open(tarball)
+		tr := tar.NewReader(f)
+		err = nil
+		for err == nil {
+			var hdr *tar.Header
+			if hdr, err = tr.Next(); err == nil {
+				err = ExtractMember(OSFS{}, tr, hdr, root, strings.TrimSuffix(hdr.Name, "/"))
+			}
+		}
+		f.Close()
+		if !errors.Is(err, ErrUnsafeMember) {
+			t.Errorf("%s: got %v, want ErrUnsafeMember", tt.name, err)
+		}
+		if _, err := os.Lstat(filepath.Join(victim, "owned")); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s: wrote outside root", tt.name)
+		}
+		if _, err := os.Lstat(filepath.Join(dir, "owned")); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s: wrote above root", tt.name)
+		}
+	}
+}
+
+// Symlinks are still unpacked as symlinks, and a directory's members go
+// into it however many times it appears
+func TestExtractMember(t *testing.T) {
+	dir := t.TempDir()
+	tarball := filepath.Join(dir, "t.tar")
+	f, err := os.Create(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeMembers(t, tar.NewWriter(f), []member{
+		{name: "d/", typ: tar.TypeDir},
+		{name: "d/link", typ: tar.TypeSymlink, link: "/etc/passwd"},
+		{name: "d/", typ: tar.TypeDir},
+		{name: "d/e/f", typ: tar.TypeReg, body: "contents"},
+	})
+	f.Close()
+
+	f, _ = os.Open(tarball)
+	defer f.Close()
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		if err := ExtractMember(OSFS{}, tr, hdr, dir, strings.TrimSuffix(hdr.Name, "/")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if link, err := os.Readlink(filepath.Join(dir, "d", "link")); err != nil || link != "/etc/passwd" {
+		t.Errorf("d/link is %q, %v", link, err)
+	}
+	if got, err := os.ReadFile(filepath.Join(dir, "d", "e", "f")); err != nil || string(got) != "contents" {
+		t.Errorf("d/e/f holds %q, %v", got, err)
+	}
+}
@@ -0,0 +1,131 @@
+package remove
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OPTIONALCHECKS are the pre-checks that only inform: what a warning says,
+// how an entry is described or the size recorded with it, never whether it
+// goes. --fast turns them all off and check_budget[NAME] caps each one.
+// A check is made optional by running it through Remover.runCheck.
+var OPTIONALCHECKS = []string{"exec", "overlay", "size"}
+
+// CheckBudgets reads the check_budget[NAME] = DURATION keys from config
+func CheckBudgets(config Config) (map[string]time.Duration, error) {
+	budgets := map[string]time.Duration{}
+	for key, value := range config {
+		name, ok := strings.CutPrefix(key, "check_budget[")
+		if !ok || !strings.HasSuffix(name, "]") {
+			continue
+		}
+		name = strings.TrimSuffix(name, "]")
+		if !In(name, OPTIONALCHECKS) {
+			return nil, fmt.Errorf("%s: no such check, expected one of %s", key, strings.Join(OPTIONALCHECKS, ", "))
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%s: expected a duration like 200ms, got %q", key, value)
+		}
+		budgets[name] = d
+	}
+	return budgets, nil
+}
+
+// checkTimes is what each optional check has cost the run so far
+type checkTimes struct {
+	mu    sync.Mutex
+	spent map[string]time.Duration
+	files map[string]int
+	// over holds the checks that went over their budget, skipped from then on
+	over map[string]bool
+}
+
+func newCheckTimes() *checkTimes {
+	return &checkTimes{spent: map[string]time.Duration{}, files: map[string]int{}, over: map[string]bool{}}
+}
+
+// runCheck runs the optional check name for path, timing it, and reports
+// whether it ran. Under --fast it never does, and once one file takes a
+// check longer than its check_budget the check is skipped for the rest of
+// the run, with a note saying so.
+func (r *Remover) runCheck(name string, path string, check func()) bool {
+	if r.opts.Fast {
+		return false
+	}
+	times := r.checks
+	times.mu.Lock()
+	over := times.over[name]
+	times.mu.Unlock()
+	if over {
+		return false
+	}
+
+	start := time.Now()
+	check()
+	took := time.Since(start)
+
+	times.mu.Lock()
+	defer times.mu.Unlock()
+	times.spent[name] += took
+	times.files[name]++
+	if budget, ok := r.opts.CheckBudgets[name]; ok && took > budget && !times.over[name] {
+		times.over[name] = true
+		fmt.Fprintf(os.Stderr, "srm: note: the %s check took %s on %s, over its check_budget of %s; skipping it from here on\n",
+			name, roundDuration(took), DisplayName(DisplayPath(path)), budget)
+	}
+	return true
+}
+
+// CheckTimes says how long each optional check that ran took, as in
+// "exec check: 1.9s across 4,200 files", in OPTIONALCHECKS order
+func (r *Remover) CheckTimes() []string {
+	times := r.checks
+	times.mu.Lock()
+	defer times.mu.Unlock()
+	lines := []string{}
+	for _, name := range OPTIONALCHECKS {
+		if times.files[name] == 0 {
+			continue
+		}
+		files := formatCount(times.files[name]) + " files"
+		if times.files[name] == 1 {
+			files = "1 file"
+		}
+		line := fmt.Sprintf("%s check: %s across %s", name, roundDuration(times.spent[name]), files)
+		if times.over[name] {
+			line += " (then skipped, over its budget)"
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// roundDuration keeps two or so significant digits of d, as in 1.9s, 340ms
+// or 12µs
+func roundDuration(d time.Duration) time.Duration {
+	switch {
+	case d >= 10*time.Second:
+		return d.Round(time.Second)
+	case d >= time.Second:
+		return d.Round(100 * time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(time.Microsecond)
+}
+
+// formatCount writes n with thousands separators, as in 4,200
+func formatCount(n int) string {
+	if n < 0 {
+		return "-" + formatCount(-n)
+	}
+	digits := fmt.Sprint(n)
+	for i := len(digits) - 3; i > 0; i -= 3 {
+		digits = digits[:i] + "," + digits[i:]
+	}
+	return digits
+}
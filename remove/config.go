@@ -0,0 +1,295 @@
+package remove
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// system wide config, read before the user's own
+var SYSTEMCONFIG = "/etc/srm/config"
+
+// CONFIGENV names the environment variable that points at another user
+// config file, as tests do
+const CONFIGENV = "SRM_CONFIG"
+
+// UserConfigPath is the user's config file, which overrides the system one
+// key by key except where the system config locks a key
+func UserConfigPath() (string, error) {
+	if path := os.Getenv(CONFIGENV); path != "" {
+		return path, nil
+	}
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "srm", "config"), nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".config", "srm", "config"), nil
+}
+
+// Config is a parsed config file: key = value lines, blank lines and
+// # comments ignored, values optionally double quoted
+type Config map[string]string
+
+// ReadConfig parses the config file at path. A missing file is an empty
+// config, anything malformed is an error naming the line.
+func ReadConfig(path string) (Config, error) {
+	config := Config{}
+
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s:%d: expected key = value, got %q", path, lineNo, line)
+		}
+
+		value = strings.TrimSpace(value)
+		if strings.HasPrefix(value, `"`) {
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: bad quoted value for %s", path, lineNo, key)
+			}
+			value = unquoted
+		}
+
+		config[key] = value
+	}
+
+	return config, scanner.Err()
+}
+
+// Bool reads key as a boolean, false when unset
+func (c Config) Bool(key string) (bool, error) {
+	value, ok := c[key]
+	if !ok {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s: expected true or false, got %q", key, value)
+	}
+	return b, nil
+}
+
+// Path reads key as an absolute path, "" when unset. A leading ~/ is the
+// home directory; a relative path would depend on where srm is run, so it
+// is an error.
+func (c Config) Path(key string) (string, error) {
+	value, ok := c[key]
+	if !ok || value == "" {
+		return "", nil
+	}
+	if rest, ok := strings.CutPrefix(value, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", key, err)
+		}
+		value = filepath.Join(home, rest)
+	}
+	if !filepath.IsAbs(value) {
+		return "", fmt.Errorf("%s: expected an absolute path or one starting ~/, got %q", key, value)
+	}
+	return filepath.Clean(value), nil
+}
+
+// Size reads key as a size like 500M or 2GB, 0 when unset
+func (c Config) Size(key string) (int64, error) {
+	value, ok := c[key]
+	if !ok {
+		return 0, nil
+	}
+	size, err := ParseSize(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+	return size, nil
+}
+
+// List reads key as a list, either ["a", "b"] or a bare a, b
+func (c Config) List(key string) ([]string, error) {
+	value, ok := c[key]
+	if !ok {
+		return nil, nil
+	}
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "[") {
+		if !strings.HasSuffix(value, "]") {
+			return nil, fmt.Errorf("%s: unterminated list %q", key, value)
+		}
+		value = value[1 : len(value)-1]
+	}
+
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if strings.HasPrefix(item, `"`) {
+			unquoted, err := strconv.Unquote(item)
+			if err != nil {
+				return nil, fmt.Errorf("%s: bad quoted item %s", key, item)
+			}
+			item = unquoted
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// Settings is the system config with the user's layered on top
+type Settings struct {
+	Config Config
+	// Source names the file each key's effective value came from
+	Source map[string]string
+	// Locked keys are fixed by the system config, Overridden holds user
+	// values that were ignored because of that
+	Locked     map[string]bool
+	Overridden map[string]string
+}
+
+// the settings are read once per run, and again only when a config file
+// changes, as srm config set and the selftest's configs do; callers don't
+// change what they are handed
+var settingsCache struct {
+	sync.Mutex
+	stamp    string
+	settings *Settings
+}
+
+// configStamp tells the config files apart from how they were when the
+// settings were last read
+func configStamp() string {
+	paths := []string{SYSTEMCONFIG}
+	if userPath, err := UserConfigPath(); err == nil {
+		paths = append(paths, userPath)
+	}
+	stamp := ""
+	for _, path := range paths {
+		stamp += path + "\x00"
+		if fi, err := os.Stat(path); err == nil {
+			stamp += fmt.Sprintf("%d %d\x00", fi.Size(), fi.ModTime().UnixNano())
+		}
+	}
+	return stamp
+}
+
+// LoadSettings returns the system and user config merged, read the first
+// time they are needed
+func LoadSettings() (*Settings, error) {
+	stamp := configStamp()
+	settingsCache.Lock()
+	defer settingsCache.Unlock()
+	if settingsCache.settings != nil && settingsCache.stamp == stamp {
+		return settingsCache.settings, nil
+	}
+	settings, err := readSettings()
+	if err != nil {
+		return nil, err
+	}
+	settingsCache.stamp, settingsCache.settings = stamp, settings
+	return settings, nil
+}
+
+// readSettings reads and merges the system and user config
+func readSettings() (*Settings, error) {
+	system, err := ReadConfig(SYSTEMCONFIG)
+	if err != nil {
+		return nil, err
+	}
+
+	// without HOME there is no user config, only the system one
+	user := Config{}
+	userPath, err := UserConfigPath()
+	if err == nil {
+		if user, err = ReadConfig(userPath); err != nil {
+			return nil, err
+		}
+	}
+
+	return mergeSettings(system, SYSTEMCONFIG, user, userPath)
+}
+
+// mergeSettings lets every user key override the system one, except keys
+// named in the system config's locked list and locked itself
+func mergeSettings(system Config, systemPath string, user Config, userPath string) (*Settings, error) {
+	s := &Settings{
+		Config:     Config{},
+		Source:     map[string]string{},
+		Locked:     map[string]bool{"locked": true},
+		Overridden: map[string]string{},
+	}
+
+	locked, err := system.List("locked")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", systemPath, err)
+	}
+	for _, key := range locked {
+		s.Locked[key] = true
+	}
+
+	for key, value := range system {
+		s.Config[key], s.Source[key] = value, systemPath
+	}
+	for key, value := range user {
+		if s.Locked[key] {
+			s.Overridden[key] = value
+			continue
+		}
+		s.Config[key], s.Source[key] = value, userPath
+	}
+
+	return s, nil
+}
+
+// Keys returns every effective key in order
+func (s *Settings) Keys() []string {
+	keys := []string{}
+	for key := range s.Config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Describe says where key's value comes from, for srm config and doctor
+func (s *Settings) Describe(key string) string {
+	source, ok := s.Source[key]
+	if !ok {
+		return "default"
+	}
+	if s.Locked[key] {
+		source += ", locked"
+	}
+	if value, ok := s.Overridden[key]; ok {
+		source += fmt.Sprintf(", user value %q ignored", value)
+	}
+	return source
+}
@@ -0,0 +1,237 @@
+package remove
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReadConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want Config
+		err  string
+	}{
+		{"empty", "", Config{}, ""},
+		{"comments and blank lines", "# a comment\n\n   \n  # indented\n", Config{}, ""},
+		{"bare values", "retention = 30d\nsafe_mode=true\n", Config{"retention": "30d", "safe_mode": "true"}, ""},
+		{"quoted value", `trash_dir = "/data/my trash"` + "\n", Config{"trash_dir": "/data/my trash"}, ""},
+		{"quoted escapes", `reason = "a \"b\"\tc"` + "\n", Config{"reason": "a \"b\"\tc"}, ""},
+		{"value with =", "x = a=b\n", Config{"x": "a=b"}, ""},
+		{"empty value", "x =\n", Config{"x": ""}, ""},
+		{"later line wins", "x = 1\nx = 2\n", Config{"x": "2"}, ""},
+		{"no newline at the end", "x = 1", Config{"x": "1"}, ""},
+		{"list kept as text", `locked = ["safe_mode", "protected"]`, Config{"locked": `["safe_mode", "protected"]`}, ""},
+		{"no =", "x = 1\nnonsense\n", nil, ":2: expected key = value"},
+		{"no key", " = 1\n", nil, ":1: expected key = value"},
+		{"bad quotes", "x = \"open\n", nil, ":1: bad quoted value for x"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "config")
+		if err := os.WriteFile(path, []byte(tt.text), 0600); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ReadConfig(path)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: error %v, want one with %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if !maps.Equal(got, tt.want) {
+			t.Errorf("%s: read %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	got, err := ReadConfig(filepath.Join(t.TempDir(), "missing"))
+	if err != nil || len(got) != 0 {
+		t.Errorf("a missing config reads as %q, %v, want it empty", got, err)
+	}
+}
+
+func TestConfigList(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+		err   string
+	}{
+		{`["safe_mode", "protected"]`, []string{"safe_mode", "protected"}, ""},
+		{`safe_mode, protected`, []string{"safe_mode", "protected"}, ""},
+		{`[]`, []string{}, ""},
+		{`["a, b", c]`, nil, "bad quoted item"},
+		{`[a, , b,]`, []string{"a", "b"}, ""},
+		{`["/x y"]`, []string{"/x y"}, ""},
+		{`[a, b`, nil, "unterminated list"},
+	}
+	for _, tt := range tests {
+		got, err := Config{"k": tt.value}.List("k")
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("List(%s): error %v, want one with %q", tt.value, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("List(%s) = %q, %v, want %q", tt.value, got, err, tt.want)
+		}
+	}
+	if got, err := (Config{}).List("k"); got != nil || err != nil {
+		t.Errorf("List of an unset key = %q, %v, want nil", got, err)
+	}
+}
+
+func TestMergeSettings(t *testing.T) {
+	const sys, usr = "/etc/srm/config", "/home/u/.config/srm/config"
+	tests := []struct {
+		name       string
+		system     Config
+		user       Config
+		config     Config
+		describe   map[string]string
+		overridden map[string]string
+	}{
+		{
+			name:     "nothing set",
+			config:   Config{},
+			describe: map[string]string{"safe_mode": "default"},
+		},
+		{
+			name:     "system only",
+			system:   Config{"retention": "30d"},
+			config:   Config{"retention": "30d"},
+			describe: map[string]string{"retention": sys},
+		},
+		{
+			name:     "user only",
+			user:     Config{"retention": "7d"},
+			config:   Config{"retention": "7d"},
+			describe: map[string]string{"retention": usr},
+		},
+		{
+			name:     "user overrides system key by key",
+			system:   Config{"retention": "30d", "safe_mode": "true"},
+			user:     Config{"retention": "7d"},
+			config:   Config{"retention": "7d", "safe_mode": "true"},
+			describe: map[string]string{"retention": usr, "safe_mode": sys},
+		},
+		{
+			name:       "locked key wins",
+			system:     Config{"locked": "[safe_mode]", "safe_mode": "true", "retention": "30d"},
+			user:       Config{"safe_mode": "false", "retention": "7d"},
+			config:     Config{"locked": "[safe_mode]", "safe_mode": "true", "retention": "7d"},
+			describe:   map[string]string{"safe_mode": sys + `, locked, user value "false" ignored`, "retention": usr},
+			overridden: map[string]string{"safe_mode": "false"},
+		},
+		{
+			name:       "locked but unset keeps the default",
+			system:     Config{"locked": `["protected"]`},
+			user:       Config{"protected": "/"},
+			config:     Config{"locked": `["protected"]`},
+			describe:   map[string]string{"protected": "default"},
+			overridden: map[string]string{"protected": "/"},
+		},
+		{
+			name:       "locked can't be unlocked by the user",
+			system:     Config{"locked": "safe_mode", "safe_mode": "true"},
+			user:       Config{"locked": "", "safe_mode": "false"},
+			config:     Config{"locked": "safe_mode", "safe_mode": "true"},
+			describe:   map[string]string{"locked": sys + `, locked, user value "" ignored`},
+			overridden: map[string]string{"locked": "", "safe_mode": "false"},
+		},
+		{
+			name:       "a user locked list locks nothing",
+			system:     Config{"safe_mode": "true"},
+			user:       Config{"locked": "[safe_mode]", "safe_mode": "false"},
+			config:     Config{"safe_mode": "false"},
+			describe:   map[string]string{"safe_mode": usr},
+			overridden: map[string]string{"locked": "[safe_mode]"},
+		},
+	}
+	for _, tt := range tests {
+		system, user := tt.system, tt.user
+		if system == nil {
+			system = Config{}
+		}
+		if user == nil {
+			user = Config{}
+		}
+		s, err := mergeSettings(system, sys, user, usr)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !maps.Equal(s.Config, tt.config) {
+			t.Errorf("%s: settings %q, want %q", tt.name, s.Config, tt.config)
+		}
+		for key, want := range tt.describe {
+			if got := s.Describe(key); got != want {
+				t.Errorf("%s: Describe(%s) = %q, want %q", tt.name, key, got, want)
+			}
+		}
+		if tt.overridden == nil {
+			tt.overridden = map[string]string{}
+		}
+		if !maps.Equal(s.Overridden, tt.overridden) {
+			t.Errorf("%s: ignored user values %q, want %q", tt.name, s.Overridden, tt.overridden)
+		}
+		if !slices.IsSorted(s.Keys()) || len(s.Keys()) != len(tt.config) {
+			t.Errorf("%s: Keys() = %q", tt.name, s.Keys())
+		}
+	}
+
+	_, err := mergeSettings(Config{"locked": "[a"}, sys, Config{}, usr)
+	if err == nil || !strings.HasPrefix(err.Error(), sys+": locked: unterminated list") {
+		t.Errorf("a bad locked list: error %v, want one naming %s", err, sys)
+	}
+}
+
+// readSettings and loadSettings read the two files, and read them again
+// only once one changes
+func TestLoadSettings(t *testing.T) {
+	dir := t.TempDir()
+	systemPath, userPath := filepath.Join(dir, "system"), filepath.Join(dir, "user")
+	oldSystem := SYSTEMCONFIG
+	SYSTEMCONFIG = systemPath
+	defer func() { SYSTEMCONFIG = oldSystem }()
+	t.Setenv(CONFIGENV, userPath)
+
+	write := func(path, text string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(text), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(systemPath, "locked = [safe_mode]\nsafe_mode = true\nretention = 30d\n")
+	write(userPath, "safe_mode = false\nretention = 7d\n")
+
+	s, err := LoadSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Config["safe_mode"] != "true" || s.Config["retention"] != "7d" {
+		t.Errorf("merged settings %q", s.Config)
+	}
+	if again, _ := LoadSettings(); again != s {
+		t.Errorf("settings read again though neither file changed")
+	}
+
+	write(userPath, "retention = 14d\nextra = 1\n")
+	s, err = LoadSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Config["retention"] != "14d" || s.Source["extra"] != userPath {
+		t.Errorf("a changed user config wasn't read again: %q", s.Config)
+	}
+
+	write(systemPath, "oops\n")
+	if _, err := LoadSettings(); err == nil || !strings.Contains(err.Error(), systemPath+":1:") {
+		t.Errorf("a broken system config: error %v, want one naming its line", err)
+	}
+}
@@ -0,0 +1,148 @@
+package remove
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+)
+
+// chmodBits is the part of mode chmod sets: the permissions along with the
+// setuid, setgid and sticky bits that Perm drops
+func chmodBits(mode fs.FileMode) fs.FileMode {
+	return mode & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+}
+
+// copyTree copies path to dest for a move that can't be a rename, dest
+// being on another filesystem. Directories are recreated and walked,
+// regular files copied and synced, symlinks recreated, and everything keeps
+// its mode and times; anything else, like a fifo or a device, can't be
+// copied and fails the whole copy. The copy is built at dest+".partial" and
+// renamed into place once complete, so dest never holds half of it, and
+// never over whatever got to dest first. size is
+// the bytes of file contents copied, reported to progress as they go. With
+// verify every file is read back and checked too, see copyFile, and progress
+// counts those reads as well.
+func copyTree(fsys FS, path string, dest string, progress func(int64), verify bool) (size int64, err error) {
+	partial := dest + ".partial"
+	defer func() {
+		if err != nil {
+			fsys.RemoveAll(partial)
+		}
+	}()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	counter := &progressWriter{w: io.Discard, fn: progress}
+
+	var walk func(from, to string) error
+	walk = func(from, to string) error {
+		select {
+		case <-sigs:
+			return errInterrupted
+		default:
+		}
+
+		fi, err := fsys.Lstat(from)
+		if err != nil {
+			return err
+		}
+
+		switch mode := fi.Mode(); {
+		case mode&fs.ModeSymlink != 0:
+			// symlink times can't be set portably, so only the link is kept
+			link, err := fsys.Readlink(from)
+			if err != nil {
+				return err
+			}
+			return fsys.Symlink(link, to)
+		case mode.IsRegular():
+			n, err := copyFile(fsys, from, to, counter, verify)
+			size += n
+			if err != nil {
+				return err
+			}
+		case mode.IsDir():
+			// writable until its entries are in, whatever mode it ends up with
+			if err := fsys.Mkdir(to, 0700); err != nil {
+				return err
+			}
+			children, err := fsys.ReadDir(from)
+			if err != nil {
+				return err
+			}
+			for _, child := range children {
+				if err := walk(filepath.Join(from, child.Name()), filepath.Join(to, child.Name())); err != nil {
+					return err
+				}
+			}
+		default:
+			return fmt.Errorf("%s: a %s can't be copied to another filesystem", DisplayPath(from), fileTypePhrase(fi))
+		}
+
+		if err := fsys.Chmod(to, chmodBits(fi.Mode())); err != nil {
+			return err
+		}
+		times := fileTimes(from, fi)
+		if times.Accessed.IsZero() {
+			times.Accessed = times.Modified
+		}
+		return fsys.Chtimes(to, times.Accessed, times.Modified)
+	}
+
+	if err = walk(path, partial); err != nil {
+		return size, err
+	}
+	return size, fsys.RenameNoReplace(partial, dest)
+}
+
+// copyFile copies the regular file from to the new file to through w,
+// synced before it is closed. With verify, to is then read back through w
+// as well and its SHA-256 compared with the one taken of what was written,
+// so from is only read once; a mismatch fails with ErrVerifyFailed.
+func copyFile(fsys FS, from string, to string, w io.Writer, verify bool) (int64, error) {
+	in, err := fsys.Open(from)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	out, err := fsys.Create(to)
+	if err != nil {
+		return 0, err
+	}
+	written := sha256.New()
+	n, err := io.Copy(io.MultiWriter(out, w, written), in)
+	if err != nil {
+		out.Close()
+		return n, err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return n, err
+	}
+	if err := out.Close(); err != nil || !verify {
+		return n, err
+	}
+
+	back, err := fsys.Open(to)
+	if err != nil {
+		return n, err
+	}
+	defer back.Close()
+	read := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(read, w), back); err != nil {
+		return n, err
+	}
+	if !bytes.Equal(read.Sum(nil), written.Sum(nil)) {
+		return n, fmt.Errorf("%s: %w", DisplayPath(from), ErrVerifyFailed)
+	}
+	return n, nil
+}
@@ -0,0 +1,117 @@
+package remove
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"syscall"
+)
+
+// removeInteractive is -i with -r for the directory operand dir, walked as
+// rm walks it rather than moved whole: it asks before descending into each
+// directory that has anything in it, then about each entry inside, each
+// removed as its own operand, and then about the directory itself. A
+// directory not descended into stays with everything in it, and the ones
+// above it aren't asked about, since they can't be emptied. One left
+// holding an entry that was declined or failed fails with ENOTEMPTY, as rm
+// does, asked about first only under --posix. The result is for dir.
+func (r *Remover) removeInteractive(dir string, plan Plan) Result {
+	r.dropDestination(plan)
+	result, _ := r.descend(dir, true)
+	return result
+}
+
+// dropDestination gives back the trash name plan took, for a plan that is
+// made again once what was in the directory has gone
+func (r *Remover) dropDestination(plan Plan) {
+	if plan.Dest != "" {
+		delete(r.destinations, filepath.Clean(plan.Dest))
+	}
+}
+
+// descend removes dir and what is in it for removeInteractive; declined is
+// set when dir or a directory inside it wasn't descended into. Results for
+// everything below the operand are reported as they happen.
+func (r *Remover) descend(dir string, operand bool) (result Result, declined bool) {
+	report := func(result Result) Result {
+		if !operand && r.opts.Callbacks.OnEntryDone != nil {
+			r.opts.Callbacks.OnEntryDone(result)
+		}
+		return result
+	}
+	if !operand && r.opts.Callbacks.OnEntryStart != nil {
+		r.opts.Callbacks.OnEntryStart(dir)
+	}
+	skipped := func(err error) Result {
+		return report(Result{Action: "skipped", Source: dir, IsDir: true, Op: r.opts.Op, Err: err})
+	}
+	failed := func(err error) Result {
+		return report(Result{Action: "failed", Source: dir, IsDir: true, Op: r.opts.Op, Err: displayErr(err, dir)})
+	}
+
+	// entries are read up front, the directory is changing as they are removed
+	children, err := r.fs.ReadDir(dir)
+	if err != nil {
+		return failed(err), false
+	}
+	left := false
+	if len(children) > 0 {
+		attrs, _ := r.fs.Attributes(dir)
+		prompt := fmt.Sprintf("descend into %s?", DisplayPath(dir))
+		if r.opts.POSIX {
+			prompt = posixDescendPrompt(dir, attrs.ReadOnly)
+		}
+		yes, err := r.confirm(dir, prompt)
+		switch {
+		case errors.Is(err, ErrAborted):
+			return skipped(err), true
+		case err != nil:
+			return failed(err), true
+		case !yes:
+			return skipped(fmt.Errorf("%s: %w", DisplayPath(dir), ErrDeclined)), true
+		}
+
+		for _, de := range children {
+			if r.Aborted() {
+				return skipped(fmt.Errorf("%s: %w", DisplayPath(dir), ErrAborted)), true
+			}
+			child := childPath(dir, de.Name())
+			var res Result
+			if de.IsDir() {
+				if plan, err := r.Plan(child); err == nil {
+					r.dropDestination(plan)
+					var childDeclined bool
+					res, childDeclined = r.descend(child, false)
+					declined = declined || childDeclined
+				}
+			}
+			if res.Action == "" {
+				// a directory that can't be planned is refused as it would
+				// be anyway
+				res = r.removeEntry(child)
+			}
+			status := res.Status()
+			left = left || (status != StatusTrashed && status != StatusDeleted)
+		}
+		if declined {
+			return skipped(fmt.Errorf("%s: %w", DisplayPath(dir), ErrDeclined)), true
+		}
+	}
+
+	// planned afresh, the trash now holds what was in it
+	plan, err := r.Plan(dir)
+	if err != nil {
+		return failed(err), false
+	}
+	if left {
+		if r.opts.POSIX {
+			for _, prompt := range plan.Prompts {
+				if yes, err := r.confirm(dir, prompt); err != nil || !yes {
+					return skipped(fmt.Errorf("%s: %w", DisplayPath(dir), ErrDeclined)), false
+				}
+			}
+		}
+		return failed(syscall.ENOTEMPTY), false
+	}
+	return report(r.removePlanned(dir, plan, nil, false)), false
+}
@@ -0,0 +1,114 @@
+package remove
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// not every GOARCH's syscall package has these
+const (
+	atFDCWD             = -0x64
+	OPath               = 0x200000
+	renameNoReplaceFlag = 0x1
+)
+
+// sysRenameat2 is renameat2's number on this GOARCH, 0 where srm doesn't
+// know it
+var sysRenameat2 = map[string]uintptr{
+	"386": 353, "amd64": 316, "arm": 382, "arm64": 276, "loong64": 276,
+	"mips": 4351, "mipsle": 4351, "mips64": 5311, "mips64le": 5311,
+	"ppc64": 357, "ppc64le": 357, "riscv64": 276, "s390x": 347,
+}[runtime.GOARCH]
+
+// renameatNoReplace is renameat(2) failing with EEXIST rather than replacing
+// newpath, as renameat2(2) does with RENAME_NOREPLACE. A kernel or
+// filesystem without it, older than Linux 3.15 or some network ones, gets
+// renameByPlaceholder over the full paths instead.
+func renameatNoReplace(olddirfd int, oldpath string, newdirfd int, newpath string, oldfull string, newfull string) error {
+	if sysRenameat2 != 0 {
+		err := renameat2(olddirfd, oldpath, newdirfd, newpath, renameNoReplaceFlag)
+		if err != syscall.ENOSYS && err != syscall.EINVAL {
+			return err
+		}
+	}
+	return renameByPlaceholder(oldfull, newfull)
+}
+
+func renameat2(olddirfd int, oldpath string, newdirfd int, newpath string, flags uint) error {
+	oldp, err := syscall.BytePtrFromString(oldpath)
+	if err != nil {
+		return err
+	}
+	newp, err := syscall.BytePtrFromString(newpath)
+	if err != nil {
+		return err
+	}
+	_, _, errno := syscall.Syscall6(sysRenameat2, uintptr(olddirfd), uintptr(unsafe.Pointer(oldp)), uintptr(newdirfd), uintptr(unsafe.Pointer(newp)), uintptr(flags), 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// renameNoReplace is os.Rename failing with EEXIST rather than replacing
+// newpath
+func renameNoReplace(oldpath, newpath string) error {
+	err := renameatNoReplace(atFDCWD, oldpath, atFDCWD, newpath, oldpath, newpath)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: unwrapLinkError(err)}
+	}
+	return nil
+}
+
+// atDir is a directory file descriptor; names inside it go through the *at
+// syscalls, so swapping the path to it for a symlink changes nothing
+type atDir struct {
+	f *os.File
+}
+
+func openDir(name string) (Dir, error) {
+	fd, err := syscall.Open(name, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &atDir{f: os.NewFile(uintptr(fd), name)}, nil
+}
+
+func (d *atDir) Rename(oldpath, name string) error {
+	newpath := filepath.Join(d.f.Name(), name)
+	err := renameatNoReplace(atFDCWD, oldpath, int(d.f.Fd()), name, oldpath, newpath)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: unwrapLinkError(err)}
+	}
+	return nil
+}
+
+func (d *atDir) RenameTo(name string, to Dir, newname string) error {
+	target, ok := to.(*atDir)
+	if !ok {
+		return to.Rename(filepath.Join(d.f.Name(), name), newname)
+	}
+	oldpath, newpath := filepath.Join(d.f.Name(), name), filepath.Join(target.f.Name(), newname)
+	err := renameatNoReplace(int(d.f.Fd()), name, int(target.f.Fd()), newname, oldpath, newpath)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: unwrapLinkError(err)}
+	}
+	return nil
+}
+
+func (d *atDir) Lstat(name string) (fs.FileInfo, error) {
+	fd, err := syscall.Openat(int(d.f.Fd()), name, OPath|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &fs.PathError{Op: "lstat", Path: filepath.Join(d.f.Name(), name), Err: err}
+	}
+	f := os.NewFile(uintptr(fd), filepath.Join(d.f.Name(), name))
+	defer f.Close()
+	return f.Stat()
+}
+
+func (d *atDir) Stat() (fs.FileInfo, error) { return d.f.Stat() }
+func (d *atDir) Close() error               { return d.f.Close() }
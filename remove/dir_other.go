@@ -0,0 +1,62 @@
+//go:build !linux
+
+package remove
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// pathDir stands in for a directory descriptor where the *at syscalls
+// aren't available. It can't stop the path being swapped, only notice when
+// it no longer leads to the directory that was opened.
+type pathDir struct {
+	path string
+	f    *os.File
+	fi   fs.FileInfo
+}
+
+// renameNoReplace is os.Rename failing with EEXIST rather than replacing
+// newpath
+func renameNoReplace(oldpath, newpath string) error {
+	return renameByPlaceholder(oldpath, newpath)
+}
+
+func openDir(name string) (Dir, error) {
+	f, err := os.OpenFile(name, os.O_RDONLY|oNoFollow, 0)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err == nil && !fi.IsDir() {
+		err = fmt.Errorf("%s: not a directory", name)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &pathDir{path: name, f: f, fi: fi}, nil
+}
+
+func (d *pathDir) Rename(oldpath, name string) error {
+	if now, err := os.Lstat(d.path); err != nil || !os.SameFile(now, d.fi) {
+		return fmt.Errorf("%s: %w", d.path, ErrDirSwapped)
+	}
+	return renameByPlaceholder(oldpath, filepath.Join(d.path, name))
+}
+
+func (d *pathDir) RenameTo(name string, to Dir, newname string) error {
+	if now, err := os.Lstat(d.path); err != nil || !os.SameFile(now, d.fi) {
+		return fmt.Errorf("%s: %w", d.path, ErrDirSwapped)
+	}
+	return to.Rename(filepath.Join(d.path, name), newname)
+}
+
+func (d *pathDir) Lstat(name string) (fs.FileInfo, error) {
+	return os.Lstat(filepath.Join(d.path, name))
+}
+
+func (d *pathDir) Stat() (fs.FileInfo, error) { return d.fi, nil }
+func (d *pathDir) Close() error               { return d.f.Close() }
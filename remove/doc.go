@@ -0,0 +1,36 @@
+// Package remove is srm's removal engine: it moves files to the trash, or
+// deletes them, the way the srm command does, for programs that would
+// rather call it than run srm and parse what it prints.
+//
+// A Remover is made from Options by NewRemover and removes one operand at a
+// time with Remove, or many with RemoveAll or RemoveEach, each giving back a
+// Result whose Status says what became of it. Options name the trash, which
+// FindTrashDir picks as srm would. The Remover asks its questions, and
+// reports what it does as it goes, through the Callbacks in its Options.
+// Close it once done, which lets go of the trashes it held open.
+//
+//	trash, err := remove.FindTrashDir()
+//	if err != nil {
+//		return err
+//	}
+//	r := remove.NewRemover(remove.Options{Recursive: true, TrashDir: trash, ResolveTrash: true})
+//	defer r.Close()
+//	if result := r.Remove(path); result.Err != nil {
+//		if errors.Is(result.Err, remove.ErrDeclined) {
+//			...
+//		}
+//	}
+//
+// Errors are always wrapped with the path they are about; compare them with
+// errors.Is against the Err sentinels.
+//
+// # Compatibility
+//
+// Remover, NewRemover, Options, Callbacks, PromptRequest, Result, Status and
+// STATUSES, FindTrashDir, the Err sentinels, and TrashBackend with the
+// types its methods take and BACKENDKINDS, are srm's API for other
+// programs and are kept compatible: they keep their names and meanings, and
+// fields and statuses may be added. The rest of what is exported here is
+// how the srm command shares code with the engine, and follows srm rather
+// than being kept compatible.
+package remove
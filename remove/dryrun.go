@@ -0,0 +1,37 @@
+package remove
+
+import (
+	"path/filepath"
+)
+
+// dryRun is removePlanned's answer under --dry-run: plan carried into a
+// Result as if it had gone through, with the questions it would ask, the
+// warnings it would print and the problems the kernel is likely to raise
+// instead of any of them happening
+func (r *Remover) dryRun(result Result, plan Plan) Result {
+	result.Action, result.Strategy, result.Dest = plan.Action, plan.Strategy, plan.Dest
+	if plan.Dest != "" {
+		result.Trash, result.TrashWhy = plan.Trash, plan.TrashWhy
+		result.Volume, result.TrashVolume = plan.Volume, plan.TrashVolume
+	}
+	if r.opts.MeasureSize {
+		r.runCheck("size", plan.Path, func() { result.Bytes, _ = DiskUsage(r.fs, plan.Path) })
+	}
+	result.Prompts = plan.Prompts
+	if t := plan.InTrash; t != nil {
+		if r.opts.SafeMode && (t.Inside || t.Origin == "") {
+			result.Action, result.Err, result.Prompts = "failed", errSafePurge(plan.Path), nil
+		} else {
+			result.Prompts = []string{t.question(plan.Path, !r.opts.SafeMode)}
+		}
+	}
+	result.Warnings = plan.Warnings
+	if plan.info != nil {
+		abs, err := filepath.Abs(plan.Path)
+		if err != nil {
+			abs = plan.Path
+		}
+		result.Problems = ExplainProblems(abs, plan.info, plan)
+	}
+	return result
+}
@@ -0,0 +1,37 @@
+package remove
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EmptyCandidate is one name in the trash, with srm's index row when it has one
+type EmptyCandidate struct {
+	Entry IndexEntry
+	Known bool
+	// dated is whether entry.Deleted is when it was deleted, rather than a
+	// guess from the payload's mtime
+	Dated bool
+	Size  int64
+}
+
+// ParseAge reads an --older-than age: a number of days ("30d") or weeks
+// ("2w"), or a Go duration ("12h", "90m")
+func ParseAge(value string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(value, suffix); ok {
+			count, err := strconv.Atoi(n)
+			if err != nil || count < 0 {
+				return 0, fmt.Errorf("bad age %q", value)
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("bad age %q", value)
+	}
+	return d, nil
+}
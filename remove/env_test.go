@@ -0,0 +1,129 @@
+package remove
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// scratchEnv is the scratch directory a test removes in: a work tree, a
+// trash and srm's own records, nothing of them outside root
+type scratchEnv struct {
+	root    string
+	work    string
+	trash   string
+	index   *Index
+	intents *IntentLog
+	faults  *FaultFS
+	// removers are closed once the test is done
+	removers []*Remover
+}
+
+// testEnv is a scratchEnv in t's temporary directory, with HOME, the XDG
+// directories and both config files pointed into it so nothing of the
+// user's is read or written
+func testEnv(t *testing.T) *scratchEnv {
+	t.Helper()
+	root := t.TempDir()
+	t.Setenv("HOME", filepath.Join(root, "home"))
+	t.Setenv("XDG_DATA_HOME", filepath.Join(root, "home", ".local", "share"))
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(root, "home", ".config"))
+	t.Setenv(CONFIGENV, filepath.Join(root, "config"))
+	oldSystem := SYSTEMCONFIG
+	SYSTEMCONFIG = filepath.Join(root, "system.config")
+	t.Cleanup(func() { SYSTEMCONFIG = oldSystem })
+
+	env := &scratchEnv{
+		root:    root,
+		work:    filepath.Join(root, "work"),
+		trash:   filepath.Join(root, "trash"),
+		index:   NewIndex(filepath.Join(root, "data", "index")),
+		intents: NewIntentLog(filepath.Join(root, "data", "intents")),
+		faults:  NewFaultFS(OSFS{}),
+	}
+	for _, dir := range []string{env.work, env.trash} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		for _, r := range env.removers {
+			r.Close()
+		}
+	})
+	return env
+}
+
+// options are the Options of a Remover bound to the scratch trash and
+// records. They never prompt; a test that would be asked something fails
+// instead.
+func (env *scratchEnv) options(recursive bool) Options {
+	return Options{
+		Recursive: recursive,
+		TrashDir:  env.trash,
+		Index:     env.index,
+		Intents:   env.intents,
+		Op:        "test",
+		FS:        env.faults,
+		Callbacks: Callbacks{
+			OnPrompt: func(req PromptRequest) (string, error) {
+				return "", fmt.Errorf("unexpected prompt: %s", req.Message)
+			},
+		},
+	}
+}
+
+// remover returns a Remover made from env.options(recursive)
+func (env *scratchEnv) remover(recursive bool) *Remover {
+	return env.removerWith(env.options(recursive))
+}
+
+// removerWith returns a Remover made from opts, closed with the test
+func (env *scratchEnv) removerWith(opts Options) *Remover {
+	r := NewRemover(opts)
+	env.removers = append(env.removers, r)
+	return r
+}
+
+// file creates a file under the work tree with content as its contents
+func (env *scratchEnv) file(name string, content string) (string, error) {
+	path := filepath.Join(env.work, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, []byte(content), 0644)
+}
+
+// trashed checks that path left the work tree and arrived in the trash as
+// name with content, and that the index has a row for it
+func (env *scratchEnv) trashed(path string, name string, content string) error {
+	if _, err := os.Lstat(path); !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%s is still there", path)
+	}
+	got, err := os.ReadFile(filepath.Join(env.trash, name))
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, []byte(content)) {
+		return fmt.Errorf("%s arrived with different contents", name)
+	}
+	return env.indexed(name)
+}
+
+// indexed checks that the index has a row for name
+func (env *scratchEnv) indexed(name string) error {
+	entries, err := env.index.Entries()
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Name == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("no index row for %s", name)
+}
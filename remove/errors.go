@@ -0,0 +1,50 @@
+package remove
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Errors returned by a Remover, always wrapped with the offending path so
+// callers should compare with errors.Is
+var (
+	ErrIsDirectory      = errors.New("is a directory")
+	ErrDirNotEmpty      = errors.New("directory not empty")
+	ErrNotFound         = errors.New("no such file or directory")
+	ErrReadOnly         = errors.New("file is read-only")
+	ErrProtectedPath    = errors.New("protected path")
+	ErrTrashUnavailable = errors.New("no usable trash")
+	ErrDeclined         = errors.New("declined")
+	ErrAborted          = errors.New("not asked, the session was aborted")
+	ErrOriginTaken      = errors.New("something else is there now")
+	ErrSkipped          = errors.New("skipped by filesystem type policy")
+	ErrCovered          = errors.New("already covered")
+	ErrEmptyOperand     = errors.New("invalid empty operand")
+	ErrDirSwapped       = errors.New("was replaced while in use")
+	ErrPayloadSwapped   = errors.New("what reached the trash isn't what was removed")
+	ErrNotInTrash       = errors.New("not in the trash")
+	ErrNameTooLong      = errors.New("destination name too long")
+	ErrDestExists       = errors.New("already exists, pass -f to trash it and restore over it")
+	ErrVerifyFailed     = errors.New("copy doesn't match the original, which was left in place")
+	ErrTrashLost        = errors.New("the trash became read-only during the run")
+	ErrDotOperand       = errors.New("refusing to remove '.' or '..' directory")
+	ErrPreserveRoot     = errors.New("is the root directory, refusing to remove it without --no-preserve-root")
+)
+
+// IsMissing reports whether err says the operand isn't there: ErrNotFound,
+// or ENOTDIR for a path through something that isn't a directory, as in
+// file/x. -f says nothing about either, as rm's doesn't.
+func IsMissing(err error) bool {
+	return errors.Is(err, ErrNotFound) || errors.Is(err, syscall.ENOTDIR)
+}
+
+// ErrRemoteURI is a file:// URI naming another machine
+var ErrRemoteURI = errors.New("names a file on another machine")
+
+// ErrNoSandbox is why sandbox = true couldn't be honoured: the platform or
+// kernel has nothing to do it with
+var ErrNoSandbox = errors.New("sandboxing isn't supported here")
+
+// ErrUnsafeMember is a tar member that would land outside the directory it
+// is unpacked into, or be written through a symlink
+var ErrUnsafeMember = errors.New("would be unpacked outside its directory or through a symlink")
@@ -0,0 +1,47 @@
+package remove
+
+import (
+	"fmt"
+	"io/fs"
+)
+
+// ProcessUse is a process that has a file mapped executable
+type ProcessUse struct {
+	PID     int
+	Command string
+	Path    string
+}
+
+func (u ProcessUse) String() string {
+	return fmt.Sprintf("pid %d (%s)", u.PID, u.Command)
+}
+
+// checkExecuting warns, and under -i asks, when the operand is a binary or
+// library some process is running from. Renaming it away changes what the
+// next exec of that path finds, which is rarely what cleaning up meant.
+func (r *Remover) checkExecuting(plan *Plan, fi fs.FileInfo) {
+	uses, err := executingFrom(plan.Path, fi)
+	if err != nil {
+		plan.tracef("can't tell whether it is executing: %s", err)
+		return
+	}
+	if len(uses) == 0 {
+		plan.tracef("no process is executing it")
+		return
+	}
+
+	what := fmt.Sprintf("%s is currently executing in %s", DisplayPath(plan.Path), uses[0])
+	if fi.IsDir() {
+		what = fmt.Sprintf("%s is currently executing in %s", uses[0].Path, uses[0])
+	}
+	if len(uses) > 1 {
+		what += fmt.Sprintf(" and %d other processes", len(uses)-1)
+	}
+	plan.tracef("%s", what)
+
+	if r.opts.Interactive {
+		plan.Prompts = append(plan.Prompts, what+", remove anyway?")
+	} else {
+		plan.Warnings = append(plan.Warnings, what)
+	}
+}
@@ -0,0 +1,48 @@
+package remove
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// ExplainProblems looks for what the kernel would refuse that Plan can't
+// know about: permissions on the parent, sticky directories, immutable files
+// and renames across filesystems
+func ExplainProblems(abs string, fi fs.FileInfo, plan Plan) []string {
+	problems := []string{}
+	parent := filepath.Dir(abs)
+
+	if err := checkWritable(parent); err != nil {
+		problems = append(problems, fmt.Sprintf("can't write to %s, which removing from it needs: %s", parent, err))
+	}
+
+	if parentInfo, err := os.Stat(parent); err == nil && parentInfo.Mode()&fs.ModeSticky != 0 {
+		uid := os.Getuid()
+		fileUID, ok1 := fileOwner(fi)
+		dirUID, ok2 := fileOwner(parentInfo)
+		if ok1 && ok2 && uid != 0 && uid != fileUID && uid != dirUID {
+			problems = append(problems, fmt.Sprintf("%s is sticky and you own neither it nor the file", parent))
+		}
+	}
+
+	if locked, err := immutable(abs); err == nil && locked {
+		problems = append(problems, "it is immutable or append-only (chattr/chflags), so not even root can move it")
+	}
+
+	if plan.Strategy == "rename" {
+		trashInfo, err := os.Stat(filepath.Dir(plan.Dest))
+		if err == nil {
+			from, ok1 := fileDevice(fi)
+			to, ok2 := fileDevice(trashInfo)
+			// anything else is copied across once the rename fails with EXDEV
+			copyable := fi.Mode().IsRegular() || fi.IsDir() || fi.Mode()&fs.ModeSymlink != 0
+			if ok1 && ok2 && from != to && !copyable {
+				problems = append(problems, fmt.Sprintf("the trash is on another filesystem, where a %s can't be copied (try a trash on the same filesystem)", fileTypePhrase(fi)))
+			}
+		}
+	}
+
+	return problems
+}
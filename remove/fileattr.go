@@ -0,0 +1,104 @@
+package remove
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FileAttributes are what the platform says about a file beyond its type:
+// whether it is read-only, hidden or part of the system
+type FileAttributes struct {
+	ReadOnly bool
+	// ReadOnlyBlocks means being read-only stops the file itself from being
+	// renamed or deleted, as Windows' read-only attribute does, rather than
+	// only its contents from being written, as a Unix mode does
+	ReadOnlyBlocks bool
+	Hidden         bool
+	System         bool
+}
+
+// attributeDecision is what a file's attributes mean for removing it
+type attributeDecision struct {
+	Err     error // refuse with this
+	Prompts []string
+	// ClearReadOnly drops the read-only attribute before moving the file, to
+	// be set again on the trashed copy
+	ClearReadOnly bool
+	// WriteProtected asks rm's write-protected question, under --posix
+	WriteProtected bool
+	Trace          []string
+}
+
+// decideAttributes
+// is the decision table for read-only, hidden and system files. It only looks
+// at its arguments, so the table is the same wherever the attributes came from.
+func decideAttributes(path string, attrs FileAttributes, opts Options) attributeDecision {
+	d := attributeDecision{}
+	trace := func(format string, args ...interface{}) {
+		d.Trace = append(d.Trace, fmt.Sprintf(format, args...))
+	}
+
+	if attrs.Hidden {
+		trace("it is hidden")
+	}
+
+	switch {
+	case attrs.ReadOnly && !opts.Force && opts.POSIX && opts.PromptWriteProtected:
+		trace("it is write-protected and input is a terminal, so rm would ask")
+		d.WriteProtected = true
+	case attrs.ReadOnly && !opts.Force && opts.POSIX:
+		trace("it is write-protected, which rm removes without asking when input isn't a terminal")
+	case attrs.ReadOnly && !opts.Force:
+		trace("it is read-only, which needs -f")
+		d.Err = fmt.Errorf("%s: %w", DisplayPath(path), ErrReadOnly)
+		return d
+	case attrs.ReadOnly && opts.SafeMode:
+		trace("it is read-only and safe mode asks even with -f")
+		d.Prompts = append(d.Prompts, fmt.Sprintf("remove read-only file %s?", DisplayPath(path)))
+	case attrs.ReadOnly:
+		trace("it is read-only but -f was given")
+	}
+	if attrs.ReadOnly && attrs.ReadOnlyBlocks {
+		trace("the read-only attribute is cleared to move it, and set again on the trashed copy")
+		d.ClearReadOnly = true
+	}
+
+	switch {
+	case attrs.System && !opts.SkipsPrompt("system"):
+		trace("it is a system file, which is always asked about without -f")
+		d.Prompts = append(d.Prompts, fmt.Sprintf("remove system file %s?", DisplayPath(path)))
+	case attrs.System:
+		trace("it is a system file but -f was given")
+	}
+
+	return d
+}
+
+// WINDOWSRESERVED are the device names Windows reserves in every directory,
+// whatever extension follows them, so NUL.txt is NUL too
+var WINDOWSRESERVED = []string{
+	"CON", "PRN", "AUX", "NUL", "CONIN$", "CONOUT$",
+	"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9", "COM¹", "COM²", "COM³",
+	"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9", "LPT¹", "LPT²", "LPT³",
+}
+
+// IsReservedName reports whether Windows takes name for a device: what
+// comes before its first dot, less trailing spaces, is in WINDOWSRESERVED
+// in any case
+func IsReservedName(name string) bool {
+	stem, _, _ := strings.Cut(name, ".")
+	return In(strings.ToUpper(strings.TrimRight(stem, " ")), WINDOWSRESERVED)
+}
+
+// escapeReservedName puts a _ ahead of the first dot of a name Windows
+// reserves, CON.txt becoming CON_.txt, and leaves any other name alone
+func escapeReservedName(name string) string {
+	if !IsReservedName(name) {
+		return name
+	}
+	stem, ext, dotted := strings.Cut(name, ".")
+	if !dotted {
+		return stem + "_"
+	}
+	return stem + "_." + ext
+}
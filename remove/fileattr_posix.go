@@ -0,0 +1,39 @@
+//go:build !windows
+
+package remove
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fileAttributes reads a file's attributes from its mode and name. Dotfiles
+// count as hidden, and nothing is a system file.
+func fileAttributes(path string) (FileAttributes, error) {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return FileAttributes{}, err
+	}
+	return FileAttributes{
+		ReadOnly: fi.Mode().Perm()&0200 == 0,
+		Hidden:   strings.HasPrefix(filepath.Base(path), "."),
+	}, nil
+}
+
+// setReadOnly adds or removes the owner's write permission
+func setReadOnly(path string, readOnly bool) error {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	// a symlink's own mode means nothing, and chmod would change its target
+	if fi.Mode()&os.ModeSymlink != 0 {
+		return nil
+	}
+	mode := fi.Mode().Perm() | 0200
+	if readOnly {
+		mode = fi.Mode().Perm() &^ 0222
+	}
+	return os.Chmod(path, mode)
+}
@@ -0,0 +1,128 @@
+package remove
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// decideAttributes only looks at its arguments, so the Windows table is
+// tested here on every platform
+func TestDecideAttributes(t *testing.T) {
+	const path = "/w/file.txt"
+	var (
+		none      = Options{}
+		force     = Options{Force: true, ForceLevel: 1}
+		forceSafe = Options{Force: true, ForceLevel: 1, SafeMode: true}
+		bypass    = Options{Force: true, ForceLevel: FORCEBYPASS}
+		posix     = Options{POSIX: true}
+		posixTTY  = Options{POSIX: true, PromptWriteProtected: true}
+		posixF    = Options{POSIX: true, PromptWriteProtected: true, Force: true, ForceLevel: 1}
+	)
+	readOnly := FileAttributes{ReadOnly: true}
+	windowsReadOnly := FileAttributes{ReadOnly: true, ReadOnlyBlocks: true}
+	system := FileAttributes{System: true}
+	askReadOnly := "remove read-only file " + DisplayPath(path) + "?"
+	askSystem := "remove system file " + DisplayPath(path) + "?"
+
+	tests := []struct {
+		name           string
+		attrs          FileAttributes
+		opts           Options
+		err            error
+		prompts        []string
+		clearReadOnly  bool
+		writeProtected bool
+	}{
+		{"plain file", FileAttributes{}, none, nil, nil, false, false},
+		{"hidden file", FileAttributes{Hidden: true}, none, nil, nil, false, false},
+		{"read-only without -f", readOnly, none, ErrReadOnly, nil, false, false},
+		{"read-only with -f", readOnly, force, nil, nil, false, false},
+		{"read-only with -f in safe mode", readOnly, forceSafe, nil, []string{askReadOnly}, false, false},
+		{"windows read-only without -f", windowsReadOnly, none, ErrReadOnly, nil, false, false},
+		{"windows read-only with -f", windowsReadOnly, force, nil, nil, true, false},
+		{"windows read-only with -f in safe mode", windowsReadOnly, forceSafe, nil, []string{askReadOnly}, true, false},
+		{"write-protected under --posix", readOnly, posix, nil, nil, false, false},
+		{"write-protected under --posix on a terminal", readOnly, posixTTY, nil, nil, false, true},
+		{"write-protected under --posix -f", readOnly, posixF, nil, nil, false, false},
+		{"windows read-only under --posix", windowsReadOnly, posix, nil, nil, true, false},
+		{"system file", system, none, nil, []string{askSystem}, false, false},
+		{"system file with -f", system, force, nil, nil, false, false},
+		{"system file with -ff", system, bypass, nil, nil, false, false},
+		{"system file with -f in safe mode", system, forceSafe, nil, []string{askSystem}, false, false},
+		{"read-only system file without -f", FileAttributes{ReadOnly: true, ReadOnlyBlocks: true, System: true}, none, ErrReadOnly, nil, false, false},
+		{"read-only system file in safe mode", FileAttributes{ReadOnly: true, ReadOnlyBlocks: true, System: true, Hidden: true}, forceSafe, nil, []string{askReadOnly, askSystem}, true, false},
+	}
+	for _, tt := range tests {
+		d := decideAttributes(path, tt.attrs, tt.opts)
+		if !errors.Is(d.Err, tt.err) || (d.Err == nil) != (tt.err == nil) {
+			t.Errorf("%s: error %v, want %v", tt.name, d.Err, tt.err)
+		}
+		if !slices.Equal(d.Prompts, tt.prompts) {
+			t.Errorf("%s: prompts %q, want %q", tt.name, d.Prompts, tt.prompts)
+		}
+		if d.ClearReadOnly != tt.clearReadOnly {
+			t.Errorf("%s: ClearReadOnly %v, want %v", tt.name, d.ClearReadOnly, tt.clearReadOnly)
+		}
+		if d.WriteProtected != tt.writeProtected {
+			t.Errorf("%s: WriteProtected %v, want %v", tt.name, d.WriteProtected, tt.writeProtected)
+		}
+		if len(d.Trace) == 0 && tt.attrs != (FileAttributes{}) {
+			t.Errorf("%s: no trace for -vv", tt.name)
+		}
+	}
+}
+
+func TestIsReservedName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"CON", true},
+		{"con", true},
+		{"Nul.txt", true},
+		{"aux.tar.gz", true},
+		{"PRN ", true},
+		{"prn .txt", true},
+		{"COM1", true},
+		{"lpt9.log", true},
+		{"COM²", true},
+		{"CONIN$", true},
+		{"conout$.txt", true},
+		{"COM0", false},
+		{"COM10", false},
+		{"LPT", false},
+		{"CONSOLE", false},
+		{"icon", false},
+		{"my.con", false},
+		{".nul", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsReservedName(tt.name); got != tt.want {
+			t.Errorf("IsReservedName(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEscapeReservedName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"CON", "CON_"},
+		{"nul.txt", "nul_.txt"},
+		{"com1.tar.gz", "com1_.tar.gz"},
+		{"notes.txt", "notes.txt"},
+		{"console", "console"},
+	}
+	for _, tt := range tests {
+		got := escapeReservedName(tt.name)
+		if got != tt.want {
+			t.Errorf("escapeReservedName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+		if IsReservedName(got) {
+			t.Errorf("escapeReservedName(%q) = %q is still reserved", tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package remove
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileAttributes reads FILE_ATTRIBUTE_READONLY, HIDDEN and SYSTEM. The
+// read-only attribute, unlike a Unix mode, stops the file being moved.
+func fileAttributes(path string) (FileAttributes, error) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return FileAttributes{}, err
+	}
+	attrs, err := syscall.GetFileAttributes(name)
+	if err != nil {
+		return FileAttributes{}, &fs.PathError{Op: "getfileattributes", Path: path, Err: err}
+	}
+	return FileAttributes{
+		ReadOnly:       attrs&syscall.FILE_ATTRIBUTE_READONLY != 0,
+		ReadOnlyBlocks: true,
+		Hidden:         attrs&syscall.FILE_ATTRIBUTE_HIDDEN != 0,
+		System:         attrs&syscall.FILE_ATTRIBUTE_SYSTEM != 0,
+	}, nil
+}
+
+// setReadOnly sets or clears FILE_ATTRIBUTE_READONLY, leaving the others
+func setReadOnly(path string, readOnly bool) error {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+	attrs, err := syscall.GetFileAttributes(name)
+	if err != nil {
+		return &fs.PathError{Op: "getfileattributes", Path: path, Err: err}
+	}
+	if readOnly {
+		attrs |= syscall.FILE_ATTRIBUTE_READONLY
+	} else {
+		attrs &^= syscall.FILE_ATTRIBUTE_READONLY
+	}
+	if err := syscall.SetFileAttributes(name, attrs); err != nil {
+		return &fs.PathError{Op: "setfileattributes", Path: path, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+package remove
+
+import "time"
+
+// FileTimes are the timestamps a payload had when it was trashed. A zero
+// time means the platform doesn't keep or expose it.
+type FileTimes struct {
+	Modified time.Time `json:"mtime"`
+	Accessed time.Time `json:"atime"`
+	Changed  time.Time `json:"ctime"`
+	Born     time.Time `json:"btime"`
+}
@@ -0,0 +1,18 @@
+package remove
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// fileTimes reads every timestamp darwin keeps, birth time included
+func fileTimes(path string, fi fs.FileInfo) FileTimes {
+	times := FileTimes{Modified: fi.ModTime()}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		times.Accessed = time.Unix(st.Atimespec.Unix())
+		times.Changed = time.Unix(st.Ctimespec.Unix())
+		times.Born = time.Unix(st.Birthtimespec.Unix())
+	}
+	return times
+}
@@ -0,0 +1,71 @@
+package remove
+
+import (
+	"encoding/binary"
+	"io/fs"
+	"runtime"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// statx(2) isn't in the syscall package, so its number per architecture
+var sysStatx = map[string]uintptr{
+	"386":     383,
+	"amd64":   332,
+	"arm":     397,
+	"arm64":   291,
+	"loong64": 291,
+	"ppc64":   383,
+	"ppc64le": 383,
+	"riscv64": 291,
+	"s390x":   379,
+}
+
+const (
+	AT_FDCWD            = -100
+	AT_SYMLINK_NOFOLLOW = 0x100
+	STATX_BTIME         = 0x800
+)
+
+// fileTimes reads mtime, atime and ctime from fi and asks statx for the
+// birth time, which only it knows
+func fileTimes(path string, fi fs.FileInfo) FileTimes {
+	times := FileTimes{Modified: fi.ModTime()}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		times.Accessed = time.Unix(st.Atim.Unix())
+		times.Changed = time.Unix(st.Ctim.Unix())
+	}
+
+	trap, ok := sysStatx[runtime.GOARCH]
+	if !ok {
+		return times
+	}
+	name, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return times
+	}
+	var buf [256]byte
+	dirfd := AT_FDCWD
+	_, _, errno := syscall.Syscall6(trap, uintptr(dirfd), uintptr(unsafe.Pointer(name)), AT_SYMLINK_NOFOLLOW, STATX_BTIME, uintptr(unsafe.Pointer(&buf[0])), 0)
+	if errno == 0 {
+		times.Born = statxBirthTime(buf[:])
+	}
+	return times
+}
+
+// statxBirthTime pulls stx_btime out of a struct statx, zero when the
+// filesystem didn't fill it in
+func statxBirthTime(buf []byte) time.Time {
+	if len(buf) < 96 {
+		return time.Time{}
+	}
+	mask := binary.NativeEndian.Uint32(buf[0:])
+	if mask&STATX_BTIME == 0 {
+		return time.Time{}
+	}
+	// struct statx_timestamp { __s64 tv_sec; __u32 tv_nsec; __s32 __reserved; } at offset 80
+	sec := int64(binary.NativeEndian.Uint64(buf[80:]))
+	nsec := int64(binary.NativeEndian.Uint32(buf[88:]))
+	return time.Unix(sec, nsec)
+}
@@ -0,0 +1,68 @@
+package remove
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStatxBirthTime(t *testing.T) {
+	statx := func(mask uint32, sec int64, nsec uint32) []byte {
+		buf := make([]byte, 256)
+		binary.NativeEndian.PutUint32(buf[0:], mask)
+		binary.NativeEndian.PutUint64(buf[80:], uint64(sec))
+		binary.NativeEndian.PutUint32(buf[88:], nsec)
+		return buf
+	}
+	tests := []struct {
+		name string
+		buf  []byte
+		want time.Time
+	}{
+		{"a birth time", statx(STATX_BTIME|0x7ff, 981173106, 500), time.Unix(981173106, 500)},
+		{"before 1970", statx(STATX_BTIME, -86400, 0), time.Unix(-86400, 0)},
+		{"not filled in", statx(0x7ff, 981173106, 500), time.Time{}},
+		{"a short buffer", statx(STATX_BTIME, 981173106, 500)[:90], time.Time{}},
+		{"nothing", nil, time.Time{}},
+	}
+	for _, tt := range tests {
+		if got := statxBirthTime(tt.buf); !got.Equal(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// fileTimes reads the times of the path itself, not of a symlink's target
+func TestFileTimes(t *testing.T) {
+	dir := t.TempDir()
+	path, link := filepath.Join(dir, "f"), filepath.Join(dir, "link")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime, atime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC), time.Date(2002, 3, 4, 5, 6, 7, 0, time.UTC)
+	if err := os.Chtimes(path, atime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(path, link); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Lstat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	times := fileTimes(path, fi)
+	if !times.Modified.Equal(mtime) || !times.Accessed.Equal(atime) || times.Changed.IsZero() {
+		t.Errorf("got %+v, want mtime %v and atime %v", times, mtime, atime)
+	}
+
+	fi, err = os.Lstat(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if times := fileTimes(link, fi); times.Modified.Equal(mtime) {
+		t.Errorf("the symlink got its target's mtime")
+	}
+}
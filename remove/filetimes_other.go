@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !windows
+
+package remove
+
+import "io/fs"
+
+func fileTimes(path string, fi fs.FileInfo) FileTimes {
+	return FileTimes{Modified: fi.ModTime()}
+}
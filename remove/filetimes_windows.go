@@ -0,0 +1,18 @@
+package remove
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// fileTimes reads the creation and access times NTFS keeps. There is no
+// ctime on Windows.
+func fileTimes(path string, fi fs.FileInfo) FileTimes {
+	times := FileTimes{Modified: fi.ModTime()}
+	if data, ok := fi.Sys().(*syscall.Win32FileAttributeData); ok {
+		times.Accessed = time.Unix(0, data.LastAccessTime.Nanoseconds())
+		times.Born = time.Unix(0, data.CreationTime.Nanoseconds())
+	}
+	return times
+}
@@ -0,0 +1,400 @@
+package remove
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// FS is every filesystem operation srm performs. OSFS is the real thing;
+// FaultFS wraps another FS to make chosen operations fail.
+type FS interface {
+	Stat(name string) (fs.FileInfo, error)
+	Lstat(name string) (fs.FileInfo, error)
+	Rename(oldpath, newpath string) error
+	// RenameNoReplace is Rename failing with EEXIST rather than replacing
+	// what is at newpath
+	RenameNoReplace(oldpath, newpath string) error
+	Remove(name string) error
+	RemoveAll(path string) error
+	Open(name string) (File, error)
+	Create(name string) (File, error)
+	Mkdir(name string, perm fs.FileMode) error
+	// OpenWrite opens an existing file for writing without truncating it
+	OpenWrite(name string) (File, error)
+	ReadDir(name string) ([]fs.DirEntry, error)
+	// OpenDir holds the directory name open, refusing a symlink at name
+	OpenDir(name string) (Dir, error)
+	Statfs(path string) (FSStats, error)
+	Link(oldname, newname string) error
+	Symlink(oldname, newname string) error
+	Readlink(name string) (string, error)
+	Chmod(name string, mode fs.FileMode) error
+	Chtimes(name string, atime time.Time, mtime time.Time) error
+	Attributes(name string) (FileAttributes, error)
+	SetReadOnly(name string, readOnly bool) error
+}
+
+// File is the subset of *os.File srm needs
+type File interface {
+	io.ReadWriteCloser
+	Stat() (fs.FileInfo, error)
+	Sync() error
+	Truncate(size int64) error
+}
+
+// Dir is a directory held open, so that once it is opened, what happens
+// inside it doesn't depend on its path still leading there. Its renames
+// never replace anything, failing with EEXIST instead, so two srms moving
+// into one trash can't overwrite each other's payloads.
+type Dir interface {
+	// Rename moves oldpath to name directly inside the directory
+	Rename(oldpath, name string) error
+	// RenameTo moves name directly inside the directory to newname directly
+	// inside to, neither path being looked up again
+	RenameTo(name string, to Dir, newname string) error
+	// Lstat stats name directly inside the directory without following it
+	Lstat(name string) (fs.FileInfo, error)
+	// Stat stats the directory itself
+	Stat() (fs.FileInfo, error)
+	Close() error
+}
+
+// FSStats is the portable part of statfs(2)
+type FSStats struct {
+	Type   uint64
+	Bsize  int64
+	Blocks uint64
+	Bfree  uint64
+	Bavail uint64
+}
+
+// OSFS talks to the real filesystem through the os package
+type OSFS struct{}
+
+func (OSFS) Stat(name string) (fs.FileInfo, error)  { return os.Stat(name) }
+func (OSFS) Lstat(name string) (fs.FileInfo, error) { return os.Lstat(name) }
+func (OSFS) Rename(oldpath, newpath string) error   { return os.Rename(oldpath, newpath) }
+func (OSFS) Remove(name string) error               { return os.Remove(name) }
+func (OSFS) RemoveAll(path string) error            { return os.RemoveAll(path) }
+func (OSFS) Open(name string) (File, error)         { return os.Open(name) }
+func (OSFS) Create(name string) (File, error)       { return os.Create(name) }
+func (OSFS) OpenWrite(name string) (File, error)    { return os.OpenFile(name, os.O_WRONLY, 0) }
+func (OSFS) Mkdir(name string, perm fs.FileMode) error {
+	return os.Mkdir(name, perm)
+}
+func (OSFS) RenameNoReplace(oldpath, newpath string) error {
+	return renameNoReplace(oldpath, newpath)
+}
+func (OSFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+func (OSFS) OpenDir(name string) (Dir, error)          { return openDir(name) }
+func (OSFS) Statfs(path string) (FSStats, error)       { return statfs(path) }
+func (OSFS) Link(oldname, newname string) error        { return os.Link(oldname, newname) }
+func (OSFS) Symlink(oldname, newname string) error     { return os.Symlink(oldname, newname) }
+func (OSFS) Readlink(name string) (string, error)      { return os.Readlink(name) }
+func (OSFS) Chmod(name string, mode fs.FileMode) error { return os.Chmod(name, mode) }
+func (OSFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+func (OSFS) Attributes(name string) (FileAttributes, error) { return fileAttributes(name) }
+func (OSFS) SetReadOnly(name string, readOnly bool) error   { return setReadOnly(name, readOnly) }
+
+// renameByPlaceholder is a rename that never replaces what is at newpath,
+// for where the kernel can't be asked for one. newpath is created
+// exclusively first, an empty directory or file as oldpath is one or not,
+// and only that placeholder is then replaced. Windows can't rename over a
+// directory, so there it is removed again just ahead of the rename.
+func renameByPlaceholder(oldpath, newpath string) error {
+	fi, err := os.Lstat(oldpath)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		err = os.Mkdir(newpath, 0700)
+	} else {
+		var f *os.File
+		if f, err = os.OpenFile(newpath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600); err == nil {
+			err = f.Close()
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() && runtime.GOOS == "windows" {
+		os.Remove(newpath)
+	}
+	if err := os.Rename(oldpath, newpath); err != nil {
+		os.Remove(newpath)
+		return err
+	}
+	return nil
+}
+
+// unwrapLinkError is the errno inside the *os.LinkError or *fs.PathError
+// err is, so it can be wrapped again with the paths the caller knows
+func unwrapLinkError(err error) error {
+	var linkErr *os.LinkError
+	var pathErr *fs.PathError
+	switch {
+	case errors.As(err, &linkErr):
+		return linkErr.Err
+	case errors.As(err, &pathErr):
+		return pathErr.Err
+	}
+	return err
+}
+
+// FaultFS passes everything through to the wrapped FS except operations that
+// have had an error injected, which makes paths like EXDEV or ENOSPC handling
+// reachable without a filesystem that actually produces them
+type FaultFS struct {
+	FS
+
+	mu     sync.Mutex
+	faults map[string]error
+	before map[string]func()
+}
+
+func NewFaultFS(base FS) *FaultFS {
+	return &FaultFS{FS: base, faults: map[string]error{}, before: map[string]func(){}}
+}
+
+// Before runs fn the next time op is called for path, ahead of the call and
+// any fault injected for it, which lets a test change the tree between a
+// check and the operation that relies on it
+func (f *FaultFS) Before(op string, path string, fn func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.before[op+"\x00"+path] = fn
+}
+
+// Inject makes op (e.g. "rename") fail with err for path, or for every path
+// when path is empty
+func (f *FaultFS) Inject(op string, path string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.faults[op+"\x00"+path] = err
+}
+
+// Clear removes every injected fault and every Before not yet run
+func (f *FaultFS) Clear() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.faults = map[string]error{}
+	f.before = map[string]func(){}
+}
+
+func (f *FaultFS) fault(op string, path string) error {
+	f.mu.Lock()
+	fn, ok := f.before[op+"\x00"+path]
+	delete(f.before, op+"\x00"+path)
+	f.mu.Unlock()
+	if ok {
+		fn()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err, ok := f.faults[op+"\x00"+path]; ok {
+		return err
+	}
+	return f.faults[op+"\x00"]
+}
+
+func (f *FaultFS) pathErr(op string, path string) error {
+	if err := f.fault(op, path); err != nil {
+		return &fs.PathError{Op: op, Path: path, Err: err}
+	}
+	return nil
+}
+
+func (f *FaultFS) linkErr(op string, oldpath, newpath string) error {
+	if err := f.fault(op, oldpath); err != nil {
+		return &os.LinkError{Op: op, Old: oldpath, New: newpath, Err: err}
+	}
+	return nil
+}
+
+func (f *FaultFS) Stat(name string) (fs.FileInfo, error) {
+	if err := f.pathErr("stat", name); err != nil {
+		return nil, err
+	}
+	return f.FS.Stat(name)
+}
+
+func (f *FaultFS) Lstat(name string) (fs.FileInfo, error) {
+	if err := f.pathErr("lstat", name); err != nil {
+		return nil, err
+	}
+	return f.FS.Lstat(name)
+}
+
+func (f *FaultFS) Rename(oldpath, newpath string) error {
+	if err := f.linkErr("rename", oldpath, newpath); err != nil {
+		return err
+	}
+	return f.FS.Rename(oldpath, newpath)
+}
+
+func (f *FaultFS) RenameNoReplace(oldpath, newpath string) error {
+	if err := f.linkErr("rename", oldpath, newpath); err != nil {
+		return err
+	}
+	return f.FS.RenameNoReplace(oldpath, newpath)
+}
+
+func (f *FaultFS) Remove(name string) error {
+	if err := f.pathErr("remove", name); err != nil {
+		return err
+	}
+	return f.FS.Remove(name)
+}
+
+func (f *FaultFS) RemoveAll(path string) error {
+	if err := f.pathErr("removeall", path); err != nil {
+		return err
+	}
+	return f.FS.RemoveAll(path)
+}
+
+func (f *FaultFS) Open(name string) (File, error) {
+	if err := f.pathErr("open", name); err != nil {
+		return nil, err
+	}
+	return f.FS.Open(name)
+}
+
+func (f *FaultFS) Create(name string) (File, error) {
+	if err := f.pathErr("create", name); err != nil {
+		return nil, err
+	}
+	return f.FS.Create(name)
+}
+
+func (f *FaultFS) Mkdir(name string, perm fs.FileMode) error {
+	if err := f.pathErr("mkdir", name); err != nil {
+		return err
+	}
+	return f.FS.Mkdir(name, perm)
+}
+
+func (f *FaultFS) OpenWrite(name string) (File, error) {
+	if err := f.pathErr("openwrite", name); err != nil {
+		return nil, err
+	}
+	return f.FS.OpenWrite(name)
+}
+
+func (f *FaultFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if err := f.pathErr("readdir", name); err != nil {
+		return nil, err
+	}
+	return f.FS.ReadDir(name)
+}
+
+func (f *FaultFS) OpenDir(name string) (Dir, error) {
+	if err := f.pathErr("opendir", name); err != nil {
+		return nil, err
+	}
+	d, err := f.FS.OpenDir(name)
+	if err != nil {
+		return nil, err
+	}
+	return &faultDir{Dir: d, faults: f, path: name}, nil
+}
+
+// faultDir applies a FaultFS's rename and lstat faults to the full paths of
+// names inside an opened directory
+type faultDir struct {
+	Dir
+	faults *FaultFS
+	path   string
+}
+
+func (d *faultDir) Rename(oldpath, name string) error {
+	if err := d.faults.linkErr("rename", oldpath, filepath.Join(d.path, name)); err != nil {
+		return err
+	}
+	return d.Dir.Rename(oldpath, name)
+}
+
+func (d *faultDir) RenameTo(name string, to Dir, newname string) error {
+	target := to
+	if f, ok := to.(*faultDir); ok {
+		if err := d.faults.linkErr("rename", filepath.Join(d.path, name), filepath.Join(f.path, newname)); err != nil {
+			return err
+		}
+		target = f.Dir
+	}
+	return d.Dir.RenameTo(name, target, newname)
+}
+
+func (d *faultDir) Lstat(name string) (fs.FileInfo, error) {
+	if err := d.faults.pathErr("lstat", filepath.Join(d.path, name)); err != nil {
+		return nil, err
+	}
+	return d.Dir.Lstat(name)
+}
+
+func (f *FaultFS) Statfs(path string) (FSStats, error) {
+	if err := f.pathErr("statfs", path); err != nil {
+		return FSStats{}, err
+	}
+	return f.FS.Statfs(path)
+}
+
+func (f *FaultFS) Link(oldname, newname string) error {
+	if err := f.linkErr("link", oldname, newname); err != nil {
+		return err
+	}
+	return f.FS.Link(oldname, newname)
+}
+
+func (f *FaultFS) Symlink(oldname, newname string) error {
+	if err := f.linkErr("symlink", oldname, newname); err != nil {
+		return err
+	}
+	return f.FS.Symlink(oldname, newname)
+}
+
+func (f *FaultFS) Readlink(name string) (string, error) {
+	if err := f.pathErr("readlink", name); err != nil {
+		return "", err
+	}
+	return f.FS.Readlink(name)
+}
+
+func (f *FaultFS) Chmod(name string, mode fs.FileMode) error {
+	if err := f.pathErr("chmod", name); err != nil {
+		return err
+	}
+	return f.FS.Chmod(name, mode)
+}
+
+func (f *FaultFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if err := f.pathErr("chtimes", name); err != nil {
+		return err
+	}
+	return f.FS.Chtimes(name, atime, mtime)
+}
+
+func (f *FaultFS) Attributes(name string) (FileAttributes, error) {
+	if err := f.pathErr("attributes", name); err != nil {
+		return FileAttributes{}, err
+	}
+	return f.FS.Attributes(name)
+}
+
+func (f *FaultFS) SetReadOnly(name string, readOnly bool) error {
+	if err := f.pathErr("setreadonly", name); err != nil {
+		return err
+	}
+	return f.FS.SetReadOnly(name, readOnly)
+}
@@ -0,0 +1,40 @@
+//go:build !linux && !darwin
+
+package remove
+
+import (
+	"errors"
+	"io/fs"
+)
+
+const oNoFollow = 0
+
+const unixModes = false
+
+func statfs(path string) (FSStats, error) {
+	return FSStats{}, errors.New("statfs: not supported on this platform")
+}
+
+func fileDevice(fi fs.FileInfo) (uint64, bool) {
+	return 0, false
+}
+
+func fileInode(fi fs.FileInfo) (uint64, bool) {
+	return 0, false
+}
+
+func IsWhiteout(fi fs.FileInfo) bool {
+	return false
+}
+
+func fileLinks(fi fs.FileInfo) (uint64, bool) {
+	return 0, false
+}
+
+func fileOwner(fi fs.FileInfo) (int, bool) {
+	return 0, false
+}
+
+func checkWritable(path string) error {
+	return nil
+}
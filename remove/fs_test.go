@@ -0,0 +1,299 @@
+package remove
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestFaultFS(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	for _, path := range []string{a, b} {
+		if err := os.WriteFile(path, []byte("x"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	faults := NewFaultFS(OSFS{})
+
+	faults.Inject("lstat", a, syscall.EIO)
+	_, err := faults.Lstat(a)
+	var pathErr *fs.PathError
+	if !errors.Is(err, syscall.EIO) || !errors.As(err, &pathErr) || pathErr.Path != a || pathErr.Op != "lstat" {
+		t.Errorf("lstat of a: %v, want EIO for %s", err, a)
+	}
+	if _, err := faults.Lstat(b); err != nil {
+		t.Errorf("lstat of b: %v", err)
+	}
+	if _, err := faults.Stat(a); err != nil {
+		t.Errorf("stat of a: %v", err)
+	}
+
+	// an empty path faults every path
+	faults.Inject("open", "", syscall.EACCES)
+	for _, path := range []string{a, b} {
+		if _, err := faults.Open(path); !errors.Is(err, syscall.EACCES) {
+			t.Errorf("open of %s: %v, want EACCES", path, err)
+		}
+	}
+
+	faults.Inject("rename", a, syscall.EXDEV)
+	err = faults.Rename(a, filepath.Join(dir, "c"))
+	var linkErr *os.LinkError
+	if !errors.Is(err, syscall.EXDEV) || !errors.As(err, &linkErr) || linkErr.Old != a {
+		t.Errorf("rename of a: %v, want EXDEV", err)
+	}
+	if err := faults.RenameNoReplace(a, b); !errors.Is(err, syscall.EXDEV) {
+		t.Errorf("rename of a without replacing: %v, want EXDEV", err)
+	}
+	if _, err := os.Lstat(a); err != nil {
+		t.Errorf("a faulted rename moved a: %v", err)
+	}
+
+	// and renames inside a directory held open
+	d, err := faults.OpenDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Rename(a, "c"); !errors.Is(err, syscall.EXDEV) {
+		t.Errorf("rename of a into the open directory: %v, want EXDEV", err)
+	}
+	if err := d.Rename(b, "c"); err != nil {
+		t.Errorf("rename of b into the open directory: %v", err)
+	}
+
+	// Before runs once, ahead of the call it is set for
+	ran := 0
+	faults.Before("stat", b, func() { ran++ })
+	faults.Stat(a)
+	faults.Stat(b)
+	faults.Stat(b)
+	if ran != 1 {
+		t.Errorf("Before ran %d times, want once", ran)
+	}
+
+	faults.Clear()
+	for _, op := range []func() error{
+		func() error { _, err := faults.Lstat(a); return err },
+		func() error {
+			f, err := faults.Open(a)
+			if err == nil {
+				f.Close()
+			}
+			return err
+		},
+		func() error { return faults.Rename(a, b) },
+	} {
+		if err := op(); err != nil {
+			t.Errorf("after Clear: %v", err)
+		}
+	}
+}
+
+// A move into the trash that fails partway leaves the operand where it was
+// and nothing of it in the trash, the error saying why; a rename that
+// can't cross devices falls back to a copy
+func TestRemoveFaults(t *testing.T) {
+	tests := []struct {
+		name     string
+		faults   func(env *scratchEnv, path string) // injected before the move
+		err      error                              // nil when the move succeeds
+		strategy string
+	}{
+		{"a rename across devices", func(env *scratchEnv, path string) {
+			env.faults.Inject("rename", path, syscall.EXDEV)
+		}, nil, "copy"},
+		{"a full trash filesystem", func(env *scratchEnv, path string) {
+			env.faults.Inject("rename", path, syscall.EXDEV)
+			env.faults.Inject("create", filepath.Join(env.trash, filepath.Base(path)+".partial"), syscall.ENOSPC)
+		}, syscall.ENOSPC, ""},
+		{"an operand that can't be read", func(env *scratchEnv, path string) {
+			env.faults.Inject("rename", path, syscall.EXDEV)
+			env.faults.Inject("open", path, syscall.EIO)
+		}, syscall.EIO, ""},
+		{"a rename that isn't allowed", func(env *scratchEnv, path string) {
+			env.faults.Inject("rename", path, syscall.EACCES)
+		}, syscall.EACCES, ""},
+		{"a rename into a read-only trash", func(env *scratchEnv, path string) {
+			env.faults.Inject("rename", path, syscall.EROFS)
+		}, syscall.EROFS, ""},
+	}
+	for _, tt := range tests {
+		env := testEnv(t)
+		name := strings.ReplaceAll(tt.name, " ", "-") + ".txt"
+		path, err := env.file(name, tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tt.faults(env, path)
+		result := env.remover(false).Remove(path)
+		env.faults.Clear()
+
+		if tt.err == nil {
+			if result.Err != nil {
+				t.Errorf("%s: %v", tt.name, result.Err)
+			} else if result.Strategy != tt.strategy {
+				t.Errorf("%s: moved by %s, want %s", tt.name, result.Strategy, tt.strategy)
+			} else if err := env.trashed(path, filepath.Base(result.Dest), tt.name); err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+
+		if !errors.Is(result.Err, tt.err) {
+			t.Errorf("%s: %v, want %v", tt.name, result.Err, tt.err)
+		}
+		if got, err := os.ReadFile(path); err != nil || string(got) != tt.name {
+			t.Errorf("%s: the operand has %q, %v", tt.name, got, err)
+		}
+		left, err := os.ReadDir(env.trash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, de := range left {
+			if strings.HasPrefix(de.Name(), name) {
+				t.Errorf("%s: %s was left in the trash", tt.name, de.Name())
+			}
+		}
+		if err := env.indexed(name); err == nil {
+			t.Errorf("%s: the index has a row for it", tt.name)
+		}
+		if pending, err := env.intents.Pending(); err != nil || len(pending) > 0 {
+			t.Errorf("%s: %d intents never settled, %v", tt.name, len(pending), err)
+		}
+	}
+}
+
+// A trash that turns read-only partway through a run is given up for the
+// next one, and reported lost
+func TestTrashTurnsReadOnly(t *testing.T) {
+	env := testEnv(t)
+	lost := filepath.Join(env.root, "lost")
+	if err := os.Mkdir(lost, 0700); err != nil {
+		t.Fatal(err)
+	}
+	first, err := env.file("first.txt", "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := env.file("second.txt", "second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// as a home remounted read-only after a suspend looks from here
+	env.faults.Inject("opendir", lost, syscall.EROFS)
+	env.faults.Inject("create", trashProbe(lost), syscall.EROFS)
+	defer env.faults.Clear()
+
+	r := env.remover(false)
+	r.opts.TrashDir = lost
+	r.opts.PreferTrash = []string{env.trash}
+	for _, path := range []string{first, second} {
+		result := r.Remove(path)
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+		if filepath.Dir(result.Dest) != env.trash {
+			t.Fatalf("%s went to %s, not the other trash", path, result.Dest)
+		}
+	}
+	if lostTrashes := r.LostTrashes(); len(lostTrashes) != 1 || lostTrashes[0] != lost {
+		t.Fatalf("expected %s lost, got %v", lost, lostTrashes)
+	}
+	stranded := Result{Action: "failed", Err: fmt.Errorf("%s: %w", first, ErrTrashLost)}
+	if status := stranded.Status(); status != StatusTrashLost {
+		t.Fatalf("expected status %s, got %s", StatusTrashLost, status)
+	}
+}
+
+// What is moved into the trash is what was planned: an operand swapped for
+// another file between the plan and the move fails with ErrPayloadSwapped
+// and isn't indexed, on a rename or a copy, and a trash directory swapped
+// for a symlink once srm has it open doesn't redirect later moves
+func TestRemoveSwapped(t *testing.T) {
+	// swap replaces path with a new file, the original moved aside
+	swap := func(t *testing.T, path string) func() {
+		return func() {
+			if err := os.Rename(path, path+".orig"); err != nil {
+				t.Error(err)
+			}
+			if err := os.WriteFile(path, []byte("swapped in"), 0600); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+
+	for _, crossDevice := range []bool{false, true} {
+		env := testEnv(t)
+		path, err := env.file("f", "planned")
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := env.remover(false)
+		env.faults.Before("rename", path, swap(t, path))
+		if crossDevice {
+			env.faults.Inject("rename", path, syscall.EXDEV)
+		}
+		result := r.Remove(path)
+
+		if !errors.Is(result.Err, ErrPayloadSwapped) {
+			t.Errorf("across devices %v: got %v, want ErrPayloadSwapped", crossDevice, result.Err)
+		}
+		if entries, err := env.index.Entries(); err != nil || len(entries) > 0 {
+			t.Errorf("across devices %v: indexed %v, %v", crossDevice, entries, err)
+		}
+		if pending, err := env.intents.Pending(); err != nil || len(pending) > 0 {
+			t.Errorf("across devices %v: pending %v, %v", crossDevice, pending, err)
+		}
+		if content, err := os.ReadFile(path + ".orig"); err != nil || string(content) != "planned" {
+			t.Errorf("across devices %v: the planned file is %q, %v", crossDevice, content, err)
+		}
+		if crossDevice {
+			// nothing is copied from a file that isn't the one planned
+			if content, err := os.ReadFile(path); err != nil || string(content) != "swapped in" {
+				t.Errorf("across devices: the swapped in file is %q, %v", content, err)
+			}
+		}
+	}
+
+	env := testEnv(t)
+	a, err := env.file("a", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := env.file("b", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := env.remover(false)
+	if result := r.Remove(a); result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	// the trash goes aside and a symlink elsewhere takes its place
+	elsewhere := filepath.Join(env.root, "elsewhere")
+	if err := os.Mkdir(elsewhere, 0700); err != nil {
+		t.Fatal(err)
+	}
+	held := env.trash + ".held"
+	if err := os.Rename(env.trash, held); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(elsewhere, env.trash); err != nil {
+		t.Fatal(err)
+	}
+	if result := r.Remove(b); result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if content, err := os.ReadFile(filepath.Join(held, "b")); err != nil || string(content) != "b" {
+		t.Errorf("b didn't reach the trash srm had open: %q, %v", content, err)
+	}
+	if names, _ := os.ReadDir(elsewhere); len(names) > 0 {
+		t.Errorf("the symlinked directory got %v", names)
+	}
+}
@@ -0,0 +1,81 @@
+//go:build linux || darwin
+
+package remove
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// oNoFollow makes an open fail on a symlink instead of following it
+const oNoFollow = syscall.O_NOFOLLOW
+
+// unixModes says whether permission bits mean what they say
+const unixModes = true
+
+func statfs(path string) (FSStats, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return FSStats{}, err
+	}
+
+	return FSStats{
+		Type:   uint64(st.Type),
+		Bsize:  int64(st.Bsize),
+		Blocks: st.Blocks,
+		Bfree:  st.Bfree,
+		Bavail: st.Bavail,
+	}, nil
+}
+
+// fileDevice is the device fi lives on. A rename only works within one.
+func fileDevice(fi fs.FileInfo) (uint64, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(st.Dev), true
+}
+
+// fileInode is fi's inode number
+func fileInode(fi fs.FileInfo) (uint64, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(st.Ino), true
+}
+
+// IsWhiteout reports whether fi is an overlayfs whiteout: a character
+// device numbered 0/0, which no real device ever is
+func IsWhiteout(fi fs.FileInfo) bool {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok || fi.Mode()&fs.ModeCharDevice == 0 {
+		return false
+	}
+	return uint64(st.Rdev) == 0
+}
+
+// fileLinks is how many hard links fi has
+func fileLinks(fi fs.FileInfo) (uint64, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(st.Nlink), true
+}
+
+// fileOwner is the uid owning fi
+func fileOwner(fi fs.FileInfo) (int, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return int(st.Uid), true
+}
+
+// checkWritable asks the kernel whether we may write to path
+func checkWritable(path string) error {
+	const W_OK = 2
+	return syscall.Access(path, W_OK)
+}
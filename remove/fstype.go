@@ -0,0 +1,95 @@
+package remove
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FSPolicy applies Policy to operands on filesystems whose type matches
+// Pattern, a glob like tmpfs or fuse.*
+type FSPolicy struct {
+	Pattern string
+	Policy  string
+}
+
+// policyFor returns the first policy matching fstype, "trash" when none does
+func policyFor(policies []FSPolicy, fstype string) (FSPolicy, bool) {
+	for _, p := range policies {
+		if ok, _ := filepath.Match(p.Pattern, fstype); ok {
+			return p, true
+		}
+	}
+	return FSPolicy{Policy: "trash"}, false
+}
+
+// Mount is one line of the mount table
+type Mount struct {
+	Point  string
+	FSType string
+}
+
+// the mount table is read at most once per run
+var (
+	mountsOnce sync.Once
+	mounts     []Mount
+	mountsErr  error
+)
+
+// LoadMounts returns the mount table, read the first time it is needed
+func LoadMounts() ([]Mount, error) {
+	mountsOnce.Do(func() {
+		mounts, mountsErr = readMounts()
+	})
+	return mounts, mountsErr
+}
+
+// realPath makes path absolute with the symlinks above it resolved; the
+// last element, which is what moves, is left as it is
+func realPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(abs)); err == nil {
+		abs = filepath.Join(dir, filepath.Base(abs))
+	}
+	return abs, nil
+}
+
+// mountOf returns the mount with the longest mount point containing the
+// absolute path abs, or a zero Mount when none does
+func mountOf(abs string, mounts []Mount) Mount {
+	best := Mount{}
+	for _, m := range mounts {
+		inside := abs == m.Point || strings.HasPrefix(abs, childPath(m.Point, ""))
+		if inside && len(m.Point) >= len(best.Point) {
+			best = m
+		}
+	}
+	return best
+}
+
+// COWFILESYSTEMS are the filesystem types that write changes to a file
+// somewhere new rather than over it, so -P can't reach the old contents
+var COWFILESYSTEMS = []string{"btrfs", "zfs", "bcachefs", "nilfs2", "apfs"}
+
+// fstypeOf returns the type of the filesystem path is on, going by the
+// mount with the longest mount point containing it
+func fstypeOf(path string) (string, error) {
+	mounts, err := LoadMounts()
+	if err != nil {
+		return "", err
+	}
+	abs, err := realPath(path)
+	if err != nil {
+		return "", err
+	}
+
+	best := mountOf(abs, mounts)
+	if best.Point == "" {
+		return "", fmt.Errorf("%s: not under any mount point", path)
+	}
+	return best.FSType, nil
+}
@@ -0,0 +1,119 @@
+package remove
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// HiddenDepth reads --keep-hidden or --hidden-only, given bare for depth 1
+// or as --name=DEPTH. 0 means the flag wasn't given.
+func HiddenDepth(name string, flags []string) (int, error) {
+	value, ok := FlagValue(name, flags)
+	if !ok {
+		if In(name, flags) {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	depth, err := strconv.Atoi(value)
+	if err != nil || depth < 1 {
+		return 0, fmt.Errorf("invalid %s: %s (expected a depth of 1 or more)", name, value)
+	}
+	return depth, nil
+}
+
+// isHidden reports whether name is a dotfile
+func isHidden(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
+
+// removeFiltered empties the directory operand dir according to
+// --keep-hidden or --hidden-only instead of removing it whole. Entries down
+// to the given depth are sorted into ones to trash, each removed as its own
+// operand, and ones to keep, reported with Action "kept". A directory that
+// holds anything kept is descended into rather than removed, so it stays.
+// The result is for dir itself, which always stays, with the joined errors
+// of whatever failed inside it.
+func (r *Remover) removeFiltered(dir string, plan Plan) Result {
+	result := Result{Action: "kept", Source: dir, IsDir: true, Op: r.opts.Op}
+	depth := r.opts.KeepHidden
+	if r.opts.HiddenOnly > 0 {
+		depth = r.opts.HiddenOnly
+	}
+
+	// entries are read up front, the directory is changing as they are removed
+	errs := []error{}
+	var walk func(path string, level int)
+	walk = func(path string, level int) {
+		children, err := r.fs.ReadDir(path)
+		if err != nil {
+			errs = append(errs, displayErr(err, path))
+			return
+		}
+		for _, de := range children {
+			child := filepath.Join(path, de.Name())
+			switch {
+			case r.descends(child, de, level, depth):
+				walk(child, level+1)
+			case r.keeps(de.Name()):
+				r.kept(child)
+			default:
+				if res := r.removeEntry(child); res.Status().Info().Fails {
+					errs = append(errs, res.Err)
+				}
+			}
+		}
+	}
+	walk(plan.Path, 1)
+
+	result.Err = errors.Join(errs...)
+	if result.Err != nil {
+		result.Action = "failed"
+	}
+	return result
+}
+
+// keeps reports whether an entry called name is one the filter leaves alone
+func (r *Remover) keeps(name string) bool {
+	if r.opts.HiddenOnly > 0 {
+		return !isHidden(name)
+	}
+	return isHidden(name)
+}
+
+// descends reports whether the filter looks inside child rather than
+// keeping or removing it whole: only visible directories above the depth
+// limit, never symlinks to them, and for --keep-hidden only those with
+// something to keep inside
+func (r *Remover) descends(child string, de fs.DirEntry, level int, depth int) bool {
+	if level >= depth || !de.IsDir() || isHidden(de.Name()) {
+		return false
+	}
+	return r.opts.HiddenOnly > 0 || r.holdsKept(child, level+1, depth)
+}
+
+// holdsKept reports whether --keep-hidden would keep anything inside dir
+func (r *Remover) holdsKept(dir string, level int, depth int) bool {
+	children, err := r.fs.ReadDir(dir)
+	if err != nil {
+		// descending reports the error where it happens
+		return true
+	}
+	for _, de := range children {
+		if isHidden(de.Name()) || r.descends(filepath.Join(dir, de.Name()), de, level, depth) {
+			return true
+		}
+	}
+	return false
+}
+
+// kept reports an entry the filter left in place
+func (r *Remover) kept(path string) {
+	if r.opts.Callbacks.OnEntryDone != nil {
+		r.opts.Callbacks.OnEntryDone(Result{Action: "kept", Source: path, Op: r.opts.Op})
+	}
+}
@@ -0,0 +1,453 @@
+package remove
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/shanahanjrs/srm/trashquery"
+)
+
+// IndexEntry is srm's record of one thing it put into a trash
+type IndexEntry struct {
+	// Schema is the SCHEMAVERSION the row was written with
+	Schema  int       `json:"v,omitempty"`
+	ID      string    `json:"id"`
+	Trash   string    `json:"trash"`  // trash directory the payload lives in
+	Name    string    `json:"name"`   // payload name inside Trash
+	Origin  string    `json:"origin"` // absolute path it was removed from
+	Deleted time.Time `json:"deleted"`
+	Size    int64     `json:"size,omitempty"`
+	IsDir   bool      `json:"dir,omitempty"`
+	Op      string    `json:"op,omitempty"`
+	// Archive is the archive format when the payload is a tarball of the
+	// original directory rather than the directory itself
+	Archive string `json:"archive,omitempty"`
+	// Reason is the --reason note, percent-encoded so any bytes survive
+	Reason string `json:"reason,omitempty"`
+	// Times are the payload's original timestamps
+	Times *FileTimes `json:"times,omitempty"`
+
+	// RawTrash, RawName and RawOrigin hold the exact bytes of paths that
+	// aren't valid UTF-8, which a JSON string can't carry
+	RawTrash  []byte `json:"trash_raw,omitempty"`
+	RawName   []byte `json:"name_raw,omitempty"`
+	RawOrigin []byte `json:"origin_raw,omitempty"`
+
+	// Gone marks the entry as no longer in the trash. Rows are only ever
+	// appended, so a later Gone row cancels an earlier one with the same ID.
+	Gone bool `json:"gone,omitempty"`
+}
+
+// Payload is the full path of the entry's payload
+func (e IndexEntry) Payload() string {
+	return filepath.Join(e.Trash, e.Name)
+}
+
+// ReasonText is the decoded --reason note
+func (e IndexEntry) ReasonText() string {
+	text, err := url.PathUnescape(e.Reason)
+	if err != nil {
+		return e.Reason
+	}
+	return text
+}
+
+// EncodeRaw fills in the Raw fields for paths JSON would mangle
+func (e IndexEntry) EncodeRaw() IndexEntry {
+	e.Schema = SCHEMAVERSION
+	e.RawTrash, e.RawName, e.RawOrigin = rawBytes(e.Trash), rawBytes(e.Name), rawBytes(e.Origin)
+	return e
+}
+
+// DecodeRaw puts the exact bytes from the Raw fields back into the paths
+func (e *IndexEntry) DecodeRaw() {
+	if e.RawTrash != nil {
+		e.Trash = string(e.RawTrash)
+	}
+	if e.RawName != nil {
+		e.Name = string(e.RawName)
+	}
+	if e.RawOrigin != nil {
+		e.Origin = string(e.RawOrigin)
+	}
+	e.RawTrash, e.RawName, e.RawOrigin = nil, nil, nil
+}
+
+// Index is the append-only file of IndexEntry rows, and the segments
+// srms append to before their rows are folded into it, see segments.go
+type Index struct {
+	path string
+	// segment is the name of the segment this srm appends to
+	segment string
+	// recovered is set once recover has run, so a bad index is set aside
+	// once per run however many times it is read
+	recovered bool
+}
+
+// OpenIndex returns the index in srm's data dir
+func OpenIndex() (*Index, error) {
+	dir, err := DataDir()
+	if err != nil {
+		return nil, err
+	}
+	return &Index{path: filepath.Join(dir, "index")}, nil
+}
+
+// NewIndex returns the index kept at path instead of in srm's data dir
+func NewIndex(path string) *Index {
+	return &Index{path: path}
+}
+
+// Path is where the index is kept, which trashquery.Open reads
+func (ix *Index) Path() string {
+	return ix.path
+}
+
+func NewEntryID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Append adds rows to the index, in one write to this srm's segment so a
+// reader never sees half of them and no other srm writes between them
+func (ix *Index) Append(entries ...IndexEntry) error {
+	path, many, err := ix.segmentPath()
+	if err != nil {
+		return err
+	}
+	if many {
+		// best effort: the rows are as safe in the segments as folded
+		defer ix.Fold()
+	}
+	f, err := OpenPrivate(path, os.O_RDWR|os.O_APPEND|os.O_CREATE)
+	if err != nil {
+		return err
+	}
+
+	buf := []byte{}
+	for _, entry := range entries {
+		line, err := json.Marshal(entry.EncodeRaw())
+		if err != nil {
+			f.Close()
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	if err := endTornRow(f); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// endTornRow puts a newline after a last row that a full disk or a crash
+// cut short, so the rows about to be appended to f aren't glued onto it and
+// lost with it. f must be open for reading as well as appending.
+func endTornRow(f *os.File) error {
+	fi, err := f.Stat()
+	if err != nil || fi.Size() == 0 {
+		return err
+	}
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, fi.Size()-1); err != nil {
+		return err
+	}
+	if last[0] == '\n' {
+		return nil
+	}
+	_, err = f.Write([]byte{'\n'})
+	return err
+}
+
+// Forget appends Gone rows for entries that have left the trash
+func (ix *Index) Forget(entries ...IndexEntry) error {
+	gone := []IndexEntry{}
+	for _, entry := range entries {
+		gone = append(gone, IndexEntry{ID: entry.ID, Gone: true})
+	}
+	return ix.Append(gone...)
+}
+
+// Entries returns every entry still in the trash, oldest first. An index
+// with rows that don't parse is rebuilt first, see recover.
+func (ix *Index) Entries() ([]IndexEntry, error) {
+	entries, bad, err := ix.read()
+	if err != nil || bad == 0 || ix.recovered {
+		return entries, err
+	}
+	return ix.recover(entries, bad), nil
+}
+
+// read is Entries without the recovery, also returning how many rows
+// didn't parse
+func (ix *Index) read() ([]IndexEntry, int, error) {
+	f, err := ix.openRows()
+	if err != nil {
+		return nil, 0, err
+	}
+	return readRows(f)
+}
+
+// readRows is read of the files f
+func readRows(f *rowFiles) ([]IndexEntry, int, error) {
+	defer f.Close()
+
+	bad := 0
+	order := []string{}
+	byID := map[string]IndexEntry{}
+	scanner := bufio.NewScanner(f.reader())
+	scanner.Buffer(make([]byte, 64*1024), trashquery.MAXROW)
+	scanner.Split(trashquery.SplitRows)
+	for scanner.Scan() {
+		var entry IndexEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil || entry.ID == "" {
+			if len(scanner.Bytes()) > 0 {
+				bad++
+			}
+			continue
+		}
+		entry.DecodeRaw()
+		entry.upgrade()
+		if entry.Gone {
+			delete(byID, entry.ID)
+			continue
+		}
+		if _, ok := byID[entry.ID]; !ok {
+			order = append(order, entry.ID)
+		}
+		byID[entry.ID] = entry
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	entries := []IndexEntry{}
+	for _, id := range order {
+		if entry, ok := byID[id]; ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, bad, nil
+}
+
+// recover deals with an index that has rows which don't parse, like one a
+// full disk cut short before more was appended. Such an index is moved
+// aside as index.corrupt-TIME and a new one written from the rows that did
+// parse, keeping those whose payload a scan of the trash still finds;
+// payloads left without a row show up in srm list and srm empty as they
+// always have, with no origin. The command reading the index goes on with
+// what is returned rather than failing, whatever goes wrong here. The
+// segments are folded in first, so their bad rows are set aside too; the
+// newest, which may still be written to, is kept and read after the new
+// index as before. While another srm folds, recovery waits for a later run.
+func (ix *Index) recover(entries []IndexEntry, bad int) []IndexEntry {
+	ix.recovered = true
+	unlock, ok, _ := ix.lockFold()
+	if !ok {
+		return entries
+	}
+	defer unlock()
+	if folded, err := ix.fold(); err == nil && folded > 0 {
+		if reread, stillBad, err := ix.read(); err == nil {
+			entries, bad = reread, stillBad
+		}
+	}
+	kept := []IndexEntry{}
+	for _, entry := range entries {
+		if _, err := os.Lstat(entry.Payload()); err == nil {
+			kept = append(kept, entry)
+		}
+	}
+
+	aside := ix.path + ".corrupt-" + time.Now().Format("20060102-150405")
+	if err := os.Rename(ix.path, aside); err != nil {
+		fmt.Fprintf(os.Stderr, "srm: warning: %d row(s) of %s don't parse, and it can't be set aside: %s\n", bad, DisplayName(ix.path), err)
+		return entries
+	}
+	if err := ix.Rewrite(kept); err != nil {
+		fmt.Fprintf(os.Stderr, "srm: warning: %d row(s) of %s didn't parse, it was moved to %s but can't be rebuilt: %s\n", bad, DisplayName(ix.path), DisplayName(aside), err)
+		return kept
+	}
+	fmt.Fprintf(os.Stderr, "srm: warning: %d row(s) of %s didn't parse; moved it to %s and rebuilt it from the %d entries still in the trash\n", bad, DisplayName(ix.path), DisplayName(aside), len(kept))
+	return kept
+}
+
+// HashString is what Scan keeps in memory per entry instead of its ID
+func HashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// Scan calls fn with every entry still in the trash and the offset of its
+// row, stopping early when fn returns false. Unlike Entries it never holds
+// the entries themselves: the first pass keeps 16 bytes per row to work out
+// which rows are live, the second just their 4 byte row numbers. Commands
+// that may face an index of millions of rows use it instead. An index with
+// rows that don't parse is rebuilt first, as by Entries.
+func (ix *Index) Scan(fn func(entry IndexEntry, offset int64) bool) error {
+	f, err := ix.openRows()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	live, bad, err := trashquery.LiveRows(f.reader())
+	if err != nil {
+		return err
+	}
+	if bad > 0 && !ix.recovered {
+		f.Close()
+		ix.Entries()
+		ix.recovered = true
+		return ix.Scan(fn)
+	}
+
+	// second pass: hand out the live rows, which come in row order
+	var row uint32
+	var offset int64
+	scanner := bufio.NewScanner(f.reader())
+	scanner.Buffer(make([]byte, 64*1024), trashquery.MAXROW)
+	scanner.Split(trashquery.SplitRows)
+	for scanner.Scan() && len(live) > 0 {
+		line := scanner.Bytes()
+		lineOffset, thisRow := offset, row
+		offset += int64(len(line)) + 1
+		row++
+		if thisRow != live[0] {
+			continue
+		}
+		live = live[1:]
+
+		var entry IndexEntry
+		if json.Unmarshal(line, &entry) != nil {
+			continue
+		}
+		entry.DecodeRaw()
+		entry.upgrade()
+		if !fn(entry, lineOffset) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// IndexReader reads single rows at the offsets Scan hands out
+type IndexReader struct {
+	f *rowFiles
+}
+
+// OpenReader opens the index for EntryAt lookups
+func (ix *Index) OpenReader() (*IndexReader, error) {
+	f, err := ix.openRows()
+	if err != nil {
+		return nil, err
+	}
+	return &IndexReader{f: f}, nil
+}
+
+// At reads the row at offset. The index may have been compacted or its
+// segments folded since the offset was handed out, so callers check it is
+// the entry they expected.
+func (r *IndexReader) At(offset int64) (IndexEntry, error) {
+	section, err := r.f.section(offset)
+	if err != nil {
+		return IndexEntry{}, err
+	}
+	line, err := bufio.NewReader(section).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return IndexEntry{}, err
+	}
+	var entry IndexEntry
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return IndexEntry{}, err
+	}
+	entry.DecodeRaw()
+	return entry, nil
+}
+
+func (r *IndexReader) Close() error {
+	return r.f.Close()
+}
+
+// Compact folds the segments into the index and rewrites it without Gone
+// rows and superseded duplicates, returning how many entries it kept. The
+// newest segment is left to the srm that may still be writing it, and read
+// after the compacted index as before.
+func (ix *Index) Compact() (int, error) {
+	unlock, ok, err := ix.lockFold()
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrIndexBusy
+	}
+	defer unlock()
+	if _, err := ix.fold(); err != nil {
+		return 0, err
+	}
+	// with index.fold held, only the newest segment is left
+	f, err := openRowFiles([]string{ix.path})
+	if err != nil {
+		return 0, err
+	}
+	entries, _, err := readRows(f)
+	if err != nil {
+		return 0, err
+	}
+	return len(entries), ix.Rewrite(entries)
+}
+
+// Rewrite replaces the file index with exactly entries, dropping Gone rows
+// and superseded duplicates, and leaving the segments be. The new file is
+// renamed into place so readers never see a half written index.
+func (ix *Index) Rewrite(entries []IndexEntry) error {
+	if err := MkdirPrivate(filepath.Dir(ix.path)); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(ix.path), ".index-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := PrivateMode(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	w := bufio.NewWriter(tmp)
+	for _, entry := range entries {
+		line, err := json.Marshal(entry.EncodeRaw())
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		w.Write(append(line, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), ix.path)
+}
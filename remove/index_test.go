@@ -0,0 +1,293 @@
+package remove
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shanahanjrs/srm/trashquery"
+)
+
+// MEMORYTARGET is what reading an index may take per row: 100 MB for a
+// million entries. FIXEDMEMORY is allowed on top for buffers.
+const (
+	MEMORYTARGET = 100
+	FIXEDMEMORY  = 8 << 20
+)
+
+// syntheticIndex writes an index of rows rows to dir: one in ten a later
+// row for an earlier ID and one in ten a Gone row, as a long-used trash has.
+// It returns the index and how many entries are live.
+func syntheticIndex(t testing.TB, dir string, rows int) (*Index, int) {
+	t.Helper()
+	path := filepath.Join(dir, "index")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := bufio.NewWriter(f)
+	deleted := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	live := 0
+	for i := 0; i < rows; i++ {
+		entry := IndexEntry{
+			ID:      fmt.Sprintf("%08x", i),
+			Trash:   "/home/user/.local/share/Trash/files",
+			Name:    fmt.Sprintf("file-%d.log", i),
+			Origin:  fmt.Sprintf("/home/user/work/project-%d/file-%d.log", i%97, i),
+			Deleted: deleted.Add(time.Duration(i) * time.Second),
+			Size:    int64(i % 65536),
+		}
+		switch i % 10 {
+		case 3:
+			entry.ID = fmt.Sprintf("%08x", i-1)
+		case 7:
+			entry = IndexEntry{ID: fmt.Sprintf("%08x", i-1), Gone: true}
+			live--
+		default:
+			live++
+		}
+		line, err := json.Marshal(entry.EncodeRaw())
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(append(line, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &Index{path: path}, live
+}
+
+// peakHeap runs fn and returns the most heap it held over what was in use
+// before, sampled every millisecond
+func peakHeap(fn func()) uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	base := stats.HeapAlloc
+
+	var peak uint64
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			var stats runtime.MemStats
+			runtime.ReadMemStats(&stats)
+			peak = max(peak, stats.HeapAlloc)
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	fn()
+	close(done)
+	wg.Wait()
+	if peak < base {
+		return 0
+	}
+	return peak - base
+}
+
+// Scan, which search and du use, and trashquery, which list uses, stay
+// under MEMORYTARGET bytes a row however big the index grows
+func TestIndexMemory(t *testing.T) {
+	rows := 1000000
+	if testing.Short() {
+		rows = 100000
+	}
+	ix, live := syntheticIndex(t, t.TempDir(), rows)
+	budget := uint64(MEMORYTARGET*rows + FIXEDMEMORY)
+
+	count := 0
+	peak := peakHeap(func() {
+		err := ix.Scan(func(entry IndexEntry, offset int64) bool {
+			count++
+			return true
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	})
+	if count != live {
+		t.Errorf("Scan found %d entries, want %d", count, live)
+	}
+	if peak > budget {
+		t.Errorf("Scan of %d rows held %d MB, over the %d MB target", rows, peak>>20, budget>>20)
+	}
+	t.Logf("Scan of %d rows: %d MB", rows, peak>>20)
+
+	count = 0
+	peak = peakHeap(func() {
+		for _, err := range trashquery.Open(ix.path).ListEntries(trashquery.Filter{}) {
+			if err != nil {
+				t.Error(err)
+				break
+			}
+			count++
+		}
+	})
+	if count != live {
+		t.Errorf("trashquery found %d entries, want %d", count, live)
+	}
+	if peak > budget {
+		t.Errorf("trashquery over %d rows held %d MB, over the %d MB target", rows, peak>>20, budget>>20)
+	}
+	t.Logf("trashquery over %d rows: %d MB", rows, peak>>20)
+}
+
+func BenchmarkIndexScan(b *testing.B) {
+	ix, _ := syntheticIndex(b, b.TempDir(), 100000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := ix.Scan(func(IndexEntry, int64) bool { return true }); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Rows as srm wrote them before SCHEMAVERSION, and as a newer srm with a
+// field this one doesn't know might, are read for the fields it knows
+func TestIndexSchemaVersions(t *testing.T) {
+	fixture := `{"id":"0a","trash":"/t","name":"old","origin":"/w/old","deleted":"2024-01-02T03:04:05Z","size":3}
+{"id":"0b","trash":"/t","name":"gone","origin":"/w/gone","deleted":"2024-01-02T03:04:05Z"}
+{"id":"0b","trash":"","name":"","origin":"","deleted":"0001-01-01T00:00:00Z","gone":true}
+{"v":1,"id":"1a","trash":"/t","name":"current","origin":"/w/current","deleted":"2025-01-02T03:04:05Z"}
+{"v":99,"id":"9a","trash":"/t","name":"new","origin":"/w/new","deleted":"2030-01-02T03:04:05Z","future":{"x":1}}
+`
+	index := &Index{path: filepath.Join(t.TempDir(), "index")}
+	if err := os.WriteFile(index.path, []byte(fixture), 0600); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := index.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		name, origin string
+		size         int64
+		schema       int
+	}{
+		{"old", "/w/old", 3, SCHEMAVERSION},
+		{"current", "/w/current", 0, SCHEMAVERSION},
+		{"new", "/w/new", 0, 99},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("read %d entries, want %d: %+v", len(entries), len(want), entries)
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.Name != w.name || e.Origin != w.origin || e.Size != w.size || e.Schema != w.schema {
+			t.Errorf("entry %d: %s from %s, %d bytes, version %d; want %s from %s, %d bytes, version %d",
+				i, e.Name, e.Origin, e.Size, e.Schema, w.name, w.origin, w.size, w.schema)
+		}
+	}
+	if _, err := os.Stat(index.path); err != nil {
+		t.Errorf("the index was set aside as corrupt: %v", err)
+	}
+}
+
+// Two srms sharing an index each append to a segment of their own, and the
+// segments fold into the index in order, a torn row in one read as nothing
+func TestIndexSegments(t *testing.T) {
+	env := testEnv(t)
+	// two srms sharing an index, each appending to a segment of its own
+	path := filepath.Join(env.root, "data", "index-segments")
+	a, b := &Index{path: path}, &Index{path: path}
+	row := func(id string) IndexEntry {
+		return IndexEntry{ID: id, Trash: "/t", Name: id, Origin: "/w/" + id, Deleted: time.Now()}
+	}
+	if err := a.Append(row("s1"), row("s2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Forget(row("s1")); err != nil {
+		t.Fatal(err)
+	}
+	// a's segment isn't the newest any more, so it starts another
+	if err := a.Append(row("s3")); err != nil {
+		t.Fatal(err)
+	}
+	names, err := a.segments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 3 {
+		t.Fatalf("expected 3 segments, got %q", names)
+	}
+	// a row still being written to a segment another has been started
+	// after is no row yet, and doesn't swallow that one's first
+	torn, err := os.OpenFile(filepath.Join(a.segmentDir(), names[1]), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	torn.WriteString(`{"id":"s4","trash":"/t"`)
+	torn.Close()
+
+	want := func(when string) error {
+		entries, bad, err := a.read()
+		if err != nil {
+			return err
+		}
+		if bad != 0 || len(entries) != 2 || entries[0].ID != "s2" || entries[1].ID != "s3" {
+			return fmt.Errorf("%s: read %+v, %d bad", when, entries, bad)
+		}
+		query := trashquery.Open(path)
+		ids := []string{}
+		for got, err := range query.ListEntries(trashquery.Filter{}) {
+			if err != nil {
+				return err
+			}
+			if at, err := query.At(got.Offset); err != nil || at.ID != got.ID {
+				return fmt.Errorf("%s: trashquery's At(%d) is %+v, %v, not %s", when, got.Offset, at, err, got.ID)
+			}
+			ids = append(ids, got.ID)
+		}
+		if strings.Join(ids, " ") != "s2 s3" {
+			return fmt.Errorf("%s: trashquery lists %q", when, ids)
+		}
+		return nil
+	}
+	if err := want("segments"); err != nil {
+		t.Fatal(err)
+	}
+	if folded, err := a.Fold(); err != nil || folded != 2 {
+		t.Fatalf("folded %d segments, %v, expected all but the newest", folded, err)
+	}
+	if err := want("folded"); err != nil {
+		t.Fatal(err)
+	}
+
+	// compaction waits for a fold, and leaves the newest segment
+	unlock, ok, err := b.lockFold()
+	if !ok {
+		t.Fatalf("index.fold not taken: %v", err)
+	}
+	if _, err := a.Compact(); !errors.Is(err, ErrIndexBusy) {
+		unlock()
+		t.Fatalf("compacted while folding: %v", err)
+	}
+	unlock()
+	if live, err := a.Compact(); err != nil || live != 1 {
+		t.Fatalf("compaction kept %d entries, %v, expected s2 with s3 in the newest segment", live, err)
+	}
+	if err := want("compacted"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,253 @@
+package remove
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/shanahanjrs/srm/trashquery"
+)
+
+// IntentRecord is one line of an intent log. An intent is written, and
+// synced, before a payload is moved into the trash; done follows once its
+// index row is written. An intent without a done is a move that may have
+// happened without srm getting to record it.
+type IntentRecord struct {
+	Kind  string      `json:"kind"` // intent or done
+	ID    string      `json:"id"`
+	Time  time.Time   `json:"time"`
+	Entry *IndexEntry `json:"entry,omitempty"`
+}
+
+// INTENTGRACE is how old an intent must be before replay settles it, so one
+// srm never settles a move another is still in the middle of
+var INTENTGRACE = time.Minute
+
+// IntentLog is the write-ahead log of moves into one trash directory
+type IntentLog struct {
+	path string
+}
+
+// OpenIntentLog returns the intent log for trashDir, kept in srm's data dir
+// so it never shows up as a trash entry
+func OpenIntentLog(trashDir string) (*IntentLog, error) {
+	dir, err := DataDir()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(trashDir))
+	return &IntentLog{path: filepath.Join(dir, "intents", hex.EncodeToString(sum[:8]))}, nil
+}
+
+// NewIntentLog returns the intent log kept at path instead of in srm's
+// data dir
+func NewIntentLog(path string) *IntentLog {
+	return &IntentLog{path: path}
+}
+
+func (l *IntentLog) write(records []IntentRecord, sync bool) error {
+	f, err := OpenPrivate(l.path, os.O_RDWR|os.O_APPEND|os.O_CREATE)
+	if err != nil {
+		return err
+	}
+	if err := endTornRow(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	// one write for the lot, so a batch costs one sync rather than one each
+	buf := []byte{}
+	now := time.Now()
+	for _, record := range records {
+		record.Time = now
+		line, err := json.Marshal(record)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	_, err = f.Write(buf)
+	if err == nil && sync {
+		err = f.Sync()
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Begin records that entries' payloads are about to be moved into the
+// trash. It is synced, so after a crash the moves are never unaccounted for.
+func (l *IntentLog) Begin(entries ...IndexEntry) error {
+	records := []IntentRecord{}
+	for _, entry := range entries {
+		encoded := entry.EncodeRaw()
+		records = append(records, IntentRecord{Kind: "intent", ID: entry.ID, Entry: &encoded})
+	}
+	return l.write(records, true)
+}
+
+// Done records that the moves of ids are settled, either indexed or failed
+// in a way srm saw
+func (l *IntentLog) Done(ids ...string) error {
+	records := []IntentRecord{}
+	for _, id := range ids {
+		records = append(records, IntentRecord{Kind: "done", ID: id})
+	}
+	return l.write(records, false)
+}
+
+// Pending returns the entries of intents that never got a done, oldest first
+func (l *IntentLog) Pending() ([]IndexEntry, error) {
+	f, err := os.Open(l.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	order := []string{}
+	open := map[string]IndexEntry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	scanner.Split(trashquery.SplitRows)
+	for scanner.Scan() {
+		var record IntentRecord
+		// a torn line is an intent that was never synced, so never acted on
+		if json.Unmarshal(scanner.Bytes(), &record) != nil || record.ID == "" {
+			continue
+		}
+		switch {
+		case record.Kind == "intent" && record.Entry != nil:
+			// logged again when its move had to take another name
+			record.Entry.DecodeRaw()
+			if _, ok := open[record.ID]; !ok {
+				order = append(order, record.ID)
+			}
+			open[record.ID] = *record.Entry
+		case record.Kind == "done":
+			delete(open, record.ID)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	pending := []IndexEntry{}
+	for _, id := range order {
+		if entry, ok := open[id]; ok {
+			pending = append(pending, entry)
+		}
+	}
+	return pending, nil
+}
+
+// Replay settles every pending intent. A payload that reached the trash
+// gets the index row the interrupted run never wrote; one that didn't means
+// the move never happened and the intent is dropped. Intents younger than
+// INTENTGRACE are left for the run that wrote them, without reading the
+// index, which is what every run starts with while srms run side by side.
+func (l *IntentLog) Replay(fsys FS, index *Index) (finished []IndexEntry, dropped []IndexEntry, err error) {
+	all, err := l.Pending()
+	if err != nil {
+		return nil, nil, err
+	}
+	pending := []IndexEntry{}
+	for _, entry := range all {
+		if time.Since(entry.Deleted) >= INTENTGRACE {
+			pending = append(pending, entry)
+		}
+	}
+	if len(pending) == 0 {
+		return nil, nil, nil
+	}
+
+	// only the pending IDs are looked for, the index may be huge
+	indexed := map[string]bool{}
+	for _, entry := range pending {
+		indexed[entry.ID] = false
+	}
+	err = index.Scan(func(entry IndexEntry, offset int64) bool {
+		if _, ok := indexed[entry.ID]; ok {
+			indexed[entry.ID] = true
+		}
+		return true
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, entry := range pending {
+		_, statErr := fsys.Lstat(entry.Payload())
+		switch {
+		case indexed[entry.ID]:
+			// the index row made it, only the done record didn't
+		case statErr == nil:
+			entry.Size, _ = DiskUsage(fsys, entry.Payload())
+			if err := index.Append(entry); err != nil {
+				return finished, dropped, err
+			}
+			finished = append(finished, entry)
+		case errors.Is(statErr, fs.ErrNotExist):
+			dropped = append(dropped, entry)
+		default:
+			return finished, dropped, statErr
+		}
+		if err := l.Done(entry.ID); err != nil {
+			return finished, dropped, err
+		}
+	}
+	return finished, dropped, nil
+}
+
+// Compact empties the log of everything settled, rewriting it with just the
+// intents still pending, or truncating it when there are none. An intent
+// another srm logs while it does so is lost, so it is left to srm gc,
+// maintenance and a log past INTENTLOGMAX. While another srm compacts the
+// log, as the srms xargs -P starts all find it past INTENTLOGMAX, it
+// leaves it to that one.
+func (l *IntentLog) Compact() error {
+	unlock, ok, err := lockFile(l.path + ".lock")
+	if !ok {
+		return err
+	}
+	defer unlock()
+	pending, err := l.Pending()
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		err = os.Truncate(l.path, 0)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	tmp := &IntentLog{path: l.path + ".compact"}
+	os.Remove(tmp.path)
+	if err := tmp.Begin(pending...); err != nil {
+		os.Remove(tmp.path)
+		return err
+	}
+	return os.Rename(tmp.path, l.path)
+}
+
+// Size is how big the log is, 0 when there isn't one
+func (l *IntentLog) Size() int64 {
+	fi, err := os.Stat(l.path)
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
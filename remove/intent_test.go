@@ -0,0 +1,223 @@
+package remove
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Compacting the intent log keeps only the intents still pending
+func TestIntentLogCompact(t *testing.T) {
+	root := t.TempDir()
+	intents := &IntentLog{path: filepath.Join(root, "intents")}
+	for i := 0; i < 5; i++ {
+		id := strconv.Itoa(i)
+		if err := intents.Begin(IndexEntry{ID: id, Trash: filepath.Join(root, "trash"), Name: id, Origin: filepath.Join(root, id)}); err != nil {
+			t.Fatal(err)
+		}
+		if i != 2 {
+			if err := intents.Done(id); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	before := intents.Size()
+	if err := intents.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	pending, err := intents.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].ID != "2" || intents.Size() >= before {
+		t.Errorf("compacting kept %d intents, %d bytes of %d, want only intent 2", len(pending), intents.Size(), before)
+	}
+}
+
+// settleNow makes Replay settle intents however young, for the length of
+// the test
+func settleNow(t *testing.T) {
+	old := INTENTGRACE
+	INTENTGRACE = 0
+	t.Cleanup(func() { INTENTGRACE = old })
+}
+
+// rows counts the index rows for id
+func rows(t *testing.T, index *Index, id string) int {
+	t.Helper()
+	entries, err := index.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, entry := range entries {
+		if entry.ID == id {
+			n++
+		}
+	}
+	return n
+}
+
+// A move into the trash is begun, renamed, indexed and settled, in that
+// order. A crash after any of those steps, or a rename failing with the
+// run stopping there, is put right by Replay: the operand ends up either
+// where it was with no row, or in the trash with exactly one.
+func TestIntentLogCrash(t *testing.T) {
+	settleNow(t)
+	steps := []string{"nothing", "begin", "move", "index", "done"}
+	for _, renameErr := range []error{nil, syscall.EIO, syscall.ENOSPC} {
+		for crash := range steps {
+			name := fmt.Sprintf("crash after %s, rename failing with %v", steps[crash], renameErr)
+			env := testEnv(t)
+			path, err := env.file("f", "contents")
+			if err != nil {
+				t.Fatal(err)
+			}
+			entry := IndexEntry{ID: NewEntryID(), Trash: env.trash, Name: "f", Origin: path, Deleted: time.Now()}
+			if renameErr != nil {
+				env.faults.Inject("rename", path, renameErr)
+			}
+
+			moved := false
+			func() {
+				if crash < 1 {
+					return
+				}
+				if err := env.intents.Begin(entry); err != nil {
+					t.Fatal(err)
+				}
+				if crash < 2 {
+					return
+				}
+				if err := env.faults.Rename(path, entry.Payload()); err != nil {
+					// a failed move is settled at once, as the Remover does
+					if !errors.Is(err, renameErr) {
+						t.Fatalf("%s: rename: %v", name, err)
+					}
+					if err := env.intents.Done(entry.ID); err != nil {
+						t.Fatal(err)
+					}
+					return
+				}
+				moved = true
+				if crash < 3 {
+					return
+				}
+				if err := env.index.Append(entry); err != nil {
+					t.Fatal(err)
+				}
+				if crash < 4 {
+					return
+				}
+				if err := env.intents.Done(entry.ID); err != nil {
+					t.Fatal(err)
+				}
+			}()
+
+			finished, dropped, err := env.intents.Replay(OSFS{}, env.index)
+			if err != nil {
+				t.Fatalf("%s: replay: %v", name, err)
+			}
+			if pending, err := env.intents.Pending(); err != nil || len(pending) > 0 {
+				t.Errorf("%s: still pending after replay: %v, %v", name, pending, err)
+			}
+			if moved {
+				if err := env.trashed(path, "f", "contents"); err != nil {
+					t.Errorf("%s: %v", name, err)
+				}
+				if n := rows(t, env.index, entry.ID); n != 1 {
+					t.Errorf("%s: %d index rows, want 1", name, n)
+				}
+				if wantFinished := crash == 2; (len(finished) == 1) != wantFinished {
+					t.Errorf("%s: replay finished %v", name, finished)
+				}
+			} else {
+				if _, err := os.Lstat(path); err != nil {
+					t.Errorf("%s: the operand is gone: %v", name, err)
+				}
+				if n := rows(t, env.index, entry.ID); n != 0 {
+					t.Errorf("%s: %d index rows for a move that never happened", name, n)
+				}
+				// only an intent begun with nothing after it is left to drop
+				if wantDropped := crash == 1; len(finished) > 0 || (len(dropped) == 1) != wantDropped {
+					t.Errorf("%s: replay finished %v and dropped %v", name, finished, dropped)
+				}
+			}
+		}
+	}
+}
+
+// An intent torn by a crash partway through writing it was never synced,
+// so never acted on: it is passed over, and the next intent is whole
+func TestIntentLogTornWrite(t *testing.T) {
+	env := testEnv(t)
+	first := IndexEntry{ID: "first", Trash: env.trash, Name: "a", Origin: filepath.Join(env.work, "a")}
+	second := IndexEntry{ID: "second", Trash: env.trash, Name: "b", Origin: filepath.Join(env.work, "b")}
+	if err := env.intents.Begin(first); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(env.intents.path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"kind":"intent","id":"torn","entry":{"id":"torn","tr`)
+	f.Close()
+	if err := env.intents.Begin(second); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := env.intents.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, entry := range pending {
+		ids = append(ids, entry.ID)
+	}
+	if !slices.Equal(ids, []string{"first", "second"}) {
+		t.Errorf("pending %v, want first and second", ids)
+	}
+}
+
+// Through a Remover: a move into the trash failing leaves the operand and
+// no intent pending or row behind, and one falling back on a copy after
+// EXDEV is settled like a rename
+func TestIntentLogMoveFaults(t *testing.T) {
+	for _, fault := range []error{syscall.EIO, syscall.ENOSPC, syscall.EROFS, syscall.EXDEV} {
+		env := testEnv(t)
+		path, err := env.file("f", "contents")
+		if err != nil {
+			t.Fatal(err)
+		}
+		env.faults.Inject("rename", path, fault)
+		result := env.remover(false).Remove(path)
+
+		if pending, err := env.intents.Pending(); err != nil || len(pending) > 0 {
+			t.Errorf("%v: pending after the run: %v, %v", fault, pending, err)
+		}
+		if fault == syscall.EXDEV {
+			if result.Err != nil || result.Strategy != "copy" {
+				t.Errorf("%v: %s by %s, %v", fault, result.Status(), result.Strategy, result.Err)
+			}
+			if err := env.trashed(path, "f", "contents"); err != nil {
+				t.Errorf("%v: %v", fault, err)
+			}
+			continue
+		}
+		if !errors.Is(result.Err, fault) {
+			t.Errorf("%v: got %v", fault, result.Err)
+		}
+		if _, err := os.Lstat(path); err != nil {
+			t.Errorf("%v: the operand is gone: %v", fault, err)
+		}
+		if entries, err := env.index.Entries(); err != nil || len(entries) > 0 {
+			t.Errorf("%v: index has %v, %v", fault, entries, err)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package remove
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// InTrash is an operand that lies in a trash, srm's or another tool's:
+// moving it like any file would leave that trash's records about it
+// behind, like an info file with no payload
+type InTrash struct {
+	// Dir is the trash directory payloads are in: the files/ of a
+	// freedesktop.org trash, ~/.Trash or a macOS volume's .Trashes/UID
+	Dir string
+	// Payload is the entry of Dir the operand is, or is inside
+	Payload string
+	Inside  bool
+	// Origin is where Payload was trashed from, from srm's index or the
+	// info file, "" when neither says
+	Origin string
+	entry  IndexEntry
+	known  bool
+}
+
+// inTrash finds the trash abs, an absolute path, lies in. Trashes are
+// recognised by their layout, so those srm never wrote to are too, and by
+// being one of trashes, the ones srm was given.
+func inTrash(abs string, home string, trashes []string) (InTrash, bool) {
+	for dir, child := filepath.Dir(abs), abs; dir != child; dir, child = filepath.Dir(dir), dir {
+		if isTrashDir(dir, home) || In(dir, trashes) {
+			return InTrash{Dir: dir, Payload: child, Inside: child != abs}, true
+		}
+	}
+	return InTrash{}, false
+}
+
+// isTrashDir is whether dir is laid out as a trash directory: the files/
+// of a freedesktop.org trash, the home's .Trash, or a volume's .Trashes/UID
+// as macOS makes them
+func isTrashDir(dir string, home string) bool {
+	switch {
+	case SpecInfoDir(dir) != "":
+		return true
+	case home != "" && dir == filepath.Join(home, ".Trash"):
+		return true
+	case runtime.GOOS == "darwin" && filepath.Base(filepath.Dir(dir)) == ".Trashes":
+		_, err := strconv.Atoi(filepath.Base(dir))
+		return err == nil
+	}
+	return false
+}
+
+// checkInTrash sets plan.InTrash when the operand lies in a trash, reading
+// what srm knows of its payload
+func (r *Remover) checkInTrash(plan *Plan) {
+	abs, err := filepath.Abs(plan.Path)
+	if err != nil {
+		return
+	}
+	home, _ := os.UserHomeDir()
+	trashes := append([]string{r.opts.TrashDir}, r.opts.PreferTrash...)
+	t, ok := inTrash(abs, home, trashes)
+	if !ok {
+		return
+	}
+	if r.opts.Index != nil {
+		entries, _ := r.opts.Index.Entries()
+		for _, entry := range entries {
+			if entry.Payload() == t.Payload {
+				t.entry, t.known, t.Origin = entry, true, entry.Origin
+			}
+		}
+	}
+	if !t.known {
+		t.entry = IndexEntry{Trash: t.Dir, Name: filepath.Base(t.Payload)}
+		t.Origin, _ = trashInfoOrigin(t.Payload)
+	}
+	plan.InTrash = &t
+	plan.tracef("it is in the trash %s, which keeps records of %s", DisplayPath(t.Dir), DisplayPath(t.Payload))
+}
+
+// question is what removeInTrash asks: p, unless purge is false, purges,
+// and r, for a payload with a known origin, puts it back there to be
+// trashed again
+func (t InTrash) question(path string, purge bool) string {
+	switch {
+	case !purge:
+		return fmt.Sprintf("%s is in the trash: put it back at %s and trash it again (r), or leave it (n)? [r/N] ", DisplayPath(path), DisplayPath(t.Origin))
+	case t.Inside:
+		return fmt.Sprintf("%s is inside %s in the trash: remove it for good, updating the trash's records (p), or leave it (n)? [p/N] ", DisplayPath(path), DisplayPath(t.Payload))
+	case t.Origin == "":
+		return fmt.Sprintf("%s is in the trash: purge it along with the trash's records of it (p), or leave it (n)? [p/N] ", DisplayPath(path))
+	}
+	return fmt.Sprintf("%s is in the trash: purge it along with the trash's records of it (p), put it back at %s and trash it again (r), or leave it (n)? [p/r/N] ", DisplayPath(path), DisplayPath(t.Origin))
+}
+
+// removeInTrash removes an operand that lies in a trash as the trash would
+// have it: purged along with its info file, directorysizes line and index
+// row, or put back where it came from and trashed again by srm
+func (r *Remover) removeInTrash(result Result, plan Plan) Result {
+	t := plan.InTrash
+	// it won't be going where the plan would have moved it
+	r.dropDestination(plan)
+	// purging is permanent, which safe mode doesn't allow, leaving only
+	// putting it back for one with an origin
+	purgeable := !r.opts.SafeMode
+	if !purgeable && (t.Inside || t.Origin == "") {
+		plan.tracef("safe mode does not allow purging the trash, and there is nowhere to put it back")
+		result.Action, result.Err = "failed", errSafePurge(plan.Path)
+		return result
+	}
+	answer, err := r.opts.Callbacks.OnPrompt(PromptRequest{Kind: "trash", Path: plan.Path, Message: t.question(plan.Path, purgeable)})
+	if err != nil {
+		result.Action, result.Err = "failed", err
+		return result
+	}
+	switch {
+	case In(answer, []string{"p", "purge"}) && !purgeable:
+		result.Action, result.Err = "failed", errSafePurge(plan.Path)
+		return result
+	case In(answer, []string{"p", "purge"}) && t.Inside:
+		size, _ := DiskUsage(r.fs, plan.Path)
+		if err := r.fs.RemoveAll(plan.Path); err != nil {
+			result.Action, result.Err = "failed", displayErr(err, plan.Path)
+			return result
+		}
+		// the payload is smaller now
+		if err := recordDirectorySize(r.fs, t.Payload); err != nil {
+			result.Note = strings.TrimPrefix(result.Note+"; directorysizes: "+err.Error(), "; ")
+		}
+		result.Action, result.Strategy, result.Bytes = "purged", "remove-all", size
+		return result
+	case In(answer, []string{"p", "purge"}):
+		size, _ := DiskUsage(r.fs, t.Payload)
+		purged := PurgeCandidate(r.fs, r.opts.Index, EmptyCandidate{Entry: t.entry, Known: t.known, Size: size}, false)
+		purged.Source, purged.Note, purged.Op = result.Source, strings.TrimPrefix(result.Note+"; "+purged.Note, "; "), result.Op
+		return purged
+	case In(answer, []string{"r", "restore"}) && !t.Inside && t.Origin != "":
+		if _, err := r.fs.Lstat(t.Origin); err == nil {
+			result.Action, result.Err = "failed", fmt.Errorf("%s: can't put it back: %w", DisplayPath(t.Origin), os.ErrExist)
+			return result
+		}
+		if err := RestoreEntry(r.fs, RestoreTarget{Entry: t.entry, Known: t.known, Generations: 1}, t.Origin); err != nil {
+			result.Action, result.Err = "failed", displayErr(err, t.Payload)
+			return result
+		}
+		RemoveTrashInfo(t.Payload)
+		if t.known {
+			r.opts.Index.Forget(t.entry)
+		}
+		retrashed := r.remove(t.Origin, false)
+		retrashed.Note = strings.TrimPrefix(retrashed.Note+"; put back from "+DisplayPath(t.Payload)+" first", "; ")
+		return retrashed
+	}
+	result.Action = "skipped"
+	result.Err = fmt.Errorf("%s: %w", DisplayPath(plan.Path), ErrDeclined)
+	return result
+}
+
+// errSafePurge is why an operand in the trash isn't purged in safe mode
+func errSafePurge(path string) error {
+	return fmt.Errorf("%s: %w (safe mode disables purging the trash)", DisplayPath(path), ErrProtectedPath)
+}
+
+// trashInfoOrigin is the Path of the info file of the payload at path
+func trashInfoOrigin(payload string) (string, bool) {
+	path := trashInfoPath(payload)
+	if path == "" {
+		return "", false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, found := strings.CutPrefix(strings.TrimSpace(line), "Path="); found {
+			origin, err := url.PathUnescape(value)
+			if err != nil || origin == "" {
+				return "", false
+			}
+			if !filepath.IsAbs(origin) {
+				// a volume trash's are from the top of the volume, which
+				// holds .Trash-UID/files/NAME
+				origin = filepath.Join(filepath.Dir(filepath.Dir(filepath.Dir(payload))), origin)
+			}
+			return origin, true
+		}
+	}
+	return "", false
+}
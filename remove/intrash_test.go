@@ -0,0 +1,97 @@
+package remove
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// An operand inside a freedesktop.org trash another tool filled is asked
+// about: purged along with its records, or put back and trashed again by
+// srm. -f moves it like anything else.
+func TestRemoveInForeignTrash(t *testing.T) {
+	env := testEnv(t)
+	// a freedesktop.org trash some other tool filled
+	files := filepath.Join(env.root, "foreign", "Trash", "files")
+	info := filepath.Join(env.root, "foreign", "Trash", "info")
+	sizes := filepath.Join(env.root, "foreign", "Trash", DIRECTORYSIZES)
+	for _, dir := range []string{files, info} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	payloads := map[string]string{"stray.txt": "", "tree/keep.txt": "keep", "tree/drop/x.txt": "x"}
+	for name, content := range payloads {
+		path := filepath.Join(files, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"stray.txt", "tree"} {
+		if _, err := writeTrashInfo(filepath.Join(files, name), filepath.Join(env.work, name), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := recordDirectorySize(OSFS{}, filepath.Join(files, "tree")); err != nil {
+		t.Fatal(err)
+	}
+
+	answer := ""
+	r := env.remover(true)
+	r.opts.Callbacks.OnPrompt = func(req PromptRequest) (string, error) {
+		if req.Kind != "trash" {
+			return "", fmt.Errorf("unexpected prompt: %s", req.Message)
+		}
+		return answer, nil
+	}
+	line := func() string {
+		data, _ := os.ReadFile(sizes)
+		return strings.TrimSpace(string(data))
+	}
+
+	// inside a payload only it goes, and the payload's size shrinks
+	answer = "p"
+	if result := r.Remove(filepath.Join(files, "tree", "drop")); result.Status() != StatusDeleted {
+		t.Fatalf("tree/drop was %s (%v), want %s", result.Status(), result.Err, StatusDeleted)
+	}
+	if fields := strings.Fields(line()); len(fields) != 3 || fields[0] != "4" {
+		t.Fatalf("%s holds %q, want tree at 4 bytes", sizes, line())
+	}
+	// put back and trashed again, into srm's trash
+	answer = "r"
+	if result := r.Remove(filepath.Join(files, "stray.txt")); result.Status() != StatusTrashed {
+		t.Fatalf("stray.txt was %s (%v), want %s", result.Status(), result.Err, StatusTrashed)
+	}
+	if err := env.trashed(filepath.Join(env.work, "stray.txt"), "stray.txt", ""); err != nil {
+		t.Fatal(err)
+	}
+	// purged with its records
+	answer = "p"
+	if result := r.Remove(filepath.Join(files, "tree")); result.Status() != StatusDeleted {
+		t.Fatalf("tree was %s (%v), want %s", result.Status(), result.Err, StatusDeleted)
+	}
+	for _, dir := range []string{files, info} {
+		if des, err := os.ReadDir(dir); err != nil || len(des) > 0 {
+			t.Fatalf("%s still holds %d entries (%v)", dir, len(des), err)
+		}
+	}
+	if line() != "" {
+		t.Fatalf("%s still holds %q", sizes, line())
+	}
+
+	// -f moves it like anything else
+	path := filepath.Join(files, "again.txt")
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	r.opts.Force, r.opts.ForceLevel = true, 1
+	if plan, err := r.Plan(path); err != nil || plan.InTrash != nil {
+		t.Fatalf("under -f %s was planned to be asked about (%v)", path, err)
+	}
+}
@@ -0,0 +1,66 @@
+//go:build linux || darwin
+
+package remove
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// A tree's directory modes, special bits included, come back from the
+// trash as they went in whichever way it was trashed, even under a umask
+// that would take bits away from anything created fresh
+func TestRestoreDirModes(t *testing.T) {
+	old := syscall.Umask(077)
+	t.Cleanup(func() { syscall.Umask(old) })
+
+	modes := map[string]fs.FileMode{
+		"d":               0700,
+		"d/shared":        0775 | fs.ModeSetgid,
+		"d/shared/sticky": 0777 | fs.ModeSticky,
+	}
+	for _, how := range []string{"rename", "copy", "archive"} {
+		env := testEnv(t)
+		if _, err := env.file("d/shared/sticky/f", "f"); err != nil {
+			t.Fatal(err)
+		}
+		// deepest first, so a 0700 parent doesn't stand in the way
+		for _, name := range []string{"d/shared/sticky", "d/shared", "d"} {
+			if err := os.Chmod(filepath.Join(env.work, name), modes[name]); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		opts := env.options(true)
+		opts.Archive = how == "archive"
+		path := filepath.Join(env.work, "d")
+		if how == "copy" {
+			env.faults.Inject("rename", path, syscall.EXDEV)
+		}
+		result := env.removerWith(opts).Remove(path)
+		if result.Err != nil || result.Strategy != how {
+			t.Fatalf("%s: %s by %s, %v", how, result.Status(), result.Strategy, result.Err)
+		}
+
+		entries, err := env.index.Entries()
+		if err != nil || len(entries) != 1 {
+			t.Fatalf("%s: indexed %v, %v", how, entries, err)
+		}
+		if err := RestoreEntry(OSFS{}, RestoreTarget{Entry: entries[0], Known: true}, path); err != nil {
+			t.Fatalf("%s: restore: %v", how, err)
+		}
+		for name, want := range modes {
+			fi, err := os.Lstat(filepath.Join(env.work, name))
+			if err != nil {
+				t.Errorf("%s: %v", how, err)
+				continue
+			}
+			if got := fi.Mode() &^ fs.ModeDir; got != want {
+				t.Errorf("%s: %s came back %v, want %v", how, name, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package remove
+
+import "syscall"
+
+// MNT_NOWAIT from <sys/mount.h>, which the syscall package doesn't export
+const mntNoWait = 2
+
+// readMounts asks the kernel for the mounted filesystems with getfsstat(2)
+func readMounts() ([]Mount, error) {
+	n, err := syscall.Getfsstat(nil, mntNoWait)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]syscall.Statfs_t, n)
+	n, err = syscall.Getfsstat(buf, mntNoWait)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make([]Mount, 0, n)
+	for _, st := range buf[:n] {
+		found = append(found, Mount{Point: cString(st.Mntonname[:]), FSType: cString(st.Fstypename[:])})
+	}
+	return found, nil
+}
+
+// cString converts a NUL terminated char array
+func cString(chars []int8) string {
+	b := make([]byte, 0, len(chars))
+	for _, c := range chars {
+		if c == 0 {
+			break
+		}
+		b = append(b, byte(c))
+	}
+	return string(b)
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package remove
+
+import "errors"
+
+// readMounts has no mount table to read here, so no fstype policy matches
+func readMounts() ([]Mount, error) {
+	return nil, errors.New("no mount table on this platform")
+}
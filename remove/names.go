@@ -0,0 +1,113 @@
+package remove
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Filenames are bytes, not text. Inside srm they stay untouched strings; only
+// where they cross into something that assumes text are they transformed:
+// DisplayName for terminals, base64 fields next to JSON strings, raw byte
+// fields in the index.
+
+// DisplayName makes name safe to print on a terminal. Names that are valid
+// UTF-8 without control characters are returned as they are. Otherwise
+// invalid bytes become \xNN, control characters their C escape, and
+// backslashes are doubled so the result reads back unambiguously.
+func DisplayName(name string) string {
+	clean := utf8.ValidString(name) && strings.IndexFunc(name, unicode.IsControl) < 0
+	if clean {
+		return name
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(name); {
+		r, size := utf8.DecodeRuneInString(name[i:])
+		switch {
+		case r == utf8.RuneError && size <= 1:
+			fmt.Fprintf(&sb, `\x%02x`, name[i])
+		case r == '\\':
+			sb.WriteString(`\\`)
+		case r == '\n':
+			sb.WriteString(`\n`)
+		case r == '\t':
+			sb.WriteString(`\t`)
+		case r == '\r':
+			sb.WriteString(`\r`)
+		case r == 0x1b:
+			sb.WriteString(`\e`)
+		case unicode.IsControl(r) && r < 0x100:
+			fmt.Fprintf(&sb, `\x%02x`, r)
+		case unicode.IsControl(r):
+			fmt.Fprintf(&sb, `\u%04x`, r)
+		default:
+			sb.WriteRune(r)
+		}
+		i += size
+	}
+	return sb.String()
+}
+
+// Base64Name is name in base64 when it isn't valid UTF-8, which JSON would
+// otherwise mangle into U+FFFD, and empty when the plain string is exact
+func Base64Name(name string) string {
+	if utf8.ValidString(name) {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString([]byte(name))
+}
+
+// rawBytes is name's bytes for the index when it isn't valid UTF-8, nil
+// when the JSON string alone round-trips
+func rawBytes(name string) []byte {
+	if utf8.ValidString(name) {
+		return nil
+	}
+	return []byte(name)
+}
+
+// PATHDISPLAY is how paths are shown to the user: "" as typed, "abs" for
+// absolute, or "rel" for relative to RELATIVETO. Logs, the index and
+// --format output always get absolute paths whatever it says.
+var PATHDISPLAY = ""
+
+// RELATIVETO is the absolute directory --relative-to shows paths against
+var RELATIVETO = ""
+
+// DisplayPath is path the way prompts, -v, errors and summaries show it.
+// It doesn't escape anything; DisplayName does that where text is printed.
+func DisplayPath(path string) string {
+	if PATHDISPLAY == "" {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if PATHDISPLAY == "rel" {
+		if rel, err := filepath.Rel(RELATIVETO, abs); err == nil {
+			return rel
+		}
+	}
+	return abs
+}
+
+// displayErr rewrites the operand in err's path to its DisplayPath
+func displayErr(err error, path string) error {
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	switch {
+	case errors.As(err, &pathErr) && pathErr.Path == path:
+		pathErr.Path = DisplayPath(path)
+	case errors.As(err, &linkErr) && linkErr.Old == path:
+		linkErr.Old = DisplayPath(path)
+	}
+	return err
+}
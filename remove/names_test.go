@@ -0,0 +1,188 @@
+package remove
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/shanahanjrs/srm/trashquery"
+)
+
+// NASTYNAMES are file names Linux takes and text handling tends to break
+var NASTYNAMES = []string{
+	"plain.txt",
+	"with space",
+	"\xff\xfe invalid",
+	"half\xe2\x82 rune",
+	"new\nline",
+	"carriage\rreturn",
+	"tab\tbed",
+	"esc\x1b[31mred\x1b[0m",
+	"bell\a",
+	"del\x7f",
+	"-rf",
+	"--",
+	"-",
+	"back\\slash",
+	`"quoted"`,
+	"percent%41%zz",
+	"plus+sign",
+	"hash#and?query",
+	"中文名",
+	"écombining",
+	"‮rtl override",
+	"c1\u0085next line",
+	strings.Repeat("n", 255),
+	strings.Repeat("é", 127),
+}
+
+func TestDisplayName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"plain.txt", "plain.txt"},
+		{"中文名", "中文名"},
+		{"back\\slash", "back\\slash"},
+		{"\xff\xfe", `\xff\xfe`},
+		{"half\xe2\x82", `half\xe2\x82`},
+		{"new\nline", `new\nline`},
+		{"a\tb\rc", `a\tb\rc`},
+		{"esc\x1b[31m", `esc\e[31m`},
+		{"bell\a", `bell\x07`},
+		{"del\x7f", `del\x7f`},
+		{"c1\u0085", `c1\x85`},
+		// once anything is escaped, backslashes are too, to read back
+		{"back\\slash\n", `back\\slash\n`},
+		{"\xff\\", `\xff\\`},
+	}
+	for _, tt := range tests {
+		if got := DisplayName(tt.name); got != tt.want {
+			t.Errorf("displayName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	seen := map[string]string{}
+	for _, name := range NASTYNAMES {
+		shown := DisplayName(name)
+		if !utf8.ValidString(shown) || strings.IndexFunc(shown, unicode.IsControl) >= 0 {
+			t.Errorf("displayName(%q) = %q, which isn't safe to print", name, shown)
+		}
+		if other, ok := seen[shown]; ok {
+			t.Errorf("%q and %q both display as %q", name, other, shown)
+		}
+		seen[shown] = name
+	}
+}
+
+func TestIndexEntryRawRoundTrip(t *testing.T) {
+	for _, name := range NASTYNAMES {
+		entry := IndexEntry{ID: "0a", Trash: "/t/" + name, Name: name, Origin: "/w/" + name}
+		row, err := json.Marshal(entry.EncodeRaw())
+		if err != nil {
+			t.Errorf("%q: %v", name, err)
+			continue
+		}
+		var got IndexEntry
+		if err := json.Unmarshal(row, &got); err != nil {
+			t.Errorf("%q: %v", name, err)
+			continue
+		}
+		got.DecodeRaw()
+		if got.Trash != entry.Trash || got.Name != entry.Name || got.Origin != entry.Origin {
+			t.Errorf("%q came back as %q, %q, %q", name, got.Trash, got.Name, got.Origin)
+		}
+		if hasRaw := bytes.Contains(row, []byte(`"name_raw"`)); hasRaw == utf8.ValidString(name) {
+			t.Errorf("%q: row %s has name_raw %v", name, row, hasRaw)
+		}
+	}
+}
+
+func TestTrashInfoNames(t *testing.T) {
+	trash := t.TempDir()
+	for _, dir := range []string{"files", "info"} {
+		if err := os.Mkdir(filepath.Join(trash, dir), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, name := range NASTYNAMES {
+		payload := filepath.Join(trash, "files", strings.Repeat("x", i+1))
+		origin := "/home/user/" + name
+		if _, err := writeTrashInfo(payload, origin, time.Now()); err != nil {
+			t.Errorf("%q: %v", name, err)
+			continue
+		}
+		data, _ := os.ReadFile(trashInfoPath(payload))
+		if !utf8.Valid(data) || bytes.Count(data, []byte("\n")) != 3 {
+			t.Errorf("%q: the info file isn't three lines of text: %q", name, data)
+		}
+		if got, ok := trashInfoOrigin(payload); !ok || got != origin {
+			t.Errorf("%q: the info file's Path reads back as %q, %v", name, got, ok)
+		}
+	}
+}
+
+// Every name goes to the trash, shows in the index and trashquery exactly,
+// and comes back from the trash as it was
+func TestNastyNamesEndToEnd(t *testing.T) {
+	env := testEnv(t)
+	r := env.remover(false)
+
+	results := map[string]Result{}
+	for _, name := range NASTYNAMES {
+		path, err := env.file(name, name)
+		if err != nil {
+			t.Fatalf("%q: %v", name, err)
+		}
+		result := r.Remove(path)
+		if result.Err != nil {
+			t.Errorf("%q: %v", name, result.Err)
+			continue
+		}
+		if got, err := os.ReadFile(result.Dest); err != nil || string(got) != name {
+			t.Errorf("%q: trashed to %q, reading %q, %v", name, result.Dest, got, err)
+		}
+		results[path] = result
+	}
+
+	entries, err := env.index.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexed := map[string]IndexEntry{}
+	for _, entry := range entries {
+		indexed[entry.Origin] = entry
+	}
+	queried := map[string]trashquery.Entry{}
+	for entry, err := range trashquery.Open(env.index.Path()).ListEntries(trashquery.Filter{}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		queried[entry.Origin] = entry
+	}
+
+	for path, result := range results {
+		entry, ok := indexed[path]
+		if !ok || entry.Payload() != result.Dest {
+			t.Errorf("%q: the index has %+v, want it in %q", path, entry, result.Dest)
+			continue
+		}
+		if q := queried[path]; q.Payload() != result.Dest {
+			t.Errorf("%q: trashquery has %+v, want it in %q", path, q, result.Dest)
+		}
+		target := RestoreTarget{Entry: entry, Known: true, Generations: 1}
+		if err := RestoreEntry(OSFS{}, target, path); err != nil {
+			t.Errorf("%q: restore: %v", path, err)
+			continue
+		}
+		if got, err := os.ReadFile(path); err != nil || string(got) != filepath.Base(path) {
+			t.Errorf("%q: restored %q, %v", path, got, err)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package remove
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// mountHolders walks the directory dir, on device dev, and returns every
+// directory under it that holds a mount point somewhere below, dir included
+// when there is one at all, along with the mount points themselves. Mount
+// points aren't walked into.
+func (r *Remover) mountHolders(dir string, dev uint64) (holders map[string]bool, mounts map[string]bool) {
+	holders, mounts = map[string]bool{}, map[string]bool{}
+	var walk func(path string) bool
+	walk = func(path string) bool {
+		children, err := r.fs.ReadDir(path)
+		if err != nil {
+			// removing it reports the error where it happens
+			return false
+		}
+		holds := false
+		for _, de := range children {
+			child := filepath.Join(path, de.Name())
+			fi, err := r.fs.Lstat(child)
+			if err != nil {
+				continue
+			}
+			if childDev, ok := fileDevice(fi); ok && childDev != dev {
+				mounts[child] = true
+				holds = true
+			} else if fi.IsDir() && walk(child) {
+				holds = true
+			}
+		}
+		if holds {
+			holders[path] = true
+		}
+		return holds
+	}
+	walk(dir)
+	return holders, mounts
+}
+
+// removeOneFS is -r under -x for a directory operand with something mounted
+// below it: everything on the operand's own filesystem is trashed, each
+// subtree without a mount point in it removed as its own operand, and mount
+// points are skipped and reported. The directories on the way down to a
+// mount point can't go without it, so they stay, and the result is for
+// dir itself, which stays too, with the joined errors of whatever failed.
+func (r *Remover) removeOneFS(dir string, plan Plan, holders map[string]bool, mounts map[string]bool) Result {
+	result := Result{Action: "kept", Source: dir, IsDir: true, Op: r.opts.Op}
+
+	errs := []error{}
+	var walk func(path string)
+	walk = func(path string) {
+		children, err := r.fs.ReadDir(path)
+		if err != nil {
+			errs = append(errs, displayErr(err, path))
+			return
+		}
+		for _, de := range children {
+			child := filepath.Join(path, de.Name())
+			switch {
+			case mounts[child]:
+				if r.opts.POSIX {
+					fmt.Fprintf(os.Stderr, "srm: skipping '%s', since it's on a different device\n", DisplayName(DisplayPath(child)))
+				} else {
+					fmt.Fprintf(os.Stderr, "srm: skipping mount point %s (-x)\n", DisplayName(DisplayPath(child)))
+				}
+				r.kept(child)
+			case holders[child]:
+				walk(child)
+			default:
+				if res := r.removeEntry(child); res.Status().Info().Fails {
+					errs = append(errs, res.Err)
+				}
+			}
+		}
+	}
+	walk(plan.Path)
+
+	result.Err = errors.Join(errs...)
+	if result.Err != nil {
+		result.Action = "failed"
+	}
+	return result
+}
@@ -0,0 +1,36 @@
+package remove
+
+// FORCEBYPASS is the force level, -ff or --force=2, at which srm also stops
+// asking the questions rm doesn't have, the ones its safety features add.
+// A single -f only skips what it skips for rm.
+const FORCEBYPASS = 2
+
+// PROMPTFORCE is the force level that skips each of srm's own questions:
+// giving up on the trash and deleting for good, and emptying it, go with
+// a single -f as they always have; the wildcard guard and fstype = ask
+// exist to catch the -f typed out of habit, so they take -ff, as does
+// confirm_over_size, but --confirm-size's question about each operand is
+// one more -i and goes with a single -f, like the one about an operand in a
+// trash, which -f moves like any other. Safe mode's
+// questions aren't here: no force level skips them.
+var PROMPTFORCE = map[string]int{
+	"permanent": 1,
+	"empty":     1,
+	"system":    1,
+	"wildcard":  FORCEBYPASS,
+	"fstype":    FORCEBYPASS,
+	"size":      FORCEBYPASS,
+	"oversize":  1,
+	"intrash":   1,
+}
+
+// SkipsPrompt reports whether the force given skips the question prompt,
+// one of PROMPTFORCE's. Every prompt site asks here rather than looking at
+// -f itself, so the levels live in one place.
+func (o Options) SkipsPrompt(prompt string) bool {
+	level, ok := PROMPTFORCE[prompt]
+	if !ok {
+		panic("skipsPrompt: unknown prompt " + prompt)
+	}
+	return !o.SafeMode && o.ForceLevel >= level
+}
@@ -0,0 +1,43 @@
+package remove
+
+import "testing"
+
+// Each of srm's own questions is skipped from its force level up, and
+// never in safe mode
+func TestSkipsPrompt(t *testing.T) {
+	tests := []struct {
+		prompt string
+		level  int
+	}{
+		{"permanent", 1},
+		{"empty", 1},
+		{"system", 1},
+		{"oversize", 1},
+		{"intrash", 1},
+		{"wildcard", FORCEBYPASS},
+		{"fstype", FORCEBYPASS},
+		{"size", FORCEBYPASS},
+	}
+	if len(tests) != len(PROMPTFORCE) {
+		t.Errorf("%d questions tested, PROMPTFORCE has %d", len(tests), len(PROMPTFORCE))
+	}
+	for _, tt := range tests {
+		for force := 0; force <= FORCEBYPASS; force++ {
+			opts := Options{Force: force > 0, ForceLevel: force}
+			if got := opts.SkipsPrompt(tt.prompt); got != (force >= tt.level) {
+				t.Errorf("%s: force level %d skips it: %v, want %v", tt.prompt, force, got, force >= tt.level)
+			}
+			opts.SafeMode = true
+			if opts.SkipsPrompt(tt.prompt) {
+				t.Errorf("%s: force level %d skips it in safe mode", tt.prompt, force)
+			}
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("an unknown question didn't panic")
+		}
+	}()
+	Options{}.SkipsPrompt("unknown")
+}
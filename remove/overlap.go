@@ -0,0 +1,189 @@
+package remove
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CheckOperand rejects operands that can only be script bugs, like an unset
+// "$FILE": empty or whitespace-only strings. No filesystem call is made for
+// them. A "$DIR/" with DIR unset is / and CheckPreserved's to refuse.
+func CheckOperand(path string) error {
+	if strings.TrimSpace(path) == "" {
+		return ErrEmptyOperand
+	}
+	return nil
+}
+
+// CheckPreserved refuses what is never removed whatever the flags, as rm
+// does: an operand whose last component is . or .., trailing slashes aside,
+// and, while preserveRoot, one that is the root directory, which includes
+// // and a symlink to / given with a trailing slash. A symlink to / given
+// as itself is only a link, and is removed like one.
+func CheckPreserved(fsys FS, path string, preserveRoot bool) error {
+	trimmed := strings.TrimRight(filepath.ToSlash(path), "/")
+	if trimmed != "" {
+		if base := trimmed[strings.LastIndex(trimmed, "/")+1:]; base == "." || base == ".." {
+			return fmt.Errorf("%s: %w", DisplayPath(path), ErrDotOperand)
+		}
+	}
+	if !preserveRoot {
+		return nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil
+	}
+	root, err := fsys.Lstat(filepath.VolumeName(abs) + string(filepath.Separator))
+	if err != nil {
+		return nil
+	}
+	if fi, err := fsys.Lstat(path); err == nil && os.SameFile(fi, root) {
+		return fmt.Errorf("%s: %w", DisplayPath(path), ErrPreserveRoot)
+	}
+	return nil
+}
+
+// protectedBy is the first of protected that path is or holds, "" for none
+func protectedBy(path string, protected []string) string {
+	if len(protected) == 0 {
+		return ""
+	}
+	operand := CanonicalOperand(path)
+	for _, p := range protected {
+		if rel, err := filepath.Rel(operand, p); err == nil && (rel == "." || !IsParentRel(rel)) {
+			return p
+		}
+	}
+	return ""
+}
+
+// CanonicalOperand is path made absolute with the symlinks above it resolved.
+// The operand itself isn't followed, since a symlink operand is removed as a
+// link.
+func CanonicalOperand(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(abs)); err == nil {
+		return filepath.Join(dir, filepath.Base(abs))
+	}
+	return abs
+}
+
+// Coverage follows which operands lie inside others. Before anything is
+// removed it knows which operand would take each one away; as removals
+// finish it records what actually went, so an operand is only reported
+// covered once something that holds it was really removed, and one whose
+// cover was declined or failed is still attempted.
+type Coverage struct {
+	paths []string
+	// canonical is each operand's CanonicalOperand, taken before anything
+	// was removed, when the symlinks above it could still be resolved
+	canonical []string
+	// enclosing is the operand that would take each one away: an earlier
+	// copy of it or, with recursive, its outermost enclosing directory; -1
+	// for none
+	enclosing []int
+	// removed maps the canonical path of everything removed so far to the
+	// operand it was
+	removed map[string]int
+}
+
+// CoveringOperands works out the Coverage of paths. Only a recursive
+// removal takes a directory's contents with it, so without recursive only
+// repeated operands are covered.
+func CoveringOperands(paths []string, recursive bool) *Coverage {
+	c := &Coverage{
+		paths:     paths,
+		canonical: make([]string, len(paths)),
+		enclosing: make([]int, len(paths)),
+		removed:   map[string]int{},
+	}
+	first := map[string]int{}
+	for i, path := range paths {
+		c.canonical[i] = CanonicalOperand(path)
+		if _, ok := first[c.canonical[i]]; !ok {
+			first[c.canonical[i]] = i
+		}
+	}
+
+	for i, path := range c.canonical {
+		c.enclosing[i] = -1
+		if j := first[path]; j != i {
+			c.enclosing[i] = j
+			continue
+		}
+		if !recursive {
+			continue
+		}
+		// the outermost enclosing operand wins
+		for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+			if j, ok := first[dir]; ok {
+				c.enclosing[i] = j
+			}
+			if dir == filepath.Dir(dir) {
+				break
+			}
+		}
+	}
+	return c
+}
+
+// Enclosed reports whether another operand would take operand i away
+func (c *Coverage) Enclosed(i int) bool {
+	return c.enclosing[i] >= 0
+}
+
+// Waits reports the later operand that encloses operand i, which is
+// attempted first so operand i goes with it
+func (c *Coverage) Waits(i int) (int, bool) {
+	j := c.enclosing[i]
+	return j, j > i
+}
+
+// Record notes what became of operand i
+func (c *Coverage) Record(i int, result Result) {
+	c.record(c.canonical[i], i, result)
+}
+
+// record notes what became of the entry at canonical, part of operand i.
+// Only a removal counts; an entry skipped or kept is still there.
+func (c *Coverage) record(canonical string, i int, result Result) {
+	if status := result.Status(); status == StatusTrashed || status == StatusDeleted {
+		if _, ok := c.removed[canonical]; !ok {
+			c.removed[canonical] = i
+		}
+	}
+}
+
+// CoveredBy returns the operand whose removal already took operand i
+// away: an earlier copy of it, or a directory above it
+func (c *Coverage) CoveredBy(i int) (string, bool) {
+	for path := c.canonical[i]; ; path = filepath.Dir(path) {
+		if j, ok := c.removed[path]; ok && j != i {
+			return c.paths[j], true
+		}
+		if path == filepath.Dir(path) {
+			return "", false
+		}
+	}
+}
+
+// Covered reports path as skipped because the operand by already removes
+// it, through OnEntryDone like any other Result
+func (r *Remover) Covered(path string, by string) Result {
+	result := Result{
+		Action: "covered",
+		Source: path,
+		Op:     r.opts.Op,
+		Err:    fmt.Errorf("%s: %w by '%s'", DisplayPath(path), ErrCovered, DisplayPath(by)),
+	}
+	if r.opts.Callbacks.OnEntryDone != nil {
+		r.opts.Callbacks.OnEntryDone(result)
+	}
+	return result
+}
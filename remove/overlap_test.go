@@ -0,0 +1,110 @@
+package remove
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestCoveringOperands(t *testing.T) {
+	work := t.TempDir()
+	parent := filepath.Join(work, "parent")
+	child := filepath.Join(parent, "child")
+	if err := os.MkdirAll(child, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// alias reaches parent through a symlink, so alias/child is child
+	alias := filepath.Join(work, "alias")
+	if err := os.Symlink(parent, alias); err != nil {
+		t.Fatal(err)
+	}
+	aliased := filepath.Join(alias, "child")
+
+	for _, c := range []struct {
+		name      string
+		operands  []string
+		recursive bool
+		// enclosing is the operand taking each one away, -1 for none
+		enclosing []int
+	}{
+		{"parent then child", []string{parent, child}, true, []int{-1, 0}},
+		{"child then parent", []string{child, parent}, true, []int{1, -1}},
+		{"not recursive", []string{parent, child}, false, []int{-1, -1}},
+		{"duplicates", []string{child, child, child}, false, []int{-1, 0, 0}},
+		{"duplicates recursive", []string{child, parent, child}, true, []int{1, -1, 0}},
+		{"spelled differently", []string{child, parent + "/./child/"}, false, []int{-1, 0}},
+		{"symlinked alias", []string{aliased, child}, false, []int{-1, 0}},
+		{"symlinked parent", []string{aliased, parent}, true, []int{1, -1}},
+		// a symlink operand is the link, not what it points at
+		{"symlink operand", []string{alias, parent}, true, []int{-1, -1}},
+		{"siblings", []string{parent, filepath.Join(work, "parent2")}, true, []int{-1, -1}},
+	} {
+		covers := CoveringOperands(c.operands, c.recursive)
+		for i := range c.operands {
+			want := c.enclosing[i]
+			if got := covers.enclosing[i]; got != want {
+				t.Errorf("%s: operand %d is enclosed by %d, want %d", c.name, i, got, want)
+			}
+			if j, ok := covers.Waits(i); ok != (want > i) || (ok && j != want) {
+				t.Errorf("%s: operand %d waits on %d, %v", c.name, i, j, ok)
+			}
+		}
+	}
+}
+
+// An operand inside another is reported covered only once that one goes,
+// whichever order they were given in; if it stays, the inner one is
+// removed on its own
+func TestRemoveEachOverlap(t *testing.T) {
+	for _, c := range []struct {
+		operands []string
+		// parentFails makes moving the parent fail
+		parentFails bool
+		want        map[string][]Status
+	}{
+		{[]string{"parent", "child"}, false, map[string][]Status{"parent": {StatusTrashed}, "child": {StatusCovered}}},
+		{[]string{"child", "parent"}, false, map[string][]Status{"parent": {StatusTrashed}, "child": {StatusCovered}}},
+		{[]string{"child", "parent", "child"}, false, map[string][]Status{"parent": {StatusTrashed}, "child": {StatusCovered, StatusCovered}}},
+		{[]string{"child", "child"}, false, map[string][]Status{"child": {StatusTrashed, StatusCovered}}},
+		{[]string{"alias/child", "child"}, false, map[string][]Status{"alias/child": {StatusTrashed}, "child": {StatusCovered}}},
+		{[]string{"parent", "child"}, true, map[string][]Status{"parent": {StatusFailed}, "child": {StatusTrashed}}},
+		{[]string{"child", "parent"}, true, map[string][]Status{"parent": {StatusFailed}, "child": {StatusTrashed}}},
+	} {
+		name := strings.Join(c.operands, " ")
+		if c.parentFails {
+			name += " with parent failing"
+		}
+		env := testEnv(t)
+		if _, err := env.file("parent/child/file.txt", "covered"); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink("parent", filepath.Join(env.work, "alias")); err != nil {
+			t.Fatal(err)
+		}
+		paths := map[string]string{"parent": filepath.Join(env.work, "parent"), "child": filepath.Join(env.work, "parent", "child")}
+		paths["alias/child"] = filepath.Join(env.work, "alias", "child")
+		operands := []string{}
+		for _, operand := range c.operands {
+			operands = append(operands, paths[operand])
+		}
+		if c.parentFails {
+			env.faults.Inject("rename", paths["parent"], syscall.EACCES)
+		}
+
+		got := map[string][]Status{}
+		env.remover(true).RemoveEach(operands, CoveringOperands(operands, true), func(i int, result Result) {
+			got[c.operands[i]] = append(got[c.operands[i]], result.Status())
+		})
+		for operand, want := range c.want {
+			if !slices.Equal(got[operand], want) {
+				t.Errorf("%s: %s was %v, want %v", name, operand, got[operand], want)
+			}
+		}
+		if pending, err := env.intents.Pending(); err != nil || len(pending) > 0 {
+			t.Errorf("%s: pending after the run: %v, %v", name, pending, err)
+		}
+	}
+}
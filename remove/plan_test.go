@@ -0,0 +1,149 @@
+package remove
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// Without a trash, a missing operand and a directory without -r or -d
+// fail as they would with one, as rm fails them, before the missing trash
+// comes into it
+func TestPlanChecksBeforeTrash(t *testing.T) {
+	env := testEnv(t)
+	dir := filepath.Join(env.work, "d")
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	file, err := env.file("f", "f")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		recursive bool
+		dir       bool
+		want      error
+	}{
+		{"a missing operand", filepath.Join(env.work, "missing"), false, false, ErrNotFound},
+		{"a directory without -r", dir, false, false, ErrIsDirectory},
+		{"a directory that isn't empty under -d", dir, false, true, ErrDirNotEmpty},
+		{"a directory under -r", dir, true, false, ErrTrashUnavailable},
+		{"a file", file, false, false, ErrTrashUnavailable},
+	}
+	for _, tt := range tests {
+		opts := env.options(tt.recursive)
+		opts.Dir, opts.TrashDir = tt.dir, ""
+		if _, err := env.removerWith(opts).Plan(tt.path); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+// ., .. and the root directory are refused before anything else, however
+// they are spelled; a symlink to / is only a link unless given with a
+// trailing slash
+func TestPlanPreserved(t *testing.T) {
+	env := testEnv(t)
+	link := filepath.Join(env.work, "root")
+	if err := os.Symlink(string(filepath.Separator), link); err != nil {
+		t.Skip("no symlinks here:", err)
+	}
+
+	// Plan only: nothing here may be removed for real, whatever goes wrong
+	r := env.remover(true)
+	for path, want := range map[string]error{
+		"/":                      ErrPreserveRoot,
+		"//":                     ErrPreserveRoot,
+		link + "/":               ErrPreserveRoot,
+		".":                      ErrDotOperand,
+		"./":                     ErrDotOperand,
+		env.work + "/..":         ErrDotOperand,
+		env.work + "/sub/.././/": ErrDotOperand,
+	} {
+		if _, err := r.Plan(path); !errors.Is(err, want) {
+			t.Errorf("%s: got %v, want %v", path, err, want)
+		}
+	}
+	if _, err := env.remover(false).Plan(link); errors.Is(err, ErrPreserveRoot) {
+		t.Errorf("%s: refused as the root directory, though it is a symlink to it", link)
+	}
+	// --no-preserve-root lets / through, though not . or ..
+	if err := CheckPreserved(env.faults, "//", false); err != nil {
+		t.Errorf("//: refused with --no-preserve-root: %v", err)
+	}
+	if err := CheckPreserved(env.faults, "./", false); !errors.Is(err, ErrDotOperand) {
+		t.Errorf("./: got %v with --no-preserve-root, want %v", err, ErrDotOperand)
+	}
+}
+
+// A protected path is refused, and so is every directory above it
+func TestPlanProtected(t *testing.T) {
+	env := testEnv(t)
+	path, err := env.file("keep/inner/notes.txt", "notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := env.file("other.txt", "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := env.options(true)
+	opts.Protected = []string{CanonicalOperand(filepath.Dir(path))}
+	r := env.removerWith(opts)
+	for _, refused := range []string{filepath.Dir(path), filepath.Join(env.work, "keep")} {
+		if _, err := r.Plan(refused); !errors.Is(err, ErrProtectedPath) {
+			t.Errorf("%s: got %v, want %v", refused, err, ErrProtectedPath)
+		}
+	}
+	if _, err := r.Plan(other); err != nil {
+		t.Errorf("%s: %v", other, err)
+	}
+}
+
+// srm's own questions are asked only below their PROMPTFORCE level
+func TestPlanForceLevels(t *testing.T) {
+	env := testEnv(t)
+	path, err := env.file("ask.txt", "ask")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sized, err := env.file("sized/ask.txt", "ask")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for force := 0; force <= FORCEBYPASS; force++ {
+		// a directory of 3 bytes is over a --confirm-size of 2
+		opts := env.options(true)
+		opts.Force, opts.ForceLevel = force > 0, force
+		opts.ConfirmSize = 2
+		plan, err := env.removerWith(opts).Plan(filepath.Dir(sized))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{}
+		if force < PROMPTFORCE["oversize"] {
+			want = append(want, DisplayPath(filepath.Dir(sized))+" is 3 B, remove?")
+		}
+		if !slices.Equal(plan.Prompts, want) {
+			t.Errorf("force level %d: --confirm-size asked %q, want %q", force, plan.Prompts, want)
+		}
+
+		opts = env.options(false)
+		opts.Force, opts.ForceLevel = force > 0, force
+		opts.FSPolicies = []FSPolicy{{Pattern: "*", Policy: "ask"}}
+		if plan, err = env.removerWith(opts).Plan(path); err != nil {
+			t.Fatal(err)
+		}
+		if plan.FSType == "" {
+			t.Skipf("the filesystem type of %s is unknown", env.work)
+		}
+		if asked := len(plan.Prompts) > 0; asked != (force < PROMPTFORCE["fstype"]) {
+			t.Errorf("force level %d: fstype = ask asked: %v", force, asked)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package remove
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// POSIX is set by --posix or SRM_POSIX=1: srm still trashes rather than
+// deletes, but otherwise behaves as rm does. Diagnostics, prompts and -v
+// lines take rm's wording, the last of -f and -i wins, -f is silent about
+// missing operands, a write-protected file is asked about only when input
+// is a terminal, and srm's own notices like the operation ID are dropped.
+var POSIX = false
+
+// fileTypePhrase names fi's type the way rm's prompts do
+func fileTypePhrase(fi fs.FileInfo) string {
+	switch mode := fi.Mode(); {
+	case mode.IsRegular() && fi.Size() == 0:
+		return "regular empty file"
+	case mode.IsRegular():
+		return "regular file"
+	case mode.IsDir():
+		return "directory"
+	case mode&fs.ModeSymlink != 0:
+		return "symbolic link"
+	case mode&fs.ModeNamedPipe != 0:
+		return "fifo"
+	case mode&fs.ModeSocket != 0:
+		return "socket"
+	case mode&fs.ModeCharDevice != 0:
+		return "character special file"
+	case mode&fs.ModeDevice != 0:
+		return "block special file"
+	}
+	return "weird file"
+}
+
+// posixPrompt is rm's question before removing path
+func posixPrompt(path string, fi fs.FileInfo, writeProtected bool) string {
+	kind := fileTypePhrase(fi)
+	if writeProtected {
+		kind = "write-protected " + kind
+	}
+	return fmt.Sprintf("remove %s '%s'?", kind, DisplayPath(path))
+}
+
+// posixDescendPrompt is rm's question before looking inside the directory
+// at path under -r -i
+func posixDescendPrompt(path string, writeProtected bool) string {
+	kind := "directory"
+	if writeProtected {
+		kind = "write-protected " + kind
+	}
+	return fmt.Sprintf("descend into %s '%s'?", kind, DisplayPath(path))
+}
+
+// posixYes reports whether answer is affirmative the way rm reads it: it
+// starts with a y
+func posixYes(answer string) bool {
+	return strings.HasPrefix(answer, "y")
+}
+
+// confirmBatchPOSIX is ConfirmBatch under --posix: rm's -I question, asked
+// once for more than three operands or for any removal with -r, with no
+// preview
+func (r *Remover) confirmBatchPOSIX(paths []string) bool {
+	if !r.opts.OnceInteractive || (len(paths) <= 3 && !r.opts.Recursive) {
+		return true
+	}
+	noun := "arguments"
+	if len(paths) == 1 {
+		noun = "argument"
+	}
+	recursively := ""
+	if r.opts.Recursive {
+		recursively = " recursively"
+	}
+	message := fmt.Sprintf("remove %d %s%s?", len(paths), noun, recursively)
+	if r.opts.DryRun {
+		fmt.Println("would ask: " + message)
+		return true
+	}
+	answer, err := r.opts.Callbacks.OnPrompt(PromptRequest{Kind: "batch", Message: message})
+	return err == nil && posixYes(answer)
+}
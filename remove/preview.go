@@ -0,0 +1,193 @@
+package remove
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// how long the -I preview may spend walking before it gives up and reports
+// what it has, and how many entry names it keeps for paging
+var (
+	PREVIEWBUDGET  = 2 * time.Second
+	PREVIEWMAXKEEP = 100000
+)
+
+// Preview is a read-only count of everything a batch of operands covers
+type Preview struct {
+	Operands int
+	Files    int
+	Bytes    int64
+	// Entries are the paths counted, as typed plus whatever is below them
+	Entries []string
+	// Groups breaks the count down by the top-level directory of each operand
+	Groups map[string]*PreviewGroup
+	// Partial means the walk ran out of time and the counts are a lower bound
+	Partial bool
+	// Cached is how many directories were counted from the size cache
+	// rather than walked, their entries missing from Entries
+	Cached int
+}
+
+type PreviewGroup struct {
+	Files int
+	Bytes int64
+}
+
+// walkPreview counts paths (recursively when asked) without modifying
+// anything, stopping once the time budget is spent. Directories cache has
+// seen before are counted from it rather than walked, and the ones walked
+// in full are recorded in it; a nil cache walks everything.
+func walkPreview(fsys FS, paths []string, recursive bool, budget time.Duration, cache *SizeCache) *Preview {
+	p := &Preview{Operands: len(paths), Groups: map[string]*PreviewGroup{}}
+	deadline := time.Now().Add(budget)
+
+	// walk returns what it counted below and including path, and whether
+	// that is all of it
+	var walk func(path string, group *PreviewGroup) (int64, int, bool)
+	walk = func(path string, group *PreviewGroup) (int64, int, bool) {
+		if p.Partial {
+			return 0, 0, false
+		}
+		if time.Now().After(deadline) {
+			p.Partial = true
+			return 0, 0, false
+		}
+
+		fi, err := fsys.Lstat(path)
+		if err != nil {
+			return 0, 0, false
+		}
+
+		p.Files++
+		group.Files++
+		if len(p.Entries) < PREVIEWMAXKEEP {
+			p.Entries = append(p.Entries, path)
+		}
+		if !fi.IsDir() {
+			p.Bytes += fi.Size()
+			group.Bytes += fi.Size()
+			return fi.Size(), 1, true
+		}
+		if !recursive {
+			return 0, 1, true
+		}
+		if size, files, ok := cache.Lookup(fi); ok {
+			p.Cached++
+			p.Files += files - 1
+			group.Files += files - 1
+			p.Bytes += size
+			group.Bytes += size
+			return size, files, true
+		}
+
+		children, err := fsys.ReadDir(path)
+		if err != nil {
+			return 0, 1, false
+		}
+		var total int64
+		files, complete := 1, true
+		for _, child := range children {
+			size, n, ok := walk(filepath.Join(path, child.Name()), group)
+			total += size
+			files += n
+			complete = complete && ok
+		}
+		if complete {
+			cache.Store(fi, total, files)
+		}
+		return total, files, complete
+	}
+
+	for _, path := range paths {
+		top := topLevel(path)
+		if fi, err := fsys.Lstat(path); err == nil && fi.IsDir() && top == "."+string(filepath.Separator) {
+			top = childPath(filepath.Clean(path), "")
+		}
+		group, ok := p.Groups[top]
+		if !ok {
+			group = &PreviewGroup{}
+			p.Groups[top] = group
+		}
+		walk(path, group)
+	}
+
+	return p
+}
+
+// topLevel
+// "build/obj/a.o" --> "build/", "a.o" --> "./", "/var/log/x" --> "/var/",
+// and on Windows "C:\\Users\\x" --> "C:\\Users\\"
+func topLevel(path string) string {
+	clean := filepath.Clean(path)
+	sep := string(filepath.Separator)
+	if filepath.IsAbs(clean) {
+		volume := filepath.VolumeName(clean)
+		parts := strings.SplitN(strings.TrimPrefix(clean[len(volume):], sep), sep, 2)
+		if len(parts) < 2 {
+			return volume + sep
+		}
+		return volume + sep + parts[0] + sep
+	}
+
+	parts := strings.SplitN(clean, sep, 2)
+	if len(parts) < 2 {
+		return "." + sep
+	}
+	return parts[0] + sep
+}
+
+// Summary is the headline used in the prompt
+func (p *Preview) Summary() string {
+	atLeast := ""
+	if p.Partial {
+		atLeast = "at least "
+	}
+	return fmt.Sprintf("remove %d operands (%s%d files, %s)?", p.Operands, atLeast, p.Files, FormatSize(p.Bytes))
+}
+
+// Details renders the per-directory breakdown and the first few entries,
+// each line cut to width
+func (p *Preview) Details(width int) []string {
+	lines := []string{}
+
+	tops := []string{}
+	for top := range p.Groups {
+		tops = append(tops, top)
+	}
+	sort.Slice(tops, func(i, j int) bool {
+		if p.Groups[tops[i]].Files != p.Groups[tops[j]].Files {
+			return p.Groups[tops[i]].Files > p.Groups[tops[j]].Files
+		}
+		return tops[i] < tops[j]
+	})
+	for i, top := range tops {
+		if i == 10 {
+			lines = append(lines, fmt.Sprintf("  ... and %d more directories", len(tops)-10))
+			break
+		}
+		group := p.Groups[top]
+		lines = append(lines, Truncate(fmt.Sprintf("  %-24s %8d files %12s", top, group.Files, FormatSize(group.Bytes)), width))
+	}
+
+	if p.Cached > 0 {
+		noun := "directories"
+		if p.Cached == 1 {
+			noun = "directory"
+		}
+		lines = append(lines, fmt.Sprintf("  (%d %s counted from the size cache, --no-size-cache walks them)", p.Cached, noun))
+	}
+
+	lines = append(lines, "first entries:")
+	for i, entry := range p.Entries {
+		if i == 10 {
+			lines = append(lines, fmt.Sprintf("  ... %d more, answer l to list them all", p.Files-10))
+			break
+		}
+		lines = append(lines, Truncate("  "+entry, width))
+	}
+
+	return lines
+}
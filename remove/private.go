@@ -0,0 +1,106 @@
+package remove
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Everything srm keeps for itself (the data dir, index, journal, intent
+// logs) goes through these helpers, so it is created owner-only whatever the
+// umask and is never written through a symlink someone else put in its place.
+
+var (
+	ErrSymlinkRefused = errors.New("is a symlink, refusing to write through it")
+	ErrNotPrivate     = errors.New("is group or world writable")
+)
+
+// MkdirPrivate makes sure dir exists as a real 0700 directory. Missing
+// parents are created 0700 too; dir itself must not be a symlink.
+func MkdirPrivate(dir string) error {
+	if err := os.MkdirAll(filepath.Dir(dir), 0700); err != nil {
+		return err
+	}
+	err := os.Mkdir(dir, 0700)
+	if err != nil && !errors.Is(err, fs.ErrExist) {
+		return err
+	}
+	created := err == nil
+
+	fi, err := os.Lstat(dir)
+	switch {
+	case err != nil:
+		return err
+	case fi.Mode()&fs.ModeSymlink != 0:
+		return fmt.Errorf("%s: %w", dir, ErrSymlinkRefused)
+	case !fi.IsDir():
+		return fmt.Errorf("%s: not a directory", dir)
+	case created && unixModes && fi.Mode().Perm() != 0700:
+		// the umask only ever takes bits away, but it can take too many
+		return os.Chmod(dir, 0700)
+	}
+	return nil
+}
+
+// OpenPrivate opens name for writing with flag, creating it in a private
+// directory if needed. The file ends up 0600 whatever the umask or its old
+// mode, and a symlink at name is refused rather than followed.
+func OpenPrivate(name string, flag int) (*os.File, error) {
+	if err := MkdirPrivate(filepath.Dir(name)); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(name, flag|oNoFollow, 0600)
+	if err != nil {
+		if fi, lerr := os.Lstat(name); lerr == nil && fi.Mode()&fs.ModeSymlink != 0 {
+			return nil, fmt.Errorf("%s: %w", name, ErrSymlinkRefused)
+		}
+		return nil, err
+	}
+	if err := PrivateMode(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// PrivateMode makes an open file 0600
+func PrivateMode(f *os.File) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if unixModes && fi.Mode().Perm() != 0600 {
+		return f.Chmod(0600)
+	}
+	return nil
+}
+
+// CheckPrivateDir returns why dir can't be trusted with srm's records: it is
+// a symlink, or others could write to it
+func CheckPrivateDir(dir string) error {
+	fi, err := os.Lstat(dir)
+	switch {
+	case err != nil:
+		return err
+	case fi.Mode()&fs.ModeSymlink != 0:
+		return fmt.Errorf("%s: %w", dir, ErrSymlinkRefused)
+	case unixModes && fi.Mode().Perm()&0022 != 0:
+		return fmt.Errorf("%s: %w", dir, ErrNotPrivate)
+	}
+	return nil
+}
+
+// DataDir is where srm keeps its own bookkeeping
+func DataDir() (string, error) {
+	if xdg := os.Getenv("XDG_DATA_HOME"); xdg != "" {
+		return filepath.Join(xdg, "srm"), nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".local", "share", "srm"), nil
+}
@@ -0,0 +1,133 @@
+package remove
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// PROCSCANMAX bounds how many processes a /proc scan looks at, so a box
+// running tens of thousands of them doesn't stall every removal
+var PROCSCANMAX = 4096
+
+// scanProcs calls visit with each process's pid, command name and /proc
+// directory, stopping after PROCSCANMAX processes or when visit returns
+// false. Processes that exit mid-scan are skipped.
+func scanProcs(visit func(pid int, comm string, dir string) bool) error {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return err
+	}
+
+	scanned := 0
+	for _, de := range entries {
+		pid, err := strconv.Atoi(de.Name())
+		if err != nil {
+			continue
+		}
+		if scanned++; scanned > PROCSCANMAX {
+			break
+		}
+		dir := filepath.Join("/proc", de.Name())
+		comm, err := os.ReadFile(filepath.Join(dir, "comm"))
+		if err != nil {
+			continue
+		}
+		if !visit(pid, strings.TrimSpace(string(comm)), dir) {
+			break
+		}
+	}
+	return nil
+}
+
+// execMapping is one executable mapping of a file into a process
+type execMapping struct {
+	pid          int
+	comm         string
+	major, minor uint64
+	inode        uint64
+	path         string
+}
+
+// executable mappings are collected at most once per run
+var (
+	execMappingsOnce sync.Once
+	execMappingsList []execMapping
+	execMappingsErr  error
+)
+
+// execMappings reads the executable, file backed lines of every readable
+// /proc/<pid>/maps
+func execMappings() ([]execMapping, error) {
+	execMappingsOnce.Do(func() {
+		execMappingsErr = scanProcs(func(pid int, comm string, dir string) bool {
+			f, err := os.Open(filepath.Join(dir, "maps"))
+			if err != nil {
+				return true
+			}
+			defer f.Close()
+
+			scanner := bufio.NewScanner(f)
+			for scanner.Scan() {
+				// 7f2c4e9d2000-7f2c4e9f4000 r-xp 00022000 fd:01 1835082 /usr/lib/libc.so.6
+				fields := strings.Fields(scanner.Text())
+				if len(fields) < 6 || !strings.Contains(fields[1], "x") {
+					continue
+				}
+				inode, err := strconv.ParseUint(fields[4], 10, 64)
+				if err != nil || inode == 0 {
+					continue
+				}
+				major, minor, ok := strings.Cut(fields[3], ":")
+				if !ok {
+					continue
+				}
+				m := execMapping{pid: pid, comm: comm, inode: inode}
+				m.major, _ = strconv.ParseUint(major, 16, 64)
+				m.minor, _ = strconv.ParseUint(minor, 16, 64)
+				m.path = strings.TrimSuffix(strings.Join(fields[5:], " "), " (deleted)")
+				execMappingsList = append(execMappingsList, m)
+			}
+			return true
+		})
+	})
+	return execMappingsList, execMappingsErr
+}
+
+// executingFrom returns the processes executing path, or for a directory
+// anything inside it, one use per process
+func executingFrom(path string, fi fs.FileInfo) ([]ProcessUse, error) {
+	mappings, err := execMappings()
+	if err != nil {
+		return nil, err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil, nil
+	}
+	dev := uint64(st.Dev)
+	major := (dev>>8)&0xfff | (dev>>32)&^0xfff
+	minor := dev&0xff | (dev>>12)&^0xff
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[int]bool{}
+	uses := []ProcessUse{}
+	for _, m := range mappings {
+		same := m.inode == uint64(st.Ino) && m.major == major && m.minor == minor
+		inside := fi.IsDir() && strings.HasPrefix(m.path, abs+"/")
+		if (same || inside) && !seen[m.pid] {
+			seen[m.pid] = true
+			uses = append(uses, ProcessUse{PID: m.pid, Command: m.comm, Path: m.path})
+		}
+	}
+	return uses, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package remove
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// executingFrom needs /proc, which only Linux has
+func executingFrom(path string, fi fs.FileInfo) ([]ProcessUse, error) {
+	return nil, errors.New("checking for running executables needs /proc")
+}
@@ -0,0 +1,150 @@
+package remove
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// PurgeCandidate permanently deletes one trash entry's payload, scrubbing
+// it first when secure, and forgets its index row
+func PurgeCandidate(fsys FS, index *Index, c EmptyCandidate, secure bool) Result {
+	start := time.Now()
+	result := Result{
+		Action:   "purged",
+		Source:   c.Entry.Origin,
+		Dest:     c.Entry.Payload(),
+		Bytes:    c.Size,
+		Strategy: "remove-all",
+		IsDir:    c.Entry.IsDir,
+	}
+	if result.Source == "" {
+		result.Source = result.Dest
+	}
+
+	if secure {
+		result.Strategy = "scrub"
+		notes, err := scrub(fsys, c.Entry.Payload(), []string{SCRUBRANDOM}, io.Discard)
+		if err != nil {
+			result.Action, result.Err = "failed", err
+			return result
+		}
+		result.Note = strings.Join(notes, "; ")
+	}
+	if err := fsys.RemoveAll(c.Entry.Payload()); err != nil {
+		result.Action, result.Err = "failed", err
+		return result
+	}
+	if err := RemoveTrashInfo(c.Entry.Payload()); err != nil {
+		result.Note = strings.TrimPrefix(result.Note+"; trash info: "+err.Error(), "; ")
+	}
+	if c.Known {
+		if err := index.Forget(c.Entry); err != nil {
+			result.Note = strings.TrimPrefix(result.Note+"; index: "+err.Error(), "; ")
+		}
+	}
+	result.Duration = time.Since(start)
+	return result
+}
+
+// SCRUBRANDOM and SCRUBZEROS are the passes scrub can write over a file
+const (
+	SCRUBRANDOM = "random"
+	SCRUBZEROS  = "zeros"
+)
+
+// zeros reads as an endless run of zero bytes
+type zeros struct{}
+
+func (zeros) Read(b []byte) (int, error) {
+	clear(b)
+	return len(b), nil
+}
+
+// scrub overwrites every regular file under path before it is unlinked,
+// with each of passes in turn, syncing after each, and then truncates it to
+// nothing. Bytes written go to counter as well. Symlinks and special files
+// are left alone, and so is a file with other hard links, whose contents
+// aren't only path's to destroy; notes names each of those. On
+// copy-on-write filesystems and SSDs the old blocks may well survive
+// anyway; this only stops the cheap recoveries.
+func scrub(fsys FS, path string, passes []string, counter io.Writer) (notes []string, err error) {
+	fi, err := fsys.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case fi.IsDir():
+		children, err := fsys.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range children {
+			more, err := scrub(fsys, filepath.Join(path, child.Name()), passes, counter)
+			notes = append(notes, more...)
+			if err != nil {
+				return notes, err
+			}
+		}
+		return notes, nil
+	case !fi.Mode().IsRegular():
+		return nil, nil
+	}
+	if links, ok := fileLinks(fi); ok && links > 1 {
+		return []string{fmt.Sprintf("%s has %d hard links, not overwritten", DisplayPath(path), links)}, nil
+	}
+
+	// the read-only attribute on Windows, the owner's write bit elsewhere
+	if attrs, err := fsys.Attributes(path); err == nil && attrs.ReadOnly {
+		if err := fsys.SetReadOnly(path, false); err != nil {
+			return nil, err
+		}
+	}
+	for i, pass := range passes {
+		source := io.Reader(zeros{})
+		if pass == SCRUBRANDOM {
+			source = rand.Reader
+		}
+		if err := scrubPass(fsys, path, fi, source, counter, i == len(passes)-1); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// scrubPass writes one pass of source over the whole of the file fi, which
+// path must still be, and syncs it; the last pass also truncates it to
+// nothing. The file is opened afresh so each pass starts at its beginning.
+func scrubPass(fsys FS, path string, fi os.FileInfo, source io.Reader, counter io.Writer, last bool) error {
+	f, err := fsys.OpenWrite(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if opened, err := f.Stat(); err != nil {
+		return err
+	} else if !os.SameFile(fi, opened) {
+		return fmt.Errorf("%s: %w", DisplayPath(path), ErrDirSwapped)
+	}
+
+	if _, err := io.CopyN(io.MultiWriter(f, counter), source, fi.Size()); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if last {
+		if err := f.Truncate(0); err != nil {
+			return err
+		}
+		if err := f.Sync(); err != nil {
+			return err
+		}
+	}
+	return f.Close()
+}
@@ -0,0 +1,340 @@
+package remove
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf16"
+)
+
+// The Recycle Bin as Windows keeps it: each user has a $Recycle.Bin\SID
+// directory on every volume, holding a removed file or tree as $RXXXXXX.ext
+// and what Explorer shows and restores it by as $IXXXXXX.ext, the same
+// six random characters and the name's extension. srm writes both itself
+// rather than asking SHFileOperation to, so it knows each entry's name as
+// it knows a trash directory's, and Explorer lists and restores what srm
+// put there like anything else. The format is read and written on every
+// platform, so the Recycle Bin of a Windows volume mounted elsewhere can be
+// the backend too, given as recyclebin:DIR.
+
+// RECYCLEVERSION is the $I format written, Windows 10's. Version 1,
+// Vista to 8's, has the path in a fixed 260 UTF-16 units instead.
+const RECYCLEVERSION = 2
+
+// FILETIMEUNIX is how many seconds a FILETIME, 100ns intervals since
+// 1601, counts up to the Unix epoch
+const FILETIMEUNIX = 11644473600
+
+// RecycleInfo is what an $I file holds about its $R
+type RecycleInfo struct {
+	// Origin is the absolute path it was removed from
+	Origin  string
+	Size    int64
+	Deleted time.Time
+}
+
+// encodeRecycleInfo is info as an $I file of RECYCLEVERSION
+func encodeRecycleInfo(info RecycleInfo) []byte {
+	path := utf16.Encode([]rune(info.Origin + "\x00"))
+	buf := make([]byte, 28, 28+2*len(path))
+	binary.LittleEndian.PutUint64(buf[0:], RECYCLEVERSION)
+	binary.LittleEndian.PutUint64(buf[8:], uint64(info.Size))
+	binary.LittleEndian.PutUint64(buf[16:], uint64((info.Deleted.Unix()+FILETIMEUNIX)*1e7+int64(info.Deleted.Nanosecond()/100)))
+	binary.LittleEndian.PutUint32(buf[24:], uint32(len(path)))
+	for _, unit := range path {
+		buf = binary.LittleEndian.AppendUint16(buf, unit)
+	}
+	return buf
+}
+
+// decodeRecycleInfo reads an $I file of either version
+func decodeRecycleInfo(data []byte) (RecycleInfo, error) {
+	if len(data) < 24 {
+		return RecycleInfo{}, errors.New("too short for a Recycle Bin $I file")
+	}
+	info := RecycleInfo{Size: int64(binary.LittleEndian.Uint64(data[8:]))}
+	ticks := int64(binary.LittleEndian.Uint64(data[16:]))
+	info.Deleted = time.Unix(ticks/1e7-FILETIMEUNIX, ticks%1e7*100)
+
+	var units []byte
+	switch version := binary.LittleEndian.Uint64(data[0:]); version {
+	case 1:
+		units = data[24:]
+	case 2:
+		if len(data) < 28 {
+			return RecycleInfo{}, errors.New("too short for a Recycle Bin $I file")
+		}
+		n := int(binary.LittleEndian.Uint32(data[24:]))
+		if len(data) < 28+2*n {
+			return RecycleInfo{}, errors.New("path cut short")
+		}
+		units = data[28 : 28+2*n]
+	default:
+		return RecycleInfo{}, fmt.Errorf("unknown $I version %d", version)
+	}
+	path := make([]uint16, 0, len(units)/2)
+	for i := 0; i+1 < len(units); i += 2 {
+		unit := binary.LittleEndian.Uint16(units[i:])
+		if unit == 0 {
+			break
+		}
+		path = append(path, unit)
+	}
+	info.Origin = string(utf16.Decode(path))
+	if info.Origin == "" {
+		return RecycleInfo{}, errors.New("no original path")
+	}
+	return info, nil
+}
+
+// RECYCLECHARS are what the six characters of an entry's name are drawn
+// from, as Windows draws them
+const RECYCLECHARS = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// newRecycleName is six random RECYCLECHARS followed by the extension of
+// name, what follows $R and $I for it
+func newRecycleName(name string) string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	for i := range b {
+		b[i] = RECYCLECHARS[int(b[i])%len(RECYCLECHARS)]
+	}
+	return string(b) + filepath.Ext(name)
+}
+
+// userRecycleBin is the Recycle Bin of the volume origin is on, for the
+// user srm runs as. Only Windows has one, elsewhere it is nil.
+var userRecycleBin func(origin string) (string, error)
+
+// userRecycleBins are the user's Recycle Bins on every volume that has one
+var userRecycleBins func() ([]string, error)
+
+// recycleBinBackend is the Recycle Bin as a TrashBackend. Its EntryIDs are
+// the names of entries after $R and $I. srm list, -W and srm empty don't
+// see what is in it, it isn't in the index; srm backend NAME and Explorer do.
+type recycleBinBackend struct {
+	// dir is the one Recycle Bin everything goes to, as recyclebin:DIR
+	// gives it; "" is each volume's own, userRecycleBin's
+	dir string
+	fs  FS
+}
+
+// newRecycleBinBackend opens the Recycle Bin dir, which must exist, or
+// with no dir the user's own
+func newRecycleBinBackend(dir string) (TrashBackend, error) {
+	if dir == "" {
+		if userRecycleBin == nil {
+			return nil, errors.New("there is no Recycle Bin here, give its directory as recyclebin:DIR")
+		}
+		return &recycleBinBackend{fs: OSFS{}}, nil
+	}
+	if !filepath.IsAbs(dir) {
+		return nil, fmt.Errorf("%q: expected an absolute Recycle Bin directory", dir)
+	}
+	if err := CheckTrashDir(dir); err != nil {
+		return nil, err
+	}
+	return &recycleBinBackend{dir: filepath.Clean(dir), fs: OSFS{}}, nil
+}
+
+func (b *recycleBinBackend) Root() string {
+	return b.dir
+}
+
+// binFor is the Recycle Bin what is at origin goes to
+func (b *recycleBinBackend) binFor(origin string) (string, error) {
+	if b.dir != "" {
+		return b.dir, nil
+	}
+	return userRecycleBin(origin)
+}
+
+// bins are the Recycle Bins entries are looked for in
+func (b *recycleBinBackend) bins() ([]string, error) {
+	if b.dir != "" {
+		return []string{b.dir}, nil
+	}
+	return userRecycleBins()
+}
+
+// Store writes the $I file, then moves src to its $R, copying it when the
+// Recycle Bin is on another volume, as the per-user one srm falls back to
+// can be. The reason and operation have nowhere to go.
+func (b *recycleBinBackend) Store(src string, meta EntryMeta) (EntryID, error) {
+	if _, err := b.fs.Lstat(src); err != nil {
+		return "", err
+	}
+	bin, err := b.binFor(meta.Origin)
+	if err != nil {
+		return "", err
+	}
+	size, _ := DiskUsage(b.fs, src)
+	info := encodeRecycleInfo(RecycleInfo{Origin: meta.Origin, Size: size, Deleted: meta.Deleted})
+
+	// a name whose $I this srm gets to create is its own, $R included
+	name := ""
+	for try := 0; name == ""; try++ {
+		if try == 16 {
+			return "", fmt.Errorf("%s: no free name: %w", DisplayPath(bin), fs.ErrExist)
+		}
+		candidate := newRecycleName(filepath.Base(src))
+		if _, err := b.fs.Lstat(filepath.Join(bin, "$R"+candidate)); err == nil {
+			continue
+		}
+		f, err := os.OpenFile(filepath.Join(bin, "$I"+candidate), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		_, err = f.Write(info)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(f.Name())
+			return "", err
+		}
+		name = candidate
+	}
+
+	payload := filepath.Join(bin, "$R"+name)
+	err = b.fs.RenameNoReplace(src, payload)
+	if errors.Is(err, syscall.EXDEV) {
+		if _, err = copyTree(b.fs, src, payload, nil, false); err == nil {
+			err = b.fs.RemoveAll(src)
+		}
+	}
+	if err != nil {
+		os.Remove(filepath.Join(bin, "$I"+name))
+		return "", err
+	}
+	return EntryID(name), nil
+}
+
+// find is the Recycle Bin holding id and what its $I says
+func (b *recycleBinBackend) find(id EntryID) (string, RecycleInfo, error) {
+	if string(id) == "" || strings.ContainsAny(string(id), SEPARATORS) {
+		return "", RecycleInfo{}, fmt.Errorf("%s: %w", id, ErrNoEntry)
+	}
+	bins, err := b.bins()
+	if err != nil {
+		return "", RecycleInfo{}, err
+	}
+	for _, bin := range bins {
+		data, err := os.ReadFile(filepath.Join(bin, "$I"+string(id)))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", RecycleInfo{}, err
+		}
+		info, err := decodeRecycleInfo(data)
+		if err != nil {
+			return "", RecycleInfo{}, fmt.Errorf("%s: %w", DisplayPath(filepath.Join(bin, "$I"+string(id))), err)
+		}
+		return bin, info, nil
+	}
+	return "", RecycleInfo{}, fmt.Errorf("%s: %w", id, ErrNoEntry)
+}
+
+func (b *recycleBinBackend) Restore(id EntryID, dst string) error {
+	bin, _, err := b.find(id)
+	if err != nil {
+		return err
+	}
+	if _, err := b.fs.Lstat(dst); err == nil {
+		return fmt.Errorf("%s: %w", DisplayPath(dst), os.ErrExist)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := MoveBack(b.fs, filepath.Join(bin, "$R"+string(id)), dst); err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(bin, "$I"+string(id)))
+}
+
+// List reads every $I file with its $R still there. Those whose $I can't
+// be read, like one Windows is writing, are left out.
+func (b *recycleBinBackend) List() ([]BackendEntry, error) {
+	bins, err := b.bins()
+	if err != nil {
+		return nil, err
+	}
+	listed := []BackendEntry{}
+	for _, bin := range bins {
+		des, err := os.ReadDir(bin)
+		if err != nil {
+			return nil, err
+		}
+		for _, de := range des {
+			name, ok := strings.CutPrefix(de.Name(), "$I")
+			if !ok {
+				continue
+			}
+			fi, err := b.fs.Lstat(filepath.Join(bin, "$R"+name))
+			if err != nil {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(bin, de.Name()))
+			if err != nil {
+				continue
+			}
+			info, err := decodeRecycleInfo(data)
+			if err != nil {
+				continue
+			}
+			listed = append(listed, BackendEntry{
+				ID:      EntryID(name),
+				Origin:  info.Origin,
+				Deleted: info.Deleted,
+				Size:    info.Size,
+				IsDir:   fi.IsDir(),
+			})
+		}
+	}
+	return listed, nil
+}
+
+func (b *recycleBinBackend) Purge(id EntryID) error {
+	bin, _, err := b.find(id)
+	if err != nil {
+		return err
+	}
+	if err := b.fs.RemoveAll(filepath.Join(bin, "$R"+string(id))); err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(bin, "$I"+string(id)))
+}
+
+func (b *recycleBinBackend) Stats() (BackendStats, error) {
+	entries, err := b.List()
+	if err != nil {
+		return BackendStats{}, err
+	}
+	stats := BackendStats{Entries: len(entries)}
+	for _, entry := range entries {
+		stats.Bytes += entry.Size
+	}
+	bins, err := b.bins()
+	if err != nil {
+		return stats, err
+	}
+	for _, bin := range bins {
+		stored, err := DiskUsage(b.fs, bin)
+		if err != nil {
+			return stats, err
+		}
+		stats.Stored += stored
+	}
+	return stats, nil
+}
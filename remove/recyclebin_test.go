@@ -0,0 +1,112 @@
+package remove
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+// $I files of both versions Windows writes are read, and srm's own written
+// as version 2
+func TestRecycleInfo(t *testing.T) {
+	deleted := time.Date(2026, 3, 14, 15, 9, 26, 535897900, time.UTC)
+	want := RecycleInfo{Origin: `C:\Users\zoë\notes.txt`, Size: 42, Deleted: deleted}
+	got, err := decodeRecycleInfo(encodeRecycleInfo(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Origin != want.Origin || got.Size != want.Size || !got.Deleted.Equal(want.Deleted) {
+		t.Fatalf("$I round trip gave %+v, want %+v", got, want)
+	}
+
+	// as Vista wrote it: the path in 260 units, deleted at the Unix epoch
+	v1 := make([]byte, 24+2*260)
+	binary.LittleEndian.PutUint64(v1[0:], 1)
+	binary.LittleEndian.PutUint64(v1[8:], 7)
+	binary.LittleEndian.PutUint64(v1[16:], 116444736000000000)
+	for i, unit := range utf16.Encode([]rune(`D:\old.txt`)) {
+		binary.LittleEndian.PutUint16(v1[24+2*i:], unit)
+	}
+	got, err = decodeRecycleInfo(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Origin != `D:\old.txt` || got.Size != 7 || !got.Deleted.Equal(time.Unix(0, 0)) {
+		t.Fatalf("version 1 $I read as %+v", got)
+	}
+	if _, err := decodeRecycleInfo(v1[:30]); err != nil {
+		t.Fatalf("a version 1 $I with a short path: %v", err)
+	}
+	binary.LittleEndian.PutUint64(v1[0:], 3)
+	if _, err := decodeRecycleInfo(v1); err == nil {
+		t.Fatal("read an $I of version 3")
+	}
+
+}
+
+// A Recycle Bin is stored in as Windows does, an $I and an $R for each
+// entry, and restored from and purged
+func TestRecycleBinBackend(t *testing.T) {
+	env := testEnv(t)
+	bin := filepath.Join(env.root, "$Recycle.Bin", "S-1-5-21-1000")
+	if err := MkdirPrivate(bin); err != nil {
+		t.Fatal(err)
+	}
+	backend, err := newRecycleBinBackend(bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := env.file("recycled/notes.txt", "recycled")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := env.remover(true)
+	r.opts.Backend, r.opts.BackendName = backend, "recyclebin"
+	result := r.Remove(path)
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	id, _ := strings.CutPrefix(result.Dest, "recyclebin:")
+	if len(id) != 6+len(".txt") || !strings.HasSuffix(id, ".txt") {
+		t.Fatalf("stored as %q, want six characters and .txt", result.Dest)
+	}
+	for _, prefix := range []string{"$I", "$R"} {
+		if _, err := os.Lstat(filepath.Join(bin, prefix+id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := backend.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].ID != EntryID(id) || entries[0].Origin != path || entries[0].Size == 0 {
+		t.Fatalf("listed %+v, want %s from %s", entries, id, path)
+	}
+	if err := backend.Restore(EntryID(".."+string(filepath.Separator)+id), path); !errors.Is(err, ErrNoEntry) {
+		t.Fatalf("restoring an id outside the bin gave %v, want %v", err, ErrNoEntry)
+	}
+	if err := backend.Restore(EntryID(id), path); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(path); err != nil || string(got) != "recycled" {
+		t.Fatalf("restored %q (%v)", got, err)
+	}
+
+	// a directory, stored and purged, leaving the bin empty
+	result = r.Remove(filepath.Dir(path))
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	id, _ = strings.CutPrefix(result.Dest, "recyclebin:")
+	if err := backend.Purge(EntryID(id)); err != nil {
+		t.Fatal(err)
+	}
+	if des, err := os.ReadDir(bin); err != nil || len(des) != 0 {
+		t.Fatalf("%d left in the bin after purging (%v)", len(des), err)
+	}
+}
@@ -0,0 +1,124 @@
+package remove
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// On Windows removals go to the Recycle Bin unless --backend or the config
+// says otherwise, and --on-no-trash=tmp to the user's %TEMP%, there being no
+// /tmp
+func init() {
+	userRecycleBin = windowsRecycleBin
+	userRecycleBins = windowsRecycleBins
+	PLATFORMBACKENDS["recyclebin"] = "recyclebin:"
+	PLATFORMBACKEND = "recyclebin"
+	TMPTRASH = os.TempDir()
+}
+
+// the user's SID is looked up at most once per run
+var (
+	userSIDOnce sync.Once
+	userSIDName string
+	userSIDErr  error
+)
+
+// userSID is the SID of the user srm runs as, which names their directory
+// in each volume's $Recycle.Bin
+func userSID() (string, error) {
+	userSIDOnce.Do(func() {
+		token, err := syscall.OpenCurrentProcessToken()
+		if err != nil {
+			userSIDErr = err
+			return
+		}
+		defer token.Close()
+		user, err := token.GetTokenUser()
+		if err != nil {
+			userSIDErr = err
+			return
+		}
+		userSIDName, userSIDErr = user.User.Sid.String()
+	})
+	return userSIDName, userSIDErr
+}
+
+// fallbackRecycleBin is where what is on a volume without a $Recycle.Bin,
+// like a network share, goes: %LOCALAPPDATA%\srm\RecycleBin, which Explorer
+// doesn't show but srm backend recyclebin does
+func fallbackRecycleBin() (string, error) {
+	dir := os.Getenv("LOCALAPPDATA")
+	if dir == "" {
+		return "", errors.New("%LOCALAPPDATA% is not set")
+	}
+	return filepath.Join(dir, "srm", "RecycleBin"), nil
+}
+
+// volumeRecycleBin is the user's directory in the $Recycle.Bin of the
+// volume that starts with volume, "" when it has none
+func volumeRecycleBin(volume string) (string, error) {
+	if volume == "" {
+		return "", nil
+	}
+	root := volume + `\$Recycle.Bin`
+	if fi, err := os.Stat(root); err != nil || !fi.IsDir() {
+		return "", nil
+	}
+	sid, err := userSID()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, sid), nil
+}
+
+// windowsRecycleBin is the Recycle Bin of origin's volume, created for the
+// user when Windows hasn't yet, falling back to fallbackRecycleBin when the
+// volume has no $Recycle.Bin or the user's can't be made
+func windowsRecycleBin(origin string) (string, error) {
+	bin, err := volumeRecycleBin(filepath.VolumeName(origin))
+	if err != nil {
+		return "", err
+	}
+	if bin != "" {
+		if err := os.MkdirAll(bin, 0700); err == nil {
+			return bin, nil
+		}
+	}
+	bin, err = fallbackRecycleBin()
+	if err != nil {
+		return "", fmt.Errorf("%s: no Recycle Bin: %w", DisplayPath(origin), err)
+	}
+	return bin, MkdirPrivate(bin)
+}
+
+// windowsRecycleBins are the user's Recycle Bins on every drive that has
+// one, and the fallback when it is there
+func windowsRecycleBins() ([]string, error) {
+	drives, _, err := syscall.NewLazyDLL("kernel32.dll").NewProc("GetLogicalDrives").Call()
+	if drives == 0 {
+		return nil, err
+	}
+	bins := []string{}
+	for i := 0; i < 26; i++ {
+		if drives&(1<<i) == 0 {
+			continue
+		}
+		bin, err := volumeRecycleBin(string(rune('A'+i)) + ":")
+		if err != nil {
+			return nil, err
+		}
+		if fi, err := os.Stat(bin); bin != "" && err == nil && fi.IsDir() {
+			bins = append(bins, bin)
+		}
+	}
+	if bin, err := fallbackRecycleBin(); err == nil {
+		if fi, err := os.Stat(bin); err == nil && fi.IsDir() {
+			bins = append(bins, bin)
+		}
+	}
+	return bins, nil
+}
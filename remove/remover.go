@@ -0,0 +1,1283 @@
+package remove
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Options controls how a Remover treats each operand
+type Options struct {
+	Force bool // -f
+	// ForceLevel is how many times -f was given, up to FORCEBYPASS; see
+	// SkipsPrompt for which questions each level skips
+	ForceLevel      int
+	Interactive     bool // -i
+	OnceInteractive bool // -I
+	Recursive       bool // -r / -R
+	Dir             bool // -d
+
+	// NoPreserveRoot is --no-preserve-root, letting / through CheckPreserved
+	NoPreserveRoot bool
+	// Protected are the config's protected paths, which are refused along
+	// with every directory above them
+	Protected []string
+	// ConfirmOverSize is confirm_over_size: a run removing more than this
+	// many bytes asks first. 0 never asks.
+	ConfirmOverSize int64
+	// ConfirmSize is --confirm-size: each operand bigger than this is asked
+	// about, -i or not. 0 never asks.
+	ConfirmSize int64
+
+	// SafeMode makes -f stop short of skipping confirmations
+	SafeMode bool
+
+	// TrashDir is where files are moved to. Leave it empty together with
+	// Permanent to delete files for real instead.
+	TrashDir  string
+	Permanent bool
+	// Delete is --permanent (-D): Permanent because it was asked for, so
+	// each operand is asked about in those words unless -f
+	Delete bool
+	// Overwrite is -P, which implies Delete: the passes scrub writes over
+	// every regular file before it is deleted, SCRUBZEROS for -P and
+	// SCRUBRANDOM before it for -PP
+	Overwrite []string
+	// TrashNote explains how TrashDir was chosen when it isn't a real trash
+	TrashNote string
+	// ResolveTrash picks each operand's trash from its own candidates, see
+	// ResolveTrash, which only differ from the run's when a trash on the
+	// operand's filesystem comes first. TrashDir is then the run's choice,
+	// used when none of an operand's candidates is usable.
+	ResolveTrash bool
+	// PreferTrash is --prefer-trash, reordering the candidates
+	PreferTrash []string
+	// Backend is where operands go instead of a trash directory, for
+	// --backend; nil for the trash. BackendName is its name in the config.
+	Backend     TrashBackend
+	BackendName string
+
+	// MeasureSize fills in Result.Bytes, which costs a walk for directories
+	MeasureSize bool
+
+	// Archive trashes directories as a single tarball instead of moving them
+	Archive bool
+
+	// Index, when set, gets a row for everything trashed, tagged with Op
+	Index *Index
+	Op    string
+	// Intents, when set along with Index, logs each move ahead of time so an
+	// interrupted run can be finished by IntentLog.Replay
+	Intents *IntentLog
+	// Reason is the --reason note recorded on every index row
+	Reason string
+
+	// FSPolicies pick trash, permanent, ask or skip by filesystem type
+	FSPolicies []FSPolicy
+
+	// KeepHidden and HiddenOnly, when above 0, make -r empty a directory
+	// operand instead of removing it: KeepHidden keeps dotfiles and
+	// HiddenOnly removes only them, looking that many levels down
+	KeepHidden int
+	HiddenOnly int
+
+	// OneFileSystem, -x, makes -r leave whatever is mounted below a
+	// directory operand where it is, trashing the rest around it
+	OneFileSystem bool
+
+	// Verify reads back every file copied into the trash across filesystems
+	// and removes the original only when the copy matches
+	Verify bool
+
+	// CheckExec looks for processes running the operand before removing it,
+	// which costs a scan of /proc
+	CheckExec bool
+
+	// Fast, --fast, skips every check in OPTIONALCHECKS, and CheckBudgets
+	// is the longest each may take on one file before it is skipped for the
+	// rest of the run
+	Fast         bool
+	CheckBudgets map[string]time.Duration
+
+	// POSIX words prompts as rm does and asks about a write-protected file
+	// only when PromptWriteProtected, see the POSIX variable
+	POSIX                bool
+	PromptWriteProtected bool
+
+	// DryRun makes every decision a removal would, filters and policies
+	// included, but asks nothing and changes nothing. Each Result says what
+	// would have happened and carries the questions that would have been
+	// asked.
+	DryRun bool
+
+	// Callbacks report progress and ask the user questions
+	Callbacks Callbacks
+
+	// FS is what every filesystem operation goes through, defaults to OSFS
+	FS FS
+}
+
+// Callbacks let a program embedding a Remover follow along and answer its
+// questions. They are called synchronously from the goroutine doing the
+// removal; a program that calls one Remover from several goroutines gets
+// them called from each, and must make them safe for that. Nil callbacks
+// are never called and cost nothing, except OnPrompt which defaults to
+// asking on the terminal.
+type Callbacks struct {
+	// OnEntryStart is called before an operand is looked at
+	OnEntryStart func(path string)
+	// OnEntryDone is called with the Result of every operand, failures and
+	// declined prompts included
+	OnEntryDone func(Result)
+	// OnProgress reports bytes written so far for the operand in progress,
+	// for strategies that copy data rather than rename it
+	OnProgress func(bytesDone, bytesTotal int64)
+	// OnPrompt asks the user something. For confirmations an answer in
+	// YESANSWERS means yes. An error fails the operand.
+	OnPrompt func(PromptRequest) (string, error)
+}
+
+// PromptRequest is one question for the user
+type PromptRequest struct {
+	Kind    string // confirm, batch or more
+	Path    string // the operand being asked about, empty for batch and more
+	Message string
+}
+
+// terminalPrompt returns the default OnPrompt. With posix the question goes
+// to stderr with rm's layout, and no notes are added to it.
+func terminalPrompt(posix bool) func(PromptRequest) (string, error) {
+	return func(req PromptRequest) (string, error) {
+		// rm asks each question whatever became of the last, and takes the
+		// end of input as no every time
+		if posix {
+			fmt.Fprint(os.Stderr, "srm: "+DisplayName(req.Message)+" ")
+			in := AnswerInput()
+			if in == nil {
+				return "", nil
+			}
+			answer, _ := readAnswer(in, io.Discard)
+			return answer, nil
+		}
+		return GetUserAnswer(DisplayName(req.Message)), nil
+	}
+}
+
+// Result describes what happened to a single operand
+type Result struct {
+	Action   string // trashed, deleted, skipped, covered, kept or failed
+	Source   string
+	Dest     string
+	Bytes    int64
+	Strategy string // rename, copy, archive, remove or remove-all
+	IsDir    bool
+	Duration time.Duration
+	Note     string
+	// FSType and Policy are set when a filesystem type policy applied
+	FSType  string
+	Policy  string
+	Overlay string
+	// Trash is the trash Dest is in and TrashWhy why it was chosen
+	Trash    string
+	TrashWhy string
+	// Volume and TrashVolume are the mount points the operand and Trash
+	// are on. When they differ, the space it took moved between volumes.
+	Volume      string
+	TrashVolume string
+	// Prompts, Warnings and Problems are, under DryRun, the questions that
+	// would have been asked, the warnings printed and what the kernel is
+	// likely to refuse
+	Prompts  []string
+	Warnings []string
+	Problems []string
+	// Verify is, under --verify, "verified" once a copy was read back and
+	// matched, or why there was nothing to verify
+	Verify string
+	// Op is the operation ID of the run, shared with the journal and index
+	Op  string
+	Err error
+}
+
+// Remover moves operands to the trash (or deletes them) according to Options
+type Remover struct {
+	opts Options
+	fs   FS
+
+	mu sync.Mutex
+	// trashes are the trash directories held open from their first move on
+	trashes map[string]Dir
+	// trashChoices is the trash picked for operands in each parent directory
+	trashChoices map[string][]TrashCandidate
+	// trashChecks caches CheckTrashCandidate, which writes a probe file
+	trashChecks map[string]error
+	// volumes caches volumeOf
+	volumes map[string]string
+	// destinations are the trash paths planned so far, kept from later
+	// operands before anything has been moved there
+	destinations map[string]bool
+	// checks is what the optional checks have cost, see runCheck
+	checks *checkTimes
+	// lostTrashes are the trashes that turned read-only during the run,
+	// see trashLost; nothing more is moved to them
+	lostTrashes map[string]error
+	// session is what an -i run has trashed so far, in order, and aborted
+	// whether it was ended with a; see confirm
+	session []sessionMove
+	aborted bool
+	// operand is the RemoveEach operand under way and report where its
+	// Result goes, which remember keeps with its move
+	operand struct {
+		path   string
+		report func(Result)
+	}
+}
+
+func NewRemover(opts Options) *Remover {
+	if opts.Callbacks.OnPrompt == nil {
+		opts.Callbacks.OnPrompt = terminalPrompt(opts.POSIX)
+	}
+	if opts.FS == nil {
+		opts.FS = OSFS{}
+	}
+	return &Remover{
+		opts:         opts,
+		fs:           opts.FS,
+		trashes:      map[string]Dir{},
+		trashChoices: map[string][]TrashCandidate{},
+		trashChecks:  map[string]error{},
+		volumes:      map[string]string{},
+		destinations: map[string]bool{},
+		checks:       newCheckTimes(),
+		lostTrashes:  map[string]error{},
+	}
+}
+
+// Close releases the trash directories the Remover holds open
+func (r *Remover) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	errs := []error{}
+	for _, dir := range r.trashes {
+		if dir != nil {
+			errs = append(errs, dir.Close())
+		}
+	}
+	r.trashes = map[string]Dir{}
+	return errors.Join(errs...)
+}
+
+// trashDir opens the trash directory path the first time something is moved
+// into it. The path is resolved then, so a trash that is a symlink on
+// purpose keeps working, but every later move goes into that same directory
+// whatever happens to the path.
+func (r *Remover) trashDir(path string) (Dir, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if dir, ok := r.trashes[path]; ok {
+		return dir, nil
+	}
+	dir, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, err
+	}
+	opened, err := r.fs.OpenDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	r.trashes[path] = opened
+	return opened, nil
+}
+
+// trashCandidates returns path's trash candidates in order, each checked.
+// Operands in the same directory share their candidates, so a directory
+// being emptied costs one resolution.
+func (r *Remover) trashCandidates(path string) []TrashCandidate {
+	parent := filepath.Dir(path)
+	if abs, err := filepath.Abs(parent); err == nil {
+		parent = abs
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if candidates, ok := r.trashChoices[parent]; ok {
+		return candidates
+	}
+	candidates := ResolveTrash(CurrentTrashContext(path, r.opts.PreferTrash))
+	for i, candidate := range candidates {
+		err, ok := r.trashChecks[candidate.Dir]
+		if !ok {
+			err = CheckTrashCandidate(candidate)
+			r.trashChecks[candidate.Dir] = err
+		}
+		candidates[i].Err = err
+	}
+	r.trashChoices[parent] = candidates
+	return candidates
+}
+
+// moveIntoTrash renames the planned operand to its Dest's name inside the
+// open trash directory, relative to parent when that is held open, then
+// checks that what arrived is what Plan looked at
+func (r *Remover) moveIntoTrash(plan Plan, parent Dir) error {
+	trash, err := r.trashDir(filepath.Dir(plan.Dest))
+	if err != nil {
+		return err
+	}
+
+	name := filepath.Base(plan.Dest)
+	if parent != nil {
+		err = parent.RenameTo(filepath.Base(plan.Path), trash, name)
+	} else {
+		err = trash.Rename(plan.Path, name)
+	}
+	if err != nil {
+		return err
+	}
+	after, err := trash.Lstat(name)
+	if err != nil {
+		return err
+	}
+	if !os.SameFile(plan.info, after) {
+		return fmt.Errorf("%s: %w", DisplayPath(plan.Path), ErrPayloadSwapped)
+	}
+	return nil
+}
+
+// claimDest claims plan's Dest before anything is moved there, which in a
+// freedesktop.org trash is writing its info file exclusively. When another
+// trasher has claimed the name since it was planned, the next free one is
+// planned and claimed instead. release gives the claim up again.
+func (r *Remover) claimDest(plan *Plan) (release func(), err error) {
+	for tries := 1; ; tries++ {
+		release, err = writeTrashInfo(plan.Dest, r.trashInfoOrigin(*plan), time.Now())
+		if !errors.Is(err, fs.ErrExist) || tries == TRASHNAMETRIES {
+			return release, err
+		}
+		if err := r.nextDest(plan); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// nextDest plans the next free name in plan's trash for it, the one it had
+// having been taken by another trasher
+func (r *Remover) nextDest(plan *Plan) error {
+	ext := ""
+	if plan.Strategy == "archive" {
+		ext = "." + ARCHIVEFORMAT
+	}
+	dest, err := r.trashDest(plan, filepath.Base(plan.Path), ext)
+	if err != nil {
+		return err
+	}
+	plan.tracef("another trasher took %s first, so this one is %s", plan.Dest, dest)
+	plan.Dest = dest
+	return nil
+}
+
+// moveToDest is moveIntoTrash, where another srm moving to the same name
+// after plan's Dest was claimed, as srms run side by side by xargs -P can,
+// fails the rename rather than one replacing the other's payload. The next
+// free name is then claimed and moved to instead, *release swapped for that
+// claim and retarget called with plan's new Dest before each retry.
+func (r *Remover) moveToDest(plan *Plan, parent Dir, release *func(), retarget func()) error {
+	err := r.moveIntoTrash(*plan, parent)
+	for tries := 1; errors.Is(err, fs.ErrExist) && tries < TRASHNAMETRIES; tries++ {
+		(*release)()
+		*release = func() {}
+		if err = r.nextDest(plan); err != nil {
+			return err
+		}
+		var claimed func()
+		if claimed, err = r.claimDest(plan); err != nil {
+			return err
+		}
+		*release = claimed
+		retarget()
+		err = r.moveIntoTrash(*plan, parent)
+	}
+	return err
+}
+
+// retarget points entry, whose intent was logged for the Dest plan had
+// before, at the one it has now, logging the intent again
+func (r *Remover) retarget(entry *IndexEntry, plan Plan) error {
+	entry.Trash, entry.Name = filepath.Dir(plan.Dest), filepath.Base(plan.Dest)
+	if r.opts.Intents == nil {
+		return nil
+	}
+	return r.opts.Intents.Begin(*entry)
+}
+
+// copyIntoTrash is what a move into the trash falls back on when the rename
+// fails with EXDEV, the trash being on another filesystem or reached through
+// another mount: the operand is copied to its Dest with copyTree, then
+// removed. copied reports whether the copy made it into the trash, where it
+// stays even if removing the operand then fails, so nothing is lost.
+func (r *Remover) copyIntoTrash(plan Plan, progress func(int64)) (size int64, copied bool, err error) {
+	fi, err := r.fs.Lstat(plan.Path)
+	if err != nil {
+		return 0, false, err
+	}
+	if !os.SameFile(plan.info, fi) {
+		return 0, false, fmt.Errorf("%s: %w", DisplayPath(plan.Path), ErrPayloadSwapped)
+	}
+	if size, err = copyTree(r.fs, plan.Path, plan.Dest, progress, r.opts.Verify); err != nil {
+		return size, false, err
+	}
+	if err := r.fs.RemoveAll(plan.Path); err != nil {
+		return size, true, fmt.Errorf("copied into the trash as %s, but removing the original failed: %w", DisplayPath(plan.Dest), err)
+	}
+	return size, true, nil
+}
+
+// verifyNote is Result.Verify for a move that succeeded with strategy
+func (r *Remover) verifyNote(strategy string) string {
+	if !r.opts.Verify {
+		return ""
+	}
+	switch strategy {
+	case "copy":
+		return "verified"
+	case "rename":
+		return "nothing to verify, renamed within one filesystem"
+	}
+	return ""
+}
+
+// copyProgress is the progress callback for copying path across
+// filesystems, nil without an OnProgress
+func (r *Remover) copyProgress(path string) func(int64) {
+	onProgress := r.opts.Callbacks.OnProgress
+	if onProgress == nil {
+		return nil
+	}
+	total, _ := DiskUsage(r.fs, path)
+	if r.opts.Verify {
+		// the read back goes through the same count
+		total *= 2
+	}
+	return func(done int64) { onProgress(done, total) }
+}
+
+// ConfirmBatch asks the single -I question for removing more than three
+// operands, returning true when there is nothing to ask. The question comes
+// with a preview of what the operands cover, and answering l lists all of it.
+// Under DryRun the question is printed rather than asked.
+func (r *Remover) ConfirmBatch(paths []string) bool {
+	if r.opts.POSIX {
+		return r.confirmBatchPOSIX(paths)
+	}
+	if !r.opts.OnceInteractive || len(paths) <= 3 {
+		return true
+	}
+
+	preview := walkPreview(r.fs, paths, r.opts.Recursive, PREVIEWBUDGET, SIZECACHE)
+	width, height := TerminalSize()
+	for _, line := range preview.Details(width) {
+		fmt.Println(DisplayName(line))
+	}
+	if r.opts.DryRun {
+		fmt.Println("would ask: " + preview.Summary())
+		return true
+	}
+
+	for {
+		answer, err := r.opts.Callbacks.OnPrompt(PromptRequest{Kind: "batch", Message: preview.Summary() + " [y/n/l] "})
+		if err != nil || answer != "l" {
+			return err == nil && In(answer, YESANSWERS)
+		}
+		if preview.Cached > 0 {
+			// listing everything means walking what the cache counted
+			preview = walkPreview(r.fs, paths, r.opts.Recursive, PREVIEWBUDGET, nil)
+		}
+		r.page(preview.Entries, width, height)
+	}
+}
+
+// page prints lines a screenful at a time until they run out or the user
+// answers q
+func (r *Remover) page(lines []string, width int, height int) {
+	pageSize := height - 1
+	if pageSize < 1 {
+		pageSize = 1
+	}
+
+	for i, line := range lines {
+		if i > 0 && i%pageSize == 0 {
+			answer, err := r.opts.Callbacks.OnPrompt(PromptRequest{Kind: "more", Message: "-- more (enter to continue, q to stop) -- "})
+			if err != nil || answer == "q" {
+				return
+			}
+		}
+		fmt.Println(Truncate(DisplayName(line), width))
+	}
+}
+
+// Plan is what Remove has decided to do with one operand before asking
+// anything or touching the filesystem. Trace says why, one step per line,
+// and is what srm explain prints.
+type Plan struct {
+	Path     string // the operand without trailing slashes
+	IsDir    bool
+	ReadOnly bool
+	// ClearReadOnly drops a read-only attribute that would block the move
+	ClearReadOnly bool
+	// Times are the operand's own timestamps, not those of a symlink's target
+	Times FileTimes
+	// Prompts must all be answered yes, in order, before anything happens
+	Prompts []string
+	// Warnings are printed before removing, without asking
+	Warnings []string
+	Action   string // trashed, deleted or skipped
+	Strategy string // rename, archive, remove or remove-all
+	Dest     string
+	// FSType is the filesystem the operand is on and Policy what the
+	// fstype table says to do there, both empty when there is no table
+	FSType string
+	Policy string
+	// Overlay is "whiteout" or "opaque directory" for overlayfs artifacts
+	Overlay string
+	// Trash is the trash Dest is in and TrashWhy why it was chosen, out of
+	// TrashCandidates when each operand's trash is resolved
+	Trash           string
+	TrashWhy        string
+	TrashCandidates []TrashCandidate
+	// Volume and TrashVolume are the mount points of the operand and of
+	// Trash, when there is a mount table to tell
+	Volume      string
+	TrashVolume string
+	Trace       []string
+
+	// InTrash is set when the operand lies in a trash, which removeInTrash
+	// asks about removing it from
+	InTrash *InTrash
+
+	// info is the operand's Lstat, what a move into the trash must deliver
+	info fs.FileInfo
+}
+
+func (p *Plan) tracef(format string, args ...interface{}) {
+	p.Trace = append(p.Trace, fmt.Sprintf(format, args...))
+}
+
+// Plan runs every check Remove makes on path without prompting or modifying
+// anything. The error is the one Remove would fail with.
+func (r *Remover) Plan(path string) (Plan, error) {
+	plan := Plan{Path: path}
+
+	if err := CheckOperand(path); err != nil {
+		plan.tracef("it isn't a usable operand")
+		return plan, err
+	}
+	if err := CheckPreserved(r.fs, path, !r.opts.NoPreserveRoot); err != nil {
+		plan.tracef("it is ., .. or the root directory, which are never removed")
+		return plan, err
+	}
+	if protected := protectedBy(path, r.opts.Protected); protected != "" {
+		plan.tracef("protected = [...] in the config names %s", protected)
+		return plan, fmt.Errorf("%s: %w (%s is in the config's protected list)", DisplayPath(path), ErrProtectedPath, DisplayPath(protected))
+	}
+
+	// directory and -r check
+	isDir, err := IsDir(r.fs, path)
+	if errors.Is(err, fs.ErrNotExist) {
+		plan.tracef("it does not exist")
+		return plan, fmt.Errorf("%s: %w", DisplayPath(path), ErrNotFound)
+	}
+	if err != nil {
+		return plan, err
+	}
+	plan.IsDir = isDir
+
+	if isDir && !r.opts.Recursive && !r.opts.Dir {
+		// if its a directory and they haven't specified -r || -R || -d then fail
+		plan.tracef("it is a directory, which needs -r (or -d when empty)")
+		return plan, fmt.Errorf("%s: %w", DisplayPath(path), ErrIsDirectory)
+	}
+	if isDir && !r.opts.Recursive {
+		// like rmdir, -d alone never takes anything with the directory
+		children, err := r.fs.ReadDir(path)
+		if err != nil {
+			return plan, err
+		}
+		if len(children) > 0 {
+			plan.tracef("it is a directory that isn't empty, which -d alone won't remove")
+			return plan, fmt.Errorf("%s: %w", DisplayPath(path), ErrDirNotEmpty)
+		}
+	}
+	if isDir {
+		plan.tracef("it is a directory and -r or -d was given")
+	}
+	// after the checks rm makes, which a real run fails just the same
+	if !r.opts.Permanent && r.opts.TrashDir == "" && r.opts.Backend == nil {
+		plan.tracef("no trash to move it to and permanent deletion is off")
+		return plan, fmt.Errorf("%s: %w", DisplayPath(path), ErrTrashUnavailable)
+	}
+
+	// if it ends with a / strip it
+	if trimmed := trimSeparators(path); trimmed != path {
+		path = trimmed
+		plan.Path = path
+	}
+
+	fi, err := r.fs.Lstat(path)
+	if err != nil {
+		return plan, err
+	}
+	plan.Times = fileTimes(path, fi)
+	plan.info = fi
+
+	// container layers are full of these, and they are removed like any file
+	switch {
+	case IsWhiteout(fi):
+		plan.Overlay = "whiteout"
+		plan.tracef("it is an overlayfs whiteout (a 0/0 character device), not a real device")
+	case isDir:
+		opaque := false
+		r.runCheck("overlay", path, func() { opaque = len(OverlayXattrs(path)) > 0 })
+		if opaque {
+			plan.Overlay = "opaque directory"
+			plan.tracef("it is an opaque overlayfs directory; --archive keeps the opaque xattr")
+		}
+	}
+
+	attrs, err := r.fs.Attributes(path)
+	if err != nil {
+		return plan, err
+	}
+	plan.ReadOnly = attrs.ReadOnly
+	if !r.opts.POSIX && !r.opts.Delete && !r.opts.SkipsPrompt("intrash") {
+		r.checkInTrash(&plan)
+	}
+	decision := decideAttributes(path, attrs, r.opts)
+	plan.Trace = append(plan.Trace, decision.Trace...)
+	if decision.Err != nil {
+		return plan, decision.Err
+	}
+	plan.ClearReadOnly = decision.ClearReadOnly
+
+	if r.opts.CheckExec {
+		r.runCheck("exec", path, func() { r.checkExecuting(&plan, fi) })
+	}
+
+	permanent := r.opts.Permanent
+	if len(r.opts.FSPolicies) > 0 {
+		if err := r.applyFSPolicy(&plan); err != nil {
+			return plan, err
+		}
+		if plan.Policy == "skip" {
+			plan.Action = "skipped"
+			return plan, nil
+		}
+		permanent = permanent || plan.Policy == "permanent"
+	}
+
+	if isDir && r.opts.OnceInteractive && r.opts.Recursive && !r.opts.POSIX {
+		plan.tracef("-I asks before removing a directory recursively")
+		plan.Prompts = append(plan.Prompts, fmt.Sprintf("recursively remove %s?", DisplayPath(path)))
+	}
+
+	// --confirm-size, which -i's question then says the size in
+	oversize := int64(-1)
+	if r.opts.ConfirmSize > 0 && !r.opts.SkipsPrompt("oversize") && !r.opts.POSIX {
+		size, errs := sizeWhatCan(r.fs, path)
+		for _, err := range errs {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("sizing %s: %s, counting the rest", DisplayPath(path), displayErr(err, path)))
+		}
+		if size > r.opts.ConfirmSize {
+			plan.tracef("it is %s, over --confirm-size %s", FormatSize(size), FormatSize(r.opts.ConfirmSize))
+			oversize = size
+		}
+	}
+
+	// -i
+	if r.opts.POSIX && (r.opts.Interactive || decision.WriteProtected) {
+		plan.tracef("rm asks before removing it")
+		plan.Prompts = append(plan.Prompts, posixPrompt(path, fi, attrs.ReadOnly))
+	} else if r.opts.Delete && (r.opts.Interactive || !r.opts.SkipsPrompt("permanent")) {
+		plan.tracef("--permanent asks before deleting it for real")
+		if len(r.opts.Overwrite) > 0 {
+			plan.Prompts = append(plan.Prompts, fmt.Sprintf("overwrite and permanently remove %s? this cannot be undone ", DisplayPath(path)))
+		} else {
+			plan.Prompts = append(plan.Prompts, fmt.Sprintf("permanently remove %s? this cannot be undone ", DisplayPath(path)))
+		}
+	} else if oversize >= 0 {
+		plan.Prompts = append(plan.Prompts, fmt.Sprintf("%s is %s, remove?", DisplayPath(path), FormatSize(oversize)))
+	} else if r.opts.Interactive {
+		if r.opts.SafeMode {
+			plan.tracef("safe mode asks before every removal")
+		} else {
+			plan.tracef("-i asks before every removal")
+		}
+		if attrs.Hidden {
+			plan.Prompts = append(plan.Prompts, fmt.Sprintf("remove hidden file %s?", DisplayPath(path)))
+		} else {
+			plan.Prompts = append(plan.Prompts, fmt.Sprintf("remove %s?", DisplayPath(path)))
+		}
+	}
+	plan.Prompts = append(plan.Prompts, decision.Prompts...)
+
+	filename := filepath.Base(path)
+
+	why := "there is no trash to use"
+	if !r.opts.Permanent {
+		why = "its filesystem type policy is permanent"
+	}
+	if !permanent && r.opts.Backend == nil {
+		if err := r.chooseTrash(&plan); err != nil {
+			return plan, err
+		}
+		plan.Volume, plan.TrashVolume = r.volumeOf(filepath.Dir(path)), r.volumeOf(plan.Trash)
+	}
+
+	switch {
+	case permanent && len(r.opts.Overwrite) > 0:
+		plan.Action, plan.Strategy = "deleted", "overwrite"
+		plan.tracef("-P overwrites every file in it with %s, then it is deleted", strings.Join(r.opts.Overwrite, " then "))
+		if fstype, err := fstypeOf(path); err == nil && In(fstype, COWFILESYSTEMS) {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("%s is on %s, which is copy-on-write: overwriting it leaves the old contents on disk", DisplayPath(path), fstype))
+		}
+	case permanent && r.opts.Recursive:
+		plan.Action, plan.Strategy = "deleted", "remove-all"
+		plan.tracef("%s, so it is deleted with everything under it", why)
+	case permanent:
+		plan.Action, plan.Strategy = "deleted", "remove"
+		plan.tracef("%s, so it is deleted", why)
+	case r.opts.Backend != nil:
+		plan.Action, plan.Strategy = "trashed", "backend"
+		plan.Trash, plan.TrashWhy = r.opts.BackendName, "--backend or backend in the config"
+		plan.tracef("it is stored in the backend %s", r.opts.BackendName)
+	case r.opts.Archive && isDir:
+		if plan.Dest, err = r.trashDest(&plan, filename, "."+ARCHIVEFORMAT); err != nil {
+			return plan, err
+		}
+		plan.Action, plan.Strategy = "trashed", "archive"
+		plan.tracef("--archive packs the directory into %s, then removes the tree", plan.Dest)
+	default:
+		if plan.Dest, err = r.trashDest(&plan, filename, ""); err != nil {
+			return plan, err
+		}
+		plan.Action, plan.Strategy = "trashed", "rename"
+		plan.tracef("it is renamed to %s", plan.Dest)
+	}
+
+	return plan, nil
+}
+
+// trashDest is where name+ext goes in plan's trash: under its own name, or
+// the first free of name.1, name.2, ... when the trash or an earlier operand
+// has it, so nothing in the trash is ever replaced
+func (r *Remover) trashDest(plan *Plan, name string, ext string) (string, error) {
+	free, err := freeTrashName(r.fs, plan.Trash, name, ext, r.destinations)
+	if err != nil {
+		plan.tracef("no name for it fits in the trash")
+		return "", err
+	}
+	switch {
+	case !strings.HasPrefix(free, name):
+		plan.tracef("%s is too long for the trash, so this one is %s", name+ext, free)
+	case free != name+ext:
+		plan.tracef("the trash has a %s already, so this one is %s", name+ext, free)
+	}
+	r.destinations[filepath.Join(plan.Trash, free)] = true
+	return filepath.Join(plan.Trash, free), nil
+}
+
+// chooseTrash sets plan's Trash: the first usable of its candidates when
+// trashes are resolved per operand, TrashDir otherwise. Once TrashDir has
+// turned read-only the candidates are resolved again, and when none is
+// usable either the error is ErrTrashLost.
+func (r *Remover) chooseTrash(plan *Plan) error {
+	plan.Trash, plan.TrashWhy = r.opts.TrashDir, r.opts.TrashNote
+	lost := r.trashIsLost(plan.Trash)
+	if (!r.opts.ResolveTrash || r.opts.TrashNote != "") && !lost {
+		return nil
+	}
+
+	plan.TrashCandidates = r.trashCandidates(plan.Path)
+	for _, candidate := range plan.TrashCandidates {
+		if candidate.Err != nil {
+			plan.tracef("trash %s (%s) is passed over: %s", candidate.Dir, candidate.Why, candidate.Err)
+			continue
+		}
+		plan.Trash, plan.TrashWhy = candidate.Dir, candidate.Why
+		plan.tracef("trash %s is used: %s", candidate.Dir, candidate.Why)
+		return nil
+	}
+	if lost {
+		plan.tracef("trash %s turned read-only and no other is usable", plan.Trash)
+		return fmt.Errorf("%s: %w (%s)", DisplayPath(plan.Path), ErrTrashLost, DisplayPath(plan.Trash))
+	}
+	plan.TrashWhy = "the run's trash, none of its own candidates being usable"
+	plan.tracef("trash %s is used: %s", plan.Trash, plan.TrashWhy)
+	return nil
+}
+
+// trashIsLost reports whether trash turned read-only during the run
+func (r *Remover) trashIsLost(trash string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, lost := r.lostTrashes[trash]
+	return lost
+}
+
+// trashLost is called when a move into trash failed with err, EROFS. When
+// the trash is what is read-only, rather than the operand's filesystem, as
+// after a suspend brings a home back mounted read-only, it is marked lost:
+// nothing more is moved there, and the candidates of every operand are
+// resolved again without it. It reports whether the trash was lost.
+func (r *Remover) trashLost(trash string, err error) bool {
+	if probe, err := r.fs.Create(trashProbe(trash)); err == nil {
+		probe.Close()
+		r.fs.Remove(trashProbe(trash))
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.lostTrashes[trash]; ok {
+		return true
+	}
+	r.lostTrashes[trash] = err
+	r.trashChecks[trash] = err
+	r.trashChoices = map[string][]TrashCandidate{}
+	if dir := r.trashes[trash]; dir != nil {
+		dir.Close()
+	}
+	delete(r.trashes, trash)
+	fmt.Fprintf(os.Stderr, "srm: warning: the trash %s turned read-only; nothing more is moved there\n", DisplayName(DisplayPath(trash)))
+	return true
+}
+
+// trashProbe is the file trashLost creates in trash to see if it still can
+func trashProbe(trash string) string {
+	return filepath.Join(trash, fmt.Sprintf(".srm-probe-%d", os.Getpid()))
+}
+
+// LostTrashes are the trashes that turned read-only during the run
+func (r *Remover) LostTrashes() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	lost := []string{}
+	for trash := range r.lostTrashes {
+		lost = append(lost, trash)
+	}
+	sort.Strings(lost)
+	return lost
+}
+
+// volumeOf returns the mount point of the directory dir, "" when there is
+// no mount table
+func (r *Remover) volumeOf(dir string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if volume, ok := r.volumes[dir]; ok {
+		return volume
+	}
+	volume := ""
+	if mounts, err := LoadMounts(); err == nil {
+		real, err := filepath.Abs(dir)
+		if err == nil {
+			if resolved, err := filepath.EvalSymlinks(real); err == nil {
+				real = resolved
+			}
+			volume = mountOf(real, mounts).Point
+		}
+	}
+	r.volumes[dir] = volume
+	return volume
+}
+
+// applyFSPolicy looks up the filesystem plan.Path is on and applies the
+// first fstype policy matching it. Filesystems no pattern matches, and ones
+// whose type can't be told, get the default of trashing.
+func (r *Remover) applyFSPolicy(plan *Plan) error {
+	fstype, err := fstypeOf(plan.Path)
+	if err != nil {
+		plan.tracef("its filesystem type is unknown (%s), so no fstype policy applies", err)
+		return nil
+	}
+	policy, matched := policyFor(r.opts.FSPolicies, fstype)
+	plan.FSType, plan.Policy = fstype, policy.Policy
+	if !matched {
+		plan.tracef("it is on %s, which no fstype policy matches, so the default applies", fstype)
+		return nil
+	}
+	plan.tracef("it is on %s, and fstype[%s] = %s", fstype, policy.Pattern, policy.Policy)
+
+	switch policy.Policy {
+	case "permanent":
+		if r.opts.SafeMode {
+			plan.tracef("safe mode does not allow permanent deletion")
+			return fmt.Errorf("%s: fstype[%s] = permanent: disabled by safe mode", DisplayPath(plan.Path), policy.Pattern)
+		}
+	case "ask":
+		if r.opts.Interactive {
+			plan.tracef("-i asks anyway")
+		} else if r.opts.SkipsPrompt("fstype") {
+			plan.tracef("-ff doesn't ask")
+		} else {
+			plan.Prompts = append(plan.Prompts, fmt.Sprintf("remove %s from %s?", DisplayPath(plan.Path), fstype))
+		}
+	case "skip":
+		plan.tracef("so it is left alone")
+	}
+	return nil
+}
+
+// Remove handles a single operand, reporting it to OnEntryStart and
+// OnEntryDone
+func (r *Remover) Remove(path string) Result {
+	if r.opts.Callbacks.OnEntryStart != nil {
+		r.opts.Callbacks.OnEntryStart(path)
+	}
+	result := r.remove(path, true)
+	if r.opts.Callbacks.OnEntryDone != nil {
+		r.opts.Callbacks.OnEntryDone(result)
+	}
+	return result
+}
+
+// removeEntry is Remove for an entry inside an operand being filtered by
+// --keep-hidden or --hidden-only, which the filter doesn't apply to again
+func (r *Remover) removeEntry(path string) Result {
+	if r.opts.Callbacks.OnEntryStart != nil {
+		r.opts.Callbacks.OnEntryStart(path)
+	}
+	result := r.remove(path, false)
+	if r.opts.Callbacks.OnEntryDone != nil {
+		r.opts.Callbacks.OnEntryDone(result)
+	}
+	return result
+}
+
+func (r *Remover) remove(path string, filter bool) Result {
+	plan, err := r.Plan(path)
+	return r.removePlanned(path, plan, err, filter)
+}
+
+// removePlanned carries out plan, or reports planErr, for the operand path
+func (r *Remover) removePlanned(path string, plan Plan, planErr error, filter bool) Result {
+	result := Result{Source: path, Note: r.opts.TrashNote, Op: r.opts.Op}
+
+	fail := func(err error) Result {
+		result.Action = "failed"
+		// -f ignores an operand that isn't there, as rm does
+		if IsProtection(err) || (r.opts.Force && IsMissing(err)) {
+			result.Action = "skipped"
+		}
+		result.Trash, result.TrashWhy, result.Volume, result.TrashVolume = "", "", "", ""
+		result.Err = displayErr(err, path)
+		return result
+	}
+
+	result.IsDir = plan.IsDir
+	result.FSType, result.Policy, result.Overlay = plan.FSType, plan.Policy, plan.Overlay
+	if planErr != nil {
+		return fail(planErr)
+	}
+	if plan.Action == "skipped" {
+		result.Action = "skipped"
+		result.Err = fmt.Errorf("%s: %w", DisplayPath(path), ErrSkipped)
+		return result
+	}
+
+	if filter && plan.IsDir && r.opts.OneFileSystem && r.opts.Recursive {
+		if fi, err := r.fs.Lstat(plan.Path); err == nil {
+			if dev, ok := fileDevice(fi); ok {
+				if holders, mounts := r.mountHolders(plan.Path, dev); len(mounts) > 0 {
+					return r.removeOneFS(path, plan, holders, mounts)
+				}
+			}
+		}
+	}
+	if filter && plan.IsDir && (r.opts.HiddenOnly > 0 || (r.opts.KeepHidden > 0 && r.holdsKept(plan.Path, 1, r.opts.KeepHidden))) {
+		return r.removeFiltered(path, plan)
+	}
+	if r.opts.DryRun {
+		return r.dryRun(result, plan)
+	}
+	if plan.InTrash != nil {
+		return r.removeInTrash(result, plan)
+	}
+	if filter && plan.IsDir && r.opts.Interactive && r.opts.Recursive {
+		return r.removeInteractive(path, plan)
+	}
+
+	for _, warning := range plan.Warnings {
+		fmt.Fprintf(os.Stderr, "srm: warning: %s\n", DisplayName(warning))
+	}
+	for _, prompt := range plan.Prompts {
+		if r.Aborted() {
+			result.Action = "skipped"
+			result.Err = fmt.Errorf("%s: %w", DisplayPath(path), ErrAborted)
+			return result
+		}
+		yes, err := r.confirm(path, prompt)
+		if errors.Is(err, ErrAborted) {
+			result.Action = "skipped"
+			result.Err = err
+			return result
+		}
+		if err != nil {
+			return fail(err)
+		}
+		if !yes {
+			result.Action = "skipped"
+			result.Err = fmt.Errorf("%s: %w", DisplayPath(path), ErrDeclined)
+			return result
+		}
+	}
+
+	if plan.Strategy == "backend" {
+		return r.storeInBackend(result, plan)
+	}
+
+	// a file costs one stat to size, for the index row or srm stats
+	path = plan.Path
+	if r.opts.MeasureSize || ((r.opts.Index != nil || plan.Action == "deleted") && !plan.IsDir) {
+		r.runCheck("size", path, func() { result.Bytes, _ = DiskUsage(r.fs, path) })
+	}
+
+	// only copying and overwriting strategies have progress worth reporting
+	var progress func(int64)
+	onProgress := r.opts.Callbacks.OnProgress
+	if onProgress != nil && (plan.Strategy == "archive" || plan.Strategy == "overwrite") {
+		total := result.Bytes
+		if !r.opts.MeasureSize {
+			total, _ = DiskUsage(r.fs, path)
+		}
+		if plan.Strategy == "overwrite" {
+			total *= int64(len(r.opts.Overwrite))
+		}
+		progress = func(done int64) { onProgress(done, total) }
+		// the estimate can be off, so always finish on done == total
+		defer onProgress(total, total)
+	}
+
+	start := time.Now()
+	if plan.ClearReadOnly {
+		if err := r.fs.SetReadOnly(path, false); err != nil {
+			return fail(err)
+		}
+	}
+
+	// a freedesktop.org trash has the info file written ahead of the move
+	release := func() {}
+	if plan.Action == "trashed" {
+		var err error
+		if release, err = r.claimDest(&plan); err != nil {
+			if result, ok := r.replanLost(path, plan, err, filter); ok {
+				return result
+			}
+			return fail(err)
+		}
+	}
+
+	// the intent is synced before the move, so a crash between the move and
+	// the index row leaves something for IntentLog.Replay to finish
+	var entry IndexEntry
+	tracked := plan.Action == "trashed" && r.opts.Index != nil
+	if tracked {
+		entry = r.indexEntry(path, plan)
+		if r.opts.Intents != nil {
+			if err := r.opts.Intents.Begin(entry); err != nil {
+				result.Note = strings.TrimPrefix(result.Note+"; intent log: "+err.Error(), "; ")
+			}
+		}
+	}
+
+	var err error
+	copied := false
+	result.Action, result.Strategy, result.Dest = plan.Action, plan.Strategy, plan.Dest
+	if plan.Dest != "" {
+		result.Trash, result.TrashWhy = plan.Trash, plan.TrashWhy
+		result.Volume, result.TrashVolume = plan.Volume, plan.TrashVolume
+	}
+	switch plan.Strategy {
+	case "remove-all":
+		err = r.fs.RemoveAll(path)
+	case "remove":
+		err = r.fs.Remove(path)
+	case "overwrite":
+		// a file that couldn't be overwritten stops it, and nothing is
+		// deleted that wasn't
+		var notes []string
+		notes, err = scrub(r.fs, path, r.opts.Overwrite, &progressWriter{w: io.Discard, fn: progress})
+		for _, note := range notes {
+			fmt.Fprintf(os.Stderr, "srm: warning: %s\n", DisplayName(note))
+		}
+		if err == nil && r.opts.Recursive {
+			err = r.fs.RemoveAll(path)
+		} else if err == nil {
+			err = r.fs.Remove(path)
+		}
+	case "archive":
+		// the tree is only removed once the tarball is complete and synced
+		result.Bytes, err = archiveTree(r.fs, path, result.Dest, progress)
+		if err != nil {
+			result.Dest = ""
+		} else {
+			err = r.fs.RemoveAll(path)
+		}
+	default:
+		err = r.moveToDest(&plan, nil, &release, func() {
+			result.Dest = plan.Dest
+			if tracked {
+				if err := r.retarget(&entry, plan); err != nil {
+					result.Note = strings.TrimPrefix(result.Note+"; intent log: "+err.Error(), "; ")
+				}
+			}
+		})
+		if errors.Is(err, syscall.EXDEV) {
+			result.Strategy = "copy"
+			result.Bytes, copied, err = r.copyIntoTrash(plan, r.copyProgress(path))
+		}
+		if err != nil && !copied {
+			result.Dest = ""
+		}
+	}
+	result.Duration = time.Since(start)
+
+	if plan.ClearReadOnly {
+		// put the attribute back on whichever copy still exists
+		switch {
+		case err != nil && !copied:
+			r.fs.SetReadOnly(path, true)
+		case result.Strategy == "rename" || result.Strategy == "copy":
+			if err := r.fs.SetReadOnly(result.Dest, true); err != nil {
+				result.Note = strings.TrimPrefix(result.Note+"; read-only: "+err.Error(), "; ")
+			}
+		}
+	}
+
+	if err != nil && !copied {
+		release()
+		if tracked && r.opts.Intents != nil {
+			r.opts.Intents.Done(entry.ID)
+		}
+		if result, ok := r.replanLost(path, plan, err, filter); ok {
+			return result
+		}
+		return fail(err)
+	}
+
+	if tracked {
+		entry.Size = result.Bytes
+		if err := r.opts.Index.Append(entry); err != nil {
+			result.Note = strings.TrimPrefix(result.Note+"; index: "+err.Error(), "; ")
+		} else if r.opts.Intents != nil {
+			r.opts.Intents.Done(entry.ID)
+		}
+	}
+	if err != nil {
+		// the copy is in the trash and indexed, but the operand is still here
+		return fail(err)
+	}
+
+	if result.Action == "trashed" {
+		if !tracked {
+			entry = r.indexEntry(path, plan)
+		}
+		r.remember(path, entry, tracked, result.Dest)
+		if err := recordDirectorySize(r.fs, result.Dest); err != nil {
+			result.Note = strings.TrimPrefix(result.Note+"; directorysizes: "+err.Error(), "; ")
+		}
+	}
+	result.Verify = r.verifyNote(result.Strategy)
+	return result
+}
+
+// replanLost removes path once more, planned afresh, when err is a move
+// into plan's trash failing because that trash turned read-only. ok is
+// false when it didn't; with no other trash usable, the result fails with
+// ErrTrashLost.
+func (r *Remover) replanLost(path string, plan Plan, err error, filter bool) (Result, bool) {
+	if plan.Action != "trashed" || !errors.Is(err, syscall.EROFS) || !r.trashLost(plan.Trash, err) {
+		return Result{}, false
+	}
+	replan, planErr := r.Plan(plan.Path)
+	return r.removePlanned(path, replan, planErr, filter), true
+}
+
+// trashInfoOrigin is the Path of plan's trash info file: where the payload
+// came from, with the tarball's extension for an --archive, since that is
+// what a desktop trash viewer puts back
+func (r *Remover) trashInfoOrigin(plan Plan) string {
+	origin, err := filepath.Abs(plan.Path)
+	if err != nil {
+		origin = plan.Path
+	}
+	if plan.Strategy == "archive" {
+		origin += "." + ARCHIVEFORMAT
+	}
+	return origin
+}
+
+// indexEntry is the index row for trashing path as planned
+func (r *Remover) indexEntry(path string, plan Plan) IndexEntry {
+	origin, err := filepath.Abs(path)
+	if err != nil {
+		origin = path
+	}
+
+	entry := IndexEntry{
+		ID:      NewEntryID(),
+		Trash:   filepath.Dir(plan.Dest),
+		Name:    filepath.Base(plan.Dest),
+		Origin:  origin,
+		Deleted: time.Now(),
+		IsDir:   plan.IsDir,
+		Op:      r.opts.Op,
+	}
+	if plan.Strategy == "archive" {
+		entry.Archive = ARCHIVEFORMAT
+	}
+	if r.opts.Reason != "" {
+		entry.Reason = url.PathEscape(r.opts.Reason)
+	}
+	if !plan.Times.Modified.IsZero() {
+		times := plan.Times
+		entry.Times = &times
+	}
+	return entry
+}
+
+// RemoveAll runs Remove over every path after the -I batch question and
+// returns every Result along with the joined errors of the ones that failed.
+// Skips and operands inside another operand are reported in their Result
+// but are not failures, see STATUSES.
+func (r *Remover) RemoveAll(paths []string) ([]Result, error) {
+	results := []Result{}
+
+	if !r.ConfirmBatch(paths) {
+		for _, path := range paths {
+			results = append(results, Result{
+				Action: "skipped",
+				Source: path,
+				Err:    fmt.Errorf("%s: %w", DisplayPath(path), ErrDeclined),
+			})
+		}
+		return results, nil
+	}
+
+	errs := []error{}
+	covers := CoveringOperands(paths, r.opts.Recursive)
+	r.RemoveEach(paths, covers, func(i int, result Result) {
+		results = append(results, result)
+		if result.Status().Info().Fails {
+			errs = append(errs, result.Err)
+		}
+	})
+
+	return results, errors.Join(errs...)
+}
@@ -0,0 +1,317 @@
+package remove
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRemove(t *testing.T) {
+	env := testEnv(t)
+
+	file, err := env.file("file.txt", "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := env.remover(false).Remove(file); result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if err := env.trashed(file, "file.txt", "first"); err != nil {
+		t.Error(err)
+	}
+
+	// a name the trash already has gets the next free one, and the first
+	// is left alone
+	if _, err := env.file("file.txt", "second"); err != nil {
+		t.Fatal(err)
+	}
+	result := env.remover(false).Remove(file)
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if want := filepath.Join(env.trash, "file.txt.1"); result.Dest != want {
+		t.Errorf("a second file.txt went to %s, want %s", result.Dest, want)
+	}
+	if err := env.trashed(file, "file.txt.1", "second"); err != nil {
+		t.Error(err)
+	}
+	if got, err := os.ReadFile(filepath.Join(env.trash, "file.txt")); err != nil || string(got) != "first" {
+		t.Errorf("the first file.txt is now %q, %v", got, err)
+	}
+
+	kept, err := env.file("dir/sub/file.txt", "nested")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(env.work, "dir")
+	if result := env.remover(false).Remove(dir); !errors.Is(result.Err, ErrIsDirectory) {
+		t.Errorf("a directory without -r: %v, want %v", result.Err, ErrIsDirectory)
+	}
+	if _, err := os.Stat(kept); err != nil {
+		t.Errorf("a directory refused without -r went: %v", err)
+	}
+	if result := env.remover(true).Remove(dir); result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if _, err := os.Lstat(dir); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("%s is still there", dir)
+	}
+	if got, err := os.ReadFile(filepath.Join(env.trash, "dir", "sub", "file.txt")); err != nil || string(got) != "nested" {
+		t.Errorf("dir arrived holding %q, %v", got, err)
+	}
+
+	// several from one directory go through removeRun
+	paths := []string{}
+	for _, name := range []string{"logs/a.log", "logs/b.log", "logs/c.log"} {
+		path, err := env.file(name, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	if _, err := env.remover(false).RemoveAll(paths); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		name := filepath.Base(path)
+		if err := env.trashed(path, name, "logs/"+name); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// A symlink is trashed as the link, even to a directory and without -r,
+// and what it points to stays
+func TestRemoveSymlink(t *testing.T) {
+	env := testEnv(t)
+	dir := filepath.Join(env.work, "linked")
+	if err := os.Mkdir(dir, 0555); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(dir, 0755) })
+	for name, target := range map[string]string{"dirlink": dir, "dangling": filepath.Join(env.work, "nowhere")} {
+		link := filepath.Join(env.work, name)
+		if err := os.Symlink(target, link); err != nil {
+			t.Skip("no symlinks here:", err)
+		}
+		result := env.remover(false).Remove(link)
+		if result.Err != nil {
+			t.Fatalf("%s: %v", name, result.Err)
+		}
+		if result.IsDir {
+			t.Errorf("%s was taken for a directory", name)
+		}
+		if fi, err := os.Lstat(result.Dest); err != nil || fi.Mode()&fs.ModeSymlink == 0 {
+			t.Errorf("%s arrived as something other than the link: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("the linked directory went too: %v", err)
+	}
+}
+
+// --permanent asks unless -f, and deletes rather than trashing
+func TestRemovePermanent(t *testing.T) {
+	env := testEnv(t)
+	path, err := env.file("gone.txt", "gone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := env.options(false)
+	opts.TrashDir, opts.Permanent, opts.Delete = "", true, true
+	plan, err := env.removerWith(opts).Plan(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Prompts) != 1 || !strings.HasPrefix(plan.Prompts[0], "permanently remove ") {
+		t.Errorf("asked %q, want the permanent question", plan.Prompts)
+	}
+
+	opts.Force, opts.ForceLevel = true, 1
+	result := env.removerWith(opts).Remove(path)
+	if result.Err != nil || result.Status() != StatusDeleted {
+		t.Fatalf("%s, %v", result.Status(), result.Err)
+	}
+	if _, err := os.Lstat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("%s is still there", path)
+	}
+	if _, err := os.Lstat(filepath.Join(env.trash, "gone.txt")); err == nil {
+		t.Error("gone.txt went to the trash")
+	}
+}
+
+// -rPP writes over every regular file before deleting it, but not over
+// what a symlink points to or a file with another hard link
+func TestRemoveOverwrite(t *testing.T) {
+	env := testEnv(t)
+	secret, err := env.file("shred/secret", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, err := env.file("shred-target", "kept")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, filepath.Join(env.work, "shred", "link")); err != nil {
+		t.Skip("no symlinks here:", err)
+	}
+	shared, err := env.file("shred/shared", "shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(shared, filepath.Join(env.work, "shred-shared")); err != nil {
+		t.Skip("no hard links here:", err)
+	}
+	// held open, so what was written over it can be looked at once it is
+	// gone
+	held, err := os.Open(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Close()
+
+	opts := env.options(true)
+	opts.TrashDir, opts.Permanent, opts.Delete = "", true, true
+	opts.Force, opts.ForceLevel = true, 1
+	opts.Overwrite = []string{SCRUBRANDOM, SCRUBZEROS}
+	reported := []int64{}
+	opts.Callbacks.OnProgress = func(done, total int64) { reported = append(reported, done) }
+	result := env.removerWith(opts).Remove(filepath.Join(env.work, "shred"))
+	if result.Err != nil || result.Strategy != "overwrite" {
+		t.Fatalf("%s by %s, %v", result.Status(), result.Strategy, result.Err)
+	}
+	if !slices.Contains(reported, 2*int64(len("secret"))) {
+		t.Errorf("progress %v, want two passes over secret", reported)
+	}
+	if fi, err := held.Stat(); err != nil || fi.Size() != 0 {
+		t.Errorf("secret wasn't truncated: %v", err)
+	}
+	for path, content := range map[string]string{target: "kept", filepath.Join(env.work, "shred-shared"): "shared"} {
+		if got, err := os.ReadFile(path); err != nil || string(got) != content {
+			t.Errorf("%s is now %q, %v", path, got, err)
+		}
+	}
+}
+
+// A rename across devices falls back on a copy that keeps times and
+// modes, and with --verify one that can't be read back leaves the
+// original
+func TestRemoveCopy(t *testing.T) {
+	env := testEnv(t)
+	path, err := env.file("xdev/sub/file.txt", "xdev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(env.work, "xdev")
+	mtime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Dir(path), 0750); err != nil {
+		t.Fatal(err)
+	}
+	env.faults.Inject("rename", dir, syscall.EXDEV)
+	result := env.remover(true).Remove(dir)
+	env.faults.Clear()
+	if result.Err != nil || result.Strategy != "copy" {
+		t.Fatalf("%s by %s, %v", result.Status(), result.Strategy, result.Err)
+	}
+	if err := env.indexed("xdev"); err != nil {
+		t.Error(err)
+	}
+	copied := filepath.Join(env.trash, "xdev", "sub", "file.txt")
+	if got, err := os.ReadFile(copied); err != nil || string(got) != "xdev" {
+		t.Errorf("the copy holds %q, %v", got, err)
+	}
+	if fi, err := os.Stat(copied); err != nil || !fi.ModTime().Equal(mtime) {
+		t.Errorf("the copy's mtime isn't %v: %v", mtime, err)
+	}
+	if fi, err := os.Stat(filepath.Dir(copied)); err != nil || fi.Mode().Perm() != 0750 {
+		t.Errorf("the copy's directory isn't 0750: %v", err)
+	}
+
+	verified, err := env.file("verify.txt", "verify me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := env.options(false)
+	opts.Verify = true
+	r := env.removerWith(opts)
+	env.faults.Inject("rename", verified, syscall.EXDEV)
+	name, err := UniqueTrashName(env.faults, env.trash, "verify.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.faults.Inject("open", filepath.Join(env.trash, name+".partial"), syscall.EIO)
+	if result := r.Remove(verified); result.Err == nil {
+		t.Error("removed although the copy couldn't be read back")
+	}
+	if _, err := os.Lstat(verified); err != nil {
+		t.Errorf("the original is gone: %v", err)
+	}
+
+	env.faults.Clear()
+	env.faults.Inject("rename", verified, syscall.EXDEV)
+	result = r.Remove(verified)
+	if result.Err != nil || result.Verify != "verified" {
+		t.Fatalf("verify %q, %v", result.Verify, result.Err)
+	}
+	if err := env.trashed(verified, filepath.Base(result.Dest), "verify me"); err != nil {
+		t.Error(err)
+	}
+}
+
+// -r -i asks about descending into each directory, and leaves what it
+// wasn't let into; a directory emptied has its entries trashed one by one
+func TestRemoveInteractiveWalk(t *testing.T) {
+	env := testEnv(t)
+	for _, name := range []string{"walk/keep/inner.txt", "walk/take/inner.txt", "walk/top.txt"} {
+		if _, err := env.file(name, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dir := filepath.Join(env.work, "walk")
+	want := []string{
+		"descend into " + dir + "?",
+		"descend into " + dir + "/keep?",
+		"descend into " + dir + "/take?",
+		"remove " + dir + "/take/inner.txt?",
+		"remove " + dir + "/take?",
+		"remove " + dir + "/top.txt?",
+	}
+	asked := []string{}
+	opts := env.options(true)
+	opts.Interactive = true
+	opts.Callbacks.OnPrompt = func(req PromptRequest) (string, error) {
+		asked = append(asked, req.Message)
+		if req.Message == "descend into "+dir+"/keep?" {
+			return "n", nil
+		}
+		return "y", nil
+	}
+	result := env.removerWith(opts).Remove(dir)
+	if !slices.Equal(asked, want) {
+		t.Errorf("asked %q, want %q", asked, want)
+	}
+	if result.Status() != StatusSkippedPrompt {
+		t.Errorf("%s was %s, want %s", dir, result.Status(), StatusSkippedPrompt)
+	}
+	if err := env.trashed(filepath.Join(dir, "top.txt"), "top.txt", "walk/top.txt"); err != nil {
+		t.Error(err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "take")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("%s/take is still there", dir)
+	}
+	if err := env.indexed("take"); err != nil {
+		t.Error(err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "keep", "inner.txt")); err != nil {
+		t.Errorf("what wasn't descended into went: %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package remove
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// RestoreTarget is one -W operand found in the trash
+type RestoreTarget struct {
+	Entry IndexEntry
+	// Known is whether the index has the entry, and so its origin
+	Known bool
+	// Generations is how many entries the operand matched, the newest
+	// being restored
+	Generations int
+}
+
+// RestoreEntry moves target's payload back to dest, creating whatever
+// parents are missing. An --archive tarball is unpacked into a staging
+// directory next to dest and renamed into place once complete, and a
+// payload on another filesystem than dest is copied back with copyTree.
+// dest then gets back the access and modification times the entry
+// recorded, which only the kernel can set for the other two.
+func RestoreEntry(fsys FS, target RestoreTarget, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	payload := target.Entry.Payload()
+	if target.Entry.Archive != "" {
+		if err := unpackArchive(fsys, payload, dest); err != nil {
+			return err
+		}
+		if err := fsys.Remove(payload); err != nil {
+			return err
+		}
+	} else if err := MoveBack(fsys, payload, dest); err != nil {
+		return err
+	}
+	if err := restoreTimes(fsys, dest, target.Entry.Times); err != nil {
+		fmt.Fprintf(os.Stderr, "srm: warning: timestamps: %s\n", err)
+	}
+	return nil
+}
+
+// restoreTimes sets path's access and modification times to those of
+// times, leaving a symlink alone since Chtimes would set its target's. An
+// access time that wasn't recorded is left as it is.
+func restoreTimes(fsys FS, path string, times *FileTimes) error {
+	if times == nil || times.Modified.IsZero() {
+		return nil
+	}
+	fi, err := fsys.Lstat(path)
+	if err != nil || fi.Mode()&fs.ModeSymlink != 0 {
+		return err
+	}
+	return fsys.Chtimes(path, times.Accessed, times.Modified)
+}
+
+// MoveBack renames src to dest, or copies it there with copyTree and
+// removes it when they are on different filesystems, failing rather than
+// replacing anything at dest
+func MoveBack(fsys FS, src string, dest string) error {
+	err := fsys.RenameNoReplace(src, dest)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	if _, err := copyTree(fsys, src, dest, nil, false); err != nil {
+		return err
+	}
+	return fsys.RemoveAll(src)
+}
+
+// unpackArchive unpacks a tarball written by archiveTree to dest. Its
+// members all sit under the directory's name at the time, which dest
+// replaces; directories get their recorded modes once everything is in.
+func unpackArchive(fsys FS, tarball string, dest string) error {
+	f, err := fsys.Open(tarball)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	staging, err := os.MkdirTemp(filepath.Dir(dest), ".srm-restore-")
+	if err != nil {
+		return err
+	}
+	defer fsys.RemoveAll(staging)
+	root := filepath.Join(staging, "root")
+
+	// directories get their times last too, once nothing more is put in
+	// them
+	dirModes := map[string]fs.FileMode{}
+	dirTimes := map[string]time.Time{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		// the first element is the directory's own name, which root
+		// stands in for
+		name := path.Clean(hdr.Name)
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return fmt.Errorf("%s: %s: %w", DisplayPath(tarball), hdr.Name, ErrUnsafeMember)
+		}
+		_, rel, _ := strings.Cut(name, "/")
+		rel = path.Join("root", rel)
+		if hdr.Typeflag == tar.TypeDir {
+			dirModes[filepath.Join(staging, filepath.FromSlash(rel))] = TarMode(hdr)
+			dirTimes[filepath.Join(staging, filepath.FromSlash(rel))] = hdr.ModTime
+		}
+		if err := ExtractMember(fsys, tr, hdr, staging, rel); err != nil {
+			return fmt.Errorf("%s: %w", DisplayPath(tarball), err)
+		}
+	}
+
+	if err := RestoreDirModes(fsys, staging, dirModes); err != nil {
+		return err
+	}
+	for dir, mtime := range dirTimes {
+		if err := fsys.Chtimes(dir, mtime, mtime); err != nil {
+			return err
+		}
+	}
+	return fsys.RenameNoReplace(root, dest)
+}
@@ -0,0 +1,130 @@
+package remove
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// An archived entry crafted to write through a symlink it carries is
+// refused, leaving nothing outside the restore destination
+func TestUnpackArchiveUnsafe(t *testing.T) {
+	dir := t.TempDir()
+	victim := filepath.Join(dir, "victim")
+	if err := os.Mkdir(victim, 0700); err != nil {
+		t.Fatal(err)
+	}
+	tarball := filepath.Join(dir, "d.tar.gz")
+	f, err := os.Create(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	writeMembers(t, tar.NewWriter(gz), []member{
+		{name: "d/", typ: tar.TypeDir},
+		{name: "d/x", typ: tar.TypeSymlink, link: victim},
+		{name: "d/x/owned", typ: tar.TypeReg, body: "x"},
+	})
+	gz.Close()
+	f.Close()
+
+	dest := filepath.Join(dir, "restored")
+	if err := unpackArchive(OSFS{}, tarball, dest); !errors.Is(err, ErrUnsafeMember) {
+		t.Errorf("got %v, want ErrUnsafeMember", err)
+	}
+	if _, err := os.Lstat(filepath.Join(victim, "owned")); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("wrote through the archived symlink")
+	}
+	if _, err := os.Lstat(dest); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("a refused archive was restored")
+	}
+}
+
+// An archive of a directory, as archiveTree writes it, comes back whole
+func TestUnpackArchive(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "d")
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "sub", "f"), []byte("contents"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("sub/f", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+	tarball := filepath.Join(dir, "d.tar.gz")
+	if _, err := archiveTree(OSFS{}, src, tarball, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := filepath.Join(dir, "restored")
+	if err := unpackArchive(OSFS{}, tarball, dest); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(filepath.Join(dest, "link")); err != nil || string(got) != "contents" {
+		t.Errorf("restored link reads %q, %v", got, err)
+	}
+}
+
+// A restored entry gets back the times it was trashed with, and an
+// archived one its directories' modification times
+func TestRestoreEntryTimes(t *testing.T) {
+	dir := t.TempDir()
+	mtime, atime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC), time.Date(2002, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	trash := filepath.Join(dir, "trash")
+	if err := os.Mkdir(trash, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(trash, "f"), []byte("f"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	entry := IndexEntry{Trash: trash, Name: "f", Times: &FileTimes{Modified: mtime, Accessed: atime}}
+	dest := filepath.Join(dir, "f")
+	if err := RestoreEntry(OSFS{}, RestoreTarget{Entry: entry, Known: true}, dest); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.ModTime().Equal(mtime) || !fileTimes(dest, fi).Accessed.Equal(atime) {
+		t.Errorf("restored with mtime %v, atime %v", fi.ModTime(), fileTimes(dest, fi).Accessed)
+	}
+
+	src := filepath.Join(dir, "d")
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "sub", "f"), []byte("f"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{filepath.Join(src, "sub"), src} {
+		if err := os.Chtimes(d, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := archiveTree(OSFS{}, src, filepath.Join(trash, "d.tar.gz"), nil); err != nil {
+		t.Fatal(err)
+	}
+	entry = IndexEntry{Trash: trash, Name: "d.tar.gz", Archive: ARCHIVEFORMAT, IsDir: true}
+	dest = filepath.Join(dir, "restored")
+	if err := RestoreEntry(OSFS{}, RestoreTarget{Entry: entry, Known: true}, dest); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{dest, filepath.Join(dest, "sub")} {
+		fi, err := os.Stat(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !fi.ModTime().Equal(mtime) {
+			t.Errorf("%s restored with mtime %v, want %v", d, fi.ModTime(), mtime)
+		}
+	}
+}
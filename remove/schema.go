@@ -0,0 +1,24 @@
+package remove
+
+// SCHEMAVERSION is the version of the records srm writes for itself and for
+// other tools: index rows and journal records carry it as "v", and
+// --format=json entries as Schema. Tools can rely on it as follows:
+//
+//   - adding a field doesn't change it, readers ignore fields they don't know
+//   - renaming or removing a field, or changing what one holds, bumps it, and
+//     upgrade learns to turn rows of the old version into the new shape, so
+//     an existing trash never needs converting
+//
+// Rows from before it existed have no "v" and are version 0, which is
+// version 1 without the field. A row from a newer srm is read for the fields
+// this one knows.
+const SCHEMAVERSION = 1
+
+// upgrade brings an index row read from disk up to SCHEMAVERSION
+func (e *IndexEntry) upgrade() {
+	// version 0 is version 1 without the field, so nothing changes yet; a
+	// bump adds a step here for each version before it
+	if e.Schema < SCHEMAVERSION {
+		e.Schema = SCHEMAVERSION
+	}
+}
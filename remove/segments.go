@@ -0,0 +1,347 @@
+package remove
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The index is the file index and the segments in index.d next to it. Each
+// srm appends its rows to a segment of its own, named for when it was
+// started, so srms running side by side, like xargs -P, never write the same
+// file, and nothing they write is lost to a compaction running meanwhile.
+// Segments are read after the index in name order, which is the order
+// their rows were written in: a segment is only appended to while it is
+// the newest, and an srm that finds a newer one starts another. Fold moves
+// the rows of all but the newest into the index.
+
+// INDEXSEGMENTS is how many segments an Append that starts another one
+// lets there be before it folds them, so `find -exec srm {} \;` doesn't
+// leave a segment per file for every reader to open
+const INDEXSEGMENTS = 64
+
+// FOLDSTALE is how old index.fold, or another lockFile, is before it is
+// taken as left behind by an srm that died holding it
+const FOLDSTALE = 10 * time.Minute
+
+// ErrIndexBusy is Compact finding another srm folding the index
+var ErrIndexBusy = errors.New("another srm is folding the index")
+
+// FOLDING is the suffix a segment is renamed to while it is folded, so
+// nothing starts appending to it again
+const FOLDING = ".folding"
+
+func (ix *Index) segmentDir() string {
+	return ix.path + ".d"
+}
+
+// segments returns the names in index.d that are segments, in the order
+// they are read: by name, one being folded ahead of one of the same name
+func (ix *Index) segments() ([]string, error) {
+	des, err := os.ReadDir(ix.segmentDir())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, de := range des {
+		if _, ok := segmentTime(de.Name()); ok {
+			names = append(names, de.Name())
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := strings.TrimSuffix(names[i], FOLDING), strings.TrimSuffix(names[j], FOLDING)
+		if a != b {
+			return a < b
+		}
+		return strings.HasSuffix(names[i], FOLDING)
+	})
+	return names, nil
+}
+
+// segmentTime is the time, in nanoseconds, a segment named name was
+// started; ok is false for anything else in index.d
+func segmentTime(name string) (int64, bool) {
+	stamp, pid, ok := strings.Cut(strings.TrimSuffix(name, FOLDING), "-")
+	if !ok || len(stamp) != 20 {
+		return 0, false
+	}
+	if _, err := strconv.Atoi(pid); err != nil {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(stamp, 10, 64)
+	return n, err == nil
+}
+
+// files is the index and its segments, in the order they are read
+func (ix *Index) files() ([]string, error) {
+	names, err := ix.segments()
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{ix.path}
+	for _, name := range names {
+		paths = append(paths, filepath.Join(ix.segmentDir(), name))
+	}
+	return paths, nil
+}
+
+// Size is how much the index and its segments take up
+func (ix *Index) Size() int64 {
+	paths, _ := ix.files()
+	var total int64
+	for _, path := range paths {
+		if fi, err := os.Stat(path); err == nil {
+			total += fi.Size()
+		}
+	}
+	return total
+}
+
+// segmentPath is the segment Append writes to: this srm's own while it is
+// the newest, otherwise a new one named to sort after every other. many is
+// set when starting one found INDEXSEGMENTS already there.
+func (ix *Index) segmentPath() (path string, many bool, err error) {
+	if err := MkdirPrivate(ix.segmentDir()); err != nil {
+		return "", false, err
+	}
+	names, err := ix.segments()
+	if err != nil {
+		return "", false, err
+	}
+	newest := ""
+	if len(names) > 0 {
+		newest = strings.TrimSuffix(names[len(names)-1], FOLDING)
+	}
+	if ix.segment == "" || ix.segment != newest {
+		start := time.Now().UnixNano()
+		if last, ok := segmentTime(newest); ok && last >= start {
+			start = last + 1
+		}
+		ix.segment = fmt.Sprintf("%020d-%d", start, os.Getpid())
+		many = len(names) >= INDEXSEGMENTS
+	}
+	return filepath.Join(ix.segmentDir(), ix.segment), many, nil
+}
+
+// rowFiles are the index and its segments opened for reading, each as far
+// as it held whole rows when opened, read as one stream: offsets into it are
+// what Scan hands out and IndexReader.At takes. Only the newest segment
+// grows, so an offset stays good until a fold or compaction.
+type rowFiles struct {
+	files []*os.File
+	sizes []int64
+}
+
+// errSegmentGone is a segment folded away between listing and opening it
+var errSegmentGone = errors.New("segment folded meanwhile")
+
+// openRows opens the index and its segments, listing them again should one
+// be folded away meanwhile, its rows then being in the index past where
+// this opened it
+func (ix *Index) openRows() (*rowFiles, error) {
+	for try := 0; ; try++ {
+		paths, err := ix.files()
+		if err != nil {
+			return nil, err
+		}
+		rf, err := openRowFiles(paths)
+		if !errors.Is(err, errSegmentGone) || try == 4 {
+			return rf, err
+		}
+	}
+}
+
+// openRowFiles opens paths, the first of them the index, which may not be
+// there yet; a segment not there is looked for as being folded
+func openRowFiles(paths []string) (*rowFiles, error) {
+	rf := &rowFiles{}
+	for i, path := range paths {
+		f, err := os.Open(path)
+		if errors.Is(err, fs.ErrNotExist) && i > 0 && !strings.HasSuffix(path, FOLDING) {
+			f, err = os.Open(path + FOLDING)
+		}
+		if errors.Is(err, fs.ErrNotExist) && i == 0 {
+			continue
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			rf.Close()
+			return nil, errSegmentGone
+		}
+		if err != nil {
+			rf.Close()
+			return nil, err
+		}
+		size, err := wholeRows(f)
+		if err != nil {
+			f.Close()
+			rf.Close()
+			return nil, err
+		}
+		rf.files = append(rf.files, f)
+		rf.sizes = append(rf.sizes, size)
+	}
+	return rf, nil
+}
+
+// wholeRows is how much of f is whole rows, leaving out a last one without
+// its newline, which is still being written or was cut short
+func wholeRows(f *os.File) (int64, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 4096)
+	for end := fi.Size(); end > 0; {
+		start := max(end-int64(len(buf)), 0)
+		n, err := f.ReadAt(buf[:end-start], start)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			return start + int64(i) + 1, nil
+		}
+		end = start
+	}
+	return 0, nil
+}
+
+// reader reads every file from the start, one after the other
+func (rf *rowFiles) reader() io.Reader {
+	readers := []io.Reader{}
+	for i, f := range rf.files {
+		readers = append(readers, io.NewSectionReader(f, 0, rf.sizes[i]))
+	}
+	return io.MultiReader(readers...)
+}
+
+// section is what of the stream is left from offset, up to the end of the
+// file it falls in
+func (rf *rowFiles) section(offset int64) (*io.SectionReader, error) {
+	for i, f := range rf.files {
+		if offset < rf.sizes[i] {
+			return io.NewSectionReader(f, offset, rf.sizes[i]-offset), nil
+		}
+		offset -= rf.sizes[i]
+	}
+	return nil, io.EOF
+}
+
+func (rf *rowFiles) Close() error {
+	for _, f := range rf.files {
+		f.Close()
+	}
+	return nil
+}
+
+// lockFold takes index.fold, which Fold, Compact and recover hold while
+// they write the index itself; ok is false while another srm holds it
+func (ix *Index) lockFold() (unlock func(), ok bool, err error) {
+	return lockFile(ix.path + ".fold")
+}
+
+// lockFile creates path for as long as the caller rewrites what it guards,
+// ok being false while another srm has it. One older than FOLDSTALE was
+// left by an srm that died holding it, and is taken over.
+func lockFile(path string) (unlock func(), ok bool, err error) {
+	for try := 0; try < 2; try++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() { os.Remove(path) }, true, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, false, err
+		}
+		fi, err := os.Stat(path)
+		if err != nil || time.Since(fi.ModTime()) < FOLDSTALE {
+			return nil, false, nil
+		}
+		os.Remove(path)
+	}
+	return nil, false, nil
+}
+
+// Fold appends the rows of every segment but the newest, which may still
+// be being written, to the index and removes them, returning how many it
+// folded. It does nothing while another srm is folding.
+func (ix *Index) Fold() (int, error) {
+	unlock, ok, err := ix.lockFold()
+	if !ok {
+		return 0, err
+	}
+	defer unlock()
+	return ix.fold()
+}
+
+// fold is Fold with index.fold held
+func (ix *Index) fold() (int, error) {
+	names, err := ix.segments()
+	if err != nil || len(names) < 2 {
+		return 0, err
+	}
+	folded := 0
+	for _, name := range names[:len(names)-1] {
+		path := filepath.Join(ix.segmentDir(), name)
+		if !strings.HasSuffix(name, FOLDING) {
+			if err := os.Rename(path, path+FOLDING); err != nil {
+				return folded, err
+			}
+			path += FOLDING
+		}
+		if err := ix.appendSegment(path); err != nil {
+			return folded, err
+		}
+		if err := os.Remove(path); err != nil {
+			return folded, err
+		}
+		folded++
+	}
+	return folded, nil
+}
+
+// appendSegment appends the whole rows of the segment at path to the
+// index, and then whatever an srm that opened it just before it was renamed
+// went on to write
+func (ix *Index) appendSegment(path string) error {
+	seg, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer seg.Close()
+	out, err := OpenPrivate(ix.path, os.O_RDWR|os.O_APPEND|os.O_CREATE)
+	if err != nil {
+		return err
+	}
+	if err := endTornRow(out); err != nil {
+		out.Close()
+		return err
+	}
+	var done int64
+	for {
+		size, err := wholeRows(seg)
+		if err != nil || size <= done {
+			if err == nil {
+				err = out.Sync()
+			}
+			out.Close()
+			return err
+		}
+		if _, err := io.Copy(out, io.NewSectionReader(seg, done, size-done)); err != nil {
+			out.Close()
+			return err
+		}
+		done = size
+	}
+}
@@ -0,0 +1,156 @@
+package remove
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// sessionMove is one operand an -i session trashed, kept so that u and a
+// can put it back
+type sessionMove struct {
+	source string
+	entry  IndexEntry
+	// indexed is whether entry has an index row to forget
+	indexed bool
+	// report is where RemoveEach reported the operand's Result, nil for
+	// an entry inside an operand or one Remove was called for directly
+	report func(Result)
+}
+
+// UNDOANSWERS and ABORTANSWERS are what -i takes besides yes and no: u puts
+// back the operand trashed last and asks about it again, a puts back every
+// one trashed so far and stops. Answers are read lowercased, so A is a.
+var (
+	UNDOANSWERS  = []string{"u", "undo"}
+	ABORTANSWERS = []string{"a", "abort"}
+)
+
+// confirm asks prompt about path and reports whether the answer was yes.
+// Under -i it also takes UNDOANSWERS, asking prompt again once the last
+// operand is put back and asked about, and ABORTANSWERS, which ends the
+// session with ErrAborted; see Abort.
+func (r *Remover) confirm(path string, prompt string) (bool, error) {
+	for {
+		answer, err := r.opts.Callbacks.OnPrompt(PromptRequest{Kind: "confirm", Path: path, Message: prompt})
+		if err != nil {
+			return false, err
+		}
+		if r.opts.POSIX {
+			return posixYes(answer), nil
+		}
+		if r.opts.Interactive && In(answer, UNDOANSWERS) {
+			r.undoLast()
+			continue
+		}
+		if r.opts.Interactive && In(answer, ABORTANSWERS) {
+			r.Abort()
+			return false, fmt.Errorf("%s: %w", DisplayPath(path), ErrAborted)
+		}
+		return In(answer, YESANSWERS), nil
+	}
+}
+
+// remember records a move of the session for undoLast and PutBackSession
+func (r *Remover) remember(source string, entry IndexEntry, indexed bool, dest string) {
+	if !r.opts.Interactive {
+		return
+	}
+	entry.Trash, entry.Name = filepath.Dir(dest), filepath.Base(dest)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	move := sessionMove{source: source, entry: entry, indexed: indexed}
+	if source == r.operand.path {
+		move.report = r.operand.report
+	}
+	r.session = append(r.session, move)
+}
+
+// undoLast puts back the operand trashed last and asks about it again,
+// reporting its new Result wherever the first went. One that can't be put
+// back stays in the session, in the trash.
+func (r *Remover) undoLast() {
+	r.mu.Lock()
+	if len(r.session) == 0 {
+		r.mu.Unlock()
+		fmt.Fprintln(os.Stderr, "srm: nothing trashed yet to undo")
+		return
+	}
+	move := r.session[len(r.session)-1]
+	r.session = r.session[:len(r.session)-1]
+	r.mu.Unlock()
+
+	if err := r.putBack(move); err != nil {
+		fmt.Fprintf(os.Stderr, "srm: undo: %s\n", DisplayName(err.Error()))
+		r.mu.Lock()
+		r.session = append(r.session, move)
+		r.mu.Unlock()
+		return
+	}
+	fmt.Printf("restored %s\n", DisplayName(DisplayPath(move.source)))
+	result := r.Remove(move.source)
+	if move.report != nil {
+		move.report(result)
+	}
+}
+
+// Abort ends an -i session: every operand after it is skipped without
+// asking, and PutBackSession puts back what it trashed
+func (r *Remover) Abort() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.aborted = true
+}
+
+// Aborted reports whether the session was aborted
+func (r *Remover) Aborted() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.aborted
+}
+
+// PutBackSession puts back every operand the session trashed, the last
+// first, returning how many went back and the error of each that didn't;
+// those are still in the trash where the error says
+func (r *Remover) PutBackSession() (int, []error) {
+	r.mu.Lock()
+	session := r.session
+	r.session = nil
+	r.mu.Unlock()
+
+	restored, errs := 0, []error{}
+	for i := len(session) - 1; i >= 0; i-- {
+		if err := r.putBack(session[i]); err != nil {
+			errs = append(errs, fmt.Errorf("%w (still at %s)", err, session[i].entry.Payload()))
+			continue
+		}
+		restored++
+	}
+	return restored, errs
+}
+
+// putBack moves a trashed operand back where it came from, as srm -W
+// would, refusing to put it over whatever took its place meanwhile. The
+// put back goes to OnEntryDone as a restored Result, from the payload to
+// the origin as srm -W journals it.
+func (r *Remover) putBack(move sessionMove) error {
+	if _, err := r.fs.Lstat(move.entry.Origin); err == nil {
+		return fmt.Errorf("%s: %w", DisplayPath(move.source), ErrOriginTaken)
+	}
+	target := RestoreTarget{Entry: move.entry, Known: true, Generations: 1}
+	if err := RestoreEntry(r.fs, target, move.entry.Origin); err != nil {
+		return err
+	}
+	if err := RemoveTrashInfo(move.entry.Payload()); err != nil {
+		fmt.Fprintf(os.Stderr, "srm: warning: trash info: %s\n", err)
+	}
+	if move.indexed {
+		if err := r.opts.Index.Forget(move.entry); err != nil {
+			fmt.Fprintf(os.Stderr, "srm: warning: index: %s\n", err)
+		}
+	}
+	if r.opts.Callbacks.OnEntryDone != nil {
+		r.opts.Callbacks.OnEntryDone(Result{Action: "restored", Source: move.entry.Payload(), Dest: move.entry.Origin, Bytes: move.entry.Size, IsDir: move.entry.IsDir, Op: r.opts.Op})
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package remove
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+)
+
+// answering answers prompts with answers in turn, failing once they run out
+func answering(answers ...string) func(PromptRequest) (string, error) {
+	return func(req PromptRequest) (string, error) {
+		if len(answers) == 0 {
+			return "", fmt.Errorf("unexpected prompt: %s", req.Message)
+		}
+		answer := answers[0]
+		answers = answers[1:]
+		return answer, nil
+	}
+}
+
+// u puts back the operand trashed last and asks about it again, and its
+// new Result is reported to RemoveEach's done like the first; the put back
+// goes to OnEntryDone as restored
+func TestUndoLast(t *testing.T) {
+	env := testEnv(t)
+	a, err := env.file("a", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := env.file("b", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// yes to a, undo it at b and say no to it this time, yes to b
+	opts := env.options(false)
+	opts.Interactive = true
+	opts.Callbacks.OnPrompt = answering("y", "u", "n", "y")
+	done := []Status{}
+	opts.Callbacks.OnEntryDone = func(result Result) { done = append(done, result.Status()) }
+	r := env.removerWith(opts)
+	reported := map[int][]Status{}
+	r.RemoveEach([]string{a, b}, CoveringOperands([]string{a, b}, false), func(i int, result Result) {
+		reported[i] = append(reported[i], result.Status())
+	})
+
+	if want := []Status{StatusTrashed, StatusSkippedPrompt}; !slices.Equal(reported[0], want) {
+		t.Errorf("a was reported %v, want %v", reported[0], want)
+	}
+	if want := []Status{StatusTrashed}; !slices.Equal(reported[1], want) {
+		t.Errorf("b was reported %v, want %v", reported[1], want)
+	}
+	if want := []Status{StatusTrashed, StatusRestored, StatusSkippedPrompt, StatusTrashed}; !slices.Equal(done, want) {
+		t.Errorf("OnEntryDone saw %v, want %v", done, want)
+	}
+	if _, err := os.Lstat(a); err != nil {
+		t.Errorf("a wasn't put back: %v", err)
+	}
+	if err := env.trashed(b, "b", "b"); err != nil {
+		t.Error(err)
+	}
+}
+
+// a skips the rest without asking, and PutBackSession puts back everything
+// the session trashed, each as a restored Result
+func TestAbortSession(t *testing.T) {
+	env := testEnv(t)
+	paths := []string{}
+	for _, name := range []string{"a", "b", "c"} {
+		path, err := env.file(name, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	opts := env.options(false)
+	opts.Interactive = true
+	opts.Callbacks.OnPrompt = answering("y", "y", "a")
+	restored := []string{}
+	opts.Callbacks.OnEntryDone = func(result Result) {
+		if result.Status() == StatusRestored {
+			restored = append(restored, result.Dest)
+		}
+	}
+	r := env.removerWith(opts)
+	statuses := make([]Status, len(paths))
+	r.RemoveEach(paths, CoveringOperands(paths, false), func(i int, result Result) { statuses[i] = result.Status() })
+	if want := []Status{StatusTrashed, StatusTrashed, StatusSkippedPrompt}; !slices.Equal(statuses, want) || !r.Aborted() {
+		t.Fatalf("got %v, aborted %v; want %v, aborted", statuses, r.Aborted(), want)
+	}
+
+	n, errs := r.PutBackSession()
+	if n != 2 || len(errs) > 0 {
+		t.Fatalf("put back %d, %v", n, errs)
+	}
+	if want := []string{paths[1], paths[0]}; !slices.Equal(restored, want) {
+		t.Errorf("restored %v, want %v", restored, want)
+	}
+	for _, path := range paths {
+		if _, err := os.Lstat(path); err != nil {
+			t.Errorf("not put back: %v", err)
+		}
+	}
+	if entries, err := env.index.Entries(); err != nil || len(entries) != 0 {
+		t.Errorf("index still has %v, %v", entries, err)
+	}
+}
@@ -0,0 +1,242 @@
+package remove
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/shanahanjrs/srm/trashquery"
+)
+
+// SIZECACHE remembers the size of big directories between runs, so the -I
+// preview, srm du and size ordering don't walk node_modules every time.
+// It is nil with --no-size-cache, which walks everything.
+var SIZECACHE *SizeCache
+
+// a directory needs SIZECACHEMIN entries before its size is worth caching,
+// and the cache keeps the SIZECACHEMAX most recently used directories
+// unless size_cache_max says otherwise
+var (
+	SIZECACHEMIN = 1000
+	SIZECACHEMAX = 2048
+)
+
+// sizeKey is one version of a directory: the same device and inode, and
+// the same mtime, which changes whenever an entry is added, removed or
+// renamed directly inside it. Changes further down and files growing in
+// place leave it alone, which is how stale a cached size can get.
+type sizeKey struct {
+	Dev   uint64 `json:"dev"`
+	Inode uint64 `json:"ino"`
+	Mtime int64  `json:"mtime"`
+}
+
+// sizeRow is one line of the cache file
+type sizeRow struct {
+	sizeKey
+	Bytes int64 `json:"bytes"`
+	// Files counts every entry, the directory itself included
+	Files int `json:"files"`
+}
+
+// SizeCache is the append-only file of sizeRow lines in srm's data dir.
+// Losing it only costs a walk, so nothing about it is ever reported.
+type SizeCache struct {
+	path string
+	// max is how many directories it keeps, the ones used last
+	max int
+
+	mu     sync.Mutex
+	rows   map[sizeKey]sizeRow
+	loaded bool
+	// line is the line of the file each row was last written at, lines
+	// how many the file has
+	line  map[sizeKey]int
+	lines int
+}
+
+// OpenSizeCache returns the size cache in srm's data dir
+func OpenSizeCache() (*SizeCache, error) {
+	dir, err := DataDir()
+	if err != nil {
+		return nil, err
+	}
+	return &SizeCache{path: filepath.Join(dir, "sizecache"), max: CurrentAuxLimits().SizeCacheMax}, nil
+}
+
+// sizeCacheKey is fi's sizeKey, false where there are no inode numbers
+func sizeCacheKey(fi fs.FileInfo) (sizeKey, bool) {
+	dev, ok := fileDevice(fi)
+	if !ok {
+		return sizeKey{}, false
+	}
+	inode, ok := fileInode(fi)
+	if !ok {
+		return sizeKey{}, false
+	}
+	return sizeKey{Dev: dev, Inode: inode, Mtime: fi.ModTime().UnixNano()}, true
+}
+
+// Lookup returns the size and entry count recorded for the directory fi
+func (c *SizeCache) Lookup(fi fs.FileInfo) (size int64, files int, ok bool) {
+	if c == nil {
+		return 0, 0, false
+	}
+	key, ok := sizeCacheKey(fi)
+	if !ok {
+		return 0, 0, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	row, ok := c.rows[key]
+	// a row used again is written again once it is far enough back in the
+	// file that it could be the next evicted, so what is evicted is what
+	// was used least recently, at a write per max/2 rows
+	if ok && c.lines-c.line[key] > c.max/2 {
+		c.append(row)
+	}
+	return row.Bytes, row.Files, ok
+}
+
+// Store records the size and entry count of the directory fi, once a walk
+// has counted all of it. Directories under SIZECACHEMIN entries are quicker
+// to walk again and aren't kept.
+func (c *SizeCache) Store(fi fs.FileInfo, size int64, files int) {
+	if c == nil || files < SIZECACHEMIN {
+		return
+	}
+	key, ok := sizeCacheKey(fi)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	row := sizeRow{sizeKey: key, Bytes: size, Files: files}
+	if c.rows[key] == row {
+		return
+	}
+	c.rows[key] = row
+	c.append(row)
+}
+
+// append writes row at the end of the file
+func (c *SizeCache) append(row sizeRow) {
+	line, err := json.Marshal(row)
+	if err != nil {
+		return
+	}
+	f, err := OpenPrivate(c.path, os.O_RDWR|os.O_APPEND|os.O_CREATE)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	if endTornRow(f) != nil {
+		return
+	}
+	if _, err := f.Write(append(line, '\n')); err == nil {
+		c.line[row.sizeKey] = c.lines
+		c.lines++
+	}
+}
+
+// load reads the cache file the first time it is needed. Lines that don't
+// parse are skipped, a later line for a directory replaces an earlier one,
+// and once the file holds more than twice max lines it is rewritten with
+// just the newest max.
+func (c *SizeCache) load() {
+	if c.loaded {
+		return
+	}
+	c.loaded = true
+	c.rows = map[sizeKey]sizeRow{}
+	c.line = map[sizeKey]int{}
+	c.lines = 0
+
+	f, err := os.Open(c.path)
+	if err != nil {
+		return
+	}
+	rows := []sizeRow{}
+	scanner := bufio.NewScanner(f)
+	scanner.Split(trashquery.SplitRows)
+	for scanner.Scan() {
+		var row sizeRow
+		if json.Unmarshal(scanner.Bytes(), &row) == nil {
+			rows = append(rows, row)
+		}
+	}
+	f.Close()
+
+	// newest first, so the first row seen for a directory is the one kept
+	kept := []sizeRow{}
+	for i := len(rows) - 1; i >= 0 && len(kept) < c.max; i-- {
+		if _, ok := c.rows[rows[i].sizeKey]; !ok {
+			c.rows[rows[i].sizeKey] = rows[i]
+			c.line[rows[i].sizeKey] = i
+			kept = append(kept, rows[i])
+		}
+	}
+	c.lines = len(rows)
+	if len(rows) > 2*c.max {
+		c.rewrite(kept)
+	}
+}
+
+// Compact rewrites the file with only the rows it keeps, returning how
+// many lines that dropped
+func (c *SizeCache) Compact() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	if c.lines <= len(c.rows) {
+		return 0
+	}
+	kept := []sizeRow{}
+	for _, row := range c.rows {
+		kept = append(kept, row)
+	}
+	// newest first, as rewrite takes them
+	sort.Slice(kept, func(i, j int) bool { return c.line[kept[i].sizeKey] > c.line[kept[j].sizeKey] })
+	dropped := c.lines - len(kept)
+	c.rewrite(kept)
+	return dropped
+}
+
+// rewrite replaces the cache file with rows, given newest first
+func (c *SizeCache) rewrite(rows []sizeRow) {
+	var buf bytes.Buffer
+	for i := len(rows) - 1; i >= 0; i-- {
+		line, err := json.Marshal(rows[i])
+		if err != nil {
+			return
+		}
+		buf.Write(append(line, '\n'))
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".sizecache-")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+	if PrivateMode(tmp) != nil {
+		tmp.Close()
+		return
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return
+	}
+	if tmp.Close() == nil && os.Rename(tmp.Name(), c.path) == nil {
+		for i, row := range rows {
+			c.line[row.sizeKey] = len(rows) - 1 - i
+		}
+		c.lines = len(rows)
+	}
+}
@@ -0,0 +1,47 @@
+package remove
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// The size cache keeps the directories used last, up to its max, and
+// compacts to no more lines than that
+func TestSizeCacheKeepsLastUsed(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "sizecache")
+	cache := &SizeCache{path: path, max: 4}
+	infos := []fs.FileInfo{}
+	for i := 0; i < 10; i++ {
+		dir := filepath.Join(root, "sized-"+strconv.Itoa(i))
+		if err := os.Mkdir(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		fi, err := os.Stat(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		infos = append(infos, fi)
+		cache.Store(fi, int64(i), SIZECACHEMIN)
+		cache.Lookup(infos[0])
+	}
+	if _, ok := sizeCacheKey(infos[0]); !ok {
+		t.Skip("no inode numbers to key directories by")
+	}
+	reopened := &SizeCache{path: path, max: 4}
+	if size, files, ok := reopened.Lookup(infos[0]); !ok || size != 0 || files != SIZECACHEMIN {
+		t.Errorf("the size cache dropped the directory used last: %d, %d, %v", size, files, ok)
+	}
+	if _, _, ok := reopened.Lookup(infos[1]); ok {
+		t.Errorf("the size cache kept a directory past its 4")
+	}
+	if size, _, ok := reopened.Lookup(infos[9]); !ok || size != 9 {
+		t.Errorf("the size cache lost the directory stored last: %d, %v", size, ok)
+	}
+	if reopened.Compact(); reopened.lines > 4 {
+		t.Errorf("compacting left the size cache %d lines", reopened.lines)
+	}
+}
@@ -0,0 +1,105 @@
+package remove
+
+import "errors"
+
+// Status is what became of one entry. -v, -vv, --format, the journal and
+// the exit status all go by it, and Result.Status is the one place that
+// decides it.
+type Status string
+
+const (
+	StatusTrashed Status = "trashed"
+	// StatusDeleted is gone for good, by -D or without a trash, or purged
+	// or evicted from the trash
+	StatusDeleted Status = "deleted"
+	// StatusRestored is a trash entry srm -W put back
+	StatusRestored Status = "restored"
+	// StatusSkippedPrompt is a question answered no
+	StatusSkippedPrompt Status = "skipped-prompt"
+	// StatusSkippedFilter is an entry --keep-hidden, --hidden-only or an
+	// fstype skip policy left where it is
+	StatusSkippedFilter Status = "skipped-filter"
+	// StatusSkippedProtected is an entry srm refused to touch, like a
+	// read-only file without -f
+	StatusSkippedProtected Status = "skipped-protected"
+	// StatusSkippedMissing is an operand that isn't there, which -f ignores
+	StatusSkippedMissing Status = "skipped-missing"
+	// StatusCovered is an operand inside another operand, removed with it
+	StatusCovered Status = "covered"
+	StatusFailed  Status = "failed"
+	// StatusTrashLost is an entry left where it was because the trash
+	// turned read-only during the run and no other was usable
+	StatusTrashLost Status = "trash-lost"
+)
+
+// StatusInfo is how a Status is reported
+type StatusInfo struct {
+	// Verbose is the -v line, with %s for the path; empty prints nothing,
+	// failures being on stderr already
+	Verbose string
+	// Fails makes srm exit 1. Skips never do.
+	Fails bool
+}
+
+// STATUSES is the table of every Status. A status missing from it is a
+// bug, caught by Info.
+var STATUSES = map[Status]StatusInfo{
+	StatusTrashed:          {Verbose: "%s"},
+	StatusDeleted:          {Verbose: "%s"},
+	StatusRestored:         {Verbose: "restored %s"},
+	StatusSkippedPrompt:    {Verbose: "skipped %s (declined)"},
+	StatusSkippedFilter:    {Verbose: "kept %s"},
+	StatusSkippedProtected: {Verbose: "skipped %s (protected)"},
+	StatusSkippedMissing:   {},
+	StatusCovered:          {},
+	StatusFailed:           {Fails: true},
+	StatusTrashLost:        {Fails: true},
+}
+
+// Info looks status up in STATUSES
+func (status Status) Info() StatusInfo {
+	info, ok := STATUSES[status]
+	if !ok {
+		panic("srm: status " + string(status) + " is missing from STATUSES")
+	}
+	return info
+}
+
+// IsProtection reports whether err is srm refusing to touch something,
+// rather than failing to
+func IsProtection(err error) bool {
+	return errors.Is(err, ErrReadOnly) || errors.Is(err, ErrProtectedPath)
+}
+
+// Status says what became of the entry, from its Action and Err
+func (r Result) Status() Status {
+	switch {
+	case errors.Is(r.Err, ErrTrashLost):
+		return StatusTrashLost
+	case r.Action == "failed":
+		return StatusFailed
+	case r.Action == "trashed":
+		return StatusTrashed
+	case In(r.Action, DELETEDACTIONS):
+		return StatusDeleted
+	case r.Action == "restored":
+		return StatusRestored
+	case r.Action == "kept":
+		return StatusSkippedFilter
+	case r.Action == "covered":
+		return StatusCovered
+	case errors.Is(r.Err, ErrDeclined), errors.Is(r.Err, ErrAborted):
+		return StatusSkippedPrompt
+	case errors.Is(r.Err, ErrSkipped):
+		return StatusSkippedFilter
+	case IsProtection(r.Err):
+		return StatusSkippedProtected
+	case r.Action == "skipped" && IsMissing(r.Err):
+		return StatusSkippedMissing
+	}
+	return StatusFailed
+}
+
+// DELETEDACTIONS are the journal actions that removed something for good:
+// -D and no trash, srm purge and srm empty, and the max_entries cap
+var DELETEDACTIONS = []string{"deleted", "purged", "evicted"}
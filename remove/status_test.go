@@ -0,0 +1,125 @@
+package remove
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Every Status is in STATUSES, only failures fail the run, and a -v line
+// has the path in it once
+func TestStatuses(t *testing.T) {
+	all := []Status{
+		StatusTrashed, StatusDeleted, StatusRestored, StatusSkippedPrompt,
+		StatusSkippedFilter, StatusSkippedProtected, StatusSkippedMissing,
+		StatusCovered, StatusFailed, StatusTrashLost,
+	}
+	if len(STATUSES) != len(all) {
+		t.Errorf("STATUSES has %d statuses, want %d", len(STATUSES), len(all))
+	}
+	for _, status := range all {
+		info := status.Info()
+		if want := status == StatusFailed || status == StatusTrashLost; info.Fails != want {
+			t.Errorf("%s fails the run: %v, want %v", status, info.Fails, want)
+		}
+		if info.Verbose != "" && strings.Count(info.Verbose, "%s") != 1 {
+			t.Errorf("%s has the -v line %q", status, info.Verbose)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Info of a status missing from STATUSES didn't panic")
+		}
+	}()
+	Status("mislaid").Info()
+}
+
+func TestResultStatus(t *testing.T) {
+	wrap := func(err error) error { return fmt.Errorf("f: %w", err) }
+	tests := []struct {
+		result Result
+		want   Status
+	}{
+		{Result{Action: "trashed"}, StatusTrashed},
+		{Result{Action: "deleted"}, StatusDeleted},
+		{Result{Action: "purged"}, StatusDeleted},
+		{Result{Action: "evicted"}, StatusDeleted},
+		{Result{Action: "restored"}, StatusRestored},
+		{Result{Action: "kept"}, StatusSkippedFilter},
+		{Result{Action: "covered", Err: wrap(ErrCovered)}, StatusCovered},
+		{Result{Action: "skipped", Err: wrap(ErrDeclined)}, StatusSkippedPrompt},
+		{Result{Action: "skipped", Err: ErrAborted}, StatusSkippedPrompt},
+		{Result{Action: "skipped", Err: wrap(ErrSkipped)}, StatusSkippedFilter},
+		{Result{Action: "skipped", Err: wrap(ErrReadOnly)}, StatusSkippedProtected},
+		{Result{Action: "skipped", Err: wrap(ErrProtectedPath)}, StatusSkippedProtected},
+		{Result{Action: "skipped", Err: wrap(ErrNotFound)}, StatusSkippedMissing},
+		// without -f a missing operand fails, as with rm
+		{Result{Action: "failed", Err: wrap(ErrNotFound)}, StatusFailed},
+		{Result{Action: "failed", Err: wrap(ErrTrashLost)}, StatusTrashLost},
+		{Result{Action: "trashed", Err: wrap(ErrTrashLost)}, StatusTrashLost},
+		{Result{Action: "failed", Err: errors.New("boom")}, StatusFailed},
+		// anything not accounted for is a failure, never a silent success
+		{Result{Action: "skipped", Err: errors.New("boom")}, StatusFailed},
+		{Result{}, StatusFailed},
+	}
+	for _, tt := range tests {
+		if got := tt.result.Status(); got != tt.want {
+			t.Errorf("%s with %v is %s, want %s", tt.result.Action, tt.result.Err, got, tt.want)
+		}
+	}
+}
+
+// The statuses a Remover actually gives back
+func TestRemoveStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup makes the operand and the Options to remove it with
+		setup func(env *scratchEnv, opts *Options) string
+		want  Status
+	}{
+		{"a file", func(env *scratchEnv, opts *Options) string {
+			path, _ := env.file("f", "f")
+			return path
+		}, StatusTrashed},
+		{"a file with -D", func(env *scratchEnv, opts *Options) string {
+			opts.Permanent, opts.Delete, opts.ForceLevel = true, true, 1
+			path, _ := env.file("f", "f")
+			return path
+		}, StatusDeleted},
+		{"a missing operand", func(env *scratchEnv, opts *Options) string {
+			return filepath.Join(env.work, "missing")
+		}, StatusFailed},
+		{"a missing operand with -f", func(env *scratchEnv, opts *Options) string {
+			opts.Force, opts.ForceLevel = true, 1
+			return filepath.Join(env.work, "missing")
+		}, StatusSkippedMissing},
+		{"a directory without -r", func(env *scratchEnv, opts *Options) string {
+			path, _ := env.file("d/f", "f")
+			return filepath.Dir(path)
+		}, StatusFailed},
+		{"a question answered no", func(env *scratchEnv, opts *Options) string {
+			opts.Interactive = true
+			opts.Callbacks.OnPrompt = answering("n")
+			path, _ := env.file("f", "f")
+			return path
+		}, StatusSkippedPrompt},
+		{"a read-only file without -f", func(env *scratchEnv, opts *Options) string {
+			path, _ := env.file("f", "f")
+			os.Chmod(path, 0444)
+			return path
+		}, StatusSkippedProtected},
+	}
+	for _, tt := range tests {
+		env := testEnv(t)
+		opts := env.options(false)
+		path := tt.setup(env, &opts)
+		result := env.removerWith(opts).Remove(path)
+		if got := result.Status(); got != tt.want {
+			t.Errorf("%s: %s (%s, %v), want %s", tt.name, got, result.Action, result.Err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+package remove
+
+import (
+	"os"
+)
+
+// isTerminal reports whether f is a character device, i.e. probably a tty
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
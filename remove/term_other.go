@@ -0,0 +1,24 @@
+//go:build !linux && !darwin
+
+package remove
+
+import (
+	"errors"
+	"os"
+)
+
+func ttySize() (int, int) {
+	return 0, 0
+}
+
+func TTYName() string {
+	return ""
+}
+
+func IsTTY(f *os.File) bool {
+	return isTerminal(f)
+}
+
+func openTerminal() (*os.File, error) {
+	return nil, errors.New("no terminal to open")
+}
@@ -0,0 +1,58 @@
+//go:build linux || darwin
+
+package remove
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+type winsize struct {
+	Row, Col, Xpixel, Ypixel uint16
+}
+
+// getWinsize asks the terminal on f for its size
+func getWinsize(f *os.File) (winsize, syscall.Errno) {
+	var ws winsize
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&ws)))
+	return ws, errno
+}
+
+// ttySize asks the terminal on stdout for its size, 0 when it isn't one
+func ttySize() (int, int) {
+	ws, errno := getWinsize(os.Stdout)
+	if errno != 0 {
+		return 0, 0
+	}
+	return int(ws.Col), int(ws.Row)
+}
+
+// TTYName is the terminal srm was run from, as in /dev/pts/3, "" when
+// none of stdin, stdout and stderr is one or, like on macOS, there is no
+// /proc to ask
+func TTYName() string {
+	for _, f := range []*os.File{os.Stdin, os.Stdout, os.Stderr} {
+		if !IsTTY(f) {
+			continue
+		}
+		if name, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", f.Fd())); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// IsTTY reports whether f is a terminal the way isatty does, unlike
+// isTerminal which takes any character device, /dev/null included
+func IsTTY(f *os.File) bool {
+	_, errno := getWinsize(f)
+	return errno == 0
+}
+
+// openTerminal opens the controlling terminal, whatever stdin is; it fails
+// when there is none, as under cron or ssh without -t
+func openTerminal() (*os.File, error) {
+	return os.Open("/dev/tty")
+}
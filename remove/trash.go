@@ -0,0 +1,417 @@
+package remove
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// what to do when none of the trash candidates can take files
+var ONNOTRASH = []string{"fail", "permanent", "tmp"}
+
+// TMPTRASH is where --on-no-trash=tmp moves files, the system's temporary
+// directory: /tmp, or on Windows the user's %TEMP%
+var TMPTRASH = "/tmp"
+
+// TRASHDIRENV names the environment variable that picks the trash directory
+// instead of ~/.Trash
+const TRASHDIRENV = "SRM_TRASH_DIR"
+
+// TRASHDIRFLAG is --trash-dir, which picks the trash for the whole
+// invocation over SRM_TRASH_DIR and the config's trash_dir
+var TRASHDIRFLAG = ""
+
+// PrepareTrashDir makes dir, given to --trash-dir, absolute and creates it
+// (owner-only) when it doesn't exist, unless dryRun, which only reports it
+// missing. The directory must then take files.
+func PrepareTrashDir(dir string, dryRun bool) (abs string, missing bool, err error) {
+	if abs, err = filepath.Abs(dir); err != nil {
+		return "", false, err
+	}
+	if _, err := os.Stat(abs); errors.Is(err, fs.ErrNotExist) {
+		if dryRun {
+			return abs, true, nil
+		}
+		if err := os.MkdirAll(abs, 0700); err != nil {
+			return "", false, err
+		}
+	}
+	return abs, false, CheckTrashDir(abs)
+}
+
+// TrashCandidate is one directory srm could move an operand to
+type TrashCandidate struct {
+	Dir  string
+	Kind string // named, env, xdg, home or volume
+	// Why says what the candidate is and why it has its place in the order
+	Why string
+	// Err is why it can't be used, set once the candidate is checked
+	Err error
+}
+
+// TrashContext is everything the choice of trash depends on. ResolveTrash
+// reads nothing else, so the same context always gives the same order.
+type TrashContext struct {
+	Home string // "" when HOME isn't set
+	// EnvDir is --trash-dir, SRM_TRASH_DIR or trash_dir from the config,
+	// the first that is set, and EnvFrom which of them it is
+	EnvDir  string
+	EnvFrom string
+	// XDGTrash is the files directory of the freedesktop.org home trash,
+	// "" where there is none, as on macOS
+	XDGTrash string
+	UID      int // -1 where there are no uids
+	Mounts   []Mount
+	// Operand is the absolute, symlink-resolved path being removed, or ""
+	// for the trash of the run as a whole
+	Operand string
+	// Prefer is --prefer-trash, or prefer_trash from the config: home,
+	// volume or a directory, most preferred first
+	Prefer []string
+	// SpecVolumes are the mount points whose .Trash-UID is laid out as a
+	// freedesktop.org trash, as GIO creates them on removable drives
+	SpecVolumes []string
+}
+
+// ResolveTrash orders the trash candidates for ctx. --trash-dir,
+// SRM_TRASH_DIR or the config's trash_dir stands in for the home trashes: the freedesktop.org
+// one, where desktop trash viewers look, then ~/.Trash. An operand on another filesystem than that trash gets its
+// volume's .Trash-UID first, so moving it stays a rename, or its files
+// directory when the volume is one of SpecVolumes. Prefer then pulls
+// the candidates it names to the front, in its order, and adds the
+// directories it names.
+func ResolveTrash(ctx TrashContext) []TrashCandidate {
+	candidates := []TrashCandidate{}
+	switch {
+	case ctx.EnvDir != "":
+		candidates = append(candidates, TrashCandidate{Dir: ctx.EnvDir, Kind: "env", Why: ctx.EnvFrom + " is set"})
+	default:
+		if ctx.XDGTrash != "" {
+			candidates = append(candidates, TrashCandidate{Dir: ctx.XDGTrash, Kind: "xdg", Why: "the freedesktop.org home trash"})
+		}
+		if ctx.Home != "" {
+			candidates = append(candidates, TrashCandidate{Dir: filepath.Join(ctx.Home, ".Trash"), Kind: "home", Why: "the home trash"})
+		}
+	}
+
+	if ctx.Operand != "" && ctx.UID >= 0 {
+		volume := mountOf(ctx.Operand, ctx.Mounts)
+		if volume.Point != "" && (len(candidates) == 0 || mountOf(candidates[0].Dir, ctx.Mounts).Point != volume.Point) {
+			candidate := TrashCandidate{
+				Dir:  VolumeTrashDir(volume.Point, ctx.UID),
+				Kind: "volume",
+				Why:  "on the operand's filesystem, mounted at " + volume.Point + ", so the move is a rename",
+			}
+			if In(volume.Point, ctx.SpecVolumes) {
+				// whoever made it, GIO mostly, reads it as the spec has it
+				candidate.Dir = filepath.Join(candidate.Dir, "files")
+				candidate.Why += "; a freedesktop.org trash, so into its files directory"
+			}
+			candidates = append([]TrashCandidate{candidate}, candidates...)
+		}
+	}
+
+	preferred := []TrashCandidate{}
+	for _, prefer := range ctx.Prefer {
+		kinds := []string{prefer}
+		if prefer == "home" {
+			kinds = append(kinds, "env", "xdg")
+		}
+		rest := []TrashCandidate{}
+		for _, candidate := range candidates {
+			if In(candidate.Kind, kinds) {
+				candidate.Why += ", preferred by " + prefer
+				preferred = append(preferred, candidate)
+			} else {
+				rest = append(rest, candidate)
+			}
+		}
+		candidates = rest
+		if prefer != "home" && prefer != "volume" {
+			preferred = append(preferred, TrashCandidate{Dir: prefer, Kind: "named", Why: "named by --prefer-trash"})
+		}
+	}
+	return append(preferred, candidates...)
+}
+
+// CurrentTrashContext is the TrashContext of this process for operand, which
+// may be "" for the run as a whole
+func CurrentTrashContext(operand string, prefer []string) TrashContext {
+	ctx := TrashContext{EnvDir: TRASHDIRFLAG, EnvFrom: "--trash-dir", UID: os.Getuid(), Prefer: prefer}
+	if ctx.EnvDir == "" {
+		ctx.EnvDir, ctx.EnvFrom = os.Getenv(TRASHDIRENV), TRASHDIRENV
+	}
+	if ctx.EnvDir == "" {
+		// a bad trash_dir is reported by resolveOptions
+		if settings, err := LoadSettings(); err == nil {
+			ctx.EnvDir, _ = settings.Config.Path("trash_dir")
+			ctx.EnvFrom = "trash_dir in the config"
+		}
+	}
+	// HOME is used as it is, the index records trash paths under it
+	ctx.Home, _ = os.UserHomeDir()
+	ctx.XDGTrash = xdgTrashDir(ctx.Home)
+	if operand != "" {
+		// without a mount table there are no volume trashes, only the rest
+		ctx.Mounts, _ = LoadMounts()
+		ctx.Operand, _ = realPath(operand)
+		if volume := mountOf(ctx.Operand, ctx.Mounts); volume.Point != "" && IsSpecTrash(VolumeTrashDir(volume.Point, ctx.UID)) {
+			ctx.SpecVolumes = []string{volume.Point}
+		}
+	}
+	return ctx
+}
+
+// VolumeTrashDir is the .Trash-UID of the volume mounted at point
+func VolumeTrashDir(point string, uid int) string {
+	return filepath.Join(point, fmt.Sprintf(".Trash-%d", uid))
+}
+
+// IsSpecTrash reports whether top is laid out as a freedesktop.org trash,
+// with files and info directories in it
+func IsSpecTrash(top string) bool {
+	for _, dir := range []string{"files", "info"} {
+		if fi, err := os.Lstat(filepath.Join(top, dir)); err != nil || !fi.IsDir() {
+			return false
+		}
+	}
+	return true
+}
+
+// xdgTrashDir is $XDG_DATA_HOME/Trash/files, ~/.local/share/Trash/files
+// when XDG_DATA_HOME isn't set, or "" on macOS, whose trash is ~/.Trash
+func xdgTrashDir(home string) string {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		return ""
+	}
+	data := os.Getenv("XDG_DATA_HOME")
+	if !filepath.IsAbs(data) {
+		// the spec has a relative XDG_DATA_HOME ignored
+		if home == "" {
+			return ""
+		}
+		data = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(data, "Trash", "files")
+}
+
+// CheckTrashCandidate returns why candidate can't be used, or nil if it can.
+// A volume trash is shared with whoever else can write to the volume, so it
+// must be private as well, the .Trash-UID around it too when it is laid
+// out as a freedesktop.org trash, and such a trash needs its info
+// directory for the .trashinfo files.
+func CheckTrashCandidate(candidate TrashCandidate) error {
+	if err := CheckTrashDir(candidate.Dir); err != nil {
+		return err
+	}
+	switch candidate.Kind {
+	case "volume":
+		if info := SpecInfoDir(candidate.Dir); info != "" {
+			if err := CheckPrivateDir(filepath.Dir(candidate.Dir)); err != nil {
+				return err
+			}
+			if err := CheckTrashDir(info); err != nil {
+				return err
+			}
+		}
+		return CheckPrivateDir(candidate.Dir)
+	case "xdg":
+		return CheckTrashDir(filepath.Join(filepath.Dir(candidate.Dir), "info"))
+	}
+	return nil
+}
+
+// CheckTrashDir returns why dir can't be used as a trash, or nil if it can
+func CheckTrashDir(dir string) error {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s: not a directory", dir)
+	}
+
+	probe, err := os.CreateTemp(dir, ".srm-probe-*")
+	if err != nil {
+		return fmt.Errorf("%s: not writable", dir)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	return nil
+}
+
+// NAMEMAX and PATHMAX are the longest name and path, in bytes, a trash
+// destination may have; macOS allows shorter paths than Linux
+var (
+	NAMEMAX = 255
+	PATHMAX = pathMax()
+)
+
+func pathMax() int {
+	if runtime.GOOS == "darwin" {
+		return 1024
+	}
+	return 4096
+}
+
+// UniqueTrashName
+// returns name if dir has nothing by that name yet, otherwise the first free
+// one of name.1, name.2, ...
+func UniqueTrashName(fsys FS, dir string, name string) (string, error) {
+	return freeTrashName(fsys, dir, name, "", nil)
+}
+
+// freeTrashName is UniqueTrashName for name+ext, numbered ahead of ext
+// (name.tar.gz, name.1.tar.gz, ...), passing over the paths in taken too.
+// A name that would make the destination longer than NAMEMAX or PATHMAX
+// is shortened, see fitName; when even that can't fit, it fails with
+// ErrNameTooLong. In a freedesktop.org trash a name is only free when its
+// info file is too, and has to leave room for that file's extension. On
+// Windows a device name like CON is escaped, see escapeReservedName, since
+// opening it would open the device.
+func freeTrashName(fsys FS, dir string, name string, ext string, taken map[string]bool) (string, error) {
+	if runtime.GOOS == "windows" {
+		name = escapeReservedName(name)
+	}
+	info := SpecInfoDir(dir)
+	budget := NAMEMAX
+	if info != "" {
+		budget -= len(TRASHINFOEXT)
+	}
+	if room := PATHMAX - 1 - len(dir) - 1; room < budget {
+		budget = room
+	}
+	suffix := ""
+	for i := 1; ; i++ {
+		stem, ok := fitName(name, budget-len(suffix)-len(ext))
+		if !ok {
+			return "", fmt.Errorf("%s: %w (%s leaves %d bytes for it); rm deletes it for good instead", DisplayPath(filepath.Join(dir, name+ext)), ErrNameTooLong, DisplayPath(dir), budget)
+		}
+		candidate := stem + suffix + ext
+		path := filepath.Join(dir, candidate)
+		if _, err := fsys.Lstat(path); errors.Is(err, fs.ErrNotExist) && !taken[path] {
+			if info == "" {
+				return candidate, nil
+			}
+			if _, err := os.Lstat(filepath.Join(info, candidate+TRASHINFOEXT)); errors.Is(err, fs.ErrNotExist) {
+				return candidate, nil
+			}
+		}
+		suffix = fmt.Sprintf(".%d", i)
+	}
+}
+
+// TRASHNAMETRIES is how many names a move into a trash tries, each one
+// taken by another trasher between being found free and being moved to,
+// before it gives up
+const TRASHNAMETRIES = 64
+
+// MoveToFreeName moves into dir under the first free name for name, as
+// freeTrashName finds them. In a freedesktop.org trash the name is claimed
+// by writing its info file first; move is a rename that never replaces,
+// like FS.RenameNoReplace. A name another trasher claims or moves to first
+// is passed over for the next, so two srms never get the same one.
+func MoveToFreeName(fsys FS, dir string, name string, origin string, deleted time.Time, move func(dest string) error) (string, error) {
+	taken := map[string]bool{}
+	for tries := 1; ; tries++ {
+		free, err := freeTrashName(fsys, dir, name, "", taken)
+		if err != nil {
+			return "", err
+		}
+		dest := filepath.Join(dir, free)
+		taken[dest] = true
+		release, err := writeTrashInfo(dest, origin, deleted)
+		if err == nil {
+			if err = move(dest); err != nil {
+				release()
+			}
+		}
+		if err == nil || !errors.Is(err, fs.ErrExist) || tries == TRASHNAMETRIES {
+			return free, err
+		}
+	}
+}
+
+// fitName returns name if it is at most max bytes, and otherwise as much of
+// it as fits ahead of a ~ and a hash of the whole name, so two long names
+// sharing a beginning still get different stems. ok is false when max
+// doesn't even leave room for the hash.
+func fitName(name string, max int) (string, bool) {
+	if len(name) <= max {
+		return name, true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	tag := fmt.Sprintf("~%08x", h.Sum32())
+	keep := max - len(tag)
+	if keep < 1 {
+		return "", false
+	}
+	// cut on a character boundary
+	for keep > 0 && !utf8.RuneStart(name[keep]) {
+		keep--
+	}
+	return name[:keep] + tag, true
+}
+
+// FindTrashDir returns the first usable trash candidate, or an error
+// describing why each of them was rejected
+func FindTrashDir() (string, error) {
+	return ChooseTrashDir(nil)
+}
+
+// ChooseTrashDir is FindTrashDir with --prefer-trash applied
+func ChooseTrashDir(prefer []string) (string, error) {
+	candidates := ResolveTrash(CurrentTrashContext("", prefer))
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("%w (HOME is not set, so there is no ~/.Trash; set HOME, or set %s to a trash directory)", ErrTrashUnavailable, TRASHDIRENV)
+	}
+
+	problems := []string{}
+	for _, candidate := range candidates {
+		err := CheckTrashCandidate(candidate)
+		if err == nil {
+			return candidate.Dir, nil
+		}
+		problems = append(problems, err.Error())
+	}
+
+	return "", fmt.Errorf("%w (%s)", ErrTrashUnavailable, strings.Join(problems, "; "))
+}
+
+// CreateXDGTrash creates the freedesktop.org home trash, files and info
+// owner-only, when none of the candidates for prefer is usable and that
+// trash is one of them, so a first removal on Linux has somewhere to go.
+// A usable ~/.Trash is left in use. created is the files directory when it
+// made anything, or under dryRun would have.
+func CreateXDGTrash(prefer []string, dryRun bool) (created string, err error) {
+	candidates := ResolveTrash(CurrentTrashContext("", prefer))
+	for _, candidate := range candidates {
+		if CheckTrashCandidate(candidate) == nil {
+			return "", nil
+		}
+	}
+	for _, candidate := range candidates {
+		if candidate.Kind != "xdg" {
+			continue
+		}
+		if dryRun {
+			return candidate.Dir, nil
+		}
+		for _, dir := range []string{candidate.Dir, filepath.Join(filepath.Dir(candidate.Dir), "info")} {
+			if err := MkdirPrivate(dir); err != nil {
+				return "", err
+			}
+		}
+		return candidate.Dir, nil
+	}
+	return "", nil
+}
@@ -0,0 +1,354 @@
+package remove
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestResolveTrash(t *testing.T) {
+	mounts := []Mount{{"/", "ext4"}, {"/home", "ext4"}, {"/data", "xfs"}, {"/mnt/usb", "vfat"}}
+	const (
+		xdg  = "/home/u/.local/share/Trash/files"
+		home = "/home/u/.Trash"
+		usb  = "/mnt/usb/.Trash-1000"
+		root = "/.Trash-1000"
+	)
+	linux := TrashContext{Home: "/home/u", XDGTrash: xdg, UID: 1000, Mounts: mounts}
+	with := func(change func(ctx *TrashContext)) TrashContext {
+		ctx := linux
+		change(&ctx)
+		return ctx
+	}
+
+	tests := []struct {
+		name  string
+		ctx   TrashContext
+		dirs  []string
+		kinds []string
+	}{
+		{"the run as a whole", linux,
+			[]string{xdg, home}, []string{"xdg", "home"}},
+		{"operand beside the home trash", with(func(c *TrashContext) { c.Operand = "/home/u/notes.txt" }),
+			[]string{xdg, home}, []string{"xdg", "home"}},
+		{"operand on a removable drive", with(func(c *TrashContext) { c.Operand = "/mnt/usb/photo.jpg" }),
+			[]string{usb, xdg, home}, []string{"volume", "xdg", "home"}},
+		{"operand on a drive GIO made a trash on", with(func(c *TrashContext) {
+			c.Operand, c.SpecVolumes = "/mnt/usb/photo.jpg", []string{"/mnt/usb"}
+		}),
+			[]string{usb + "/files", xdg, home}, []string{"volume", "xdg", "home"}},
+		{"the longest mount point wins", with(func(c *TrashContext) {
+			c.Operand, c.Mounts = "/mnt/usb/a/b", append(slices.Clone(mounts), Mount{"/mnt/usb/a", "ext4"})
+		}),
+			[]string{"/mnt/usb/a/.Trash-1000", xdg, home}, []string{"volume", "xdg", "home"}},
+		{"a mount point that is only a prefix", with(func(c *TrashContext) { c.Operand = "/database/x" }),
+			[]string{root, xdg, home}, []string{"volume", "xdg", "home"}},
+		{"--trash-dir on the operand's filesystem", with(func(c *TrashContext) {
+			c.EnvDir, c.EnvFrom, c.Operand = "/data/trash", "--trash-dir", "/data/x"
+		}),
+			[]string{"/data/trash"}, []string{"env"}},
+		{"SRM_TRASH_DIR elsewhere", with(func(c *TrashContext) {
+			c.EnvDir, c.EnvFrom, c.Operand = "/data/trash", TRASHDIRENV, "/home/u/x"
+		}),
+			[]string{"/home/.Trash-1000", "/data/trash"}, []string{"volume", "env"}},
+		{"no mount table", with(func(c *TrashContext) { c.Operand, c.Mounts = "/mnt/usb/x", nil }),
+			[]string{xdg, home}, []string{"xdg", "home"}},
+		{"no uids, as on Windows", with(func(c *TrashContext) { c.Operand, c.UID = "/mnt/usb/x", -1 }),
+			[]string{xdg, home}, []string{"xdg", "home"}},
+		{"macOS, with no freedesktop.org trash", with(func(c *TrashContext) { c.XDGTrash = "" }),
+			[]string{home}, []string{"home"}},
+		{"no HOME", with(func(c *TrashContext) { c.Home, c.XDGTrash, c.Operand = "", "", "/tmp/x" }),
+			[]string{root}, []string{"volume"}},
+		{"no HOME and no operand", with(func(c *TrashContext) { c.Home, c.XDGTrash = "", "" }),
+			[]string{}, []string{}},
+		{"--prefer-trash=home", with(func(c *TrashContext) { c.Operand, c.Prefer = "/mnt/usb/x", []string{"home"} }),
+			[]string{xdg, home, usb}, []string{"xdg", "home", "volume"}},
+		{"--prefer-trash=volume", with(func(c *TrashContext) { c.Operand, c.Prefer = "/mnt/usb/x", []string{"volume"} }),
+			[]string{usb, xdg, home}, []string{"volume", "xdg", "home"}},
+		{"--prefer-trash=volume with no volume trash", with(func(c *TrashContext) { c.Prefer = []string{"volume"} }),
+			[]string{xdg, home}, []string{"xdg", "home"}},
+		{"--prefer-trash=DIR", with(func(c *TrashContext) { c.Operand, c.Prefer = "/mnt/usb/x", []string{"/srv/trash"} }),
+			[]string{"/srv/trash", usb, xdg, home}, []string{"named", "volume", "xdg", "home"}},
+		{"--prefer-trash given several times", with(func(c *TrashContext) {
+			c.Operand, c.Prefer = "/mnt/usb/x", []string{"/srv/a", "home", "/srv/b", "volume"}
+		}),
+			[]string{"/srv/a", xdg, home, "/srv/b", usb}, []string{"named", "xdg", "home", "named", "volume"}},
+		{"--prefer-trash=home takes --trash-dir", with(func(c *TrashContext) {
+			c.EnvDir, c.EnvFrom, c.Operand, c.Prefer = "/data/trash", "--trash-dir", "/mnt/usb/x", []string{"home"}
+		}),
+			[]string{"/data/trash", usb}, []string{"env", "volume"}},
+	}
+	for _, tt := range tests {
+		candidates := ResolveTrash(tt.ctx)
+		dirs, kinds := []string{}, []string{}
+		for _, candidate := range candidates {
+			dirs, kinds = append(dirs, candidate.Dir), append(kinds, candidate.Kind)
+			if candidate.Why == "" {
+				t.Errorf("%s: no reason given for %s", tt.name, candidate.Dir)
+			}
+			homely := slices.Contains([]string{"xdg", "home", "env"}, candidate.Kind)
+			if homely && slices.Contains(tt.ctx.Prefer, "home") && !strings.Contains(candidate.Why, "preferred by home") {
+				t.Errorf("%s: %s doesn't say it was preferred: %s", tt.name, candidate.Dir, candidate.Why)
+			}
+		}
+		if !slices.Equal(dirs, tt.dirs) || !slices.Equal(kinds, tt.kinds) {
+			t.Errorf("%s: resolved %q %q, want %q %q", tt.name, dirs, kinds, tt.dirs, tt.kinds)
+		}
+		if again := ResolveTrash(tt.ctx); !slices.Equal(again, candidates) {
+			t.Errorf("%s: resolved differently the second time", tt.name)
+		}
+	}
+}
+
+// --trash-dir wins over SRM_TRASH_DIR, which wins over trash_dir in the
+// config
+func TestCurrentTrashContext(t *testing.T) {
+	env := testEnv(t)
+	defer func(old string) { TRASHDIRFLAG = old }(TRASHDIRFLAG)
+	config := filepath.Join(env.root, "config")
+
+	tests := []struct {
+		flag, envDir, configDir string
+		dir, from               string
+	}{
+		{"", "", "", "", ""},
+		{"", "", "/c", "/c", "trash_dir in the config"},
+		{"", "/e", "/c", "/e", TRASHDIRENV},
+		{"/f", "/e", "/c", "/f", "--trash-dir"},
+		{"/f", "", "", "/f", "--trash-dir"},
+	}
+	for _, tt := range tests {
+		TRASHDIRFLAG = tt.flag
+		t.Setenv(TRASHDIRENV, tt.envDir)
+		text := ""
+		if tt.configDir != "" {
+			text = "trash_dir = " + tt.configDir + "\n"
+		}
+		if err := os.WriteFile(config, []byte(text), 0600); err != nil {
+			t.Fatal(err)
+		}
+		ctx := CurrentTrashContext("", nil)
+		if ctx.EnvDir != tt.dir || (tt.dir != "" && ctx.EnvFrom != tt.from) {
+			t.Errorf("--trash-dir %q, %s %q, trash_dir %q: got %q from %q, want %q from %q",
+				tt.flag, TRASHDIRENV, tt.envDir, tt.configDir, ctx.EnvDir, ctx.EnvFrom, tt.dir, tt.from)
+		}
+	}
+}
+
+func TestXDGTrashDir(t *testing.T) {
+	if xdgTrashDir("/h") == "" {
+		t.Skip("no freedesktop.org trash on this platform")
+	}
+	tests := []struct {
+		home, data string
+		want       string
+	}{
+		{"/h", "", "/h/.local/share/Trash/files"},
+		{"/h", "/d", "/d/Trash/files"},
+		// the spec has a relative XDG_DATA_HOME ignored
+		{"/h", "rel", "/h/.local/share/Trash/files"},
+		{"", "/d", "/d/Trash/files"},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		t.Setenv("XDG_DATA_HOME", tt.data)
+		if got := xdgTrashDir(tt.home); got != tt.want {
+			t.Errorf("xdgTrashDir(%q) with XDG_DATA_HOME=%q = %q, want %q", tt.home, tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestFitName(t *testing.T) {
+	long := strings.Repeat("n", NAMEMAX)
+	tests := []struct {
+		name string
+		max  int
+		keep string // what is left of name before its tag
+		ok   bool
+	}{
+		{"short", 255, "short", true},
+		{long, 255, long, true},
+		{long + "x", 255, long[:246], true},
+		{long, 100, long[:91], true},
+		{"abcdefghij", 10, "abcdefghij", true},
+		{"abcdefghijk", 10, "a", true},
+		{"abcdefghijk", 9, "", false},
+		{"abcdefghijk", 0, "", false},
+		// never cut inside a character
+		{strings.Repeat("é", 10), 12, "é", true},
+		{strings.Repeat("é", 10), 13, "éé", true},
+		{strings.Repeat("é", 10), 14, "éé", true},
+		{strings.Repeat("é", 10), 15, "ééé", true},
+	}
+	tag := regexp.MustCompile(`^~[0-9a-f]{8}$`)
+	for _, tt := range tests {
+		got, ok := fitName(tt.name, tt.max)
+		if ok != tt.ok {
+			t.Errorf("fitName(%.20q..., %d) = %q, %v, want %v", tt.name, tt.max, got, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		rest, cut := strings.CutPrefix(got, tt.keep)
+		if !cut || (len(tt.name) > tt.max && !tag.MatchString(rest)) || (len(tt.name) <= tt.max && rest != "") {
+			t.Errorf("fitName(%.20q..., %d) = %q, want %q and its tag", tt.name, tt.max, got, tt.keep)
+		}
+		if len(got) > tt.max || !utf8.ValidString(got) {
+			t.Errorf("fitName(%.20q..., %d) = %q, %d bytes", tt.name, tt.max, got, len(got))
+		}
+	}
+
+	// long names sharing a beginning stay apart
+	a, _ := fitName(long+"a", NAMEMAX)
+	b, _ := fitName(long+"b", NAMEMAX)
+	if a == b {
+		t.Errorf("%q and %q both fit as %q", long+"a", long+"b", a)
+	}
+}
+
+// deepDir makes a directory under root whose path is length bytes long
+func deepDir(t *testing.T, root string, length int) string {
+	t.Helper()
+	dir := root
+	for len(dir) < length {
+		part := min(200, length-len(dir)-1)
+		if part < 1 {
+			part = 1
+		}
+		dir = filepath.Join(dir, strings.Repeat("d", part))
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestFreeTrashName(t *testing.T) {
+	long := strings.Repeat("n", NAMEMAX)
+	root := t.TempDir()
+	plain := filepath.Join(root, "plain")
+	spec := filepath.Join(root, "spec", "files")
+	for _, dir := range []string{plain, spec, filepath.Join(root, "spec", "info")} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{long, "taken", "taken.1", "a.tar.gz"} {
+		os.WriteFile(filepath.Join(plain, name), nil, 0600)
+	}
+	os.WriteFile(filepath.Join(spec, "taken"), nil, 0600)
+	os.WriteFile(filepath.Join(root, "spec", "info", "claimed.trashinfo"), nil, 0600)
+	deep := deepDir(t, root, PATHMAX-60)
+
+	tests := []struct {
+		name     string
+		dir      string
+		file     string
+		ext      string
+		want     string
+		tooLong  bool
+		maxBytes int
+	}{
+		{"free", plain, "free", "", "free", false, NAMEMAX},
+		{"taken", plain, "taken", "", "taken.2", false, NAMEMAX},
+		{"numbered ahead of the extension", plain, "a", ".tar.gz", "a.1.tar.gz", false, NAMEMAX},
+		{"a free 255-byte name", plain, strings.Repeat("m", NAMEMAX), "", strings.Repeat("m", NAMEMAX), false, NAMEMAX},
+		{"a taken 255-byte name", plain, long, "", "", false, NAMEMAX},
+		{"a 255-byte name with an extension", plain, long, ".tar.gz", "", false, NAMEMAX},
+		{"a spec trash leaves room for .trashinfo", spec, long, "", "", false, NAMEMAX - len(TRASHINFOEXT)},
+		{"a spec trash numbers past names with only an info file", spec, "claimed", "", "claimed.1", false, NAMEMAX},
+		{"a spec trash numbers past its payloads", spec, "taken", "", "taken.1", false, NAMEMAX},
+		{"a deep trash shortens to fit the path", deep, long, "", "", false, PATHMAX - 1 - len(deep) - 1},
+		{"a deep trash keeps a short name", deep, "short", "", "short", false, PATHMAX},
+		{"a trash too deep for any name", deepDir(t, root, PATHMAX-8), "report.txt", "", "", true, 0},
+	}
+	for _, tt := range tests {
+		got, err := freeTrashName(OSFS{}, tt.dir, tt.file, tt.ext, nil)
+		if tt.tooLong {
+			if !errors.Is(err, ErrNameTooLong) || !strings.Contains(err.Error(), "rm deletes it for good") {
+				t.Errorf("%s: %q, %v, want %v", tt.name, got, err, ErrNameTooLong)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if tt.want != "" && got != tt.want {
+			t.Errorf("%s: %q, want %q", tt.name, got, tt.want)
+		}
+		if len(got) > tt.maxBytes {
+			t.Errorf("%s: %q is %d bytes, over %d", tt.name, got, len(got), tt.maxBytes)
+		}
+		if len(filepath.Join(tt.dir, got)) >= PATHMAX {
+			t.Errorf("%s: the destination is %d bytes", tt.name, len(filepath.Join(tt.dir, got)))
+		}
+		if !strings.HasSuffix(got, tt.ext) {
+			t.Errorf("%s: %q lost its extension %s", tt.name, got, tt.ext)
+		}
+		if _, err := os.Lstat(filepath.Join(tt.dir, got)); err == nil {
+			t.Errorf("%s: %q is taken", tt.name, got)
+		}
+	}
+}
+
+// A 255-byte name goes to the trash twice, the second time shortened to
+// number it, and a trash too deep for it fails that operand alone
+func TestTrashLongNames(t *testing.T) {
+	env := testEnv(t)
+	long := strings.Repeat("n", NAMEMAX)
+	dests := map[string]bool{}
+	for _, content := range []string{"first", "second"} {
+		path, err := env.file(long, content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := env.remover(false).Remove(path)
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+		if name := filepath.Base(result.Dest); len(name) > NAMEMAX {
+			t.Errorf("trashed as %q, %d bytes", name, len(name))
+		}
+		if got, err := os.ReadFile(result.Dest); err != nil || string(got) != content {
+			t.Errorf("%s has %q, %v, want %q", result.Dest, got, err, content)
+		}
+		dests[result.Dest] = true
+	}
+	if len(dests) != 2 {
+		t.Errorf("both went to the same place: %v", dests)
+	}
+	entries, err := env.index.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if !dests[entry.Payload()] {
+			t.Errorf("the index has %s", entry.Payload())
+		}
+		delete(dests, entry.Payload())
+	}
+	if len(dests) != 0 {
+		t.Errorf("the index lacks %v", dests)
+	}
+
+	deep := deepDir(t, env.root, PATHMAX-8)
+	r := NewRemover(Options{TrashDir: deep, Index: env.index, Intents: env.intents, Op: "test"})
+	defer r.Close()
+	kept, err := env.file("kept.txt", "kept")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := r.Remove(kept); !errors.Is(result.Err, ErrNameTooLong) {
+		t.Errorf("removing into a trash too deep: %v, want %v", result.Err, ErrNameTooLong)
+	}
+	if _, err := os.Stat(kept); err != nil {
+		t.Errorf("the operand is gone: %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package remove
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// TRASHINFOEXT is the extension of a freedesktop.org trash info file
+const TRASHINFOEXT = ".trashinfo"
+
+// SpecInfoDir is the info directory of the freedesktop.org trash whose
+// files directory is trashDir, or "" when trashDir isn't one: a trash
+// laid out by the spec keeps payloads in files/ and, beside it, an info/
+// with a NAME.trashinfo for each, which desktop trash viewers read
+func SpecInfoDir(trashDir string) string {
+	if filepath.Base(trashDir) != "files" {
+		return ""
+	}
+	info := filepath.Join(filepath.Dir(trashDir), "info")
+	if fi, err := os.Stat(info); err != nil || !fi.IsDir() {
+		return ""
+	}
+	return info
+}
+
+// trashInfoPath is the info file for the payload at path, "" when it isn't
+// in a freedesktop.org trash
+func trashInfoPath(path string) string {
+	info := SpecInfoDir(filepath.Dir(path))
+	if info == "" {
+		return ""
+	}
+	return filepath.Join(info, filepath.Base(path)+TRASHINFOEXT)
+}
+
+// writeTrashInfo records where the payload about to be moved to dest came
+// from, for a dest in a freedesktop.org trash. The spec has the info file
+// written first, created exclusively so two trashers can't both claim a
+// name; release removes it again when the move then fails. Outside such a
+// trash it does nothing.
+func writeTrashInfo(dest string, origin string, deleted time.Time) (release func(), err error) {
+	path := trashInfoPath(dest)
+	if path == "" {
+		return func() {}, nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("%s: trash info: %w", DisplayPath(dest), err)
+	}
+	release = func() { os.Remove(path) }
+
+	text := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n", (&url.URL{Path: origin}).EscapedPath(), deleted.Format("2006-01-02T15:04:05"))
+	if _, err := f.Write([]byte(text)); err != nil {
+		f.Close()
+		release()
+		return nil, fmt.Errorf("%s: trash info: %w", DisplayPath(dest), err)
+	}
+	if err := f.Close(); err != nil {
+		release()
+		return nil, fmt.Errorf("%s: trash info: %w", DisplayPath(dest), err)
+	}
+	return release, nil
+}
+
+// RemoveTrashInfo removes the info file of a payload that has left a
+// freedesktop.org trash, and its line in directorysizes. One already gone
+// is no error.
+func RemoveTrashInfo(payload string) error {
+	path := trashInfoPath(payload)
+	if path == "" {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return dropDirectorySize(payload)
+}
+
+// TrashInfoDate is the DeletionDate of the info file of the payload at
+// path, for payloads srm has no index row for; ok is false when there is no
+// info file or it has no date it can read
+func TrashInfoDate(payload string) (deleted time.Time, ok bool) {
+	path := trashInfoPath(payload)
+	if path == "" {
+		return time.Time{}, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, found := strings.CutPrefix(strings.TrimSpace(line), "DeletionDate="); found {
+			deleted, err := time.ParseInLocation("2006-01-02T15:04:05", value, time.Local)
+			return deleted, err == nil
+		}
+	}
+	return time.Time{}, false
+}
+
+// DIRECTORYSIZES is the file beside files/ and info/ in which a
+// freedesktop.org trash caches the size of each directory in it, a line of
+// "SIZE MTIME NAME" per directory, MTIME that of its info file and NAME
+// percent-encoded. Nautilus shows the trash's size from it.
+const DIRECTORYSIZES = "directorysizes"
+
+// directorySizesPath is the directorysizes file of the trash the payload at
+// path is in, "" when it isn't in a freedesktop.org trash
+func directorySizesPath(payload string) string {
+	info := SpecInfoDir(filepath.Dir(payload))
+	if info == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(info), DIRECTORYSIZES)
+}
+
+// recordDirectorySize adds the directory just moved to payload to its
+// trash's directorysizes. Anything that isn't a directory, such as an
+// --archive tarball, has no line there.
+func recordDirectorySize(fsys FS, payload string) error {
+	path := directorySizesPath(payload)
+	if path == "" {
+		return nil
+	}
+	fi, err := fsys.Lstat(payload)
+	if err != nil || !fi.IsDir() {
+		return err
+	}
+	size, err := DiskUsage(fsys, payload)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(trashInfoPath(payload))
+	if err != nil {
+		return err
+	}
+	line := fmt.Sprintf("%d %d %s", size, info.ModTime().Unix(), directorySizesName(payload))
+	return rewriteDirectorySizes(path, directorySizesName(payload), line)
+}
+
+// dropDirectorySize removes the payload's line from its trash's
+// directorysizes, if there is one
+func dropDirectorySize(payload string) error {
+	path := directorySizesPath(payload)
+	if path == "" {
+		return nil
+	}
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return rewriteDirectorySizes(path, directorySizesName(payload), "")
+}
+
+func directorySizesName(payload string) string {
+	return (&url.URL{Path: filepath.Base(payload)}).EscapedPath()
+}
+
+// rewriteDirectorySizes replaces the line for name in the directorysizes
+// file at path with line, or drops it when line is "". The spec has the
+// file replaced by a rename, so a reader never sees half of it.
+func rewriteDirectorySizes(path string, name string, line string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	kept := []string{}
+	for _, old := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(old)
+		if len(fields) != 3 || fields[2] == name {
+			continue
+		}
+		kept = append(kept, old)
+	}
+	if line != "" {
+		kept = append(kept, line)
+	}
+	text := strings.Join(kept, "\n")
+	if text != "" {
+		text += "\n"
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+DIRECTORYSIZES+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write([]byte(text)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,88 @@
+package remove
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// purgeAll purges every indexed entry of trash, as srm empty does
+func purgeAll(t *testing.T, env *scratchEnv, trash string) {
+	t.Helper()
+	entries, err := env.index.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.Trash != trash {
+			continue
+		}
+		if result := PurgeCandidate(env.faults, env.index, EmptyCandidate{Entry: entry, Known: true}, false); result.Err != nil {
+			t.Fatal(result.Err)
+		}
+	}
+}
+
+// In a freedesktop.org trash each payload gets a .trashinfo, escaped as
+// the spec has it, and each directory a line in directorysizes; purging
+// the payload takes both away
+func TestFreedesktopTrash(t *testing.T) {
+	env := testEnv(t)
+	files := filepath.Join(env.root, "xdg", "Trash", "files")
+	info := filepath.Join(env.root, "xdg", "Trash", "info")
+	sizes := filepath.Join(env.root, "xdg", "Trash", DIRECTORYSIZES)
+	for _, dir := range []string{files, info} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := env.options(true)
+	opts.TrashDir = files
+
+	path, err := env.file("spec trash/100% done.txt", "viewed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := env.removerWith(opts).Remove(path)
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	infoPath := filepath.Join(info, filepath.Base(result.Dest)+TRASHINFOEXT)
+	got, err := os.ReadFile(infoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Path=" + strings.ReplaceAll(strings.ReplaceAll(filepath.ToSlash(path), " ", "%20"), "100%", "100%25")
+	if !strings.HasPrefix(string(got), "[Trash Info]\n") || !strings.Contains(string(got), want+"\n") || !strings.Contains(string(got), "\nDeletionDate=") {
+		t.Errorf("%s doesn't hold %s:\n%s", infoPath, want, got)
+	}
+
+	dir, err := env.file("sized dir/inner.txt", "1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := env.removerWith(opts).Remove(filepath.Dir(dir)); result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	got, err = os.ReadFile(sizes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields := strings.Fields(string(got)); len(fields) != 3 || fields[2] != "sized%20dir" {
+		t.Errorf("%s holds %q, want a line for sized%%20dir", sizes, got)
+	}
+
+	purgeAll(t, env, files)
+	if _, err := os.Lstat(infoPath); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("%s outlived its payload", infoPath)
+	}
+	if got, err := os.ReadFile(sizes); err != nil || len(got) != 0 {
+		t.Errorf("%s still holds %q, %v", sizes, got, err)
+	}
+	if left, err := os.ReadDir(info); err != nil || len(left) > 0 {
+		t.Errorf("info still holds %v, %v", left, err)
+	}
+}
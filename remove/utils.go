@@ -0,0 +1,296 @@
+package remove
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// In
+// ("lindsay", ["matt" "mark" "john" "lindsay"]) --> true
+func In(needle string, haystack []string) bool {
+	for _, i := range haystack {
+		if needle == i {
+			return true
+		}
+	}
+	return false
+}
+
+// FlagValue
+// ("--format", ["-v" "--format=csv"]) --> ("csv", true)
+// the last one wins when the option was given more than once
+func FlagValue(name string, flags []string) (string, bool) {
+	values := FlagValues(name, flags)
+	if len(values) == 0 {
+		return "", false
+	}
+	return values[len(values)-1], true
+}
+
+// FlagValues
+// ("--exclude", ["--exclude=a" "-v" "--exclude=b"]) --> ["a" "b"]
+// every value of a repeatable option, in the order given
+func FlagValues(name string, flags []string) []string {
+	values := []string{}
+	for _, f := range flags {
+		if value, ok := strings.CutPrefix(f, name+"="); ok {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// SEPARATORS are the characters that end a path element: / and, on
+// Windows, \ as well
+const SEPARATORS = "/" + string(filepath.Separator)
+
+// trimSeparators
+// ("logs//") --> "logs", ("C:\\logs\\") --> "C:\\logs" on Windows
+func trimSeparators(path string) string {
+	return strings.TrimRight(path, SEPARATORS)
+}
+
+// childPath
+// ("logs", "a.txt") --> "logs/a.txt", ("logs/", "a.txt") --> "logs/a.txt"
+// dir as given, so a child of ./logs is ./logs/a.txt as rm shows it, with
+// the platform's separator
+func childPath(dir string, name string) string {
+	if dir != "" && os.IsPathSeparator(dir[len(dir)-1]) {
+		return dir + name
+	}
+	return dir + string(filepath.Separator) + name
+}
+
+// IsReadOnly reports whether the file at filepath is read-only; for a
+// symlink that is the link's own attribute, never its target's
+func IsReadOnly(fsys FS, filepath string) (bool, error) {
+	attrs, err := fsys.Attributes(filepath)
+
+	if err != nil {
+		return false, err
+	}
+
+	return attrs.ReadOnly, nil
+}
+
+// AnyExists reports whether any of paths is there, dangling symlinks
+// included. Anything that can't be checked counts as there.
+func AnyExists(paths []string) bool {
+	for _, path := range paths {
+		if _, err := os.Lstat(path); !errors.Is(err, fs.ErrNotExist) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDir reports whether filepath is a directory. A symlink is never one,
+// whatever it points to or whether it points anywhere, so that it is
+// removed as itself; only a trailing slash, as always, goes through it.
+func IsDir(fsys FS, filepath string) (bool, error) {
+	fi, err := fsys.Lstat(filepath)
+
+	if err != nil {
+		return false, err
+	}
+
+	return fi.Mode().IsDir(), nil
+}
+
+// DiskUsage returns the apparent size of path, summing everything below it
+// for directories. Symlinks are counted as themselves, never followed. A
+// directory SIZECACHE has seen before isn't walked again.
+func DiskUsage(fsys FS, path string) (int64, error) {
+	size, _, err := diskUsage(fsys, path)
+	return size, err
+}
+
+// diskUsage is DiskUsage along with how many entries path holds, itself
+// included
+func diskUsage(fsys FS, path string) (int64, int, error) {
+	fi, err := fsys.Lstat(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !fi.IsDir() {
+		return fi.Size(), 1, nil
+	}
+	if size, files, ok := SIZECACHE.Lookup(fi); ok {
+		return size, files, nil
+	}
+
+	entries, err := fsys.ReadDir(path)
+	if err != nil {
+		return 0, 1, err
+	}
+
+	var total int64
+	files := 1
+	for _, entry := range entries {
+		size, n, err := diskUsage(fsys, filepath.Join(path, entry.Name()))
+		total += size
+		files += n
+		if err != nil {
+			return total, files, err
+		}
+	}
+	SIZECACHE.Store(fi, total, files)
+	return total, files, nil
+}
+
+// sizeWhatCan is DiskUsage carrying on past what can't be read, like a
+// directory without permission, whose errors it returns along with the
+// size of the rest. Symlinks are counted, not followed.
+func sizeWhatCan(fsys FS, path string) (int64, []error) {
+	fi, err := fsys.Lstat(path)
+	if err != nil {
+		return 0, []error{err}
+	}
+	if !fi.IsDir() {
+		return fi.Size(), nil
+	}
+	if size, _, ok := SIZECACHE.Lookup(fi); ok {
+		return size, nil
+	}
+	entries, err := fsys.ReadDir(path)
+	if err != nil {
+		return 0, []error{err}
+	}
+	var total int64
+	var errs []error
+	for _, entry := range entries {
+		size, more := sizeWhatCan(fsys, filepath.Join(path, entry.Name()))
+		total += size
+		errs = append(errs, more...)
+	}
+	return total, errs
+}
+
+// ForEachDirEntry calls fn with dir's entries a batch at a time, in
+// directory order, until they run out or fn returns false. Unlike
+// os.ReadDir it never holds the whole of a huge directory.
+func ForEachDirEntry(dir string, fn func(os.DirEntry) bool) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for {
+		batch, err := f.ReadDir(1024)
+		for _, de := range batch {
+			if !fn(de) {
+				return nil
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// TerminalSize returns the width and height of the terminal, falling back to
+// $COLUMNS/$LINES and then 80x24
+func TerminalSize() (int, int) {
+	width, height := ttySize()
+	if width <= 0 {
+		width, _ = strconv.Atoi(os.Getenv("COLUMNS"))
+	}
+	if height <= 0 {
+		height, _ = strconv.Atoi(os.Getenv("LINES"))
+	}
+	if width <= 0 {
+		width = 80
+	}
+	if height <= 0 {
+		height = 24
+	}
+	return width, height
+}
+
+// Truncate shortens s to at most width runes, marking the cut with "..."
+func Truncate(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width || width < 4 {
+		return s
+	}
+	return string(runes[:width-3]) + "..."
+}
+
+// EXACTSIZES is set by --bytes, making FormatSize print plain byte counts
+var EXACTSIZES = false
+
+// FormatSize
+// 1536 --> "1.5 KiB", or "1536" with --bytes
+func FormatSize(bytes int64) string {
+	if EXACTSIZES {
+		return strconv.FormatInt(bytes, 10)
+	}
+	if bytes < 1024 {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	units := []string{"KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+	value := float64(bytes) / 1024
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.1f %s", value, units[unit])
+}
+
+// sizeUnits maps lowercased size suffixes to their multiplier. A bare letter
+// means the binary unit, as it does for du and ls.
+var sizeUnits = map[string]float64{
+	"": 1, "b": 1,
+	"k": 1 << 10, "kib": 1 << 10, "kb": 1e3,
+	"m": 1 << 20, "mib": 1 << 20, "mb": 1e6,
+	"g": 1 << 30, "gib": 1 << 30, "gb": 1e9,
+	"t": 1 << 40, "tib": 1 << 40, "tb": 1e12,
+	"p": 1 << 50, "pib": 1 << 50, "pb": 1e15,
+	"e": 1 << 60, "eib": 1 << 60, "eb": 1e18,
+}
+
+// ParseSize
+// "1.5 KiB" --> 1536, "2MB" --> 2000000, "10k" --> 10240
+// Suffixes are case-insensitive, SI (kB, MB) and IEC (KiB, MiB) are both accepted.
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	split := strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= '0' && r <= '9' || r == '.')
+	})
+	number, suffix := s, ""
+	if split >= 0 {
+		number, suffix = s[:split], strings.TrimSpace(s[split:])
+	}
+
+	multiplier, ok := sizeUnits[strings.ToLower(suffix)]
+	if n, err := strconv.ParseInt(number, 10, 64); ok && multiplier == 1 && err == nil {
+		// a plain byte count, as --bytes prints, is taken exactly
+		return n, nil
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if !ok || err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q (try 500M, 1.5GiB or 2GB)", s)
+	}
+	if value*multiplier >= math.MaxInt64 {
+		return 0, fmt.Errorf("size %q is too large", s)
+	}
+	return int64(math.Round(value * multiplier)), nil
+}
+
+// IsParentRel reports whether the relative path rel climbs out of where it
+// starts
+func IsParentRel(rel string) bool {
+	return rel == ".." || len(rel) > 2 && rel[:3] == ".."+string(filepath.Separator)
+}
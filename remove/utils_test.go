@@ -0,0 +1,164 @@
+package remove
+
+import (
+	"math"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		bytes int64
+		want  string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{1<<20 - 1, "1024.0 KiB"},
+		{1 << 20, "1.0 MiB"},
+		{5<<30 + 1<<29, "5.5 GiB"},
+		{1 << 40, "1.0 TiB"},
+		{1 << 50, "1.0 PiB"},
+		{1 << 60, "1.0 EiB"},
+		{math.MaxInt64, "8.0 EiB"},
+	}
+	for _, tt := range tests {
+		if got := FormatSize(tt.bytes); got != tt.want {
+			t.Errorf("formatSize(%d) = %q, want %q", tt.bytes, got, tt.want)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int64
+	}{
+		{"0", 0},
+		{"1536", 1536},
+		{"12b", 12},
+		{"10k", 10 << 10},
+		{"10K", 10 << 10},
+		{"1.5 KiB", 1536},
+		{"1.5kib", 1536},
+		{"2MB", 2000000},
+		{"2mb", 2000000},
+		{"2 Mb", 2000000},
+		{"500M", 500 << 20},
+		{"1.5GiB", 3 << 29},
+		{"1GB", 1e9},
+		{"1tb", 1e12},
+		{"1T", 1 << 40},
+		{"1P", 1 << 50},
+		{"1pb", 1e15},
+		{"2EiB", 2 << 60},
+		{" 7 ", 7},
+		{".5k", 512},
+		{"9007199254740993", 9007199254740993},
+		{"9223372036854775807", math.MaxInt64},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.s)
+		if err != nil {
+			t.Errorf("parseSize(%q): %v", tt.s, err)
+		} else if got != tt.want {
+			t.Errorf("parseSize(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestParseSizeInvalid(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"", "invalid size"},
+		{"k", "invalid size"},
+		{"-1", "invalid size"},
+		{"1.2.3M", "invalid size"},
+		{"10 kilobytes", "invalid size"},
+		{"1xb", "invalid size"},
+		{"1e3", "invalid size"},
+		{"8EiB", "too large"},
+		{"9223372036854775808", "too large"},
+		{"100000000000000000000", "too large"},
+	}
+	for _, tt := range tests {
+		_, err := ParseSize(tt.s)
+		if err == nil {
+			t.Errorf("parseSize(%q) succeeded, want an error", tt.s)
+		} else if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseSize(%q) = %q, want it to mention %q", tt.s, err, tt.want)
+		}
+	}
+}
+
+// parseSize reads back what formatSize prints to within its one decimal,
+// and exactly under --bytes
+func TestSizeRoundTrip(t *testing.T) {
+	sizes := []int64{0, 1, 999, 1023, 1024, 1025, 1536, 10<<10 - 1, 1 << 20, 123456789, 5 << 30, 1<<40 + 12345, 3 << 50, 1 << 60, 7 << 60}
+	for shift := 0; shift < 63; shift += 3 {
+		sizes = append(sizes, 1<<shift+int64(shift)*7919)
+	}
+
+	defer func() { EXACTSIZES = false }()
+	for _, exact := range []bool{false, true} {
+		EXACTSIZES = exact
+		for _, size := range sizes {
+			text := FormatSize(size)
+			got, err := ParseSize(text)
+			if err != nil {
+				t.Errorf("parseSize(formatSize(%d) = %q): %v", size, text, err)
+				continue
+			}
+			if exact && got != size {
+				t.Errorf("with --bytes, parseSize(formatSize(%d) = %q) = %d", size, text, got)
+			}
+			// a unit's value is at least 1 so the lost decimals are 5% at most
+			if diff := math.Abs(float64(got - size)); diff > 0.05*float64(size) {
+				t.Errorf("parseSize(formatSize(%d) = %q) = %d, off by %.0f", size, text, got, diff)
+			}
+		}
+	}
+}
+
+// Paths split on / and, on Windows, \ as well, however they are mixed
+func TestSplitPaths(t *testing.T) {
+	slash := filepath.FromSlash
+	tests := []struct {
+		name      string
+		got, want string
+	}{
+		{"trailing separators", trimSeparators(slash("logs//")), "logs"},
+		{"nothing to trim", trimSeparators("logs"), "logs"},
+		{"a child", childPath("logs", "a.txt"), slash("logs/a.txt")},
+		{"a child of a dir with a separator", childPath(slash("logs/"), "a.txt"), slash("logs/a.txt")},
+		{"a child of ./", childPath(".", "a.txt"), slash("./a.txt")},
+		{"a parent", OperandParent(slash("build/obj/")), "build"},
+		{"a parent of a bare name", OperandParent("a.o"), "."},
+		{"a top level", topLevel(slash("build/obj/a.o")), slash("build/")},
+		{"a bare name's top level", topLevel("a.o"), slash("./")},
+		{"an absolute top level", topLevel(slash("/var/log/x")), slash("/var/")},
+	}
+	if runtime.GOOS == "windows" {
+		tests = append(tests, []struct {
+			name      string
+			got, want string
+		}{
+			{"mixed trailing separators", trimSeparators(`C:\logs/`), `C:\logs`},
+			{"a child of a dir ending in /", childPath(`C:\logs/`, "a.txt"), `C:\logs/a.txt`},
+			{"a parent with mixed separators", OperandParent(`C:\logs/sub\`), `C:\logs`},
+			{"a drive's top level", topLevel(`C:/Users/x`), `C:\Users\`},
+			{"a share's top level", topLevel(`\\server\share\x\y`), `\\server\share\x\`},
+			{"a base name with mixed separators", filepath.Base(`C:\logs/a.txt`), "a.txt"},
+		}...)
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
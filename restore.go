@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// findRestoreTarget finds what query names in the trash: whatever
+// resolveEntries matches in the index, the most recently trashed when it
+// matches several, and otherwise a plain name in trashDir
+func findRestoreTarget(entries []remove.IndexEntry, trashDir string, query string) (remove.RestoreTarget, error) {
+	matches := resolveEntries(entries, query)
+	if len(matches) > 0 {
+		sort.SliceStable(matches, func(i, j int) bool { return matches[i].Deleted.After(matches[j].Deleted) })
+		return remove.RestoreTarget{Entry: matches[0], Known: true, Generations: len(matches)}, nil
+	}
+
+	if trashDir != "" {
+		name := filepath.Base(query)
+		if _, err := os.Lstat(filepath.Join(trashDir, name)); err == nil {
+			return remove.RestoreTarget{Entry: remove.IndexEntry{Trash: trashDir, Name: name}, Generations: 1}, nil
+		}
+	}
+	return remove.RestoreTarget{}, fmt.Errorf("%s: %w", remove.DisplayPath(query), remove.ErrNotInTrash)
+}
+
+// restoreDest is where target goes back to: its recorded origin, or the
+// current directory under its trash name when srm has no record of it
+func restoreDest(target remove.RestoreTarget) (string, error) {
+	if target.Known && target.Entry.Origin != "" {
+		return target.Entry.Origin, nil
+	}
+	return filepath.Abs(strings.TrimSuffix(target.Entry.Name, "."+remove.ARCHIVEFORMAT))
+}
+
+// reasonNote is what -v adds to a restored entry's line: the --reason it
+// was trashed with, if any
+func reasonNote(entry remove.IndexEntry) string {
+	if reason := entry.ReasonText(); reason != "" {
+		return " (reason: " + remove.DisplayName(reason) + ")"
+	}
+	return ""
+}
+
+// restoreOperands
+// srm -W <entry ...>
+// moves each named trash entry back where it came from, see
+// findRestoreTarget and restoreDest. Something already at the destination
+// stops the restore unless force, which trashes it first with r so nothing
+// is lost; r is nil when there is no trash. Both are recorded in journal.
+// A directory entry whose origin is a directory again is merged into it
+// instead, by merge, one of MERGEPOLICIES, or as asked when merge is ""
+// and there is someone to ask, see mergeEntry. jsonOut prints each
+// operand's MergeAnalysis and changes nothing. Under dryRun only what would
+// happen is printed. Returns whether everything named was restored.
+func restoreOperands(r *remove.Remover, index *remove.Index, journal *Journal, trashDir string, operands []string, force bool, verbose bool, dryRun bool, merge string, jsonOut bool) bool {
+	entries := []remove.IndexEntry{}
+	if index != nil {
+		var err error
+		if entries, err = index.Entries(); err != nil {
+			fmt.Fprintf(os.Stderr, "srm: %s\n", err)
+			return false
+		}
+	}
+
+	ok := true
+	fail := func(err error) {
+		fmt.Fprintf(os.Stderr, "srm: %s\n", remove.DisplayName(err.Error()))
+		ok = false
+	}
+	for _, query := range operands {
+		target, err := findRestoreTarget(entries, trashDir, query)
+		if err != nil {
+			fail(err)
+			continue
+		}
+		dest, err := restoreDest(target)
+		if err != nil {
+			fail(err)
+			continue
+		}
+		older := ""
+		if target.Generations > 1 {
+			older = fmt.Sprintf(" (the newest of %d, use an entry ID for another)", target.Generations)
+		}
+
+		if jsonOut {
+			analysis, err := analyzeMerge(remove.OSFS{}, target, dest)
+			if err == nil {
+				err = printMergeAnalysis(analysis)
+			}
+			if err != nil {
+				fail(err)
+			}
+			continue
+		}
+
+		fi, err := os.Lstat(dest)
+		occupied := err == nil
+		if occupied && !force && fi.IsDir() && target.Entry.IsDir && target.Entry.Archive == "" && (merge != "" || remove.CanAsk()) {
+			if !restoreMerged(r, index, journal, target, dest, merge, verbose, dryRun) {
+				ok = false
+			}
+			continue
+		}
+		if occupied && !force {
+			fail(fmt.Errorf("%s: %w", remove.DisplayPath(dest), remove.ErrDestExists))
+			continue
+		}
+		if dryRun {
+			if occupied {
+				fmt.Printf("would trash %s\n", remove.DisplayName(remove.DisplayPath(dest)))
+			}
+			fmt.Printf("would restore %s -> %s%s\n", remove.DisplayName(target.Entry.Payload()), remove.DisplayName(remove.DisplayPath(dest)), older)
+			continue
+		}
+
+		if occupied {
+			if r == nil {
+				fail(fmt.Errorf("%s: %w to move it to", remove.DisplayPath(dest), remove.ErrTrashUnavailable))
+				continue
+			}
+			result := r.Remove(dest)
+			journal.Record(result)
+			if result.Err != nil {
+				fail(result.Err)
+				continue
+			}
+		}
+		if err := remove.RestoreEntry(remove.OSFS{}, target, dest); err != nil {
+			fail(err)
+			continue
+		}
+		journal.Record(remove.Result{Action: "restored", Source: target.Entry.Payload(), Dest: dest, Bytes: target.Entry.Size, IsDir: target.Entry.IsDir})
+		if err := remove.RemoveTrashInfo(target.Entry.Payload()); err != nil {
+			fmt.Fprintf(os.Stderr, "srm: warning: trash info: %s\n", err)
+		}
+		if target.Known && index != nil {
+			if err := index.Forget(target.Entry); err != nil {
+				fmt.Fprintf(os.Stderr, "srm: warning: index: %s\n", err)
+			}
+		}
+		if verbose {
+			fmt.Printf("restored %s%s%s\n", remove.DisplayName(remove.DisplayPath(dest)), older, reasonNote(target.Entry))
+		}
+	}
+	return ok
+}
+
+// restoreMerged merges target into the directory dest by merge, asking
+// which way when it is "", and forgets the entry once nothing of it is left
+// in the trash. Returns whether it was merged.
+func restoreMerged(r *remove.Remover, index *remove.Index, journal *Journal, target remove.RestoreTarget, dest string, merge string, verbose bool, dryRun bool) bool {
+	analysis, err := analyzeMerge(remove.OSFS{}, target, dest)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm: %s\n", remove.DisplayName(err.Error()))
+		return false
+	}
+	if merge == "" {
+		if merge = askMergePolicy(analysis); merge == "" {
+			fmt.Fprintf(os.Stderr, "srm: %s\n", remove.DisplayName(fmt.Errorf("%s: %w", remove.DisplayPath(dest), remove.ErrDestExists).Error()))
+			return false
+		}
+	} else if dryRun || verbose {
+		fmt.Println(analysis.Summary())
+	}
+
+	restored, left, err := mergeEntry(r, remove.OSFS{}, journal, analysis, merge, dryRun)
+	if !dryRun && (restored > 0 || (err == nil && left == 0)) {
+		journal.Record(remove.Result{Action: "restored", Source: target.Entry.Payload(), Dest: dest, Bytes: restored, IsDir: true})
+	}
+	switch {
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "srm: %s\n", remove.DisplayName(err.Error()))
+		return false
+	case dryRun:
+		return true
+	case left > 0:
+		fmt.Printf("%s: %d conflicting left in the trash as %s\n", remove.DisplayName(remove.DisplayPath(dest)), left, remove.DisplayName(target.Entry.Payload()))
+		return true
+	}
+	if err := remove.RemoveTrashInfo(target.Entry.Payload()); err != nil {
+		fmt.Fprintf(os.Stderr, "srm: warning: trash info: %s\n", err)
+	}
+	if target.Known && index != nil {
+		if err := index.Forget(target.Entry); err != nil {
+			fmt.Fprintf(os.Stderr, "srm: warning: index: %s\n", err)
+		}
+	}
+	if verbose {
+		fmt.Printf("merged %s%s\n", remove.DisplayName(remove.DisplayPath(dest)), reasonNote(target.Entry))
+	}
+	return true
+}
+
+// restoreCommand is srm -W: operands name trash entries rather than files,
+// and whatever -f would replace goes to the trash like any removal, as does
+// what --merge replaces
+func restoreCommand(operands []string, opts remove.Options, verbose bool, dryRun bool, merge string, jsonOut bool) {
+	trashDir, _ := remove.ChooseTrashDir(opts.PreferTrash)
+	index, err := remove.OpenIndex()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm: warning: index: %s\n", err)
+		index = nil
+	}
+
+	// an unopened journal records nothing
+	opts.Op = newOpID()
+	journal := &Journal{}
+	if !dryRun && !jsonOut {
+		journal = openJournal(opts.Op, os.Args)
+	}
+
+	var r *remove.Remover
+	if trashDir != "" && (opts.Force || merge != "" || remove.CanAsk()) && !dryRun && !jsonOut {
+		opts.Recursive = true
+		opts.TrashDir = trashDir
+		opts.ResolveTrash = true
+		opts.Index = index
+		r = remove.NewRemover(opts)
+	}
+	ok := restoreOperands(r, index, journal, trashDir, operands, opts.Force, verbose, dryRun, merge, jsonOut)
+	if r != nil {
+		r.Close()
+	}
+	journal.Close()
+	if !ok || journal.Refused() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// stdout runs fn and returns what it printed
+func stdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = old }()
+	out := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- string(b)
+	}()
+	fn()
+	w.Close()
+	return <-out
+}
+
+// scratchTrash is a work tree, a trash and an index for it, with a -r
+// Remover trashing into them
+func scratchTrash(t *testing.T) (work, trash string, index *remove.Index, r *remove.Remover) {
+	t.Helper()
+	dir := t.TempDir()
+	work, trash = filepath.Join(dir, "work"), filepath.Join(dir, "trash")
+	for _, d := range []string{work, trash} {
+		if err := os.Mkdir(d, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	index = remove.NewIndex(filepath.Join(dir, "index"))
+	r = remove.NewRemover(remove.Options{Recursive: true, TrashDir: trash, Index: index, Op: "test"})
+	t.Cleanup(func() { r.Close() })
+	return work, trash, index, r
+}
+
+// writeFiles creates each of files under dir, with its contents
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// A trashed file and an archived directory are found by their origin and
+// put back whole
+func TestRestoreEntries(t *testing.T) {
+	work, trash, index, r := scratchTrash(t)
+	writeFiles(t, work, map[string]string{"file.txt": "restore me", "dir/sub/file.txt": "unpack me"})
+	file, dir := filepath.Join(work, "file.txt"), filepath.Join(work, "dir")
+	if result := r.Remove(file); result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	archiver := remove.NewRemover(remove.Options{Recursive: true, Archive: true, TrashDir: trash, Index: index, Op: "test"})
+	defer archiver.Close()
+	if result := archiver.Remove(dir); result.Err != nil || result.Strategy != "archive" {
+		t.Fatalf("%s by %s, %v", result.Status(), result.Strategy, result.Err)
+	}
+
+	entries, err := index.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{file, dir} {
+		target, err := findRestoreTarget(entries, trash, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remove.RestoreEntry(remove.OSFS{}, target, path); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Lstat(target.Entry.Payload()); err == nil {
+			t.Errorf("%s is still in the trash", target.Entry.Name)
+		}
+	}
+	for path, content := range map[string]string{file: "restore me", filepath.Join(dir, "sub", "file.txt"): "unpack me"} {
+		if got, err := os.ReadFile(path); err != nil || string(got) != content {
+			t.Errorf("%s came back holding %q, %v", path, got, err)
+		}
+	}
+}
+
+// -v -W says what each entry was trashed with --reason for
+func TestRestoreReason(t *testing.T) {
+	dir := t.TempDir()
+	trash, work := filepath.Join(dir, "trash"), filepath.Join(dir, "work")
+	for _, d := range []string{trash, work} {
+		if err := os.Mkdir(d, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	index := remove.NewIndex(filepath.Join(dir, "index"))
+	for _, entry := range []remove.IndexEntry{
+		{ID: "a1", Trash: trash, Name: "a", Origin: filepath.Join(work, "a"), Reason: url.PathEscape("old 50% draft")},
+		{ID: "b1", Trash: trash, Name: "b", Origin: filepath.Join(work, "b")},
+	} {
+		if err := os.WriteFile(entry.Payload(), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := index.Append(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var ok bool
+	out := stdout(t, func() {
+		ok = restoreOperands(nil, index, &Journal{}, trash, []string{"a1", "b1"}, false, true, false, "", false)
+	})
+	if !ok {
+		t.Fatal("restore failed")
+	}
+	want := "restored " + filepath.Join(work, "a") + " (reason: old 50% draft)\n" +
+		"restored " + filepath.Join(work, "b") + "\n"
+	if out != want {
+		t.Errorf("printed %q, want %q", out, want)
+	}
+	for _, name := range []string{"a", "b"} {
+		if _, err := os.Lstat(filepath.Join(work, name)); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// SANDBOXFILES are files outside the writable directories that a run may
+// still open for writing, like the terminal a prompt is asked on
+var SANDBOXFILES = []string{"/dev/null", "/dev/tty"}
+
+// sandboxEnabled reads sandbox from the config, false when unset
+func sandboxEnabled() (bool, error) {
+	settings, err := remove.LoadSettings()
+	if err != nil {
+		return false, err
+	}
+	return settings.Config.Bool("sandbox")
+}
+
+// sandboxDirs are the directories a run removing operands into trashes
+// writes to: each operand's parent, each trash and, for a freedesktop.org
+// trash, the directory holding its info directory and directorysizes, and
+// srm's data directory, which holds the journal, the index, the intent logs
+// and the size cache. Parents are resolved through
+// symlinks as the kernel resolves them, an operand itself never is, since
+// a symlink operand is removed as itself. Directories that aren't there
+// are left out: nothing is created in them by a removal.
+func sandboxDirs(operands []string, trashes []string) []string {
+	seen := map[string]bool{}
+	dirs := []string{}
+	add := func(dir string) {
+		if dir == "" {
+			return
+		}
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			dir = resolved
+		}
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() || seen[dir] {
+			return
+		}
+		seen[dir] = true
+		dirs = append(dirs, dir)
+	}
+
+	for _, operand := range operands {
+		add(remove.OperandParent(operand))
+	}
+	for _, trash := range trashes {
+		add(trash)
+		if info := remove.SpecInfoDir(trash); info != "" {
+			add(filepath.Dir(info))
+		}
+	}
+	if dir, err := remove.DataDir(); err == nil {
+		add(dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// runTrashes are the trashes a run might move operands to: the run's own,
+// and every candidate of every operand, since any of them can be picked
+func runTrashes(operands []string, targetDir string, prefer []string) []string {
+	trashes := []string{targetDir}
+	for _, operand := range operands {
+		for _, candidate := range remove.ResolveTrash(remove.CurrentTrashContext(operand, prefer)) {
+			trashes = append(trashes, candidate.Dir)
+		}
+	}
+	return trashes
+}
+
+// sandboxRun confines the rest of the run to writing in sandboxDirs, for
+// sandbox = true, so that a mistake in srm's path handling can't reach
+// anything else. Reading is never restricted. It says on stderr when it
+// can't, and the run goes on without. backendDir is where --backend
+// stores operands, "" for the trash.
+func sandboxRun(operands []string, targetDir string, prefer []string, backendDir string) {
+	dirs := sandboxDirs(operands, append(runTrashes(operands, targetDir, prefer), backendDir))
+	if err := engageSandbox(dirs, SANDBOXFILES); err != nil {
+		fmt.Fprintf(os.Stderr, "srm: warning: sandbox = true, but running without one: %s\n", err)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// landlock(7)'s system calls and flags, which the syscall package predates
+const (
+	sysLandlockCreateRuleset = 444
+	sysLandlockAddRule       = 445
+	sysLandlockRestrictSelf  = 446
+
+	landlockCreateRulesetVersion = 1 << 0
+	landlockRulePathBeneath      = 1
+
+	prSetNoNewPrivs = 38
+)
+
+// landlock's filesystem access rights that modify something; reading and
+// executing aren't handled, and so stay allowed everywhere
+const (
+	landlockWriteFile  = 1 << 1
+	landlockRemoveDir  = 1 << 4
+	landlockRemoveFile = 1 << 5
+	landlockMakeChar   = 1 << 6
+	landlockMakeDir    = 1 << 7
+	landlockMakeReg    = 1 << 8
+	landlockMakeSock   = 1 << 9
+	landlockMakeFifo   = 1 << 10
+	landlockMakeBlock  = 1 << 11
+	landlockMakeSym    = 1 << 12
+	// landlockRefer (ABI 2) allows renaming between directories, without
+	// which nothing could be moved into a trash
+	landlockRefer = 1 << 13
+	// landlockTruncate is ABI 3
+	landlockTruncate = 1 << 14
+)
+
+type landlockRulesetAttr struct {
+	handledAccessFS uint64
+}
+
+// landlockPathBeneathAttr is packed in the kernel, 12 bytes; Go's padding
+// only comes after them
+type landlockPathBeneathAttr struct {
+	allowedAccess uint64
+	parentFd      int32
+}
+
+// landlockABI is the landlock version the kernel has, 0 for none
+func landlockABI() int {
+	abi, _, errno := syscall.Syscall(sysLandlockCreateRuleset, 0, 0, landlockCreateRulesetVersion)
+	if errno != 0 {
+		return 0
+	}
+	return int(abi)
+}
+
+// sandboxStatus says whether engageSandbox can work here, for doctor
+func sandboxStatus() string {
+	abi := landlockABI()
+	switch {
+	case abi == 0:
+		return "unavailable (this kernel has no landlock)"
+	case abi < 2:
+		return fmt.Sprintf("unavailable (landlock ABI %d, renaming into a trash needs 2)", abi)
+	}
+	return fmt.Sprintf("available (landlock ABI %d)", abi)
+}
+
+// engageSandbox restricts every thread of the process, and whatever it
+// runs, to modifying things beneath dirs and to writing files, which may
+// only be opened for writing. It can't be undone. Landlock needs ABI 2, and
+// a binary without cgo, whose threads Go can't all reach.
+func engageSandbox(dirs []string, files []string) error {
+	abi := landlockABI()
+	if abi < 2 {
+		return fmt.Errorf("%w: %s", remove.ErrNoSandbox, sandboxStatus())
+	}
+	handled := uint64(landlockWriteFile | landlockRemoveDir | landlockRemoveFile | landlockMakeChar |
+		landlockMakeDir | landlockMakeReg | landlockMakeSock | landlockMakeFifo | landlockMakeBlock |
+		landlockMakeSym | landlockRefer)
+	fileAccess := uint64(landlockWriteFile)
+	if abi >= 3 {
+		handled |= landlockTruncate
+		fileAccess |= landlockTruncate
+	}
+
+	attr := landlockRulesetAttr{handledAccessFS: handled}
+	fd, _, errno := syscall.Syscall(sysLandlockCreateRuleset, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("landlock_create_ruleset: %w", errno)
+	}
+	ruleset := int(fd)
+	defer syscall.Close(ruleset)
+
+	allow := func(path string, access uint64) error {
+		pathFd, err := syscall.Open(path, remove.OPath|syscall.O_CLOEXEC, 0)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		defer syscall.Close(pathFd)
+		rule := landlockPathBeneathAttr{allowedAccess: access, parentFd: int32(pathFd)}
+		if _, _, errno := syscall.Syscall6(sysLandlockAddRule, uintptr(ruleset), landlockRulePathBeneath, uintptr(unsafe.Pointer(&rule)), 0, 0, 0); errno != 0 {
+			return fmt.Errorf("%s: landlock_add_rule: %w", path, errno)
+		}
+		return nil
+	}
+	for _, dir := range dirs {
+		if err := allow(dir, handled); err != nil {
+			return err
+		}
+	}
+	for _, file := range files {
+		// a file that isn't there can't be written to anyway
+		if err := allow(file, fileAccess); err != nil && !remove.IsMissing(err) {
+			return err
+		}
+	}
+
+	if _, _, errno := syscall.AllThreadsSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		if errno == syscall.ENOTSUP {
+			return fmt.Errorf("%w: srm was built with cgo, build it with CGO_ENABLED=0", remove.ErrNoSandbox)
+		}
+		return fmt.Errorf("prctl: %w", errno)
+	}
+	if _, _, errno := syscall.AllThreadsSyscall(sysLandlockRestrictSelf, uintptr(ruleset), 0, 0); errno != 0 {
+		return fmt.Errorf("landlock_restrict_self: %w", errno)
+	}
+	return nil
+}
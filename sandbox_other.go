@@ -0,0 +1,17 @@
+//go:build !linux
+
+package main
+
+import (
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// sandboxStatus says whether engageSandbox can work here, for doctor
+func sandboxStatus() string {
+	return "unavailable (landlock is Linux only)"
+}
+
+// engageSandbox has nothing to restrict the process with outside Linux
+func engageSandbox(dirs []string, files []string) error {
+	return remove.ErrNoSandbox
+}
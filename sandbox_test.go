@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// Under the sandbox a run writes where it was let and nowhere else. It
+// stays on for the rest of the process, so it is engaged in a child.
+func TestEngageSandbox(t *testing.T) {
+	if dirs, ok := os.LookupEnv("SRM_TEST_SANDBOX"); ok {
+		inside, outside, _ := strings.Cut(dirs, string(os.PathListSeparator))
+		if err := engageSandbox([]string{inside}, SANDBOXFILES); errors.Is(err, remove.ErrNoSandbox) {
+			fmt.Print("skip: ", err)
+			os.Exit(0)
+		} else if err != nil {
+			fmt.Print(err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(filepath.Join(inside, "inside"), []byte("x"), 0600); err != nil {
+			fmt.Print("writing inside: ", err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(filepath.Join(outside, "outside"), []byte("x"), 0600); !errors.Is(err, syscall.EACCES) {
+			fmt.Printf("writing outside: got %v, want permission denied", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	inside, outside := t.TempDir(), t.TempDir()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestEngageSandbox$")
+	cmd.Env = append(os.Environ(), "SRM_TEST_SANDBOX="+inside+string(os.PathListSeparator)+outside)
+	out, err := cmd.Output()
+	if reason, ok := strings.CutPrefix(string(out), "skip: "); ok {
+		t.Skip(reason)
+	}
+	if err != nil {
+		t.Errorf("%s (%v)", out, err)
+	}
+	if _, err := os.Stat(filepath.Join(inside, "inside")); err != nil {
+		t.Error(err)
+	}
+}
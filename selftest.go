@@ -0,0 +1,209 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// errSelftestSkip marks a check that can't run in this build or on this
+// machine; it is reported as SKIP, not as a failure
+var errSelftestSkip = errors.New("not checked")
+
+// selftestEnv is the scratch directory the checks run in: a work tree, a
+// trash and srm's own records, nothing of them outside root, and the one
+// Remover trashing into them
+type selftestEnv struct {
+	root    string
+	work    string
+	trash   string
+	index   *remove.Index
+	intents *remove.IntentLog
+	remover *remove.Remover
+	// trashed are the operands moved into the trash, to restore
+	trashed []string
+}
+
+// selftestChecks run in order against one scratch directory, later ones
+// relying on what earlier ones left in the trash. They are a smoke check
+// that this build works on this machine; the behaviour itself is covered
+// by the Go tests.
+var selftestChecks = []struct {
+	name string
+	run  func(env *selftestEnv) error
+}{
+	{"trash a file and a directory", func(env *selftestEnv) error {
+		for name, content := range map[string]string{"file.txt": "file", "dir/sub/file.txt": "nested"} {
+			path := filepath.Join(env.work, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				return err
+			}
+		}
+		for _, name := range []string{"file.txt", "dir"} {
+			path := filepath.Join(env.work, name)
+			if result := env.remover.Remove(path); result.Err != nil {
+				return result.Err
+			}
+			if _, err := os.Lstat(path); !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("%s is still there", path)
+			}
+			env.trashed = append(env.trashed, path)
+		}
+		return nil
+	}},
+	{"settle every move in the intent log", func(env *selftestEnv) error {
+		pending, err := env.intents.Pending()
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%d intents never got a done", len(pending))
+		}
+		return nil
+	}},
+	{"restore what was trashed", func(env *selftestEnv) error {
+		entries, err := env.index.Entries()
+		if err != nil {
+			return err
+		}
+		for _, path := range env.trashed {
+			target, err := findRestoreTarget(entries, env.trash, path)
+			if err != nil {
+				return err
+			}
+			if err := remove.RestoreEntry(remove.OSFS{}, target, path); err != nil {
+				return err
+			}
+			if err := env.index.Forget(target.Entry); err != nil {
+				return err
+			}
+		}
+		got, err := os.ReadFile(filepath.Join(env.work, "dir", "sub", "file.txt"))
+		if err != nil {
+			return err
+		}
+		if string(got) != "nested" {
+			return fmt.Errorf("dir came back with different contents")
+		}
+		return nil
+	}},
+	{"empty the trash", func(env *selftestEnv) error {
+		if result := env.remover.Remove(filepath.Join(env.work, "dir")); result.Err != nil {
+			return result.Err
+		}
+		candidates, err := emptyCandidates(env.index, env.trash)
+		if err != nil {
+			return err
+		}
+		for _, c := range candidates {
+			if result := remove.PurgeCandidate(remove.OSFS{}, env.index, c, false); result.Err != nil {
+				return result.Err
+			}
+		}
+		if left, err := os.ReadDir(env.trash); err != nil || len(left) > 0 {
+			return fmt.Errorf("%d entries left in the trash (%v)", len(left), err)
+		}
+		if entries, err := env.index.Entries(); err != nil || len(entries) > 0 {
+			return fmt.Errorf("%d index rows left (%v)", len(entries), err)
+		}
+		return nil
+	}},
+	// last, as the sandbox stays on for the rest of the process
+	{"confine writes to the scratch directory under sandbox = true", func(env *selftestEnv) error {
+		outside, err := os.MkdirTemp("", "srm-selftest-outside-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(outside)
+		if err := engageSandbox([]string{env.root}, SANDBOXFILES); errors.Is(err, remove.ErrNoSandbox) {
+			return fmt.Errorf("%w: %v", errSelftestSkip, err)
+		} else if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(env.work, "inside"), []byte("x"), 0600); err != nil {
+			return fmt.Errorf("writing inside: %w", err)
+		}
+		err = os.WriteFile(filepath.Join(outside, "outside"), []byte("x"), 0600)
+		if !errors.Is(err, syscall.EACCES) {
+			return fmt.Errorf("writing outside: got %v, want permission denied", err)
+		}
+		return nil
+	}},
+}
+
+// selftestCommand
+// srm selftest
+// runs a removal, a restore and an empty end to end against a scratch
+// trash in a new temporary directory and prints PASS, FAIL or SKIP per
+// check. The user's trash, index and journal are never touched. Exits 1
+// when any check fails, keeping the scratch directory to look at.
+func selftestCommand(args []string) {
+	_, rest := parseArgs(args)
+	if len(rest) > 0 {
+		fmt.Fprintf(os.Stderr, "srm selftest: unexpected argument %s\n", rest[0])
+		os.Exit(1)
+	}
+
+	root, err := os.MkdirTemp("", "srm-selftest-")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm selftest: %s\n", err)
+		os.Exit(1)
+	}
+	env := &selftestEnv{
+		root:    root,
+		work:    filepath.Join(root, "work"),
+		trash:   filepath.Join(root, "trash"),
+		index:   remove.NewIndex(filepath.Join(root, "data", "index")),
+		intents: remove.NewIntentLog(filepath.Join(root, "data", "intents")),
+	}
+	for _, dir := range []string{env.work, env.trash} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			fmt.Fprintf(os.Stderr, "srm selftest: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	env.remover = remove.NewRemover(remove.Options{
+		Recursive: true,
+		TrashDir:  env.trash,
+		Index:     env.index,
+		Intents:   env.intents,
+		Op:        "selftest",
+		Callbacks: remove.Callbacks{
+			OnPrompt: func(req remove.PromptRequest) (string, error) {
+				return "", fmt.Errorf("unexpected prompt: %s", req.Message)
+			},
+		},
+	})
+
+	passed, failed, skipped := 0, 0, 0
+	for _, check := range selftestChecks {
+		err := check.run(env)
+		switch {
+		case err == nil:
+			passed++
+			fmt.Printf("PASS %s\n", check.name)
+		case errors.Is(err, errSelftestSkip):
+			skipped++
+			fmt.Printf("SKIP %s (%s)\n", check.name, err)
+		default:
+			failed++
+			fmt.Printf("FAIL %s: %s\n", check.name, remove.DisplayName(err.Error()))
+		}
+	}
+	fmt.Printf("%d passed, %d failed, %d skipped\n", passed, failed, skipped)
+	env.remover.Close()
+
+	if failed > 0 {
+		fmt.Printf("scratch directory kept at %s\n", root)
+		os.Exit(1)
+	}
+	os.RemoveAll(root)
+}
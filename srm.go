@@ -1,9 +1,14 @@
 package main
 
 import (
+    "errors"
     "fmt"
     "os"
+    "path/filepath"
+    "slices"
     "strings"
+
+    "github.com/shanahanjrs/srm/remove"
 )
 
 // Checklist
@@ -16,197 +21,1377 @@ import (
 //             previous -f options.
 // [X] -I      Request confirmation once if more than three files are being removed or if a directory is being recursively removed.  This is a far less intrusive option than -i yet provides almost the same
 //             level of protection against mistakes.
-// [X] -P      This flag has no effect.  It is kept only for backwards compatibility with 4.4BSD-Lite2.
+// [X] -P      Overwrite regular files before deleting them.  Files are overwritten three times, first with the byte pattern 0xff, then 0x00, and then 0xff again, before they are deleted.
+//             srm overwrites with zeros (-PP: random data, then zeros) and deletes for real rather than trashing, see --permanent.
 // [X] -R      Attempt to remove the file hierarchy rooted in each file argument.  The -R option implies the -d option.  If the -i option is specified, the user is prompted for confirmation before each
 //             directory's contents are processed (as well as before the attempt is made to remove the directory).  If the user does not respond affirmatively, the file hierarchy rooted in that directory is
 //             skipped.
 // [X] -r      Equivalent to -R.
 // [X] -v      Be verbose when deleting files, showing them as they are removed.
-// [ ] -W      Attempt to undelete the named files.  Currently, this option can only be used to recover files covered by whiteouts in a union file system (see undelete(2)).
-// [ ] -x      When removing a hierarchy, do not cross mount points.
-// [ ] --      Makes all args after the double dash filenames (would be required to delete a file literally named "-i" for example)
-// [ ] rename file if it already exists in destination
-
-var VALIDARGS = []string{
-    "-h",
-    "--help",
-    "-P",
-    "-f",
-    "-i",
-    "-I",
-    "-r",
-    "-R",
-    "-d",
-    "-v",
+// [X] -W      Attempt to undelete the named files.  Currently, this option can only be used to recover files covered by whiteouts in a union file system (see undelete(2)).
+// [X] -x      When removing a hierarchy, do not cross mount points.
+// [X] --      Makes all args after the double dash filenames (would be required to delete a file literally named "-i" for example)
+// [X] rename file if it already exists in destination
+
+// OPTIONS is every option srm knows, and the one place parsing, validation,
+// the Options section of usage and srm completion read them from.
+// Command is the subcommand an option belongs to, empty for removal.
+var OPTIONS = []Option{
+    {Name: "--help", Aliases: []string{"-h"}, Global: true, Help: "show this help"},
+    {Name: "-P", Help: "overwrite each file with zeros, then delete it for real (implies -D); -PP adds a random pass"},
+    {Name: "-f", Also: []string{"empty", "purge"}, Help: "never prompt, ignore what can't be removed quietly; -ff skips srm's own questions too"},
+    {Name: "--force", Value: OptionalValue, Arg: "LEVEL", Also: []string{"empty", "purge"}, Help: "-f, or with =2 -ff"},
+    {Name: "-i", Help: "prompt before every removal (u undoes the last, a aborts the run)"},
+    {Name: "--interactive", Value: OptionalValue, Arg: "WHEN", Help: "-i, or with =once -I, and with =never neither"},
+    {Name: "-I", Help: "prompt once before removing more than three operands, or recursively"},
+    {Name: "-r", Aliases: []string{"-R", "--recursive"}, Help: "remove directories and their contents"},
+    {Name: "-d", Aliases: []string{"--directory"}, Help: "remove empty directories"},
+    {Name: "--permanent", Aliases: []string{"-D"}, Help: "delete for real instead of trashing, asking about each operand unless -f"},
+    {Name: "-v", Aliases: []string{"--verbose"}, Also: []string{"empty"}, Help: "print each operand and where it went as it is removed"},
+    {Name: "--trash-dir", Value: RequiredValue, Arg: "DIR", Global: true, Help: "use DIR as the trash, creating it if need be"},
+    {Name: "--preserve-root", Help: "refuse to remove / (the default)"},
+    {Name: "--no-preserve-root", Help: "don't treat / specially"},
+    {Name: "-x", Aliases: []string{"--one-file-system"}, Help: "with -r, leave anything mounted inside a directory where it is"},
+    {Name: "--fast", Help: "skip the optional checks (exec, overlay, size), for huge batches"},
+    {Name: "--time", Help: "say how long the optional checks took, as -vv does"},
+    {Name: "--verify", Help: "read back what is copied into the trash from another filesystem before removing the original"},
+    {Name: "-W", Help: "restore the named entries from the trash instead of removing anything"},
+    {Name: "--merge", Value: RequiredValue, Arg: "POLICY", Help: "with -W, merge a directory into the one at its origin: missing, trash, disk or review"},
+    {Name: "-vv", Also: []string{"empty"}, Help: "-v with the policy and overlay notes that applied, and check times"},
+    {Name: "--quiet", Help: "don't print the operation ID at the end"},
+    {Name: "--keep-hidden", Value: OptionalValue, Arg: "DEPTH", Help: "with -r, keep dotfiles and the directory (=DEPTH looks deeper)"},
+    {Name: "--hidden-only", Value: OptionalValue, Arg: "DEPTH", Help: "with -r, remove only dotfiles (=DEPTH looks deeper)"},
+    {Name: "--archive", Help: "trash directories as a single tarball"},
+    {Name: "--check-exec", Help: "warn about files running processes have mapped"},
+    {Name: "--bytes", Global: true, Help: "print sizes as exact byte counts"},
+    {Name: "--no-size-cache", Global: true, Help: "walk directories for their size instead of trusting the size cache"},
+    {Name: "--abs", Global: true, Help: "show paths absolute"},
+    {Name: "--relative-to", Value: RequiredValue, Arg: "DIR", Global: true, Help: "show paths relative to DIR"},
+    {Name: "--format", Value: RequiredValue, Arg: "TEMPLATE", Also: []string{"list"}, Help: "print each entry with a template or preset"},
+    {Name: "--biggest-first", Help: "with -r, remove directory contents one by one, biggest first"},
+    {Name: "--batch-stdin", Help: "remove the NUL-terminated paths read from stdin as they arrive"},
+    {Name: "--sort-operands", Value: RequiredValue, Arg: "ORDER", Help: "none, path or size (biggest first)"},
+    {Name: "--backend", Value: RequiredValue, Arg: "NAME", Help: "store operands in the backend[NAME] from the config instead of the trash"},
+    {Name: "--prefer-trash", Value: RequiredValue, Arg: "WHERE", Repeatable: true, Help: "home, volume or DIR to try first, repeatable"},
+    {Name: "--on-no-trash", Value: RequiredValue, Arg: "POLICY", Help: "fail, permanent or tmp when no trash is usable"},
+    {Name: "--confirm-size", Value: RequiredValue, Arg: "SIZE", Help: "ask before removing an operand bigger than SIZE, like 10G"},
+    {Name: "--reason", Value: RequiredValue, Arg: "TEXT", Sensitive: true, Also: []string{"search"}, Help: "note recorded with every trashed entry"},
+    {Name: "--posix", Help: "behave like rm in everything but trashing: its messages, prompts and -f/-i precedence"},
+    {Name: "--dry-run", Also: []string{"empty"}, Help: "show what would be done and asked, changing nothing (srm empty too)"},
+    {Name: "--empty", Help: "srm empty: permanently delete what is in the trash, taking its options"},
+    {Name: "--list", Help: "srm list --format=full --sort=deleted: what is in the trash, newest first"},
+    {Command: "list", Name: "--tree", Help: "show what is inside directories and archives"},
+    {Command: "list", Name: "--columns", Value: RequiredValue, Arg: "COLS", Help: "comma separated columns to show"},
+    {Command: "list", Name: "--when", Value: RequiredValue, Arg: "WHEN", Also: []string{"search"}, Help: "only entries deleted WHEN"},
+    {Command: "list", Name: "--sort", Value: RequiredValue, Arg: "KEY", Help: "name, deleted or size"},
+    {Command: "list", Name: "--limit", Value: RequiredValue, Arg: "N", Help: "show at most N entries"},
+    {Command: "history", Name: "--failed-only", Help: "only operations where something failed"},
+    {Command: "history", Name: "--path", Value: RequiredValue, Arg: "SUBSTR", Help: "only operations touching paths containing SUBSTR"},
+    {Command: "history", Name: "--since", Value: RequiredValue, Arg: "WHEN", Help: "only operations since WHEN"},
+    {Command: "export", Name: "--output", Aliases: []string{"-o"}, Value: RequiredValue, Arg: "FILE", Help: "bundle file to write"},
+    {Command: "stats", Name: "--days", Value: RequiredValue, Arg: "N", Help: "show the last N days, 30 by default"},
+    {Command: "stats", Name: "--json", Also: []string{""}, Help: "print a JSON object per day instead of the table"},
+    {Command: "maintain", Name: "--install-timer", Help: "run maintenance daily"},
+    {Command: "maintain", Name: "--uninstall", Help: "remove the maintenance timer"},
+    {Command: "empty", Name: "--older-than", Value: RequiredValue, Arg: "AGE", Help: "only entries deleted longer than AGE ago, like 30d, 2w or 12h"},
+    {Command: "empty", Name: "--keep-last", Value: RequiredValue, Arg: "N", Help: "keep the newest N entries"},
+    {Command: "empty", Name: "--pattern", Value: RequiredValue, Arg: "GLOB", Help: "only entries whose name matches GLOB"},
+    {Command: "purge", Name: "--yes", Help: "don't ask before each entry"},
+    {Command: "purge", Name: "--secure", Help: "overwrite files before deleting them"},
+    {Command: "du", Name: "--internal", Help: "show how much srm's own bookkeeping takes up"},
+    {Command: "init", Name: "--trash", Value: RequiredValue, Arg: "WHERE", Help: "home, volume or DIR: where trashed files go"},
+    {Command: "init", Name: "--alias", Help: "add alias rm='srm' to the shell's startup file"},
+    {Command: "init", Name: "--timer", Help: "run srm maintain daily"},
+}
+
+// subcommands take over the whole invocation when given as the first argument
+// `srm -- list` or `srm ./list` still removes a file called list
+var SUBCOMMANDS = map[string]func(args []string){
+    "list":       listCommand,
+    "history":    historyCommand,
+    "export":     exportCommand,
+    "import":     importCommand,
+    "maintain":   maintainCommand,
+    "doctor":     doctorCommand,
+    "open":       openCommand,
+    "du":         duCommand,
+    "info":       infoCommand,
+    "search":     searchCommand,
+    "empty":      emptyCommand,
+    "explain":    explainCommand,
+    "config":     configCommand,
+    "which":      whichCommand,
+    "gc":         gcCommand,
+    "purge":      purgeCommand,
+    "completion": completionCommand,
+    "selftest":   selftestCommand,
+    "init":       initCommand,
+    "stats":      statsCommand,
+    "backend":    backendCommand,
+}
+
+// SYNOPSES are the usage lines of srm and its subcommands, in the order
+// usage lists them; srm COMMAND --help shows the command's own
+var SYNOPSES = []Synopsis{
+    {"", "srm [-f | -ff | -i] [-DdIPRrvWx] [-vv] [--quiet] [--keep-hidden[=DEPTH] | --hidden-only[=DEPTH]] [--archive] [--check-exec] [--abs | --relative-to=DIR] [--format=TEMPLATE] [--sort-operands=none|path|size | --biggest-first] [--prefer-trash=home|volume|DIR] [--on-no-trash=fail|permanent|tmp] [--trash-dir DIR] [--backend NAME] [--confirm-size SIZE] [--reason TEXT] [--verify] [--fast] [--time] [--dry-run] [--posix] <filepath> <...>"},
+    {"", "srm [--force[=2] | --interactive[=never|once|always]] [--directory] [--recursive] [--verbose] <filepath> <...>  (GNU rm's long forms)"},
+    {"", "srm [options] --batch-stdin < paths  (NUL-terminated, as find -print0 writes them)"},
+    {"list", "srm list [--format=TEMPLATE | --columns=COLS] [--tree] [--when WHEN] [--sort name|deleted|size] [--limit N] [pattern ...]"},
+    {"history", "srm history [--path SUBSTR] [--since WHEN] [--failed-only]"},
+    {"history", "srm history show <op-id>"},
+    {"export", "srm export <entry ...> -o bundle.tar"},
+    {"import", "srm import bundle.tar"},
+    {"maintain", "srm maintain [--install-timer | --uninstall]"},
+    {"doctor", "srm doctor"},
+    {"open", "srm open [entry]"},
+    {"du", "srm du [--internal]"},
+    {"info", "srm info <entry ...>"},
+    {"search", "srm search [--reason TEXT] [--when WHEN]"},
+    {"empty", "srm empty [-f] [-v] [--older-than AGE] [--keep-last N] [--pattern GLOB] [--dry-run]  (or srm --empty ...)"},
+    {"explain", "srm explain [removal options] <filepath> <...>"},
+    {"config", "srm config"},
+    {"which", "srm which <filepath>"},
+    {"gc", "srm gc"},
+    {"purge", "srm purge [--yes] [--secure] <entry ...>"},
+    {"completion", "srm completion bash|zsh"},
+    {"selftest", "srm selftest"},
+    {"init", "srm init [--trash home|volume|DIR] [--alias] [--timer]"},
+    {"stats", "srm stats [--days N] [--json]"},
+    {"backend", "srm backend [NAME [list | stats | restore ID [DEST] | purge ID]]"},
+}
+
+// HELPTOPICS are the sections srm --help TOPIC shows, TOPIC being the title
+// in lower case with - for spaces; srm COMMAND --help shows those of its
+// Commands too
+var HELPTOPICS = []HelpTopic{
+    {Title: "Formats", Commands: []string{"list"}, Lines: []string{
+        "--format takes a Go text/template evaluated once per entry, with the fields",
+        "{{.Name}} {{.Path}} {{.Dest}} {{.Size}} {{.IsDir}} {{.Action}} {{.Status}} {{.Duration}} {{.Reason}} {{.Op}}",
+        "{{.Trash}} {{.TrashWhy}}, and with --dry-run {{.DryRun}} {{.Prompts}} {{.Warnings}} {{.Problems}} {{.Error}},",
+        "or one of the presets long, csv, json. srm list --columns takes a comma separated",
+        "list of name,path,dest,size,dir,reason,deleted,volume instead; +reason adds to the default",
+    }},
+    {Title: "Sizes", Commands: []string{"du"}, Lines: []string{
+        "sizes are shown in KiB/MiB/GiB, --bytes prints exact byte counts for scripts;",
+        "{{size .Size}} formats a size the same way in templates",
+        "directories of 1000 entries or more have their size cached in the data dir (sizecache),",
+        "keyed by device, inode and mtime, so -I, srm du and --sort-operands=size don't walk them",
+        "again. Only adding, removing or renaming entries directly inside a directory changes its",
+        "mtime, so changes deeper down can leave a cached size stale; --no-size-cache walks everything",
+    }},
+    {Title: "When", Commands: []string{"list", "search"}, Lines: []string{
+        "--when filters by deletion time: today, yesterday, 2024-06-01, 2024-06-01..2024-06-03,",
+        "-7d.. (either side of .. may be left out)",
+    }},
+    {Title: "Paths", Lines: []string{
+        "prompts, -v and errors show paths as typed; --abs shows them absolute and --relative-to=DIR",
+        "relative to DIR. The journal, the index and --format output always record absolute paths",
+    }},
+    {Title: "Terminals", Commands: []string{"list", "history"}, Lines: []string{
+        "list and history page output longer than the screen through $PAGER, or ask -- more (y/n) --",
+        "between screenfuls without one or when TERM=dumb, which also drops progress bars. Output",
+        "that isn't to a terminal is never paged. Questions are answered on the terminal, /dev/tty",
+        "when stdin is redirected (so xargs srm -i doesn't answer with the file list), or from the",
+        "file SRM_ANSWERS names; --posix reads stdin as rm does. With no terminal every question is",
+        "no and -I is skipped; the end of input (Ctrl-D) is no to that question and every later one",
+    }},
+    {Title: "Operations", Commands: []string{"history"}, Lines: []string{
+        "a run that trashes anything ends by printing its operation ID to stderr (--quiet drops it).",
+        "Every journal and index row carries it, as does {{.Op}}; srm history show <id> looks it up",
+        "along with the command line, working directory, user (and SUDO_USER) and terminal it ran",
+        "from. Past 64 arguments only the first are kept, with the count and a SHA-256 of them all;",
+        "record_argv = false in the config keeps none, redact_argv = true keeps --reason's text out",
+    }},
+    {Title: "Statuses", Commands: []string{"history"}, Lines: []string{
+        "every entry ends up trashed, deleted, skipped-prompt (answered no), skipped-filter (left by",
+        "--keep-hidden, --hidden-only or an fstype skip policy), skipped-protected (refused, like a",
+        "read-only file without -f), skipped-missing (not there, with -f), covered (inside another",
+        "operand), trash-lost (left in place by a trash turning read-only) or failed. -vv and",
+        "{{.Status}} show it and the journal records it;",
+        "only failed makes srm exit 1, and trash-lost 75. The journal also records what srm purge,",
+        "srm empty and max_entries delete (deleted), and what srm -W puts back (restored). A journal",
+        "that is a symlink isn't written through, and makes the run exit 1 too",
+    }},
+    {Title: "Order", Commands: []string{"list"}, Lines: []string{
+        "operands are removed, asked about and reported one at a time in the order given;",
+        "--sort-operands=path sorts them, =size puts the biggest first (ties keep their order).",
+        "A repeated operand, or one inside another with -r, is reported in its own place. srm list",
+        "sorts by name unless --sort says otherwise, ties going by name",
+    }},
+    {Title: "Biggest first", Lines: []string{
+        "--biggest-first sizes every operand and, with -r, each entry of a directory operand, then",
+        "removes them one by one, biggest first, with a bar of the bytes handled so far; directories",
+        "emptied that way go last. Ctrl-C stops between entries and lists what was not removed",
+    }},
+    {Title: "Batches", Lines: []string{
+        "--batch-stdin makes one srm remove the NUL-terminated paths on stdin as they arrive, with one",
+        "journal run and operation ID, instead of xargs -0 starting an srm per handful of them:",
+        "find . -name '*.tmp' -print0 | srm -f --batch-stdin. Paths that arrive together share the",
+        "index and intent writes, as operands do. Each srm appends to an index segment of its own",
+        "in index.d, so those xargs -P runs side by side never write the same file; srm maintain",
+        "folds the segments into the index. tests/throughput.sh measures both ways",
+    }},
+    {Title: "POSIX", Lines: []string{
+        "--posix (or SRM_POSIX=1) keeps trashing but otherwise behaves as rm: rm's diagnostics,",
+        "prompts on stderr (\"remove regular file 'x'?\", answered yes by anything starting with y)",
+        "and -v lines (\"removed 'x'\"), the last of -f and -i wins, -f says nothing about missing",
+        "operands, a write-protected file is asked about only when input is a terminal, and the",
+        "operation ID, notices and progress bar are left out",
+    }},
+    {Title: "Dry run", Commands: []string{"empty"}, Lines: []string{
+        "--dry-run goes through every decision a removal makes, -I, -i, --keep-hidden, --hidden-only,",
+        "fstype policies and the trash choice included, and prints a line per operand and filtered",
+        "entry (would trash, would delete, would skip, would keep, would fail) with the questions it",
+        "would ask, the warnings it would print and the problems it expects. Nothing is asked, moved",
+        "or recorded, and a trash that would be created is only named; --format=json prints the same",
+        "as records with DryRun set. Exits 1 when anything would fail",
+    }},
+    {Title: "Hidden files", Lines: []string{
+        "with -r, --keep-hidden empties each directory operand but keeps its dotfiles (like .git or .envrc)",
+        "and the directory itself; --hidden-only removes just the dotfiles. =DEPTH also looks inside",
+        "subdirectories that many levels down. -v lists what was kept",
+    }},
+    {Title: "Archive", Lines: []string{
+        "--archive trashes each directory as a single <name>.tar.gz instead of moving the tree",
+    }},
+    {Title: "Mount points", Lines: []string{
+        "with -x (--one-file-system), -r trashes a directory around whatever is mounted inside it: each",
+        "mount point is skipped and reported, and it and the directories above it stay",
+    }},
+    {Title: "Restore", Lines: []string{
+        "-W moves each named entry (a name, path or entry ID) back where it was removed from, or into",
+        "the current directory when srm has no record of it; archives are unpacked. The newest entry",
+        "wins when several match. Something already there stops it, unless -f, which trashes it first",
+        "a directory entry whose origin is a directory again is merged into it: both trees are walked",
+        "into what is only in the trash, only on disk, identical (same size, then same checksum) and",
+        "conflicting, and srm asks whether to restore what is missing only, prefer the trash's or",
+        "what is on disk where they conflict, or review each conflict. --merge POLICY (missing,",
+        "trash, disk or review) answers that up front; what is replaced goes to the trash, and",
+        "conflicts left stay in the entry. --json prints the analysis as a line of JSON per entry",
+        "and changes nothing, for a script to choose a POLICY by",
+    }},
+    {Title: "Permanent", Lines: []string{
+        "--permanent (-D) deletes instead of trashing, for what is only worth the disk space it",
+        "frees. Each operand is asked about first (\"permanently remove X? this cannot be undone\"),",
+        "in place of -i's question, unless -f; -r, -d, -I, -v and carrying on past failures are as",
+        "without it. Safe mode refuses it. -P is --permanent that first overwrites every regular",
+        "file, each one under a directory with -r too, with zeros (-PP: random data, then zeros),",
+        "syncing and truncating it before it is unlinked. Symlinks, special files and files with",
+        "other hard links are deleted without. On btrfs, zfs and other copy-on-write filesystems",
+        "the old blocks survive, which it warns about, and on SSDs they may well too",
+    }},
+    {Title: "No trash", Commands: []string{"explain"}, Lines: []string{
+        "when no trash directory is usable, --on-no-trash decides: fail (default) refuses,",
+        "permanent deletes after confirmation (or with -f), tmp moves files to /tmp with a warning;",
+        "on_no_trash = POLICY in the config does when it isn't given (safe mode takes permanent as fail).",
+        "On Linux the XDG trash is created (owner-only) before it comes to that. Every trash is",
+        "checked for being writable before anything is moved.",
+        "--trash-dir DIR names the trash to use instead of ~/.Trash for the whole run, creating it",
+        "(owner-only) when it isn't there; without it SRM_TRASH_DIR does, then trash_dir in the",
+        "config. Whichever is set makes HOME unnecessary; --prefer-trash still comes before it",
+        "a trash that turns read-only during the run, like a home remounted after a suspend, is",
+        "dropped and the rest go to the next usable trash. What none can take is left in place,",
+        "listed once at the end (status trash-lost in --format) and, with --on-no-trash=permanent,",
+        "offered for permanent removal; if any is still left the exit status is 75",
+    }},
+    {Title: "Trash choice", Commands: []string{"explain", "which"}, Lines: []string{
+        "on Linux the freedesktop.org trash, $XDG_DATA_HOME/Trash/files, comes before ~/.Trash when",
+        "it and its info directory exist; each file trashed there gets an info/NAME.trashinfo with",
+        "its origin and deletion date, so desktop trash viewers show it and can put it back",
+        "an operand on another filesystem than ~/.Trash (or SRM_TRASH_DIR) goes to its .Trash-UID when",
+        "it exists and is private, so the move stays a rename. One laid out as a freedesktop.org trash,",
+        "as GIO makes them on removable drives, is used as such: files/ and info/, and a directory",
+        "trashed there is added to its directorysizes, from which Nautilus shows the trash's size.",
+        "srm list also lists the trashes of removable drives (mounted at /run/media/USER/LABEL or",
+        "/media/USER/LABEL), each entry marked with the LABEL, and \"offline\" when the drive isn't",
+        "mounted and only the index knows it ({{.Label}} and {{.Offline}} in --format).",
+        "--prefer-trash=home|volume|DIR, given once or more (or prefer_trash = [...] in the config),",
+        "tries those first, in order. -vv and --format show where each operand went and why",
+        "({{.Volume}} and {{.TrashVolume}} give the mounts); srm explain shows every candidate.",
+        "When something lands on another volume than it came from, like an --archive tarball, a",
+        "closing notice says how much (--quiet drops it)",
+        "A move that can't be a rename (EXDEV) is copied into the trash instead, keeping modes and",
+        "times, and the original removed once the copy is complete; fifos and devices can't be copied.",
+        "--verify reads each copied file back and compares its SHA-256 with the one taken while",
+        "writing, keeping the original when they differ; -vv notes when a rename left nothing to verify",
+    }},
+    {Title: "Backends", Commands: []string{"backend"}, Lines: []string{
+        "--backend NAME (or backend = NAME in the config) stores operands in a backend instead of",
+        "the trash, configured as backend[NAME] = KIND:ARG: directory:DIR is a trash directory kept",
+        "in the index like any other, cas:DIR an archive keeping each file's contents once under",
+        "their SHA-256 however many entries hold them. Each stored operand's dest is NAME:ID;",
+        "srm backend NAME restore ID puts it back, at its origin unless DEST is given, and srm",
+        "backend lists the backends with how much they hold and take up. A new kind implements",
+        "remove.TrashBackend (package github.com/shanahanjrs/srm/remove) and adds itself to",
+        "remove.BACKENDKINDS from an init function, in srm's tree or a program built on remove.",
+        "recyclebin:DIR is a Windows Recycle Bin, a $Recycle.Bin\\SID directory, its entries what",
+        "Explorer lists and restores. On Windows the backend recyclebin is there without configuring",
+        "and is the default: each operand goes to the Recycle Bin of its own volume, or to",
+        "%LOCALAPPDATA%\\srm\\RecycleBin when that volume has none, and --backend trash picks srm's",
+        "own trash again. --on-no-trash=tmp moves files to the user's temporary directory there",
+    }},
+    {Title: "Maintenance", Commands: []string{"maintain", "gc"}, Lines: []string{
+        "srm maintain applies max_entries, finishes moves an interrupted srm never recorded, forgets",
+        "index entries whose payload is gone, compacts the index (srm gc does the middle two) and",
+        "sums the journal up by day into the stats file; --install-timer runs it daily from a",
+        "systemd user timer (launchd on macOS)",
+        "max_entries = N in the config (or max_entries[<trash dir>] = N) caps how many",
+        "entries a trash holds, evicting the oldest after each removal and during maintenance.",
+        "An index with rows that don't parse, like one a full disk cut short, is moved aside as",
+        "index.corrupt-TIME with a warning and rebuilt from the rows that do, and the command goes on",
+        "srm's own files are kept in bounds: the journal is rotated to journal.1, .2, ... once it",
+        "reaches journal_max_size (8M), keeping journal_generations (4) of them and dropping those",
+        "untouched for journal_max_age (unset: kept), the stats file first taking in their days; the",
+        "size cache keeps the size_cache_max (2048) directories used last; the stats file keeps",
+        "stats_max_age (104w) of days; an intent log is emptied of settled moves during maintenance",
+        "or once past 64 KiB; and index.corrupt-TIME copies go after 30 days. srm du --internal",
+        "shows what each takes up",
+    }},
+    {Title: "Stats", Commands: []string{"stats"}, Lines: []string{
+        "srm stats shows each of the last --days N (30) days: operations, files trashed or deleted",
+        "for good, the bytes of each and restores, then a total and a chart of the bytes removed a",
+        "day; --json prints a line per day instead. Days come from the journal, and those rotated",
+        "out of it from the stats file maintenance keeps. Bytes are what the journal recorded: a",
+        "directory, which would cost a walk, has no size there unless --format asked for it",
+    }},
+    {Title: "Filesystem types", Lines: []string{
+        "fstype[PATTERN] = trash|permanent|ask|skip in the config picks what happens to operands",
+        "on matching mounts, e.g. fstype[tmpfs] = permanent or fstype[fuse.*] = ask; exact types",
+        "win over patterns and unmatched types are trashed. -vv shows the policy that applied",
+    }},
+    {Title: "Wildcards", Lines: []string{
+        "before removing operands that are the parent of the current directory or above it, like the",
+        ".. that .* expands to, or that are wildcard_guard percent (default 80) of the entries of",
+        "their directory, srm asks, even under -f on a terminal; wildcard_guard = 0 turns it off",
+        "--confirm-size SIZE (or confirm_size = SIZE in the config), like 500M or 10G, sizes each",
+        "operand, a directory with all it holds, symlinks not followed, and asks \"X is 42.3 GiB,",
+        "remove?\" about those bigger, -i or not; -f doesn't ask. What can't be read is warned about",
+        "and left out of the size. --posix, being rm, doesn't ask",
+    }},
+    {Title: "Never removed", Lines: []string{
+        "an operand ending in . or .. (./ and dir/.. too) is refused as rm refuses it, and so is the",
+        "root directory, however it is spelt (//, or a symlink to / given as link/), even under -f;",
+        "--no-preserve-root lets / through, except in safe mode",
+        "an argument before -- that starts with - and isn't an option srm knows is refused with",
+        "\"illegal option\" before anything is removed; a file named like one goes after --",
+    }},
+    {Title: "File URIs", Lines: []string{
+        "operands before -- that start with file://, as desktops and browsers paste them, are decoded",
+        "to the path they name (%20 a space, + a plus); one naming another host is refused, and -v",
+        "shows the URI after the path",
+    }},
+    {Title: "Force", Lines: []string{
+        "-f is rm's -f: no prompts rm would give, missing operands ignored, and none of srm's own",
+        "questions about giving up on the trash, emptying it, system files, --confirm-size or an",
+        "operand in a trash, which it moves like any other. -ff",
+        "(--force=2) also skips the wildcard guard, confirm_over_size and fstype = ask. Safe mode",
+        "asks what it asks at any level",
+    }},
+    {Title: "Interactive", Lines: []string{
+        "-i takes u and a besides y and n: u puts back the operand trashed last and asks about it",
+        "again, a puts back everything this run trashed and exits 1, listing what couldn't go back",
+        "with -r, -i walks a directory as rm does rather than moving it whole: it asks before",
+        "descending into each directory, about each entry inside, each trashed on its own, then about",
+        "the directory itself. No to a descend leaves that directory as it is and the ones above it",
+        "unasked; one left holding anything fails as not empty",
+    }},
+    {Title: "In a trash", Commands: []string{"purge"}, Lines: []string{
+        "an operand in a trash's files/ (freedesktop.org), ~/.Trash or a macOS volume's .Trashes/UID,",
+        "srm's or another tool's, isn't moved like any file, which would leave its info file behind.",
+        "srm asks instead: p purges it along with its info file, directorysizes line and index row,",
+        "r puts it back where it came from and trashes it again, n leaves it. Inside a trashed",
+        "directory only p is offered, and updates the directory's size. -f moves it as before",
+    }},
+    {Title: "Checks", Lines: []string{
+        "the exec (--check-exec), overlay and size checks only inform, and on huge batches can cost",
+        "more than the removal. --time and -vv end with what each took (\"exec check: 1.9s across",
+        "4,200 files\"), --fast skips them all, and check_budget[NAME] = 200ms in the config skips",
+        "one for the rest of the run, with a note, once a single file takes it longer",
+    }},
+    {Title: "Running executables", Lines: []string{
+        "--check-exec (or check_exec = true in the config) warns when a file, or anything in a",
+        "directory, is mapped executable by a running process, and asks under -i (Linux only)",
+    }},
+    {Title: "Config", Commands: []string{"config"}, Lines: []string{
+        remove.SYSTEMCONFIG + " then ~/.config/srm/config, key = value per line; the user file",
+        "overrides per key unless the system file lists the key in locked = [\"key\", ...]",
+        "(SRM_CONFIG names another user file). A line that doesn't parse is an error naming it.",
+        "trash_dir = ~/DIR is the trash SRM_TRASH_DIR would name, which wins over it, as do",
+        "--trash-dir and --prefer-trash; always_verbose = true is -v on every run; confirm_over_size = 10G",
+        "asks before a run removing more, like the wildcard guard (-ff doesn't); protected =",
+        "[\"~/work\", ...] are refused, and so is every directory above them; sandbox = true",
+        "confines a run's writes to the operands' directories, the trashes and srm's data (landlock,",
+        "Linux only: elsewhere it warns and runs without; srm doctor says which)",
+    }},
+    {Title: "First run", Commands: []string{"init"}, Lines: []string{
+        "srm init creates the trash and writes prefer_trash to the user config, and with --alias",
+        "and --timer adds alias rm='srm' to the shell's startup file and installs the maintenance",
+        "timer; on a terminal it asks about what no flag settled, and running it again changes",
+        "nothing. Without it, the first removal with no user config says once where files went",
+    }},
 }
 
+// usage
+// is srm --help: how to remove files and with which options, the
+// subcommands, and where the rest of the help is
 func usage() {
     fmt.Println("Usage:")
-    fmt.Println("    srm [-f | -i] [-dIRrv] <filepath> <...>")
+    for _, synopsis := range SYNOPSES {
+        if synopsis.Command == "" {
+            fmt.Println("    " + synopsis.Line)
+        }
+    }
+    fmt.Println("Commands:")
+    for _, synopsis := range SYNOPSES {
+        if synopsis.Command != "" {
+            fmt.Println("    " + synopsis.Line)
+        }
+    }
+    fmt.Println("Options:")
+    for _, opt := range OPTIONS {
+        if opt.takes("") {
+            fmt.Println(optionUsage(opt))
+        }
+    }
+    topics := []string{}
+    for _, topic := range HELPTOPICS {
+        topics = append(topics, topic.Name())
+    }
+    fmt.Println("More help:")
+    fmt.Println("    srm COMMAND --help shows a command's options and the topics on it; srm --help TOPIC ...")
+    fmt.Println("    shows topics, which are:")
+    line := "   "
+    for _, topic := range topics {
+        if len(line)+1+len(topic) > 100 {
+            fmt.Println(line)
+            line = "   "
+        }
+        line += " " + topic
+    }
+    fmt.Println(line)
     fmt.Println("Note:")
     fmt.Println("    Intended to replace `rm` via a shell alias")
-}
 
-// getUserConfirmation
-// will print your msg (string) and then return true or false depending on users response
-func getUserConfirmation(msg string) bool {
-    var interactiveResponse string
-    fmt.Print(msg)
-    fmt.Scanln(&interactiveResponse)
-    interactiveResponse = strings.ToLower(interactiveResponse)
-    if In(interactiveResponse, []string{"y", "yes", "yea", "yeah", "da", "si", "letsgo"}) {
-        return true
+    if safe, _ := safeModeEnabled(); safe {
+        fmt.Println("Safe mode:")
+        fmt.Println("    on (SRM_SAFE or safe_mode in the config): every removal is confirmed,")
+        fmt.Println("    -f does not skip prompts, and permanent deletion (including srm empty) is disabled")
     }
-
-    return false
 }
 
-func parseArgs() ([]string, []string) {
-    // TODO support --
-    // srm -- -f would remove a file named -f instead of being parsed as the "force flag"
-    args := os.Args[1:]
+// EXITTRASHLOST is the exit status when a trash turned read-only during the
+// run and left operands where they were, EX_TEMPFAIL as trying again once it
+// is writable may well work
+const EXITTRASHLOST = 75
 
-    if len(args) < 1 {
-        usage()
-        os.Exit(1)
-    }
+func parseArgs(args []string) ([]string, []string) {
+    flags, files, _, _ := parseArgPositions(args)
+    return flags, files
+}
 
+// parseArgPositions
+// is parseArgs that also returns where in args each file came from, and
+// which files are file:// URIs to decode: any before --, none after it
+func parseArgPositions(args []string) ([]string, []string, []int, map[int]bool) {
     flags := []string{}
     files := []string{}
+    positions := []int{}
+    uris := map[int]bool{}
     seenDoubleDash := false
 
-    for _, arg := range args {
-        if arg == "--" {
+    for i := 0; i < len(args); i++ {
+        arg := args[i]
+        // only the first -- ends the options; a later one is a file
+        if arg == "--" && !seenDoubleDash {
             seenDoubleDash = true
             continue
         }
 
-        // flags/params
-        if In(arg, VALIDARGS) && !seenDoubleDash {
-            flags = append(flags, arg)
+        // --force is -f and --force=2 is -ff, so that counting -f is all
+        // there is to the force level
+        if name, value, _ := strings.Cut(arg, "="); name == "--force" && !seenDoubleDash {
+            switch value {
+            case "", "1":
+                flags = append(flags, "-f")
+            case "2":
+                flags = append(flags, "-f", "-f")
+            default:
+                fmt.Fprintf(os.Stderr, "srm: invalid --force level %q: expected 1 or 2\n", value)
+                os.Exit(1)
+            }
+            continue
+        }
+
+        // --interactive is -i, =once is -I, and =never undoes the -i and -I
+        // before it, as a later -f would
+        if name, value, _ := strings.Cut(arg, "="); name == "--interactive" && !seenDoubleDash {
+            switch value {
+            case "", "always", "yes":
+                flags = append(flags, "-i")
+            case "once":
+                flags = append(flags, "-I")
+            case "never", "no", "none":
+                flags = slices.DeleteFunc(flags, func(flag string) bool { return flag == "-i" || flag == "-I" })
+            default:
+                fmt.Fprintf(os.Stderr, "srm: invalid --interactive value %q: expected never, once or always\n", value)
+                os.Exit(1)
+            }
+            continue
+        }
+
+        // --name, or --name value; aliases are recorded by the option's name
+        if opt := lookupOption(arg); opt != nil && !seenDoubleDash {
+            if opt.Value == RequiredValue && i+1 < len(args) {
+                flags = append(flags, opt.Name+"="+args[i+1])
+                i++
+            } else {
+                // a value that is missing is validateFlags' to report
+                flags = append(flags, opt.Name)
+            }
+            continue
+        }
+
+        // --name=value
+        if name, value, ok := strings.Cut(arg, "="); ok && !seenDoubleDash {
+            if opt := lookupOption(name); opt != nil && opt.Value != NoValue {
+                flags = append(flags, opt.Name+"="+value)
+                continue
+            }
+        }
+
+        // short options run together, like -rf; the last may take a value
+        if !seenDoubleDash && len(arg) > 2 && arg[0] == '-' && arg[1] != '-' {
+            letters := arg[1:]
+            for j, letter := range letters {
+                opt := lookupOption("-" + string(letter))
+                if opt == nil || (opt.Value != NoValue && j < len(letters)-1) {
+                    illegalOption(letter)
+                }
+                if opt.Value == RequiredValue && i+1 < len(args) {
+                    flags = append(flags, opt.Name+"="+args[i+1])
+                    i++
+                } else {
+                    flags = append(flags, opt.Name)
+                }
+            }
             continue
         }
 
+        // anything else that looks like an option is one srm doesn't know,
+        // never a file: those go after --. - alone is a file, as for rm.
+        if !seenDoubleDash && len(arg) > 1 && arg[0] == '-' {
+            if arg[1] != '-' {
+                illegalOption(rune(arg[1]))
+            }
+            illegalLongOption(arg)
+        }
+
         // files
+        if !seenDoubleDash && isFileURI(arg) {
+            uris[len(files)] = true
+        }
         files = append(files, arg)
+        positions = append(positions, i)
     }
 
-    return flags, files
+    return flags, files, positions, uris
+}
+
+// illegalOption
+// is rm's complaint about an option letter it doesn't know
+func illegalOption(letter rune) {
+    fmt.Fprintf(os.Stderr, "srm: illegal option -- %c\n", letter)
+    fmt.Fprintln(os.Stderr, "usage: srm [-f | -i] [-dIRrvWx] file ...")
+    os.Exit(1)
+}
+
+// illegalLongOption
+// is illegalOption for a long option srm doesn't know, or a value given to
+// one that takes none
+func illegalLongOption(arg string) {
+    if name, _, ok := strings.Cut(arg, "="); ok && lookupOption(name) != nil {
+        fmt.Fprintf(os.Stderr, "srm: option %s doesn't take a value\n", name)
+    } else {
+        fmt.Fprintf(os.Stderr, "srm: illegal option %s\n", arg)
+    }
+    fmt.Fprintln(os.Stderr, "usage: srm [-f | -i] [-dIRrvWx] file ...")
+    os.Exit(1)
+}
+
+// failureMessages
+// are the lines printed for a failed operand: rm's wording where rm has one,
+// and a line per entry when several inside a filtered directory failed
+func failureMessages(path string, err error) []string {
+    if joined, ok := err.(interface{ Unwrap() []error }); ok {
+        msgs := []string{}
+        for _, err := range joined.Unwrap() {
+            msgs = append(msgs, err.Error())
+        }
+        return msgs
+    }
+    if errors.Is(err, remove.ErrPreserveRoot) {
+        msg, _ := rmDiagnostic(path, err)
+        return []string{msg, "use --no-preserve-root to override this failsafe"}
+    }
+    if remove.POSIX {
+        return []string{posixMessage(path, err)}
+    }
+    if msg, ok := rmDiagnostic(path, err); ok {
+        return []string{msg}
+    }
+    return []string{err.Error()}
+}
+
+// trashDestNote
+// is what -v adds after the path when result went into the trash: where it
+// landed, under the name it really got when its own was taken there already
+func trashDestNote(result remove.Result) string {
+    if result.Action != "trashed" || result.Dest == "" {
+        return ""
+    }
+    return " -> " + remove.DisplayPath(result.Dest)
+}
+
+// veryVerboseNotes
+// is what -vv adds after the path: the filesystem type policy that applied,
+// whether it was an overlayfs artifact, the trash and what --verify did
+func veryVerboseNotes(result remove.Result) string {
+    notes := []string{}
+    if result.Policy != "" {
+        notes = append(notes, result.FSType+": "+result.Policy)
+    }
+    if result.Overlay != "" {
+        notes = append(notes, "overlay "+result.Overlay)
+    }
+    if result.Trash != "" {
+        notes = append(notes, "trash "+result.Trash+": "+result.TrashWhy)
+    }
+    if result.Verify != "" {
+        notes = append(notes, "--verify: "+result.Verify)
+    }
+    if len(notes) == 0 {
+        return ""
+    }
+    return " (" + strings.Join(notes, ", ") + ")"
+}
+
+// volumeNotices
+// says, per trash, how much of what was trashed moved there from another
+// volume: space that volume got back, but that the trash's volume now holds
+// until srm empty
+func volumeNotices(results []remove.Result) []string {
+    order := []string{}
+    bytes := map[string]int64{}
+    from := map[string][]string{}
+    volume := map[string]string{}
+    for _, result := range results {
+        if _, ok := bytes[result.Trash]; !ok {
+            order = append(order, result.Trash)
+            volume[result.Trash] = result.TrashVolume
+        }
+        size := result.Bytes
+        if size == 0 {
+            size, _ = remove.DiskUsage(remove.OSFS{}, result.Dest)
+        }
+        bytes[result.Trash] += size
+        if !remove.In(result.Volume, from[result.Trash]) {
+            from[result.Trash] = append(from[result.Trash], result.Volume)
+        }
+    }
+
+    notices := []string{}
+    for _, trash := range order {
+        notices = append(notices, fmt.Sprintf("%s moved from %s to %s, so it now takes space on %s; run 'srm empty' to reclaim it",
+            remove.FormatSize(bytes[trash]), strings.Join(from[trash], ", "), trash, volume[trash]))
+    }
+    return notices
+}
+
+// chooseTarget
+// returns the trash directory to use ("" for permanent deletion) and a note
+// when it isn't a real trash, or the error to refuse with under --on-no-trash=fail
+func chooseTarget(onNoTrash string, prefer []string) (string, string, error) {
+    dir, err := remove.ChooseTrashDir(prefer)
+    if err == nil {
+        return dir, "", nil
+    }
+
+    switch onNoTrash {
+    case "tmp":
+        if tmpErr := remove.CheckTrashDir(remove.TMPTRASH); tmpErr != nil {
+            return "", "", fmt.Errorf("%w; %s", err, tmpErr)
+        }
+        return remove.TMPTRASH, err.Error() + ", using " + remove.TMPTRASH + " (--on-no-trash=tmp)", nil
+    case "permanent":
+        return "", err.Error() + ", deleting permanently (--on-no-trash=permanent)", nil
+    }
+    return "", "", err
 }
 
 // Get target dir for safely removed files
-func getTargetRmDir() string {
-    // First check if ~/.Trash exists (macOS)
-    homeDir, err := os.UserHomeDir()
-    if err != nil {
-        fmt.Println("Could not get users home dir")
+// An empty dir means files should be deleted permanently. note explains why we
+// didn't end up in a real trash and is empty when we did. On Linux a missing
+// XDG trash is created first, so /tmp is only ever the last resort; a dry
+// run says it would be and goes on as if it had been.
+func getTargetRmDir(onNoTrash string, prefer []string, dryRun bool) (string, string) {
+    dir, note, created, err := selectTarget(onNoTrash, prefer, dryRun)
+    switch {
+    case created != "" && dryRun:
+        fmt.Printf("would create %s\n", remove.DisplayName(created))
+    case err == nil && dir == remove.TMPTRASH:
+        fmt.Fprintf(os.Stderr, "srm: WARNING: no usable trash, moving files to %s, where anyone can read them and they are\n", remove.TMPTRASH)
+        fmt.Fprintln(os.Stderr, "srm: WARNING: lost on reboot or to systemd-tmpfiles; run 'srm doctor' to see what is wrong with the trash")
+    case err != nil:
+        fmt.Fprintf(os.Stderr, "srm: %s\n", err)
+        fmt.Fprintf(os.Stderr, "srm: refusing to remove anything; pass --on-no-trash=tmp to move files to %s instead, or --on-no-trash=permanent to delete them for real\n", remove.TMPTRASH)
         os.Exit(1)
     }
+    return dir, note
+}
 
-    path := homeDir + "/.Trash"
-    if _, err := os.Stat(path); err == nil {
-        // ~/.Trash
-        return path
+// selectTarget is getTargetRmDir without what it prints, which srm explain
+// shares: created is the XDG trash made for the run, or under dryRun only
+// found missing, and err why there is no trash to use
+func selectTarget(onNoTrash string, prefer []string, dryRun bool) (dir string, note string, created string, err error) {
+    // under --dry-run a --trash-dir that isn't there yet is only said to be
+    // created, and used all the same
+    if dryRun && remove.TRASHDIRFLAG != "" {
+        if _, err := os.Stat(remove.TRASHDIRFLAG); err != nil {
+            return remove.TRASHDIRFLAG, "", "", nil
+        }
+    }
+    created, err = remove.CreateXDGTrash(prefer, dryRun)
+    switch {
+    case err != nil:
+        fmt.Fprintf(os.Stderr, "srm: warning: creating the trash: %s\n", err)
+    case created != "" && dryRun:
+        return created, "", created, nil
     }
 
-    // Otherwise just use /tmp
-    return "/tmp"
+    dir, note, err = chooseTarget(onNoTrash, prefer)
+    return dir, note, created, err
 }
 
-func main() {
-    targetDir := getTargetRmDir()
-    flags, files := parseArgs()
-    filesCount := len(files)
+// commandOf
+// is the subcommand args run and the arguments it is given, "" and args for
+// a removal. --empty and --list are srm empty and srm list spelled as
+// options, wherever they come; --list shows every entry in full, newest first
+func commandOf(args []string, flags []string) (string, []string) {
+    commandArgs := args
+    for len(commandArgs) > 1 && remove.In(commandArgs[0], []string{"--bytes", "--no-size-cache"}) {
+        commandArgs = commandArgs[1:]
+    }
+    if _, ok := SUBCOMMANDS[commandArgs[0]]; ok {
+        return commandArgs[0], commandArgs[1:]
+    }
+    if remove.In("--empty", flags) {
+        return "empty", withoutArg(commandArgs, "--empty")
+    }
+    if remove.In("--list", flags) {
+        rest := withoutArg(commandArgs, "--list")
+        _, hasFormat := remove.FlagValue("--format", flags)
+        _, hasColumns := remove.FlagValue("--columns", flags)
+        if !hasFormat && !hasColumns {
+            rest = append([]string{"--format=full"}, rest...)
+        }
+        if _, ok := remove.FlagValue("--sort", flags); !ok {
+            rest = append([]string{"--sort=deleted"}, rest...)
+        }
+        return "list", rest
+    }
+    return "", args
+}
 
-    // help
-    helpFlag := In("-h", flags) || In("--help", flags)
-    if helpFlag {
+func main() {
+    if len(os.Args) < 2 {
+        if posix, _ := posixEnabled(nil); posix {
+            missingOperand()
+        }
         usage()
+        os.Exit(1)
+    }
+
+    // --bytes and --no-size-cache apply to every command, so pick them up
+    // before dispatching. They may also come before the subcommand name.
+    globalFlags, _ := parseArgs(os.Args[1:])
+    command, commandArgs := commandOf(os.Args[1:], globalFlags)
+    commandFlags := globalFlags
+    if command != "" {
+        commandFlags, _ = parseArgs(commandArgs)
+    }
+    if err := validateFlags(command, commandFlags); err != nil {
+        fmt.Fprintf(os.Stderr, "srm: %s\n", err)
+        os.Exit(1)
+    }
+    // --help does nothing but show the command's usage, or for a removal
+    // the topics given as operands
+    if remove.In("--help", commandFlags) {
+        _, topics := parseArgs(os.Args[1:])
+        switch {
+        case command != "":
+            commandUsage(command)
+        case len(topics) > 0:
+            if err := helpTopics(topics); err != nil {
+                fmt.Fprintf(os.Stderr, "srm: %s\n", err)
+                os.Exit(1)
+            }
+        default:
+            usage()
+        }
         os.Exit(0)
     }
+    remove.EXACTSIZES = remove.In("--bytes", globalFlags)
+    posix, err := posixEnabled(globalFlags)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "srm: %s\n", err)
+        os.Exit(1)
+    }
+    remove.POSIX = posix
+    if !remove.In("--no-size-cache", globalFlags) {
+        remove.SIZECACHE, _ = remove.OpenSizeCache()
+    }
 
-    // Force
-    forceFlag := In("-f", flags)
+    // --abs and --relative-to change how paths are shown, never what is recorded
+    relativeTo, hasRelativeTo := remove.FlagValue("--relative-to", globalFlags)
+    switch {
+    case hasRelativeTo && remove.In("--abs", globalFlags):
+        fmt.Fprintln(os.Stderr, "srm: --abs and --relative-to can't be combined")
+        os.Exit(1)
+    case hasRelativeTo:
+        dir, err := filepath.Abs(relativeTo)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "srm: invalid --relative-to: %s\n", err)
+            os.Exit(1)
+        }
+        remove.PATHDISPLAY, remove.RELATIVETO = "rel", dir
+    case remove.In("--abs", globalFlags):
+        remove.PATHDISPLAY = "abs"
+    }
 
-    // interactive
-    interactiveFlag := In("-i", flags)
-    nonintrusiveInteractiveFlag := In("-I", flags)
+    // --trash-dir picks the trash for every command, created if need be
+    if dir, ok := remove.FlagValue("--trash-dir", globalFlags); ok {
+        abs, missing, err := remove.PrepareTrashDir(dir, remove.In("--dry-run", globalFlags))
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "srm: invalid --trash-dir: %s\n", err)
+            os.Exit(1)
+        }
+        if missing {
+            fmt.Printf("would create %s\n", remove.DisplayName(abs))
+        }
+        remove.TRASHDIRFLAG = abs
+    }
 
-    // recursive
-    recursiveFlag := In("-r", flags) || In("-R", flags)
+    if command != "" {
+        SUBCOMMANDS[command](commandArgs)
+        return
+    }
 
-    // allow directories to be deleted
-    directoryFlag := In("-d", flags)
+    flags, operands, positions, uris := parseArgPositions(os.Args[1:])
 
-    // verbose delete
-    verboseFlag := In("-v", flags)
+    // --batch-stdin takes its operands from stdin, as they come
+    batchStdin := remove.In("--batch-stdin", flags)
+    _, sorted := remove.FlagValue("--sort-operands", flags)
+    switch {
+    case batchStdin && len(operands) > 0:
+        fmt.Fprintln(os.Stderr, "srm: --batch-stdin reads the paths from stdin, not the command line")
+        os.Exit(1)
+    case batchStdin && (remove.In("--biggest-first", flags) || sorted):
+        fmt.Fprintln(os.Stderr, "srm: --batch-stdin removes paths as they come, it can't be combined with --biggest-first or --sort-operands")
+        os.Exit(1)
+    }
 
-    //fmt.Println("Flags: ", flags)
-    //fmt.Println("Files: ", files)
+    if remove.POSIX && len(operands) == 0 && !remove.In("-f", flags) && !batchStdin {
+        missingOperand()
+    }
 
-    // handle -I >3 files case
-    if nonintrusiveInteractiveFlag && filesCount > 3 {
-        removeMultipleMsg := fmt.Sprintf("remove %d files?", filesCount)
-        if !getUserConfirmation(removeMultipleMsg) {
-            os.Exit(0)
+    // empty operands are script bugs: reported, never looked up, and only a
+    // failure without -f. file:// URIs are decoded first, -v showing each
+    // one after the path it became.
+    files := []string{}
+    fromURI := map[string]string{}
+    invalidOperands := false
+    for i, operand := range operands {
+        err := remove.CheckOperand(operand)
+        if uris[i] {
+            uri := operand
+            if operand, err = decodeFileURI(uri); err == nil {
+                err = remove.CheckOperand(operand)
+                fromURI[operand] = uri
+            }
+        }
+        if err != nil {
+            switch {
+            case remove.In("-f", flags):
+            case remove.POSIX && operand == "":
+                fmt.Fprintln(os.Stderr, "srm: cannot remove '': No such file or directory")
+                invalidOperands = true
+            default:
+                fmt.Fprintf(os.Stderr, "srm: %s (argument %d)\n", remove.DisplayName(err.Error()), positions[i]+1)
+                invalidOperands = true
+            }
+            continue
+        }
+        files = append(files, operand)
+    }
+    filesCount := len(files)
+
+    // -f -i -I -r -d, plus whatever the environment and config impose
+    opts, err := resolveOptions(flags)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "srm: %s\n", err)
+        os.Exit(1)
+    }
+
+    // everything from the -I question on follows this order
+    sortBy, _ := remove.FlagValue("--sort-operands", flags)
+    if sortBy != "" && remove.In("--biggest-first", flags) {
+        fmt.Fprintln(os.Stderr, "srm: --sort-operands and --biggest-first can't be combined")
+        os.Exit(1)
+    }
+    files, err = sortOperands(files, sortBy)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "srm: %s\n", err)
+        os.Exit(1)
+    }
+
+    // --dry-run decides everything and does nothing
+    dryRun := remove.In("--dry-run", flags)
+
+    // -I is there for a slip at the keyboard; with no terminal to answer on
+    // there is none, and it is skipped rather than taken as no
+    if opts.OnceInteractive && !dryRun && !remove.CanAsk() {
+        opts.OnceInteractive = false
+    }
+
+    // verbose delete, -vv adds the filesystem type policy that applied;
+    // always_verbose = true in the config is a -v on every run
+    veryVerboseFlag := remove.In("-vv", flags)
+    verboseFlag := remove.In("-v", flags) || veryVerboseFlag
+    if settings, err := remove.LoadSettings(); err == nil && !verboseFlag {
+        if verboseFlag, err = settings.Config.Bool("always_verbose"); err != nil {
+            fmt.Fprintf(os.Stderr, "srm: %s\n", err)
+            os.Exit(1)
         }
     }
 
-    for _, filepath := range files {
-        // directory and -r check
-        isDir, err := IsDir(filepath)
+    // -W undoes removals rather than making any
+    if remove.In("-W", flags) {
+        merge, _ := remove.FlagValue("--merge", flags)
+        if merge != "" && !remove.In(merge, MERGEPOLICIES) {
+            fmt.Fprintf(os.Stderr, "srm: invalid --merge: %s (expected %s)\n", merge, strings.Join(MERGEPOLICIES, ", "))
+            os.Exit(1)
+        }
+        restoreCommand(files, opts, verboseFlag, dryRun, merge, remove.In("--json", flags))
+        if invalidOperands {
+            os.Exit(1)
+        }
+        return
+    }
+
+    // --format replaces the -v line, so parse it before touching anything
+    var formatter *Formatter
+    if spec, ok := remove.FlagValue("--format", flags); ok {
+        formatter, err = NewFormatter("remove", spec)
         if err != nil {
-            fmt.Println(err)
+            fmt.Fprintf(os.Stderr, "srm: invalid --format: %s\n", err)
             os.Exit(1)
         }
+    }
 
-        if (isDir && !recursiveFlag && !directoryFlag) {
-            // if its a directory and they haven't specified -r || -R || -d then fail
-            fmt.Printf("srm: %s: is a directory\n", filepath)
+    // --backend, when there is something for it to store
+    if opts.BackendName != remove.DEFAULTBACKEND && (batchStdin || remove.AnyExists(files)) && !opts.Delete {
+        settings, err := remove.LoadSettings()
+        if err == nil {
+            opts.Backend, err = remove.OpenBackend(opts.BackendName, settings.Config)
+        }
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "srm: %s\n", err)
             os.Exit(1)
         }
+    }
 
-        if isDir && nonintrusiveInteractiveFlag && recursiveFlag {
-            recursiveDelMsg := fmt.Sprintf("recursively remove %s?", filepath)
-            if !getUserConfirmation(recursiveDelMsg) {
-                continue
-            }
+    // what to do if there is nowhere safe to put things
+    onNoTrash, err := resolveOnNoTrash(flags, opts)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "srm: %s\n", err)
+        os.Exit(1)
+    }
+
+    // a trash is only looked for once there is something to put in it, so
+    // removing files that aren't there works without HOME
+    // --permanent wants no trash at all
+    targetDir, trashNote, permanent := "", "", opts.Delete
+    if (batchStdin || remove.AnyExists(files)) && !opts.Delete && opts.Backend == nil {
+        targetDir, trashNote = getTargetRmDir(onNoTrash, opts.PreferTrash, dryRun)
+        permanent = targetDir == ""
+    }
+    if trashNote != "" && verboseFlag && formatter == nil {
+        fmt.Println("srm: " + trashNote)
+    }
+
+    if permanent && !opts.Delete && !opts.SkipsPrompt("permanent") {
+        permanentMsg := fmt.Sprintf("no usable trash, permanently remove %d file(s)? this cannot be undone ", filesCount)
+        if batchStdin {
+            permanentMsg = "no usable trash, permanently remove the files read from stdin? this cannot be undone "
+        }
+        if dryRun {
+            fmt.Println("would ask: " + permanentMsg)
+        } else if !remove.GetUserConfirmation(permanentMsg) {
+            os.Exit(0)
         }
+    }
+
+    //fmt.Println("Flags: ", flags)
+    //fmt.Println("Files: ", files)
 
-        splitFilePath := strings.Split(filepath, "/")
-        filename := splitFilePath[len(splitFilePath)-1]
-        dest := targetDir + "/" + filename
+    opts.TrashDir = targetDir
+    opts.ResolveTrash = true
+    opts.Permanent = permanent
+    opts.TrashNote = trashNote
+    opts.MeasureSize = formatter != nil
+    opts.Archive = remove.In("--archive", flags)
+    opts.Reason, _ = remove.FlagValue("--reason", flags)
+    opts.Op = newOpID()
+    opts.DryRun = dryRun
+    // others could swap payloads in a trash they can write to, so the index
+    // doesn't vouch for anything put there
+    sharedTrash := false
+    if err := remove.CheckPrivateDir(targetDir); targetDir != "" && errors.Is(err, remove.ErrNotPrivate) {
+        fmt.Fprintf(os.Stderr, "srm: warning: %s, not recording its entries in the index\n", remove.DisplayName(err.Error()))
+        sharedTrash = true
+    }
+    if index, err := remove.OpenIndex(); err == nil && targetDir != "" && !sharedTrash && !dryRun {
+        opts.Index = index
+        opts.Intents, err = remove.OpenIntentLog(targetDir)
+        if err == nil {
+            // finish whatever an earlier, interrupted srm left half recorded
+            _, _, err = opts.Intents.Replay(remove.OSFS{}, index)
+        }
+        if err == nil && opts.Intents.Size() > INTENTLOGMAX {
+            err = opts.Intents.Compact()
+        }
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "srm: intent log: %s\n", err)
+        }
+    }
 
-        // -i
-        if interactiveFlag {
-            deleteMsg := fmt.Sprintf("remove %s?", filepath)
-            if !getUserConfirmation(deleteMsg) {
-                continue
+    // the journal, -v and --format all hang off the Remover's callbacks
+    var journal *Journal
+    // trashed counts what is in the trash for the run, less what an -i
+    // session put back
+    trashed := 0
+    firstTrashed := remove.Result{}
+    crossVolume := []remove.Result{}
+    opts.Callbacks.OnEntryDone = func(result remove.Result) {
+        if dryRun {
+            // covered operands are reported in their turn, and under -f
+            // missing ones not at all, as in a real run
+            if errors.Is(result.Err, remove.ErrCovered) || (opts.Force && remove.IsMissing(result.Err)) {
+                return
             }
+            if formatter != nil {
+                entry := resultEntry(result)
+                entry.DryRun = true
+                formatter.Write(os.Stdout, entry)
+                return
+            }
+            for _, line := range dryRunLines(result) {
+                fmt.Println(remove.DisplayName(line))
+            }
+            return
         }
 
-        // fmt.Printf("attempting to move %s to %s\n", filepath, dest)
+        journal.Record(result)
+        if result.Action == "trashed" && trashed == 0 {
+            firstTrashed = result
+        }
+        switch result.Action {
+        case "trashed":
+            trashed++
+        case "restored":
+            trashed--
+        }
+        if result.Action == "trashed" && result.Volume != "" && result.TrashVolume != "" && result.Volume != result.TrashVolume {
+            crossVolume = append(crossVolume, result)
+        }
+        // failures are reported on stderr, covered operands in their turn
+        status := result.Status()
+        if status == remove.StatusFailed || status == remove.StatusCovered {
+            return
+        }
+        // u and a say what they put back themselves
+        if status == remove.StatusRestored && formatter == nil {
+            return
+        }
 
-        // if it ends with a / strip it
-        if filepath[len(filepath)-1:] == "/" {
-            filepath = strings.TrimRight(filepath, "/")
+        entry := resultEntry(result)
+        switch verbose := status.Info().Verbose; {
+        case formatter != nil:
+            formatter.Write(os.Stdout, entry)
+        case remove.POSIX && verboseFlag:
+            if status == remove.StatusTrashed || status == remove.StatusDeleted {
+                fmt.Println(remove.DisplayName(posixVerbose(result)))
+            }
+        case veryVerboseFlag:
+            fmt.Printf("%s %s%s%s%s\n", status, remove.DisplayName(remove.DisplayPath(result.Source)), fileURINote(fromURI, result.Source), trashDestNote(result), veryVerboseNotes(result))
+        case verboseFlag && verbose != "":
+            fmt.Printf(verbose+"%s\n", remove.DisplayName(remove.DisplayPath(result.Source)), fileURINote(fromURI, result.Source)+trashDestNote(result))
         }
+    }
+    if redrawable(os.Stderr) && !remove.POSIX {
+        width, _ := remove.TerminalSize()
+        opts.Callbacks.OnProgress = progressBar(os.Stderr, width)
+    }
+    remover := remove.NewRemover(opts)
+    defer remover.Close()
 
-        // check file isn't RO
-        fileIsReadOnly, err := IsReadOnly(filepath)
+    // a wildcard that took in a whole directory, or .., is asked about
+    // even under -f when there is someone to ask, though not under -ff
+    if settings, err := remove.LoadSettings(); err == nil {
+        percent, err := wildcardGuardPercent(settings.Config)
         if err != nil {
-            fmt.Println(err)
+            fmt.Fprintf(os.Stderr, "srm: %s\n", err)
             os.Exit(1)
         }
-        if fileIsReadOnly && !forceFlag {
-            fmt.Println("File is read-only")
+        if question, ok := wildcardSweep(files, percent); ok && !opts.SkipsPrompt("wildcard") && (!opts.Force || remove.IsTTY(os.Stdin)) {
+            question = remove.DisplayName(question) + " (wildcard_guard = 0 in the config turns this off) "
+            if dryRun {
+                fmt.Println("would ask: " + question)
+            } else if !remove.GetUserConfirmation(question) {
+                os.Exit(0)
+            }
+        }
+    }
+
+    // confirm_over_size asks before a run that frees more than it says,
+    // like the wildcard guard, even under -f on a terminal but not -ff
+    if opts.ConfirmOverSize > 0 && !opts.SkipsPrompt("size") && (!opts.Force || remove.IsTTY(os.Stdin)) {
+        var total int64
+        for _, file := range files {
+            size, _ := remove.DiskUsage(remove.OSFS{}, file)
+            total += size
+        }
+        if total > opts.ConfirmOverSize {
+            question := fmt.Sprintf("remove %d operand(s) totalling %s, over confirm_over_size (%s)? ", len(files), remove.FormatSize(total), remove.FormatSize(opts.ConfirmOverSize))
+            if dryRun {
+                fmt.Println("would ask: " + question)
+            } else if !remove.GetUserConfirmation(question) {
+                os.Exit(0)
+            }
+        }
+    }
+
+    // handle -I >3 files case
+    if !remover.ConfirmBatch(files) {
+        os.Exit(0)
+    }
+
+    // an unopened journal records nothing
+    journal = &Journal{}
+    if !dryRun {
+        journal = openJournal(opts.Op, os.Args)
+    }
+    defer journal.Close()
+
+    // from here on the run may only write where it removes from and to
+    if !dryRun {
+        sandbox, err := sandboxEnabled()
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "srm: %s\n", err)
             os.Exit(1)
         }
+        switch {
+        case sandbox && batchStdin:
+            fmt.Fprintln(os.Stderr, "srm: warning: sandbox = true, but running without one: --batch-stdin's paths aren't known up front")
+        case sandbox:
+            sandboxRun(files, targetDir, opts.PreferTrash, remove.BackendRoot(opts.Backend))
+        }
+    }
+
+    // the operation ID is what srm history show takes, so say it whenever
+    // there is something in the trash to look up
+    quietFlag := remove.In("--quiet", flags) || remove.POSIX
+    finish := func() {
+        journal.Close()
+        if !quietFlag {
+            for _, notice := range volumeNotices(crossVolume) {
+                fmt.Fprintf(os.Stderr, "srm: %s\n", remove.DisplayName(notice))
+            }
+        }
+        if remove.In("--time", flags) || veryVerboseFlag {
+            for _, line := range remover.CheckTimes() {
+                fmt.Fprintf(os.Stderr, "srm: %s\n", line)
+            }
+        }
+        if trashed > 0 && !quietFlag {
+            if notice := firstRunNotice(firstTrashed); notice != "" {
+                fmt.Fprintf(os.Stderr, "srm: %s\n", remove.DisplayName(notice))
+            }
+            fmt.Fprintf(os.Stderr, "operation %s\n", opts.Op)
+        }
+    }
+
+    // operands inside another operand go with it, whichever order they came
+    // in, as long as it really goes
+    covers := remove.CoveringOperands(files, opts.Recursive)
+    failed := invalidOperands
+    removed := 0
+    // stranded are the operands a trash turning read-only left in place,
+    // summed up once at the end rather than each failing the same way
+    stranded := []string{}
+    report := func(i int, result remove.Result) {
+        // rm has already removed a covered operand by the time it gets to
+        // it, and -f says nothing about missing operands
+        covered := result.Status() == remove.StatusCovered
+        if remove.POSIX && (covered || errors.Is(result.Err, remove.ErrNotFound)) {
+            if !opts.Force {
+                fmt.Fprintf(os.Stderr, "srm: %s\n", remove.DisplayName(posixMessage(result.Source, remove.ErrNotFound)))
+                failed = true
+            }
+            return
+        }
+        if covered {
+            fmt.Fprintf(os.Stderr, "srm: %s\n", remove.DisplayName(result.Err.Error()))
+            return
+        }
+        // -f means a missing operand is neither reported nor a failure
+        if opts.Force && remove.IsMissing(result.Err) {
+            return
+        }
+        // like rm, a failed operand doesn't stop the rest, it only makes
+        // the exit status 1. A protected one says why it was skipped, but
+        // skips never change the exit status.
+        status := result.Status()
+        if status == remove.StatusTrashLost {
+            stranded = append(stranded, result.Source)
+            return
+        }
+        fails := status.Info().Fails
+        // --dry-run has already said what would happen
+        if (fails || status == remove.StatusSkippedProtected) && !dryRun {
+            for _, msg := range failureMessages(result.Source, result.Err) {
+                fmt.Fprintf(os.Stderr, "srm: %s\n", remove.DisplayName(msg))
+            }
+        }
+        failed = failed || fails
+        if status == remove.StatusTrashed || status == remove.StatusDeleted {
+            removed++
+        }
+    }
+
+    if remove.In("--biggest-first", flags) {
+        // a bar of the bytes handled so far, to stop at once enough is freed
+        var handled, total int64
+        bar := func(done, total int64) {}
+        if redrawable(os.Stderr) {
+            width, _ := remove.TerminalSize()
+            bar = progressBar(os.Stderr, width)
+        }
+        progress := func(done, all int64) {
+            handled, total = done, all
+            bar(done, total)
+        }
+
+        left := remover.RemoveBiggestFirst(files, covers, report, progress)
+        if left != nil {
+            bar(total, total)
+            fmt.Fprintf(os.Stderr, "srm: interrupted after removing %d entries (%s), these were not removed:\n", removed, remove.FormatSize(handled))
+            for _, path := range left {
+                fmt.Fprintf(os.Stderr, "  %s\n", remove.DisplayName(remove.DisplayPath(path)))
+            }
+            finish()
+            os.Exit(130)
+        }
+    } else if batchStdin {
+        n := 0
+        err := readNULBatches(os.Stdin, func(paths []string) {
+            batch := []string{}
+            for _, path := range paths {
+                n++
+                if err := remove.CheckOperand(path); err != nil {
+                    if !opts.Force {
+                        fmt.Fprintf(os.Stderr, "srm: %s (path %d on stdin)\n", remove.DisplayName(err.Error()), n)
+                        failed = true
+                    }
+                    continue
+                }
+                batch = append(batch, path)
+            }
+            remover.RemoveEach(batch, remove.CoveringOperands(batch, opts.Recursive), report)
+        })
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "srm: reading stdin: %s\n", err)
+            failed = true
+        }
+    } else {
+        remover.RemoveEach(files, covers, report)
+    }
 
-        if verboseFlag {
-            fmt.Println(filename)
+    // a at an -i prompt takes back the whole run
+    if remover.Aborted() {
+        restored, errs := remover.PutBackSession()
+        fmt.Fprintf(os.Stderr, "srm: aborted, put back %d operand(s) trashed this run\n", restored)
+        for _, err := range errs {
+            fmt.Fprintf(os.Stderr, "srm: not put back: %s\n", remove.DisplayName(err.Error()))
         }
+        finish()
+        os.Exit(1)
+    }
+
+    if len(stranded) > 0 {
+        lost := []string{}
+        for _, trash := range remover.LostTrashes() {
+            lost = append(lost, remove.DisplayPath(trash))
+        }
+        fmt.Fprintf(os.Stderr, "srm: %s turned read-only and no other trash was usable, these %d were left where they are:\n", remove.DisplayName(strings.Join(lost, ", ")), len(stranded))
+        for _, path := range stranded {
+            fmt.Fprintf(os.Stderr, "  %s\n", remove.DisplayName(remove.DisplayPath(path)))
+        }
+        // as when there was no trash to begin with
+        question := fmt.Sprintf("permanently remove these %d? this cannot be undone ", len(stranded))
+        if onNoTrash == "permanent" && (opts.SkipsPrompt("permanent") || remove.GetUserConfirmation(question)) {
+            permanentOpts := opts
+            permanentOpts.TrashDir, permanentOpts.Permanent = "", true
+            left := stranded
+            stranded = []string{}
+            permanentRemover := remove.NewRemover(permanentOpts)
+            permanentRemover.RemoveEach(left, remove.CoveringOperands(left, opts.Recursive), report)
+            permanentRemover.Close()
+        }
+    }
+
+    // keep the trash under its max_entries cap, which safe mode only warns
+    // about, eviction being permanent
+    if opts.Index != nil && !dryRun {
+        settings, err := remove.LoadSettings()
+        if err == nil && opts.SafeMode {
+            var count, limit int
+            count, limit, err = entriesOverCap(settings.Config, targetDir)
+            if limit > 0 {
+                fmt.Fprintf(os.Stderr, "srm: %s holds %d entries, over max_entries of %d; safe mode evicts none\n", remove.DisplayPath(targetDir), count, limit)
+            }
+        } else if err == nil {
+            var evictions []remove.Result
+            evictions, err = enforceEntryCap(remove.OSFS{}, opts.Index, settings.Config, targetDir)
+            for _, result := range evictions {
+                journal.Record(result)
+                if result.Err != nil {
+                    fmt.Fprintf(os.Stderr, "srm: evict %s: %s\n", remove.DisplayName(result.Dest), result.Err)
+                } else if verboseFlag && formatter == nil {
+                    fmt.Printf("evicted %s\n", remove.DisplayName(result.Dest))
+                }
+            }
+        }
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "srm: max_entries: %s\n", err)
+        }
+    }
 
-        os.Rename(filepath, dest)
+    finish()
+    if len(stranded) > 0 {
+        os.Exit(EXITTRASHLOST)
+    }
+    if failed || journal.Refused() {
+        os.Exit(1)
     }
 }
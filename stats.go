@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// DayStats is what srm did in one day, local time, summed up from the
+// journal. Bytes are what the journal recorded, which leaves out the size
+// of directories unless something asked for it.
+type DayStats struct {
+	Day string `json:"day"` // 2006-01-02
+	// Operations counts the runs that removed or restored anything
+	Operations int `json:"operations"`
+	// Files counts what was trashed or deleted for good
+	Files    int   `json:"files"`
+	Trashed  int64 `json:"bytes_trashed"`
+	Deleted  int64 `json:"bytes_deleted"`
+	Restores int   `json:"restores"`
+	// Rotated marks a day the journal still has the rest of: these are
+	// only its records rotated out of it, which the journal's add to
+	Rotated bool `json:"rotated,omitempty"`
+}
+
+// add is s with more's counts added to it
+func (s DayStats) add(more DayStats, sign int) DayStats {
+	s.Operations += sign * more.Operations
+	s.Files += sign * more.Files
+	s.Trashed += int64(sign) * more.Trashed
+	s.Deleted += int64(sign) * more.Deleted
+	s.Restores += sign * more.Restores
+	return s
+}
+
+// STATSDAY is how a DayStats' Day is written
+const STATSDAY = "2006-01-02"
+
+// SPARKS are the bars of srm stats' chart, lowest first
+var SPARKS = []rune("▁▂▃▄▅▆▇█")
+
+func statsPath() (string, error) {
+	dir, err := remove.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "stats"), nil
+}
+
+// journalStats sums up the journal generations files, every one when nil,
+// by day. first is the day of the oldest record, which the journal may only
+// have part of once older generations were rotated away; empty for an
+// empty journal.
+func journalStats(files []string) (days map[string]DayStats, first string, err error) {
+	if files == nil {
+		if files, err = journalFiles(); err != nil {
+			return nil, "", err
+		}
+	}
+	days = map[string]DayStats{}
+	counted := map[string]bool{}
+	err = readJournalFiles(files, func(record JournalRecord) {
+		day := record.Time.Local().Format(STATSDAY)
+		if first == "" || day < first {
+			first = day
+		}
+		if record.Kind != "file" || record.Error != "" {
+			return
+		}
+
+		stats := days[day]
+		switch {
+		case record.Action == "trashed":
+			stats.Files++
+			stats.Trashed += record.Bytes
+		case remove.In(record.Action, remove.DELETEDACTIONS):
+			stats.Files++
+			stats.Deleted += record.Bytes
+		case record.Action == "restored":
+			stats.Restores++
+		default:
+			return
+		}
+		// an operation counts on the day it first did something
+		if !counted[record.Op] {
+			counted[record.Op] = true
+			stats.Operations++
+		}
+		stats.Day = day
+		days[day] = stats
+	})
+	return days, first, err
+}
+
+// mergeStats folds what the journal has now into the days stored before.
+// The journal is complete after its first day and wins there. Its first
+// day may be cut short by rotation, and anything before it is gone, so
+// there a stored day srm rotated part of away is added to, and one rotated
+// by something else stays unless the journal's has more operations.
+func mergeStats(stored map[string]DayStats, journal map[string]DayStats, first string) map[string]DayStats {
+	merged := map[string]DayStats{}
+	for day, stats := range stored {
+		stats.Rotated = false
+		merged[day] = stats
+	}
+	for day, stats := range journal {
+		old, ok := merged[day]
+		switch {
+		case ok && day == first && stored[day].Rotated:
+			merged[day] = old.add(stats, 1)
+		case !ok || day > first || stats.Operations >= old.Operations:
+			merged[day] = stats
+		}
+	}
+	return merged
+}
+
+// foldRotated brings the stats file up to date before the journal
+// generations dropping are removed. Days before what is left of the journal
+// are stored whole; the day it starts partway through, or the last day when
+// none of it is left, is stored as the part rotated away, Rotated, so
+// mergeStats adds the rest from the journal.
+func foldRotated(dropping []string) error {
+	path, err := statsPath()
+	if err != nil {
+		return err
+	}
+	stored, err := loadStats(path)
+	if err != nil {
+		return err
+	}
+	files, err := journalFiles()
+	if err != nil {
+		return err
+	}
+	all, first, err := journalStats(files)
+	if err != nil {
+		return err
+	}
+	merged := mergeStats(stored, all, first)
+
+	left := []string{}
+	for _, name := range files {
+		if !remove.In(name, dropping) {
+			left = append(left, name)
+		}
+	}
+	rest, restFirst, err := journalStats(left)
+	if err != nil {
+		return err
+	}
+	days := map[string]DayStats{}
+	if restFirst == "" {
+		// nothing is left, and the journal may yet go on with the last day
+		sorted := sortedDays(merged)
+		if len(sorted) > 0 {
+			restFirst = sorted[len(sorted)-1]
+		}
+	}
+	for day, stats := range merged {
+		switch {
+		case day < restFirst:
+			days[day] = stats
+		case day == restFirst:
+			if part := stats.add(rest[day], -1); part != (DayStats{Day: day}) {
+				part.Rotated = true
+				days[day] = part
+			}
+		}
+	}
+	return writeStats(path, days)
+}
+
+// loadStats reads the stats file, a DayStats per line. A missing file has
+// no days, and lines that don't parse are skipped.
+func loadStats(path string) (map[string]DayStats, error) {
+	days := map[string]DayStats{}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return days, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var stats DayStats
+		if json.Unmarshal(scanner.Bytes(), &stats) == nil && stats.Day != "" {
+			days[stats.Day] = stats
+		}
+	}
+	return days, scanner.Err()
+}
+
+// writeStats replaces the stats file with days, oldest first
+func writeStats(path string, days map[string]DayStats) error {
+	var buf bytes.Buffer
+	for _, day := range sortedDays(days) {
+		line, err := json.Marshal(days[day])
+		if err != nil {
+			return err
+		}
+		buf.Write(append(line, '\n'))
+	}
+
+	if err := remove.MkdirPrivate(filepath.Dir(path)); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".stats-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := remove.PrivateMode(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func sortedDays(days map[string]DayStats) []string {
+	keys := []string{}
+	for day := range days {
+		keys = append(keys, day)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// currentStats is the stats file brought up to date with the journal
+func currentStats() (map[string]DayStats, error) {
+	path, err := statsPath()
+	if err != nil {
+		return nil, err
+	}
+	stored, err := loadStats(path)
+	if err != nil {
+		return nil, err
+	}
+	journal, first, err := journalStats(nil)
+	if err != nil {
+		return nil, err
+	}
+	return mergeStats(stored, journal, first), nil
+}
+
+// updateStats writes the journal's days into the stats file, so they
+// outlive the journal generations they came from
+func updateStats(index *remove.Index, journal *Journal) (string, error) {
+	path, err := statsPath()
+	if err != nil {
+		return "", err
+	}
+	stored, err := loadStats(path)
+	if err != nil {
+		return "", err
+	}
+	days, err := currentStats()
+	if err != nil {
+		return "", err
+	}
+	// the journal still has the rest of a day that was partly rotated away,
+	// which must stay only that part to be added to
+	for day, stats := range stored {
+		if stats.Rotated {
+			days[day] = stats
+		}
+	}
+	if err := writeStats(path, days); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d days recorded", len(days)), nil
+}
+
+// sparkline draws values as one bar each, scaled to the largest; nothing
+// at all is a blank rather than the lowest bar
+func sparkline(values []int64) string {
+	peak := int64(0)
+	for _, v := range values {
+		peak = max(peak, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		if v <= 0 {
+			b.WriteRune(' ')
+			continue
+		}
+		b.WriteRune(SPARKS[(v*int64(len(SPARKS))-1)/peak])
+	}
+	return b.String()
+}
+
+// statsCommand
+// srm stats [--days N] [--json]
+// prints what was trashed, deleted and restored each of the last N days
+// (30), today included, with a chart of the bytes removed a day. Days with
+// nothing are shown as such. --json prints a DayStats per line instead.
+func statsCommand(args []string) {
+	flags, rest := parseArgs(args)
+	if len(rest) > 0 {
+		fmt.Fprintf(os.Stderr, "srm stats: unexpected argument %s\n", rest[0])
+		os.Exit(1)
+	}
+	count := 30
+	if value, ok := remove.FlagValue("--days", flags); ok {
+		var err error
+		if count, err = strconv.Atoi(value); err != nil || count < 1 {
+			fmt.Fprintf(os.Stderr, "srm stats: invalid --days %q, expected a positive count\n", value)
+			os.Exit(1)
+		}
+	}
+
+	all, err := currentStats()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm stats: %s\n", err)
+		os.Exit(1)
+	}
+
+	// every day of the range, oldest first, by the calendar rather than 24h
+	// steps so a DST change can't skip or repeat one
+	now := time.Now()
+	days := make([]DayStats, count)
+	for i := range days {
+		day := time.Date(now.Year(), now.Month(), now.Day()-(count-1-i), 0, 0, 0, 0, time.Local).Format(STATSDAY)
+		days[i] = all[day]
+		days[i].Day = day
+	}
+
+	if remove.In("--json", flags) {
+		for _, stats := range days {
+			line, err := json.Marshal(stats)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "srm stats: %s\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(line))
+		}
+		return
+	}
+
+	total := DayStats{Day: "total"}
+	removed := []int64{}
+	fmt.Printf("%-10s  %5s  %7s  %10s  %10s  %8s\n", "day", "ops", "files", "trashed", "deleted", "restores")
+	for _, stats := range days {
+		fmt.Printf("%-10s  %5d  %7d  %10s  %10s  %8d\n", stats.Day, stats.Operations, stats.Files, remove.FormatSize(stats.Trashed), remove.FormatSize(stats.Deleted), stats.Restores)
+		total.Operations += stats.Operations
+		total.Files += stats.Files
+		total.Trashed += stats.Trashed
+		total.Deleted += stats.Deleted
+		total.Restores += stats.Restores
+		removed = append(removed, stats.Trashed+stats.Deleted)
+	}
+	fmt.Printf("%-10s  %5d  %7d  %10s  %10s  %8d\n", total.Day, total.Operations, total.Files, remove.FormatSize(total.Trashed), remove.FormatSize(total.Deleted), total.Restores)
+	fmt.Printf("bytes/day  %s  (peak %s)\n", sparkline(removed), remove.FormatSize(slices.Max(removed)))
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// The stats file keeps the days rotated out of the journal; where both
+// have a day, the journal's count wins from its first day on
+func TestMergeStats(t *testing.T) {
+	stored := map[string]DayStats{
+		"2024-06-01": {Day: "2024-06-01", Operations: 4, Files: 9, Trashed: 900},
+		"2024-06-02": {Day: "2024-06-02", Operations: 5, Files: 5, Trashed: 500},
+		"2024-06-03": {Day: "2024-06-03", Operations: 1, Files: 1, Trashed: 100},
+	}
+	// the journal starts partway through the 2nd, and has more of the 3rd
+	journal := map[string]DayStats{
+		"2024-06-02": {Day: "2024-06-02", Operations: 2, Files: 2, Trashed: 200},
+		"2024-06-03": {Day: "2024-06-03", Operations: 1, Files: 3, Deleted: 300},
+		"2024-06-04": {Day: "2024-06-04", Operations: 1, Restores: 1},
+	}
+	merged := mergeStats(stored, journal, "2024-06-02")
+	for day, want := range map[string]DayStats{
+		"2024-06-01": stored["2024-06-01"],
+		"2024-06-02": stored["2024-06-02"],
+		"2024-06-03": journal["2024-06-03"],
+		"2024-06-04": journal["2024-06-04"],
+	} {
+		if merged[day] != want {
+			t.Errorf("%s: %+v, want %+v", day, merged[day], want)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "stats")
+	if err := writeStats(path, merged); err != nil {
+		t.Fatal(err)
+	}
+	read, err := loadStats(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != len(merged) || read["2024-06-03"] != merged["2024-06-03"] {
+		t.Errorf("the stats file read back as %+v", read)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	if chart := sparkline([]int64{0, 1, 50, 100}); chart != " ▁▄█" {
+		t.Errorf("sparkline is %q, want \" ▁▄█\"", chart)
+	}
+}
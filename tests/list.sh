@@ -0,0 +1,68 @@
+#!/bin/sh
+# list.sh checks srm --list against a scratch home: what it prints for an
+# empty trash, for a trash holding entries srm recorded and ones it didn't,
+# with a pattern, and when the trash can't be read.
+#
+#   go build -o /tmp/srm . && SRM=/tmp/srm tests/list.sh
+#
+# The unreadable trash is skipped as root, who is never denied access.
+
+SRM=${SRM:-./srm}
+SRM=$(cd "$(dirname "$SRM")" && pwd)/$(basename "$SRM")
+
+work=$(mktemp -d)
+trap 'chmod -R u+rwx "$work"; rm -rf "$work"' EXIT
+failures=0
+
+# check NAME STATUS STDOUT-PATTERN STDERR-PATTERN ARGS... runs srm ARGS in
+# the scratch home and greps its output for the patterns, "" matching
+# empty output
+check() {
+	name=$1 status=$2 out=$3 err=$4
+	shift 4
+
+	(cd "$work/cwd" && HOME=$work/home "$SRM" "$@") >"$work/out" 2>"$work/err"
+	got=$?
+	problem=
+	if [ "$got" -ne "$status" ]; then
+		problem="exit status $got, expected $status"
+	elif [ -z "$out" ] && [ -s "$work/out" ]; then
+		problem="unexpected output"
+	elif [ -n "$out" ] && ! grep -q -- "$out" "$work/out"; then
+		problem="output doesn't match $out"
+	elif [ -z "$err" ] && [ -s "$work/err" ]; then
+		problem="unexpected diagnostics"
+	elif [ -n "$err" ] && ! grep -q -- "$err" "$work/err"; then
+		problem="diagnostics don't match $err"
+	fi
+	if [ -n "$problem" ]; then
+		echo "FAIL $name: $problem"
+		sed 's/^/    out: /' "$work/out"
+		sed 's/^/    err: /' "$work/err"
+		failures=$((failures + 1))
+		return
+	fi
+	echo "ok   $name"
+}
+
+mkdir -p "$work/home/.Trash" "$work/cwd"
+check "empty trash" 0 "" "is empty$" --list
+
+(cd "$work/cwd" && mkdir dir && echo log >app.log && touch dir/file &&
+	HOME=$work/home "$SRM" --quiet -r app.log dir) || exit 1
+echo stray >"$work/home/.Trash/stray"
+check "entry with its origin" 0 "^- .*app\.log  (from $work/cwd/app\.log)$" "" --list
+check "directory entry" 0 "^d .* dir  (from $work/cwd/dir)$" "" --list
+check "entry srm didn't trash" 0 "^- .*?  *stray$" "" --list
+check "pattern" 0 "app\.log" "" --list '*.log'
+check "pattern leaves out the rest" 0 "" "nothing in .* matches$" --list '*.txt'
+
+if [ "$(id -u)" -ne 0 ]; then
+	chmod 300 "$work/home/.Trash"
+	check "trash that can't be read" 1 "" "permission denied" --list
+	chmod 700 "$work/home/.Trash"
+else
+	echo "skip trash that can't be read (running as root)"
+fi
+
+[ "$failures" -eq 0 ]
@@ -0,0 +1,103 @@
+#!/bin/sh
+# rm-parity.sh runs the same scenarios through rm and srm and compares exit
+# codes and diagnostics, with the "rm: "/"srm: " prefix stripped. The POSIX
+# scenarios run srm with SRM_POSIX=1 and compare its output and prompts too.
+#
+#   go build -o /tmp/srm . && SRM=/tmp/srm tests/rm-parity.sh
+#
+# rm defaults to the one on PATH; scenarios that need an unprivileged user are
+# skipped as root, who is never denied access.
+
+SRM=${SRM:-./srm}
+RM=${RM:-rm}
+SRM=$(cd "$(dirname "$SRM")" && pwd)/$(basename "$SRM")
+
+work=$(mktemp -d)
+trap 'chmod -R u+w "$work"; rm -rf "$work"' EXIT
+failures=0
+posix=
+input=
+
+# setup builds the scenario's tree in the current directory
+# scenario NAME SETUP ARGS... runs ARGS through both in fresh copies of SETUP,
+# with $input on stdin, where srm's own mode is told to read answers from
+scenario() {
+	name=$1 setup=$2
+	shift 2
+
+	for tool in rm srm; do
+		dir=$work/$tool
+		rm -rf "$dir"
+		mkdir -p "$dir/home/.Trash" "$dir/cwd"
+		(cd "$dir/cwd" && eval "$setup")
+		if [ "$tool" = rm ]; then
+			(cd "$dir/cwd" && printf %s "$input" | "$RM" "$@") >"$dir/out" 2>"$dir/err"
+		elif [ -n "$posix" ]; then
+			(cd "$dir/cwd" && printf %s "$input" | HOME=$dir/home SRM_POSIX=1 "$SRM" "$@") >"$dir/out" 2>"$dir/err"
+		else
+			(cd "$dir/cwd" && printf %s "$input" | HOME=$dir/home SRM_ANSWERS=/dev/stdin "$SRM" --quiet "$@") >"$dir/out" 2>"$dir/err"
+		fi
+		echo $? >"$dir/status"
+		sed -e 's/^rm: //' -e 's/^srm: //' -e "s/'s\{0,1\}rm --help'/'rm --help'/" "$dir/err" >"$dir/msg"
+		(cd "$dir/cwd" && find . | sort) >"$dir/tree"
+	done
+
+	parts="status msg tree"
+	if [ -n "$posix" ]; then
+		parts="$parts out"
+	fi
+	for part in $parts; do
+		if ! cmp -s "$work/rm/$part" "$work/srm/$part"; then
+			echo "FAIL $name: $part differs"
+			diff "$work/rm/$part" "$work/srm/$part" | sed 's/^/    /'
+			failures=$((failures + 1))
+			return
+		fi
+	done
+	echo "ok   $name"
+}
+
+scenario "directory without -r" 'mkdir dir' dir
+scenario "directory without -r keeps going" 'mkdir dir; touch file' dir file
+scenario "missing operand keeps going" 'touch file' missing file
+scenario "-f on a missing operand" 'touch file' -f missing file
+scenario "a path through a file" 'touch file' file/x
+scenario "-f on a path through a file" 'touch file' -f file/x
+scenario "-d on an empty directory" 'mkdir dir' -d dir
+scenario "-d on a non-empty directory" 'mkdir dir; touch dir/file' -d dir
+scenario "-r on a directory" 'mkdir -p dir/sub; touch dir/sub/file' -r dir
+scenario "-rf on a directory" 'mkdir -p dir/sub; touch dir/sub/file' -rf dir
+scenario "-r on ." 'touch file' -r .
+scenario "-rf on ./ and a file" 'touch file' -rf ./ file
+scenario "-r on dir/.." 'mkdir dir; touch dir/file' -r dir/..
+scenario "a file named like flags after --" 'touch ./-rf file' -- -rf file
+scenario "a symlink to a directory without -r" 'mkdir dir; touch dir/file; ln -s dir link' link
+scenario "a dangling symlink" 'ln -s missing link; touch file' link file
+scenario "-f on a dangling symlink" 'ln -s missing link' -f link
+
+if [ "$(id -u)" -ne 0 ]; then
+	scenario "-r under a parent denying access" 'mkdir -p p/c; chmod 555 p' -r p/c
+else
+	echo "skip -r under a parent denying access (running as root)"
+fi
+
+# POSIX mode: everything rm says, asks and prints, down to the wording
+posix=1
+scenario "posix: -f on a missing operand" '' -f missing
+scenario "posix: -f with no operands" '' -f
+scenario "posix: no operands" ''
+scenario "posix: missing operand" 'touch file' missing file
+scenario "posix: empty operand" 'touch file' '' file
+scenario "posix: repeated operand" 'touch file' file file
+scenario "posix: directory without -r" 'mkdir dir' dir
+scenario "posix: -v on a file and an empty directory" 'mkdir dir; touch file' -v -d file dir
+scenario "posix: -i then -f" 'touch file' -i -f file
+input=y
+scenario "posix: -i accepted" 'echo data >file' -i file
+scenario "posix: -i on a symlink to a read-only directory" 'mkdir dir; chmod a-w dir; ln -s dir link' -i link
+input=n
+scenario "posix: -f then -i declined" 'touch file' -f -i file
+scenario "posix: write-protected file, input not a terminal" 'touch file; chmod a-w file' file
+posix= input=
+
+[ "$failures" -eq 0 ]
@@ -0,0 +1,98 @@
+#!/bin/sh
+# throughput.sh measures how fast srm trashes files fed to it by find and
+# xargs, in a scratch home whose index already holds ROWS entries: one srm
+# per file as find -exec runs it, xargs -0 -P4 srm -f, and one srm
+# --batch-stdin. Then the files all share one name, each in a directory of
+# its own, so the srms xargs -P8 runs side by side all want the same names
+# in the trash. Each way must leave every file in the trash, with its own
+# contents, and indexed, or it fails. With BASELINE set to another srm, that
+# one is measured the same way first, to compare against; it is run without
+# --batch-stdin, which it may not have.
+#
+#   go build -o /tmp/srm . && SRM=/tmp/srm tests/throughput.sh [COUNT [ROWS]]
+#   SRM=/tmp/srm BASELINE=/tmp/srm-old tests/throughput.sh 2000 500000
+#
+# It needs a date that knows %N, as GNU date does.
+
+SRM=${SRM:-./srm}
+SRM=$(cd "$(dirname "$SRM")" && pwd)/$(basename "$SRM")
+count=${1:-1000}
+rows=${2:-100000}
+
+work=$(mktemp -d)
+trap 'rm -rf "$work"' EXIT
+failures=0
+
+# fresh LAYOUT makes an empty scratch home whose index has $rows entries of
+# a trash elsewhere, and $count files to remove, each holding its number:
+# f0, f1, ... with LAYOUT unique, d0/app.log, d1/app.log, ... with same
+fresh() {
+	rm -rf "$work/home" "$work/files"
+	mkdir -p "$work/home/.local/share/srm" "$work/files"
+	awk -v n="$rows" 'BEGIN {
+		for (i = 0; i < n; i++)
+			printf "{\"id\":\"%08x\",\"trash\":\"/elsewhere/.Trash\",\"name\":\"old%d\",\"origin\":\"/elsewhere/old%d\",\"deleted\":\"2024-01-01T00:00:00Z\",\"size\":1}\n", i, i, i
+	}' >"$work/home/.local/share/srm/index"
+	i=0
+	while [ "$i" -lt "$count" ]; do
+		if [ "$1" = same ]; then
+			mkdir "$work/files/d$i"
+			echo "$i" >"$work/files/d$i/app.log"
+		else
+			echo "$i" >"$work/files/f$i"
+		fi
+		i=$((i + 1))
+	done
+}
+
+# measure NAME SRM LAYOUT COMMAND... runs COMMAND, which is fed the files
+# of LAYOUT NUL terminated on stdin with $srm naming the srm to run, and
+# reports files and srm invocations per second
+measure() {
+	name=$1 srm=$2 layout=$3
+	shift 3
+	fresh "$layout"
+	invocations=$work/invocations
+	: >"$invocations"
+	start=$(date +%s.%N)
+	(cd "$work" && find files -type f -print0 | HOME=$work/home SRM_RUN=$srm COUNTER=$invocations sh -c "$*")
+	end=$(date +%s.%N)
+	runs=$(wc -l <"$invocations")
+	indexed=$(HOME=$work/home "$srm" list | wc -l)
+	left=$(find "$work/files" -type f | wc -l)
+	# a payload replaced by another with the same name leaves fewer
+	kept=$(cat "$work/home/.local/share/Trash/files"/* | sort -u | wc -l)
+	awk -v name="$name" -v s="$start" -v e="$end" -v n="$count" -v r="$runs" \
+		'BEGIN { t = e - s; printf "%-44s %6.2fs %8.0f files/s %6d srms %7.0f srms/s\n", name, t, n / t, r, r / t }'
+	if [ "$indexed" -ne "$count" ] || [ "$left" -ne 0 ] || [ "$kept" -ne "$count" ]; then
+		echo "FAIL $name: $indexed of $count indexed, $kept kept in the trash, $left left in place"
+		failures=$((failures + 1))
+	fi
+}
+
+# the srm each way runs, counting its invocations
+cat >"$work/run" <<'EOF'
+#!/bin/sh
+echo >>"$COUNTER"
+exec "$SRM_RUN" "$@"
+EOF
+chmod +x "$work/run"
+run=$work/run
+
+echo "$count files, $rows entries already in the index"
+for srm in $BASELINE "$SRM"; do
+	label=$(basename "$srm")
+	measure "$label: one srm per file (find -exec)" "$srm" unique "xargs -0 -n1 $run -f --quiet"
+	measure "$label: xargs -0 -n8 -P4" "$srm" unique "xargs -0 -n8 -P4 $run -f --quiet"
+	measure "$label: xargs -0 -P4" "$srm" unique "xargs -0 -P4 $run -f --quiet"
+	if [ "$srm" = "$SRM" ]; then
+		measure "$label: --batch-stdin" "$srm" unique "$run -f --quiet --batch-stdin"
+	fi
+	measure "$label: same names, xargs -0 -n1 -P8" "$srm" same "xargs -0 -n1 -P8 $run -f --quiet"
+	measure "$label: same names, xargs -0 -n20 -P8" "$srm" same "xargs -0 -n20 -P8 $run -f --quiet"
+done
+
+if [ "$failures" -gt 0 ]; then
+	echo "$failures failed"
+	exit 1
+fi
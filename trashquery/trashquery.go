@@ -0,0 +1,543 @@
+// Package trashquery answers questions about what srm has put in the trash,
+// like how big it is, what the oldest entry is or whether retention is
+// keeping up, without running srm and parsing what it prints.
+//
+// It only ever reads srm's index: the file and the segments in index.d that
+// srms append to before their rows are folded into it, read after it in
+// name order. They are opened read-only, no lock is taken, and nothing is
+// rebuilt or set aside, so it is safe to call while another srm is
+// trashing, emptying or compacting. A query sees the index as it was when it
+// started. Rows that don't parse, like a last one still being written, are
+// left out rather than reported.
+//
+// # Compatibility
+//
+// The read paths here are srm's API for other programs and are kept
+// compatible: ListEntries, At, Stats and Locations, the Index, Entry,
+// Filter and LocationStats types and their fields keep their names and
+// meanings. Fields may be added. An index written by a newer srm is read
+// for the fields this package knows, as srm itself reads it. Package
+// remove is srm's other API; nothing else in srm is one.
+//
+// SplitRows and LiveRows are how srm itself reads its row files, so that
+// the two can't come to disagree about what a row is or which are live.
+// They follow srm's format rather than being kept compatible.
+package trashquery
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"hash/fnv"
+	"io"
+	"iter"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MAXROW is the longest index row read, as srm itself reads them
+const MAXROW = 16 * 1024 * 1024
+
+// folding is the suffix srm gives a segment while folding it into the index
+const folding = ".folding"
+
+// Entry is one thing srm put into a trash and hasn't seen leave it
+type Entry struct {
+	ID string
+	// Location is the trash directory the entry is in
+	Location string
+	// Name is the entry's name inside Location
+	Name string
+	// Origin is the absolute path it was removed from
+	Origin  string
+	Deleted time.Time
+	// Size is what it took up when it was removed, 0 when srm didn't record it
+	Size  int64
+	IsDir bool
+	// Archive is the archive format, as in "tar.gz", when a directory was
+	// trashed as a single tarball
+	Archive string
+	// Reason is the --reason note given with it
+	Reason string
+	// Offset is where the entry's row starts in the index, for At
+	Offset int64
+}
+
+// Payload is the full path of the entry in its trash
+func (e Entry) Payload() string {
+	return filepath.Join(e.Location, e.Name)
+}
+
+// Filter picks the entries ListEntries yields; its zero value picks them all
+type Filter struct {
+	// Location keeps the entries in this trash directory
+	Location string
+	// Pattern keeps the entries whose Name matches this filepath.Match glob
+	Pattern string
+	// Since and Before keep the entries deleted at or after Since and
+	// before Before, each ignored when zero
+	Since, Before time.Time
+}
+
+// Match reports whether e passes f
+func (f Filter) Match(e Entry) bool {
+	if f.Location != "" && filepath.Clean(f.Location) != e.Location {
+		return false
+	}
+	if f.Pattern != "" {
+		if ok, _ := filepath.Match(f.Pattern, e.Name); !ok {
+			return false
+		}
+	}
+	if !f.Since.IsZero() && e.Deleted.Before(f.Since) {
+		return false
+	}
+	if !f.Before.IsZero() && !e.Deleted.Before(f.Before) {
+		return false
+	}
+	return true
+}
+
+// LocationStats sums up the entries of one trash directory
+type LocationStats struct {
+	Location string
+	Count    int
+	// Bytes adds up the Size of each entry
+	Bytes int64
+	// Oldest and Newest are the earliest and latest deletion times
+	Oldest, Newest time.Time
+}
+
+// Index is srm's index of trashed entries, opened for reading only
+type Index struct {
+	path string
+}
+
+// DefaultPath is where srm keeps its index: srm/index under
+// $XDG_DATA_HOME, or ~/.local/share when that isn't set
+func DefaultPath() (string, error) {
+	if xdg := os.Getenv("XDG_DATA_HOME"); xdg != "" {
+		return filepath.Join(xdg, "srm", "index"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "srm", "index"), nil
+}
+
+// Open returns the index at path. Nothing is read until it is queried, and
+// an index that doesn't exist yet has no entries.
+func Open(path string) *Index {
+	return &Index{path: path}
+}
+
+// OpenDefault returns the index at DefaultPath
+func OpenDefault() (*Index, error) {
+	path, err := DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	return Open(path), nil
+}
+
+// ListEntries yields the entries f picks, in the order they were trashed,
+// stopping at the first error, which it yields with a zero Entry. Only a
+// few bytes per row are held, however big the index.
+func ListEntries(f Filter) iter.Seq2[Entry, error] {
+	ix, err := OpenDefault()
+	if err != nil {
+		return func(yield func(Entry, error) bool) { yield(Entry{}, err) }
+	}
+	return ix.ListEntries(f)
+}
+
+// Stats sums up the default index, see Index.Stats
+func Stats() ([]LocationStats, error) {
+	ix, err := OpenDefault()
+	if err != nil {
+		return nil, err
+	}
+	return ix.Stats()
+}
+
+// Locations lists the default index's trash directories, see
+// Index.Locations
+func Locations() ([]string, error) {
+	ix, err := OpenDefault()
+	if err != nil {
+		return nil, err
+	}
+	return ix.Locations()
+}
+
+// ListEntries is the package's ListEntries for ix
+func (ix *Index) ListEntries(f Filter) iter.Seq2[Entry, error] {
+	return func(yield func(Entry, error) bool) {
+		snap, err := ix.open()
+		if err != nil {
+			yield(Entry{}, err)
+			return
+		}
+		defer snap.Close()
+
+		// both passes read the same bytes, however much is appended meanwhile
+		live, _, err := LiveRows(snap.reader())
+		if err != nil {
+			yield(Entry{}, err)
+			return
+		}
+
+		var row uint32
+		var offset int64
+		scanner := rowScanner(snap.reader())
+		for scanner.Scan() && len(live) > 0 {
+			line := scanner.Bytes()
+			lineOffset, thisRow := offset, row
+			offset += int64(len(line)) + 1
+			row++
+			if thisRow != live[0] {
+				continue
+			}
+			live = live[1:]
+
+			entry, ok := decodeRow(line, lineOffset)
+			if !ok || !f.Match(entry) {
+				continue
+			}
+			if !yield(entry, nil) {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			yield(Entry{}, err)
+		}
+	}
+}
+
+// At reads the entry whose row starts at offset, as handed out in
+// Entry.Offset. srm may have compacted the index or folded its segments
+// since, so callers check it is the entry they expected.
+func (ix *Index) At(offset int64) (Entry, error) {
+	snap, err := ix.open()
+	if err != nil {
+		return Entry{}, err
+	}
+	defer snap.Close()
+	section, err := snap.section(offset)
+	if err != nil {
+		return Entry{}, err
+	}
+	line, err := bufio.NewReader(section).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return Entry{}, err
+	}
+	var r row
+	if err := json.Unmarshal(line, &r); err != nil {
+		return Entry{}, err
+	}
+	return r.entry(offset), nil
+}
+
+// Stats sums up the entries of each trash directory, ordered by Location
+func (ix *Index) Stats() ([]LocationStats, error) {
+	byLocation := map[string]*LocationStats{}
+	for entry, err := range ix.ListEntries(Filter{}) {
+		if err != nil {
+			return nil, err
+		}
+		s, ok := byLocation[entry.Location]
+		if !ok {
+			s = &LocationStats{Location: entry.Location, Oldest: entry.Deleted, Newest: entry.Deleted}
+			byLocation[entry.Location] = s
+		}
+		s.Count++
+		s.Bytes += entry.Size
+		if entry.Deleted.Before(s.Oldest) {
+			s.Oldest = entry.Deleted
+		}
+		if entry.Deleted.After(s.Newest) {
+			s.Newest = entry.Deleted
+		}
+	}
+	stats := []LocationStats{}
+	for _, s := range byLocation {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Location < stats[j].Location })
+	return stats, nil
+}
+
+// Locations lists the trash directories that hold entries, sorted
+func (ix *Index) Locations() ([]string, error) {
+	stats, err := ix.Stats()
+	if err != nil {
+		return nil, err
+	}
+	locations := []string{}
+	for _, s := range stats {
+		locations = append(locations, s.Location)
+	}
+	return locations, nil
+}
+
+// snapshot is the index and its segments as far as each held whole rows when
+// opened, read one after the other. Offsets are into that whole, as srm's
+// own are; srm's copy of this is rowFiles.
+type snapshot struct {
+	files []*os.File
+	sizes []int64
+}
+
+// open opens the index and its segments, listing them again when one is
+// folded away between listing and opening it: its rows are then in the
+// index, past where it was opened
+func (ix *Index) open() (*snapshot, error) {
+	for try := 0; ; try++ {
+		snap, gone, err := ix.tryOpen()
+		if !gone || try == 4 {
+			return snap, err
+		}
+	}
+}
+
+func (ix *Index) tryOpen() (snap *snapshot, gone bool, err error) {
+	paths := []string{ix.path}
+	des, err := os.ReadDir(ix.path + ".d")
+	if err != nil && !os.IsNotExist(err) {
+		return nil, false, err
+	}
+	names := []string{}
+	for _, de := range des {
+		stamp, _, ok := strings.Cut(de.Name(), "-")
+		if ok && len(stamp) == 20 {
+			names = append(names, de.Name())
+		}
+	}
+	// by name, one being folded ahead of one of the same name
+	sort.Slice(names, func(i, j int) bool {
+		a, b := strings.TrimSuffix(names[i], folding), strings.TrimSuffix(names[j], folding)
+		if a != b {
+			return a < b
+		}
+		return strings.HasSuffix(names[i], folding)
+	})
+	for _, name := range names {
+		paths = append(paths, filepath.Join(ix.path+".d", name))
+	}
+
+	snap = &snapshot{}
+	for i, path := range paths {
+		file, err := os.Open(path)
+		if os.IsNotExist(err) && i > 0 && !strings.HasSuffix(path, folding) {
+			file, err = os.Open(path + folding)
+		}
+		if os.IsNotExist(err) {
+			if i == 0 {
+				continue
+			}
+			snap.Close()
+			return nil, true, err
+		}
+		if err != nil {
+			snap.Close()
+			return nil, false, err
+		}
+		size, err := wholeRows(file)
+		if err != nil {
+			file.Close()
+			snap.Close()
+			return nil, false, err
+		}
+		snap.files = append(snap.files, file)
+		snap.sizes = append(snap.sizes, size)
+	}
+	return snap, false, nil
+}
+
+// wholeRows is how much of file ends with a newline: a last row without
+// one is still being written, and would run into the next file's first
+func wholeRows(file *os.File) (int64, error) {
+	fi, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 4096)
+	for end := fi.Size(); end > 0; {
+		start := max(end-int64(len(buf)), 0)
+		n, err := file.ReadAt(buf[:end-start], start)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			return start + int64(i) + 1, nil
+		}
+		end = start
+	}
+	return 0, nil
+}
+
+func (s *snapshot) reader() io.Reader {
+	readers := []io.Reader{}
+	for i, file := range s.files {
+		readers = append(readers, io.NewSectionReader(file, 0, s.sizes[i]))
+	}
+	return io.MultiReader(readers...)
+}
+
+// section reads from offset to the end of the file it falls in
+func (s *snapshot) section(offset int64) (*io.SectionReader, error) {
+	for i, file := range s.files {
+		if offset < s.sizes[i] {
+			return io.NewSectionReader(file, offset, s.sizes[i]-offset), nil
+		}
+		offset -= s.sizes[i]
+	}
+	return nil, io.EOF
+}
+
+func (s *snapshot) Close() error {
+	for _, file := range s.files {
+		file.Close()
+	}
+	return nil
+}
+
+// row is an index row as srm writes it. srm's own copy of this is
+// IndexEntry; the two read the same JSON.
+type row struct {
+	ID        string    `json:"id"`
+	Trash     string    `json:"trash"`
+	Name      string    `json:"name"`
+	Origin    string    `json:"origin"`
+	Deleted   time.Time `json:"deleted"`
+	Size      int64     `json:"size"`
+	IsDir     bool      `json:"dir"`
+	Archive   string    `json:"archive"`
+	Reason    string    `json:"reason"`
+	RawTrash  []byte    `json:"trash_raw"`
+	RawName   []byte    `json:"name_raw"`
+	RawOrigin []byte    `json:"origin_raw"`
+	Gone      bool      `json:"gone"`
+}
+
+// entry is r as an Entry, with the exact bytes of paths that aren't UTF-8
+// and the reason decoded
+func (r row) entry(offset int64) Entry {
+	e := Entry{
+		ID:       r.ID,
+		Location: r.Trash,
+		Name:     r.Name,
+		Origin:   r.Origin,
+		Deleted:  r.Deleted,
+		Size:     r.Size,
+		IsDir:    r.IsDir,
+		Archive:  r.Archive,
+		Reason:   r.Reason,
+		Offset:   offset,
+	}
+	if r.RawTrash != nil {
+		e.Location = string(r.RawTrash)
+	}
+	if r.RawName != nil {
+		e.Name = string(r.RawName)
+	}
+	if r.RawOrigin != nil {
+		e.Origin = string(r.RawOrigin)
+	}
+	if reason, err := url.PathUnescape(r.Reason); err == nil {
+		e.Reason = reason
+	}
+	return e
+}
+
+// decodeRow parses one row, ok false for one that doesn't parse
+func decodeRow(line []byte, offset int64) (Entry, bool) {
+	var r row
+	if json.Unmarshal(line, &r) != nil || r.ID == "" || r.Gone {
+		return Entry{}, false
+	}
+	return r.entry(offset), true
+}
+
+// SplitRows is the bufio.SplitFunc for srm's row files. A last row without
+// its newline is left out: it is still being written, or was cut short, and
+// either way isn't a row yet.
+func SplitRows(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), nil, nil
+	}
+	return 0, nil, nil
+}
+
+// rowScanner reads the rows of r, split by SplitRows
+func rowScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), MAXROW)
+	scanner.Split(SplitRows)
+	return scanner
+}
+
+// LiveRows returns, in order, the numbers of the rows of r that are the
+// last word on their ID and not a gone row, and how many rows didn't parse.
+// Rows are only ever appended, so a later row for an ID replaces an earlier
+// one and a gone row cancels it. Only 16 bytes per row are held.
+func LiveRows(r io.Reader) ([]uint32, int, error) {
+	const gone = 1 << 31
+	type rowRef struct {
+		hash uint64
+		row  uint32 // high bit set for gone rows
+	}
+
+	refs := []rowRef{}
+	bad := 0
+	var n uint32
+	scanner := rowScanner(r)
+	for scanner.Scan() {
+		var key struct {
+			ID   string `json:"id"`
+			Gone bool   `json:"gone"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &key) == nil && key.ID != "" {
+			h := fnv.New64a()
+			h.Write([]byte(key.ID))
+			ref := rowRef{hash: h.Sum64(), row: n}
+			if key.Gone {
+				ref.row |= gone
+			}
+			refs = append(refs, ref)
+		} else if len(scanner.Bytes()) > 0 {
+			bad++
+		}
+		n++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	// rows of the same ID end up together, in row order, and the last wins
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].hash != refs[j].hash {
+			return refs[i].hash < refs[j].hash
+		}
+		return refs[i].row&^gone < refs[j].row&^gone
+	})
+	live := []uint32{}
+	for i, ref := range refs {
+		if i+1 < len(refs) && refs[i+1].hash == ref.hash {
+			continue
+		}
+		if ref.row&gone == 0 {
+			live = append(live, ref.row)
+		}
+	}
+	sort.Slice(live, func(i, j int) bool { return live[i] < live[j] })
+	return live, bad, nil
+}
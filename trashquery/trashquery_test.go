@@ -0,0 +1,65 @@
+package trashquery_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shanahanjrs/srm/remove"
+	"github.com/shanahanjrs/srm/trashquery"
+)
+
+// trashquery reads back what srm's index wrote, in the same order
+func TestListEntries(t *testing.T) {
+	dir := t.TempDir()
+	index := remove.NewIndex(filepath.Join(dir, "index"))
+	trashes := []string{filepath.Join(dir, "trash"), filepath.Join(dir, "other")}
+	deleted := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	written := []remove.IndexEntry{
+		{ID: "a", Trash: trashes[0], Name: "a", Origin: "/work/a", Deleted: deleted, Size: 10},
+		{ID: "b", Trash: trashes[0], Name: "b", Origin: "/work/b", Deleted: deleted.Add(time.Hour), Size: 20, IsDir: true},
+		{ID: "c", Trash: trashes[1], Name: "c.tar.gz", Origin: "/work/c", Deleted: deleted, Archive: "tar.gz"},
+	}
+	for _, entry := range written {
+		if err := index.Append(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	query := trashquery.Open(index.Path())
+	i := 0
+	for got, err := range query.ListEntries(trashquery.Filter{}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == len(written) {
+			t.Fatalf("lists %s, which the index doesn't have", got.Name)
+		}
+		want := written[i]
+		if got.ID != want.ID || got.Location != want.Trash || got.Name != want.Name || got.Origin != want.Origin || !got.Deleted.Equal(want.Deleted) || got.Size != want.Size || got.IsDir != want.IsDir || got.Archive != want.Archive {
+			t.Errorf("reads %+v as %+v", want, got)
+		}
+		if got.Payload() != want.Payload() {
+			t.Errorf("payload %s, want %s", got.Payload(), want.Payload())
+		}
+		i++
+	}
+	if i != len(written) {
+		t.Errorf("lists %d entries, the index has %d", i, len(written))
+	}
+
+	stats, err := query.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]int{}
+	for _, s := range stats {
+		counts[s.Location] = s.Count
+		if s.Location == trashes[0] && (s.Bytes != 30 || !s.Oldest.Equal(deleted) || !s.Newest.Equal(deleted.Add(time.Hour))) {
+			t.Errorf("stats for %s are %+v", s.Location, s)
+		}
+	}
+	if len(counts) != 2 || counts[trashes[0]] != 2 || counts[trashes[1]] != 1 {
+		t.Errorf("stats count %v", counts)
+	}
+}
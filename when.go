@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WhenRange is a half-open span of time [From, To). A zero bound is open.
+type WhenRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// Contains reports whether t falls inside the range
+func (r WhenRange) Contains(t time.Time) bool {
+	if !r.From.IsZero() && t.Before(r.From) {
+		return false
+	}
+	if !r.To.IsZero() && !t.Before(r.To) {
+		return false
+	}
+	return true
+}
+
+const whenForms = `accepted forms:
+    today, yesterday        that whole day
+    2024-06-01              that whole day
+    2024-06-01..2024-06-03  the 1st through the end of the 3rd
+    -7d.. or -12h..         from then until now
+    ..2024-06-01            everything before the end of that day
+    2024-06-01 14:00..      from that minute on`
+
+// parseWhen
+// turns a --when expression into a range, with days taken in now's location.
+// Either side of A..B may be left out, and a day used as the upper bound
+// includes the whole of that day.
+func parseWhen(expr string, now time.Time) (WhenRange, error) {
+	invalid := fmt.Errorf("invalid --when %q, %s", expr, whenForms)
+
+	expr = strings.TrimSpace(expr)
+	if expr == "" || expr == ".." {
+		return WhenRange{}, invalid
+	}
+
+	from, to, isRange := strings.Cut(expr, "..")
+	if !isRange {
+		start, end, err := whenBound(expr, now)
+		if err != nil {
+			return WhenRange{}, invalid
+		}
+		// a single instant or relative time runs until now
+		return WhenRange{From: start, To: end}, nil
+	}
+
+	var r WhenRange
+	var err error
+	if from = strings.TrimSpace(from); from != "" {
+		if r.From, _, err = whenBound(from, now); err != nil {
+			return WhenRange{}, invalid
+		}
+	}
+	if to = strings.TrimSpace(to); to != "" {
+		start, end, err := whenBound(to, now)
+		if err != nil {
+			return WhenRange{}, invalid
+		}
+		r.To = end
+		if end.IsZero() {
+			r.To = start
+		}
+	}
+	if !r.From.IsZero() && !r.To.IsZero() && !r.From.Before(r.To) {
+		return WhenRange{}, fmt.Errorf("invalid --when %q: the range is empty", expr)
+	}
+	return r, nil
+}
+
+// whenBound parses one side of a --when range. Days give their start and the
+// start of the next day, anything more precise gives just the instant.
+func whenBound(value string, now time.Time) (start time.Time, end time.Time, err error) {
+	midnight := func(t time.Time, days int) time.Time {
+		// going through time.Date rather than adding 24h keeps DST days right
+		return time.Date(t.Year(), t.Month(), t.Day()+days, 0, 0, 0, 0, now.Location())
+	}
+
+	switch value {
+	case "now":
+		return now, time.Time{}, nil
+	case "today":
+		return midnight(now, 0), midnight(now, 1), nil
+	case "yesterday":
+		return midnight(now, -1), midnight(now, 0), nil
+	}
+
+	if ago, ok := strings.CutPrefix(value, "-"); ok {
+		if days, ok := strings.CutSuffix(ago, "d"); ok {
+			if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+				return now.AddDate(0, 0, -n), time.Time{}, nil
+			}
+		}
+		if weeks, ok := strings.CutSuffix(ago, "w"); ok {
+			if n, err := strconv.Atoi(weeks); err == nil && n >= 0 {
+				return now.AddDate(0, 0, -7*n), time.Time{}, nil
+			}
+		}
+		if d, err := time.ParseDuration(ago); err == nil && d >= 0 {
+			return now.Add(-d), time.Time{}, nil
+		}
+		return time.Time{}, time.Time{}, fmt.Errorf("bad relative time %q", value)
+	}
+
+	if day, err := time.ParseInLocation("2006-01-02", value, now.Location()); err == nil {
+		return day, midnight(day, 1), nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02 15:04:05", "2006-01-02T15:04"} {
+		if t, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+			return t, time.Time{}, nil
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, time.Time{}, nil
+	}
+
+	return time.Time{}, time.Time{}, fmt.Errorf("bad time %q", value)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseWhen(t *testing.T) {
+	ist := time.FixedZone("IST", 5*3600+1800)
+	now := time.Date(2024, 6, 5, 10, 30, 0, 0, ist)
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, ist) }
+
+	tests := []struct {
+		expr string
+		want WhenRange
+	}{
+		{"today", WhenRange{day(2024, 6, 5), day(2024, 6, 6)}},
+		{" today ", WhenRange{day(2024, 6, 5), day(2024, 6, 6)}},
+		{"yesterday", WhenRange{day(2024, 6, 4), day(2024, 6, 5)}},
+		{"2024-06-01", WhenRange{day(2024, 6, 1), day(2024, 6, 2)}},
+		{"2024-06-01..2024-06-03", WhenRange{day(2024, 6, 1), day(2024, 6, 4)}},
+		{"2024-06-01 .. 2024-06-03", WhenRange{day(2024, 6, 1), day(2024, 6, 4)}},
+		{"2024-06-01..", WhenRange{From: day(2024, 6, 1)}},
+		{"..2024-06-01", WhenRange{To: day(2024, 6, 2)}},
+		{"-7d..", WhenRange{From: now.AddDate(0, 0, -7)}},
+		{"-7d", WhenRange{From: now.AddDate(0, 0, -7)}},
+		{"-2w..", WhenRange{From: now.AddDate(0, 0, -14)}},
+		{"-12h..", WhenRange{From: now.Add(-12 * time.Hour)}},
+		{"-90m..-30m", WhenRange{now.Add(-90 * time.Minute), now.Add(-30 * time.Minute)}},
+		{"yesterday..now", WhenRange{day(2024, 6, 4), now}},
+		{"..now", WhenRange{To: now}},
+		{"2024-06-01 14:00..", WhenRange{From: time.Date(2024, 6, 1, 14, 0, 0, 0, ist)}},
+		{"2024-06-01T14:00..2024-06-01 15:30:15", WhenRange{time.Date(2024, 6, 1, 14, 0, 0, 0, ist), time.Date(2024, 6, 1, 15, 30, 15, 0, ist)}},
+		// an explicit offset wins over now's location
+		{"2024-06-01T00:00:00Z..", WhenRange{From: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)}},
+	}
+	for _, tt := range tests {
+		got, err := parseWhen(tt.expr, now)
+		if err != nil {
+			t.Errorf("parseWhen(%q): %v", tt.expr, err)
+			continue
+		}
+		if !got.From.Equal(tt.want.From) || !got.To.Equal(tt.want.To) {
+			t.Errorf("parseWhen(%q) = [%v, %v), want [%v, %v)", tt.expr, got.From, got.To, tt.want.From, tt.want.To)
+		}
+	}
+}
+
+func TestParseWhenInvalid(t *testing.T) {
+	now := time.Date(2024, 6, 5, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"", "accepted forms"},
+		{"..", "accepted forms"},
+		{"tomorrow", "accepted forms"},
+		{"-7x..", "accepted forms"},
+		{"--7d", "accepted forms"},
+		{"-d", "accepted forms"},
+		{"2024-13-01", "accepted forms"},
+		{"2024-06-01..soon", "accepted forms"},
+		{"2024-06-03..2024-06-01", "the range is empty"},
+		{"now..now", "the range is empty"},
+		{"now..-1h", "the range is empty"},
+	}
+	for _, tt := range tests {
+		_, err := parseWhen(tt.expr, now)
+		if err == nil {
+			t.Errorf("parseWhen(%q) succeeded, want an error", tt.expr)
+		} else if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseWhen(%q) = %q, want it to mention %q", tt.expr, err, tt.want)
+		}
+	}
+}
+
+// A day is midnight to midnight on the calendar, however long it is
+func TestParseWhenDST(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no time zone database: %v", err)
+	}
+	tests := []struct {
+		name  string
+		now   time.Time
+		expr  string
+		hours float64
+	}{
+		{"spring forward", time.Date(2024, 3, 10, 12, 0, 0, 0, newYork), "today", 23},
+		{"fall back", time.Date(2024, 11, 3, 12, 0, 0, 0, newYork), "today", 25},
+		{"day after spring forward", time.Date(2024, 3, 11, 12, 0, 0, 0, newYork), "yesterday", 23},
+		{"range across fall back", time.Date(2024, 11, 5, 12, 0, 0, 0, newYork), "2024-11-02..2024-11-03", 49},
+	}
+	for _, tt := range tests {
+		got, err := parseWhen(tt.expr, tt.now)
+		if err != nil {
+			t.Errorf("%s: parseWhen(%q): %v", tt.name, tt.expr, err)
+			continue
+		}
+		if hours := got.To.Sub(got.From).Hours(); hours != tt.hours {
+			t.Errorf("%s: parseWhen(%q) spans %vh, want %vh", tt.name, tt.expr, hours, tt.hours)
+		}
+		if h, m, _ := got.From.In(newYork).Clock(); h != 0 || m != 0 {
+			t.Errorf("%s: parseWhen(%q) starts at %v, not midnight", tt.name, tt.expr, got.From)
+		}
+	}
+}
+
+func TestWhenRangeContains(t *testing.T) {
+	from := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		r    WhenRange
+		t    time.Time
+		want bool
+	}{
+		{"at the start", WhenRange{from, to}, from, true},
+		{"inside", WhenRange{from, to}, from.Add(time.Hour), true},
+		{"just before the end", WhenRange{from, to}, to.Add(-time.Nanosecond), true},
+		{"at the end", WhenRange{from, to}, to, false},
+		{"before the start", WhenRange{from, to}, from.Add(-time.Nanosecond), false},
+		{"open end", WhenRange{From: from}, to.AddDate(10, 0, 0), true},
+		{"open start", WhenRange{To: to}, time.Time{}.Add(time.Hour), true},
+		{"open start, at the end", WhenRange{To: to}, to, false},
+		{"open both ways", WhenRange{}, to, true},
+		{"another zone, same instant", WhenRange{from, to}, to.In(time.FixedZone("", -3600)), false},
+	}
+	for _, tt := range tests {
+		if got := tt.r.Contains(tt.t); got != tt.want {
+			t.Errorf("%s: Contains(%v) = %v, want %v", tt.name, tt.t, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/shanahanjrs/srm/remove"
+)
+
+// whichCommand
+// srm which <path>
+// lists every trashed generation of exactly path, newest first, and says
+// whether the live file matches the newest one. Exits 1 when the trash holds
+// none, so scripts can ask whether there is a copy to fall back on.
+func whichCommand(args []string) {
+	_, rest := parseArgs(args)
+	if len(rest) != 1 {
+		fmt.Fprintln(os.Stderr, "srm which: expected exactly one path")
+		os.Exit(1)
+	}
+
+	abs, err := filepath.Abs(rest[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm which: %s\n", err)
+		os.Exit(1)
+	}
+
+	index, err := remove.OpenIndex()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm which: %s\n", err)
+		os.Exit(1)
+	}
+	generations := []remove.IndexEntry{}
+	err = index.Scan(func(entry remove.IndexEntry, offset int64) bool {
+		if entry.Origin == abs {
+			generations = append(generations, entry)
+		}
+		return true
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "srm which: %s\n", err)
+		os.Exit(1)
+	}
+	if len(generations) == 0 {
+		fmt.Fprintf(os.Stderr, "srm which: %s: not in the trash\n", remove.DisplayName(abs))
+		os.Exit(1)
+	}
+	sort.SliceStable(generations, func(i, j int) bool {
+		return generations[i].Deleted.After(generations[j].Deleted)
+	})
+
+	fmt.Printf("%s: %d in the trash\n", remove.DisplayName(remove.DisplayPath(abs)), len(generations))
+	sums := make([]string, len(generations))
+	for i, entry := range generations {
+		sum, err := payloadChecksum(remove.OSFS{}, entry.Payload())
+		if err != nil {
+			sum = "unreadable: " + err.Error()
+		}
+		sums[i] = sum
+		fmt.Printf("%s\t%s\t%s\t%s\n", entry.ID, entry.Deleted.Local().Format(time.RFC3339), remove.FormatSize(entry.Size), sum)
+	}
+
+	fmt.Println(liveStatus(abs, generations[0], sums[0]))
+}
+
+// liveStatus says whether the file at abs exists and matches the newest
+// trashed generation, whose payload checksum is newest
+func liveStatus(abs string, newest remove.IndexEntry, newestSum string) string {
+	if _, err := os.Lstat(abs); errors.Is(err, fs.ErrNotExist) {
+		return "live: does not exist"
+	} else if err != nil {
+		return "live: " + err.Error()
+	}
+
+	// an archive's checksum is the tarball's, not the tree's
+	if newest.Archive != "" {
+		return "live: exists, newest generation is archived so it isn't compared"
+	}
+	sum, err := payloadChecksum(remove.OSFS{}, abs)
+	switch {
+	case err != nil:
+		return "live: exists, unreadable: " + err.Error()
+	case sum == newestSum:
+		return "live: exists, same as " + newest.ID
+	}
+	return "live: exists, differs from " + newest.ID
+}